@@ -0,0 +1,29 @@
+// Command bench runs the hot-path benchmarks in internal/bench with memory
+// stats, printing go test's standard benchmark lines so runs before and
+// after a change can be compared with benchstat.
+//
+//	go run ./cmd/bench [-run regexp] [-count n]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+func main() {
+	filter := flag.String("run", ".", "only run benchmarks matching this regexp")
+	count := flag.Int("count", 1, "run each benchmark this many times")
+	flag.Parse()
+
+	cmd := exec.Command("go", "test", "-run", "^$", "-bench", *filter, "-benchmem",
+		"-count", strconv.Itoa(*count), "labyrinth-duel/websocket/internal/bench")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"labyrinth-duel/websocket/internal/game"
+	"labyrinth-duel/websocket/internal/messages"
+	"labyrinth-duel/websocket/internal/room"
+	"labyrinth-duel/websocket/sdk"
+)
+
+// botCaps are the capabilities bots ask for; the SDK expands compact mazes
+// again, so this only saves bandwidth
+var botCaps = []string{messages.CapCompactMaze}
+
+// bot is one headless player
+type bot struct {
+	name     string
+	url      string
+	baseRoom string
+	strategy string
+	encoding string
+	rematch  bool
+
+	client *sdk.Client
+	brain  strategy
+	view   view
+	state  string
+	match  int // Rooms played so far, for naming the next one
+
+	// Lifetime stats, read after stop
+	matches, wins, moves int
+
+	mu   sync.Mutex
+	done chan struct{}
+}
+
+func newBot(name, url, roomID, strategyName, encoding string, rematch bool) *bot {
+	return &bot{
+		name:     name,
+		url:      url,
+		baseRoom: roomID,
+		strategy: strategyName,
+		encoding: encoding,
+		rematch:  rematch,
+		brain:    strategies[strategyName](),
+		done:     make(chan struct{}),
+	}
+}
+
+// start connects, joins the first room and begins moving
+func (b *bot) start() error {
+	client, err := sdk.Dial(sdk.Options{
+		URL:       b.url,
+		Encoding:  b.encoding,
+		Caps:      botCaps,
+		OnMessage: b.handle,
+		OnState: func(state sdk.State, err error) {
+			if err != nil {
+				log.Printf("%s: %s: %v", b.name, state, err)
+			}
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	b.client = client
+	b.mu.Unlock()
+	b.join()
+
+	go b.run()
+	return nil
+}
+
+// stop disconnects the bot
+func (b *bot) stop() {
+	close(b.done)
+	b.mu.Lock()
+	client := b.client
+	b.mu.Unlock()
+	client.Close()
+}
+
+// join enters the current room; rooms after the first get a numbered name
+func (b *bot) join() {
+	b.mu.Lock()
+	b.match++
+	roomID := b.baseRoom
+	if b.match > 1 {
+		roomID = fmt.Sprintf("%s-%d", b.baseRoom, b.match)
+	}
+	b.view = view{me: b.view.me}
+	b.state = ""
+	b.brain.reset()
+	client := b.client
+	b.mu.Unlock()
+
+	client.Send(sdk.ClientMessage{Type: "join", RoomID: roomID})
+}
+
+// run moves once per move interval while a match is on
+func (b *bot) run() {
+	// A little jitter keeps a crowd of bots from moving in lockstep
+	interval := room.BaseMoveInterval + time.Duration(rand.Intn(20))*time.Millisecond
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.done:
+			return
+		case <-ticker.C:
+		}
+
+		b.mu.Lock()
+		if b.state != string(room.StatePlaying) || !b.view.known() {
+			b.mu.Unlock()
+			continue
+		}
+		next, ok := b.brain.next(&b.view)
+		client := b.client
+		if ok {
+			b.moves++
+		}
+		b.mu.Unlock()
+
+		if ok {
+			client.Send(sdk.ClientMessage{Type: "move", X: next.X, Y: next.Y})
+		}
+	}
+}
+
+// handle folds a server message into what the bot knows
+func (b *bot) handle(msg sdk.ServerMessage) {
+	for _, inner := range msg.Batch {
+		b.handle(inner)
+	}
+
+	b.mu.Lock()
+	if msg.Type == "connected" {
+		b.view.me = msg.Message
+	}
+	if msg.Maze != nil {
+		b.view.setMaze(msg.Maze)
+	}
+	b.view.addCells(msg.Cells)
+	for _, p := range msg.Players {
+		if p.ID == b.view.me {
+			b.view.at = game.Point{X: p.X, Y: p.Y}
+			b.view.level = p.Level
+		}
+	}
+	if msg.Type == "playerMoved" && msg.Message == b.view.me {
+		b.view.moveTo(*msg.Position)
+	}
+	if msg.State != "" {
+		b.state = msg.State
+	}
+	client := b.client
+	b.mu.Unlock()
+
+	switch msg.Type {
+	case "mazeData":
+		client.Send(sdk.ClientMessage{Type: "ready"})
+	case "gameOver":
+		b.mu.Lock()
+		b.matches++
+		if msg.Winner == b.view.me {
+			b.wins++
+		}
+		b.mu.Unlock()
+
+		if b.rematch {
+			// Give humans a moment to see the result before the bot leaves
+			go func() {
+				select {
+				case <-time.After(3 * time.Second):
+					b.rejoin()
+				case <-b.done:
+				}
+			}()
+		}
+	}
+}
+
+// rejoin leaves the finished room by reconnecting and joins the next one.
+// The server has no leave message, so dropping the connection is the way
+// out.
+func (b *bot) rejoin() {
+	b.mu.Lock()
+	old := b.client
+	b.mu.Unlock()
+	old.Close()
+
+	client, err := sdk.Dial(sdk.Options{URL: b.url, Encoding: b.encoding, Caps: botCaps, OnMessage: b.handle})
+	if err != nil {
+		log.Printf("%s: rejoin: %v", b.name, err)
+		return
+	}
+	b.mu.Lock()
+	b.client = client
+	b.mu.Unlock()
+	b.join()
+}
@@ -0,0 +1,74 @@
+// Command botrunner connects headless bots to a server, for demos, filling
+// community servers and soak testing.
+//
+//	botrunner -url ws://localhost:8080/ws -bots 8 -rooms lobby,arena -strategy mix
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+)
+
+func main() {
+	url := flag.String("url", "ws://localhost:8080/ws", "server WebSocket endpoint")
+	count := flag.Int("bots", 2, "number of bots")
+	rooms := flag.String("rooms", "bots", "comma-separated rooms; bots are spread over them in turn")
+	strategyName := flag.String("strategy", "mix", "random, racer, camper, or mix (cycle through all)")
+	duration := flag.Duration("duration", 0, "stop after this long (0 = until interrupted)")
+	encoding := flag.String("encoding", "", "wire format: json or msgpack")
+	rematch := flag.Bool("rematch", true, "after a match, move on to a fresh room (<room>-2, <room>-3, ...)")
+	flag.Parse()
+
+	roomIDs := strings.Split(*rooms, ",")
+	if *strategyName != "mix" {
+		if _, ok := strategies[*strategyName]; !ok {
+			fmt.Fprintf(os.Stderr, "unknown strategy %q\n", *strategyName)
+			os.Exit(2)
+		}
+	}
+
+	bots := make([]*bot, 0, *count)
+	for i := 0; i < *count; i++ {
+		name := *strategyName
+		if name == "mix" {
+			name = strategyNames[i%len(strategyNames)]
+		}
+		b := newBot(fmt.Sprintf("bot-%d", i+1), *url, roomIDs[i%len(roomIDs)], name, *encoding, *rematch)
+		if err := b.start(); err != nil {
+			log.Fatalf("%s: %v", b.name, err)
+		}
+		bots = append(bots, b)
+	}
+	log.Printf("Started %d bots in %s", len(bots), strings.Join(roomIDs, ", "))
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt)
+	var timeout <-chan time.Time
+	if *duration > 0 {
+		timeout = time.After(*duration)
+	}
+	select {
+	case <-stop:
+	case <-timeout:
+	}
+
+	var wg sync.WaitGroup
+	for _, b := range bots {
+		wg.Add(1)
+		go func(b *bot) {
+			defer wg.Done()
+			b.stop()
+		}(b)
+	}
+	wg.Wait()
+
+	for _, b := range bots {
+		log.Printf("%s (%s): %d matches, %d wins, %d moves", b.name, b.strategy, b.matches, b.wins, b.moves)
+	}
+}
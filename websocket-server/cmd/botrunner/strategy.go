@@ -0,0 +1,175 @@
+package main
+
+import (
+	"math/rand"
+
+	"labyrinth-duel/websocket/internal/game"
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+// strategy picks a bot's next cell to move to
+type strategy interface {
+	// next returns the neighbouring cell to move to, or false to stay put
+	next(v *view) (messages.Position, bool)
+	// reset forgets everything about the previous match
+	reset()
+}
+
+// strategies builds each strategy by name
+var strategies = map[string]func() strategy{
+	"random": func() strategy { return &randomWalk{} },
+	"racer":  func() strategy { return &racer{} },
+	"camper": func() strategy { return &camper{} },
+}
+
+// strategyNames is the order -strategy mix cycles through
+var strategyNames = []string{"random", "racer", "camper"}
+
+// view is what a bot knows of the maze, kept as a game.Maze so bots plan
+// with the server's own pathfinding. Under fog, cells not seen yet are
+// open on every side.
+type view struct {
+	me    string
+	at    game.Point
+	level int
+	goals []game.Point
+	maze  *game.Maze
+}
+
+func (v *view) known() bool {
+	return v.maze != nil
+}
+
+func (v *view) setMaze(m *messages.MazeData) {
+	v.goals = v.goals[:0]
+	for _, g := range m.Goals {
+		v.goals = append(v.goals, game.Point{X: g.X, Y: g.Y})
+	}
+	if len(v.goals) == 0 {
+		v.goals = append(v.goals, game.Point{X: m.Goal.X, Y: m.Goal.Y})
+	}
+
+	v.maze = &game.Maze{Width: m.Width, Height: m.Height, Cells: make([][]game.Cell, m.Height)}
+	for y := range v.maze.Cells {
+		v.maze.Cells[y] = make([]game.Cell, m.Width)
+		for x := range v.maze.Cells[y] {
+			v.maze.Cells[y][x] = game.Cell{X: x, Y: y}
+		}
+	}
+	for _, row := range m.Cells {
+		v.addCells(row)
+	}
+}
+
+func (v *view) addCells(cells []messages.Cell) {
+	if v.maze == nil {
+		return
+	}
+	for _, c := range cells {
+		if !v.maze.InBounds(c.X, c.Y) {
+			continue
+		}
+		v.maze.Cells[c.Y][c.X] = game.Cell{
+			X: c.X, Y: c.Y,
+			Top: c.Top, Right: c.Right, Bottom: c.Bottom, Left: c.Left,
+			Terrain: game.Terrain(c.Terrain),
+			Under:   c.Under,
+		}
+	}
+}
+
+// moveTo follows the bot to a cell it moved to, working out which level of
+// a crossing it ended up on
+func (v *view) moveTo(p messages.Position) {
+	to := game.Point{X: p.X, Y: p.Y}
+	level := game.LevelSurface
+	if v.maze != nil {
+		if l, ok := v.maze.Step(v.at, v.level, to); ok {
+			level = l
+		}
+	}
+	v.at, v.level = to, level
+}
+
+// neighbours returns the cells reachable in one step from where the bot is
+func (v *view) neighbours() []game.Point {
+	var out []game.Point
+	for _, d := range []string{"up", "right", "down", "left"} {
+		dx, dy, _ := game.Offset(d)
+		to := game.Point{X: v.at.X + dx, Y: v.at.Y + dy}
+		if _, ok := v.maze.Step(v.at, v.level, to); ok {
+			out = append(out, to)
+		}
+	}
+	return out
+}
+
+// firstStep returns the cell after the start of a path, or false if the
+// path is too short to move along
+func firstStep(path []game.Point) (messages.Position, bool) {
+	if len(path) < 2 {
+		return messages.Position{}, false
+	}
+	return messages.Position{X: path[1].X, Y: path[1].Y}, true
+}
+
+// randomWalk wanders, avoiding the cell it just left unless cornered
+type randomWalk struct {
+	prev game.Point
+	has  bool
+}
+
+func (s *randomWalk) next(v *view) (messages.Position, bool) {
+	options := v.neighbours()
+	if len(options) == 0 {
+		return messages.Position{}, false
+	}
+	if len(options) > 1 && s.has {
+		for i, o := range options {
+			if o == s.prev {
+				options = append(options[:i], options[i+1:]...)
+				break
+			}
+		}
+	}
+	s.prev, s.has = v.at, true
+	to := options[rand.Intn(len(options))]
+	return messages.Position{X: to.X, Y: to.Y}, true
+}
+
+func (s *randomWalk) reset() { s.has = false }
+
+// racer heads for the nearest exit along an A* path, replanning every step
+// as fog lifts and walls change
+type racer struct{}
+
+func (racer) next(v *view) (messages.Position, bool) {
+	return firstStep(v.maze.PathFrom(v.at, v.level, v.goals))
+}
+
+func (racer) reset() {}
+
+// camper walks to the cell next to the primary exit and sits there,
+// blocking it under collision rules
+type camper struct{}
+
+func (camper) next(v *view) (messages.Position, bool) {
+	goal := v.goals[0]
+	var posts []game.Point
+	for _, d := range []string{"up", "right", "down", "left"} {
+		dx, dy, _ := game.Offset(d)
+		// Only cells that actually open onto the exit
+		post := game.Point{X: goal.X + dx, Y: goal.Y + dy}
+		if v.maze.CanMove(post.X, post.Y, 0, goal.X, goal.Y, 0) {
+			posts = append(posts, post)
+		}
+	}
+	for _, p := range posts {
+		if p == v.at {
+			return messages.Position{}, false
+		}
+	}
+	return firstStep(v.maze.PathFrom(v.at, v.level, posts))
+}
+
+func (camper) reset() {}
@@ -0,0 +1,39 @@
+// Command schemagen writes the protocol documents that the server also
+// serves under /schema, for client generators and API tooling:
+//
+//	go run ./cmd/schemagen -o docs
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+
+	"labyrinth-duel/websocket/internal/schema"
+)
+
+func main() {
+	out := flag.String("o", ".", "output directory")
+	flag.Parse()
+
+	docs := map[string]schema.Schema{
+		"asyncapi.json": schema.AsyncAPI(),
+		"openapi.json":  schema.OpenAPI(),
+	}
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		log.Fatal(err)
+	}
+	for name, doc := range docs {
+		data, err := json.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		path := filepath.Join(*out, name)
+		if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Wrote %s", path)
+	}
+}
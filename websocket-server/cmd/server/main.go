@@ -1,217 +1,327 @@
 package main
 
 import (
-	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
-	"sync"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
-	"labyrinth-duel/websocket/internal/game"
-	"labyrinth-duel/websocket/internal/messages"
-	"labyrinth-duel/websocket/internal/room"
+	"labyrinth-duel/websocket/internal/bus"
+	"labyrinth-duel/websocket/internal/certs"
+	"labyrinth-duel/websocket/internal/config"
+	"labyrinth-duel/websocket/internal/daily"
+	"labyrinth-duel/websocket/internal/history"
+	"labyrinth-duel/websocket/internal/leaderboard"
+	"labyrinth-duel/websocket/internal/level"
+	"labyrinth-duel/websocket/internal/logging"
+	"labyrinth-duel/websocket/internal/persist"
+	"labyrinth-duel/websocket/internal/rating"
+	"labyrinth-duel/websocket/internal/replay"
+	"labyrinth-duel/websocket/internal/server"
 )
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
+func main() {
+	cfg := loadConfig()
+	setupLogging(cfg)
+	srv := server.New(cfg, newRatingStore(cfg))
+	srv.Replays = newReplayStore(cfg)
+	srv.Levels = newLevelStore(cfg)
+	srv.Daily = newDailyStore(cfg)
+	srv.Leaderboards = leaderboard.New(newLeaderboardStore(cfg))
+	srv.History = newHistoryStore(cfg)
+	srv.RoomStore = newRoomStore(cfg)
+	srv.Suspended = newSuspendedStore(cfg)
+	joinCluster(cfg, srv)
+	if err := srv.RestoreRooms(); err != nil {
+		// Start without them rather than not at all
+		slog.Error("Cannot restore rooms", "err", err)
+	}
+	srv.Run()
+
+	// Stop taking new players, then save the rooms on the way out so a
+	// deploy doesn't lose them
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-stop
+		slog.Info("Draining", "grace", srv.DrainGrace())
+		srv.Drain()
+		time.Sleep(srv.DrainGrace())
+		srv.Stop()
+		os.Exit(0)
+	}()
+
+	if cfg.DevAnyOrigin {
+		slog.Warn("Allowing every origin: development only")
+	}
+	upgrader := websocket.Upgrader{CheckOrigin: srv.CheckOrigin}
+	http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		claims, err := srv.Authenticate(r)
+		if err != nil {
+			slog.Info("Unauthenticated connection", "remote", r.RemoteAddr, "err", err)
+			server.HandleUpgradeError(w, err)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			slog.Info("Upgrade error", "err", err)
+			return
+		}
+		conn.SetReadLimit(server.MaxMessageSize)
+		hs := server.HandshakeFromQuery(r.URL.Query())
+		hs.Claims = claims
+		srv.Serve(conn, hs)
+	})
+	http.HandleFunc("/healthz", srv.HandleHealth)
+	http.HandleFunc("/readyz", srv.HandleReady)
+	http.HandleFunc("/rooms", srv.CORS(srv.HandleRooms))
+	http.HandleFunc("/replays", srv.CORS(srv.HandleReplays))
+	http.HandleFunc("/replays/", srv.CORS(srv.HandleReplays))
+	http.HandleFunc("/leaderboard", srv.CORS(srv.HandleLeaderboard))
+	http.HandleFunc("/admin/", srv.HandleDashboard)
+	http.HandleFunc("/admin/metrics", srv.HandleAdminMetrics)
+	http.HandleFunc("/admin/rooms", srv.HandleAdminRooms)
+	http.HandleFunc("/admin/rooms/", srv.HandleAdminRooms)
+	http.HandleFunc("/admin/players/", srv.HandleAdminPlayers)
+	http.HandleFunc("/admin/announce", srv.HandleAdminAnnounce)
+	http.HandleFunc("/admin/trace", srv.HandleTrace)
+	http.HandleFunc("/schema", srv.CORS(srv.HandleSchema))
+	http.HandleFunc("/schema/", srv.CORS(srv.HandleSchema))
+
+	serve(cfg)
 }
 
-// Global managers
-var roomManager = room.NewManager()
-
-// Client represents a connected WebSocket client
-type Client struct {
-	ID     string
-	Conn   *websocket.Conn
-	RoomID string
-	mu     sync.Mutex
-}
+// serve listens on the configured port until the server fails: over TLS,
+// for wss://, if there is a certificate. A renewed certificate is picked up
+// within cert-check, or at once on SIGHUP, without dropping connections.
+func serve(cfg config.Config) {
+	if !cfg.TLS() {
+		slog.Info("WebSocket server starting", "addr", cfg.Addr())
+		fatal("Server stopped", "err", http.ListenAndServe(cfg.Addr(), nil))
+	}
 
-// Track all clients for broadcasting
-var clients = make(map[string]*Client)
-var clientsMu sync.RWMutex
+	reloader, err := certs.New(cfg.TLSCert, cfg.TLSKey)
+	if err != nil {
+		fatal("Cannot load certificate", "err", err)
+	}
+	go reloader.Watch(cfg.CertCheck, nil)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := reloader.Reload(); err != nil {
+				slog.Error("Cannot reload certificate", "err", err)
+				continue
+			}
+			slog.Info("Reloaded certificate", "cert", cfg.TLSCert)
+		}
+	}()
 
-func main() {
-	http.HandleFunc("/ws", handleWebSocket)
+	if cfg.RedirectPort != 0 {
+		go func() {
+			slog.Info("Redirecting HTTP to HTTPS", "addr", cfg.RedirectAddr())
+			fatal("Redirect listener stopped", "err", http.ListenAndServe(cfg.RedirectAddr(), certs.Redirect(cfg.Port)))
+		}()
+	}
 
-	port := ":8080"
-	fmt.Printf("WebSocket server starting on %s\n", port)
-	log.Fatal(http.ListenAndServe(port, nil))
+	https := &http.Server{Addr: cfg.Addr(), TLSConfig: reloader.TLSConfig()}
+	slog.Info("WebSocket server starting", "addr", cfg.Addr(), "tls", true)
+	fatal("Server stopped", "err", https.ListenAndServeTLS("", ""))
 }
 
-func handleWebSocket(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+// loadConfig reads the settings from the config file, environment and
+// command line, printing them all for -h
+func loadConfig() config.Config {
+	cfg, err := config.Load(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		config.Usage(os.Stdout)
+		os.Exit(0)
+	}
 	if err != nil {
-		log.Printf("Upgrade error: %v", err)
-		return
+		fmt.Fprintf(os.Stderr, "Bad configuration: %v\n", err)
+		config.Usage(os.Stderr)
+		os.Exit(2)
 	}
-	defer conn.Close()
+	return cfg
+}
 
-	// Create client with unique ID
-	client := &Client{
-		ID:   uuid.New().String()[:8],
-		Conn: conn,
+// setupLogging makes every log go through a structured logger at the
+// configured level (debug, info, warn or error; info if unset), written as
+// text or JSON
+func setupLogging(cfg config.Config) {
+	logger, err := logging.New(os.Stderr, cfg.LogLevel, cfg.LogFormat)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot set up logging: %v\n", err)
+		os.Exit(1)
 	}
+	slog.SetDefault(logger)
+}
 
-	// Register client
-	clientsMu.Lock()
-	clients[client.ID] = client
-	clientsMu.Unlock()
-
-	fmt.Printf("Client %s connected\n", client.ID)
-
-	// Send client their ID
-	client.SendJSON(messages.ServerMessage{
-		Type:    "connected",
-		Message: client.ID,
-	})
-
-	// Handle messages
-	for {
-		_, msgBytes, err := conn.ReadMessage()
-		if err != nil {
-			log.Printf("Read error: %v", err)
-			break
-		}
-
-		var msg messages.ClientMessage
-		if err := json.Unmarshal(msgBytes, &msg); err != nil {
-			log.Printf("JSON parse error: %v", err)
-			continue
-		}
+// fatal logs an error the server cannot start or run without, and exits
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
 
-		switch msg.Type {
-		case "join":
-			handleJoin(client, msg)
-		case "move":
-			handleMove(client, msg)
-		}
+// newRatingStore keeps ratings in the configured file, or only in memory
+// if there isn't one
+func newRatingStore(cfg config.Config) rating.Store {
+	path := cfg.RatingsFile
+	if path == "" {
+		return rating.NewMemoryStore()
 	}
 
-	// Cleanup on disconnect
-	handleDisconnect(client)
+	store, err := rating.NewFileStore(path)
+	if err != nil {
+		fatal("Cannot load ratings", "err", err)
+	}
+	return store
 }
 
-func handleJoin(client *Client, msg messages.ClientMessage) {
-	client.RoomID = msg.RoomID
-
-	// Get or create room (creates maze if new)
-	r := roomManager.GetOrCreateRoom(msg.RoomID)
+// newReplayStore keeps replays as files in the replay directory, or only
+// the most recent ones in memory if there isn't one
+func newReplayStore(cfg config.Config) replay.Store {
+	dir := cfg.ReplayDir
+	if dir == "" {
+		return replay.NewMemoryStore(0)
+	}
 
-	// Add player to room at starting position (0, 0)
-	r.AddPlayer(client.ID, 0, 0)
+	store, err := replay.NewFileStore(dir)
+	if err != nil {
+		fatal("Cannot load replays", "err", err)
+	}
+	return store
+}
 
-	fmt.Printf("Client %s joined room %s\n", client.ID, msg.RoomID)
+// newLevelStore keeps uploaded levels as files in the level directory, or
+// only the most recent ones in memory if there isn't one
+func newLevelStore(cfg config.Config) level.Store {
+	dir := cfg.LevelDir
+	if dir == "" {
+		return level.NewMemoryStore(0)
+	}
 
-	// Convert maze to message format
-	mazeData := convertMazeToMessage(r.Maze)
+	store, err := level.NewFileStore(dir)
+	if err != nil {
+		fatal("Cannot open level directory", "err", err)
+	}
+	return store
+}
 
-	// Send maze to the joining player
-	client.SendJSON(messages.ServerMessage{
-		Type:    "mazeData",
-		Maze:    mazeData,
-		Players: r.GetPlayers(),
-	})
+// newDailyStore keeps the daily challenge leaderboards as files in the
+// daily directory, or only in memory if there isn't one
+func newDailyStore(cfg config.Config) daily.Store {
+	dir := cfg.DailyDir
+	if dir == "" {
+		return daily.NewMemoryStore()
+	}
 
-	// Notify other players in room
-	broadcastToRoom(msg.RoomID, messages.ServerMessage{
-		Type:    "playerJoined",
-		Message: client.ID,
-		Players: r.GetPlayers(),
-	}, client.ID) // Exclude the joining player
+	store, err := daily.NewFileStore(dir)
+	if err != nil {
+		fatal("Cannot load daily leaderboards", "err", err)
+	}
+	return store
 }
 
-func handleMove(client *Client, msg messages.ClientMessage) {
-	if client.RoomID == "" {
-		return
+// newLeaderboardStore keeps the leaderboards as files in the leaderboard
+// directory, or only in memory if there isn't one
+func newLeaderboardStore(cfg config.Config) leaderboard.Store {
+	dir := cfg.LeaderboardDir
+	if dir == "" {
+		return leaderboard.NewMemoryStore()
 	}
 
-	r := roomManager.GetRoom(client.RoomID)
-	if r == nil {
-		return
+	store, err := leaderboard.NewFileStore(dir)
+	if err != nil {
+		fatal("Cannot load leaderboards", "err", err)
 	}
+	return store
+}
 
-	// Validate and update position (server validates against maze!)
-	if !r.UpdatePlayerPosition(client.ID, msg.X, msg.Y) {
-		fmt.Printf("Client %s invalid move to (%d, %d)\n", client.ID, msg.X, msg.Y)
-		return
+// newHistoryStore keeps match history in the configured file, or only the
+// most recent matches in memory if there isn't one
+func newHistoryStore(cfg config.Config) history.Store {
+	path := cfg.HistoryFile
+	if path == "" {
+		return history.NewMemoryStore(0)
 	}
 
-	fmt.Printf("Client %s moved to (%d, %d)\n", client.ID, msg.X, msg.Y)
-
-	// Broadcast to all players in room
-	broadcastToRoom(client.RoomID, messages.ServerMessage{
-		Type:    "gameState",
-		Players: r.GetPlayers(),
-	}, "")
+	store, err := history.NewFileStore(path, 0)
+	if err != nil {
+		fatal("Cannot load match history", "err", err)
+	}
+	return store
 }
 
-func handleDisconnect(client *Client) {
-	fmt.Printf("Client %s disconnected\n", client.ID)
-
-	// Remove from clients map
-	clientsMu.Lock()
-	delete(clients, client.ID)
-	clientsMu.Unlock()
-
-	if client.RoomID != "" {
-		r := roomManager.GetRoom(client.RoomID)
-		if r != nil {
-			r.RemovePlayer(client.ID)
-
-			// Notify remaining players
-			broadcastToRoom(client.RoomID, messages.ServerMessage{
-				Type:    "playerLeft",
-				Message: client.ID,
-				Players: r.GetPlayers(),
-			}, "")
+// newRoomStore saves rooms as files in the rooms directory, or in the rooms
+// Redis (host:port or redis:// URL). With neither set, rooms are lost on
+// restart.
+func newRoomStore(cfg config.Config) persist.Store {
+	if dir := cfg.RoomsDir; dir != "" {
+		store, err := persist.NewFileStore(dir)
+		if err != nil {
+			fatal("Cannot open room store", "err", err)
 		}
+		return store
 	}
+	if addr := cfg.RoomsRedis; addr != "" {
+		store, err := persist.NewRedisStore(addr, persist.DefaultRedisPrefix)
+		if err != nil {
+			fatal("Cannot connect to room store", "err", err)
+		}
+		return store
+	}
+	return nil
 }
 
-// SendJSON sends a JSON message to the client
-func (c *Client) SendJSON(msg messages.ServerMessage) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	c.Conn.WriteJSON(msg)
-}
-
-// broadcastToRoom sends a message to all clients in a room
-func broadcastToRoom(roomID string, msg messages.ServerMessage, excludeID string) {
-	clientsMu.RLock()
-	defer clientsMu.RUnlock()
-
-	for _, c := range clients {
-		if c.RoomID == roomID && c.ID != excludeID {
-			c.SendJSON(msg)
+// newSuspendedStore keeps suspended matches next to the saved rooms, in
+// the rooms directory's suspended folder or under their own prefix in the
+// rooms Redis. With neither set they only last until the server restarts.
+func newSuspendedStore(cfg config.Config) persist.Store {
+	if dir := cfg.RoomsDir; dir != "" {
+		store, err := persist.NewFileStore(filepath.Join(dir, "suspended"))
+		if err != nil {
+			fatal("Cannot open suspended match store", "err", err)
 		}
+		return store
 	}
+	if addr := cfg.RoomsRedis; addr != "" {
+		store, err := persist.NewRedisStore(addr, persist.DefaultRedisPrefix+"suspended:")
+		if err != nil {
+			fatal("Cannot connect to suspended match store", "err", err)
+		}
+		return store
+	}
+	return persist.NewMemoryStore()
 }
 
-// convertMazeToMessage converts game.Maze to messages.MazeData
-func convertMazeToMessage(m *game.Maze) *messages.MazeData {
-	cells := make([][]messages.Cell, m.Height)
-	for y := 0; y < m.Height; y++ {
-		cells[y] = make([]messages.Cell, m.Width)
-		for x := 0; x < m.Width; x++ {
-			cells[y][x] = messages.Cell{
-				X:      m.Cells[y][x].X,
-				Y:      m.Cells[y][x].Y,
-				Top:    m.Cells[y][x].Top,
-				Right:  m.Cells[y][x].Right,
-				Bottom: m.Cells[y][x].Bottom,
-				Left:   m.Cells[y][x].Left,
-			}
-		}
+// joinCluster shares rooms with the other instances using the cluster
+// Redis, as the configured node (a random one if unset). Without it the
+// server hosts its own rooms only.
+func joinCluster(cfg config.Config, srv *server.Server) {
+	addr := cfg.ClusterRedis
+	if addr == "" {
+		return
+	}
+	node := cfg.NodeID
+	if node == "" {
+		node = uuid.New().String()
 	}
 
-	return &messages.MazeData{
-		Width:  m.Width,
-		Height: m.Height,
-		Cells:  cells,
+	b, err := bus.NewRedis(addr)
+	if err != nil {
+		fatal("Cannot connect to cluster bus", "err", err)
+	}
+	if err := srv.JoinCluster(node, b, b); err != nil {
+		fatal("Cannot join cluster", "err", err)
 	}
+	slog.Info("Joined cluster", "node", node)
 }
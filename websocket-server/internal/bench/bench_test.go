@@ -0,0 +1,133 @@
+package bench
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+
+	"labyrinth-duel/websocket/internal/game"
+	"labyrinth-duel/websocket/internal/messages"
+	"labyrinth-duel/websocket/internal/room"
+)
+
+// mazeSizes are the square maze sizes generation is measured at
+var mazeSizes = []int{10, 25, 50, 100}
+
+// fanOuts are the client counts broadcasts are measured at
+var fanOuts = []int{2, 16, 128}
+
+func BenchmarkGenerate(b *testing.B) {
+	for _, alg := range game.Algorithms() {
+		for _, size := range mazeSizes {
+			b.Run(fmt.Sprintf("%s/%dx%d", alg, size, size), generate(alg, size))
+		}
+	}
+}
+
+func BenchmarkBroadcast(b *testing.B) {
+	for _, n := range fanOuts {
+		b.Run(fmt.Sprintf("%dclients", n), broadcast(n))
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	b.Run("json/gameState", encodeJSON(gameState))
+	b.Run("msgpack/gameState", encodeMsgPack(gameState))
+	b.Run("json/playerMoved", encodeJSON(playerMoved))
+	b.Run("json/mazeData", encodeJSON(mazeData))
+	b.Run("msgpack/mazeData", encodeMsgPack(mazeData))
+	b.Run("json/mazeDataCompact", encodeJSON(mazeDataCompact))
+}
+
+func generate(algorithm string, size int) func(b *testing.B) {
+	return func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			game.Generate(size, size, game.Options{Seed: int64(i + 1), Algorithm: algorithm})
+		}
+	}
+}
+
+// encodingSender encodes every message like a real connection would, then
+// throws the bytes away
+type encodingSender struct{}
+
+func (encodingSender) SendJSON(msg messages.ServerMessage) {
+	json.NewEncoder(io.Discard).Encode(msg)
+}
+
+func broadcast(clients int) func(b *testing.B) {
+	return func(b *testing.B) {
+		rooms := room.NewManager()
+		r, _ := rooms.GetOrCreateRoom("bench", room.Options{Maze: game.Options{Seed: 1}})
+		defer rooms.RemoveRoom("bench")
+		for i := 0; i < clients; i++ {
+			r.AddPlayer(fmt.Sprintf("p%d", i), encodingSender{})
+		}
+		msg := playerMoved()
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			r.Broadcast(msg, "")
+		}
+	}
+}
+
+// gameState is the full player list for a four-player room, as carried by
+// snapshots
+func gameState() messages.ServerMessage {
+	players := make([]messages.Player, 4)
+	for i := range players {
+		players[i] = messages.Player{ID: fmt.Sprintf("player-%d", i), X: i, Y: i, Score: 100 * i, Multiplier: 1}
+	}
+	return messages.ServerMessage{Type: "gameState", Players: players}
+}
+
+// playerMoved is the per-move delta broadcast
+func playerMoved() messages.ServerMessage {
+	return messages.ServerMessage{Type: "playerMoved", Message: "player-1", Position: &messages.Position{X: 3, Y: 4}}
+}
+
+// mazeData is the join payload for a 10x10 maze
+func mazeData() messages.ServerMessage {
+	rooms := room.NewManager()
+	r, _ := rooms.GetOrCreateRoom("bench", room.Options{Maze: game.Options{Seed: 1}})
+	defer rooms.RemoveRoom("bench")
+	data, _ := r.MazeDataFor("")
+	return messages.ServerMessage{Type: "mazeData", Maze: data}
+}
+
+// mazeDataCompact is the join payload for clients with the compactMaze
+// capability
+func mazeDataCompact() messages.ServerMessage {
+	msg := mazeData()
+	msg.Maze = msg.Maze.Compacted()
+	return msg
+}
+
+func encodeJSON(build func() messages.ServerMessage) func(b *testing.B) {
+	return func(b *testing.B) {
+		msg := build()
+		var buf bytes.Buffer
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			buf.Reset()
+			json.NewEncoder(&buf).Encode(msg)
+		}
+		b.SetBytes(int64(buf.Len()))
+	}
+}
+
+// encodeMsgPack measures the binary wire format
+func encodeMsgPack(build func() messages.ServerMessage) func(b *testing.B) {
+	return func(b *testing.B) {
+		msg := build()
+		var data []byte
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			data, _ = messages.MarshalMsgPack(msg)
+		}
+		b.SetBytes(int64(len(data)))
+	}
+}
@@ -0,0 +1,4 @@
+// Package bench holds benchmarks for the server's hot paths: maze
+// generation, room broadcast fan-out and message encoding. Run them with
+// go test -bench . ./internal/bench, or through cmd/bench.
+package bench
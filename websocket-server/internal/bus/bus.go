@@ -0,0 +1,144 @@
+// Package bus connects server instances so they can share rooms. Each room
+// is owned by one instance, found through a Registry; the others reach it
+// by publishing on the owner's topic of a Bus.
+package bus
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+)
+
+// Bus carries messages between server instances
+type Bus interface {
+	// Publish sends data to every subscriber of topic
+	Publish(topic string, data []byte) error
+	// Subscribe calls handler with everything published on topic, one
+	// message at a time and in publish order
+	Subscribe(topic string, handler func(data []byte)) error
+	// Close stops delivering messages
+	Close() error
+}
+
+// Registry records which instance owns each room (or any other key)
+type Registry interface {
+	// Claim makes node the owner of key unless another node already owns
+	// it, and returns the owner either way. Claiming a key node already
+	// owns keeps the claim alive.
+	Claim(key, node string) (owner string, err error)
+	// Lookup returns the owner of key, if any
+	Lookup(key string) (owner string, ok bool, err error)
+	// Release gives up node's claim on key; other owners are left alone
+	Release(key, node string) error
+}
+
+// ErrClosed is returned when publishing or subscribing on a closed bus
+var ErrClosed = errors.New("bus is closed")
+
+// ErrFull is returned when a message was dropped for a subscriber whose
+// queue is full
+var ErrFull = errors.New("bus subscriber fell behind, message dropped")
+
+// QueueSize is how many messages a subscriber may fall behind before
+// messages to it are dropped
+const QueueSize = 1024
+
+// Local is an in-process Bus and Registry, for a single instance or for
+// several servers sharing one process
+type Local struct {
+	subs   map[string][]chan []byte
+	owners map[string]string
+	closed bool
+	mu     sync.RWMutex
+}
+
+// NewLocal creates an empty in-process bus
+func NewLocal() *Local {
+	return &Local{
+		subs:   make(map[string][]chan []byte),
+		owners: make(map[string]string),
+	}
+}
+
+// Publish implements Bus. It never waits on a subscriber: one that has
+// fallen QueueSize messages behind misses this one, and ErrFull is
+// returned once the others have it.
+func (l *Local) Publish(topic string, data []byte) error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.closed {
+		return ErrClosed
+	}
+	var err error
+	for _, queue := range l.subs[topic] {
+		select {
+		case queue <- append([]byte(nil), data...):
+		default:
+			slog.Warn("Dropping bus message for a subscriber that fell behind", "topic", topic)
+			err = ErrFull
+		}
+	}
+	return err
+}
+
+// Subscribe implements Bus
+func (l *Local) Subscribe(topic string, handler func(data []byte)) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return ErrClosed
+	}
+	queue := make(chan []byte, QueueSize)
+	l.subs[topic] = append(l.subs[topic], queue)
+	go func() {
+		for data := range queue {
+			handler(data)
+		}
+	}()
+	return nil
+}
+
+// Close implements Bus
+func (l *Local) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return nil
+	}
+	l.closed = true
+	for _, queues := range l.subs {
+		for _, queue := range queues {
+			close(queue)
+		}
+	}
+	return nil
+}
+
+// Claim implements Registry
+func (l *Local) Claim(key, node string) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if owner, ok := l.owners[key]; ok {
+		return owner, nil
+	}
+	l.owners[key] = node
+	return node, nil
+}
+
+// Lookup implements Registry
+func (l *Local) Lookup(key string) (string, bool, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	owner, ok := l.owners[key]
+	return owner, ok, nil
+}
+
+// Release implements Registry
+func (l *Local) Release(key, node string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.owners[key] == node {
+		delete(l.owners, key)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package bus
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestLocalSlowSubscriber has a subscriber stop handling messages: the
+// publisher is never held up, and is told the overflow was dropped
+func TestLocalSlowSubscriber(t *testing.T) {
+	l := NewLocal()
+	defer l.Close()
+
+	stuck := make(chan struct{})
+	defer close(stuck)
+	if err := l.Subscribe("node", func([]byte) { <-stuck }); err != nil {
+		t.Fatal(err)
+	}
+
+	published := make(chan error)
+	go func() {
+		var last error
+		for i := 0; i < QueueSize+2; i++ {
+			if err := l.Publish("node", []byte{byte(i)}); err != nil {
+				last = err
+			}
+		}
+		published <- last
+	}()
+	select {
+	case err := <-published:
+		if !errors.Is(err, ErrFull) {
+			t.Errorf("publishing past a full queue returned %v, want ErrFull", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("publishing blocked on a stuck subscriber")
+	}
+}
@@ -0,0 +1,154 @@
+package bus
+
+import (
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"labyrinth-duel/websocket/internal/redis"
+)
+
+const (
+	// OwnerTTL is how long a room claim in Redis lasts unless renewed by
+	// claiming it again, so rooms of a crashed instance are freed
+	OwnerTTL = 30 * time.Second
+	// ResubscribeDelay is how long a dropped subscription waits to retry
+	ResubscribeDelay = time.Second
+	// DefaultRedisPrefix namespaces the channels and keys Redis uses
+	DefaultRedisPrefix = "maze:"
+)
+
+// releaseScript deletes a claim only if the given node still holds it
+const releaseScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) end return 0`
+
+// Redis is a Bus over Redis pub/sub and a Registry over expiring keys, so
+// instances on different machines can share rooms
+type Redis struct {
+	client *redis.Client
+	prefix string
+
+	subs   []*redis.Subscription
+	closed bool
+	mu     sync.Mutex
+}
+
+// NewRedis connects to the Redis at addr (see redis.New)
+func NewRedis(addr string) (*Redis, error) {
+	client, err := redis.New(addr)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := client.Do("PING"); err != nil {
+		return nil, err
+	}
+	return &Redis{client: client, prefix: DefaultRedisPrefix}, nil
+}
+
+// Publish implements Bus
+func (r *Redis) Publish(topic string, data []byte) error {
+	_, err := r.client.Do("PUBLISH", r.prefix+topic, string(data))
+	return err
+}
+
+// Subscribe implements Bus. A dropped connection is resubscribed after
+// ResubscribeDelay; anything published in between is lost.
+func (r *Redis) Subscribe(topic string, handler func(data []byte)) error {
+	sub, err := r.subscribe(topic)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for {
+			_, payload, err := sub.Receive()
+			if err == nil {
+				handler([]byte(payload))
+				continue
+			}
+			for {
+				if r.isClosed() {
+					return
+				}
+				slog.Warn("Bus subscription lost", "topic", topic, "err", err)
+				time.Sleep(ResubscribeDelay)
+				if sub, err = r.subscribe(topic); err == nil {
+					break
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func (r *Redis) subscribe(topic string) (*redis.Subscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil, ErrClosed
+	}
+	sub, err := r.client.Subscribe(r.prefix + topic)
+	if err != nil {
+		return nil, err
+	}
+	r.subs = append(r.subs, sub)
+	return sub, nil
+}
+
+func (r *Redis) isClosed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.closed
+}
+
+// Close implements Bus
+func (r *Redis) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	for _, sub := range r.subs {
+		sub.Close()
+	}
+	r.subs = nil
+	return r.client.Close()
+}
+
+// Claim implements Registry
+func (r *Redis) Claim(key, node string) (string, error) {
+	ttl := strconv.Itoa(int(OwnerTTL.Seconds()))
+	reply, err := r.client.Do("SET", r.ownerKey(key), node, "NX", "EX", ttl)
+	if err != nil {
+		return "", err
+	}
+	if reply != nil {
+		return node, nil
+	}
+
+	owner, ok, err := redis.String(r.client.Do("GET", r.ownerKey(key)))
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		// The claim expired in between: try again
+		return r.Claim(key, node)
+	}
+	if owner == node {
+		_, err = r.client.Do("EXPIRE", r.ownerKey(key), ttl)
+	}
+	return owner, err
+}
+
+// Lookup implements Registry
+func (r *Redis) Lookup(key string) (string, bool, error) {
+	return redis.String(r.client.Do("GET", r.ownerKey(key)))
+}
+
+// Release implements Registry
+func (r *Redis) Release(key, node string) error {
+	_, err := r.client.Do("EVAL", releaseScript, "1", r.ownerKey(key), node)
+	return err
+}
+
+func (r *Redis) ownerKey(key string) string {
+	return r.prefix + "owner:" + key
+}
@@ -0,0 +1,140 @@
+// Package certs serves TLS from a certificate and key on disk that can be
+// replaced while the server runs, as when an ACME client such as certbot
+// renews a Let's Encrypt certificate. Reloading only changes what new
+// handshakes get: connections already open keep going.
+package certs
+
+import (
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCheckInterval is how often Watch looks for renewed files
+const DefaultCheckInterval = time.Minute
+
+// Reloader holds the current certificate loaded from a pair of files
+type Reloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time // Newer of the two files' when they were loaded
+}
+
+// New loads the certificate and key, PEM encoded, from their files
+func New(certFile, keyFile string) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload loads the files again. If they don't make a valid pair, the
+// certificate in use is kept and the error returned.
+func (r *Reloader) Reload() error {
+	modTime, err := r.modified()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert = &cert
+	r.modTime = modTime
+	return nil
+}
+
+// Watch reloads the files whenever they change, checking every interval
+// (DefaultCheckInterval if not positive) until done is closed
+func (r *Reloader) Watch(interval time.Duration, done <-chan struct{}) {
+	if interval <= 0 {
+		interval = DefaultCheckInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		modTime, err := r.modified()
+		r.mu.RLock()
+		changed := err == nil && modTime.After(r.modTime)
+		r.mu.RUnlock()
+		if !changed {
+			continue
+		}
+		if err := r.Reload(); err != nil {
+			slog.Error("Cannot reload certificate", "cert", r.certFile, "err", err)
+			continue
+		}
+		slog.Info("Reloaded certificate", "cert", r.certFile)
+	}
+}
+
+// modified returns when the newer of the two files last changed
+func (r *Reloader) modified() (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// GetCertificate hands out the current certificate, for tls.Config
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.cert == nil {
+		return nil, errors.New("no certificate loaded")
+	}
+	return r.cert, nil
+}
+
+// TLSConfig returns a server TLS config that always uses the current
+// certificate
+func (r *Reloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: r.GetCertificate,
+	}
+}
+
+// Redirect sends plain HTTP requests to the same host and path over HTTPS
+// on httpsPort, leaving the port out when it is 443
+func Redirect(httpsPort int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		host := req.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		} else {
+			host = strings.Trim(host, "[]") // A bare IPv6 literal
+		}
+		host = strings.TrimSuffix(net.JoinHostPort(host, strconv.Itoa(httpsPort)), ":443")
+		target := "https://" + host + req.URL.RequestURI()
+		http.Redirect(w, req, target, http.StatusPermanentRedirect)
+	})
+}
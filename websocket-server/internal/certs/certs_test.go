@@ -0,0 +1,127 @@
+package certs
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestReload renews the certificate under a live listener: new connections
+// get the new one, old ones keep working, and a broken renewal is ignored
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := writeCert(certFile, keyFile, 1); err != nil {
+		t.Fatal(err)
+	}
+	reloader, err := New(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", reloader.TLSConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	web := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})}
+	go web.Serve(ln)
+	defer web.Close()
+
+	dial := func() (*tls.Conn, int64, error) {
+		conn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+		if err != nil {
+			return nil, 0, err
+		}
+		return conn, conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64(), nil
+	}
+	old, serial, err := dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer old.Close()
+	if serial != 1 {
+		t.Fatalf("served certificate %d, want 1", serial)
+	}
+
+	if err := writeCert(certFile, keyFile, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloader.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	renewed, serial, err := dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	renewed.Close()
+	if serial != 2 {
+		t.Fatalf("served certificate %d after renewal, want 2", serial)
+	}
+
+	fmt.Fprint(old, "GET / HTTP/1.1\r\nHost: maze\r\n\r\n")
+	resp, err := http.ReadResponse(bufio.NewReader(old), nil)
+	if err != nil {
+		t.Fatalf("connection from before the renewal: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("connection from before the renewal got %d", resp.StatusCode)
+	}
+
+	// A broken renewal leaves the good certificate in place
+	if err := os.WriteFile(keyFile, []byte("not a key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloader.Reload(); err == nil {
+		t.Fatal("bad key pair loaded")
+	}
+	if _, serial, err = dial(); err != nil || serial != 2 {
+		t.Fatalf("after a bad renewal served %d (%v), want 2", serial, err)
+	}
+}
+
+func TestRedirect(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Redirect(8443).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://maze.example:8080/ws?room=a", nil))
+	if loc := rec.Header().Get("Location"); rec.Code != http.StatusPermanentRedirect || loc != "https://maze.example:8443/ws?room=a" {
+		t.Errorf("redirected with %d to %q", rec.Code, loc)
+	}
+}
+
+// writeCert writes a self-signed certificate with the given serial number
+func writeCert(certFile, keyFile string, serial int64) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		return err
+	}
+	return os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+}
@@ -0,0 +1,184 @@
+// Package clock tells rooms and the server the time and runs their timers.
+// Production uses the system clock; tests use a Manual clock they advance
+// by hand, so timed behavior runs without sleeping.
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is a source of time and timers
+type Clock interface {
+	Now() time.Time
+	// After sends the time on the returned channel once d has passed
+	After(d time.Duration) <-chan time.Time
+	// AfterFunc calls f once d has passed. The returned stop cancels the
+	// call, reporting false if it had already been made.
+	AfterFunc(d time.Duration, f func()) (stop func() bool)
+	// Every calls f with the time every d, one call at a time, until the
+	// returned stop is called
+	Every(d time.Duration, f func(now time.Time)) (stop func())
+}
+
+// Real is the system clock
+type Real struct{}
+
+// Now implements Clock
+func (Real) Now() time.Time {
+	return time.Now()
+}
+
+// After implements Clock
+func (Real) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// AfterFunc implements Clock
+func (Real) AfterFunc(d time.Duration, f func()) func() bool {
+	return time.AfterFunc(d, f).Stop
+}
+
+// Every implements Clock
+func (Real) Every(d time.Duration, f func(now time.Time)) func() {
+	ticker := time.NewTicker(d)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				f(now)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			ticker.Stop()
+			close(done)
+		})
+	}
+}
+
+// Manual is a clock that only moves when advanced. Timers that come due
+// fire inside Advance, in time order, on the caller's goroutine; After
+// channels are sent to instead.
+type Manual struct {
+	now    time.Time
+	timers []*manualTimer
+	seq    int // Orders timers due at the same moment by when they were set
+	mu     sync.Mutex
+}
+
+// manualTimer is a pending AfterFunc, After or Every
+type manualTimer struct {
+	at     time.Time
+	period time.Duration // Every's interval, 0 for one-off timers
+	seq    int
+	fire   func(now time.Time)
+}
+
+// NewManual returns a manual clock reading start
+func NewManual(start time.Time) *Manual {
+	return &Manual{now: start}
+}
+
+// Now implements Clock
+func (m *Manual) Now() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.now
+}
+
+// After implements Clock
+func (m *Manual) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	m.add(d, 0, func(now time.Time) { ch <- now })
+	return ch
+}
+
+// AfterFunc implements Clock
+func (m *Manual) AfterFunc(d time.Duration, f func()) func() bool {
+	t := m.add(d, 0, func(time.Time) { f() })
+	return func() bool { return m.remove(t) }
+}
+
+// Every implements Clock
+func (m *Manual) Every(d time.Duration, f func(now time.Time)) func() {
+	if d <= 0 {
+		panic("clock: non-positive interval for Every")
+	}
+	t := m.add(d, d, f)
+	return func() { m.remove(t) }
+}
+
+// Advance moves the clock forward by d, firing every timer that comes due
+// on the way at the time it is due
+func (m *Manual) Advance(d time.Duration) {
+	m.mu.Lock()
+	target := m.now.Add(d)
+	for {
+		if len(m.timers) == 0 || m.timers[0].at.After(target) {
+			break
+		}
+		t := m.timers[0]
+		at := t.at
+		m.now = at
+		if t.period > 0 {
+			t.at = t.at.Add(t.period)
+			t.seq = m.nextSeqLocked()
+			m.sortLocked()
+		} else {
+			m.timers = m.timers[1:]
+		}
+
+		// Timers may set or stop others, so fire them unlocked
+		m.mu.Unlock()
+		t.fire(at)
+		m.mu.Lock()
+	}
+	m.now = target
+	m.mu.Unlock()
+}
+
+// add schedules fire d from now, and every period after that if period is
+// set
+func (m *Manual) add(d, period time.Duration, fire func(now time.Time)) *manualTimer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t := &manualTimer{at: m.now.Add(max(d, 0)), period: period, seq: m.nextSeqLocked(), fire: fire}
+	m.timers = append(m.timers, t)
+	m.sortLocked()
+	return t
+}
+
+// remove cancels a timer, reporting whether it was still pending
+func (m *Manual) remove(t *manualTimer) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, pending := range m.timers {
+		if pending == t {
+			m.timers = append(m.timers[:i], m.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Manual) nextSeqLocked() int {
+	m.seq++
+	return m.seq
+}
+
+func (m *Manual) sortLocked() {
+	sort.Slice(m.timers, func(i, j int) bool {
+		if !m.timers[i].at.Equal(m.timers[j].at) {
+			return m.timers[i].at.Before(m.timers[j].at)
+		}
+		return m.timers[i].seq < m.timers[j].seq
+	})
+}
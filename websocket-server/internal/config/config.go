@@ -0,0 +1,318 @@
+// Package config gathers every server setting in one place. Settings come
+// from an optional config file, then environment variables, then
+// command-line flags, each overriding the one before.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+// Config is every setting the server runs with. Zero values mean the
+// owning package's default unless noted.
+type Config struct {
+	File string // Config file read before the environment and flags
+
+	Port      int    // Port to listen on
+	LogLevel  string // debug, info, warn or error
+	LogFormat string // text or json
+
+	TLSCert          string        // Certificate file (PEM); with TLSKey, serve HTTPS and wss://
+	TLSKey           string        // Private key file (PEM)
+	CertCheck        time.Duration // How often to look for a renewed certificate
+	RedirectPort     int           // Plain HTTP port redirecting to HTTPS (0 = none)
+	AdminToken       string        // Guards the admin endpoints; they are disabled when empty
+	JWTSecret        string        // Verifies player tokens (HS256); players are anonymous without it
+	JWTIssuer        string        // Required token issuer, if set
+	RequireAuth      bool          // Turn away players without a token
+	AllowedOrigins   string        // Comma-separated origins allowed besides the server's own
+	DevAnyOrigin     bool          // Allow every origin, for development only
+	Debug            bool          // Check room invariants after every change
+	StrictValidation bool          // Reject inbound messages that don't match their schema
+	MinProtocol      int           // Oldest protocol version clients may speak (0 = the oldest the server translates for)
+	BotFill          bool          // Match players who time out in the queue against a bot
+
+	RatingsFile    string // Ratings file (in memory if empty)
+	HistoryFile    string // Match history file (recent matches in memory if empty)
+	ReplayDir      string // Replay directory (recent replays in memory if empty)
+	LevelDir       string // Uploaded level directory (recent levels in memory if empty)
+	DailyDir       string // Daily challenge leaderboard directory (in memory if empty)
+	LeaderboardDir string // Leaderboard directory (in memory if empty)
+	RoomsDir       string // Saved rooms directory
+	RoomsRedis     string // Saved rooms Redis, if RoomsDir is empty
+	ClusterRedis   string // Redis shared with the other instances (none if empty)
+	NodeID         string // This instance's name in the cluster (random if empty)
+
+	MazeWidth     int           // New rooms' maze width
+	MazeHeight    int           // New rooms' maze height
+	MaxPlayers    int           // Cap on any room's player limit (0 = none)
+	TickInterval  time.Duration // How often rooms update timed state
+	MatchDuration time.Duration // How long a match runs before time is up
+	RoomTTL       time.Duration // How long an empty room stays open (0 = closes at once)
+	AttractMode   bool          // Bots play demo matches in empty public rooms while they stay open
+	IdleTimeout   time.Duration // How long a player may do nothing before they are flagged idle (0 = never)
+	IdleGrace     time.Duration // How much longer before an idle player is removed from their room
+	DrainGrace    time.Duration // How long to keep serving after a shutdown signal
+}
+
+// Default returns the settings used when nothing else is given
+func Default() Config {
+	return Config{Port: 8080}
+}
+
+// setting ties a Config field to its flag, which is also its key in config
+// files, and its environment variable
+type setting struct {
+	flag  string
+	env   string
+	usage string
+}
+
+// bind registers a flag for every setting on fs, starting from c's current
+// values, and returns the settings by flag name
+func bind(fs *flag.FlagSet, c *Config) map[string]setting {
+	settings := make(map[string]setting)
+	str := func(p *string, s setting) { fs.StringVar(p, s.flag, *p, s.usage); settings[s.flag] = s }
+	num := func(p *int, s setting) { fs.IntVar(p, s.flag, *p, s.usage); settings[s.flag] = s }
+	on := func(p *bool, s setting) { fs.BoolVar(p, s.flag, *p, s.usage); settings[s.flag] = s }
+	dur := func(p *time.Duration, s setting) { fs.DurationVar(p, s.flag, *p, s.usage); settings[s.flag] = s }
+
+	str(&c.File, setting{"config", "MAZE_CONFIG", "config file (JSON, or flat YAML)"})
+	num(&c.Port, setting{"port", "PORT", "port to listen on"})
+	str(&c.LogLevel, setting{"log-level", "LOG_LEVEL", "debug, info, warn or error"})
+	str(&c.LogFormat, setting{"log-format", "LOG_FORMAT", "text or json"})
+	str(&c.TLSCert, setting{"tls-cert", "TLS_CERT", "certificate file (PEM); with tls-key, serve HTTPS and wss://"})
+	str(&c.TLSKey, setting{"tls-key", "TLS_KEY", "private key file (PEM)"})
+	dur(&c.CertCheck, setting{"cert-check", "CERT_CHECK", "how often to look for a renewed certificate; SIGHUP reloads at once (0 = default)"})
+	num(&c.RedirectPort, setting{"redirect-port", "REDIRECT_PORT", "plain HTTP port redirecting to HTTPS (0 = none)"})
+	str(&c.AdminToken, setting{"admin-token", "ADMIN_TOKEN", "token guarding the admin endpoints (disabled if empty)"})
+	str(&c.JWTSecret, setting{"jwt-secret", "JWT_SECRET", "secret verifying player tokens (HS256); players are anonymous without it"})
+	str(&c.JWTIssuer, setting{"jwt-issuer", "JWT_ISSUER", "required player token issuer"})
+	on(&c.RequireAuth, setting{"require-auth", "REQUIRE_AUTH", "turn away players without a token"})
+	str(&c.AllowedOrigins, setting{"allowed-origins", "ALLOWED_ORIGINS", "comma-separated origins allowed besides the server's own, e.g. https://*.example.com"})
+	on(&c.DevAnyOrigin, setting{"dev-any-origin", "DEV_ANY_ORIGIN", "allow every origin (development only)"})
+	on(&c.Debug, setting{"debug", "MAZE_DEBUG", "check room invariants after every change"})
+	on(&c.StrictValidation, setting{"strict-validation", "STRICT_VALIDATION", "reject messages that don't match their schema"})
+	num(&c.MinProtocol, setting{"min-protocol", "MIN_PROTOCOL", "oldest protocol version clients may speak (0 = the oldest supported)"})
+	on(&c.BotFill, setting{"bot-fill", "BOT_FILL", "match players who time out in the queue against a bot"})
+	str(&c.RatingsFile, setting{"ratings-file", "RATINGS_FILE", "ratings file (in memory if empty)"})
+	str(&c.HistoryFile, setting{"history-file", "HISTORY_FILE", "match history file (recent matches in memory if empty)"})
+	str(&c.ReplayDir, setting{"replay-dir", "REPLAY_DIR", "replay directory (recent replays in memory if empty)"})
+	str(&c.LevelDir, setting{"level-dir", "LEVEL_DIR", "uploaded level directory (recent levels in memory if empty)"})
+	str(&c.DailyDir, setting{"daily-dir", "DAILY_DIR", "daily challenge leaderboard directory (in memory if empty)"})
+	str(&c.LeaderboardDir, setting{"leaderboard-dir", "LEADERBOARD_DIR", "leaderboard directory (in memory if empty)"})
+	str(&c.RoomsDir, setting{"rooms-dir", "ROOMS_DIR", "saved rooms directory"})
+	str(&c.RoomsRedis, setting{"rooms-redis", "ROOMS_REDIS", "saved rooms Redis (host:port or redis:// URL)"})
+	str(&c.ClusterRedis, setting{"cluster-redis", "CLUSTER_REDIS", "Redis shared with the other instances"})
+	str(&c.NodeID, setting{"node-id", "NODE_ID", "this instance's name in the cluster (random if empty)"})
+	num(&c.MazeWidth, setting{"maze-width", "MAZE_WIDTH", "new rooms' maze width (0 = default)"})
+	num(&c.MazeHeight, setting{"maze-height", "MAZE_HEIGHT", "new rooms' maze height (0 = default)"})
+	num(&c.MaxPlayers, setting{"max-players", "MAX_PLAYERS", "cap on any room's player limit (0 = none)"})
+	dur(&c.TickInterval, setting{"tick-interval", "TICK_INTERVAL", "how often rooms update timed state (0 = default)"})
+	dur(&c.MatchDuration, setting{"match-duration", "MATCH_DURATION", "how long a match runs (0 = default)"})
+	dur(&c.RoomTTL, setting{"room-ttl", "ROOM_TTL", "how long an empty room stays open (0 = closes at once)"})
+	on(&c.AttractMode, setting{"attract-mode", "ATTRACT_MODE", "bots play demo matches in empty public rooms while room-ttl keeps them open"})
+	dur(&c.IdleTimeout, setting{"idle-timeout", "IDLE_TIMEOUT", "how long a player may do nothing before they are flagged idle (0 = never)"})
+	dur(&c.IdleGrace, setting{"idle-grace", "IDLE_GRACE", "how much longer before an idle player is removed from their room (0 = default)"})
+	dur(&c.DrainGrace, setting{"drain-grace", "DRAIN_GRACE", "how long to keep serving after a shutdown signal (0 = default)"})
+	return settings
+}
+
+// Load reads the settings from the config file named by -config or
+// MAZE_CONFIG, then the environment (looked up with getenv), then args,
+// the command line without the program name
+func Load(args []string, getenv func(string) string) (Config, error) {
+	// A first pass finds the config file
+	c, err := load(args, getenv, "")
+	if err != nil || c.File == "" {
+		return c, err
+	}
+	return load(args, getenv, c.File)
+}
+
+// load layers file, if any, the environment and args over the defaults
+func load(args []string, getenv func(string) string, file string) (Config, error) {
+	c := Default()
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	settings := bind(fs, &c)
+
+	if file != "" {
+		values, err := readFile(file)
+		if err != nil {
+			return c, err
+		}
+		for _, key := range sortedKeys(values) {
+			if _, ok := settings[key]; !ok || key == "config" {
+				return c, fmt.Errorf("%s: unknown setting %q", file, key)
+			}
+			if err := fs.Set(key, values[key]); err != nil {
+				return c, fmt.Errorf("%s: %s: %w", file, key, err)
+			}
+		}
+	}
+
+	for _, name := range sortedKeys(settings) {
+		s := settings[name]
+		if v := getenv(s.env); v != "" {
+			if err := fs.Set(s.flag, v); err != nil {
+				return c, fmt.Errorf("%s: %w", s.env, err)
+			}
+		}
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return c, err
+	}
+	if fs.NArg() > 0 {
+		return c, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	return c, c.validate()
+}
+
+// validate rejects settings no package default could make sense of
+func (c Config) validate() error {
+	switch {
+	case c.Port <= 0 || c.Port > 65535:
+		return fmt.Errorf("port %d out of range", c.Port)
+	case (c.TLSCert == "") != (c.TLSKey == ""):
+		return errors.New("tls-cert and tls-key go together")
+	case c.RedirectPort != 0 && !c.TLS():
+		return errors.New("redirect-port needs tls-cert and tls-key")
+	case c.RedirectPort < 0 || c.RedirectPort > 65535 || c.RedirectPort != 0 && c.RedirectPort == c.Port:
+		return fmt.Errorf("redirect port %d out of range or taken", c.RedirectPort)
+	case c.RequireAuth && c.JWTSecret == "":
+		return errors.New("require-auth needs a jwt-secret")
+	case c.MazeWidth < 0 || c.MazeHeight < 0:
+		return errors.New("maze size can't be negative")
+	case c.MaxPlayers < 0:
+		return errors.New("max players can't be negative")
+	case c.MinProtocol < 0 || c.MinProtocol > messages.ProtocolVersion:
+		return fmt.Errorf("min protocol %d out of range, the server speaks up to %d", c.MinProtocol, messages.ProtocolVersion)
+	case c.TickInterval < 0 || c.MatchDuration < 0 || c.RoomTTL < 0 || c.IdleTimeout < 0 || c.IdleGrace < 0 || c.DrainGrace < 0 || c.CertCheck < 0:
+		return errors.New("durations can't be negative")
+	}
+	return nil
+}
+
+// Origins splits AllowedOrigins into its entries
+func (c Config) Origins() []string {
+	var origins []string
+	for _, o := range strings.Split(c.AllowedOrigins, ",") {
+		if o = strings.TrimSpace(o); o != "" {
+			origins = append(origins, o)
+		}
+	}
+	return origins
+}
+
+// TLS reports whether to serve HTTPS and wss://
+func (c Config) TLS() bool {
+	return c.TLSCert != ""
+}
+
+// RedirectAddr is the address of the HTTP to HTTPS redirect listener
+func (c Config) RedirectAddr() string {
+	return ":" + strconv.Itoa(c.RedirectPort)
+}
+
+// Addr is the address to listen on
+func (c Config) Addr() string {
+	return ":" + strconv.Itoa(c.Port)
+}
+
+// Usage writes every setting with its flag, environment variable and
+// default to w
+func Usage(w io.Writer) {
+	c := Default()
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	settings := bind(fs, &c)
+	fmt.Fprintln(w, "Settings, by flag (and file key) and environment variable:")
+	fs.VisitAll(func(f *flag.Flag) {
+		def := ""
+		if f.DefValue != "" && f.DefValue != "0" && f.DefValue != "0s" && f.DefValue != "false" {
+			def = " (default " + f.DefValue + ")"
+		}
+		fmt.Fprintf(w, "  -%s, %s\n\t%s%s\n", f.Name, settings[f.Name].env, f.Usage, def)
+	})
+}
+
+// readFile reads a config file into setting values by key. Files ending in
+// .yaml or .yml hold one "key: value" per line; anything else is a JSON
+// object.
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return parseYAML(path, string(data))
+	}
+
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	values := make(map[string]string, len(raw))
+	for key, v := range raw {
+		switch v := v.(type) {
+		case string:
+			values[key] = v
+		case float64:
+			values[key] = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			values[key] = strconv.FormatBool(v)
+		default:
+			return nil, fmt.Errorf("%s: %s must be a string, number or boolean", path, key)
+		}
+	}
+	return values, nil
+}
+
+// parseYAML reads the flat subset of YAML config files need: "key: value"
+// lines, with # comments and optionally quoted values
+func parseYAML(path, data string) (map[string]string, error) {
+	values := make(map[string]string)
+	for i, line := range strings.Split(data, "\n") {
+		if hash := strings.Index(line, "#"); hash == 0 || hash > 0 && line[hash-1] == ' ' {
+			line = line[:hash]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" || line == "---" {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected key: value", path, i+1)
+		}
+		value = strings.TrimSpace(value)
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		} else if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+			value = value[1 : len(value)-1]
+		}
+		values[strings.TrimSpace(key)] = value
+	}
+	return values, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,50 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"labyrinth-duel/websocket/internal/config"
+	"labyrinth-duel/websocket/internal/rating"
+	"labyrinth-duel/websocket/internal/room"
+	"labyrinth-duel/websocket/internal/server"
+)
+
+// TestLoad layers a file, the environment and flags, each overriding the
+// last, and checks rooms are made to the loaded size
+func TestLoad(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "maze.yaml")
+	yaml := `# Big rooms, few players
+port: 9000
+maze-width: 14
+max-players: 3
+admin-token: "from file"
+`
+	if err := os.WriteFile(file, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{
+		"MAZE_CONFIG": file,
+		"PORT":        "9001",
+		"MAZE_HEIGHT": "12",
+	}
+	cfg, err := config.Load([]string{"-port", "9002"}, func(k string) string { return env[k] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != 9002 || cfg.MazeWidth != 14 || cfg.MazeHeight != 12 || cfg.AdminToken != "from file" {
+		t.Fatalf("loaded %+v", cfg)
+	}
+
+	if _, err := config.Load([]string{"-tick-interval", "soon"}, func(string) string { return "" }); err == nil {
+		t.Error("bad duration accepted")
+	}
+
+	r, _ := server.New(cfg, rating.NewMemoryStore()).Rooms().GetOrCreateRoom("big", room.Options{})
+	defer r.Stop()
+	status := r.Status()
+	if status.Width != 14 || status.Height != 12 || status.MaxPlayers != 3 {
+		t.Errorf("room is %dx%d for %d players", status.Width, status.Height, status.MaxPlayers)
+	}
+}
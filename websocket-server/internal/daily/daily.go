@@ -0,0 +1,76 @@
+// Package daily runs the daily challenge: one maze per UTC day, the same
+// for everyone, raced solo against the clock for a place on that day's
+// leaderboard
+package daily
+
+import (
+	"hash/fnv"
+	"sort"
+	"time"
+
+	"labyrinth-duel/websocket/internal/game"
+)
+
+// DayFormat is how days are written, e.g. 2026-10-16
+const DayFormat = "2006-01-02"
+
+// LeaderboardSize is how many of the best times a leaderboard shows
+const LeaderboardSize = 50
+
+// Day returns the UTC day t falls on
+func Day(t time.Time) string {
+	return t.UTC().Format(DayFormat)
+}
+
+// ValidDay reports whether day is written as DayFormat
+func ValidDay(day string) bool {
+	_, err := time.Parse(DayFormat, day)
+	return err == nil
+}
+
+// Seed is the maze seed of a day: the same on every server, and a
+// different maze every day. It fits a JavaScript number like any seed.
+func Seed(day string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("daily:" + day))
+	return int64(h.Sum64()>>12) + 1
+}
+
+// MazeOptions generates a day's maze. The theme is pinned to the day's
+// season so that it doesn't depend on when the maze is built.
+func MazeOptions(day string) game.Options {
+	t, _ := time.Parse(DayFormat, day)
+	return game.Options{
+		Seed:      Seed(day),
+		Algorithm: game.DefaultAlgorithm,
+		Theme:     game.SeasonalTheme(t),
+	}
+}
+
+// Entry is a player's best completion time on a day
+type Entry struct {
+	PlayerID string
+	Name     string
+	Time     time.Duration
+	At       time.Time // When it was set
+}
+
+// Store keeps every day's leaderboard
+type Store interface {
+	// Record keeps a completion time if it is the player's best of the
+	// day, reporting whether it was
+	Record(day string, e Entry) (bool, error)
+	// Rankings returns everyone's best time on a day, fastest first
+	Rankings(day string) ([]Entry, error)
+}
+
+// sortEntries puts entries fastest first; ties go to whoever set theirs
+// first
+func sortEntries(entries []Entry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Time != entries[j].Time {
+			return entries[i].Time < entries[j].Time
+		}
+		return entries[i].At.Before(entries[j].At)
+	})
+}
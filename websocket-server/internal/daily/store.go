@@ -0,0 +1,113 @@
+package daily
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"labyrinth-duel/websocket/internal/datafile"
+)
+
+// MemoryStore keeps leaderboards in memory; they are lost on restart
+type MemoryStore struct {
+	days map[string]map[string]Entry // Best entry by player ID, by day
+	mu   sync.RWMutex
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{days: make(map[string]map[string]Entry)}
+}
+
+// Record implements Store
+func (s *MemoryStore) Record(day string, e Entry) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.recordLocked(day, e), nil
+}
+
+func (s *MemoryStore) recordLocked(day string, e Entry) bool {
+	entries, ok := s.days[day]
+	if !ok {
+		entries = make(map[string]Entry)
+		s.days[day] = entries
+	}
+	if best, ok := entries[e.PlayerID]; ok && best.Time <= e.Time {
+		return false
+	}
+	entries[e.PlayerID] = e
+	return true
+}
+
+// Rankings implements Store
+func (s *MemoryStore) Rankings(day string) ([]Entry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.rankingsLocked(day), nil
+}
+
+func (s *MemoryStore) rankingsLocked(day string) []Entry {
+	entries := make([]Entry, 0, len(s.days[day]))
+	for _, e := range s.days[day] {
+		entries = append(entries, e)
+	}
+	sortEntries(entries)
+	return entries
+}
+
+// FileStore keeps leaderboards in memory and writes a day's to its own
+// JSON file in a directory whenever it changes
+type FileStore struct {
+	dir string
+	mem *MemoryStore
+}
+
+// NewFileStore loads the leaderboards in dir, creating it if needed
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := datafile.MkdirAll(dir); err != nil {
+		return nil, err
+	}
+	s := &FileStore{dir: dir, mem: NewMemoryStore()}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		var entries []Entry
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, err
+		}
+		day := strings.TrimSuffix(filepath.Base(f), ".json")
+		for _, e := range entries {
+			s.mem.recordLocked(day, e)
+		}
+	}
+	return s, nil
+}
+
+// Record implements Store
+func (s *FileStore) Record(day string, e Entry) (bool, error) {
+	s.mem.mu.Lock()
+	defer s.mem.mu.Unlock()
+
+	if !s.mem.recordLocked(day, e) {
+		return false, nil
+	}
+	data, err := json.MarshalIndent(s.mem.rankingsLocked(day), "", "  ")
+	if err != nil {
+		return true, err
+	}
+	return true, datafile.Write(filepath.Join(s.dir, day+".json"), data)
+}
+
+// Rankings implements Store
+func (s *FileStore) Rankings(day string) ([]Entry, error) {
+	return s.mem.Rankings(day)
+}
@@ -0,0 +1,28 @@
+// Package datafile writes the files the stores keep on disk, all under one
+// permission policy: only the user the server runs as may read them, since
+// they hold player IDs, results and room state.
+package datafile
+
+import "os"
+
+// Modes of the files and directories the stores create
+const (
+	FileMode os.FileMode = 0o600
+	DirMode  os.FileMode = 0o700
+)
+
+// MkdirAll creates a store's directory, and any parents it is missing
+func MkdirAll(dir string) error {
+	return os.MkdirAll(dir, DirMode)
+}
+
+// Write replaces the file at path with data. It writes a temporary file
+// next to it and renames that into place, so a crash never leaves a
+// half-written file.
+func Write(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, FileMode); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
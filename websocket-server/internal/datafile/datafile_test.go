@@ -0,0 +1,38 @@
+package datafile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestWrite replaces a file, leaving it private and no temporary file
+// behind
+func TestWrite(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "store")
+	if err := MkdirAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "data.json")
+	for _, data := range []string{"first", "second"} {
+		if err := Write(path, []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got, _ := os.ReadFile(path); string(got) != "second" {
+		t.Errorf("file holds %q, want second", got)
+	}
+	for p, want := range map[string]os.FileMode{dir: DirMode, path: FileMode} {
+		info, err := os.Stat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mode := info.Mode().Perm(); mode != want {
+			t.Errorf("%s has mode %o, want %o", p, mode, want)
+		}
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package game
+
+import "sort"
+
+// DeadEnd is a corridor that leads nowhere: it starts at a cell with one
+// open side and runs back until it reaches a junction
+type DeadEnd struct {
+	Tip    Point   // The closed-off end
+	Cells  []Point // Corridor cells from the tip up to (not including) the junction
+	Length int
+}
+
+// openSides lists the neighbors reachable from p, stairs included
+func (m *Maze) openSides(p Point) []Point {
+	var open []Point
+	for _, n := range Moves(p) {
+		if m.CanMove(p.X, p.Y, p.Z, n.X, n.Y, n.Z) {
+			open = append(open, n)
+		}
+	}
+	return open
+}
+
+// DeadEnds finds every dead-end corridor on every floor, longest first
+func (m *Maze) DeadEnds() []DeadEnd {
+	var deadEnds []DeadEnd
+
+	for _, p := range m.Points() {
+		if !m.IsDeadEnd(p) {
+			continue
+		}
+
+		// Walk the corridor until it branches (or hits another dead end)
+		d := DeadEnd{Tip: p}
+		prev, cur := Point{X: -1, Y: -1}, p
+		for {
+			d.Cells = append(d.Cells, cur)
+
+			next, found := Point{}, false
+			for _, o := range m.openSides(cur) {
+				if o != prev {
+					next, found = o, true
+					break
+				}
+			}
+			// Stop at a junction (or the far end of an isolated corridor)
+			if !found || len(m.openSides(next)) != 2 {
+				break
+			}
+			prev, cur = cur, next
+		}
+		d.Length = len(d.Cells)
+		deadEnds = append(deadEnds, d)
+	}
+
+	sort.SliceStable(deadEnds, func(i, j int) bool {
+		return deadEnds[i].Length > deadEnds[j].Length
+	})
+	return deadEnds
+}
+
+// PruneDeadEnd opens the tip of a dead end into a neighboring cell, turning
+// the corridor into a loop. Returns false if the tip has no wall to open.
+func (m *Maze) PruneDeadEnd(d DeadEnd) bool {
+	for _, dir := range directions {
+		n := Point{X: d.Tip.X + dir.DX, Y: d.Tip.Y + dir.DY, Z: d.Tip.Z}
+		if m.RemoveWallBetween(d.Tip, n) {
+			return true
+		}
+	}
+	return false
+}
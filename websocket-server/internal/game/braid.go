@@ -0,0 +1,69 @@
+package game
+
+import "math/rand"
+
+// Braid removes walls from dead ends of a flat maze to introduce loops. loopFactor is the
+// fraction of dead ends (0 to 1) that get opened up; 0 leaves the maze
+// perfect, 1 removes every dead end. Only m.Cells is braided: floors above
+// the ground are braided through their floorView.
+func (m *Maze) Braid(loopFactor float64, rng *rand.Rand) {
+	if loopFactor <= 0 {
+		return
+	}
+	if loopFactor > 1 {
+		loopFactor = 1
+	}
+
+	var deadEnds []Point
+	for y := 0; y < m.Height; y++ {
+		for x := 0; x < m.Width; x++ {
+			if p := (Point{X: x, Y: y}); m.IsDeadEnd(p) {
+				deadEnds = append(deadEnds, p)
+			}
+		}
+	}
+	rng.Shuffle(len(deadEnds), func(i, j int) { deadEnds[i], deadEnds[j] = deadEnds[j], deadEnds[i] })
+
+	count := int(float64(len(deadEnds)) * loopFactor)
+	for _, p := range deadEnds[:count] {
+		// An earlier removal may already have opened this one
+		if !m.IsDeadEnd(p) {
+			continue
+		}
+
+		// Prefer knocking through into another dead end: it fixes two at once
+		var walled, walledDeadEnds []Point
+		for _, d := range directions {
+			n := Point{X: p.X + d.DX, Y: p.Y + d.DY}
+			if !m.InBounds(n.X, n.Y) || m.CanMove(p.X, p.Y, 0, n.X, n.Y, 0) {
+				continue
+			}
+			walled = append(walled, n)
+			if m.IsDeadEnd(n) {
+				walledDeadEnds = append(walledDeadEnds, n)
+			}
+		}
+		if len(walledDeadEnds) > 0 {
+			walled = walledDeadEnds
+		}
+		if len(walled) == 0 {
+			continue
+		}
+
+		n := walled[rng.Intn(len(walled))]
+		m.removeWall(p.X, p.Y, n.X, n.Y)
+	}
+}
+
+// IsDeadEnd reports whether the cell has exactly one way out, counting
+// stairs as one
+func (m *Maze) IsDeadEnd(p Point) bool {
+	c := m.CellAt(p)
+	open := 0
+	for _, side := range []bool{!c.Top, !c.Right, !c.Bottom, !c.Left, c.StairsUp, c.StairsDown} {
+		if side {
+			open++
+		}
+	}
+	return open == 1
+}
@@ -0,0 +1,87 @@
+package game
+
+import "math/rand"
+
+// Axes a tunnel can run along under a crossing cell
+const (
+	AxisHorizontal = "horizontal"
+	AxisVertical   = "vertical"
+)
+
+// Levels a player can occupy inside a crossing cell
+const (
+	LevelSurface = 0 // On the bridge (or any normal cell)
+	LevelUnder   = 1 // In the tunnel passing beneath
+)
+
+func axisOf(dx, dy int) string {
+	if dx != 0 {
+		return AxisHorizontal
+	}
+	return AxisVertical
+}
+
+// Step validates a move for a player at the given level and returns the
+// level they end up on. In a crossing cell a player can only continue along
+// the passage they are on: the surface path or the tunnel beneath it.
+// Stairs are never crossings, so climbing them keeps to the surface.
+func (m *Maze) Step(from Point, level int, to Point) (int, bool) {
+	if !m.CanMove(from.X, from.Y, from.Z, to.X, to.Y, to.Z) {
+		return 0, false
+	}
+	if to.Z != from.Z {
+		return LevelSurface, true
+	}
+
+	axis := axisOf(to.X-from.X, to.Y-from.Y)
+	if under := m.CellAt(from).Under; under != "" {
+		if (level == LevelUnder) != (axis == under) {
+			return 0, false
+		}
+	}
+
+	if m.CellAt(to).Under == axis {
+		return LevelUnder, true
+	}
+	return LevelSurface, true
+}
+
+// addCrossings turns roughly density of the eligible straight corridor
+// cells into crossings by digging a tunnel underneath them, connecting the
+// two cells on either side without touching the corridor above. Like
+// Braid, it only works on m.Cells.
+func (m *Maze) addCrossings(density float64, rng *rand.Rand) {
+	if density <= 0 {
+		return
+	}
+
+	for y := 0; y < m.Height; y++ {
+		for x := 0; x < m.Width; x++ {
+			if rng.Float64() >= density {
+				continue
+			}
+
+			c := &m.Cells[y][x]
+			switch {
+			case !c.Left && !c.Right && c.Top && c.Bottom:
+				// Horizontal corridor: tunnel runs vertically beneath it
+				if y == 0 || y == m.Height-1 ||
+					m.Cells[y-1][x].Under != "" || m.Cells[y+1][x].Under != "" {
+					continue
+				}
+				c.Under = AxisVertical
+				m.removeWall(x, y, x, y-1)
+				m.removeWall(x, y, x, y+1)
+			case !c.Top && !c.Bottom && c.Left && c.Right:
+				// Vertical corridor: tunnel runs horizontally beneath it
+				if x == 0 || x == m.Width-1 ||
+					m.Cells[y][x-1].Under != "" || m.Cells[y][x+1].Under != "" {
+					continue
+				}
+				c.Under = AxisHorizontal
+				m.removeWall(x, y, x-1, y)
+				m.removeWall(x, y, x+1, y)
+			}
+		}
+	}
+}
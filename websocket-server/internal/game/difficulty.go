@@ -0,0 +1,123 @@
+package game
+
+import "math"
+
+// Difficulty tiers a maze can be rated in, and asked for with
+// Options.Difficulty
+const (
+	DifficultyEasy   = "easy"
+	DifficultyMedium = "medium"
+	DifficultyHard   = "hard"
+)
+
+// Lowest score of the medium and hard tiers; easy is everything below
+const (
+	mediumScore = 35
+	hardScore   = 55
+)
+
+// difficultyLoopStep is how much Generate braids more, or less, after an
+// attempt that came out harder, or easier, than the difficulty asked for
+const difficultyLoopStep = 0.15
+
+// Rating is how hard a maze is to solve, from what its solutions look like
+type Rating struct {
+	Tier           string  `json:"tier"`
+	Score          int     `json:"score"`          // 0 (a straight corridor) to 100
+	SolutionLength int     `json:"solutionLength"` // Average corridor distance from a spawn to its nearest exit
+	BranchFactor   float64 `json:"branchFactor"`   // Wrong turns on offer per step of those solutions
+	DeadEnds       int     `json:"deadEnds"`       // Cells with one open side
+}
+
+// IsDifficulty reports whether name is a difficulty tier
+func IsDifficulty(name string) bool {
+	return name == DifficultyEasy || name == DifficultyMedium || name == DifficultyHard
+}
+
+// DifficultyOf is the tier a score falls in
+func DifficultyOf(score int) string {
+	switch {
+	case score >= hardScore:
+		return DifficultyHard
+	case score >= mediumScore:
+		return DifficultyMedium
+	}
+	return DifficultyEasy
+}
+
+// Rate scores how hard the maze is. Three things make a maze hard: a
+// solution that winds far beyond the straight line to the exit, many
+// side turnings to get wrong along it, and dead ends to waste time in.
+// Each is scaled to 0-1 and weighted 40/30/30. Braiding shortens the
+// solution and closes dead ends, so it makes a maze easier.
+func (m *Maze) Rate() Rating {
+	var r Rating
+	dist := m.GoalDistanceMap()
+	winding, branches, steps, solved := 0.0, 0, 0, 0
+	for _, s := range m.Spawns {
+		d := dist.At(s)
+		if d <= 0 {
+			continue
+		}
+		solved++
+		r.SolutionLength += d
+
+		// Compare to the straight line to the exit the solution ends at
+		path := m.PathToGoal(s, LevelSurface)
+		end := path[len(path)-1]
+		winding += float64(d) / float64(max(1, abs(end.X-s.X)+abs(end.Y-s.Y)+abs(end.Z-s.Z)))
+
+		for i, p := range path[:len(path)-1] {
+			wrong := len(m.openSides(p)) - 1 // Every way on but the right one...
+			if i > 0 {
+				wrong-- // ...or back
+			}
+			branches += max(0, wrong)
+			steps++
+		}
+	}
+	if solved > 0 {
+		r.SolutionLength /= solved
+		winding /= float64(solved)
+		r.BranchFactor = float64(branches) / float64(steps)
+	}
+	points := m.Points()
+	for _, p := range points {
+		if m.IsDeadEnd(p) {
+			r.DeadEnds++
+		}
+	}
+
+	windingScore := clamp01((winding - 1) / 3)
+	branchScore := clamp01(r.BranchFactor / 0.7)
+	deadEndScore := clamp01(float64(r.DeadEnds) / float64(len(points)) / 0.3)
+	r.Score = int(math.Round(100 * (0.4*windingScore + 0.3*branchScore + 0.3*deadEndScore)))
+	r.Tier = DifficultyOf(r.Score)
+	r.BranchFactor = math.Round(r.BranchFactor*100) / 100
+	return r
+}
+
+// difficultyMiss is how many points a score is outside the range of a tier,
+// 0 if it is in it or no tier is asked for, negative if it is too easy
+func difficultyMiss(score int, tier string) int {
+	low, high := 0, 100
+	switch tier {
+	case DifficultyEasy:
+		high = mediumScore - 1
+	case DifficultyMedium:
+		low, high = mediumScore, hardScore-1
+	case DifficultyHard:
+		low = hardScore
+	}
+	switch {
+	case score < low:
+		return score - low
+	case score > high:
+		return score - high
+	}
+	return 0
+}
+
+func clamp01(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
@@ -0,0 +1,67 @@
+package game
+
+import "math/rand"
+
+// Eller builds the maze one row at a time, tracking which cells of the
+// current row are already connected. Produces long horizontal runs with
+// frequent vertical shortcuts, so races tend to be quick and tactical.
+type Eller struct{}
+
+// Generate implements MazeGenerator
+func (Eller) Generate(m *Maze, rng *rand.Rand) {
+	sets := make([]int, m.Width)
+	nextSet := 1
+
+	for y := 0; y < m.Height; y++ {
+		lastRow := y == m.Height-1
+
+		// Cells without a set (fresh row or no vertical link) get their own
+		for x := range sets {
+			if sets[x] == 0 {
+				sets[x] = nextSet
+				nextSet++
+			}
+		}
+
+		// Randomly join horizontal neighbors from different sets. The last
+		// row must join everything so the maze stays connected.
+		for x := 0; x < m.Width-1; x++ {
+			if sets[x] == sets[x+1] || (!lastRow && rng.Intn(2) == 0) {
+				continue
+			}
+			m.removeWall(x, y, x+1, y)
+			old := sets[x+1]
+			for i := range sets {
+				if sets[i] == old {
+					sets[i] = sets[x]
+				}
+			}
+		}
+
+		if lastRow {
+			break
+		}
+
+		// Every set needs at least one passage down to the next row
+		// (sets are visited in row order so a seeded rng stays deterministic)
+		members := make(map[int][]int)
+		var order []int
+		for x, s := range sets {
+			if _, seen := members[s]; !seen {
+				order = append(order, s)
+			}
+			members[s] = append(members[s], x)
+		}
+		below := make([]int, m.Width)
+		for _, s := range order {
+			xs := members[s]
+			rng.Shuffle(len(xs), func(i, j int) { xs[i], xs[j] = xs[j], xs[i] })
+			count := 1 + rng.Intn(len(xs))
+			for _, x := range xs[:count] {
+				m.removeWall(x, y, x, y+1)
+				below[x] = s
+			}
+		}
+		sets = below
+	}
+}
@@ -0,0 +1,116 @@
+package game
+
+import (
+	"errors"
+	"math/rand"
+)
+
+// MaxFloors is the most floors a maze may be stacked from
+const MaxFloors = 4
+
+// Errors that make Generate throw a maze away and try the next seed
+var (
+	errNoStairs     = errors.New("a floor has no cell for stairs to the next")
+	errDisconnected = errors.New("part of the maze can't be reached")
+)
+
+// Stair directions a player can climb in, alongside up, right, down and
+// left on a floor
+const (
+	DirectionUpstairs   = "upstairs"
+	DirectionDownstairs = "downstairs"
+)
+
+// FloorCount is how many floors the maze has, 1 for a flat maze
+func (m *Maze) FloorCount() int {
+	return len(m.Upper) + 1
+}
+
+// Floor returns the cells of floor z, indexed [y][x]
+func (m *Maze) Floor(z int) [][]Cell {
+	if z == 0 {
+		return m.Cells
+	}
+	return m.Upper[z-1]
+}
+
+// CellAt returns the cell at p, which must be inside the maze
+func (m *Maze) CellAt(p Point) *Cell {
+	return &m.Floor(p.Z)[p.Y][p.X]
+}
+
+// Contains reports whether p is a cell of the maze, on one of its floors
+func (m *Maze) Contains(p Point) bool {
+	return m.InBounds(p.X, p.Y) && p.Z >= 0 && p.Z < m.FloorCount()
+}
+
+// Points lists every cell of the maze, floor by floor in reading order
+func (m *Maze) Points() []Point {
+	points := make([]Point, 0, m.Width*m.Height*m.FloorCount())
+	for z := 0; z < m.FloorCount(); z++ {
+		for y := 0; y < m.Height; y++ {
+			for x := 0; x < m.Width; x++ {
+				points = append(points, Point{X: x, Y: y, Z: z})
+			}
+		}
+	}
+	return points
+}
+
+// floorView returns a flat maze sharing the cells of floor z as its Cells,
+// so the carving and wall helpers, which only see Cells, can work on any
+// floor in place
+func (m *Maze) floorView(z int) *Maze {
+	return &Maze{Width: m.Width, Height: m.Height, Cells: m.Floor(z), Theme: m.Theme}
+}
+
+// Climb returns the change of floor for a stair direction (upstairs,
+// downstairs)
+func Climb(direction string) (dz int, ok bool) {
+	switch direction {
+	case DirectionUpstairs:
+		return 1, true
+	case DirectionDownstairs:
+		return -1, true
+	}
+	return 0, false
+}
+
+// Moves lists the cells one step from p a player might try: its four
+// neighbors on the floor and the same cell on the floors above and below.
+// Step and CanMove tell which of them are open.
+func Moves(p Point) []Point {
+	out := make([]Point, 0, len(directions)+2)
+	for _, d := range directions {
+		out = append(out, Point{X: p.X + d.DX, Y: p.Y + d.DY, Z: p.Z})
+	}
+	return append(out, Point{X: p.X, Y: p.Y, Z: p.Z + 1}, Point{X: p.X, Y: p.Y, Z: p.Z - 1})
+}
+
+// placeStairs joins every floor to the one above with a staircase on a
+// random cell that is a crossing on neither floor, nor the exit. Each floor
+// is a maze of its own, so one staircase between them keeps the whole maze
+// connected; if two floors have no such cell, errNoStairs is returned.
+func (m *Maze) placeStairs(rng *rand.Rand) error {
+	for z := 0; z+1 < m.FloorCount(); z++ {
+		var candidates []Point
+		for y := 0; y < m.Height; y++ {
+			for x := 0; x < m.Width; x++ {
+				below := Point{X: x, Y: y, Z: z}
+				above := Point{X: x, Y: y, Z: z + 1}
+				if m.CellAt(below).Under != "" || m.CellAt(above).Under != "" ||
+					m.CellAt(below).StairsDown || above == m.Goal {
+					continue
+				}
+				candidates = append(candidates, below)
+			}
+		}
+		if len(candidates) == 0 {
+			return errNoStairs
+		}
+		at := candidates[rng.Intn(len(candidates))]
+		m.CellAt(at).StairsUp = true
+		m.CellAt(Point{X: at.X, Y: at.Y, Z: z + 1}).StairsDown = true
+	}
+	return nil
+}
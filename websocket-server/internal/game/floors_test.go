@@ -0,0 +1,35 @@
+package game
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// TestPlaceStairsNoRoom fills a floor with crossings, leaving the stairs
+// nowhere to go: placing them fails instead of cutting the floors apart
+func TestPlaceStairsNoRoom(t *testing.T) {
+	m := &Maze{Width: 3, Height: 3, Cells: newFloor(3, 3, 0), Upper: [][][]Cell{newFloor(3, 3, 1)}}
+	for _, p := range m.Points() {
+		if p.Z == 1 {
+			m.CellAt(p).Under = "horizontal"
+		}
+	}
+	if err := m.placeStairs(rand.New(rand.NewSource(1))); err != errNoStairs {
+		t.Fatalf("placeStairs returned %v, want errNoStairs", err)
+	}
+}
+
+// TestGenerateConnected checks every algorithm, with and without floors
+// and crossings, only hands out mazes whose every cell can be reached
+func TestGenerateConnected(t *testing.T) {
+	for _, algorithm := range Algorithms() {
+		for _, floors := range []int{1, 3} {
+			for _, seed := range []int64{1, 7, 42} {
+				m := Generate(10, 10, Options{Seed: seed, Algorithm: algorithm, Floors: floors, CrossingDensity: 0.5})
+				if m.FloorCount() != floors || !m.IsConnected() {
+					t.Errorf("%s, %d floors, seed %d: %d floors, connected %v", algorithm, floors, seed, m.FloorCount(), m.IsConnected())
+				}
+			}
+		}
+	}
+}
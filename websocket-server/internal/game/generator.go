@@ -0,0 +1,56 @@
+package game
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// MazeGenerator carves passages into a maze whose cells start with all
+// four walls up. Every implementation produces a perfect maze (exactly one
+// path between any two cells), but with different corridor shapes. Only
+// m.Cells is carved, so a maze with floors is carved one floorView at a
+// time.
+type MazeGenerator interface {
+	Generate(m *Maze, rng *rand.Rand)
+}
+
+// Algorithm names accepted by GeneratorByName
+const (
+	AlgorithmBacktracker = "backtracker"
+	AlgorithmPrim        = "prim"
+	AlgorithmKruskal     = "kruskal"
+	AlgorithmWilson      = "wilson"
+	AlgorithmEller       = "eller"
+	AlgorithmWeave       = "weave"
+)
+
+// DefaultAlgorithm is used when a room doesn't ask for one
+const DefaultAlgorithm = AlgorithmBacktracker
+
+var generators = map[string]MazeGenerator{
+	AlgorithmBacktracker: Backtracker{},
+	AlgorithmPrim:        Prim{},
+	AlgorithmKruskal:     Kruskal{},
+	AlgorithmWilson:      Wilson{},
+	AlgorithmEller:       Eller{},
+	AlgorithmWeave:       Weave{},
+}
+
+// GeneratorByName looks up a maze generation algorithm
+func GeneratorByName(name string) (MazeGenerator, bool) {
+	g, ok := generators[name]
+	return g, ok
+}
+
+// Algorithms lists the registered algorithm names in sorted order
+func Algorithms() []string {
+	names := make([]string, 0, len(generators))
+	for name := range generators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// edge is a wall between two orthogonally adjacent cells
+type edge struct{ x1, y1, x2, y2 int }
@@ -0,0 +1,91 @@
+package game
+
+import "math/rand"
+
+const (
+	// GoalPointsPerStep is how much an exit is worth per corridor step from
+	// the first spawn, so farther exits pay more
+	GoalPointsPerStep = 5
+	// MinGoalSpawnDistance is how close to any spawn an extra exit may be
+	MinGoalSpawnDistance = 3
+)
+
+// Goal is an exit cell and how many points reaching it is worth
+type Goal struct {
+	Point
+	Value int `json:"value"`
+}
+
+// placeGoals keeps the bottom-right exit and adds count-1 more exits at
+// random dead-end tips every spawn can reach from a fair distance, then
+// prices every exit by its distance from spawn
+func (m *Maze) placeGoals(count int, rng *rand.Rand) {
+	m.Goals = []Goal{{Point: m.Goal}}
+
+	if count > 1 {
+		var tips []Point
+		for _, d := range m.DeadEnds() {
+			if d.Tip != m.Goal && !m.IsSpawn(d.Tip) {
+				tips = append(tips, d.Tip)
+			}
+		}
+		rng.Shuffle(len(tips), func(i, j int) { tips[i], tips[j] = tips[j], tips[i] })
+		for _, tip := range tips {
+			if len(m.Goals) == count {
+				break
+			}
+			if !m.goalPlaceable(tip) {
+				continue
+			}
+			m.Goals = append(m.Goals, Goal{Point: tip})
+		}
+	}
+
+	m.priceGoals()
+}
+
+// priceGoals sets every exit's value from its distance to the first spawn
+func (m *Maze) priceGoals() {
+	dist := m.DistanceMap(m.Spawns[0])
+	for i := range m.Goals {
+		g := &m.Goals[i]
+		g.Value = dist.At(g.Point) * GoalPointsPerStep
+	}
+}
+
+// goalPlaceable reports whether every spawn has a path to p at least
+// MinGoalSpawnDistance long
+func (m *Maze) goalPlaceable(p Point) bool {
+	for _, s := range m.Spawns {
+		if d := m.Distance(s, p); d < MinGoalSpawnDistance {
+			return false
+		}
+	}
+	return true
+}
+
+// GoalAt returns the exit at p, if there is one
+func (m *Maze) GoalAt(p Point) (Goal, bool) {
+	for _, g := range m.Goals {
+		if g.Point == p {
+			return g, true
+		}
+	}
+	return Goal{}, false
+}
+
+// IsGoal reports whether p is one of the maze's exits
+func (m *Maze) IsGoal(p Point) bool {
+	_, ok := m.GoalAt(p)
+	return ok
+}
+
+// GoalDistanceMap returns the corridor distance from every cell to its
+// nearest exit. Unreachable cells are -1.
+func (m *Maze) GoalDistanceMap() Distances {
+	points := make([]Point, len(m.Goals))
+	for i, g := range m.Goals {
+		points[i] = g.Point
+	}
+	return m.NearestDistanceMap(points)
+}
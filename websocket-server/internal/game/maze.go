@@ -2,32 +2,212 @@ package game
 
 import (
 	"fmt"
+	"log/slog"
+	"math"
 	"math/rand"
+	"time"
 )
 
 // Cell represents a single cell in the maze
 type Cell struct {
-	X       int  `json:"x"`
-	Y       int  `json:"y"`
-	Top     bool `json:"top"`
-	Right   bool `json:"right"`
-	Bottom  bool `json:"bottom"`
-	Left    bool `json:"left"`
-	Visited bool `json:"-"` // Don't send to client
+	X          int     `json:"x"`
+	Y          int     `json:"y"`
+	Z          int     `json:"z,omitempty"` // Floor, from 0 at the bottom
+	Top        bool    `json:"top"`
+	Right      bool    `json:"right"`
+	Bottom     bool    `json:"bottom"`
+	Left       bool    `json:"left"`
+	StairsUp   bool    `json:"stairsUp,omitempty"`   // Stairs up to the same cell on the floor above
+	StairsDown bool    `json:"stairsDown,omitempty"` // Stairs down to the same cell on the floor below
+	Terrain    Terrain `json:"terrain,omitempty"`
+	Under      string  `json:"under,omitempty"` // Axis of a tunnel passing beneath, if this is a crossing
+	Visited    bool    `json:"-"`               // Don't send to client
 }
 
 // Maze represents the game maze
 type Maze struct {
-	Width  int      `json:"width"`
-	Height int      `json:"height"`
-	Cells  [][]Cell `json:"cells"`
+	Width      int        `json:"width"`
+	Height     int        `json:"height"`
+	Cells      [][]Cell   `json:"cells"`             // The ground floor (z = 0) only; Floor(z) reaches every floor
+	Upper      [][][]Cell `json:"upper,omitempty"`   // Floors above the ground floor, indexed [z-1][y][x]
+	Goal       Point      `json:"goal"`              // Primary exit (bottom-right)
+	Goals      []Goal     `json:"goals"`             // Every exit, including Goal
+	Spawns     []Point    `json:"spawns"`            // Where players start
+	Portals    []Portal   `json:"portals,omitempty"` // Linked pairs of portal cells
+	Seed       int64      `json:"seed"`
+	Algorithm  string     `json:"algorithm"`
+	Theme      string     `json:"theme"`
+	LoopFactor float64    `json:"loopFactor"`
 }
 
-// NewMaze generates a new maze using recursive backtracking
-func NewMaze(width, height int) *Maze {
-	// Note: rand is auto-seeded in Go 1.20+
+// Point is a cell coordinate in the maze
+type Point struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	Z int `json:"z,omitempty"` // Floor
+}
+
+// String writes the point as (x,y), with the floor as (x,y,z) above the
+// ground floor
+func (p Point) String() string {
+	if p.Z == 0 {
+		return fmt.Sprintf("(%d,%d)", p.X, p.Y)
+	}
+	return fmt.Sprintf("(%d,%d,%d)", p.X, p.Y, p.Z)
+}
+
+// maxSeed keeps generated seeds exactly representable as JavaScript numbers
+const maxSeed = 1 << 53
+
+// Options controls how a maze is generated
+type Options struct {
+	Seed            int64   // Same seed + options = same maze; 0 picks a random seed
+	Algorithm       string  // One of Algorithms(); empty means DefaultAlgorithm
+	Theme           string  // Cosmetic theme; empty means the current seasonal theme
+	LoopFactor      float64 // Fraction of dead ends to braid into loops (0 = perfect maze)
+	TerrainDensity  float64 // Fraction of cells covered in the theme's terrain, see Theme.Terrain
+	CrossingDensity float64 // Fraction of straight corridors given a tunnel underneath
+	MinPathRatio    float64 // Spawn-to-goal distance must be at least this share of the longest possible path
+	GoalCount       int     // Number of exits (default 1)
+	SpawnCount      int     // Number of spawn points (default DefaultSpawnCount)
+	Difficulty      string  // Tier the maze should Rate in: easy, medium or hard; empty means any
+	Floors          int     // Floors stacked and joined by stairs, 1 to MaxFloors (default 1)
+}
+
+// MaxGenerationAttempts bounds how often Generate retries to satisfy
+// constraints such as MinPathRatio and Difficulty before settling for the
+// best attempt
+const MaxGenerationAttempts = 50
+
+// NewMaze generates a new maze using recursive backtracking. An optional
+// seed reproduces a previous maze exactly.
+func NewMaze(width, height int, seed ...int64) *Maze {
+	opts := Options{}
+	if len(seed) > 0 {
+		opts.Seed = seed[0]
+	}
+	return Generate(width, height, opts)
+}
+
+// Generate builds a new maze with the given options. Unknown algorithms
+// fall back to DefaultAlgorithm. Attempts that leave any cell unreachable
+// are thrown away. If the maze fails the MinPathRatio
+// constraint, or doesn't rate in the Difficulty tier, it is regenerated
+// with the next seed; the returned maze's Seed always reproduces it
+// directly. Chasing a tier also braids more after a maze that was too hard
+// and less after one too easy, so the returned maze's LoopFactor may differ
+// from the one asked for; some algorithms can't reach every tier at every
+// size, and then the closest attempt is kept.
+func Generate(width, height int, opts Options) *Maze {
+	gen, ok := GeneratorByName(opts.Algorithm)
+	if !ok {
+		opts.Algorithm = DefaultAlgorithm
+		gen = generators[DefaultAlgorithm]
+	}
+	if _, ok := ThemeByName(opts.Theme); !ok {
+		opts.Theme = SeasonalTheme(time.Now())
+	}
+	if opts.Seed == 0 {
+		opts.Seed = rand.Int63n(maxSeed-1) + 1
+	}
+	opts.Floors = min(max(opts.Floors, 1), MaxFloors)
+
+	// The longest possible path visits every cell once
+	required := int(opts.MinPathRatio * float64(width*height*opts.Floors-1))
+
+	// Attempts are ranked by how far short of the path length they fall,
+	// then by how far off the difficulty they are
+	var best *Maze
+	bestShort, bestMiss := 0, 0
+	for attempt := 0; attempt < MaxGenerationAttempts; attempt++ {
+		maze, err := generateOnce(width, height, opts, gen)
+		if err == nil && !maze.IsConnected() {
+			err = errDisconnected
+		}
+		if err != nil {
+			slog.Debug("Discarding maze", "seed", opts.Seed, "algorithm", opts.Algorithm, "err", err)
+			opts.Seed = opts.Seed%(maxSeed-1) + 1
+			continue
+		}
+		dist := maze.ShortestSpawnDistance()
+		short := max(0, required-dist)
+		if dist < 0 {
+			short = required + 1 // Worse than any maze that can be solved
+		}
+		miss := 0
+		if IsDifficulty(opts.Difficulty) {
+			miss = difficultyMiss(maze.Rate().Score, opts.Difficulty)
+		}
+		if best == nil || short < bestShort || short == bestShort && abs(miss) < abs(bestMiss) {
+			best, bestShort, bestMiss = maze, short, miss
+		}
+		if bestShort == 0 && bestMiss == 0 {
+			break
+		}
+		opts.Seed = opts.Seed%(maxSeed-1) + 1
+		if miss > 0 {
+			opts.LoopFactor = math.Min(1, opts.LoopFactor+difficultyLoopStep)
+		} else if miss < 0 {
+			opts.LoopFactor = math.Max(0, opts.LoopFactor-difficultyLoopStep)
+		}
+	}
+
+	if best == nil {
+		// Crossings can leave floors nowhere to put their stairs; without
+		// them every cell but the exit will do
+		opts.Algorithm, opts.CrossingDensity = DefaultAlgorithm, 0
+		best, _ = generateOnce(width, height, opts, generators[DefaultAlgorithm])
+	}
+
+	slog.Debug("Generated maze", "seed", best.Seed, "algorithm", best.Algorithm,
+		"width", width, "height", height, "floors", opts.Floors, "spawnDistance", best.ShortestSpawnDistance(), "difficultyMiss", bestMiss)
+
+	return best
+}
+
+// generateOnce builds a single maze from a seed, without any constraints.
+// Every floor is carved on its own, then stairs join each to the next.
+func generateOnce(width, height int, opts Options, gen MazeGenerator) (*Maze, error) {
+	rng := rand.New(rand.NewSource(opts.Seed))
+
+	maze := &Maze{
+		Width:      width,
+		Height:     height,
+		Cells:      newFloor(width, height, 0),
+		Goal:       Point{X: width - 1, Y: height - 1, Z: opts.Floors - 1}, // Exit at bottom-right of the top floor
+		Seed:       opts.Seed,
+		Algorithm:  opts.Algorithm,
+		Theme:      opts.Theme,
+		LoopFactor: opts.LoopFactor,
+	}
+
+	for z := 0; z < opts.Floors; z++ {
+		if z > 0 {
+			maze.Upper = append(maze.Upper, newFloor(width, height, z))
+		}
+		floor := maze.floorView(z)
+		gen.Generate(floor, rng)
+		floor.Braid(opts.LoopFactor, rng)
+		floor.addCrossings(opts.CrossingDensity, rng)
+	}
+	if err := maze.placeStairs(rng); err != nil {
+		return nil, err
+	}
+
+	spawns := opts.SpawnCount
+	if spawns <= 0 {
+		spawns = DefaultSpawnCount
+	}
+	maze.placeSpawns(spawns)
+	maze.placeGoals(opts.GoalCount, rng)
+	maze.placeTerrain(opts.TerrainDensity, rng)
+
+	return maze, nil
+}
 
-	// Initialize grid with all walls
+// newFloor returns a height x width grid of cells on floor z with all
+// their walls up
+func newFloor(width, height, z int) [][]Cell {
 	cells := make([][]Cell, height)
 	for y := 0; y < height; y++ {
 		cells[y] = make([]Cell, width)
@@ -35,6 +215,7 @@ func NewMaze(width, height int) *Maze {
 			cells[y][x] = Cell{
 				X:       x,
 				Y:       y,
+				Z:       z,
 				Top:     true,
 				Right:   true,
 				Bottom:  true,
@@ -43,24 +224,16 @@ func NewMaze(width, height int) *Maze {
 			}
 		}
 	}
-
-	maze := &Maze{
-		Width:  width,
-		Height: height,
-		Cells:  cells,
-	}
-
-	// Generate maze using recursive backtracking
-	maze.generate()
-
-	// Debug: print cell (0,0) walls
-	c := maze.Cells[0][0]
-	fmt.Printf("Cell (0,0) walls - Top:%v Right:%v Bottom:%v Left:%v\n", c.Top, c.Right, c.Bottom, c.Left)
-
-	return maze
+	return cells
 }
 
-func (m *Maze) generate() {
+// Backtracker is the classic recursive backtracking (depth-first)
+// algorithm. Produces long winding corridors with few branches, so the
+// solution path is long and dead ends are deep.
+type Backtracker struct{}
+
+// Generate implements MazeGenerator
+func (Backtracker) Generate(m *Maze, rng *rand.Rand) {
 	stack := []struct{ x, y int }{{0, 0}}
 	m.Cells[0][0].Visited = true
 
@@ -73,21 +246,37 @@ func (m *Maze) generate() {
 			stack = stack[:len(stack)-1] // Pop
 		} else {
 			// Pick random neighbor
-			idx := rand.Intn(len(neighbors))
+			idx := rng.Intn(len(neighbors))
 			next := neighbors[idx]
 
-			if iterations < 5 {
-				fmt.Printf("Iteration %d: current=(%d,%d) next=(%d,%d)\n",
-					iterations, current.x, current.y, next.x, next.y)
-			}
-
 			m.removeWall(current.x, current.y, next.x, next.y)
 			m.Cells[next.y][next.x].Visited = true
 			stack = append(stack, next)
 		}
 		iterations++
 	}
-	fmt.Printf("Maze generated in %d iterations\n", iterations)
+	slog.Debug("Backtracker finished", "iterations", iterations)
+}
+
+// Clone returns a deep copy of the maze that shares nothing with it
+func (m *Maze) Clone() *Maze {
+	c := *m
+	c.Cells = make([][]Cell, len(m.Cells))
+	for y, row := range m.Cells {
+		c.Cells[y] = append([]Cell(nil), row...)
+	}
+	c.Upper = nil
+	for _, floor := range m.Upper {
+		rows := make([][]Cell, len(floor))
+		for y, row := range floor {
+			rows[y] = append([]Cell(nil), row...)
+		}
+		c.Upper = append(c.Upper, rows)
+	}
+	c.Goals = append([]Goal(nil), m.Goals...)
+	c.Spawns = append([]Point(nil), m.Spawns...)
+	c.Portals = append([]Portal(nil), m.Portals...)
+	return &c
 }
 
 func (m *Maze) getUnvisitedNeighbors(x, y int) []struct{ x, y int } {
@@ -114,33 +303,98 @@ func (m *Maze) getUnvisitedNeighbors(x, y int) []struct{ x, y int } {
 }
 
 func (m *Maze) removeWall(x1, y1, x2, y2 int) {
+	m.setWall(x1, y1, x2, y2, false)
+}
+
+// setWall sets the shared wall between two adjacent cells on both sides
+func (m *Maze) setWall(x1, y1, x2, y2 int, wall bool) {
 	dx := x2 - x1
 	dy := y2 - y1
 
 	if dx == 1 {
-		m.Cells[y1][x1].Right = false
-		m.Cells[y2][x2].Left = false
+		m.Cells[y1][x1].Right = wall
+		m.Cells[y2][x2].Left = wall
 	} else if dx == -1 {
-		m.Cells[y1][x1].Left = false
-		m.Cells[y2][x2].Right = false
+		m.Cells[y1][x1].Left = wall
+		m.Cells[y2][x2].Right = wall
 	} else if dy == 1 {
-		m.Cells[y1][x1].Bottom = false
-		m.Cells[y2][x2].Top = false
+		m.Cells[y1][x1].Bottom = wall
+		m.Cells[y2][x2].Top = wall
 	} else if dy == -1 {
-		m.Cells[y1][x1].Top = false
-		m.Cells[y2][x2].Bottom = false
+		m.Cells[y1][x1].Top = wall
+		m.Cells[y2][x2].Bottom = wall
 	}
 }
 
-// CanMove checks if movement from one cell to another is valid
-func (m *Maze) CanMove(fromX, fromY, toX, toY int) bool {
-	if toX < 0 || toX >= m.Width || toY < 0 || toY >= m.Height {
+// RemoveWallBetween knocks down the wall between two adjacent cells on
+// the same floor. Returns false if the cells aren't such neighbors inside
+// the maze, or if there is no wall to remove.
+func (m *Maze) RemoveWallBetween(a, b Point) bool {
+	if !m.Contains(a) || !m.Contains(b) || a.Z != b.Z {
 		return false
 	}
+	if abs(b.X-a.X)+abs(b.Y-a.Y) != 1 || m.CanMove(a.X, a.Y, a.Z, b.X, b.Y, b.Z) {
+		return false
+	}
+	m.floorView(a.Z).removeWall(a.X, a.Y, b.X, b.Y)
+	return true
+}
 
-	cell := m.Cells[fromY][fromX]
+// AddWallBetween builds a wall between two adjacent cells on the same
+// floor. Returns false if the cells aren't such neighbors inside the maze,
+// or a wall is already there.
+func (m *Maze) AddWallBetween(a, b Point) bool {
+	if !m.Contains(a) || !m.Contains(b) || a.Z != b.Z {
+		return false
+	}
+	if abs(b.X-a.X)+abs(b.Y-a.Y) != 1 || !m.CanMove(a.X, a.Y, a.Z, b.X, b.Y, b.Z) {
+		return false
+	}
+	m.floorView(a.Z).setWall(a.X, a.Y, b.X, b.Y, true)
+	return true
+}
+
+// IsConnected reports whether every cell, on every floor, can be reached
+// from the first spawn
+func (m *Maze) IsConnected() bool {
+	dist := m.DistanceMap(m.Spawns[0])
+	for _, p := range m.Points() {
+		if dist.At(p) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// InBounds reports whether (x, y) is inside the maze
+func (m *Maze) InBounds(x, y int) bool {
+	return x >= 0 && x < m.Width && y >= 0 && y < m.Height
+}
+
+// CanMove checks if movement from one cell to an adjacent one is valid:
+// through an opening to a neighbor on the same floor, or up or down the
+// stairs to the same cell on the next floor
+func (m *Maze) CanMove(fromX, fromY, fromZ, toX, toY, toZ int) bool {
+	from, to := Point{X: fromX, Y: fromY, Z: fromZ}, Point{X: toX, Y: toY, Z: toZ}
+	if !m.Contains(from) || !m.Contains(to) {
+		return false
+	}
+	// Exactly one step along one axis; diagonals and jumps are never moves
+	if abs(toX-fromX)+abs(toY-fromY)+abs(toZ-fromZ) != 1 {
+		return false
+	}
+
+	cell := m.CellAt(from)
 	dx := toX - fromX
 	dy := toY - fromY
+	dz := toZ - fromZ
 
 	if dx == 1 {
 		return !cell.Right
@@ -154,6 +408,12 @@ func (m *Maze) CanMove(fromX, fromY, toX, toY int) bool {
 	if dy == -1 {
 		return !cell.Top
 	}
+	if dz == 1 {
+		return cell.StairsUp
+	}
+	if dz == -1 {
+		return cell.StairsDown
+	}
 
 	return false
 }
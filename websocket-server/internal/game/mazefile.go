@@ -0,0 +1,415 @@
+package game
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Maze file formats, for exporting mazes and loading hand-designed ones
+const (
+	FormatJSON = "json" // The Maze as JSON, with the same fields as mazeData
+	FormatText = "text" // ASCII art, see ParseText
+)
+
+// AlgorithmCustom is the Algorithm of mazes loaded from a file that don't
+// name the one they were made with
+const AlgorithmCustom = "custom"
+
+// MaxFileSize is the most cells a side of a maze loaded from a file may have
+const MaxFileSize = 100
+
+// Cell marks in the text format: spawn and exits, terrain, and tunnel. A
+// cell holds at most one of each kind.
+const roleMarks = "SGg"
+
+var (
+	terrainMarks = map[byte]Terrain{'m': TerrainMud, 'r': TerrainRoad, 'i': TerrainIce, 'p': TerrainPortal}
+	tunnelMarks  = map[byte]string{'h': AxisHorizontal, 'v': AxisVertical}
+)
+
+// ImportMaze reads a maze file in the given format, guessing it from the
+// first character if format is empty, checks the maze with Validate and
+// prices its exits
+func ImportMaze(data []byte, format string) (*Maze, error) {
+	if format == "" {
+		format = FormatText
+		if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+			format = FormatJSON
+		}
+	}
+
+	var m *Maze
+	var err error
+	switch format {
+	case FormatJSON:
+		m, err = parseJSON(data)
+	case FormatText:
+		m, err = ParseText(data)
+	default:
+		return nil, fmt.Errorf("unknown maze format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	m.priceGoals()
+	return m, nil
+}
+
+// Export writes the maze in one of the file formats. Only FormatJSON holds
+// more than one floor.
+func (m *Maze) Export(format string) ([]byte, error) {
+	switch format {
+	case FormatJSON:
+		return json.MarshalIndent(m, "", "  ")
+	case FormatText:
+		if m.FloorCount() > 1 {
+			return nil, fmt.Errorf("a maze with %d floors can't be drawn as text, export it as json", m.FloorCount())
+		}
+		return []byte(m.Text()), nil
+	}
+	return nil, fmt.Errorf("unknown maze format %q", format)
+}
+
+// parseJSON reads a maze in FormatJSON. Cell coordinates come from where
+// the cells are in the grid, and exit values are worked out again.
+func parseJSON(data []byte) (*Maze, error) {
+	var m Maze
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("malformed maze JSON: %w", err)
+	}
+	for z := 0; z < m.FloorCount(); z++ {
+		for y, row := range m.Floor(z) {
+			for x := range row {
+				row[x].X, row[x].Y, row[x].Z, row[x].Visited = x, y, z, false
+			}
+		}
+	}
+	if len(m.Goals) == 0 {
+		m.Goals = []Goal{{Point: m.Goal}}
+	}
+	if m.Algorithm == "" {
+		m.Algorithm = AlgorithmCustom
+	}
+	return &m, nil
+}
+
+// Text draws the maze in FormatText, three characters to a cell
+func (m *Maze) Text() string {
+	var b strings.Builder
+	wallLine := func(y int) {
+		b.WriteByte('+')
+		for x := 0; x < m.Width; x++ {
+			if y < m.Height && m.Cells[y][x].Top || y == m.Height && m.Cells[y-1][x].Bottom {
+				b.WriteString("---+")
+			} else {
+				b.WriteString("   +")
+			}
+		}
+		b.WriteByte('\n')
+	}
+
+	for y := 0; y < m.Height; y++ {
+		wallLine(y)
+		for x := 0; x < m.Width; x++ {
+			c := m.Cells[y][x]
+			if c.Left {
+				b.WriteByte('|')
+			} else {
+				b.WriteByte(' ')
+			}
+			b.WriteByte(m.roleMark(Point{X: x, Y: y}))
+			b.WriteByte(markOf(terrainMarks, c.Terrain))
+			b.WriteByte(markOf(tunnelMarks, c.Under))
+		}
+		if m.Cells[y][m.Width-1].Right {
+			b.WriteByte('|')
+		}
+		b.WriteByte('\n')
+	}
+	wallLine(m.Height)
+
+	for _, p := range m.Portals {
+		fmt.Fprintf(&b, "portal %d %d %d %d\n", p.A.X, p.A.Y, p.B.X, p.B.Y)
+	}
+	return b.String()
+}
+
+// roleMark is the mark of the spawn or exit at p, or a space
+func (m *Maze) roleMark(p Point) byte {
+	switch {
+	case p == m.Goal:
+		return 'G'
+	case m.IsGoal(p):
+		return 'g'
+	case m.IsSpawn(p):
+		return 'S'
+	}
+	return ' '
+}
+
+// markOf finds the mark standing for v, or a space for none
+func markOf[V comparable](marks map[byte]V, v V) byte {
+	for mark, mv := range marks {
+		if mv == v {
+			return mark
+		}
+	}
+	return ' '
+}
+
+// ParseText reads a maze drawn in ASCII art. Rows of cells alternate with
+// rows of walls, every cell the same number of characters wide:
+//
+//	+---+---+---+
+//	| S   m   S |
+//	+   +---+   +
+//	| p   G   p |
+//	+---+---+---+
+//	portal 0 1 2 1
+//
+// A wall is - or | and an opening is blank, and + marks each corner.
+// Inside a cell, S is a spawn (in reading order), G the primary exit and g
+// any other; m, r, i and p are mud, road, ice and portal; and h or v is a
+// tunnel running horizontally or vertically under a crossing. Each portal
+// cell is linked to another by a portal line giving both cells' x and y.
+// Blank lines and lines starting with # are ignored.
+func ParseText(data []byte) (*Maze, error) {
+	var grid []string
+	var grids []int // Line number of each grid line, for errors
+	m := &Maze{Algorithm: AlgorithmCustom}
+	for i, line := range strings.Split(string(data), "\n") {
+		n := i + 1
+		line = strings.TrimRight(line, " \t\r")
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "+") || strings.HasPrefix(line, "|"):
+			if len(grid) > 0 && grids[len(grids)-1] != n-1 {
+				return nil, fmt.Errorf("line %d: the grid must be in one piece", n)
+			}
+			grid, grids = append(grid, line), append(grids, n)
+		case strings.HasPrefix(line, "portal "):
+			var p Portal
+			if _, err := fmt.Sscanf(line, "portal %d %d %d %d", &p.A.X, &p.A.Y, &p.B.X, &p.B.Y); err != nil {
+				return nil, fmt.Errorf("line %d: expected portal x1 y1 x2 y2", n)
+			}
+			m.Portals = append(m.Portals, p)
+		default:
+			return nil, fmt.Errorf("line %d: not part of the grid, a portal or a comment", n)
+		}
+	}
+	if len(grid) < 3 || len(grid)%2 == 0 {
+		return nil, fmt.Errorf("expected rows of cells between rows of walls, found %d grid lines", len(grid))
+	}
+
+	// The first corner after the top-left one gives the cell width
+	cellWidth := strings.IndexByte(grid[0][1:], '+')
+	if cellWidth < 1 || (len(grid[0])-1)%(cellWidth+1) != 0 {
+		return nil, fmt.Errorf("line %d: the top wall's corners are unevenly spaced", grids[0])
+	}
+	m.Width, m.Height = (len(grid[0])-1)/(cellWidth+1), len(grid)/2
+	if m.Width > MaxFileSize || m.Height > MaxFileSize {
+		return nil, fmt.Errorf("%dx%d is bigger than %dx%d", m.Width, m.Height, MaxFileSize, MaxFileSize)
+	}
+	for i, line := range grid {
+		if len(line) > len(grid[0]) {
+			return nil, fmt.Errorf("line %d: wider than the top wall", grids[i])
+		}
+		grid[i] = line + strings.Repeat(" ", len(grid[0])-len(line))
+	}
+
+	// walled reads the wall above row y (or below the last) over cell x
+	walled := func(y, x int) (bool, error) {
+		line, col := grid[2*y], x*(cellWidth+1)
+		if line[col] != '+' || line[col+cellWidth+1] != '+' {
+			return false, fmt.Errorf("line %d: expected + at columns %d and %d", grids[2*y], col+1, col+cellWidth+2)
+		}
+		switch segment := line[col+1 : col+cellWidth+1]; segment {
+		case strings.Repeat("-", cellWidth):
+			return true, nil
+		case strings.Repeat(" ", cellWidth):
+			return false, nil
+		}
+		return false, fmt.Errorf("line %d: a wall must be all - or all blank at column %d", grids[2*y], col+2)
+	}
+
+	m.Cells = make([][]Cell, m.Height)
+	var goals []Point
+	primary := false
+	for y := range m.Cells {
+		m.Cells[y] = make([]Cell, m.Width)
+		line, n := grid[2*y+1], grids[2*y+1]
+		for x := range m.Cells[y] {
+			col := x * (cellWidth + 1)
+			if line[col] != '|' && line[col] != ' ' {
+				return nil, fmt.Errorf("line %d: expected | or blank at column %d", n, col+1)
+			}
+			c := Cell{X: x, Y: y, Left: line[col] == '|', Right: line[col+cellWidth+1] == '|'}
+			var err error
+			if c.Top, err = walled(y, x); err != nil {
+				return nil, err
+			}
+			if c.Bottom, err = walled(y+1, x); err != nil {
+				return nil, err
+			}
+
+			var role byte
+			for i := col + 1; i <= col+cellWidth; i++ {
+				mark := line[i]
+				if strings.IndexByte(roleMarks, mark) >= 0 && role == 0 {
+					role = mark
+				} else if t, ok := terrainMarks[mark]; ok && c.Terrain == TerrainNormal {
+					c.Terrain = t
+				} else if axis, ok := tunnelMarks[mark]; ok && c.Under == "" {
+					c.Under = axis
+				} else if mark != ' ' {
+					return nil, fmt.Errorf("line %d: unexpected %q in cell (%d,%d)", n, mark, x, y)
+				}
+			}
+			p := Point{X: x, Y: y}
+			switch role {
+			case 'S':
+				m.Spawns = append(m.Spawns, p)
+			case 'G':
+				if primary {
+					return nil, fmt.Errorf("line %d: a second primary exit G at (%d,%d)", n, x, y)
+				}
+				m.Goal, primary = p, true
+				goals = append([]Point{p}, goals...)
+			case 'g':
+				goals = append(goals, p)
+			}
+			m.Cells[y][x] = c
+		}
+		if last := line[m.Width*(cellWidth+1)]; last != '|' && last != ' ' {
+			return nil, fmt.Errorf("line %d: expected | or blank after the last cell", n)
+		}
+	}
+
+	if !primary {
+		return nil, fmt.Errorf("no primary exit G")
+	}
+	for _, g := range goals {
+		m.Goals = append(m.Goals, Goal{Point: g})
+	}
+	return m, nil
+}
+
+// Validate checks that a maze from a file can be played: a consistent grid
+// closed all round on every floor, stairs that lead somewhere, spawns and
+// exits on cells of their own, crossings and portals that work, and a way
+// out from every spawn
+func (m *Maze) Validate() error {
+	if m.Width < 2 || m.Height < 2 || m.Width > MaxFileSize || m.Height > MaxFileSize {
+		return fmt.Errorf("%dx%d maze out of range, each side must be 2 to %d cells", m.Width, m.Height, MaxFileSize)
+	}
+	if m.FloorCount() > MaxFloors {
+		return fmt.Errorf("%d floors, a maze may have at most %d", m.FloorCount(), MaxFloors)
+	}
+	for z := 0; z < m.FloorCount(); z++ {
+		floor := m.Floor(z)
+		if len(floor) != m.Height {
+			return fmt.Errorf("%d rows of cells on floor %d of a maze %d high", len(floor), z, m.Height)
+		}
+		for y, row := range floor {
+			if len(row) != m.Width {
+				return fmt.Errorf("row %d on floor %d has %d cells in a maze %d wide", y, z, len(row), m.Width)
+			}
+		}
+	}
+
+	portals := make(map[Point]int)
+	for _, p := range m.Points() {
+		x, y, c := p.X, p.Y, m.CellAt(p)
+		if x == 0 && !c.Left || y == 0 && !c.Top || x == m.Width-1 && !c.Right || y == m.Height-1 && !c.Bottom {
+			return fmt.Errorf("cell %v is open to the outside", p)
+		}
+		if x+1 < m.Width && c.Right != m.Floor(p.Z)[y][x+1].Left || y+1 < m.Height && c.Bottom != m.Floor(p.Z)[y+1][x].Top {
+			return fmt.Errorf("cell %v and a neighbor disagree about the wall between them", p)
+		}
+		above, below := Point{X: x, Y: y, Z: p.Z + 1}, Point{X: x, Y: y, Z: p.Z - 1}
+		if c.StairsUp && (!m.Contains(above) || !m.CellAt(above).StairsDown) ||
+			c.StairsDown && (!m.Contains(below) || !m.CellAt(below).StairsUp) {
+			return fmt.Errorf("stairs at %v don't meet stairs on the next floor", p)
+		}
+		if !IsTerrain(c.Terrain) {
+			return fmt.Errorf("cell %v has unknown terrain %q", p, c.Terrain)
+		}
+		if c.Terrain == TerrainPortal {
+			portals[p] = 0
+		}
+		switch c.Under {
+		case "":
+		case AxisHorizontal, AxisVertical:
+			if c.Top || c.Right || c.Bottom || c.Left || c.Terrain == TerrainPortal || c.StairsUp || c.StairsDown {
+				return fmt.Errorf("crossing %v must be open on all four sides and neither a portal nor stairs", p)
+			}
+		default:
+			return fmt.Errorf("cell %v has a tunnel along unknown axis %q", p, c.Under)
+		}
+	}
+
+	taken := make(map[Point]string)
+	place := func(p Point, what string) error {
+		if !m.Contains(p) {
+			return fmt.Errorf("%s %v is outside the maze", what, p)
+		}
+		if other, ok := taken[p]; ok {
+			return fmt.Errorf("%s %v is on the same cell as %s", what, p, other)
+		}
+		taken[p] = what
+		return nil
+	}
+	if len(m.Spawns) == 0 {
+		return fmt.Errorf("no spawns")
+	}
+	if len(m.Goals) == 0 || m.Goals[0].Point != m.Goal {
+		return fmt.Errorf("the primary exit must be the first of the exits")
+	}
+	for _, g := range m.Goals {
+		if err := place(g.Point, "exit"); err != nil {
+			return err
+		}
+		if c := m.CellAt(g.Point); c.Terrain != TerrainNormal || c.StairsUp || c.StairsDown {
+			return fmt.Errorf("exit %v must be on plain ground", g.Point)
+		}
+	}
+	for _, s := range m.Spawns {
+		if err := place(s, "spawn"); err != nil {
+			return err
+		}
+		if m.CellAt(s).Terrain == TerrainPortal {
+			return fmt.Errorf("spawn %v can't be a portal", s)
+		}
+	}
+
+	for _, p := range m.Portals {
+		for _, end := range []Point{p.A, p.B} {
+			n, ok := portals[end]
+			if !ok {
+				return fmt.Errorf("portal link from %v to %v isn't between portal cells", p.A, p.B)
+			}
+			if n > 0 || p.A == p.B {
+				return fmt.Errorf("portal %v is linked more than once", end)
+			}
+			portals[end]++
+		}
+	}
+	for p, n := range portals {
+		if n == 0 {
+			return fmt.Errorf("portal %v isn't linked to another", p)
+		}
+	}
+
+	dist := m.GoalDistanceMap()
+	for _, s := range m.Spawns {
+		if dist.At(s) < 0 {
+			return fmt.Errorf("spawn %v can't reach an exit", s)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package game
+
+// Direction offsets used when walking the maze grid
+var directions = []struct {
+	Name   string
+	DX, DY int
+}{
+	{"up", 0, -1},
+	{"right", 1, 0},
+	{"down", 0, 1},
+	{"left", -1, 0},
+}
+
+// Offset returns the grid step for a direction name (up, right, down, left)
+func Offset(direction string) (dx, dy int, ok bool) {
+	for _, d := range directions {
+		if d.Name == direction {
+			return d.DX, d.DY, true
+		}
+	}
+	return 0, 0, false
+}
+
+// Distances are corridor distances to every cell of a maze, indexed
+// [z][y][x]. Unreachable cells are -1.
+type Distances [][][]int
+
+// At returns the distance to p
+func (d Distances) At(p Point) int {
+	return d[p.Z][p.Y][p.X]
+}
+
+// newDistances returns distances for every cell of the maze, all -1
+func (m *Maze) newDistances() Distances {
+	dist := make(Distances, m.FloorCount())
+	for z := range dist {
+		dist[z] = newDistGrid(m.Width, m.Height)
+	}
+	return dist
+}
+
+// DistanceMap runs a BFS from a cell and returns the corridor distance to
+// every cell, stairs included. Crossing cells are walked level by level, so
+// a tunnel never shortcuts onto the bridge above.
+func (m *Maze) DistanceMap(from Point) Distances {
+	type node struct {
+		Point
+		level int
+	}
+
+	// levelDist[level] is the distance to a cell on a specific level
+	var levelDist [2]Distances
+	for level := range levelDist {
+		levelDist[level] = m.newDistances()
+	}
+
+	levelDist[LevelSurface][from.Z][from.Y][from.X] = 0
+	queue := []node{{from, LevelSurface}}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+
+		for _, next := range Moves(cur.Point) {
+			level, ok := m.Step(cur.Point, cur.level, next)
+			if !ok || levelDist[level].At(next) != -1 {
+				continue
+			}
+			levelDist[level][next.Z][next.Y][next.X] = levelDist[cur.level].At(cur.Point) + 1
+			queue = append(queue, node{next, level})
+		}
+	}
+
+	// Collapse levels: a cell's distance is its closest level
+	dist := levelDist[LevelSurface]
+	for _, p := range m.Points() {
+		under, d := levelDist[LevelUnder].At(p), dist.At(p)
+		if under >= 0 && (d < 0 || under < d) {
+			dist[p.Z][p.Y][p.X] = under
+		}
+	}
+	return dist
+}
+
+// NearestDistanceMap returns the corridor distance from every cell to the
+// nearest of the given cells. Unreachable cells are -1.
+func (m *Maze) NearestDistanceMap(from []Point) Distances {
+	nearest := m.newDistances()
+	for _, p := range from {
+		nearest.merge(m.DistanceMap(p))
+	}
+	return nearest
+}
+
+// merge lowers every distance that other has a shorter one for, so d
+// ends up holding the distance to the nearest of their sources
+func (d Distances) merge(other Distances) {
+	for z := range d {
+		for y := range d[z] {
+			for x, o := range other[z][y] {
+				if o >= 0 && (d[z][y][x] < 0 || o < d[z][y][x]) {
+					d[z][y][x] = o
+				}
+			}
+		}
+	}
+}
+
+// newDistGrid returns a height x width grid filled with -1
+func newDistGrid(width, height int) [][]int {
+	dist := make([][]int, height)
+	for row := range dist {
+		dist[row] = make([]int, width)
+		for col := range dist[row] {
+			dist[row][col] = -1
+		}
+	}
+	return dist
+}
+
+// ShortestSpawnDistance returns the shortest corridor distance from any
+// spawn to its nearest exit, or -1 if some spawn cannot reach one
+func (m *Maze) ShortestSpawnDistance() int {
+	dist := m.GoalDistanceMap()
+
+	shortest := -1
+	for _, s := range m.Spawns {
+		d := dist.At(s)
+		if d < 0 {
+			return -1
+		}
+		if shortest == -1 || d < shortest {
+			shortest = d
+		}
+	}
+	return shortest
+}
+
+// DirectionToward returns the direction of the open neighbor of p that is
+// one step closer to the source of dist, upstairs or downstairs if the way
+// is by the stairs, or "" if there is none
+func (m *Maze) DirectionToward(dist Distances, p Point) string {
+	here := dist.At(p)
+	for _, d := range directions {
+		n := Point{X: p.X + d.DX, Y: p.Y + d.DY, Z: p.Z}
+		if !m.CanMove(p.X, p.Y, p.Z, n.X, n.Y, n.Z) {
+			continue
+		}
+		if dist.At(n) >= 0 && dist.At(n) < here {
+			return d.Name
+		}
+	}
+	for _, name := range []string{DirectionUpstairs, DirectionDownstairs} {
+		dz, _ := Climb(name)
+		n := Point{X: p.X, Y: p.Y, Z: p.Z + dz}
+		if m.CanMove(p.X, p.Y, p.Z, n.X, n.Y, n.Z) && dist.At(n) >= 0 && dist.At(n) < here {
+			return name
+		}
+	}
+	return ""
+}
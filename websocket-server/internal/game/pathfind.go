@@ -0,0 +1,114 @@
+package game
+
+import "container/heap"
+
+// ShortestPath returns a shortest corridor path between two cells, both
+// included, starting on the surface level. It is nil if to can't be reached.
+func (m *Maze) ShortestPath(from, to Point) []Point {
+	return m.PathFrom(from, LevelSurface, []Point{to})
+}
+
+// Distance returns the corridor distance between two cells, or -1 if there
+// is no path
+func (m *Maze) Distance(from, to Point) int {
+	return len(m.ShortestPath(from, to)) - 1
+}
+
+// PathToGoal returns a shortest path from a player's cell and level to the
+// nearest exit, or nil if none can be reached
+func (m *Maze) PathToGoal(from Point, level int) []Point {
+	goals := make([]Point, len(m.Goals))
+	for i, g := range m.Goals {
+		goals[i] = g.Point
+	}
+	return m.PathFrom(from, level, goals)
+}
+
+// PathFrom runs A* from a cell, on the given level, to whichever target is
+// nearest. The heuristic is the Manhattan distance to the closest target,
+// counting each floor between as a step, which never overestimates, so the
+// path found is a shortest one. Crossings are walked level by level as in
+// DistanceMap. The path includes both ends and is nil if no target can be
+// reached.
+func (m *Maze) PathFrom(from Point, level int, targets []Point) []Point {
+	if len(targets) == 0 || !m.Contains(from) {
+		return nil
+	}
+	isTarget := make(map[Point]bool, len(targets))
+	for _, t := range targets {
+		isTarget[t] = true
+	}
+	estimate := func(p Point) int {
+		best := -1
+		for _, t := range targets {
+			if d := abs(p.X-t.X) + abs(p.Y-t.Y) + abs(p.Z-t.Z); best < 0 || d < best {
+				best = d
+			}
+		}
+		return best
+	}
+
+	start := pathNode{from, level}
+	cost := map[pathNode]int{start: 0}
+	prev := map[pathNode]pathNode{}
+	open := &pathQueue{{node: start, f: estimate(from)}}
+	for open.Len() > 0 {
+		cur := heap.Pop(open).(pathEntry)
+		if cur.f-estimate(cur.node.Point) > cost[cur.node] {
+			continue // Stale entry, a cheaper route was found since
+		}
+		if isTarget[cur.node.Point] {
+			path := []Point{cur.node.Point}
+			for at := cur.node; at != start; {
+				at = prev[at]
+				path = append(path, at.Point)
+			}
+			for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+				path[i], path[j] = path[j], path[i]
+			}
+			return path
+		}
+
+		for _, to := range Moves(cur.node.Point) {
+			level, ok := m.Step(cur.node.Point, cur.node.Level, to)
+			if !ok {
+				continue
+			}
+			next := pathNode{to, level}
+			g := cost[cur.node] + 1
+			if old, seen := cost[next]; seen && old <= g {
+				continue
+			}
+			cost[next] = g
+			prev[next] = cur.node
+			heap.Push(open, pathEntry{node: next, f: g + estimate(to)})
+		}
+	}
+	return nil
+}
+
+// pathNode is a place a player can be: a cell on a floor and, in
+// crossings, a level
+type pathNode struct {
+	Point
+	Level int
+}
+
+type pathEntry struct {
+	node pathNode
+	f    int // Cost so far plus the estimate still to go
+}
+
+// pathQueue is a min-heap of A* entries by f
+type pathQueue []pathEntry
+
+func (q pathQueue) Len() int            { return len(q) }
+func (q pathQueue) Less(i, j int) bool  { return q[i].f < q[j].f }
+func (q pathQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *pathQueue) Push(x interface{}) { *q = append(*q, x.(pathEntry)) }
+func (q *pathQueue) Pop() interface{} {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return e
+}
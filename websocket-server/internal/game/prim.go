@@ -0,0 +1,42 @@
+package game
+
+import "math/rand"
+
+// Prim grows the maze outward from a random cell by repeatedly opening a
+// random wall on the frontier. Produces many short dead ends and a
+// "bushy" layout that is easy to wander but hard to read at a glance.
+type Prim struct{}
+
+// Generate implements MazeGenerator
+func (Prim) Generate(m *Maze, rng *rand.Rand) {
+	inMaze := make([][]bool, m.Height)
+	for y := range inMaze {
+		inMaze[y] = make([]bool, m.Width)
+	}
+
+	var frontier []edge
+	addFrontier := func(x, y int) {
+		inMaze[y][x] = true
+		for _, d := range directions {
+			nx, ny := x+d.DX, y+d.DY
+			if m.InBounds(nx, ny) && !inMaze[ny][nx] {
+				frontier = append(frontier, edge{x, y, nx, ny})
+			}
+		}
+	}
+
+	addFrontier(rng.Intn(m.Width), rng.Intn(m.Height))
+
+	for len(frontier) > 0 {
+		i := rng.Intn(len(frontier))
+		e := frontier[i]
+		frontier[i] = frontier[len(frontier)-1]
+		frontier = frontier[:len(frontier)-1]
+
+		if inMaze[e.y2][e.x2] {
+			continue
+		}
+		m.removeWall(e.x1, e.y1, e.x2, e.y2)
+		addFrontier(e.x2, e.y2)
+	}
+}
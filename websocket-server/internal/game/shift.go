@@ -0,0 +1,46 @@
+package game
+
+// RotateSection turns the walls inside the size x size square with its top
+// left corner at p a quarter turn clockwise, leaving the walls around the
+// square, and any stairs in it, as they are. Returns the cells of the
+// square, or false if it doesn't fit in the maze or holds a crossing, whose
+// tunnel can't turn.
+func (m *Maze) RotateSection(p Point, size int) ([]Point, bool) {
+	x, y := p.X, p.Y
+	if size < 2 || !m.Contains(p) || !m.InBounds(x+size-1, y+size-1) {
+		return nil, false
+	}
+	floor := m.floorView(p.Z)
+	var cells []Point
+	for j := y; j < y+size; j++ {
+		for i := x; i < x+size; i++ {
+			if floor.Cells[j][i].Under != "" {
+				return nil, false
+			}
+			cells = append(cells, Point{X: i, Y: j, Z: p.Z})
+		}
+	}
+
+	// Read every inner wall before writing any: a wall between two cells
+	// ends up between the cells they turn into
+	type edge struct {
+		a, b Point
+		wall bool
+	}
+	var edges []edge
+	for _, a := range cells {
+		for _, b := range []Point{{X: a.X + 1, Y: a.Y, Z: a.Z}, {X: a.X, Y: a.Y + 1, Z: a.Z}} {
+			if b.X < x+size && b.Y < y+size {
+				edges = append(edges, edge{a, b, !m.CanMove(a.X, a.Y, a.Z, b.X, b.Y, b.Z)})
+			}
+		}
+	}
+	turn := func(p Point) Point {
+		return Point{X: x + size - 1 - (p.Y - y), Y: y + (p.X - x)}
+	}
+	for _, e := range edges {
+		a, b := turn(e.a), turn(e.b)
+		floor.setWall(a.X, a.Y, b.X, b.Y, e.wall)
+	}
+	return cells, true
+}
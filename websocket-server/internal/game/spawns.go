@@ -0,0 +1,59 @@
+package game
+
+// DefaultSpawnCount is how many spawn points a maze gets unless
+// Options.SpawnCount says otherwise
+const DefaultSpawnCount = 4
+
+// placeSpawns picks count starting cells on the ground floor: the top-left
+// corner, then the other corners except the exit's, then whichever cells
+// are farthest by corridor distance from every spawn so far. Tiny mazes may
+// end up with fewer spawns than asked for.
+func (m *Maze) placeSpawns(count int) {
+	m.Spawns = []Point{{X: 0, Y: 0}}
+
+	corners := []Point{{X: m.Width - 1, Y: 0}, {X: 0, Y: m.Height - 1}, {X: m.Width - 1, Y: m.Height - 1}}
+	for _, c := range corners {
+		if len(m.Spawns) >= count {
+			return
+		}
+		if c != m.Goal && !m.IsSpawn(c) {
+			m.Spawns = append(m.Spawns, c)
+		}
+	}
+
+	// Distance from each cell to its nearest spawn, updated as spawns are added
+	nearest := m.newDistances()
+	addSpawn := func(s Point) {
+		nearest.merge(m.DistanceMap(s))
+	}
+	for _, s := range m.Spawns {
+		addSpawn(s)
+	}
+
+	for len(m.Spawns) < count {
+		best, bestDist := Point{}, 0
+		for y := range nearest[0] {
+			for x, d := range nearest[0][y] {
+				p := Point{X: x, Y: y}
+				if d > bestDist && p != m.Goal {
+					best, bestDist = p, d
+				}
+			}
+		}
+		if bestDist == 0 {
+			return // Every cell is a spawn or the exit
+		}
+		m.Spawns = append(m.Spawns, best)
+		addSpawn(best)
+	}
+}
+
+// IsSpawn reports whether p is one of the maze's spawn points
+func (m *Maze) IsSpawn(p Point) bool {
+	for _, s := range m.Spawns {
+		if s == p {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package game
+
+import "math/rand"
+
+// Terrain is the ground type of a cell
+type Terrain string
+
+const (
+	TerrainNormal Terrain = ""
+	TerrainMud    Terrain = "mud"    // Slows the next move
+	TerrainRoad   Terrain = "road"   // Speeds up the next move
+	TerrainIce    Terrain = "ice"    // Whoever steps on slides on the same way until off the ice or stopped
+	TerrainPortal Terrain = "portal" // Whoever steps on comes out of the linked portal, see Maze.Portals
+)
+
+// terrainCosts scale the move cooldown after stepping onto a cell
+var terrainCosts = map[Terrain]float64{
+	TerrainNormal: 1,
+	TerrainMud:    2,
+	TerrainRoad:   0.5,
+	TerrainIce:    1,
+	TerrainPortal: 1,
+}
+
+// Portal links two portal cells both ways
+type Portal struct {
+	A Point `json:"a"`
+	B Point `json:"b"`
+}
+
+// PortalExit returns where stepping onto the portal at p comes out
+func (m *Maze) PortalExit(p Point) (Point, bool) {
+	for _, portal := range m.Portals {
+		switch p {
+		case portal.A:
+			return portal.B, true
+		case portal.B:
+			return portal.A, true
+		}
+	}
+	return Point{}, false
+}
+
+// IsTerrain reports whether t is a known terrain type
+func IsTerrain(t Terrain) bool {
+	_, ok := terrainCosts[t]
+	return ok
+}
+
+// MoveCost returns the cooldown multiplier for leaving cell p
+func (m *Maze) MoveCost(p Point) float64 {
+	if cost, ok := terrainCosts[m.CellAt(p).Terrain]; ok {
+		return cost
+	}
+	return 1
+}
+
+// placeTerrain scatters the theme's terrain over roughly density of the
+// cells on every floor, each drawn by its Theme.Terrain weight. Portals are
+// then linked in random pairs, across floors too; an odd one out, or one on
+// a spawn, crossing or stairs, is left normal. Exits are always left normal.
+func (m *Maze) placeTerrain(density float64, rng *rand.Rand) {
+	if density <= 0 {
+		return
+	}
+	theme, ok := ThemeByName(m.Theme)
+	if !ok {
+		theme = themes[ThemeHedge]
+	}
+	total := 0
+	for _, w := range theme.Terrain {
+		total += w.Weight
+	}
+
+	var portals []Point
+	for _, p := range m.Points() {
+		if m.IsGoal(p) {
+			continue
+		}
+		if rng.Float64() >= density {
+			continue
+		}
+		c := m.CellAt(p)
+		pick := rng.Intn(total)
+		for _, w := range theme.Terrain {
+			if pick < w.Weight {
+				c.Terrain = w.Terrain
+				break
+			}
+			pick -= w.Weight
+		}
+		if c.Terrain != TerrainPortal {
+			continue
+		}
+		if m.IsSpawn(p) || c.Under != "" || c.StairsUp || c.StairsDown {
+			c.Terrain = TerrainNormal
+			continue
+		}
+		portals = append(portals, p)
+	}
+
+	rng.Shuffle(len(portals), func(i, j int) { portals[i], portals[j] = portals[j], portals[i] })
+	if len(portals)%2 == 1 {
+		odd := portals[len(portals)-1]
+		m.CellAt(odd).Terrain = TerrainNormal
+		portals = portals[:len(portals)-1]
+	}
+	for i := 0; i < len(portals); i += 2 {
+		m.Portals = append(m.Portals, Portal{A: portals[i], B: portals[i+1]})
+	}
+}
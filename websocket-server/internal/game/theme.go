@@ -0,0 +1,67 @@
+package game
+
+import "time"
+
+// Theme names
+const (
+	ThemeHedge = "hedge"
+	ThemeIce   = "ice"
+	ThemeLava  = "lava"
+)
+
+// Theme is a cosmetic maze style. Besides telling clients which tileset to
+// render, it weights which items, hazards and terrain get spawned into the
+// maze.
+type Theme struct {
+	Name        string
+	ItemWeights map[string]int  // Relative spawn chance per item kind
+	Hazards     []string        // Hazard kinds that fit the theme
+	Terrain     []TerrainWeight // Relative chance per terrain type of a cell Options.TerrainDensity covers
+}
+
+// TerrainWeight is how likely a theme's covered cells are to be of a
+// terrain type
+type TerrainWeight struct {
+	Terrain Terrain
+	Weight  int
+}
+
+var themes = map[string]Theme{
+	ThemeHedge: {
+		Name:        ThemeHedge,
+		ItemWeights: map[string]int{"speedBoost": 3, "wallBreak": 3, "teleport": 2, "freeze": 2},
+		Hazards:     []string{"mole"},
+		Terrain:     []TerrainWeight{{TerrainMud, 1}, {TerrainRoad, 1}},
+	},
+	ThemeIce: {
+		Name:        ThemeIce,
+		ItemWeights: map[string]int{"speedBoost": 2, "wallBreak": 2, "teleport": 2, "freeze": 4},
+		Hazards:     []string{"iceSlide"},
+		Terrain:     []TerrainWeight{{TerrainIce, 6}, {TerrainRoad, 2}, {TerrainPortal, 1}},
+	},
+	ThemeLava: {
+		Name:        ThemeLava,
+		ItemWeights: map[string]int{"speedBoost": 4, "wallBreak": 3, "teleport": 3, "freeze": 0},
+		Hazards:     []string{"lavaPool"},
+		Terrain:     []TerrainWeight{{TerrainMud, 3}, {TerrainRoad, 2}, {TerrainPortal, 1}},
+	},
+}
+
+// ThemeByName looks up a theme
+func ThemeByName(name string) (Theme, bool) {
+	t, ok := themes[name]
+	return t, ok
+}
+
+// SeasonalTheme picks the theme for the seasonal event running at t:
+// ice in winter, lava in summer, hedge the rest of the year
+func SeasonalTheme(t time.Time) string {
+	switch t.Month() {
+	case time.December, time.January, time.February:
+		return ThemeIce
+	case time.June, time.July, time.August:
+		return ThemeLava
+	default:
+		return ThemeHedge
+	}
+}
@@ -0,0 +1,74 @@
+package game
+
+import "math/rand"
+
+// Weave is a recursive backtracker that may carry a corridor under a
+// straight passage it has already carved, instead of stopping at it. The
+// crossed cell becomes a crossing, so corridors weave over and under each
+// other while the maze stays perfect. Like every MazeGenerator, it carves
+// m.Cells only.
+type Weave struct{}
+
+// Generate implements MazeGenerator
+func (Weave) Generate(m *Maze, rng *rand.Rand) {
+	type move struct {
+		x, y  int
+		under bool // Tunnels under the cell between, rather than stepping into it
+	}
+
+	stack := []struct{ x, y int }{{0, 0}}
+	m.Cells[0][0].Visited = true
+
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+
+		var moves []move
+		for _, d := range directions {
+			nx, ny := current.x+d.DX, current.y+d.DY
+			if !m.InBounds(nx, ny) {
+				continue
+			}
+			if !m.Cells[ny][nx].Visited {
+				moves = append(moves, move{x: nx, y: ny})
+				continue
+			}
+			bx, by := nx+d.DX, ny+d.DY
+			if m.InBounds(bx, by) && !m.Cells[by][bx].Visited && m.crossable(nx, ny, axisOf(d.DX, d.DY)) {
+				moves = append(moves, move{x: bx, y: by, under: true})
+			}
+		}
+
+		if len(moves) == 0 {
+			stack = stack[:len(stack)-1] // Pop
+			continue
+		}
+
+		next := moves[rng.Intn(len(moves))]
+		if next.under {
+			// Open both sides of the cell between along the tunnel's axis;
+			// its own corridor runs across, so the two never join
+			mx, my := (current.x+next.x)/2, (current.y+next.y)/2
+			m.Cells[my][mx].Under = axisOf(next.x-current.x, next.y-current.y)
+			m.removeWall(current.x, current.y, mx, my)
+			m.removeWall(mx, my, next.x, next.y)
+		} else {
+			m.removeWall(current.x, current.y, next.x, next.y)
+		}
+		m.Cells[next.y][next.x].Visited = true
+		stack = append(stack, struct{ x, y int }{next.x, next.y})
+	}
+}
+
+// crossable reports whether a tunnel along axis could pass under the cell
+// at (x, y): it must be a straight corridor running across that axis, and
+// not a crossing already
+func (m *Maze) crossable(x, y int, axis string) bool {
+	c := m.Cells[y][x]
+	if c.Under != "" {
+		return false
+	}
+	if axis == AxisHorizontal {
+		return !c.Top && !c.Bottom && c.Left && c.Right
+	}
+	return !c.Left && !c.Right && c.Top && c.Bottom
+}
@@ -0,0 +1,54 @@
+package game
+
+import "testing"
+
+// TestWeavePerfect checks a weave maze is still a perfect maze once each
+// crossing counts as two places, the bridge and the tunnel beneath it
+func TestWeavePerfect(t *testing.T) {
+	for _, seed := range []int64{1, 5, 42} {
+		m := Generate(12, 12, Options{Seed: seed, Algorithm: AlgorithmWeave})
+		crossings, openWalls := 0, 0
+		for _, p := range m.Points() {
+			c := m.CellAt(p)
+			if c.Under != "" {
+				crossings++
+			}
+			if !c.Right {
+				openWalls++
+			}
+			if !c.Bottom {
+				openWalls++
+			}
+		}
+		if crossings == 0 {
+			t.Fatalf("seed %d: weave maze has no crossings", seed)
+		}
+
+		type place struct {
+			at    Point
+			level int
+		}
+		start := place{at: Point{}}
+		seen := map[place]bool{start: true}
+		queue := []place{start}
+		for len(queue) > 0 {
+			cur := queue[0]
+			queue = queue[1:]
+			for _, to := range Moves(cur.at) {
+				if !m.Contains(to) {
+					continue
+				}
+				level, ok := m.Step(cur.at, cur.level, to)
+				if next := (place{to, level}); ok && !seen[next] {
+					seen[next] = true
+					queue = append(queue, next)
+				}
+			}
+		}
+		places := m.Width*m.Height + crossings
+		if len(seen) != places || openWalls != places-1 {
+			t.Errorf("seed %d: reaches %d of %d places through %d passages, want a tree of %d",
+				seed, len(seen), places, openWalls, places-1)
+		}
+	}
+}
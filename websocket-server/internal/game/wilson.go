@@ -0,0 +1,56 @@
+package game
+
+import "math/rand"
+
+// Wilson builds the maze from loop-erased random walks, giving a
+// uniformly random spanning tree. Mazes have no directional bias, which
+// makes them the fairest choice for competitive play.
+type Wilson struct{}
+
+// Generate implements MazeGenerator
+func (Wilson) Generate(m *Maze, rng *rand.Rand) {
+	inMaze := make([][]bool, m.Height)
+	for y := range inMaze {
+		inMaze[y] = make([]bool, m.Width)
+	}
+	inMaze[rng.Intn(m.Height)][rng.Intn(m.Width)] = true
+
+	// next[y][x] is the direction the current walk last left (x, y) by.
+	// Overwriting it when the walk revisits a cell erases the loop.
+	next := make([][]int, m.Height)
+	for y := range next {
+		next[y] = make([]int, m.Width)
+	}
+
+	for y := 0; y < m.Height; y++ {
+		for x := 0; x < m.Width; x++ {
+			if inMaze[y][x] {
+				continue
+			}
+
+			// Random walk until we hit the maze
+			cx, cy := x, y
+			for !inMaze[cy][cx] {
+				for {
+					d := rng.Intn(len(directions))
+					nx, ny := cx+directions[d].DX, cy+directions[d].DY
+					if m.InBounds(nx, ny) {
+						next[cy][cx] = d
+						cx, cy = nx, ny
+						break
+					}
+				}
+			}
+
+			// Carve the loop-erased path into the maze
+			cx, cy = x, y
+			for !inMaze[cy][cx] {
+				d := directions[next[cy][cx]]
+				nx, ny := cx+d.DX, cy+d.DY
+				m.removeWall(cx, cy, nx, ny)
+				inMaze[cy][cx] = true
+				cx, cy = nx, ny
+			}
+		}
+	}
+}
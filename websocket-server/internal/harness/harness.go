@@ -0,0 +1,308 @@
+// Package harness runs full multi-client game scenarios against a real
+// server over in-memory connections, for Go tests. The server tells time by
+// a manual clock, so scenarios advance it instead of sleeping.
+package harness
+
+import (
+	"fmt"
+	"time"
+
+	"labyrinth-duel/websocket/internal/clock"
+	"labyrinth-duel/websocket/internal/config"
+	"labyrinth-duel/websocket/internal/memconn"
+	"labyrinth-duel/websocket/internal/messages"
+	"labyrinth-duel/websocket/internal/rating"
+	"labyrinth-duel/websocket/internal/room"
+	"labyrinth-duel/websocket/internal/server"
+)
+
+// DefaultTimeout is how long Expect waits when no timeout is given. It is
+// real time, for the server to get to a message, not game time.
+const DefaultTimeout = 5 * time.Second
+
+// Harness owns a server that clients connect to in memory
+type Harness struct {
+	Server *server.Server
+	// Clock is the server's time. It only moves when advanced.
+	Clock *clock.Manual
+}
+
+// New starts a fresh server with the default settings and in-memory ratings
+func New() *Harness {
+	return NewWith(config.Default())
+}
+
+// NewWith starts a fresh server with the given settings and in-memory
+// ratings
+func NewWith(cfg config.Config) *Harness {
+	return Start(server.New(cfg, rating.NewMemoryStore()))
+}
+
+// Start puts srv on a manual clock and runs it. Anything else about it,
+// such as its stores, must be set up before.
+func Start(srv *server.Server) *Harness {
+	return start(srv, clock.NewManual(time.Now()))
+}
+
+// Sibling starts another fresh server with the default settings on the
+// same clock, for scenarios that span servers
+func (h *Harness) Sibling() *Harness {
+	return start(server.New(config.Default(), rating.NewMemoryStore()), h.Clock)
+}
+
+func start(srv *server.Server, c *clock.Manual) *Harness {
+	srv.UseClock(c)
+	srv.Run()
+	return &Harness{Server: srv, Clock: c}
+}
+
+// Close stops the server's background loops
+func (h *Harness) Close() {
+	h.Server.Stop()
+}
+
+// Client is one simulated player
+type Client struct {
+	ID     string
+	Secret string // Resume secret the server issued with ID
+	conn   *memconn.ClientConn
+	clock  *clock.Manual
+
+	// Maze is the last mazeData/newRound maze received, if any
+	Maze *messages.MazeData
+	// Log is every message received so far, in order
+	Log []messages.ServerMessage
+
+	seq     uint64                   // Seq of the last message sent
+	acked   uint64                   // Highest seq the server has acked
+	pending []messages.ServerMessage // Read by Sync, not yet returned by Next
+}
+
+// Connect opens a client connection speaking the current protocol,
+// optionally asking for a player ID without its secret, and waits for the
+// server's greeting
+func (h *Harness) Connect(playerID string) (*Client, error) {
+	return h.ConnectWith(server.Handshake{PlayerID: playerID, Protocol: messages.ProtocolVersion})
+}
+
+// Resume connects again as an earlier client's player, with the secret it
+// was issued
+func (h *Harness) Resume(c *Client) (*Client, error) {
+	return h.ConnectWith(server.Handshake{PlayerID: c.ID, Secret: c.Secret, Protocol: messages.ProtocolVersion})
+}
+
+// ConnectWith opens a client connection with a full handshake, such as one
+// carrying verified claims, and waits for the server's greeting
+func (h *Harness) ConnectWith(hs server.Handshake) (*Client, error) {
+	serverEnd, clientEnd := memconn.Pipe()
+	go h.Server.Serve(serverEnd, hs)
+
+	c := &Client{conn: clientEnd, clock: h.Clock}
+	hello, err := c.Expect("connected", 0)
+	if err != nil {
+		return nil, err
+	}
+	c.ID, c.Secret = hello.Message, hello.Secret
+	return c, nil
+}
+
+// Send sends a message to the server, numbering it so Sync can tell when
+// it has been handled
+func (c *Client) Send(msg messages.ClientMessage) error {
+	c.seq++
+	msg.Seq = c.seq
+	return c.conn.Send(msg)
+}
+
+// Sync waits until the server has handled everything the client sent, so
+// the clock can be advanced without running ahead of it. Messages that
+// arrive meanwhile are kept for Next and Expect.
+func (c *Client) Sync() error {
+	deadline := time.Now().Add(DefaultTimeout)
+	for c.acked < c.seq {
+		msg, err := c.read(time.Until(deadline))
+		if err != nil {
+			return fmt.Errorf("client %s waiting for ack %d: %w", c.ID, c.seq, err)
+		}
+		c.pending = append(c.pending, msg)
+	}
+	return nil
+}
+
+// Next returns the next message, whatever its type
+func (c *Client) Next(timeout time.Duration) (messages.ServerMessage, error) {
+	if len(c.pending) > 0 {
+		msg := c.pending[0]
+		c.pending = c.pending[1:]
+		return msg, nil
+	}
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return c.read(timeout)
+}
+
+// read takes the next message off the connection and keeps the client's
+// view of the maze up to date with it
+func (c *Client) read(timeout time.Duration) (messages.ServerMessage, error) {
+	msg, err := c.conn.Next(timeout)
+	if err != nil {
+		return msg, err
+	}
+	c.Log = append(c.Log, msg)
+	c.acked = max(c.acked, msg.Ack)
+	if msg.Maze != nil && (msg.Type == "mazeData" || msg.Type == "newRound") {
+		c.Maze = msg.Maze
+	}
+	if msg.Type == "goalMoved" && c.Maze != nil {
+		c.Maze.Goal, c.Maze.Goals = *msg.Position, msg.Goals
+	}
+	if msg.Type == "mazeUpdated" && c.Maze != nil && c.Maze.Cells != nil {
+		for _, cell := range msg.Cells {
+			floor := c.Maze.Cells
+			if cell.Z > 0 {
+				floor = c.Maze.Upper[cell.Z-1]
+			}
+			floor[cell.Y][cell.X] = cell
+		}
+	}
+	return msg, nil
+}
+
+// Expect skips messages until one of the given type arrives. Batched
+// messages are searched too.
+func (c *Client) Expect(msgType string, timeout time.Duration) (messages.ServerMessage, error) {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	deadline := time.Now().Add(timeout)
+
+	for {
+		msg, err := c.Next(time.Until(deadline))
+		if err != nil {
+			return msg, fmt.Errorf("client %s waiting for %q: %w", c.ID, msgType, err)
+		}
+		if found, ok := find(msg, msgType); ok {
+			return found, nil
+		}
+	}
+}
+
+// Await skips messages until one of the given type arrives, advancing the
+// clock a room tick at a time for up to within while none is waiting. Sync
+// what the client sent first, or the clock may run ahead of it.
+func (c *Client) Await(msgType string, within time.Duration) (messages.ServerMessage, error) {
+	for waited := time.Duration(0); ; {
+		// Give the server a moment to act on the last tick before the next
+		msg, err := c.Next(time.Millisecond)
+		if err == nil {
+			if found, ok := find(msg, msgType); ok {
+				return found, nil
+			}
+			continue
+		}
+		if waited >= within {
+			// The clock has moved far enough; give the server real time to
+			// catch up with it
+			return c.Expect(msgType, 0)
+		}
+		step := min(room.DefaultTickInterval, within-waited)
+		c.clock.Advance(step)
+		waited += step
+	}
+}
+
+// find returns the message of the given type among msg and its batch
+func find(msg messages.ServerMessage, msgType string) (messages.ServerMessage, bool) {
+	if msg.Type == msgType {
+		return msg, true
+	}
+	for _, inner := range msg.Batch {
+		if inner.Type == msgType {
+			return inner, true
+		}
+	}
+	return messages.ServerMessage{}, false
+}
+
+// Walk moves the client's player through path one cell at a time, each
+// after the interval on the clock and handled before the next
+func (c *Client) Walk(path []messages.Position, interval time.Duration) error {
+	for _, p := range path {
+		c.clock.Advance(interval)
+		c.Send(messages.ClientMessage{Type: "move", X: p.X, Y: p.Y, Z: p.Z})
+		if err := c.Sync(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Disconnect drops the connection
+func (c *Client) Disconnect() {
+	c.conn.Close()
+}
+
+// PathToGoal finds the shortest route from (x, y) on the ground floor to the
+// primary exit of the client's maze, as the list of cells to move through.
+// Stairs are climbed; tunnels under crossings are ignored.
+func (c *Client) PathToGoal(x, y int) ([]messages.Position, error) {
+	if c.Maze == nil {
+		return nil, fmt.Errorf("client %s has no visible maze", c.ID)
+	}
+	return c.PathTo(x, y, c.Maze.Goal)
+}
+
+// PathTo finds the shortest route from (x, y) on the ground floor to a cell
+// of the client's maze, like PathToGoal
+func (c *Client) PathTo(x, y int, to messages.Position) ([]messages.Position, error) {
+	m := c.Maze
+	if m == nil || m.Cells == nil {
+		return nil, fmt.Errorf("client %s has no visible maze", c.ID)
+	}
+
+	type step struct{ x, y, z int }
+	start := step{x, y, 0}
+	prev := map[step]step{start: start}
+	queue := []step{start}
+	goal := step{to.X, to.Y, to.Z}
+
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		if cur == goal {
+			break
+		}
+
+		floor := m.Cells
+		if cur.z > 0 {
+			floor = m.Upper[cur.z-1]
+		}
+		cell := floor[cur.y][cur.x]
+		for _, n := range []struct {
+			open bool
+			next step
+		}{
+			{!cell.Top, step{cur.x, cur.y - 1, cur.z}},
+			{!cell.Right, step{cur.x + 1, cur.y, cur.z}},
+			{!cell.Bottom, step{cur.x, cur.y + 1, cur.z}},
+			{!cell.Left, step{cur.x - 1, cur.y, cur.z}},
+			{cell.StairsUp, step{cur.x, cur.y, cur.z + 1}},
+			{cell.StairsDown, step{cur.x, cur.y, cur.z - 1}},
+		} {
+			if _, seen := prev[n.next]; n.open && !seen {
+				prev[n.next] = cur
+				queue = append(queue, n.next)
+			}
+		}
+	}
+
+	if _, ok := prev[goal]; !ok {
+		return nil, fmt.Errorf("no path from (%d, %d) to (%d, %d, %d)", x, y, to.X, to.Y, to.Z)
+	}
+	var path []messages.Position
+	for at := goal; at != start; at = prev[at] {
+		path = append([]messages.Position{{X: at.x, Y: at.y, Z: at.z}}, path...)
+	}
+	return path, nil
+}
@@ -0,0 +1,177 @@
+// Package history keeps every player's finished matches
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"labyrinth-duel/websocket/internal/datafile"
+)
+
+const (
+	// DefaultPageSize is how many matches a page has unless asked otherwise
+	DefaultPageSize = 10
+	// MaxPageSize caps the matches asked for in one page
+	MaxPageSize = 50
+	// DefaultPerPlayer is how many of a player's most recent matches a
+	// store keeps
+	DefaultPerPlayer = 100
+)
+
+// Participant is how one player did in a match
+type Participant struct {
+	ID    string
+	Name  string
+	Team  int
+	Score int
+	Moves int
+	Won   bool
+	Bot   bool
+}
+
+// Match is a finished match
+type Match struct {
+	ReplayID  string // Its replay, if it was kept
+	RoomID    string
+	Mode      string // Game mode, "" for the classic race
+	Daily     string // Day of the daily challenge, if it was one
+	Seed      int64  // Seed of the first round's maze
+	StartedAt time.Time
+	Duration  time.Duration
+	Winner    string
+	Reason    string
+	Players   []Participant
+}
+
+// Store keeps matches. Implementations must be safe for concurrent use.
+type Store interface {
+	// Save records a finished match in the history of everyone in it
+	Save(m *Match) error
+	// ForPlayer returns up to limit of a player's matches from offset on,
+	// newest first, and how many they have in all
+	ForPlayer(playerID string, offset, limit int) ([]*Match, int, error)
+}
+
+// MemoryStore keeps the most recent matches of every player in memory;
+// they are lost on restart
+type MemoryStore struct {
+	perPlayer int
+	players   map[string][]*Match // Oldest first
+	mu        sync.RWMutex
+}
+
+// NewMemoryStore creates an empty in-memory store keeping up to perPlayer
+// matches of each player (DefaultPerPlayer if perPlayer is 0 or less)
+func NewMemoryStore(perPlayer int) *MemoryStore {
+	if perPlayer <= 0 {
+		perPlayer = DefaultPerPlayer
+	}
+	return &MemoryStore{perPlayer: perPlayer, players: make(map[string][]*Match)}
+}
+
+// Save implements Store, dropping a player's oldest match once they have
+// too many
+func (s *MemoryStore) Save(m *Match) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.saveLocked(m)
+	return nil
+}
+
+func (s *MemoryStore) saveLocked(m *Match) {
+	for _, p := range m.Players {
+		if p.Bot {
+			continue
+		}
+		matches := append(s.players[p.ID], m)
+		if len(matches) > s.perPlayer {
+			matches = matches[len(matches)-s.perPlayer:]
+		}
+		s.players[p.ID] = matches
+	}
+}
+
+// ForPlayer implements Store
+func (s *MemoryStore) ForPlayer(playerID string, offset, limit int) ([]*Match, int, error) {
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	limit = min(limit, MaxPageSize)
+	offset = max(offset, 0)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	all := s.players[playerID]
+	var page []*Match
+	for i := len(all) - 1 - offset; i >= 0 && len(page) < limit; i-- {
+		page = append(page, all[i])
+	}
+	return page, len(all), nil
+}
+
+// FileStore keeps matches in memory like a MemoryStore, and appends each
+// one to a JSON lines file so they survive a restart
+type FileStore struct {
+	path string
+	mem  *MemoryStore
+}
+
+// NewFileStore loads the matches in path, which need not exist yet,
+// keeping up to perPlayer of each player's (see NewMemoryStore)
+func NewFileStore(path string, perPlayer int) (*FileStore, error) {
+	s := &FileStore{path: path, mem: NewMemoryStore(perPlayer)}
+	if err := datafile.MkdirAll(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var m Match
+		if err := json.Unmarshal(scanner.Bytes(), &m); err != nil {
+			return nil, err
+		}
+		s.mem.saveLocked(&m)
+	}
+	return s, scanner.Err()
+}
+
+// Save implements Store
+func (s *FileStore) Save(m *Match) error {
+	s.mem.mu.Lock()
+	defer s.mem.mu.Unlock()
+
+	s.mem.saveLocked(m)
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, datafile.FileMode)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ForPlayer implements Store
+func (s *FileStore) ForPlayer(playerID string, offset, limit int) ([]*Match, int, error) {
+	return s.mem.ForPlayer(playerID, offset, limit)
+}
@@ -0,0 +1,208 @@
+// Package leaderboard ranks players by wins, fastest solve and rating,
+// overall and in each game mode
+package leaderboard
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"labyrinth-duel/websocket/internal/rating"
+)
+
+// Boards players can be ranked on
+const (
+	BoardWins    = "wins"    // Most matches won
+	BoardFastest = "fastest" // Quickest match won by reaching the exit
+	BoardRating  = "rating"  // Highest Elo rating
+)
+
+// Modes besides the registered game modes
+const (
+	ModeAll     = "all"     // Every mode together
+	ModeClassic = "classic" // The classic race, i.e. rooms without a mode
+)
+
+const (
+	// DefaultPageSize is how many entries a page has unless asked otherwise
+	DefaultPageSize = 10
+	// MaxPageSize caps the entries asked for in one page
+	MaxPageSize = 100
+)
+
+// ErrUnknownBoard is returned for a board that isn't one of the Board
+// constants
+var ErrUnknownBoard = errors.New("board must be wins, fastest or rating")
+
+// Stats are a player's results in one mode
+type Stats struct {
+	PlayerID string
+	Name     string
+	Matches  int
+	Wins     int
+	BestTime time.Duration // Fastest win by reaching the exit (0 = none yet)
+	Rating   int           // 0 until they play a rated match
+}
+
+// Store persists every mode's stats. Implementations must be safe for
+// concurrent use.
+type Store interface {
+	// Get returns a player's stats in a mode, or false if they have none
+	Get(mode, playerID string) (Stats, bool, error)
+	// Save records a player's new stats in a mode
+	Save(mode string, st Stats) error
+	// All returns every player's stats in a mode, in no order
+	All(mode string) ([]Stats, error)
+}
+
+// Result is how one player did in a finished match
+type Result struct {
+	PlayerID string
+	Name     string
+	Won      bool
+	Time     time.Duration // How long they took to win by reaching the exit (0 = they didn't)
+	Rating   int           // Their new overall rating, if the match was rated
+}
+
+// Entry is a player's place on a board
+type Entry struct {
+	Rank int
+	Stats
+}
+
+// Page is a stretch of a board
+type Page struct {
+	Board   string
+	Mode    string
+	Offset  int
+	Total   int // Players on the whole board
+	Entries []Entry
+}
+
+// Leaderboards keeps the boards on top of a Store
+type Leaderboards struct {
+	store Store
+	mu    sync.Mutex // Serialises read-modify-write updates
+}
+
+// New creates leaderboards backed by the given store
+func New(store Store) *Leaderboards {
+	return &Leaderboards{store: store}
+}
+
+// Record adds a finished match played in mode to that mode's boards and
+// the overall ones. Ratings change only for rated matches: within the mode
+// by the Elo of its matches alone, overall to each Result.Rating.
+func (l *Leaderboards) Record(mode string, results []Result, rated bool) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, m := range []string{mode, ModeAll} {
+		for _, res := range results {
+			st, _, err := l.store.Get(m, res.PlayerID)
+			if err != nil {
+				return err
+			}
+			st.PlayerID, st.Name = res.PlayerID, res.Name
+			st.Matches++
+			if res.Won {
+				st.Wins++
+			}
+			if res.Time > 0 && (st.BestTime == 0 || res.Time < st.BestTime) {
+				st.BestTime = res.Time
+			}
+			if rated && m == ModeAll {
+				st.Rating = res.Rating
+			}
+			if err := l.store.Save(m, st); err != nil {
+				return err
+			}
+		}
+	}
+	if !rated {
+		return nil
+	}
+
+	winner := ""
+	ids := make([]string, len(results))
+	for i, res := range results {
+		ids[i] = res.PlayerID
+		if res.Won {
+			winner = res.PlayerID
+		}
+	}
+	_, err := rating.New(modeRatings{l.store, mode}).RecordMatch(winner, ids)
+	return err
+}
+
+// Page returns up to limit entries of a board in a mode from offset on,
+// best first
+func (l *Leaderboards) Page(board, mode string, offset, limit int) (Page, error) {
+	less, ok := orders[board]
+	if !ok {
+		return Page{}, ErrUnknownBoard
+	}
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	limit = min(limit, MaxPageSize)
+	offset = max(offset, 0)
+
+	all, err := l.store.All(mode)
+	if err != nil {
+		return Page{}, err
+	}
+	var ranked []Stats
+	for _, st := range all {
+		if board == BoardWins && st.Wins > 0 || board == BoardFastest && st.BestTime > 0 || board == BoardRating && st.Rating > 0 {
+			ranked = append(ranked, st)
+		}
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if less(ranked[i], ranked[j]) != less(ranked[j], ranked[i]) {
+			return less(ranked[i], ranked[j])
+		}
+		return ranked[i].PlayerID < ranked[j].PlayerID
+	})
+
+	page := Page{Board: board, Mode: mode, Offset: offset, Total: len(ranked), Entries: []Entry{}}
+	for i := offset; i < len(ranked) && i < offset+limit; i++ {
+		page.Entries = append(page.Entries, Entry{Rank: i + 1, Stats: ranked[i]})
+	}
+	return page, nil
+}
+
+// orders rank each board, reporting whether a goes above b
+var orders = map[string]func(a, b Stats) bool{
+	BoardWins: func(a, b Stats) bool {
+		if a.Wins != b.Wins {
+			return a.Wins > b.Wins
+		}
+		return a.Matches < b.Matches
+	},
+	BoardFastest: func(a, b Stats) bool { return a.BestTime < b.BestTime },
+	BoardRating:  func(a, b Stats) bool { return a.Rating > b.Rating },
+}
+
+// modeRatings lets rating.Ratings keep a mode's ratings in its stats
+type modeRatings struct {
+	store Store
+	mode  string
+}
+
+// Load implements rating.Store
+func (m modeRatings) Load(playerID string) (int, bool, error) {
+	st, ok, err := m.store.Get(m.mode, playerID)
+	return st.Rating, ok && st.Rating > 0, err
+}
+
+// Save implements rating.Store
+func (m modeRatings) Save(playerID string, r int) error {
+	st, _, err := m.store.Get(m.mode, playerID)
+	if err != nil {
+		return err
+	}
+	st.Rating = r
+	return m.store.Save(m.mode, st)
+}
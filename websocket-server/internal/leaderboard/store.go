@@ -0,0 +1,120 @@
+package leaderboard
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"labyrinth-duel/websocket/internal/datafile"
+)
+
+// MemoryStore keeps stats in memory; they are lost on restart
+type MemoryStore struct {
+	modes map[string]map[string]Stats // Stats by player ID, by mode
+	mu    sync.RWMutex
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{modes: make(map[string]map[string]Stats)}
+}
+
+// Get implements Store
+func (s *MemoryStore) Get(mode, playerID string) (Stats, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	st, ok := s.modes[mode][playerID]
+	return st, ok, nil
+}
+
+// Save implements Store
+func (s *MemoryStore) Save(mode string, st Stats) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.saveLocked(mode, st)
+	return nil
+}
+
+func (s *MemoryStore) saveLocked(mode string, st Stats) {
+	players, ok := s.modes[mode]
+	if !ok {
+		players = make(map[string]Stats)
+		s.modes[mode] = players
+	}
+	players[st.PlayerID] = st
+}
+
+// All implements Store
+func (s *MemoryStore) All(mode string) ([]Stats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.allLocked(mode), nil
+}
+
+func (s *MemoryStore) allLocked(mode string) []Stats {
+	all := make([]Stats, 0, len(s.modes[mode]))
+	for _, st := range s.modes[mode] {
+		all = append(all, st)
+	}
+	return all
+}
+
+// FileStore keeps stats in memory and writes a mode's to its own JSON file
+// in a directory whenever they change
+type FileStore struct {
+	dir string
+	mem *MemoryStore
+}
+
+// NewFileStore loads the stats in dir, creating it if needed
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := datafile.MkdirAll(dir); err != nil {
+		return nil, err
+	}
+	s := &FileStore{dir: dir, mem: NewMemoryStore()}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		mode := strings.TrimSuffix(filepath.Base(f), ".json")
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		var all []Stats
+		if err := json.Unmarshal(data, &all); err != nil {
+			return nil, err
+		}
+		for _, st := range all {
+			s.mem.saveLocked(mode, st)
+		}
+	}
+	return s, nil
+}
+
+// Get implements Store
+func (s *FileStore) Get(mode, playerID string) (Stats, bool, error) {
+	return s.mem.Get(mode, playerID)
+}
+
+// Save implements Store
+func (s *FileStore) Save(mode string, st Stats) error {
+	s.mem.mu.Lock()
+	defer s.mem.mu.Unlock()
+
+	s.mem.saveLocked(mode, st)
+	data, err := json.MarshalIndent(s.mem.allLocked(mode), "", "  ")
+	if err != nil {
+		return err
+	}
+	return datafile.Write(filepath.Join(s.dir, mode+".json"), data)
+}
+
+// All implements Store
+func (s *FileStore) All(mode string) ([]Stats, error) {
+	return s.mem.All(mode)
+}
@@ -0,0 +1,65 @@
+// Package level holds uploaded levels, hand-made mazes players design in an
+// editor, and the stores that keep them. Rooms created from a level play
+// every round on it.
+package level
+
+import (
+	"errors"
+	"fmt"
+
+	"labyrinth-duel/websocket/internal/game"
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+// ErrNotFound is returned by Store.Get for an unknown level ID
+var ErrNotFound = errors.New("level not found")
+
+// Size limits of an uploaded level, in cells per side
+const (
+	MinSize = 5
+	MaxSize = 50
+)
+
+// MaxNameLength is the longest level name kept; longer ones are cut
+const MaxNameLength = 40
+
+// Level is an uploaded maze with who made it and when
+type Level struct {
+	messages.LevelInfo
+	Maze *game.Maze `json:"maze"`
+}
+
+// Store keeps levels
+type Store interface {
+	// Save stores a level under its ID
+	Save(l *Level) error
+	// Get loads a level, or returns ErrNotFound
+	Get(id string) (*Level, error)
+}
+
+// Check makes sure an uploaded maze, already through game.Maze.Validate,
+// is fit to be a level: within the size limits, with every cell reachable
+// and a path from each spawn to every exit
+func Check(m *game.Maze) error {
+	if m.Width < MinSize || m.Height < MinSize || m.Width > MaxSize || m.Height > MaxSize {
+		return fmt.Errorf("%dx%d level out of range, each side must be %d to %d cells", m.Width, m.Height, MinSize, MaxSize)
+	}
+	return CheckPlayable(m)
+}
+
+// CheckPlayable makes sure every cell of a maze can be reached and each
+// spawn has a path to every exit, so nobody is handed a maze they can't
+// finish
+func CheckPlayable(m *game.Maze) error {
+	if !m.IsConnected() {
+		return errors.New("some cells can't be reached")
+	}
+	for _, s := range m.Spawns {
+		for _, g := range m.Goals {
+			if m.ShortestPath(s, g.Point) == nil {
+				return fmt.Errorf("no path from spawn %v to exit %v", s, g.Point)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package level
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"labyrinth-duel/websocket/internal/datafile"
+)
+
+// DefaultMemoryCapacity is how many levels a MemoryStore keeps
+const DefaultMemoryCapacity = 200
+
+// MemoryStore keeps the most recently uploaded levels in memory; they are
+// lost on restart
+type MemoryStore struct {
+	capacity int
+	levels   map[string]*Level
+	order    []string // IDs, oldest first
+	mu       sync.RWMutex
+}
+
+// NewMemoryStore creates an empty in-memory store holding up to capacity
+// levels (DefaultMemoryCapacity if capacity is 0 or less)
+func NewMemoryStore(capacity int) *MemoryStore {
+	if capacity <= 0 {
+		capacity = DefaultMemoryCapacity
+	}
+	return &MemoryStore{capacity: capacity, levels: make(map[string]*Level)}
+}
+
+// Save implements Store, dropping the oldest level once full
+func (s *MemoryStore) Save(l *Level) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.levels[l.ID]; !exists {
+		s.order = append(s.order, l.ID)
+	}
+	s.levels[l.ID] = l
+	for len(s.order) > s.capacity {
+		delete(s.levels, s.order[0])
+		s.order = s.order[1:]
+	}
+	return nil
+}
+
+// Get implements Store
+func (s *MemoryStore) Get(id string) (*Level, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	l, ok := s.levels[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return l, nil
+}
+
+// FileStore writes each level to its own JSON file in a directory, read
+// back on demand
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore stores levels in dir, creating it if needed
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := datafile.MkdirAll(dir); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Save implements Store
+func (s *FileStore) Save(l *Level) error {
+	data, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+	path, ok := s.path(l.ID)
+	if !ok {
+		return ErrNotFound
+	}
+	return datafile.Write(path, data)
+}
+
+// Get implements Store
+func (s *FileStore) Get(id string) (*Level, error) {
+	path, ok := s.path(id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var l Level
+	if err := json.Unmarshal(data, &l); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// path returns where a level lives, refusing IDs that would escape dir
+func (s *FileStore) path(id string) (string, bool) {
+	if id == "" || strings.ContainsAny(id, `/\.`) {
+		return "", false
+	}
+	return filepath.Join(s.dir, id+".json"), true
+}
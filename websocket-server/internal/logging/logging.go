@@ -0,0 +1,37 @@
+// Package logging builds the server's structured logger: leveled entries
+// with fields, written as text for people or as JSON for log pipelines.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Formats a logger can write
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// New returns a logger writing entries at level and above to w in format.
+// level is debug, info, warn or error and format is text or json; empty
+// means info and text.
+func New(w io.Writer, level, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if level != "" {
+		if err := lvl.UnmarshalText([]byte(level)); err != nil {
+			return nil, fmt.Errorf("unknown log level %q", level)
+		}
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	switch strings.ToLower(format) {
+	case "", FormatText:
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+	return nil, fmt.Errorf("unknown log format %q", format)
+}
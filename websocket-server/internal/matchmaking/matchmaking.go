@@ -0,0 +1,369 @@
+package matchmaking
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"labyrinth-duel/websocket/internal/clock"
+	"labyrinth-duel/websocket/internal/messages"
+	"labyrinth-duel/websocket/internal/room"
+)
+
+const (
+	// MatchInterval is how often the matcher looks for pairs
+	MatchInterval = 500 * time.Millisecond
+	// QueueTimeout is how long a player waits before giving up
+	QueueTimeout = 60 * time.Second
+	// BaseRatingWindow is the largest rating gap accepted right away
+	BaseRatingWindow = 100
+	// RatingWindowGrowth widens the window every WindowGrowthInterval of waiting
+	RatingWindowGrowth   = 50
+	WindowGrowthInterval = 5 * time.Second
+	// JoinTimeout is how long a matched room waits for its players to join
+	JoinTimeout = 30 * time.Second
+	// AcceptTimeout is how long both players of a proposed match have to
+	// accept it
+	AcceptTimeout = 15 * time.Second
+)
+
+// ticket is a player waiting in the queue
+type ticket struct {
+	id       string
+	client   room.Sender
+	rating   int
+	queuedAt time.Time
+}
+
+// window returns the rating gap this ticket accepts after waiting
+func (t *ticket) window(now time.Time) int {
+	waited := int(now.Sub(t.queuedAt) / WindowGrowthInterval)
+	return BaseRatingWindow + waited*RatingWindowGrowth
+}
+
+// proposal is a pair waiting for both players to accept their match
+type proposal struct {
+	tickets  [2]*ticket
+	accepted [2]bool
+	expires  time.Time
+}
+
+// index returns which of the pair a player is
+func (p *proposal) index(id string) int {
+	if p.tickets[0].id == id {
+		return 0
+	}
+	return 1
+}
+
+// Matchmaker pairs queued players by rating, has both accept, and puts
+// each pair in a fresh private room
+type Matchmaker struct {
+	// FillWithBots, if set, matches players who time out against a bot of
+	// about their rating instead of sending them away. Set before Run.
+	FillWithBots bool
+	// Penalize, if set, is called for each player who declines a proposed
+	// match or lets it time out, and returns the matchmaking cooldown they
+	// earn. It runs with the matchmaker locked.
+	Penalize func(playerID string) time.Duration
+	// Clock tells the time and paces the matcher (the system clock if nil).
+	// Set before Run.
+	Clock clock.Clock
+
+	rooms     *room.Manager
+	queue     []*ticket
+	proposals map[string]*proposal // By player ID, both players of each
+	mu        sync.Mutex
+
+	stopMatching func() // Stops the matcher loop, once Run has started it
+	stopOnce     sync.Once
+}
+
+// New creates a matchmaker that opens rooms on the given manager
+func New(rooms *room.Manager) *Matchmaker {
+	return &Matchmaker{
+		rooms:     rooms,
+		proposals: make(map[string]*proposal),
+	}
+}
+
+// Run pairs players every MatchInterval until Stop is called
+func (m *Matchmaker) Run() {
+	m.stopMatching = m.clock().Every(MatchInterval, m.match)
+}
+
+// Stop shuts down the matcher loop
+func (m *Matchmaker) Stop() {
+	m.stopOnce.Do(func() {
+		if m.stopMatching != nil {
+			m.stopMatching()
+		}
+	})
+}
+
+// clock returns the matchmaker's clock
+func (m *Matchmaker) clock() clock.Clock {
+	if m.Clock == nil {
+		return clock.Real{}
+	}
+	return m.Clock
+}
+
+// Enqueue puts a player in the queue. Returns false if they already are,
+// or have a match waiting to be accepted.
+func (m *Matchmaker) Enqueue(id string, client room.Sender, rating int) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.indexLocked(id) >= 0 || m.proposals[id] != nil {
+		return false
+	}
+	m.enqueueLocked(&ticket{
+		id:     id,
+		client: client,
+		rating: rating,
+	})
+	return true
+}
+
+// enqueueLocked starts a ticket's wait in the queue
+func (m *Matchmaker) enqueueLocked(t *ticket) {
+	t.queuedAt = m.clock().Now()
+	m.queue = append(m.queue, t)
+	t.client.SendJSON(messages.ServerMessage{
+		Type:    "queued",
+		Seconds: int(QueueTimeout.Seconds()),
+	})
+}
+
+// Cancel takes a player out of the queue. Returns false if they weren't in
+// it. Cancelling while a match waits to be accepted declines it.
+func (m *Matchmaker) Cancel(id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if p := m.proposals[id]; p != nil {
+		m.failLocked(p, "declined")
+		return true
+	}
+	i := m.indexLocked(id)
+	if i < 0 {
+		return false
+	}
+	m.queue = append(m.queue[:i], m.queue[i+1:]...)
+	return true
+}
+
+// Accept accepts the player's proposed match. Once both players have, the
+// match starts. Returns false if they have no match to accept.
+func (m *Matchmaker) Accept(id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p := m.proposals[id]
+	if p == nil {
+		return false
+	}
+	i := p.index(id)
+	if p.accepted[i] {
+		return true
+	}
+	p.accepted[i] = true
+	if !p.accepted[1-i] {
+		p.tickets[1-i].client.SendJSON(messages.ServerMessage{
+			Type:    "matchAccepted",
+			Message: id,
+		})
+		return true
+	}
+
+	delete(m.proposals, p.tickets[0].id)
+	delete(m.proposals, p.tickets[1].id)
+	m.startMatch(p.tickets[0], p.tickets[1])
+	return true
+}
+
+// Decline turns down the player's proposed match. Returns false if they
+// have no match to decline.
+func (m *Matchmaker) Decline(id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p := m.proposals[id]
+	if p == nil {
+		return false
+	}
+	m.failLocked(p, "declined")
+	return true
+}
+
+// propose offers two players a match they both have to accept within
+// AcceptTimeout
+func (m *Matchmaker) propose(a, b *ticket, now time.Time) {
+	p := &proposal{
+		tickets: [2]*ticket{a, b},
+		expires: now.Add(AcceptTimeout),
+	}
+	m.proposals[a.id] = p
+	m.proposals[b.id] = p
+
+	for _, pair := range [][2]*ticket{{a, b}, {b, a}} {
+		pair[0].client.SendJSON(messages.ServerMessage{
+			Type:    "matchProposed",
+			Message: pair[1].id,
+			Rating:  pair[1].rating,
+			Seconds: int(AcceptTimeout.Seconds()),
+		})
+	}
+}
+
+// failLocked calls off a proposed match. Whoever hadn't accepted is
+// penalized and leaves the queue; whoever had goes back in it.
+func (m *Matchmaker) failLocked(p *proposal, reason string) {
+	delete(m.proposals, p.tickets[0].id)
+	delete(m.proposals, p.tickets[1].id)
+
+	for i, t := range p.tickets {
+		other := p.tickets[1-i]
+		if p.accepted[i] {
+			t.client.SendJSON(messages.ServerMessage{
+				Type:    "matchCancelled",
+				Reason:  reason,
+				Message: other.id,
+			})
+			m.enqueueLocked(t)
+			continue
+		}
+
+		var cooldown time.Duration
+		if m.Penalize != nil {
+			cooldown = m.Penalize(t.id)
+		}
+		t.client.SendJSON(messages.ServerMessage{
+			Type:    "matchCancelled",
+			Reason:  reason,
+			Message: t.id,
+			Seconds: int(cooldown.Seconds()),
+		})
+	}
+}
+
+func (m *Matchmaker) indexLocked(id string) int {
+	for i, t := range m.queue {
+		if t.id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// match calls off proposals nobody accepted in time, drops timed-out
+// tickets, and proposes matches to neighbours in rating order whose gap
+// fits the wider of their two windows
+func (m *Matchmaker) match(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, p := range m.proposals {
+		if p.tickets[0].id == id && !now.Before(p.expires) {
+			m.failLocked(p, "timeout")
+		}
+	}
+
+	waiting := m.queue[:0]
+	for _, t := range m.queue {
+		if now.Sub(t.queuedAt) >= QueueTimeout {
+			if m.FillWithBots {
+				m.startBotMatch(t)
+				continue
+			}
+			t.client.SendJSON(messages.ServerMessage{
+				Type:    "queueTimeout",
+				Message: "No match found",
+			})
+			continue
+		}
+		waiting = append(waiting, t)
+	}
+	sort.SliceStable(waiting, func(i, j int) bool { return waiting[i].rating < waiting[j].rating })
+
+	var left []*ticket
+	for i := 0; i < len(waiting); i++ {
+		if i+1 < len(waiting) {
+			a, b := waiting[i], waiting[i+1]
+			window := a.window(now)
+			if w := b.window(now); w > window {
+				window = w
+			}
+			if b.rating-a.rating <= window {
+				m.propose(a, b, now)
+				i++
+				continue
+			}
+		}
+		left = append(left, waiting[i])
+	}
+	m.queue = left
+}
+
+// startMatch opens a private two-player room and tells both players how to
+// join it. The room is removed if nobody turns up within JoinTimeout.
+func (m *Matchmaker) startMatch(a, b *ticket) {
+	roomID, r := m.openRoom(true)
+
+	for _, pair := range [][2]*ticket{{a, b}, {b, a}} {
+		pair[0].client.SendJSON(messages.ServerMessage{
+			Type:    "matchFound",
+			RoomID:  roomID,
+			Code:    r.JoinCode,
+			Message: pair[1].id,
+			Rating:  pair[1].rating,
+		})
+	}
+
+	m.expireUnjoined(r)
+}
+
+// startBotMatch opens a private room with a bot of about the player's
+// rating already seated, and sends the player there
+func (m *Matchmaker) startBotMatch(t *ticket) {
+	roomID, r := m.openRoom(false)
+	difficulty := room.BotDifficultyForRating(t.rating)
+	botID, err := r.AddBot(difficulty.Name)
+	if err != nil {
+		m.rooms.RemoveRoom(roomID)
+		t.client.SendJSON(messages.ServerMessage{
+			Type:    "queueTimeout",
+			Message: "No match found",
+		})
+		return
+	}
+
+	t.client.SendJSON(messages.ServerMessage{
+		Type:    "matchFound",
+		RoomID:  roomID,
+		Code:    r.JoinCode,
+		Message: botID,
+		Rating:  difficulty.Rating,
+	})
+	m.expireUnjoined(r)
+}
+
+// openRoom creates a fresh private two-player room for a match, whose
+// maze the players pick by veto once both are ready. Leaving a ranked one
+// mid-match counts as abandoning it.
+func (m *Matchmaker) openRoom(ranked bool) (string, *room.Room) {
+	roomID := "match-" + uuid.New().String()[:8]
+	r, _ := m.rooms.GetOrCreateRoom(roomID, room.Options{
+		Rules:  room.RuleSet{MapVeto: true, Ranked: ranked},
+		Access: room.Access{Private: true, MaxPlayers: 2},
+	})
+	return roomID, r
+}
+
+// expireUnjoined removes a match room if no player has joined it within
+// JoinTimeout
+func (m *Matchmaker) expireUnjoined(r *room.Room) {
+	m.clock().AfterFunc(JoinTimeout, func() { m.rooms.RemoveIfEmpty(r, "unjoined") })
+}
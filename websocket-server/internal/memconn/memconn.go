@@ -0,0 +1,169 @@
+// Package memconn is an in-memory transport for the game server. It lets
+// whole multi-client scenarios run in one process without sockets.
+package memconn
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+// TextMessage mirrors websocket.TextMessage so servers can't tell the
+// difference
+const TextMessage = 1
+
+// Errors returned by connection ends
+var (
+	ErrClosed  = errors.New("connection closed")
+	ErrTimeout = errors.New("timed out waiting for a message")
+)
+
+// queue is an unbounded FIFO of frames. Writers never block, which matters
+// because the server writes while holding room locks.
+type queue struct {
+	frames [][]byte
+	ready  chan struct{} // Signalled when frames were pushed
+	mu     sync.Mutex
+}
+
+func newQueue() *queue {
+	return &queue{ready: make(chan struct{}, 1)}
+}
+
+func (q *queue) push(frame []byte) {
+	q.mu.Lock()
+	q.frames = append(q.frames, frame)
+	q.mu.Unlock()
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// pop waits for the next frame until the timeout (0 = forever) or until
+// closed is closed
+func (q *queue) pop(timeout time.Duration, closed <-chan struct{}) ([]byte, error) {
+	var expire <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expire = timer.C
+	}
+
+	for {
+		q.mu.Lock()
+		if len(q.frames) > 0 {
+			frame := q.frames[0]
+			q.frames = q.frames[1:]
+			q.mu.Unlock()
+			return frame, nil
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-q.ready:
+		case <-closed:
+			return nil, ErrClosed
+		case <-expire:
+			return nil, ErrTimeout
+		}
+	}
+}
+
+// pipe is the state shared by both ends
+type pipe struct {
+	toServer *queue
+	toClient *queue
+	closed   chan struct{}
+	once     sync.Once
+}
+
+func (p *pipe) close() {
+	p.once.Do(func() { close(p.closed) })
+}
+
+// ServerConn is the server's end; it satisfies server.Conn
+type ServerConn struct{ p *pipe }
+
+// ClientConn is the client's end, speaking the protocol's message types
+type ClientConn struct{ p *pipe }
+
+// Pipe returns the two ends of a new in-memory connection
+func Pipe() (*ServerConn, *ClientConn) {
+	p := &pipe{
+		toServer: newQueue(),
+		toClient: newQueue(),
+		closed:   make(chan struct{}),
+	}
+	return &ServerConn{p}, &ClientConn{p}
+}
+
+// ReadMessage blocks until the client sends a frame or the pipe closes
+func (c *ServerConn) ReadMessage() (int, []byte, error) {
+	frame, err := c.p.toServer.pop(0, c.p.closed)
+	return TextMessage, frame, err
+}
+
+// WriteMessage queues a frame for the client. The client end always
+// speaks JSON, so only text frames are expected.
+func (c *ServerConn) WriteMessage(messageType int, data []byte) error {
+	select {
+	case <-c.p.closed:
+		return ErrClosed
+	default:
+	}
+
+	c.p.toClient.push(data)
+	return nil
+}
+
+// Close closes the connection for both ends
+func (c *ServerConn) Close() error {
+	c.p.close()
+	return nil
+}
+
+// Send queues a message for the server
+func (c *ClientConn) Send(msg messages.ClientMessage) error {
+	select {
+	case <-c.p.closed:
+		return ErrClosed
+	default:
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.p.toServer.push(data)
+	return nil
+}
+
+// Next returns the next message from the server, waiting up to timeout
+// (0 = forever). Messages already queued are still delivered after Close.
+func (c *ClientConn) Next(timeout time.Duration) (messages.ServerMessage, error) {
+	var msg messages.ServerMessage
+	frame, err := c.p.toClient.pop(timeout, c.p.closed)
+	if errors.Is(err, ErrClosed) {
+		// Drain whatever the server wrote before hanging up
+		frame, err = c.p.toClient.pop(time.Nanosecond, nil)
+		if err != nil {
+			return msg, ErrClosed
+		}
+	}
+	if err != nil {
+		return msg, err
+	}
+	err = json.Unmarshal(frame, &msg)
+	return msg, err
+}
+
+// Close hangs up, as if the client's socket dropped
+func (c *ClientConn) Close() error {
+	c.p.close()
+	return nil
+}
@@ -0,0 +1,59 @@
+package messages
+
+import "encoding/json"
+
+// WebSocket frame types, as in gorilla/websocket
+const (
+	TextFrame   = 1
+	BinaryFrame = 2
+)
+
+// Encoder turns a message into one wire frame
+type Encoder interface {
+	Encode(v interface{}) ([]byte, error)
+}
+
+// Decoder parses one wire frame into a message
+type Decoder interface {
+	Decode(data []byte, v interface{}) error
+}
+
+// Codec is a wire format. Clients choose one when they connect, so the
+// server can talk JSON and MessagePack to different clients at once.
+type Codec interface {
+	Encoder
+	Decoder
+	Name() string
+	FrameType() int // TextFrame or BinaryFrame
+}
+
+// Codecs by the name clients request them with
+var (
+	JSON    Codec = jsonCodec{}
+	MsgPack Codec = msgpackCodec{}
+)
+
+// CodecByName looks a codec up by name; "" means JSON
+func CodecByName(name string) (Codec, bool) {
+	switch name {
+	case "", "json":
+		return JSON, true
+	case "msgpack":
+		return MsgPack, true
+	}
+	return nil, false
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Encode(v interface{}) ([]byte, error)    { return json.Marshal(v) }
+func (jsonCodec) Decode(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+func (jsonCodec) Name() string                            { return "json" }
+func (jsonCodec) FrameType() int                          { return TextFrame }
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Encode(v interface{}) ([]byte, error)    { return MarshalMsgPack(v) }
+func (msgpackCodec) Decode(data []byte, v interface{}) error { return UnmarshalMsgPack(data, v) }
+func (msgpackCodec) Name() string                            { return "msgpack" }
+func (msgpackCodec) FrameType() int                          { return BinaryFrame }
@@ -0,0 +1,196 @@
+package messages
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// CapCompactMaze is the capability flag asking for compact mazes: MazeData
+// then carries Walls and Terrain instead of the Cells grid.
+//
+// Walls is the standard base64 encoding of one byte per cell, row by row and
+// floor by floor from the ground up (index z*width*height + y*width + x):
+//
+//	bit 0  top wall
+//	bit 1  right wall
+//	bit 2  bottom wall
+//	bit 3  left wall
+//	bits 4-5  crossing tunnel axis: 0 none, 1 horizontal, 2 vertical
+//	bit 6  stairs up
+//	bit 7  stairs down
+//
+// Terrain holds one letter per cell in the same order, from TerrainCodes,
+// and is omitted when every cell is plain. A 50x50 maze takes about 3 KB
+// instead of 150 KB of cell objects.
+const CapCompactMaze = "compactMaze"
+
+// TerrainCodes maps each terrain to its letter in MazeData.Terrain
+var TerrainCodes = map[string]byte{
+	"":       '.',
+	"mud":    'm',
+	"road":   'r',
+	"ice":    'i',
+	"portal": 'p',
+}
+
+// underAxes are the crossing tunnel axes by their bits 4-5 value
+var underAxes = []string{"", "horizontal", "vertical"}
+
+// Compacted returns a copy of the maze with the cell grid packed into Walls
+// and Terrain. A maze without a grid (under fog) is returned as is.
+func (m *MazeData) Compacted() *MazeData {
+	if m == nil || m.Cells == nil {
+		return m
+	}
+
+	n := m.Width * m.Height * (len(m.Upper) + 1)
+	walls := make([]byte, 0, n)
+	terrain := make([]byte, 0, n)
+	plain := true
+	for _, row := range m.rows() {
+		for _, c := range row {
+			var b byte
+			for bit, set := range []bool{c.Top, c.Right, c.Bottom, c.Left, false, false, c.StairsUp, c.StairsDown} {
+				if set {
+					b |= 1 << bit
+				}
+			}
+			for i, axis := range underAxes {
+				if axis != "" && axis == c.Under {
+					b |= byte(i) << 4
+				}
+			}
+			walls = append(walls, b)
+
+			code, ok := TerrainCodes[c.Terrain]
+			if !ok {
+				code = TerrainCodes[""]
+			}
+			plain = plain && c.Terrain == ""
+			terrain = append(terrain, code)
+		}
+	}
+
+	compact := *m
+	compact.Cells, compact.Upper = nil, nil
+	compact.Walls = base64.StdEncoding.EncodeToString(walls)
+	compact.Terrain = ""
+	if !plain {
+		compact.Terrain = string(terrain)
+	}
+	return &compact
+}
+
+// Expand rebuilds Cells from a compact maze's Walls and Terrain, and clears
+// them. It does nothing if the maze isn't compact.
+func (m *MazeData) Expand() error {
+	if m.Walls == "" {
+		return nil
+	}
+	walls, err := base64.StdEncoding.DecodeString(m.Walls)
+	if err != nil {
+		return fmt.Errorf("maze walls: %w", err)
+	}
+	floors := max(m.Floors, 1)
+	n := m.Width * m.Height * floors
+	if len(walls) != n {
+		return fmt.Errorf("maze walls: %d cells for a %dx%dx%d maze", len(walls), m.Width, m.Height, floors)
+	}
+	if m.Terrain != "" && len(m.Terrain) != n {
+		return fmt.Errorf("maze terrain: %d cells for a %dx%dx%d maze", len(m.Terrain), m.Width, m.Height, floors)
+	}
+
+	terrains := make(map[byte]string, len(TerrainCodes))
+	for name, code := range TerrainCodes {
+		terrains[code] = name
+	}
+
+	grid := make([][][]Cell, floors)
+	for z := range grid {
+		grid[z] = make([][]Cell, m.Height)
+		for y := range grid[z] {
+			grid[z][y] = make([]Cell, m.Width)
+			for x := range grid[z][y] {
+				i := (z*m.Height+y)*m.Width + x
+				b := walls[i]
+				axis := int(b>>4) & 3
+				if axis >= len(underAxes) {
+					return errors.New("maze walls: bad cell byte")
+				}
+				c := Cell{
+					X:          x,
+					Y:          y,
+					Z:          z,
+					Top:        b&1 != 0,
+					Right:      b&2 != 0,
+					Bottom:     b&4 != 0,
+					Left:       b&8 != 0,
+					Under:      underAxes[axis],
+					StairsUp:   b&64 != 0,
+					StairsDown: b&128 != 0,
+				}
+				if m.Terrain != "" {
+					name, ok := terrains[m.Terrain[i]]
+					if !ok {
+						return fmt.Errorf("maze terrain: unknown code %q", m.Terrain[i])
+					}
+					c.Terrain = name
+				}
+				grid[z][y][x] = c
+			}
+		}
+	}
+
+	m.Cells, m.Upper = grid[0], nil
+	if floors > 1 {
+		m.Upper = grid[1:]
+	}
+	m.Walls, m.Terrain = "", ""
+	return nil
+}
+
+// rows returns the rows of every floor, ground floor first
+func (m *MazeData) rows() [][]Cell {
+	rows := m.Cells
+	for _, floor := range m.Upper {
+		rows = append(rows[:len(rows):len(rows)], floor...)
+	}
+	return rows
+}
+
+// CapTickBatch is the capability flag asking for ticks: instead of one
+// message per room event, the client gets one "tick" message per room tick
+// (20 Hz by default) whose Batch holds everything since the last, in order.
+// Within a tick only the latest playerMoved per player, and the latest
+// timer, snapshot and scoreUpdate, are kept. Tick and Time stamp it for
+// interpolating between ticks.
+const CapTickBatch = "tickBatch"
+
+// CapDeltaUpdates is the capability flag saying the client applies deltas:
+// playerMoved with only the mover's position and mazeUpdated with only the
+// changed cells. A client that says hello without it gets the whole player
+// list and maze with each instead. Clients that never say hello get deltas.
+const CapDeltaUpdates = "deltaUpdates"
+
+// CapFog is the capability flag saying the client can draw a maze it only
+// sees part of. A client that says hello without it can't join fog-of-war
+// rooms. Clients that never say hello may join them.
+const CapFog = "fog"
+
+// CapFloors is the capability flag saying the client can show a maze of
+// more than one floor: Upper, the stairs and the z of everything in it. Only
+// clients that say hello with it may join rooms whose maze has floors.
+const CapFloors = "floors"
+
+// ParseCaps splits a comma-separated capability list, dropping blanks
+func ParseCaps(s string) []string {
+	var caps []string
+	for _, c := range strings.Split(s, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			caps = append(caps, c)
+		}
+	}
+	return caps
+}
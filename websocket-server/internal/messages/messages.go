@@ -1,41 +1,529 @@
 package messages
 
+//go:generate go run ../../cmd/pysdkgen -in messages.go -o ../../sdk/python/maze_sdk/messages.py
+
 // ClientMessage is what we receive from the browser
 type ClientMessage struct {
-	Type   string `json:"type"`
-	RoomID string `json:"roomId,omitempty"`
-	X      int    `json:"x,omitempty"`
-	Y      int    `json:"y,omitempty"`
+	Type      string `json:"type"`
+	RequestID string `json:"requestId,omitempty"` // Echoed in any error the message causes
+	Seq       uint64 `json:"seq,omitempty"`       // Optional increasing message ID; acked via ack, duplicates are dropped
+	RoomID    string `json:"roomId,omitempty"`
+	X         int    `json:"x,omitempty"`
+	Y         int    `json:"y,omitempty"`
+	Z         int    `json:"z,omitempty"` // Floor, in mazes of more than one
+
+	// hello: what the client speaks, replacing what the connection URL asked for
+	Protocol  int      `json:"protocol,omitempty"`  // Protocol version the client was written against
+	Encodings []string `json:"encodings,omitempty"` // Wire formats it can use, preferred first: json, msgpack
+	Caps      []string `json:"caps,omitempty"`      // Features it supports: compactMaze, tickBatch, deltaUpdates, fog, floors
+
+	// join
+	Code     string `json:"code,omitempty"`     // Join code of a private room (instead of roomId); resume code (resumeMatch)
+	Password string `json:"password,omitempty"` // Room password, if it has one
+
+	// updateProfile (empty fields are left unchanged)
+	Name   string `json:"name,omitempty"`
+	Color  string `json:"color,omitempty"` // #rrggbb
+	Avatar string `json:"avatar,omitempty"`
+
+	// chat / emote
+	Text    string `json:"text,omitempty"`
+	Channel string `json:"channel,omitempty"` // chat: "" for the whole room, team for your team only
+	Emote   string `json:"emote,omitempty"`   // One of the predefined emote IDs
+
+	// setTeam, kick, transferHost, lockRoom (host only)
+	PlayerID string `json:"playerId,omitempty"` // Player to move, kick or hand the room to
+	Team     int    `json:"team,omitempty"`     // Team to pin them to (0 = back to auto-balancing)
+	Ban      bool   `json:"ban,omitempty"`      // kick: keep them out for good
+	Locked   bool   `json:"locked,omitempty"`   // lockRoom: true to turn new players away, false to let them in again
+
+	// loadMaze (admin only; roomId picks the room, default yours), uploadMaze (name names the level)
+	MazeFile string    `json:"mazeFile,omitempty"` // The maze in a file format: text (ASCII art) or json
+	Format   string    `json:"format,omitempty"`   // text or json (default: json if mazeFile starts with {)
+	Layout   *MazeData `json:"layout,omitempty"`   // uploadMaze: the maze as cells, goal(s) and spawns, instead of mazeFile
+
+	// startVote, castVote
+	VoteKind string `json:"voteKind,omitempty"` // Kind of vote to start: kick (needs playerId), newMaze, mazeSize (needs size), extendTime, restart, pause, resume or suspend
+	Size     int    `json:"size,omitempty"`     // startVote mazeSize: new maze width and height, 5-40
+	Yes      bool   `json:"yes,omitempty"`      // castVote: for (true) or against
+
+	// watchReplay
+	ReplayID string  `json:"replayId,omitempty"` // Replay to stream; empty with a speed changes the running one
+	Speed    float64 `json:"speed,omitempty"`    // Playback rate (default 1, MinReplaySpeed-MaxReplaySpeed); sandbox setSpeed: the speed stat
+
+	// dailyLeaderboard
+	Day string `json:"day,omitempty"` // UTC day as YYYY-MM-DD (default today)
+
+	// leaderboard (mode picks the game mode's board: all, classic or a
+	// registered mode), matchHistory (playerId picks whose, default yours)
+	Board  string `json:"board,omitempty"`  // wins (default), fastest or rating
+	Offset int    `json:"offset,omitempty"` // Entries to skip
+	Limit  int    `json:"limit,omitempty"`  // Entries to return (leaderboard: default 10, at most 100; matchHistory: default 10, at most 50)
+
+	// addBot; join and mazeSettings (room options)
+	Difficulty string `json:"difficulty,omitempty"` // easy, medium or hard. addBot: how well the bot plays (default medium); rooms: the tier every maze must rate in ("" = any)
+
+	// Sandbox command (practice rooms), review command (finished ranked matches)
+	Command string `json:"command,omitempty"` // teleport (x, y), reveal, spawnItem (item, x, y) or setSpeed (speed); review: start, next, prev, stop
+
+	// visibility
+	Hidden bool `json:"hidden,omitempty"` // Page was backgrounded (false = foregrounded again)
+
+	// requestSnapshot
+	SinceVersion uint64 `json:"sinceVersion,omitempty"` // Last snapshot version seen; enables a fastForward instead of a full snapshot
+
+	// useItem / breakWall / moveDir
+	Item      string `json:"item,omitempty"`      // Power-up kind to use
+	Direction string `json:"direction,omitempty"` // up, right, down, left (moveDir, breakWall, wallBreak item); upstairs, downstairs (moveDir on stairs)
+
+	// Room creation options (only used by the first join)
+	LevelID         string  `json:"levelId,omitempty"`         // Play every round on this uploaded level instead of generated mazes
+	Seed            int64   `json:"seed,omitempty"`            // Reproduce a specific maze
+	MazeAlgorithm   string  `json:"mazeAlgorithm,omitempty"`   // backtracker, prim, kruskal, wilson, eller, weave
+	Theme           string  `json:"theme,omitempty"`           // hedge, ice, lava (default: seasonal)
+	LoopFactor      float64 `json:"loopFactor,omitempty"`      // 0-1, share of dead ends opened into loops
+	TerrainDensity  float64 `json:"terrainDensity,omitempty"`  // 0-1, share of cells with the theme's terrain: mud, road, ice or portals
+	CrossingDensity float64 `json:"crossingDensity,omitempty"` // 0-1, share of straight corridors bridged over a tunnel
+	Floors          int     `json:"floors,omitempty"`          // Floors stacked and joined by stairs, 1-4 (default 1)
+	DeadEnds        string  `json:"deadEnds,omitempty"`        // "", prune, stuff
+	DeadEndCount    int     `json:"deadEndCount,omitempty"`    // How many of the longest dead ends to prune/stuff
+	MinPathRatio    float64 `json:"minPathRatio,omitempty"`    // 0-1, minimum spawn-to-goal distance vs. the longest possible path
+	GoalCount       int     `json:"goalCount,omitempty"`       // Number of exits
+	GoalMode        string  `json:"goalMode,omitempty"`        // "" (first exit wins) or points (bank exits until time-up)
+	GoalMoveSeconds int     `json:"goalMoveSeconds,omitempty"` // Move the exits far from every player this often (0 = fixed, at least 10)
+	ShiftSeconds    int     `json:"shiftSeconds,omitempty"`    // Change part of the maze this often, keeping it solvable (0 = static, at least 10)
+	Minotaur        string  `json:"minotaur,omitempty"`        // Let minotaurs prowl the maze: easy, medium or hard ("" = none)
+	AntiCamp        string  `json:"antiCamp,omitempty"`        // Camping penalty: "" (off), reveal, decay, both
+	CampSeconds     int     `json:"campSeconds,omitempty"`     // How long a player may stay put before they are camping (default 20)
+	CampRadius      int     `json:"campRadius,omitempty"`      // Cells a player may wander each way and still be camping (default 1)
+	MoveIntervalMs  int     `json:"moveIntervalMs,omitempty"`  // Milliseconds between moves at speed 1 (default 100, at least 20)
+	WallCharges     int     `json:"wallCharges,omitempty"`     // Walls each player may break per match
+	Collision       string  `json:"collision,omitempty"`       // "" (pass through), block, bump
+	Fog             bool    `json:"fog,omitempty"`             // Reveal the maze only as players explore
+	FogRadius       int     `json:"fogRadius,omitempty"`       // Visibility radius under fog
+	Private         bool    `json:"private,omitempty"`         // Hide the room from listings; others join with the returned code
+	MaxPlayers      int     `json:"maxPlayers,omitempty"`      // Player limit (0 = unlimited)
+	Rounds          int     `json:"rounds,omitempty"`          // Best-of-N rounds, each on a new maze
+	Mode            string  `json:"mode,omitempty"`            // Game mode; its messages are typed "<mode>.<action>"
+	Teams           int     `json:"teams,omitempty"`           // Number of teams (0 = free-for-all)
+	TeamGoal        string  `json:"teamGoal,omitempty"`        // "" (first player out wins for their team), all (whole team out), score (combined score)
+	Coop            bool    `json:"coop,omitempty"`            // Everyone on one team, racing the clock to get every player out
+	Hints           bool    `json:"hints,omitempty"`           // Spawn hint power-ups
+	Practice        bool    `json:"practice,omitempty"`        // Solo practice room that accepts sandbox commands
+	MapVeto         bool    `json:"mapVeto,omitempty"`         // Players strike candidate mazes before the match
 }
 
 // ServerMessage is what we send to the browser
 type ServerMessage struct {
-	Type    string    `json:"type"`
-	Players []Player  `json:"players,omitempty"`
-	Message string    `json:"message,omitempty"`
-	Maze    *MazeData `json:"maze,omitempty"`
+	Type        string          `json:"type"`
+	Players     []Player        `json:"players,omitempty"`
+	Message     string          `json:"message,omitempty"`
+	Maze        *MazeData       `json:"maze,omitempty"`
+	Direction   string          `json:"direction,omitempty"` // For noise hints: up, right, down, left
+	State       string          `json:"state,omitempty"`     // Room lifecycle: waiting, countdown, playing, paused, finished
+	Seconds     int             `json:"seconds,omitempty"`   // Countdown seconds remaining
+	StartsAt    int64           `json:"startsAt,omitempty"`  // gameStarting, matchResuming: server time (Unix ms) moves are accepted from, to count down to
+	Winner      string          `json:"winner,omitempty"`
+	WinningTeam int             `json:"winningTeam,omitempty"` // Team of the winner in team rooms, all of whom won (gameOver)
+	Reason      string          `json:"reason,omitempty"`      // Why the game ended, e.g. "goal"
+	Summary     *GameSummary    `json:"summary,omitempty"`
+	Items       []Item          `json:"items,omitempty"`
+	Cells       []Cell          `json:"cells,omitempty"`       // Newly visible cells (mazeReveal, or mazeData under fog)
+	Position    *Position       `json:"position,omitempty"`    // Where an event happened (collision), a player moved to (playerMoved) or the primary exit moved to (goalMoved)
+	Goals       []Goal          `json:"goals,omitempty"`       // Every exit with its new value (goalMoved)
+	Breadcrumbs []Breadcrumb    `json:"breadcrumbs,omitempty"` // Runners' recent cells, for hunters only (breadcrumbs)
+	Flags       []Flag          `json:"flags,omitempty"`       // Every team's flag, in capture the flag
+	Hazards     []Hazard        `json:"hazards,omitempty"`     // Server-controlled hazards roaming the maze (hazardsMoved, snapshot, mazeData)
+	Path        []Position      `json:"path,omitempty"`        // Next steps toward the nearest exit (hint)
+	Vote        *VoteStatus     `json:"vote,omitempty"`        // voteStarted, voteUpdated, voteEnded
+	Veto        *VetoStatus     `json:"veto,omitempty"`        // vetoStarted, vetoUpdated, vetoDecided
+	Review      *ReviewStatus   `json:"review,omitempty"`      // reviewStarted, reviewStep
+	Replay      *ReplayInfo     `json:"replay,omitempty"`      // replayStart
+	Event       *ReplayEvent    `json:"event,omitempty"`       // replayEvent
+	Replays     []ReplayInfo    `json:"replays,omitempty"`     // replayList
+	Level       *LevelInfo      `json:"level,omitempty"`       // mazeUploaded
+	Batch       []ServerMessage `json:"batch,omitempty"`       // Messages from one atomic room transaction, or one tick, in order
+	Round       int             `json:"round,omitempty"`       // Round just finished (roundOver) or about to start (newRound)
+	Hash        string          `json:"hash,omitempty"`        // Fingerprint of the room state (snapshot, resync) for desync detection
+	Tick        uint64          `json:"tick,omitempty"`        // Room tick the snapshot was taken at or the tick message is for
+	Time        int64           `json:"time,omitempty"`        // Server clock in Unix milliseconds when the tick was sent, for interpolation
+	Version     uint64          `json:"version,omitempty"`     // Room version a snapshot reflects; pass back as sinceVersion
+	Rooms       []RoomInfo      `json:"rooms,omitempty"`       // Open rooms (roomList)
+	Code        string          `json:"code,omitempty"`        // Join code of a private room (mazeData, matchFound); resume code (matchSuspended, resumePending, resumeRequested)
+	RoomID      string          `json:"roomId,omitempty"`      // Room to join (matchFound)
+	Profile     *Profile        `json:"profile,omitempty"`     // The player's own profile (connected, profile)
+	Secret      string          `json:"secret,omitempty"`      // Secret to pass back with the player ID to resume it (connected); sent to no one else
+	Rating      int             `json:"rating,omitempty"`      // Opponent's rating (matchProposed, matchFound)
+	Host        string          `json:"host,omitempty"`        // Player hosting the room (mazeData, spectating, hostChanged)
+	Daily       *DailyBoard     `json:"daily,omitempty"`       // dailyLeaderboard
+	Leaderboard *Leaderboard    `json:"leaderboard,omitempty"` // leaderboard
+	History     *MatchHistory   `json:"history,omitempty"`     // matchHistory
+	Chat        []ChatMessage   `json:"chat,omitempty"`        // chat (one line) or chatHistory
+	Emote       string          `json:"emote,omitempty"`       // Emote ID; Message holds the sender
+	Error       string          `json:"error,omitempty"`       // Machine-readable code (error, *Rejected), e.g. INVALID_MOVE
+	RequestID   string          `json:"requestId,omitempty"`   // requestId of the message that failed
+	Fields      []FieldError    `json:"fields,omitempty"`      // What exactly was wrong with a rejected message (strict validation)
+	Caps        []string        `json:"caps,omitempty"`        // Capabilities enabled for this connection (connected, welcome)
+	Protocol    int             `json:"protocol,omitempty"`    // Protocol version the connection speaks from now on (welcome)
+	Encoding    string          `json:"encoding,omitempty"`    // Wire format used from the next message on (welcome)
+
+	// Envelope
+	Seq     uint64 `json:"seq,omitempty"`     // Room sequence number (room messages only)
+	PrevSeq uint64 `json:"prevSeq,omitempty"` // seq of the previous room message to this client; a mismatch means one was lost
+	Ack     uint64 `json:"ack,omitempty"`     // Highest client seq processed so far
+}
+
+// FieldError is one problem with a field of a client message
+type FieldError struct {
+	Field   string `json:"field"` // Path to the field, e.g. x or batch[2].type
+	Message string `json:"message"`
+}
+
+// RoomInfo describes a room for lobby discovery
+type RoomInfo struct {
+	ID         string `json:"id"`
+	Players    int    `json:"players"`
+	Width      int    `json:"width"`
+	Height     int    `json:"height"`
+	State      string `json:"state"`    // Room lifecycle: waiting, countdown, playing, paused, finished
+	Joinable   bool   `json:"joinable"` // In the lobby with a free slot, so new players can take part
+	MaxPlayers int    `json:"maxPlayers,omitempty"`
+	Password   bool   `json:"password,omitempty"` // Joining needs a password
+	Degraded   bool   `json:"degraded,omitempty"` // Over its bandwidth budget
+	Rating     int    `json:"rating,omitempty"`   // Average rating of the players in it
+	Mode       string `json:"mode,omitempty"`     // Game mode, if not the classic race
+	Demo       bool   `json:"demo,omitempty"`     // Bots are playing a demo match to watch until someone joins
+	Locked     bool   `json:"locked,omitempty"`   // The host locked it against new players
+	Level      string `json:"level,omitempty"`    // Name of the uploaded level it plays, if any
+}
+
+// ChatMessage is one line of room chat
+type ChatMessage struct {
+	PlayerID string `json:"playerId"`
+	Text     string `json:"text"`
+	Time     int64  `json:"time"`              // Unix milliseconds
+	Channel  string `json:"channel,omitempty"` // "" for the whole room, team for the sender's team only
+	Team     int    `json:"team,omitempty"`    // Sender's team in team rooms
+}
+
+// Item is a pickup lying in the maze
+type Item struct {
+	ID   string `json:"id"`
+	Kind string `json:"kind"`
+	X    int    `json:"x"`
+	Y    int    `json:"y"`
+	Z    int    `json:"z,omitempty"` // Floor
+}
+
+// GameSummary is sent with gameOver to recap the match
+type GameSummary struct {
+	Duration float64  `json:"duration"` // Seconds from start to finish
+	Players  []Player `json:"players"`
+	Awards   []Award  `json:"awards"`
+	Ranking  []string `json:"ranking,omitempty"` // Player IDs nearest an exit first, when a race runs out of time
+}
+
+// LevelInfo describes an uploaded level, a hand-made maze rooms can be
+// created to play
+type LevelInfo struct {
+	ID        string `json:"id"` // levelId to join with
+	Name      string `json:"name"`
+	Author    string `json:"author"` // Player ID of the uploader
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+	CreatedAt int64  `json:"createdAt"` // Unix milliseconds
+}
+
+// ReplayInfo describes a recorded match
+type ReplayInfo struct {
+	ID        string   `json:"id"`
+	RoomID    string   `json:"roomId"`
+	StartedAt int64    `json:"startedAt"` // Unix milliseconds
+	Duration  float64  `json:"duration"`  // Seconds from start to finish
+	Winner    string   `json:"winner,omitempty"`
+	Players   []Player `json:"players"` // As they finished
+}
+
+// ReplayEvent is one recorded event of a match
+type ReplayEvent struct {
+	T        int64  `json:"t"` // Milliseconds since play started
+	Type     string `json:"type"`
+	PlayerID string `json:"playerId,omitempty"`
+	X        int    `json:"x"`
+	Y        int    `json:"y"`
+	Z        int    `json:"z,omitempty"` // Floor
+	Value    int    `json:"value,omitempty"`
+	Detail   string `json:"detail,omitempty"` // Item kind, wall direction
+}
+
+// VoteStatus is the standing of a room vote
+type VoteStatus struct {
+	ID        int    `json:"id"`
+	Kind      string `json:"kind"`
+	Target    string `json:"target,omitempty"` // Player the vote is about
+	Size      int    `json:"size,omitempty"`   // Maze size asked for
+	StartedBy string `json:"startedBy"`
+	Yes       int    `json:"yes"`
+	No        int    `json:"no"`
+	Eligible  int    `json:"eligible"`         // Players who get a say
+	Needed    int    `json:"needed"`           // Yes votes that pass it
+	Seconds   int    `json:"seconds"`          // Left to vote
+	Result    string `json:"result,omitempty"` // passed, failed or cancelled, once ended
+}
+
+// VetoStatus is the standing of a pre-match map veto
+type VetoStatus struct {
+	Candidates []MapCandidate `json:"candidates"`
+	Turn       string         `json:"turn,omitempty"`    // Player who strikes next
+	Seconds    int            `json:"seconds,omitempty"` // Left in their turn before a random strike
+	Chosen     int64          `json:"chosen,omitempty"`  // Seed of the maze left over, once decided
+}
+
+// ReviewStatus is where a post-match review stands, with the paths to
+// draw over the maze of the round being reviewed
+type ReviewStatus struct {
+	Step    int          `json:"step"`    // Events shown so far
+	Steps   int          `json:"steps"`   // Events in the match
+	Round   int          `json:"round"`   // Round the step is in, from 0
+	Paths   []ReviewPath `json:"paths"`   // Where each player has walked this round, up to the step
+	Optimal []ReviewPath `json:"optimal"` // Shortest way from each player's start to an exit this round
+}
+
+// ReviewPath is one player's path in a review
+type ReviewPath struct {
+	PlayerID string     `json:"playerId"`
+	Path     []Position `json:"path"`
+}
+
+// MapCandidate is a maze on the veto table
+type MapCandidate struct {
+	Seed      int64  `json:"seed"`
+	Algorithm string `json:"algorithm"`
+	Theme     string `json:"theme"`
+	VetoedBy  string `json:"vetoedBy,omitempty"` // Player who struck it
+}
+
+// Award is an end-of-match accolade such as MVP or Pathfinder
+type Award struct {
+	Name     string  `json:"name"`
+	PlayerID string  `json:"playerId"`
+	Value    float64 `json:"value"` // Stat that earned the award
 }
 
 // Player represents a player's state
 type Player struct {
-	ID string `json:"id"`
-	X  int    `json:"x"`
-	Y  int    `json:"y"`
+	ID          string   `json:"id"`
+	Name        string   `json:"name,omitempty"`
+	Color       string   `json:"color,omitempty"` // #rrggbb
+	Avatar      string   `json:"avatar,omitempty"`
+	Rating      int      `json:"rating,omitempty"` // Elo skill rating
+	X           int      `json:"x"`
+	Y           int      `json:"y"`
+	Z           int      `json:"z,omitempty"` // Floor, from 0 at the bottom
+	Ready       bool     `json:"ready"`
+	Away        bool     `json:"away,omitempty"` // Backgrounded (AFK)
+	Idle        bool     `json:"idle,omitempty"` // Has done nothing for a while; removed soon if it goes on
+	Score       int      `json:"score"`
+	Multiplier  float64  `json:"multiplier"`          // Current streak multiplier
+	Level       int      `json:"level,omitempty"`     // 1 when in a tunnel under a crossing
+	Inventory   []string `json:"inventory,omitempty"` // Power-ups held
+	WallCharges int      `json:"wallCharges"`         // Walls the player can still break
+	Speed       float64  `json:"speed"`               // Speed stat: moves are this many times as frequent as the room's base
+	RoundWins   int      `json:"roundWins,omitempty"` // Rounds won in a best-of-N match
+	Team        int      `json:"team,omitempty"`      // Team number from 1 in team rooms
+	TeamColor   string   `json:"teamColor,omitempty"` // Team's color, to tint the player with
+	Bot         bool     `json:"bot,omitempty"`       // Server-controlled player
+}
+
+// Profile is a player's persistent identity and lifetime stats
+type Profile struct {
+	ID     string       `json:"id"`
+	Name   string       `json:"name"`
+	Color  string       `json:"color"`
+	Avatar string       `json:"avatar,omitempty"`
+	Rating int          `json:"rating"`
+	Stats  ProfileStats `json:"stats"`
+	// Verified players connected with a token, which their ID (and name, if
+	// it has one) come from
+	Verified bool `json:"verified,omitempty"`
+	// TutorialDone players have finished the tutorial
+	TutorialDone bool `json:"tutorialDone,omitempty"`
+}
+
+// ProfileStats are a player's lifetime results
+type ProfileStats struct {
+	Matches    int     `json:"matches"`
+	Wins       int     `json:"wins"`
+	BestScore  int     `json:"bestScore"`
+	WinRate    float64 `json:"winRate"`              // Share of matches won, 0-1
+	AvgSolveMs int64   `json:"avgSolveMs,omitempty"` // Average time to win by reaching the exit
 }
 
 // MazeData represents maze data sent to clients
 type MazeData struct {
-	Width  int      `json:"width"`
-	Height int      `json:"height"`
-	Cells  [][]Cell `json:"cells"`
+	Width      int        `json:"width"`
+	Height     int        `json:"height"`
+	Floors     int        `json:"floors,omitempty"`  // Floors joined by stairs, when more than one
+	Cells      [][]Cell   `json:"cells"`             // Ground floor; null under fog (cells arrive via mazeReveal) or when compact
+	Upper      [][][]Cell `json:"upper,omitempty"`   // Floors above the ground floor, [z-1][y][x]; sent like cells
+	Walls      string     `json:"walls,omitempty"`   // Compact cell grid (compactMaze capability), see CapCompactMaze
+	Terrain    string     `json:"terrain,omitempty"` // Compact terrain, one letter per cell (compactMaze capability)
+	Fog        bool       `json:"fog"`
+	Goal       Position   `json:"goal"`              // Primary exit
+	Goals      []Goal     `json:"goals"`             // Every exit with its point value
+	Spawns     []Position `json:"spawns"`            // Starting cells, handed out one per player while there are enough
+	Portals    []Portal   `json:"portals,omitempty"` // Linked pairs of portal cells: stepping on either comes out of the other
+	Seed       int64      `json:"seed"`              // Share to replay the same maze
+	Algorithm  string     `json:"algorithm"`
+	Theme      string     `json:"theme"` // Tileset to render: hedge, ice, lava
+	LoopFactor float64    `json:"loopFactor"`
+	Rating     *Rating    `json:"rating,omitempty"` // How hard the maze is
+}
+
+// Rating is a maze's difficulty, from what its solutions look like
+type Rating struct {
+	Tier           string  `json:"tier"`           // easy, medium or hard
+	Score          int     `json:"score"`          // 0 (a straight corridor) to 100; medium from 35, hard from 55
+	SolutionLength int     `json:"solutionLength"` // Average distance from a spawn to its nearest exit
+	BranchFactor   float64 `json:"branchFactor"`   // Wrong turns on offer per step of those solutions
+	DeadEnds       int     `json:"deadEnds"`       // Cells with one open side
+}
+
+// Portal links two portal cells both ways
+type Portal struct {
+	A Position `json:"a"`
+	B Position `json:"b"`
+}
+
+// Goal is an exit cell and what reaching it is worth
+type Goal struct {
+	X     int `json:"x"`
+	Y     int `json:"y"`
+	Z     int `json:"z,omitempty"` // Floor
+	Value int `json:"value"`
+}
+
+// Breadcrumb is a cell a runner walked out of, shown to hunters
+type Breadcrumb struct {
+	PlayerID string  `json:"playerId"`
+	X        int     `json:"x"`
+	Y        int     `json:"y"`
+	Z        int     `json:"z,omitempty"` // Floor
+	Fade     float64 `json:"fade"`        // 0 when dropped, rising to 1 as it expires
+}
+
+// Hazard is a server-controlled danger in the maze, such as a minotaur
+type Hazard struct {
+	ID      string `json:"id"`
+	Kind    string `json:"kind"` // minotaur
+	X       int    `json:"x"`
+	Y       int    `json:"y"`
+	Z       int    `json:"z,omitempty"`       // Floor
+	Chasing string `json:"chasing,omitempty"` // Player it is charging
+}
+
+// MatchHistory is a page of a player's finished matches, newest first
+type MatchHistory struct {
+	PlayerID string         `json:"playerId"`
+	Offset   int            `json:"offset"`
+	Total    int            `json:"total"` // Matches kept for the player
+	Matches  []MatchSummary `json:"matches"`
+}
+
+// MatchSummary is a finished match
+type MatchSummary struct {
+	ReplayID  string        `json:"replayId,omitempty"` // Watch it with watchReplay, while it is kept
+	RoomID    string        `json:"roomId"`
+	Mode      string        `json:"mode,omitempty"`  // Game mode, if not the classic race
+	Daily     string        `json:"daily,omitempty"` // Day of the daily challenge, if it was one
+	Seed      int64         `json:"seed"`            // Seed of the first round's maze
+	StartedAt int64         `json:"startedAt"`       // Unix milliseconds
+	Duration  float64       `json:"duration"`        // Seconds from start to finish
+	Winner    string        `json:"winner,omitempty"`
+	Reason    string        `json:"reason,omitempty"`
+	Players   []MatchPlayer `json:"players"`
+}
+
+// MatchPlayer is how one player did in a finished match
+type MatchPlayer struct {
+	ID    string `json:"id"`
+	Name  string `json:"name,omitempty"`
+	Team  int    `json:"team,omitempty"`
+	Score int    `json:"score"`
+	Moves int    `json:"moves"`
+	Won   bool   `json:"won,omitempty"`
+	Bot   bool   `json:"bot,omitempty"`
+}
+
+// Leaderboard is a page of players ranked on a board in a game mode
+type Leaderboard struct {
+	Board   string             `json:"board"` // wins, fastest or rating
+	Mode    string             `json:"mode"`  // all, classic or a registered mode
+	Offset  int                `json:"offset"`
+	Total   int                `json:"total"` // Players on the whole board
+	Entries []LeaderboardEntry `json:"entries"`
+}
+
+// LeaderboardEntry is one player's place on a leaderboard, with the stats
+// every board ranks on
+type LeaderboardEntry struct {
+	Rank       int    `json:"rank"`
+	PlayerID   string `json:"playerId"`
+	Name       string `json:"name,omitempty"`
+	Matches    int    `json:"matches"`
+	Wins       int    `json:"wins"`
+	BestTimeMs int64  `json:"bestTimeMs,omitempty"` // Fastest win by reaching the exit
+	Rating     int    `json:"rating,omitempty"`     // Elo rating in the mode
+}
+
+// DailyBoard is a daily challenge leaderboard
+type DailyBoard struct {
+	Day      string       `json:"day"` // UTC day as YYYY-MM-DD
+	Seed     int64        `json:"seed"`
+	Entries  []DailyEntry `json:"entries"`            // Best times, fastest first
+	You      *DailyEntry  `json:"you,omitempty"`      // The player's own best time, if they have one
+	Improved bool         `json:"improved,omitempty"` // The run just finished was the player's best of the day
+}
+
+// DailyEntry is one player's place on a daily leaderboard
+type DailyEntry struct {
+	Rank     int    `json:"rank"`
+	PlayerID string `json:"playerId"`
+	Name     string `json:"name,omitempty"`
+	TimeMs   int64  `json:"timeMs"` // From the start of the run to the exit
+}
+
+// Flag is a team's flag in capture the flag
+type Flag struct {
+	Team     int      `json:"team"`
+	X        int      `json:"x"` // Where it is now, following its carrier
+	Y        int      `json:"y"`
+	Z        int      `json:"z,omitempty"`
+	Base     Position `json:"base"`               // Where it goes home to, and where its team captures
+	Carrier  string   `json:"carrier,omitempty"`  // Player carrying it
+	Home     bool     `json:"home,omitempty"`     // At its base
+	ReturnIn float64  `json:"returnIn,omitempty"` // Seconds until a dropped flag goes home by itself
+	Captures int      `json:"captures"`           // Enemy flags its team has captured this round
+}
+
+// Position is a cell coordinate
+type Position struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	Z int `json:"z,omitempty"` // Floor
 }
 
 // Cell represents a maze cell
 type Cell struct {
-	X      int  `json:"x"`
-	Y      int  `json:"y"`
-	Top    bool `json:"top"`
-	Right  bool `json:"right"`
-	Bottom bool `json:"bottom"`
-	Left   bool `json:"left"`
+	X          int    `json:"x"`
+	Y          int    `json:"y"`
+	Z          int    `json:"z,omitempty"` // Floor
+	Top        bool   `json:"top"`
+	Right      bool   `json:"right"`
+	Bottom     bool   `json:"bottom"`
+	Left       bool   `json:"left"`
+	StairsUp   bool   `json:"stairsUp,omitempty"`   // Stairs to the same cell on the floor above
+	StairsDown bool   `json:"stairsDown,omitempty"` // Stairs to the same cell on the floor below
+	Terrain    string `json:"terrain,omitempty"`    // "", mud, road, ice (slide on through), portal (come out of the linked one)
+	Under      string `json:"under,omitempty"`      // Crossing cells: axis of the tunnel beneath (horizontal, vertical)
 }
@@ -0,0 +1,589 @@
+package messages
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// MessagePack encoding of the protocol structs. Structs become maps keyed
+// by their JSON field names, and omitempty is honoured, so a msgpack frame
+// decodes to exactly the object its JSON twin would.
+
+var (
+	errShort = errors.New("msgpack: unexpected end of data")
+	errDepth = errors.New("msgpack: exceeded max depth")
+)
+
+// maxDepth caps how deeply arrays and maps may nest. The decoder recurses
+// once per level, so without a cap a small frame of nested arrays could
+// exhaust the stack.
+const maxDepth = 64
+
+// MarshalMsgPack encodes v as MessagePack
+func MarshalMsgPack(v interface{}) ([]byte, error) {
+	e := &mpEncoder{}
+	if err := e.encode(reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+// UnmarshalMsgPack decodes MessagePack into the value v points to. Unknown
+// map keys are skipped.
+func UnmarshalMsgPack(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("msgpack: decode target must be a non-nil pointer")
+	}
+	d := &mpDecoder{data: data}
+	if err := d.decode(rv.Elem(), 0); err != nil {
+		return err
+	}
+	if d.pos != len(d.data) {
+		return fmt.Errorf("msgpack: %d trailing bytes", len(d.data)-d.pos)
+	}
+	return nil
+}
+
+// mpField is a struct field with its JSON name
+type mpField struct {
+	index     int
+	name      string
+	omitempty bool
+}
+
+var fieldCache sync.Map // reflect.Type -> []mpField
+
+func fieldsOf(t reflect.Type) []mpField {
+	if cached, ok := fieldCache.Load(t); ok {
+		return cached.([]mpField)
+	}
+	var fields []mpField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue // Unexported
+		}
+		name, opts := f.Name, ""
+		if tag, ok := f.Tag.Lookup("json"); ok {
+			if tag == "-" {
+				continue
+			}
+			if comma := strings.IndexByte(tag, ','); comma >= 0 {
+				name, opts = tag[:comma], tag[comma:]
+			} else {
+				name = tag
+			}
+			if name == "" {
+				name = f.Name
+			}
+		}
+		fields = append(fields, mpField{index: i, name: name, omitempty: strings.Contains(opts, ",omitempty")})
+	}
+	fieldCache.Store(t, fields)
+	return fields
+}
+
+// isEmpty matches encoding/json's omitempty rules
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map, reflect.String, reflect.Array:
+		return v.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	}
+	return false
+}
+
+type mpEncoder struct {
+	buf []byte
+}
+
+func (e *mpEncoder) encode(v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Invalid:
+		e.buf = append(e.buf, 0xc0)
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			e.buf = append(e.buf, 0xc0)
+			return nil
+		}
+		return e.encode(v.Elem())
+	case reflect.Bool:
+		if v.Bool() {
+			e.buf = append(e.buf, 0xc3)
+		} else {
+			e.buf = append(e.buf, 0xc2)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.int(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		e.uint(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		e.buf = append(e.buf, 0xcb)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, math.Float64bits(v.Float()))
+	case reflect.String:
+		e.str(v.String())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			e.buf = append(e.buf, 0xc0)
+			return nil
+		}
+		e.header(v.Len(), 0x90, 0xdc, 0xdd)
+		for i := 0; i < v.Len(); i++ {
+			if err := e.encode(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		fields := fieldsOf(v.Type())
+		n := 0
+		for _, f := range fields {
+			if !f.omitempty || !isEmpty(v.Field(f.index)) {
+				n++
+			}
+		}
+		e.header(n, 0x80, 0xde, 0xdf)
+		for _, f := range fields {
+			fv := v.Field(f.index)
+			if f.omitempty && isEmpty(fv) {
+				continue
+			}
+			e.str(f.name)
+			if err := e.encode(fv); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("msgpack: cannot encode %s", v.Type())
+	}
+	return nil
+}
+
+// header writes an array or map length: fix format up to 15, then 16 or
+// 32 bits
+func (e *mpEncoder) header(n int, fix, b16, b32 byte) {
+	switch {
+	case n < 16:
+		e.buf = append(e.buf, fix|byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, b16)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, b32)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	}
+}
+
+func (e *mpEncoder) str(s string) {
+	n := len(s)
+	switch {
+	case n < 32:
+		e.buf = append(e.buf, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xda)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, 0xdb)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	}
+	e.buf = append(e.buf, s...)
+}
+
+func (e *mpEncoder) int(n int64) {
+	switch {
+	case n >= 0:
+		e.uint(uint64(n))
+	case n >= -32:
+		e.buf = append(e.buf, byte(n))
+	case n >= math.MinInt8:
+		e.buf = append(e.buf, 0xd0, byte(n))
+	case n >= math.MinInt16:
+		e.buf = append(e.buf, 0xd1)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	case n >= math.MinInt32:
+		e.buf = append(e.buf, 0xd2)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	default:
+		e.buf = append(e.buf, 0xd3)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(n))
+	}
+}
+
+func (e *mpEncoder) uint(n uint64) {
+	switch {
+	case n < 128:
+		e.buf = append(e.buf, byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xcc, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xcd)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	case n <= math.MaxUint32:
+		e.buf = append(e.buf, 0xce)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	default:
+		e.buf = append(e.buf, 0xcf)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, n)
+	}
+}
+
+type mpDecoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *mpDecoder) next(n int) ([]byte, error) {
+	if d.pos+n > len(d.data) {
+		return nil, errShort
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *mpDecoder) byte() (byte, error) {
+	b, err := d.next(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+// uintN reads a big-endian unsigned integer of n bytes
+func (d *mpDecoder) uintN(n int) (uint64, error) {
+	b, err := d.next(n)
+	if err != nil {
+		return 0, err
+	}
+	var u uint64
+	for _, c := range b {
+		u = u<<8 | uint64(c)
+	}
+	return u, nil
+}
+
+func (d *mpDecoder) decode(v reflect.Value, depth int) error {
+	if d.pos >= len(d.data) {
+		return errShort
+	}
+	if depth > maxDepth {
+		return errDepth
+	}
+	if d.data[d.pos] == 0xc0 {
+		d.pos++
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return d.decode(v.Elem(), depth)
+	case reflect.Bool:
+		b, _ := d.byte()
+		switch b {
+		case 0xc2:
+			v.SetBool(false)
+		case 0xc3:
+			v.SetBool(true)
+		default:
+			return fmt.Errorf("msgpack: expected bool, got 0x%02x", b)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := d.number()
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(n))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := d.number()
+		if err != nil {
+			return err
+		}
+		v.SetUint(uint64(n))
+	case reflect.Float32, reflect.Float64:
+		f, err := d.float()
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.String:
+		s, err := d.str()
+		if err != nil {
+			return err
+		}
+		v.SetString(s)
+	case reflect.Slice:
+		n, err := d.length(0x90, 0xdc, 0xdd)
+		if err != nil {
+			return err
+		}
+		slice := reflect.MakeSlice(v.Type(), n, n)
+		for i := 0; i < n; i++ {
+			if err := d.decode(slice.Index(i), depth+1); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	case reflect.Struct:
+		n, err := d.length(0x80, 0xde, 0xdf)
+		if err != nil {
+			return err
+		}
+		fields := fieldsOf(v.Type())
+		for i := 0; i < n; i++ {
+			key, err := d.str()
+			if err != nil {
+				return err
+			}
+			found := false
+			for _, f := range fields {
+				if f.name == key {
+					if err := d.decode(v.Field(f.index), depth+1); err != nil {
+						return fmt.Errorf("%s: %w", key, err)
+					}
+					found = true
+					break
+				}
+			}
+			if !found {
+				if err := d.skip(depth + 1); err != nil {
+					return err
+				}
+			}
+		}
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return fmt.Errorf("msgpack: cannot decode into %s", v.Type())
+		}
+		x, err := d.any(depth)
+		if err != nil {
+			return err
+		}
+		if x == nil {
+			v.Set(reflect.Zero(v.Type()))
+		} else {
+			v.Set(reflect.ValueOf(x))
+		}
+	default:
+		return fmt.Errorf("msgpack: cannot decode into %s", v.Type())
+	}
+	return nil
+}
+
+// any reads a value of any type into the same Go types encoding/json uses
+// for interface{}: bool, float64, string, []interface{} and
+// map[string]interface{}
+func (d *mpDecoder) any(depth int) (interface{}, error) {
+	if d.pos >= len(d.data) {
+		return nil, errShort
+	}
+	if depth > maxDepth {
+		return nil, errDepth
+	}
+	b := d.data[d.pos]
+	switch {
+	case b == 0xc0:
+		d.pos++
+		return nil, nil
+	case b == 0xc2, b == 0xc3:
+		d.pos++
+		return b == 0xc3, nil
+	case b < 0x80, b >= 0xe0, b >= 0xcc && b <= 0xcf, b >= 0xd0 && b <= 0xd3, b == 0xca, b == 0xcb:
+		return d.float()
+	case b&0xe0 == 0xa0, b >= 0xd9 && b <= 0xdb:
+		return d.str()
+	case b&0xf0 == 0x90, b == 0xdc, b == 0xdd:
+		n, err := d.length(0x90, 0xdc, 0xdd)
+		if err != nil {
+			return nil, err
+		}
+		list := make([]interface{}, n)
+		for i := range list {
+			if list[i], err = d.any(depth + 1); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	case b&0xf0 == 0x80, b == 0xde, b == 0xdf:
+		n, err := d.length(0x80, 0xde, 0xdf)
+		if err != nil {
+			return nil, err
+		}
+		m := make(map[string]interface{}, n)
+		for i := 0; i < n; i++ {
+			key, err := d.str()
+			if err != nil {
+				return nil, err
+			}
+			if m[key], err = d.any(depth + 1); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("msgpack: unsupported type 0x%02x", b)
+}
+
+// number reads any integer format as an int64 (uint64 values above
+// MaxInt64 wrap, which no protocol field reaches)
+func (d *mpDecoder) number() (int64, error) {
+	b, err := d.byte()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case b < 0x80:
+		return int64(b), nil
+	case b >= 0xe0:
+		return int64(int8(b)), nil
+	case b >= 0xcc && b <= 0xcf:
+		u, err := d.uintN(1 << (b - 0xcc))
+		return int64(u), err
+	case b >= 0xd0 && b <= 0xd3:
+		size := 1 << (b - 0xd0)
+		u, err := d.uintN(size)
+		shift := 64 - 8*size
+		return int64(u<<shift) >> shift, err // Sign-extend
+	}
+	return 0, fmt.Errorf("msgpack: expected integer, got 0x%02x", b)
+}
+
+func (d *mpDecoder) float() (float64, error) {
+	switch d.data[d.pos] {
+	case 0xca:
+		d.pos++
+		u, err := d.uintN(4)
+		return float64(math.Float32frombits(uint32(u))), err
+	case 0xcb:
+		d.pos++
+		u, err := d.uintN(8)
+		return math.Float64frombits(u), err
+	}
+	n, err := d.number()
+	return float64(n), err
+}
+
+func (d *mpDecoder) str() (string, error) {
+	b, err := d.byte()
+	if err != nil {
+		return "", err
+	}
+	var n uint64
+	switch {
+	case b&0xe0 == 0xa0:
+		n = uint64(b & 0x1f)
+	case b == 0xd9:
+		n, err = d.uintN(1)
+	case b == 0xda:
+		n, err = d.uintN(2)
+	case b == 0xdb:
+		n, err = d.uintN(4)
+	default:
+		return "", fmt.Errorf("msgpack: expected string, got 0x%02x", b)
+	}
+	if err != nil {
+		return "", err
+	}
+	s, err := d.next(int(n))
+	return string(s), err
+}
+
+// length reads an array or map header
+func (d *mpDecoder) length(fix, b16, b32 byte) (int, error) {
+	b, err := d.byte()
+	if err != nil {
+		return 0, err
+	}
+	var n uint64
+	switch {
+	case b&0xf0 == fix:
+		n = uint64(b & 0x0f)
+	case b == b16:
+		n, err = d.uintN(2)
+	case b == b32:
+		n, err = d.uintN(4)
+	default:
+		return 0, fmt.Errorf("msgpack: expected array or map, got 0x%02x", b)
+	}
+	if err == nil && n > uint64(len(d.data)-d.pos) {
+		return 0, errShort // Each element needs at least one byte
+	}
+	return int(n), err
+}
+
+// skip steps over one value of any type
+func (d *mpDecoder) skip(depth int) error {
+	if depth > maxDepth {
+		return errDepth
+	}
+	b, err := d.byte()
+	if err != nil {
+		return err
+	}
+	var size uint64 // Payload bytes to skip
+	items := 0      // Nested values to skip
+	switch {
+	case b < 0x80, b >= 0xe0, b == 0xc0, b == 0xc2, b == 0xc3:
+	case b&0xf0 == 0x80:
+		items = 2 * int(b&0x0f)
+	case b&0xf0 == 0x90:
+		items = int(b & 0x0f)
+	case b&0xe0 == 0xa0:
+		size = uint64(b & 0x1f)
+	case b == 0xc4, b == 0xd9: // bin8, str8
+		size, err = d.uintN(1)
+	case b == 0xc5, b == 0xda:
+		size, err = d.uintN(2)
+	case b == 0xc6, b == 0xdb:
+		size, err = d.uintN(4)
+	case b == 0xcc, b == 0xd0:
+		size = 1
+	case b == 0xcd, b == 0xd1:
+		size = 2
+	case b == 0xca, b == 0xce, b == 0xd2:
+		size = 4
+	case b == 0xcb, b == 0xcf, b == 0xd3:
+		size = 8
+	case b == 0xdc, b == 0xdd:
+		var n uint64
+		n, err = d.uintN(2 << (b - 0xdc))
+		items = int(n)
+	case b == 0xde, b == 0xdf:
+		var n uint64
+		n, err = d.uintN(2 << (b - 0xde))
+		items = 2 * int(n)
+	default:
+		return fmt.Errorf("msgpack: unsupported type 0x%02x", b)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := d.next(int(size)); err != nil {
+		return err
+	}
+	for i := 0; i < items; i++ {
+		if err := d.skip(depth + 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package messages
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestMsgPackRoundTrip encodes a message with strings, negative and large
+// numbers, floats, lists and a nested struct, and decodes it back
+// unchanged
+func TestMsgPackRoundTrip(t *testing.T) {
+	in := ClientMessage{
+		Type:   "uploadMaze",
+		Seq:    1 << 40,
+		X:      -3,
+		Y:      200,
+		Caps:   []string{"compactMaze", "floors"},
+		Speed:  1.5,
+		Text:   strings.Repeat("long ", 20),
+		Layout: &MazeData{Width: 2, Height: 1},
+	}
+	data, err := MarshalMsgPack(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out ClientMessage
+	if err := UnmarshalMsgPack(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("decoded %+v, want %+v", out, in)
+	}
+}
+
+// TestMsgPackMatchesJSON decodes a frame into interface{} and gets the
+// same value encoding/json does for the JSON twin
+func TestMsgPackMatchesJSON(t *testing.T) {
+	in := ClientMessage{Type: "join", RoomID: "lobby", X: 4, Caps: []string{"fog"}}
+	data, err := MarshalMsgPack(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got interface{}
+	if err := UnmarshalMsgPack(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := json.Marshal(in)
+	var want interface{}
+	json.Unmarshal(raw, &want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("decoded %v, want %v", got, want)
+	}
+}
+
+// TestMsgPackUnknownKeys skips keys the struct doesn't have, whatever
+// their type
+func TestMsgPackUnknownKeys(t *testing.T) {
+	data := []byte{0x83,
+		0xa4, 't', 'y', 'p', 'e', 0xa4, 'm', 'o', 'v', 'e',
+		0xa5, 'e', 'x', 't', 'r', 'a', 0x92, 0xcd, 0x01, 0x00, 0x81, 0xa1, 'k', 0xc3,
+		0xa1, 'x', 0x05,
+	}
+	var msg ClientMessage
+	if err := UnmarshalMsgPack(data, &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Type != "move" || msg.X != 5 {
+		t.Errorf("decoded %+v", msg)
+	}
+}
+
+// TestMsgPackErrors turns away truncated, mistyped and oversized frames
+// instead of decoding part of them
+func TestMsgPackErrors(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"empty":     {},
+		"truncated": {0x81, 0xa4, 't', 'y', 'p'},
+		"trailing":  {0x80, 0x00},
+		"wrongType": {0x81, 0xa4, 't', 'y', 'p', 'e', 0x05},
+		"notMap":    {0x92, 0x01, 0x02},
+		"badLength": {0xdf, 0xff, 0xff, 0xff, 0xff},
+		"badCode":   {0x81, 0xa1, 'z', 0xc1},
+	} {
+		var msg ClientMessage
+		if err := UnmarshalMsgPack(data, &msg); err == nil {
+			t.Errorf("%s: decoded %+v", name, msg)
+		}
+	}
+}
+
+// TestMsgPackDepth refuses deeply nested frames, both under an unknown key
+// (skipped) and into interface{}, rather than recursing until the stack
+// runs out
+func TestMsgPackDepth(t *testing.T) {
+	const depth = 1 << 20
+	var nested bytes.Buffer
+	nested.Write(bytes.Repeat([]byte{0x91}, depth))
+	nested.WriteByte(0xc0)
+
+	frame := append([]byte{0x81, 0xa2, 'z', 'z'}, nested.Bytes()...)
+	var msg ClientMessage
+	if err := UnmarshalMsgPack(frame, &msg); err != errDepth {
+		t.Errorf("unknown key: got %v, want %v", err, errDepth)
+	}
+	var v interface{}
+	if err := UnmarshalMsgPack(nested.Bytes(), &v); err != errDepth {
+		t.Errorf("interface{}: got %v, want %v", err, errDepth)
+	}
+
+	// Nesting up to the cap is fine
+	ok := append(bytes.Repeat([]byte{0x91}, maxDepth), 0xc0)
+	if err := UnmarshalMsgPack(ok, &v); err != nil {
+		t.Errorf("%d levels: %v", maxDepth, err)
+	}
+}
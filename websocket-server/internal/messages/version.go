@@ -0,0 +1,78 @@
+package messages
+
+import "slices"
+
+// Protocol versions. The version goes up whenever server messages gain a
+// type, state or field meaning that clients written earlier would trip
+// over, and a shim translates them back down for those clients:
+//
+//	1  the protocol before versions were declared
+//	2  matches pause: the paused state, and matchPaused, matchResuming and
+//	   matchResumed
+const (
+	ProtocolVersion    = 2 // The version the server speaks
+	MinProtocolVersion = 1 // The oldest version it still translates for
+)
+
+// shim translates a message of its version into what the version before
+// understood, or reports false if that version has no equivalent
+type shim struct {
+	version int
+	down    func(ServerMessage) (ServerMessage, bool)
+}
+
+// shims are newest first, so a message is walked down one version at a time
+var shims = []shim{
+	{2, withoutPauses},
+}
+
+// Downgrade translates a message and its batch for a client on an older
+// protocol version. It reports false if the message has no equivalent in
+// that version, so the client shouldn't be sent it. Messages shared with
+// other recipients are copied, not changed.
+func Downgrade(msg ServerMessage, version int) (ServerMessage, bool) {
+	for _, s := range shims {
+		if s.version <= version {
+			break
+		}
+		var ok bool
+		if msg, ok = s.down(msg); !ok {
+			return msg, false
+		}
+	}
+	if len(msg.Batch) > 0 {
+		batch := make([]ServerMessage, 0, len(msg.Batch))
+		for _, m := range msg.Batch {
+			if m, ok := Downgrade(m, version); ok {
+				batch = append(batch, m)
+			}
+		}
+		msg.Batch = batch
+	}
+	return msg, true
+}
+
+// withoutPauses shows version 1 clients a paused match as a countdown,
+// which they already hold moves through, ending in the usual Go
+func withoutPauses(msg ServerMessage) (ServerMessage, bool) {
+	switch msg.Type {
+	case "matchPaused":
+		return ServerMessage{Type: "countdown", State: "countdown", Message: "Paused"}, true
+	case "matchResuming":
+		return msg, false
+	case "matchResumed":
+		return ServerMessage{Type: "countdown", State: "playing", Message: "Go!"}, true
+	}
+	if msg.State == "paused" {
+		msg.State = "countdown"
+	}
+	if slices.ContainsFunc(msg.Rooms, func(info RoomInfo) bool { return info.State == "paused" }) {
+		msg.Rooms = slices.Clone(msg.Rooms)
+		for i := range msg.Rooms {
+			if msg.Rooms[i].State == "paused" {
+				msg.Rooms[i].State = "playing"
+			}
+		}
+	}
+	return msg, true
+}
@@ -0,0 +1,131 @@
+// Package metrics keeps server-wide traffic counters for the admin
+// dashboard: live connections, messages and bytes in each direction with
+// their recent per-second rates, and WebSocket upgrades by origin.
+package metrics
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Window is how many seconds rates are averaged over
+const Window = 10
+
+// Metrics counts the server's traffic. The zero value is ready to use.
+type Metrics struct {
+	started     time.Time
+	connections atomic.Int64
+	messagesIn  counter
+	messagesOut counter
+	bytesIn     counter
+	bytesOut    counter
+	origins     origins
+}
+
+// New creates metrics whose uptime starts now
+func New() *Metrics {
+	return &Metrics{started: time.Now()}
+}
+
+// Connected counts a new connection
+func (m *Metrics) Connected() {
+	m.connections.Add(1)
+}
+
+// Disconnected counts a closed connection
+func (m *Metrics) Disconnected() {
+	m.connections.Add(-1)
+}
+
+// Received counts one inbound message of the given size
+func (m *Metrics) Received(bytes int) {
+	now := time.Now()
+	m.messagesIn.add(now, 1)
+	m.bytesIn.add(now, uint64(bytes))
+}
+
+// Sent counts one outbound message of the given size
+func (m *Metrics) Sent(bytes int) {
+	now := time.Now()
+	m.messagesOut.add(now, 1)
+	m.bytesOut.add(now, uint64(bytes))
+}
+
+// Snapshot is a point-in-time copy of the counters
+type Snapshot struct {
+	Uptime            float64 `json:"uptime"` // Seconds
+	Connections       int64   `json:"connections"`
+	MessagesIn        uint64  `json:"messagesIn"`
+	MessagesOut       uint64  `json:"messagesOut"`
+	BytesIn           uint64  `json:"bytesIn"`
+	BytesOut          uint64  `json:"bytesOut"`
+	MessagesInPerSec  float64 `json:"messagesInPerSec"`
+	MessagesOutPerSec float64 `json:"messagesOutPerSec"`
+	BytesInPerSec     float64 `json:"bytesInPerSec"`
+	BytesOutPerSec    float64 `json:"bytesOutPerSec"`
+
+	Origins map[string]OriginStats `json:"origins"` // WebSocket upgrades by Origin header
+}
+
+// Snapshot reads every counter
+func (m *Metrics) Snapshot() Snapshot {
+	now := time.Now()
+	s := Snapshot{
+		Uptime:      now.Sub(m.started).Seconds(),
+		Connections: m.connections.Load(),
+		Origins:     m.origins.snapshot(),
+	}
+	s.MessagesIn, s.MessagesInPerSec = m.messagesIn.read(now)
+	s.MessagesOut, s.MessagesOutPerSec = m.messagesOut.read(now)
+	s.BytesIn, s.BytesInPerSec = m.bytesIn.read(now)
+	s.BytesOut, s.BytesOutPerSec = m.bytesOut.read(now)
+	return s
+}
+
+// counter is a running total with one-second buckets for its rate
+type counter struct {
+	total   uint64
+	buckets [Window]uint64
+	second  int64 // Unix second of the newest bucket
+	mu      sync.Mutex
+}
+
+func (c *counter) add(now time.Time, n uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.advance(now.Unix())
+	c.total += n
+	c.buckets[c.second%Window] += n
+}
+
+// read returns the total and the average per second over the last Window
+// whole seconds
+func (c *counter) read(now time.Time) (uint64, float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.advance(now.Unix())
+
+	var sum uint64
+	for i, n := range c.buckets {
+		if int64(i) != c.second%Window { // The current second is still filling
+			sum += n
+		}
+	}
+	return c.total, float64(sum) / (Window - 1)
+}
+
+// advance moves the newest bucket up to sec, clearing the seconds skipped
+func (c *counter) advance(sec int64) {
+	if sec <= c.second {
+		return
+	}
+	gap := sec - c.second
+	if gap > Window {
+		gap = Window
+	}
+	for i := int64(0); i < gap; i++ {
+		c.buckets[(sec-i)%Window] = 0
+	}
+	c.second = sec
+}
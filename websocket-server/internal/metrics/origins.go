@@ -0,0 +1,67 @@
+package metrics
+
+import "sync"
+
+// MaxOrigins bounds how many origins are counted separately; connections
+// from any others are counted under OtherOrigin, so a flood of made-up
+// origins can't grow the table without limit
+const MaxOrigins = 100
+
+// Keys in Snapshot.Origins for connections not counted under their own
+// origin
+const (
+	NoOrigin    = "(none)"  // No Origin header: not a browser
+	OtherOrigin = "(other)" // Past MaxOrigins
+)
+
+// OriginStats counts the WebSocket upgrades from one origin
+type OriginStats struct {
+	Accepted uint64 `json:"accepted"`
+	Rejected uint64 `json:"rejected"`
+}
+
+// origins counts upgrades by origin
+type origins struct {
+	counts map[string]*OriginStats
+	mu     sync.Mutex
+}
+
+// Upgraded counts a WebSocket upgrade from origin, accepted or rejected by
+// the origin policy
+func (m *Metrics) Upgraded(origin string, accepted bool) {
+	m.origins.mu.Lock()
+	defer m.origins.mu.Unlock()
+
+	if m.origins.counts == nil {
+		m.origins.counts = make(map[string]*OriginStats)
+	}
+	if origin == "" {
+		origin = NoOrigin
+	}
+	stats, ok := m.origins.counts[origin]
+	if !ok {
+		if len(m.origins.counts) >= MaxOrigins {
+			origin = OtherOrigin
+		}
+		if stats, ok = m.origins.counts[origin]; !ok {
+			stats = &OriginStats{}
+			m.origins.counts[origin] = stats
+		}
+	}
+	if accepted {
+		stats.Accepted++
+	} else {
+		stats.Rejected++
+	}
+}
+
+// snapshot copies the counts
+func (o *origins) snapshot() map[string]OriginStats {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	counts := make(map[string]OriginStats, len(o.counts))
+	for origin, stats := range o.counts {
+		counts[origin] = *stats
+	}
+	return counts
+}
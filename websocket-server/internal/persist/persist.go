@@ -0,0 +1,18 @@
+// Package persist keeps saved rooms somewhere that outlives the process,
+// so a restarted server can bring back the games that were running.
+package persist
+
+import "labyrinth-duel/websocket/internal/room"
+
+// Store keeps the latest save of each room
+type Store interface {
+	// Save stores a room, replacing any earlier save of it
+	Save(r *room.SavedRoom) error
+	// Delete forgets a room; deleting an unknown room is not an error
+	Delete(id string) error
+	// Get returns the save of one room, if there is one
+	Get(id string) (*room.SavedRoom, bool, error)
+	// Load returns every saved room. Saves that can't be read are logged
+	// and skipped, so one bad save doesn't keep the others from coming back
+	Load() ([]*room.SavedRoom, error)
+}
@@ -0,0 +1,245 @@
+package persist
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"labyrinth-duel/websocket/internal/datafile"
+	"labyrinth-duel/websocket/internal/redis"
+	"labyrinth-duel/websocket/internal/room"
+)
+
+// MemoryStore keeps saved rooms in memory: they survive a server being
+// replaced within the process, but not a restart
+type MemoryStore struct {
+	rooms map[string][]byte
+	mu    sync.RWMutex
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{rooms: make(map[string][]byte)}
+}
+
+// Save implements Store. Rooms are kept encoded, so later changes to the
+// saved value don't leak in.
+func (s *MemoryStore) Save(r *room.SavedRoom) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rooms[r.ID] = data
+	return nil
+}
+
+// Delete implements Store
+func (s *MemoryStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.rooms, id)
+	return nil
+}
+
+// Get implements Store
+func (s *MemoryStore) Get(id string) (*room.SavedRoom, bool, error) {
+	s.mu.RLock()
+	data, ok := s.rooms[id]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, false, nil
+	}
+	r, err := decode(data)
+	return r, err == nil, err
+}
+
+// Load implements Store
+func (s *MemoryStore) Load() ([]*room.SavedRoom, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rooms := make([]*room.SavedRoom, 0, len(s.rooms))
+	for id, data := range s.rooms {
+		r, err := decode(data)
+		if err != nil {
+			slog.Error("Skipping unreadable saved room", "room", id, "err", err)
+			continue
+		}
+		rooms = append(rooms, r)
+	}
+	return rooms, nil
+}
+
+// FileStore writes each room to its own JSON file in a directory, readable
+// only by the server's user
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore keeps rooms in dir, creating it if needed
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := datafile.MkdirAll(dir); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Save implements Store
+func (s *FileStore) Save(r *room.SavedRoom) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return datafile.Write(s.path(r.ID), data)
+}
+
+// Delete implements Store
+func (s *FileStore) Delete(id string) error {
+	err := os.Remove(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Get implements Store
+func (s *FileStore) Get(id string) (*room.SavedRoom, bool, error) {
+	data, err := os.ReadFile(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	r, err := decode(data)
+	return r, err == nil, err
+}
+
+// Load implements Store. Files that can't be read are renamed to
+// <name>.bad, out of the way of later loads but kept for a look.
+func (s *FileStore) Load() ([]*room.SavedRoom, error) {
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	rooms := make([]*room.SavedRoom, 0, len(files))
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err == nil {
+			var r *room.SavedRoom
+			if r, err = decode(data); err == nil {
+				rooms = append(rooms, r)
+				continue
+			}
+		}
+		slog.Error("Skipping unreadable saved room", "file", f, "err", err)
+		if err := os.Rename(f, f+".bad"); err != nil {
+			slog.Error("Cannot set aside saved room", "file", f, "err", err)
+		}
+	}
+	return rooms, nil
+}
+
+// path names a room's file. Room IDs are chosen by players, so they are
+// hex-encoded to keep them inside dir.
+func (s *FileStore) path(id string) string {
+	return filepath.Join(s.dir, hex.EncodeToString([]byte(id))+".json")
+}
+
+// DefaultRedisPrefix namespaces the keys RedisStore uses
+const DefaultRedisPrefix = "maze:"
+
+// RedisStore keeps each room as a JSON string under <prefix>room:<id>,
+// with the IDs in the set <prefix>rooms
+type RedisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisStore keeps rooms in the Redis at addr (see redis.New), under
+// prefix (e.g. DefaultRedisPrefix)
+func NewRedisStore(addr, prefix string) (*RedisStore, error) {
+	client, err := redis.New(addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &RedisStore{client: client, prefix: prefix}
+	if _, err := client.Do("PING"); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Save implements Store
+func (s *RedisStore) Save(r *room.SavedRoom) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if _, err := s.client.Do("SET", s.key(r.ID), string(data)); err != nil {
+		return err
+	}
+	_, err = s.client.Do("SADD", s.prefix+"rooms", r.ID)
+	return err
+}
+
+// Delete implements Store
+func (s *RedisStore) Delete(id string) error {
+	if _, err := s.client.Do("DEL", s.key(id)); err != nil {
+		return err
+	}
+	_, err := s.client.Do("SREM", s.prefix+"rooms", id)
+	return err
+}
+
+// Get implements Store
+func (s *RedisStore) Get(id string) (*room.SavedRoom, bool, error) {
+	data, ok, err := redis.String(s.client.Do("GET", s.key(id)))
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	r, err := decode([]byte(data))
+	return r, err == nil, err
+}
+
+// Load implements Store. IDs whose room has gone missing are skipped.
+func (s *RedisStore) Load() ([]*room.SavedRoom, error) {
+	ids, err := redis.Strings(s.client.Do("SMEMBERS", s.prefix+"rooms"))
+	if err != nil {
+		return nil, err
+	}
+	rooms := make([]*room.SavedRoom, 0, len(ids))
+	for _, id := range ids {
+		data, ok, err := redis.String(s.client.Do("GET", s.key(id)))
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		r, err := decode([]byte(data))
+		if err != nil {
+			slog.Error("Skipping unreadable saved room", "room", id, "err", err)
+			continue
+		}
+		rooms = append(rooms, r)
+	}
+	return rooms, nil
+}
+
+func (s *RedisStore) key(id string) string {
+	return s.prefix + "room:" + id
+}
+
+func decode(data []byte) (*room.SavedRoom, error) {
+	var r room.SavedRoom
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
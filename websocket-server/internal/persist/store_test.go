@@ -0,0 +1,70 @@
+package persist
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"labyrinth-duel/websocket/internal/room"
+)
+
+// TestFileStore saves two rooms, one with a password, next to a corrupt
+// save: the files are private and hold no password, and loading brings
+// back both rooms, still needing the password, and sets the corrupt file
+// aside
+func TestFileStore(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := room.NewManager()
+	m.GetOrCreateRoom("open", room.Options{})
+	m.GetOrCreateRoom("locked", room.Options{Access: room.Access{Password: "hunter2"}})
+	for _, sr := range m.SaveAll() {
+		if err := s.Save(sr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	corrupt := filepath.Join(dir, "corrupt.json")
+	if err := os.WriteFile(corrupt, []byte("{not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	for _, f := range files {
+		info, err := os.Stat(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mode := info.Mode().Perm(); mode != 0o600 {
+			t.Errorf("%s has mode %o, want 600", f, mode)
+		}
+		if data, _ := os.ReadFile(f); strings.Contains(string(data), "hunter2") {
+			t.Errorf("%s holds the room password", f)
+		}
+	}
+
+	rooms, err := s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rooms) != 2 {
+		t.Fatalf("loaded %d rooms, want the 2 readable ones", len(rooms))
+	}
+	if _, err := os.Stat(corrupt + ".bad"); err != nil {
+		t.Errorf("corrupt save not set aside: %v", err)
+	}
+	restored := room.NewManager()
+	for _, sr := range rooms {
+		restored.Restore(sr)
+	}
+	locked := restored.GetRoom("locked")
+	if err := locked.Join("guest", nil, room.PlayerProfile{}, "", "hunter3"); err != room.ErrBadPassword {
+		t.Errorf("joined with the wrong password: %v", err)
+	}
+	if err := locked.Join("guest", nil, room.PlayerProfile{}, "", "hunter2"); err != nil {
+		t.Errorf("joining with the password: %v", err)
+	}
+}
@@ -0,0 +1,317 @@
+package profile
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+// MaxNameLength caps display names, in characters
+const MaxNameLength = 20
+
+// Errors returned by Update
+var (
+	ErrBadName  = errors.New("name must be 1-20 characters")
+	ErrBadColor = errors.New("color must look like #rrggbb")
+)
+
+var colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// defaultColors are handed out to new players by hashing their ID
+var defaultColors = []string{"#e6194b", "#3cb44b", "#4363d8", "#f58231", "#911eb4", "#42d4f4", "#f032e6", "#bfef45"}
+
+// Stats are a player's lifetime results
+type Stats struct {
+	Matches   int
+	Wins      int
+	BestScore int
+	Solves    int           // Wins by reaching the exit
+	SolveTime time.Duration // Total time those took, for the average
+}
+
+// Offence is a kind of unsporting behaviour that earns a matchmaking
+// cooldown when repeated
+type Offence string
+
+const (
+	OffenceDecline Offence = "decline" // Declined, or let time out, a proposed ranked match
+	OffenceAbandon Offence = "abandon" // Left a ranked match before it finished
+)
+
+// OffenceDecay is how long a player must keep out of trouble for their
+// offences to be forgotten
+const OffenceDecay = 24 * time.Hour
+
+// Cooldowns is the matchmaking cooldown for each offence in a row; the
+// first is let off, and the last applies to every one after it
+var Cooldowns = []time.Duration{0, 2 * time.Minute, 10 * time.Minute, 30 * time.Minute, 2 * time.Hour}
+
+// Conduct is a player's record of offences
+type Conduct struct {
+	Declines      int
+	Abandons      int
+	Offences      int // In a row, i.e. since the last OffenceDecay-long clean spell
+	LastOffence   time.Time
+	CooldownUntil time.Time // No matchmaking before then
+}
+
+// Profile is what other players see of someone, keyed by their persistent
+// player ID
+type Profile struct {
+	ID     string
+	Name   string
+	Color  string
+	Avatar string
+	Stats  Stats
+	// Verified is set once the player connects with a verified token; from
+	// then on only tokens can connect as them
+	Verified bool
+	// TutorialDone is set once the player finishes the tutorial
+	TutorialDone bool
+	// ResumeHash is the hash of the secret needed to connect as the player
+	// again. The ID is public; the secret is only ever sent to the player.
+	ResumeHash string
+	// Conduct is private to the server and the player
+	Conduct Conduct
+}
+
+// Store keeps every known profile in memory
+type Store struct {
+	profiles map[string]*Profile
+	mu       sync.RWMutex
+}
+
+// NewStore creates an empty profile store
+func NewStore() *Store {
+	return &Store{
+		profiles: make(map[string]*Profile),
+	}
+}
+
+// Get returns a player's profile, creating a default one for new IDs
+func (s *Store) Get(id string) Profile {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *s.getLocked(id)
+}
+
+func (s *Store) getLocked(id string) *Profile {
+	p, ok := s.profiles[id]
+	if !ok {
+		h := fnv.New32a()
+		h.Write([]byte(id))
+		p = &Profile{
+			ID:    id,
+			Name:  fmt.Sprintf("Player-%.4s", id),
+			Color: defaultColors[h.Sum32()%uint32(len(defaultColors))],
+		}
+		s.profiles[id] = p
+	}
+	return p
+}
+
+// Update changes a player's name, color and avatar. Empty fields are left
+// as they were.
+func (s *Store) Update(id, name, color, avatar string) (Profile, error) {
+	if name != "" && utf8.RuneCountInString(name) > MaxNameLength {
+		return Profile{}, ErrBadName
+	}
+	if color != "" && !colorPattern.MatchString(color) {
+		return Profile{}, ErrBadColor
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.getLocked(id)
+	if name != "" {
+		p.Name = name
+	}
+	if color != "" {
+		p.Color = color
+	}
+	if avatar != "" {
+		p.Avatar = avatar
+	}
+	return *p, nil
+}
+
+// Verify marks a profile as a token-verified identity, taking the name the
+// token gives, if any and valid
+func (s *Store) Verify(id, name string) Profile {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.getLocked(id)
+	p.Verified = true
+	if name != "" && utf8.RuneCountInString(name) <= MaxNameLength {
+		p.Name = name
+	}
+	return *p
+}
+
+// IsVerified reports whether a player ID belongs to a token-verified
+// identity, without creating a profile for unknown IDs
+func (s *Store) IsVerified(id string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p, ok := s.profiles[id]
+	return ok && p.Verified
+}
+
+// IssueSecret gives a player a new random resume secret, replacing any
+// earlier one, and returns it. Only its hash is kept.
+func (s *Store) IssueSecret(id string) string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	secret := hex.EncodeToString(b)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.getLocked(id).ResumeHash = HashSecret(secret)
+	return secret
+}
+
+// CheckSecret reports whether secret is the one last issued to a player,
+// without creating a profile for unknown IDs
+func (s *Store) CheckSecret(id, secret string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p, ok := s.profiles[id]
+	if !ok || p.ResumeHash == "" || secret == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(p.ResumeHash), []byte(HashSecret(secret))) == 1
+}
+
+// ResumeHash returns the hash of a player's resume secret, "" if they have
+// none, for saving with the rooms they are in
+func (s *Store) ResumeHash(id string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if p, ok := s.profiles[id]; ok {
+		return p.ResumeHash
+	}
+	return ""
+}
+
+// RestoreResumeHash puts back a saved resume hash, e.g. after a restart,
+// unless the player has been issued a secret since
+func (s *Store) RestoreResumeHash(id, hash string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p := s.getLocked(id); p.ResumeHash == "" {
+		p.ResumeHash = hash
+	}
+}
+
+// HashSecret is the hash a resume secret is kept as
+func HashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// CompleteTutorial marks a player as having finished the tutorial
+func (s *Store) CompleteTutorial(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.getLocked(id).TutorialDone = true
+}
+
+// RecordResult adds a finished match to a player's stats. solve is how
+// long they took to win it by reaching the exit, 0 if they didn't.
+func (s *Store) RecordResult(id string, won bool, score int, solve time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.getLocked(id)
+	p.Stats.Matches++
+	if won {
+		p.Stats.Wins++
+	}
+	if score > p.Stats.BestScore {
+		p.Stats.BestScore = score
+	}
+	if solve > 0 {
+		p.Stats.Solves++
+		p.Stats.SolveTime += solve
+	}
+}
+
+// RecordOffence notes an offence and returns the matchmaking cooldown it
+// earns, if any
+func (s *Store) RecordOffence(id string, offence Offence, now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := &s.getLocked(id).Conduct
+	switch offence {
+	case OffenceDecline:
+		c.Declines++
+	case OffenceAbandon:
+		c.Abandons++
+	}
+	if now.Sub(c.LastOffence) >= OffenceDecay {
+		c.Offences = 0
+	}
+	c.Offences++
+	c.LastOffence = now
+
+	cooldown := Cooldowns[len(Cooldowns)-1]
+	if c.Offences <= len(Cooldowns) {
+		cooldown = Cooldowns[c.Offences-1]
+	}
+	if until := now.Add(cooldown); until.After(c.CooldownUntil) {
+		c.CooldownUntil = until
+	}
+	return cooldown
+}
+
+// Cooldown returns how long a player must still wait before matchmaking
+func (s *Store) Cooldown(id string, now time.Time) time.Duration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	p, ok := s.profiles[id]
+	if !ok || !p.Conduct.CooldownUntil.After(now) {
+		return 0
+	}
+	return p.Conduct.CooldownUntil.Sub(now)
+}
+
+// ToMessage converts a profile to its wire format
+func (p Profile) ToMessage() *messages.Profile {
+	var winRate float64
+	if p.Stats.Matches > 0 {
+		winRate = float64(p.Stats.Wins) / float64(p.Stats.Matches)
+	}
+	var averageSolve time.Duration
+	if p.Stats.Solves > 0 {
+		averageSolve = p.Stats.SolveTime / time.Duration(p.Stats.Solves)
+	}
+	return &messages.Profile{
+		ID:           p.ID,
+		Name:         p.Name,
+		Color:        p.Color,
+		Avatar:       p.Avatar,
+		Verified:     p.Verified,
+		TutorialDone: p.TutorialDone,
+		Stats: messages.ProfileStats{
+			Matches:    p.Stats.Matches,
+			Wins:       p.Stats.Wins,
+			BestScore:  p.Stats.BestScore,
+			WinRate:    winRate,
+			AvgSolveMs: averageSolve.Milliseconds(),
+		},
+	}
+}
@@ -0,0 +1,89 @@
+package rating
+
+import (
+	"math"
+	"sync"
+)
+
+const (
+	// DefaultRating is where every new player starts
+	DefaultRating = 1200
+	// KFactor is the most a single pairing can move a rating
+	KFactor = 32
+)
+
+// Store persists ratings. Implementations must be safe for concurrent use.
+type Store interface {
+	// Load returns a player's rating, or false if they have none yet
+	Load(playerID string) (int, bool, error)
+	// Save records a player's new rating
+	Save(playerID string, rating int) error
+}
+
+// Ratings computes Elo ratings on top of a Store
+type Ratings struct {
+	store Store
+	mu    sync.Mutex // Serialises read-modify-write updates
+}
+
+// New creates a rating service backed by the given store
+func New(store Store) *Ratings {
+	return &Ratings{store: store}
+}
+
+// Get returns a player's rating, or DefaultRating if unknown
+func (r *Ratings) Get(playerID string) int {
+	rating, ok, err := r.store.Load(playerID)
+	if err != nil || !ok {
+		return DefaultRating
+	}
+	return rating
+}
+
+// RecordMatch updates ratings after a match. The winner is scored as
+// beating every other player; with no winner everyone draws with everyone.
+// Returns the new ratings.
+func (r *Ratings) RecordMatch(winnerID string, playerIDs []string) (map[string]int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	before := make(map[string]int, len(playerIDs))
+	for _, id := range playerIDs {
+		before[id] = r.Get(id)
+	}
+
+	delta := make(map[string]float64, len(playerIDs))
+	for i, a := range playerIDs {
+		for _, b := range playerIDs[i+1:] {
+			var scoreA float64
+			switch winnerID {
+			case a:
+				scoreA = 1
+			case b:
+				scoreA = 0
+			case "":
+				scoreA = 0.5
+			default:
+				// Neither won: the pairing says nothing about them
+				continue
+			}
+			change := KFactor * (scoreA - expected(before[a], before[b]))
+			delta[a] += change
+			delta[b] -= change
+		}
+	}
+
+	after := make(map[string]int, len(playerIDs))
+	for _, id := range playerIDs {
+		after[id] = before[id] + int(math.Round(delta[id]))
+		if err := r.store.Save(id, after[id]); err != nil {
+			return after, err
+		}
+	}
+	return after, nil
+}
+
+// expected is the Elo win probability of a player rated a against b
+func expected(a, b int) float64 {
+	return 1 / (1 + math.Pow(10, float64(b-a)/400))
+}
@@ -0,0 +1,79 @@
+package rating
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+
+	"labyrinth-duel/websocket/internal/datafile"
+)
+
+// MemoryStore keeps ratings in memory; they are lost on restart
+type MemoryStore struct {
+	ratings map[string]int
+	mu      sync.RWMutex
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{ratings: make(map[string]int)}
+}
+
+// Load implements Store
+func (s *MemoryStore) Load(playerID string) (int, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rating, ok := s.ratings[playerID]
+	return rating, ok, nil
+}
+
+// Save implements Store
+func (s *MemoryStore) Save(playerID string, rating int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ratings[playerID] = rating
+	return nil
+}
+
+// FileStore keeps ratings in memory and writes them all to a JSON file on
+// every save
+type FileStore struct {
+	path string
+	mem  *MemoryStore
+}
+
+// NewFileStore loads ratings from path, which need not exist yet
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{path: path, mem: NewMemoryStore()}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.mem.ratings); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Load implements Store
+func (s *FileStore) Load(playerID string) (int, bool, error) {
+	return s.mem.Load(playerID)
+}
+
+// Save implements Store
+func (s *FileStore) Save(playerID string, rating int) error {
+	s.mem.mu.Lock()
+	defer s.mem.mu.Unlock()
+
+	s.mem.ratings[playerID] = rating
+	data, err := json.MarshalIndent(s.mem.ratings, "", "  ")
+	if err != nil {
+		return err
+	}
+	return datafile.Write(s.path, data)
+}
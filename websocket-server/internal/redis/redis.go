@@ -0,0 +1,304 @@
+// Package redis is a minimal Redis client speaking RESP over TCP: enough
+// for the key/value, set and pub/sub commands the server uses, without
+// pulling in a driver.
+package redis
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DialTimeout bounds connecting to the server
+const DialTimeout = 5 * time.Second
+
+// CommandTimeout bounds writing a command and reading its reply; a server
+// that takes longer is treated as gone and the connection redialled
+const CommandTimeout = 5 * time.Second
+
+// Error is an error reply from the server
+type Error string
+
+func (e Error) Error() string { return string(e) }
+
+// ErrProtocol is returned for replies the client can't parse
+var ErrProtocol = errors.New("redis: malformed reply")
+
+// Client runs commands over a single connection, one at a time. A broken
+// connection is redialled on the next command.
+type Client struct {
+	addr     string
+	password string
+	db       int
+
+	conn net.Conn
+	r    *bufio.Reader
+	mu   sync.Mutex
+}
+
+// New returns a client for addr, given as host:port or as a URL
+// redis://[:password@]host:port[/db]. It connects on first use.
+func New(addr string) (*Client, error) {
+	c := &Client{addr: addr}
+	if !strings.HasPrefix(addr, "redis://") {
+		return c, nil
+	}
+
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, err
+	}
+	c.addr = u.Host
+	if p, ok := u.User.Password(); ok {
+		c.password = p
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if c.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("redis: bad database %q", db)
+		}
+	}
+	return c, nil
+}
+
+// Do sends a command and returns its reply: a string for simple and bulk
+// strings, int64 for integers, []interface{} for arrays, and nil for a nil
+// reply. An error reply is returned as an Error.
+func (c *Client) Do(args ...string) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		if err := c.dialLocked(); err != nil {
+			return nil, err
+		}
+	}
+	reply, err := c.doLocked(args)
+	if err != nil {
+		if _, isReply := err.(Error); !isReply {
+			c.closeLocked()
+		}
+	}
+	return reply, err
+}
+
+// Close drops the connection
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closeLocked()
+}
+
+func (c *Client) dialLocked() error {
+	conn, r, err := c.dial()
+	if err != nil {
+		return err
+	}
+	c.conn, c.r = conn, r
+	return nil
+}
+
+// dial opens a connection, authenticated and on the right database
+func (c *Client) dial() (net.Conn, *bufio.Reader, error) {
+	conn, err := net.DialTimeout("tcp", c.addr, DialTimeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	r := bufio.NewReader(conn)
+
+	if c.password != "" {
+		if _, err := command(conn, r, []string{"AUTH", c.password}); err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+	}
+	if c.db != 0 {
+		if _, err := command(conn, r, []string{"SELECT", strconv.Itoa(c.db)}); err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+	}
+	return conn, r, nil
+}
+
+func (c *Client) closeLocked() error {
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn, c.r = nil, nil
+	return err
+}
+
+func (c *Client) doLocked(args []string) (interface{}, error) {
+	return command(c.conn, c.r, args)
+}
+
+// command writes a command and reads its reply, each within CommandTimeout
+func command(conn net.Conn, r *bufio.Reader, args []string) (interface{}, error) {
+	conn.SetWriteDeadline(time.Now().Add(CommandTimeout))
+	if err := writeCommand(conn, args); err != nil {
+		return nil, err
+	}
+	conn.SetReadDeadline(time.Now().Add(CommandTimeout))
+	return readReply(r)
+}
+
+func writeCommand(w io.Writer, args []string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Subscription is a connection in subscriber mode, receiving the messages
+// published to its channels
+type Subscription struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// Subscribe opens a connection of its own subscribed to the given
+// channels; the client stays usable for other commands
+func (c *Client) Subscribe(channels ...string) (*Subscription, error) {
+	conn, r, err := c.dial()
+	if err != nil {
+		return nil, err
+	}
+	// Messages may be a long time coming, so only the write has a deadline
+	conn.SetWriteDeadline(time.Now().Add(CommandTimeout))
+	if err := writeCommand(conn, append([]string{"SUBSCRIBE"}, channels...)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &Subscription{conn: conn, r: r}, nil
+}
+
+// Receive waits for the next published message. Subscription
+// confirmations are skipped.
+func (s *Subscription) Receive() (channel, payload string, err error) {
+	for {
+		reply, err := readReply(s.r)
+		if err != nil {
+			return "", "", err
+		}
+		push, ok := reply.([]interface{})
+		if !ok || len(push) != 3 {
+			return "", "", ErrProtocol
+		}
+		kind, _ := push[0].(string)
+		if kind != "message" {
+			continue
+		}
+		channel, _ = push[1].(string)
+		payload, _ = push[2].(string)
+		return channel, payload, nil
+	}
+}
+
+// Close ends the subscription
+func (s *Subscription) Close() error {
+	return s.conn.Close()
+}
+
+// readReply parses one RESP reply
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || !strings.HasSuffix(line, "\r\n") {
+		return nil, ErrProtocol
+	}
+	kind, body := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, Error(body)
+	case ':':
+		n, err := strconv.ParseInt(body, 10, 64)
+		if err != nil {
+			return nil, ErrProtocol
+		}
+		return n, nil
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, ErrProtocol
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, ErrProtocol
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			// An error inside an array is a value, not a failed command
+			item, err := readReply(r)
+			if e, isReply := err.(Error); isReply {
+				item, err = e, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	}
+	return nil, ErrProtocol
+}
+
+// String converts a reply to a string, for GET and friends. A nil reply
+// gives ok=false.
+func String(reply interface{}, err error) (string, bool, error) {
+	if err != nil || reply == nil {
+		return "", false, err
+	}
+	s, ok := reply.(string)
+	if !ok {
+		return "", false, ErrProtocol
+	}
+	return s, true, nil
+}
+
+// Strings converts an array reply of strings, for SMEMBERS and friends
+func Strings(reply interface{}, err error) ([]string, error) {
+	if err != nil || reply == nil {
+		return nil, err
+	}
+	items, ok := reply.([]interface{})
+	if !ok {
+		return nil, ErrProtocol
+	}
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		s, ok := item.(string)
+		if !ok {
+			return nil, ErrProtocol
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
@@ -0,0 +1,31 @@
+// Package replay holds recorded matches and the stores that keep them.
+// Rooms record a replay as a match is played; the server saves it when the
+// match finishes and streams it back to watchers on request.
+package replay
+
+import (
+	"errors"
+
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+// ErrNotFound is returned by Store.Get for an unknown replay ID
+var ErrNotFound = errors.New("replay not found")
+
+// Replay is a finished match: the mazes it was played on and every event,
+// timed from the start of play
+type Replay struct {
+	messages.ReplayInfo
+	Mazes  []*messages.MazeData   `json:"mazes"` // One per round, in order
+	Events []messages.ReplayEvent `json:"events"`
+}
+
+// Store keeps replays
+type Store interface {
+	// Save stores a replay under its ID
+	Save(r *Replay) error
+	// Get loads a replay, or returns ErrNotFound
+	Get(id string) (*Replay, error)
+	// List describes every stored replay, newest first
+	List() ([]messages.ReplayInfo, error)
+}
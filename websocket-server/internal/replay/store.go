@@ -0,0 +1,165 @@
+package replay
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"labyrinth-duel/websocket/internal/datafile"
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+// DefaultMemoryCapacity is how many replays a MemoryStore keeps
+const DefaultMemoryCapacity = 100
+
+// MemoryStore keeps the most recent replays in memory; they are lost on
+// restart
+type MemoryStore struct {
+	capacity int
+	replays  map[string]*Replay
+	order    []string // IDs, oldest first
+	mu       sync.RWMutex
+}
+
+// NewMemoryStore creates an empty in-memory store holding up to capacity
+// replays (DefaultMemoryCapacity if capacity is 0 or less)
+func NewMemoryStore(capacity int) *MemoryStore {
+	if capacity <= 0 {
+		capacity = DefaultMemoryCapacity
+	}
+	return &MemoryStore{capacity: capacity, replays: make(map[string]*Replay)}
+}
+
+// Save implements Store, dropping the oldest replay once full
+func (s *MemoryStore) Save(r *Replay) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.replays[r.ID]; !exists {
+		s.order = append(s.order, r.ID)
+	}
+	s.replays[r.ID] = r
+	for len(s.order) > s.capacity {
+		delete(s.replays, s.order[0])
+		s.order = s.order[1:]
+	}
+	return nil
+}
+
+// Get implements Store
+func (s *MemoryStore) Get(id string) (*Replay, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r, ok := s.replays[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return r, nil
+}
+
+// List implements Store
+func (s *MemoryStore) List() ([]messages.ReplayInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	infos := make([]messages.ReplayInfo, 0, len(s.order))
+	for i := len(s.order) - 1; i >= 0; i-- {
+		infos = append(infos, s.replays[s.order[i]].ReplayInfo)
+	}
+	return infos, nil
+}
+
+// FileStore writes each replay to its own JSON file in a directory. Only
+// the listing is kept in memory; replays are read back on demand.
+type FileStore struct {
+	dir   string
+	infos map[string]messages.ReplayInfo
+	mu    sync.RWMutex
+}
+
+// NewFileStore indexes the replays already in dir, creating it if needed
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := datafile.MkdirAll(dir); err != nil {
+		return nil, err
+	}
+	s := &FileStore{dir: dir, infos: make(map[string]messages.ReplayInfo)}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		r, err := readReplay(f)
+		if err != nil {
+			return nil, err
+		}
+		s.infos[r.ID] = r.ReplayInfo
+	}
+	return s, nil
+}
+
+// Save implements Store
+func (s *FileStore) Save(r *Replay) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	path, ok := s.path(r.ID)
+	if !ok {
+		return ErrNotFound
+	}
+	if err := datafile.Write(path, data); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.infos[r.ID] = r.ReplayInfo
+	return nil
+}
+
+// Get implements Store
+func (s *FileStore) Get(id string) (*Replay, error) {
+	s.mu.RLock()
+	_, ok := s.infos[id]
+	s.mu.RUnlock()
+	path, valid := s.path(id)
+	if !ok || !valid {
+		return nil, ErrNotFound
+	}
+	return readReplay(path)
+}
+
+// List implements Store
+func (s *FileStore) List() ([]messages.ReplayInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	infos := make([]messages.ReplayInfo, 0, len(s.infos))
+	for _, info := range s.infos {
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].StartedAt > infos[j].StartedAt })
+	return infos, nil
+}
+
+// path returns where a replay lives, refusing IDs that would escape dir
+func (s *FileStore) path(id string) (string, bool) {
+	if id == "" || strings.ContainsAny(id, `/\.`) {
+		return "", false
+	}
+	return filepath.Join(s.dir, id+".json"), true
+}
+
+func readReplay(path string) (*Replay, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var r Replay
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
@@ -0,0 +1,133 @@
+package room
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+// Errors returned when a player is turned away from a room
+var (
+	ErrBadCode     = errors.New("unknown or missing join code")
+	ErrBadPassword = errors.New("wrong room password")
+	ErrRoomFull    = errors.New("room is full")
+	ErrNeedsFog    = errors.New("room has fog of war, which the client said it can't show")
+	ErrNeedsFloors = errors.New("room's maze has floors, which the client didn't say it can show")
+	ErrRoomClosed  = errors.New("room has closed")
+	ErrInMatch     = errors.New("already playing in this room")
+)
+
+// Access controls who may join a room
+type Access struct {
+	Private      bool   // Hidden from listings; joining needs the room's join code
+	Password     string `json:"-"` // Required to join when set; rooms keep only its hash
+	PasswordHash string // Hash of Password (see hashPassword), as saved
+	MaxPlayers   int    // Player limit (0 = unlimited)
+	Locked       bool   // Set by the host: nobody new may join
+}
+
+// hashPassword hashes a room password, so neither the room nor its saves
+// hold it in the clear
+func hashPassword(password string) string {
+	sum := sha256.Sum256([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
+
+// JoinCodeLength is how many characters a private room's join code has
+const JoinCodeLength = 6
+
+// joinCodeAlphabet leaves out characters that are easy to misread (0/O, 1/I)
+const joinCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// newJoinCode returns a random join code
+func newJoinCode() string {
+	buf := make([]byte, JoinCodeLength)
+	rand.Read(buf)
+	for i, b := range buf {
+		buf[i] = joinCodeAlphabet[int(b)%len(joinCodeAlphabet)]
+	}
+	return string(buf)
+}
+
+// Join admits a player if the code, password and player limit allow it and
+// their client can show the room, then adds them on a spawn point. The code
+// is only checked for private rooms.
+func (r *Room) Join(playerID string, client Sender, profile PlayerProfile, code, password string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.removed {
+		return ErrRoomClosed
+	}
+	// Joining again mid-match would be a free trip back to spawn
+	if r.Clients[playerID] != nil && r.State == StatePlaying {
+		return ErrInMatch
+	}
+	if err := r.checkAccessLocked(playerID, code, password); err != nil {
+		return err
+	}
+	if r.Rules.Fog && !supports(client, messages.CapFog) {
+		return ErrNeedsFog
+	}
+	if r.Maze.FloorCount() > 1 && !supports(client, messages.CapFloors) {
+		return ErrNeedsFloors
+	}
+	if r.demo {
+		r.endDemoLocked(r.clock.Now())
+	}
+	r.addPlayerLocked(playerID, client)
+	r.Players[playerID].Profile = profile
+	r.balanceTeamsLocked()
+	return nil
+}
+
+// showsFloorsLocked reports whether every client in the room can show a
+// maze of more than one floor, which a maze with floors needs before it is
+// swapped in
+func (r *Room) showsFloorsLocked() bool {
+	for _, client := range r.Clients {
+		if !supports(client, messages.CapFloors) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkAccessLocked validates a join attempt against the room's Access
+func (r *Room) checkAccessLocked(playerID, code, password string) error {
+	if r.kicked[playerID] {
+		return ErrKicked
+	}
+	if _, rejoining := r.Players[playerID]; r.Access.Locked && !rejoining {
+		return ErrRoomLocked
+	}
+	if r.Access.Private && code != r.JoinCode {
+		return ErrBadCode
+	}
+	if r.Access.PasswordHash != "" &&
+		subtle.ConstantTimeCompare([]byte(hashPassword(password)), []byte(r.Access.PasswordHash)) != 1 {
+		return ErrBadPassword
+	}
+	if r.fullLocked(playerID) {
+		return ErrRoomFull
+	}
+	return nil
+}
+
+// fullLocked reports whether the room has no slot for the player. A player
+// already in the room (rejoining after a reconnect) keeps their slot.
+func (r *Room) fullLocked(playerID string) bool {
+	if _, rejoining := r.Players[playerID]; rejoining {
+		return false
+	}
+	// A demo match gives way to anyone who joins
+	players := len(r.Players)
+	if r.demo {
+		players -= len(r.bots)
+	}
+	return r.Access.MaxPlayers > 0 && players >= r.Access.MaxPlayers
+}
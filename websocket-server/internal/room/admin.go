@@ -0,0 +1,106 @@
+package room
+
+import (
+	"sort"
+
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+// The methods below are the operator's handles on rooms, for the admin API
+
+// Close sends everyone in the room away with a roomClosed notice carrying
+// reason. The room is left empty for the caller to remove.
+func (r *Room) Close(reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.broadcastLocked(messages.ServerMessage{
+		Type:    "roomClosed",
+		RoomID:  r.ID,
+		Message: reason,
+	}, "")
+	r.emptyLocked()
+}
+
+// CloseRoom closes a room (see Room.Close) and removes it. Returns false if
+// there is no such room.
+func (m *Manager) CloseRoom(roomID, reason string) bool {
+	r := m.GetRoom(roomID)
+	if r == nil {
+		return false
+	}
+	r.Close(reason)
+	m.RemoveRoom(roomID)
+	return true
+}
+
+// RemoveIfEmpty closes and removes a room if it is still the manager's
+// room by its ID and nobody but bots is in it, with no one able to join in
+// between. Returns whether it did.
+func (m *Manager) RemoveIfEmpty(r *Room, reason string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.rooms[r.ID] != r {
+		return false
+	}
+
+	r.mu.Lock()
+	if len(r.Players) != len(r.bots) {
+		r.mu.Unlock()
+		return false
+	}
+	r.broadcastLocked(messages.ServerMessage{
+		Type:    "roomClosed",
+		RoomID:  r.ID,
+		Message: reason,
+	}, "")
+	r.emptyLocked()
+	r.removed = true
+	r.mu.Unlock()
+
+	m.removeLocked(r)
+	return true
+}
+
+// Kick removes a player from the room for good, sending them a kicked
+// notice with reason "admin" and the operator's message
+func (r *Room) Kick(playerID, message string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.Players[playerID]; !exists {
+		return ErrNoPlayer
+	}
+	r.kickLocked(playerID, messages.ServerMessage{
+		Type:    "kicked",
+		RoomID:  r.ID,
+		Reason:  "admin",
+		Message: message,
+	})
+	return nil
+}
+
+// Dump is everything known about a room, for debugging it
+type Dump struct {
+	Status    Status     `json:"status"`
+	Saved     *SavedRoom `json:"saved"`     // Maze, players, items and the match's event log
+	Connected []string   `json:"connected"` // Players with a connection, by ID
+}
+
+// Dump captures the room's full state
+func (r *Room) Dump() Dump {
+	status := r.Status()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	d := Dump{
+		Status:    status,
+		Saved:     r.saveLocked(),
+		Connected: make([]string, 0, len(r.Clients)),
+	}
+	for id := range r.Clients {
+		d.Connected = append(d.Connected, id)
+	}
+	sort.Strings(d.Connected)
+	return d
+}
@@ -0,0 +1,78 @@
+package room
+
+import (
+	"time"
+
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+const (
+	// AttractDelay is how long a public room sits empty in the lobby
+	// before a demo match starts in it
+	AttractDelay = 5 * time.Second
+	// DemoBots is how many bots play a demo match
+	DemoBots = 2
+)
+
+// updateAttractLocked keeps a demo match going in an empty public room
+// under Settings.AttractMode, for people browsing rooms to watch: bots
+// start one once the room has been empty for AttractDelay, and the next
+// waits as long again after each one ends. Rooms with a map veto are left
+// alone, since bots can't veto.
+func (r *Room) updateAttractLocked(now time.Time) {
+	if !r.attract || r.Access.Private || r.Rules.Practice || r.Rules.MapVeto {
+		return
+	}
+	if len(r.Players) > len(r.bots) {
+		r.emptySince = time.Time{}
+		return
+	}
+	if r.demo {
+		if r.State == StateFinished {
+			r.endDemoLocked(now)
+		}
+		return
+	}
+	// Bots a host left behind aren't ours to play with
+	if r.State != StateWaiting || len(r.bots) > 0 {
+		return
+	}
+	if r.emptySince.IsZero() {
+		r.emptySince = now
+	}
+	if now.Sub(r.emptySince) >= AttractDelay {
+		r.startDemoLocked(now)
+	}
+}
+
+// startDemoLocked seats the demo bots, which are ready at once
+func (r *Room) startDemoLocked(now time.Time) {
+	r.demo = true
+	for i := 0; i < DemoBots; i++ {
+		r.addBotLocked(DefaultBotDifficulty)
+	}
+	r.broadcastLocked(messages.ServerMessage{
+		Type:    "demoStarted",
+		Players: r.playersLocked(),
+	}, "")
+	r.startWhenReadyLocked(now)
+}
+
+// endDemoLocked sends the demo bots away and puts the room back in the
+// lobby on a fresh maze, when the demo match is over or someone joins
+func (r *Room) endDemoLocked(now time.Time) {
+	for id := range r.bots {
+		r.removePlayerLocked(id)
+	}
+	r.demo = false
+	r.emptySince = now
+	r.State = StateWaiting
+	r.round = 0
+	r.LastMatch = nil
+	r.newMazeLocked()
+	r.broadcastLocked(messages.ServerMessage{
+		Type:    "demoEnded",
+		State:   string(r.State),
+		Players: r.playersLocked(),
+	}, "")
+}
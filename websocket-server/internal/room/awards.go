@@ -0,0 +1,152 @@
+package room
+
+import (
+	"maps"
+	"slices"
+	"time"
+
+	"labyrinth-duel/websocket/internal/game"
+	"labyrinth-duel/websocket/internal/messages"
+	"labyrinth-duel/websocket/internal/replay"
+)
+
+// Award names handed out at the end of a match
+const (
+	AwardMVP        = "MVP"        // Highest score
+	AwardPathfinder = "Pathfinder" // Route closest to the optimal one
+	AwardDemolisher = "Demolisher" // Most walls smashed
+	AwardSurvivor   = "Survivor"   // Least time stunned
+)
+
+// MatchRecord is the permanent summary of a finished match
+type MatchRecord struct {
+	RoomID      string
+	Winner      string
+	Reason      string
+	StartedAt   time.Time
+	EndedAt     time.Time
+	Players     []messages.Player
+	Teams       [][]string // Player IDs on each team, team 1 first; nil outside team rooms
+	WinningTeam int        // Team of the winner, whose members all won; 0 outside team rooms
+	Awards      []messages.Award
+	Moves       map[string]int // Cells each player stepped, by player ID
+	Replay      *replay.Replay // Everything that happened, for playback
+	Mode        string         // Game mode, "" for the classic race
+	Seed        int64          // Seed of the first round's maze, as picked by any map veto
+	Practice    bool           // Played in a practice room, where sandbox commands were allowed
+	Coop        bool           // Played together against the maze, so nobody beat anybody
+	Daily       string         // Day of the daily challenge played, if it was one
+}
+
+// playerTally accumulates per-player stats from the event log
+type playerTally struct {
+	start       game.Point
+	moves       int
+	wallsBroken int
+	stunnedMs   int
+}
+
+// computeAwardsLocked derives the end-of-match awards from the event log.
+// Ties go to the lowest player ID.
+func (r *Room) computeAwardsLocked() []messages.Award {
+	tallies := make(map[string]*playerTally)
+	for _, ev := range r.matchEventsLocked() {
+		t, ok := tallies[ev.PlayerID]
+		if !ok {
+			if ev.Type != EventMatchStart {
+				continue // Player wasn't in the match from the start
+			}
+			t = &playerTally{}
+			tallies[ev.PlayerID] = t
+		}
+
+		switch ev.Type {
+		case EventMatchStart:
+			t.start = game.Point{X: ev.X, Y: ev.Y, Z: ev.Z}
+		case EventMove:
+			t.moves++
+		case EventWallBroken:
+			t.wallsBroken++
+		case EventStunned:
+			t.stunnedMs += ev.Value
+		}
+	}
+
+	var awards []messages.Award
+	ids := slices.Sorted(maps.Keys(tallies))
+
+	// MVP: highest score among players still in the room
+	best := ""
+	for _, id := range ids {
+		p, present := r.Players[id]
+		if !present {
+			continue
+		}
+		if best == "" || p.Score > r.Players[best].Score {
+			best = id
+		}
+	}
+	if best != "" {
+		awards = append(awards, messages.Award{Name: AwardMVP, PlayerID: best, Value: float64(r.Players[best].Score)})
+	}
+
+	// Pathfinder: ratio of optimal distance to moves actually taken
+	pathfinder, bestRatio := "", 0.0
+	for _, id := range ids {
+		t := tallies[id]
+		p, present := r.Players[id]
+		if !present || t.moves == 0 {
+			continue
+		}
+		optimal := r.Maze.DistanceMap(t.start).At(p.at())
+		ratio := float64(optimal) / float64(t.moves)
+		if ratio > bestRatio {
+			pathfinder, bestRatio = id, ratio
+		}
+	}
+	if pathfinder != "" {
+		awards = append(awards, messages.Award{Name: AwardPathfinder, PlayerID: pathfinder, Value: bestRatio})
+	}
+
+	// Demolisher: most walls broken (at least one)
+	demolisher, mostWalls := "", 0
+	for _, id := range ids {
+		if t := tallies[id]; t.wallsBroken > mostWalls {
+			demolisher, mostWalls = id, t.wallsBroken
+		}
+	}
+	if demolisher != "" {
+		awards = append(awards, messages.Award{Name: AwardDemolisher, PlayerID: demolisher, Value: float64(mostWalls)})
+	}
+
+	// Survivor: least time stunned, when anybody was stunned at all
+	survivor, leastStun, anyStun := "", -1, false
+	for _, id := range ids {
+		t := tallies[id]
+		if t.stunnedMs > 0 {
+			anyStun = true
+		}
+		if _, present := r.Players[id]; !present {
+			continue
+		}
+		if leastStun == -1 || t.stunnedMs < leastStun {
+			survivor, leastStun = id, t.stunnedMs
+		}
+	}
+	if survivor != "" && anyStun {
+		awards = append(awards, messages.Award{Name: AwardSurvivor, PlayerID: survivor, Value: float64(leastStun) / 1000})
+	}
+
+	return awards
+}
+
+// moveCountsLocked counts each player's moves this match from the event log
+func (r *Room) moveCountsLocked() map[string]int {
+	moves := make(map[string]int)
+	for _, ev := range r.matchEventsLocked() {
+		if ev.Type == EventMove {
+			moves[ev.PlayerID]++
+		}
+	}
+	return moves
+}
@@ -0,0 +1,84 @@
+package room
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"labyrinth-duel/websocket/internal/clock"
+)
+
+// TestSurvivor hands out the Survivor award from made-up match logs: it
+// goes to whoever was stunned least, the lowest ID on a tie, and to nobody
+// if no one was stunned at all
+func TestSurvivor(t *testing.T) {
+	tests := []struct {
+		name    string
+		stunned map[string]int // Milliseconds stunned, by player
+		want    string         // "" for no award
+	}{
+		{"nobody stunned", nil, ""},
+		{"one stunned", map[string]int{"b": 500}, "a"},
+		{"tied", map[string]int{"c": 500}, "a"},
+		{"all stunned", map[string]int{"a": 900, "b": 300, "c": 300}, "b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewManager()
+			r, _ := m.GetOrCreateRoom("awards", Options{})
+			defer m.RemoveRoom("awards")
+			for _, id := range []string{"c", "b", "a"} {
+				if err := r.AddPlayer(id, discard{}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.events = nil
+			for _, id := range []string{"c", "b", "a"} {
+				r.logEventLocked(Event{Type: EventMatchStart, PlayerID: id})
+				if ms := tt.stunned[id]; ms > 0 {
+					r.logEventLocked(Event{Type: EventStunned, PlayerID: id, Value: ms})
+				}
+			}
+			got := ""
+			for _, award := range r.computeAwardsLocked() {
+				if award.Name == AwardSurvivor {
+					got = award.PlayerID
+				}
+			}
+			if got != tt.want {
+				t.Errorf("Survivor went to %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestEventLogPerMatch starts a second match in a room: the log then holds
+// only that match's events
+func TestEventLogPerMatch(t *testing.T) {
+	c := clock.NewManual(time.Now())
+	m := NewManager()
+	m.Clock = c
+	r, _ := m.GetOrCreateRoom("log", Options{})
+	defer m.RemoveRoom("log")
+	if err := r.AddPlayer("solo", discard{}); err != nil {
+		t.Fatal(err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for match := 0; match < 2; match++ {
+		r.round = 0
+		r.startMatchLocked(c.Now())
+		r.logEventLocked(Event{Type: EventMove, PlayerID: "solo"})
+	}
+	var types []string
+	for _, ev := range r.events {
+		types = append(types, ev.Type)
+	}
+	if want := []string{EventRound, EventMatchStart, EventMove}; !slices.Equal(types, want) {
+		t.Errorf("log holds %v, want the second match's %v", types, want)
+	}
+}
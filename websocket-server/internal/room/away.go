@@ -0,0 +1,39 @@
+package room
+
+import "labyrinth-duel/websocket/internal/messages"
+
+// SetAway marks a player as backgrounded (away) or back in the foreground.
+// While away their personal update stream is paused and the room sees them
+// as AFK; coming back sends them a full snapshot to catch up. Returns false
+// if the player isn't in the room or nothing changed.
+func (r *Room) SetAway(playerID string, away bool) bool {
+	r.mu.Lock()
+	player, exists := r.Players[playerID]
+	if !exists || player.Away == away {
+		r.mu.Unlock()
+		return false
+	}
+
+	player.Away = away
+	msgType := "playerAway"
+	if !away {
+		msgType = "playerBack"
+	}
+	r.broadcastLocked(messages.ServerMessage{
+		Type:    msgType,
+		Message: playerID,
+		Players: r.playersLocked(),
+	}, playerID)
+	r.mu.Unlock()
+
+	if !away {
+		r.sendFullSnapshot(playerID, "fullSnapshot")
+	}
+	return true
+}
+
+// pausedLocked reports whether messages to a client are currently dropped
+func (r *Room) pausedLocked(id string) bool {
+	p, exists := r.Players[id]
+	return exists && p.Away
+}
@@ -0,0 +1,62 @@
+package room
+
+import (
+	"encoding/json"
+	"time"
+
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+const (
+	// DefaultBandwidthBudget is how many bytes per second a room may send
+	// before it is degraded
+	DefaultBandwidthBudget = 64 * 1024
+	// DegradedTickEvery runs only every Nth tick while degraded
+	DegradedTickEvery = 2
+	// DegradedSnapshotInterval spaces out periodic snapshots while degraded
+	DegradedSnapshotInterval = 4 * time.Second
+	// DegradedTimerEvery only announces the match timer every N seconds while
+	// degraded (the final ten seconds are always sent)
+	DegradedTimerEvery = 5
+)
+
+// countBytesLocked adds an outgoing message to this second's byte and
+// message counts
+func (r *Room) countBytesLocked(msg messages.ServerMessage) {
+	r.messagesSent++
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	r.bytesSent += len(data)
+}
+
+// updateBandwidthLocked closes each one-second window: a room that went over
+// its budget is degraded, and recovers once it drops below half of it
+func (r *Room) updateBandwidthLocked(now time.Time) {
+	if now.Sub(r.bandwidthWindow) < time.Second {
+		return
+	}
+
+	budget := r.BandwidthBudget
+	if budget <= 0 {
+		budget = DefaultBandwidthBudget
+	}
+
+	switch {
+	case !r.degraded && r.bytesSent > budget:
+		r.degraded = true
+	case r.degraded && r.bytesSent < budget/2:
+		r.degraded = false
+	}
+
+	r.LastBandwidth = r.bytesSent
+	r.LastMessages = r.messagesSent
+	r.bytesSent, r.messagesSent = 0, 0
+	r.bandwidthWindow = now
+}
+
+// skipTickLocked reports whether a degraded room should sit this tick out
+func (r *Room) skipTickLocked() bool {
+	return r.degraded && r.tickCount%DegradedTickEvery != 0
+}
@@ -0,0 +1,163 @@
+package room
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"labyrinth-duel/websocket/internal/game"
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+// BotDifficulty tunes how well a server-controlled player races
+type BotDifficulty struct {
+	Name         string
+	MoveInterval time.Duration // Time between steps, on top of the terrain cooldown
+	WrongTurn    float64       // Chance each step goes somewhere other than the shortest path
+	Rating       int           // Shown in the lobby, so rating-based balancing treats bots fairly
+}
+
+// Bot difficulties
+var (
+	BotEasy   = BotDifficulty{Name: "easy", MoveInterval: 400 * time.Millisecond, WrongTurn: 0.25, Rating: 1000}
+	BotMedium = BotDifficulty{Name: "medium", MoveInterval: 250 * time.Millisecond, WrongTurn: 0.1, Rating: 1200}
+	BotHard   = BotDifficulty{Name: "hard", MoveInterval: 150 * time.Millisecond, WrongTurn: 0.02, Rating: 1500}
+)
+
+// DefaultBotDifficulty is used when addBot doesn't name one
+var DefaultBotDifficulty = BotMedium
+
+var botDifficulties = map[string]BotDifficulty{
+	BotEasy.Name:   BotEasy,
+	BotMedium.Name: BotMedium,
+	BotHard.Name:   BotHard,
+}
+
+// ErrUnknownDifficulty is returned by AddBot for a difficulty that doesn't exist
+var ErrUnknownDifficulty = errors.New("unknown bot difficulty")
+
+// BotDifficultyByName looks up a difficulty; "" is DefaultBotDifficulty
+func BotDifficultyByName(name string) (BotDifficulty, bool) {
+	if name == "" {
+		return DefaultBotDifficulty, true
+	}
+	d, ok := botDifficulties[name]
+	return d, ok
+}
+
+// BotDifficultyForRating picks the difficulty closest to a player's rating
+func BotDifficultyForRating(rating int) BotDifficulty {
+	best := DefaultBotDifficulty
+	for _, d := range []BotDifficulty{BotEasy, BotMedium, BotHard} {
+		if abs(d.Rating-rating) < abs(best.Rating-rating) {
+			best = d
+		}
+	}
+	return best
+}
+
+// bot is the controller behind a server-controlled player
+type bot struct {
+	difficulty BotDifficulty
+	nextMoveAt time.Time
+}
+
+// AddBot seats a server-controlled player in the lobby, ready to race. It
+// moves from the room loop and is seen by everyone else like any player.
+// Returns the bot's player ID.
+func (r *Room) AddBot(difficulty string) (string, error) {
+	d, ok := BotDifficultyByName(difficulty)
+	if !ok {
+		return "", ErrUnknownDifficulty
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.State != StateWaiting {
+		return "", ErrNotInLobby
+	}
+	if r.fullLocked("") {
+		return "", ErrRoomFull
+	}
+
+	id := r.addBotLocked(d)
+	r.broadcastLocked(messages.ServerMessage{
+		Type:    "playerJoined",
+		Message: id,
+		Players: r.playersLocked(),
+	}, "")
+	r.startWhenReadyLocked(r.clock.Now())
+	return id, nil
+}
+
+// addBotLocked seats a ready bot and returns its player ID
+func (r *Room) addBotLocked(d BotDifficulty) string {
+	r.botSeq++
+	id := fmt.Sprintf("bot-%d", r.botSeq)
+	if r.bots == nil {
+		r.bots = make(map[string]*bot)
+	}
+	r.bots[id] = &bot{difficulty: d}
+
+	// Bots have no connection: nothing is ever sent to them
+	r.addPlayerLocked(id, nil)
+	player := r.Players[id]
+	player.Bot = true
+	player.Ready = true
+	player.Profile = PlayerProfile{Name: fmt.Sprintf("Bot %d (%s)", r.botSeq, d.Name), Rating: d.Rating}
+	r.balanceTeamsLocked()
+	return id
+}
+
+// moveBotsLocked takes a step for every bot whose turn it is
+func (r *Room) moveBotsLocked(now time.Time) {
+	for id, b := range r.bots {
+		if r.State != StatePlaying {
+			return // A bot reached the exit
+		}
+		player, exists := r.Players[id]
+		if !exists || now.Before(b.nextMoveAt) || now.Before(player.nextMoveAt) || now.Before(player.frozenUntil) {
+			continue
+		}
+		b.nextMoveAt = now.Add(b.difficulty.MoveInterval)
+
+		to, ok := r.botStepLocked(player, b)
+		if !ok || r.movePlayerLocked(player, to, now) != nil {
+			continue
+		}
+		r.broadcastMoveLocked(id)
+		r.emitFootstepsLocked(id)
+	}
+}
+
+// botStepLocked picks a bot's next cell: usually along the shortest path to
+// the nearest exit it hasn't banked yet, sometimes a wrong turn. Bots see
+// the whole maze, fog or not.
+func (r *Room) botStepLocked(player *PlayerState, b *bot) (game.Point, bool) {
+	var targets []game.Point
+	for _, g := range r.Maze.Goals {
+		if !player.claimed[g.Point] {
+			targets = append(targets, g.Point)
+		}
+	}
+	at := player.at()
+	path := r.Maze.PathFrom(at, player.Level, targets)
+
+	if len(path) < 2 || rand.Float64() < b.difficulty.WrongTurn {
+		var others []game.Point
+		for _, to := range game.Moves(at) {
+			if _, ok := r.Maze.Step(at, player.Level, to); ok && (len(path) < 2 || to != path[1]) {
+				others = append(others, to)
+			}
+		}
+		if len(others) > 0 {
+			return others[rand.Intn(len(others))], true
+		}
+	}
+	if len(path) < 2 {
+		return game.Point{}, false
+	}
+	return path[1], true
+}
@@ -0,0 +1,122 @@
+package room
+
+import (
+	"time"
+
+	"labyrinth-duel/websocket/internal/game"
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+// Camping penalties for RuleSet.AntiCamp
+const (
+	AntiCampOff    = ""       // Players may stay put as long as they like
+	AntiCampReveal = "reveal" // Opponents are pinged with where the camper is
+	AntiCampDecay  = "decay"  // The camper loses points
+	AntiCampBoth   = "both"   // Both of the above
+)
+
+const (
+	// DefaultCampWindow is how long a player may stay within CampRadius
+	// before they count as camping
+	DefaultCampWindow = 20 * time.Second
+	// DefaultCampRadius is how many cells (each way) a player may wander
+	// and still be camping
+	DefaultCampRadius = 1
+	// CampPenaltyInterval is how often a camper is penalized
+	CampPenaltyInterval = time.Second
+	// CampDecayPoints is what a camper loses at every penalty under
+	// AntiCampDecay
+	CampDecayPoints = 5
+)
+
+// campState tracks how long a player has stayed in one spot
+type campState struct {
+	anchor      game.Point // Where they settled
+	since       time.Time  // When they settled there
+	camping     bool
+	nextPenalty time.Time
+}
+
+func (r *Room) campWindow() time.Duration {
+	if r.Rules.CampWindow > 0 {
+		return r.Rules.CampWindow
+	}
+	return DefaultCampWindow
+}
+
+func (r *Room) campRadius() int {
+	if r.Rules.CampRadius > 0 {
+		return r.Rules.CampRadius
+	}
+	return DefaultCampRadius
+}
+
+// resetCampingLocked starts everyone's camping clock over where they stand
+func (r *Room) resetCampingLocked(now time.Time) {
+	for _, p := range r.Players {
+		p.camp = campState{anchor: p.at(), since: now}
+	}
+}
+
+// updateCampingLocked finds players who have stayed within the camp radius
+// for the camp window, warns them once, and penalizes them every
+// CampPenaltyInterval until they move on. Practice rooms have nobody to
+// compete with, so nobody camps there.
+func (r *Room) updateCampingLocked(now time.Time) {
+	if r.Rules.AntiCamp == AntiCampOff || r.Rules.Practice {
+		return
+	}
+
+	radius := r.campRadius()
+	scored := false
+	for id, p := range r.Players {
+		c := &p.camp
+		// A frozen player isn't staying put by choice
+		if p.Z != c.anchor.Z || abs(p.X-c.anchor.X) > radius || abs(p.Y-c.anchor.Y) > radius || now.Before(p.frozenUntil) {
+			*c = campState{anchor: p.at(), since: now}
+			continue
+		}
+		if now.Sub(c.since) < r.campWindow() {
+			continue
+		}
+
+		if !c.camping {
+			c.camping = true
+			c.nextPenalty = now
+			r.sendLocked(id, messages.ServerMessage{
+				Type:   "campingWarning",
+				Reason: r.Rules.AntiCamp,
+			})
+		}
+		if now.Before(c.nextPenalty) {
+			continue
+		}
+		c.nextPenalty = now.Add(CampPenaltyInterval)
+
+		if r.Rules.AntiCamp == AntiCampReveal || r.Rules.AntiCamp == AntiCampBoth {
+			r.revealCamperLocked(p)
+		}
+		if (r.Rules.AntiCamp == AntiCampDecay || r.Rules.AntiCamp == AntiCampBoth) && p.Score > 0 {
+			p.Score -= CampDecayPoints
+			if p.Score < 0 {
+				p.Score = 0
+			}
+			scored = true
+		}
+	}
+	if scored {
+		r.broadcastScoresLocked()
+	}
+}
+
+// revealCamperLocked pings a camper's opponents with where they are
+func (r *Room) revealCamperLocked(camper *PlayerState) {
+	r.broadcastToLocked(messages.ServerMessage{
+		Type:     "camperRevealed",
+		Message:  camper.ID,
+		Position: &messages.Position{X: camper.X, Y: camper.Y},
+	}, func(id string) bool {
+		p, ok := r.Players[id]
+		return ok && id != camper.ID && (p.Team == 0 || p.Team != camper.Team)
+	})
+}
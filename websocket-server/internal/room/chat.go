@@ -0,0 +1,114 @@
+package room
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+const (
+	// MaxChatLength caps a chat message, in characters
+	MaxChatLength = 200
+	// ChatHistorySize is how many recent messages new players are sent
+	ChatHistorySize = 50
+	// ChatBurst messages are allowed per ChatWindow before rate limiting
+	ChatBurst  = 5
+	ChatWindow = 5 * time.Second
+)
+
+// Chat channels
+const (
+	ChatChannelRoom = ""     // Everyone in the room
+	ChatChannelTeam = "team" // The sender's team only
+)
+
+// Errors returned by Chat
+var (
+	ErrChatEmpty       = errors.New("message is empty")
+	ErrChatTooLong     = errors.New("message is too long")
+	ErrChatRateLimited = errors.New("sending messages too quickly")
+	ErrBadChannel      = errors.New("unknown chat channel")
+)
+
+// Chat relays a text message from a player to the whole room, or to their
+// team on the team channel, and keeps it in the room's chat history
+func (r *Room) Chat(playerID, channel, text string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	player, exists := r.Players[playerID]
+	if !exists {
+		return ErrNoPlayer
+	}
+	switch {
+	case channel != ChatChannelRoom && channel != ChatChannelTeam:
+		return ErrBadChannel
+	case channel == ChatChannelTeam && r.teamCount() == 0:
+		return ErrNoTeams
+	}
+
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return ErrChatEmpty
+	}
+	if utf8.RuneCountInString(text) > MaxChatLength {
+		return ErrChatTooLong
+	}
+
+	// Sliding window: forget sends older than ChatWindow
+	now := r.clock.Now()
+	recent := player.chatTimes[:0]
+	for _, t := range player.chatTimes {
+		if now.Sub(t) < ChatWindow {
+			recent = append(recent, t)
+		}
+	}
+	player.chatTimes = recent
+	if len(recent) >= ChatBurst {
+		return ErrChatRateLimited
+	}
+	player.chatTimes = append(player.chatTimes, now)
+
+	line := messages.ChatMessage{
+		PlayerID: playerID,
+		Text:     text,
+		Time:     now.UnixMilli(),
+		Channel:  channel,
+		Team:     r.teamOfLocked(playerID),
+	}
+	r.chat = append(r.chat, line)
+	if len(r.chat) > ChatHistorySize {
+		r.chat = r.chat[len(r.chat)-ChatHistorySize:]
+	}
+
+	msg := messages.ServerMessage{
+		Type: "chat",
+		Chat: []messages.ChatMessage{line},
+	}
+	if channel == ChatChannelTeam {
+		r.broadcastToLocked(msg, func(id string) bool { return r.teamOfLocked(id) == line.Team })
+		return nil
+	}
+	r.broadcastLocked(msg, "")
+	return nil
+}
+
+// ChatHistory returns the room's most recent chat messages a player may
+// read, oldest first: the room's, and their own team's
+func (r *Room) ChatHistory(playerID string) []messages.ChatMessage {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	team := r.teamOfLocked(playerID)
+	var history []messages.ChatMessage
+	for _, line := range r.chat {
+		if line.Channel == ChatChannelTeam && line.Team != team {
+			continue
+		}
+		history = append(history, line)
+	}
+	return history
+}
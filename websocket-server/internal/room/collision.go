@@ -0,0 +1,69 @@
+package room
+
+import (
+	"time"
+
+	"labyrinth-duel/websocket/internal/game"
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+// Collision policies for RuleSet.Collision
+const (
+	CollisionPass  = ""      // Players walk through each other
+	CollisionBlock = "block" // An occupied cell can't be entered
+	CollisionBump  = "bump"  // The occupant is shoved one cell further, if there is room and it isn't an exit
+)
+
+// playerAtLocked returns the player standing at p on the given level
+func (r *Room) playerAtLocked(at game.Point, level int, exceptID string) *PlayerState {
+	for id, p := range r.Players {
+		if id != exceptID && p.at() == at && p.Level == level {
+			return p
+		}
+	}
+	return nil
+}
+
+// resolveCollisionLocked applies the room's collision policy to a move into
+// to. Returns false if the mover has to stay where they are.
+func (r *Room) resolveCollisionLocked(mover *PlayerState, to game.Point, level int, now time.Time) bool {
+	if r.Rules.Collision == CollisionPass {
+		return true
+	}
+
+	other := r.playerAtLocked(to, level, mover.ID)
+	if other == nil {
+		return true
+	}
+	r.tagCarrierLocked(mover, other, now)
+
+	if r.Rules.Collision == CollisionBump {
+		// Shove the occupant one more cell in the mover's direction. Never
+		// onto an exit: nobody gets there without stepping on it themselves
+		from := mover.at()
+		pushed := game.Point{X: 2*to.X - from.X, Y: 2*to.Y - from.Y, Z: 2*to.Z - from.Z}
+		pushedLevel, ok := r.Maze.Step(to, other.Level, pushed)
+		_, onGoal := r.Maze.GoalAt(pushed)
+		if ok && !onGoal && r.playerAtLocked(pushed, pushedLevel, other.ID) == nil {
+			other.moveTo(pushed)
+			other.Level = pushedLevel
+			r.logEventLocked(Event{Type: EventMove, PlayerID: other.ID, X: pushed.X, Y: pushed.Y, Z: pushed.Z})
+			r.sendRevealLocked(other)
+			r.pickupLocked(other, now)
+			r.sendCollisionLocked(mover, other, to, "bumped")
+			return true
+		}
+	}
+
+	r.sendCollisionLocked(mover, other, to, "blocked")
+	return false
+}
+
+func (r *Room) sendCollisionLocked(mover, other *PlayerState, at game.Point, result string) {
+	r.broadcastLocked(messages.ServerMessage{
+		Type:     "collision",
+		Message:  result,
+		Players:  []messages.Player{mover.toMessage(), other.toMessage()},
+		Position: &messages.Position{X: at.X, Y: at.Y, Z: at.Z},
+	}, "")
+}
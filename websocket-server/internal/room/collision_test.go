@@ -0,0 +1,63 @@
+package room
+
+import (
+	"testing"
+	"time"
+
+	"labyrinth-duel/websocket/internal/clock"
+	"labyrinth-duel/websocket/internal/game"
+)
+
+// TestBumpOntoExit has a player bump another toward an exit in a bump room:
+// the shove is refused, so nobody wins without reaching the exit themselves
+func TestBumpOntoExit(t *testing.T) {
+	c := clock.NewManual(time.Now())
+	m := NewManager()
+	m.Clock = c
+	r, _ := m.GetOrCreateRoom("bump", Options{Maze: game.Options{Seed: 4}, Rules: RuleSet{Collision: CollisionBump}})
+	defer m.RemoveRoom("bump")
+	for _, id := range []string{"mover", "blocker"} {
+		if err := r.AddPlayer(id, discard{}); err != nil {
+			t.Fatal(err)
+		}
+		r.SetReady(id)
+	}
+	c.Advance(CountdownDuration + DefaultTickInterval)
+
+	// Line the two up in a straight corridor ending at the exit
+	r.mu.Lock()
+	goal := r.Maze.Goals[0].Point
+	var from, mid game.Point
+	found := false
+	for _, next := range game.Moves(goal) {
+		back := game.Point{X: 2*next.X - goal.X, Y: 2*next.Y - goal.Y, Z: goal.Z}
+		_, toGoal := r.Maze.Step(next, 0, goal)
+		_, toNext := r.Maze.Step(back, 0, next)
+		if r.Maze.Contains(back) && toGoal && toNext && next.Z == goal.Z {
+			from, mid, found = back, next, true
+			break
+		}
+	}
+	if !found {
+		r.mu.Unlock()
+		t.Skip("no straight corridor into the exit in this maze")
+	}
+	mover, blocker := r.Players["mover"], r.Players["blocker"]
+	mover.moveTo(from)
+	blocker.moveTo(mid)
+	mover.Level, blocker.Level = 0, 0
+	r.mu.Unlock()
+
+	c.Advance(BaseMoveInterval)
+	if err := r.UpdatePlayerPosition("mover", mid.X, mid.Y, mid.Z); err == nil {
+		t.Error("bumped a player onto the exit")
+	}
+	if r.GetState() != StatePlaying {
+		t.Errorf("match in state %q after the bump, want still playing", r.GetState())
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if blocker.at() != mid {
+		t.Errorf("blocker shoved to %v, want left at %v", blocker.at(), mid)
+	}
+}
@@ -0,0 +1,301 @@
+package room
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"labyrinth-duel/websocket/internal/game"
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+// ModeCTF is the built-in capture-the-flag mode. Every team has a flag at
+// its base; a player who takes the enemy flag home while their own is safe
+// at base scores a capture. Exits don't end the round.
+const ModeCTF = "ctf"
+
+const (
+	// CaptureLimit is how many captures win the round
+	CaptureLimit = 3
+	// CapturePoints is what a capture scores for its carrier
+	CapturePoints = 100
+	// FlagCarrierSpeed is how much a flag slows whoever carries it
+	FlagCarrierSpeed = 0.75
+	// FlagReturnDelay is how long a dropped flag lies before going home
+	FlagReturnDelay = 15 * time.Second
+)
+
+// ErrNoFlag is returned by ctf.drop when the player carries no flag
+var ErrNoFlag = errors.New("player is not carrying a flag")
+
+func init() {
+	RegisterMode(ModeCTF, func() GameMode { return &ctfMode{} })
+}
+
+// flag is one team's flag
+type flag struct {
+	team     int
+	base     game.Point
+	at       game.Point // Where it lies when nobody carries it
+	carrier  string
+	returnAt time.Time // When it goes home if dropped in the field
+}
+
+// home reports whether the flag is at its base
+func (f *flag) home() bool {
+	return f.carrier == "" && f.at == f.base
+}
+
+// ctfMode holds a capture-the-flag room's flags and score
+type ctfMode struct {
+	flags    []*flag // Team 1 first
+	captures []int   // This round's captures by team, team 1 first
+}
+
+func (m *ctfMode) Name() string { return ModeCTF }
+
+// HandleMessage takes ctf.drop, which leaves the carried flag where the
+// carrier stands
+func (m *ctfMode) HandleMessage(tx *Tx, playerID, action string, msg messages.ClientMessage) error {
+	if action != "drop" {
+		return ErrUnknownAction
+	}
+	f := m.carriedBy(playerID)
+	if f == nil {
+		return ErrNoFlag
+	}
+	tx.r.dropFlagLocked(f, "dropped", tx.r.clock.Now())
+	return nil
+}
+
+// carriedBy returns the flag a player carries, if any
+func (m *ctfMode) carriedBy(playerID string) *flag {
+	for _, f := range m.flags {
+		if f.carrier == playerID {
+			return f
+		}
+	}
+	return nil
+}
+
+// ctfLocked returns the room's mode if it is capture the flag
+func (r *Room) ctfLocked() *ctfMode {
+	m, _ := r.Mode.(*ctfMode)
+	return m
+}
+
+// carryingFlagLocked reports whether a player carries a flag
+func (r *Room) carryingFlagLocked(playerID string) bool {
+	m := r.ctfLocked()
+	return m != nil && m.carriedBy(playerID) != nil
+}
+
+// setUpFlagsLocked plants every team's flag on a spawn point of its own at
+// the start of a round and gathers each team there
+func (r *Room) setUpFlagsLocked() {
+	m := r.ctfLocked()
+	if m == nil {
+		return
+	}
+	teams := r.teamCount()
+	m.flags = make([]*flag, teams)
+	m.captures = make([]int, teams)
+	for t := range teams {
+		spawn := t % len(r.Maze.Spawns)
+		base := r.Maze.Spawns[spawn]
+		m.flags[t] = &flag{team: t + 1, base: base, at: base}
+		for _, p := range r.Players {
+			if p.Team == t+1 {
+				p.Spawn = spawn
+				p.X, p.Y, p.Level = base.X, base.Y, game.LevelSurface
+			}
+		}
+	}
+	r.broadcastFlagsLocked("flags", "")
+}
+
+// ctfStepLocked plays out a player's step in capture the flag: tagging an
+// enemy carrier on the same cell, returning their own dropped flag,
+// taking the enemy's, and capturing it at their base
+func (r *Room) ctfStepLocked(player *PlayerState, now time.Time) {
+	m := r.ctfLocked()
+	if m == nil || player.Team == 0 {
+		return
+	}
+	here := player.at()
+	if other := r.playerAtLocked(here, player.Level, player.ID); other != nil {
+		r.tagCarrierLocked(player, other, now)
+	}
+
+	for _, f := range m.flags {
+		if f.carrier != "" || f.at != here {
+			continue
+		}
+		switch {
+		case f.team == player.Team && !f.home():
+			f.at, f.returnAt = f.base, time.Time{}
+			r.logEventLocked(Event{Type: EventFlag, PlayerID: player.ID, X: here.X, Y: here.Y, Z: here.Z, Detail: "returned", Value: f.team, At: now})
+			r.broadcastFlagsLocked("flagReturned", player.ID)
+		case f.team != player.Team && m.carriedBy(player.ID) == nil:
+			f.carrier, f.returnAt = player.ID, time.Time{}
+			r.logEventLocked(Event{Type: EventFlag, PlayerID: player.ID, X: here.X, Y: here.Y, Z: here.Z, Detail: "taken", Value: f.team, At: now})
+			r.broadcastFlagsLocked("flagTaken", player.ID)
+		}
+	}
+
+	carried := m.carriedBy(player.ID)
+	if carried == nil {
+		return
+	}
+	own := m.flags[player.Team-1]
+	if here != own.base || !own.home() {
+		return
+	}
+
+	// Capture: the enemy flag goes home and the carrier scores
+	carried.carrier = ""
+	carried.at = carried.base
+	m.captures[player.Team-1]++
+	r.logEventLocked(Event{Type: EventFlag, PlayerID: player.ID, X: here.X, Y: here.Y, Z: here.Z, Detail: "captured", Value: carried.team, At: now})
+	r.awardPointsLocked(player.ID, CapturePoints, now)
+	r.broadcastFlagsLocked("flagCaptured", player.ID)
+	if m.captures[player.Team-1] >= CaptureLimit {
+		r.finishLocked(player.ID, "captures")
+	}
+}
+
+// tagCarrierLocked makes an enemy flag carrier the mover ran into drop
+// the flag
+func (r *Room) tagCarrierLocked(mover, other *PlayerState, now time.Time) {
+	m := r.ctfLocked()
+	if m == nil || other.Team == mover.Team {
+		return
+	}
+	if f := m.carriedBy(other.ID); f != nil {
+		r.dropFlagLocked(f, "tagged", now)
+	}
+}
+
+// dropFlagLocked leaves a carried flag where its carrier stands, to go home
+// after FlagReturnDelay unless someone picks it up first
+func (r *Room) dropFlagLocked(f *flag, why string, now time.Time) {
+	carrierID := f.carrier
+	if p, ok := r.Players[carrierID]; ok {
+		f.at = p.at()
+	}
+	f.carrier = ""
+	f.returnAt = time.Time{}
+	if !f.home() {
+		f.returnAt = now.Add(FlagReturnDelay)
+	}
+	r.logEventLocked(Event{Type: EventFlag, PlayerID: carrierID, X: f.at.X, Y: f.at.Y, Z: f.at.Z, Detail: why, Value: f.team, At: now})
+	r.broadcastFlagsLocked("flagDropped", carrierID)
+}
+
+// dropFlagOfLocked drops whatever flag a leaving player carries
+func (r *Room) dropFlagOfLocked(playerID string, now time.Time) {
+	if m := r.ctfLocked(); m != nil {
+		if f := m.carriedBy(playerID); f != nil {
+			r.dropFlagLocked(f, "left", now)
+		}
+	}
+}
+
+// updateFlagsLocked sends dropped flags home once they have lain long enough
+func (r *Room) updateFlagsLocked(now time.Time) {
+	m := r.ctfLocked()
+	if m == nil {
+		return
+	}
+	for _, f := range m.flags {
+		if f.returnAt.IsZero() || now.Before(f.returnAt) {
+			continue
+		}
+		f.at, f.returnAt = f.base, time.Time{}
+		r.logEventLocked(Event{Type: EventFlag, X: f.at.X, Y: f.at.Y, Z: f.at.Z, Detail: "returned", Value: f.team, At: now})
+		r.broadcastFlagsLocked("flagReturned", "")
+	}
+}
+
+// ctfLeaderLocked returns the winner when time runs out: the best scorer of
+// the team with the most captures, combined score breaking ties, or "" if
+// the teams are level on both
+func (r *Room) ctfLeaderLocked() string {
+	m := r.ctfLocked()
+	totals := make([]int, len(m.captures))
+	for _, p := range r.Players {
+		if p.Team > 0 && p.Team <= len(totals) {
+			totals[p.Team-1] += p.Score
+		}
+	}
+	best, tied := 0, false
+	for t := 1; t < len(m.captures); t++ {
+		switch {
+		case m.captures[t] > m.captures[best],
+			m.captures[t] == m.captures[best] && totals[t] > totals[best]:
+			best, tied = t, false
+		case m.captures[t] == m.captures[best] && totals[t] == totals[best]:
+			tied = true
+		}
+	}
+	if tied {
+		return ""
+	}
+
+	var top *PlayerState
+	for _, p := range r.Players {
+		if p.Team == best+1 && (top == nil || p.Score > top.Score || p.Score == top.Score && p.ID < top.ID) {
+			top = p
+		}
+	}
+	if top == nil {
+		return ""
+	}
+	return top.ID
+}
+
+// GetFlags returns the flags of a capture-the-flag room, nil otherwise
+func (r *Room) GetFlags() []messages.Flag {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.flagsLocked(r.clock.Now())
+}
+
+// flagsLocked is GetFlags for callers already holding the room lock
+func (r *Room) flagsLocked(now time.Time) []messages.Flag {
+	m := r.ctfLocked()
+	if m == nil {
+		return nil
+	}
+	flags := make([]messages.Flag, len(m.flags))
+	for i, f := range m.flags {
+		flags[i] = messages.Flag{
+			Team:     f.team,
+			X:        f.at.X,
+			Y:        f.at.Y,
+			Z:        f.at.Z,
+			Base:     positionOf(f.base),
+			Carrier:  f.carrier,
+			Home:     f.home(),
+			Captures: m.captures[i],
+		}
+		if p, ok := r.Players[f.carrier]; ok {
+			flags[i].X, flags[i].Y, flags[i].Z = p.X, p.Y, p.Z
+		}
+		if !f.returnAt.IsZero() {
+			flags[i].ReturnIn = math.Max(0, f.returnAt.Sub(now).Seconds())
+		}
+	}
+	return flags
+}
+
+// broadcastFlagsLocked tells everyone where the flags are after a player
+// (message) took, dropped, returned or captured one
+func (r *Room) broadcastFlagsLocked(msgType, playerID string) {
+	r.broadcastLocked(messages.ServerMessage{
+		Type:    msgType,
+		Message: playerID,
+		Flags:   r.flagsLocked(r.clock.Now()),
+		Players: r.playersLocked(),
+	}, "")
+}
@@ -0,0 +1,57 @@
+package room
+
+import (
+	"errors"
+	"time"
+
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+// Emotes players can send. Only these IDs are relayed, so there is no
+// free text to moderate.
+var Emotes = []string{"wave", "gg", "laugh", "taunt", "thumbsUp", "oops"}
+
+// EmoteCooldown is the minimum time between a player's emotes
+const EmoteCooldown = 2 * time.Second
+
+// Errors returned by Emote
+var (
+	ErrUnknownEmote  = errors.New("unknown emote")
+	ErrEmoteCooldown = errors.New("emote is cooling down")
+)
+
+// Emote relays a predefined emote from a player to the room
+func (r *Room) Emote(playerID, emote string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	player, exists := r.Players[playerID]
+	if !exists {
+		return ErrNoPlayer
+	}
+	if !isEmote(emote) {
+		return ErrUnknownEmote
+	}
+
+	now := r.clock.Now()
+	if now.Before(player.nextEmoteAt) {
+		return ErrEmoteCooldown
+	}
+	player.nextEmoteAt = now.Add(EmoteCooldown)
+
+	r.broadcastLocked(messages.ServerMessage{
+		Type:    "emote",
+		Message: playerID,
+		Emote:   emote,
+	}, "")
+	return nil
+}
+
+func isEmote(emote string) bool {
+	for _, e := range Emotes {
+		if e == emote {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package room
+
+import "time"
+
+// Event types recorded in a room's event log
+const (
+	EventJoin       = "join"
+	EventLeave      = "leave"
+	EventMatchStart = "matchStart" // One per player, at their starting cell
+	EventMove       = "move"
+	EventScore      = "score"
+	EventWallBroken = "wallBroken"
+	EventStunned    = "stunned" // Value holds the stun length in milliseconds
+	EventFinish     = "finish"
+	EventRound      = "round"      // A round began; Value indexes the match's mazes
+	EventItemPickup = "itemPickup" // Detail holds the item kind
+	EventItemUsed   = "itemUsed"   // Detail holds the item kind
+	EventGoalMoved  = "goalMoved"  // An exit moved to (X, Y); Value indexes the maze's exits
+	EventMazeShift  = "mazeShift"  // The maze shifted at (X, Y); Detail holds the kind
+	EventCaught     = "caught"     // A minotaur caught the player at (X, Y); Detail holds which
+	EventFlag       = "flag"       // Detail says what happened to Value's team flag: taken, dropped, tagged, left, returned, captured
+)
+
+// Event is a single entry in a room's event log
+type Event struct {
+	Type     string    `json:"type"`
+	PlayerID string    `json:"playerId,omitempty"`
+	X        int       `json:"x"`
+	Y        int       `json:"y"`
+	Z        int       `json:"z,omitempty"` // Floor
+	Value    int       `json:"value,omitempty"`
+	Detail   string    `json:"detail,omitempty"` // Item kind, wall direction
+	At       time.Time `json:"at"`
+}
+
+// logEventLocked appends an event to the room's log
+func (r *Room) logEventLocked(ev Event) {
+	if ev.At.IsZero() {
+		ev.At = r.clock.Now()
+	}
+	r.events = append(r.events, ev)
+}
+
+// matchEventsLocked returns the events of the current (or last) match.
+// The log starts over with each match, so that is all of it.
+func (r *Room) matchEventsLocked() []Event {
+	return r.events
+}
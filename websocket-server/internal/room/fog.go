@@ -0,0 +1,76 @@
+package room
+
+import (
+	"labyrinth-duel/websocket/internal/game"
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+// DefaultFogRadius is how far (in Manhattan distance) a player can see
+const DefaultFogRadius = 5
+
+func (r *Room) fogRadius() int {
+	if r.Rules.FogRadius > 0 {
+		return r.Rules.FogRadius
+	}
+	return DefaultFogRadius
+}
+
+// revealLocked marks every cell on the player's floor within the fog radius
+// as seen and returns the cells that were not seen before
+func (r *Room) revealLocked(p *PlayerState) []messages.Cell {
+	if p.revealed == nil {
+		p.revealed = make(map[game.Point]bool)
+	}
+
+	radius := r.fogRadius()
+	var fresh []messages.Cell
+	for dy := -radius; dy <= radius; dy++ {
+		for dx := -radius; dx <= radius; dx++ {
+			if abs(dx)+abs(dy) > radius {
+				continue
+			}
+			cell := game.Point{X: p.X + dx, Y: p.Y + dy, Z: p.Z}
+			if !r.Maze.Contains(cell) || p.revealed[cell] {
+				continue
+			}
+			p.revealed[cell] = true
+			fresh = append(fresh, cellToMessage(*r.Maze.CellAt(cell)))
+		}
+	}
+	return fresh
+}
+
+// revealedCellsLocked returns every cell the player has seen so far,
+// revealing their current surroundings first
+func (r *Room) revealedCellsLocked(p *PlayerState) []messages.Cell {
+	r.revealLocked(p)
+
+	cells := make([]messages.Cell, 0, len(p.revealed))
+	for cell := range p.revealed {
+		cells = append(cells, cellToMessage(*r.Maze.CellAt(cell)))
+	}
+	return cells
+}
+
+// sendRevealLocked tells a player about any cells that just came into view
+func (r *Room) sendRevealLocked(p *PlayerState) {
+	if !r.Rules.Fog {
+		return
+	}
+
+	fresh := r.revealLocked(p)
+	if len(fresh) == 0 {
+		return
+	}
+	r.sendLocked(p.ID, messages.ServerMessage{
+		Type:  "mazeReveal",
+		Cells: fresh,
+	})
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
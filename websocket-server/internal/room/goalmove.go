@@ -0,0 +1,118 @@
+package room
+
+import (
+	"math"
+	"time"
+
+	"labyrinth-duel/websocket/internal/game"
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+const (
+	// MinGoalMoveInterval is the shortest RuleSet.GoalMoveInterval allowed,
+	// so there is time to head for an exit before it moves again
+	MinGoalMoveInterval = 10 * time.Second
+	// GoalMoveWarning is how long before the exits move players are warned
+	GoalMoveWarning = 5 * time.Second
+)
+
+// goalMoveInterval is how often the exits move, or 0 if they stay put
+func (r *Room) goalMoveInterval() time.Duration {
+	if r.Rules.GoalMoveInterval <= 0 {
+		return 0
+	}
+	if r.Rules.GoalMoveInterval < MinGoalMoveInterval {
+		return MinGoalMoveInterval
+	}
+	return r.Rules.GoalMoveInterval
+}
+
+// scheduleGoalMoveLocked sets when the exits next move, counting from now
+func (r *Room) scheduleGoalMoveLocked(now time.Time) {
+	r.nextGoalMove = time.Time{}
+	r.lastGoalWarning = 0
+	if interval := r.goalMoveInterval(); interval > 0 {
+		r.nextGoalMove = now.Add(interval)
+	}
+}
+
+// updateGoalMoveLocked counts down the last GoalMoveWarning seconds before
+// the exits move, once per second, then moves them
+func (r *Room) updateGoalMoveLocked(now time.Time) {
+	if r.nextGoalMove.IsZero() {
+		return
+	}
+	remaining := r.nextGoalMove.Sub(now)
+	if remaining > GoalMoveWarning {
+		return
+	}
+	if remaining > 0 {
+		seconds := int(math.Ceil(remaining.Seconds()))
+		if seconds != r.lastGoalWarning {
+			r.lastGoalWarning = seconds
+			r.broadcastLocked(messages.ServerMessage{
+				Type:    "goalMoving",
+				Seconds: seconds,
+			}, "")
+		}
+		return
+	}
+
+	r.moveGoalsLocked(now)
+	r.scheduleGoalMoveLocked(now)
+}
+
+// moveGoalsLocked puts every exit on the free cell farthest (by corridor)
+// from the nearest player, each one no closer than MinGoalSpawnDistance to
+// another, and prices them by that distance
+func (r *Room) moveGoalsLocked(now time.Time) {
+	var players []game.Point
+	for _, p := range r.Players {
+		players = append(players, p.at())
+	}
+	nearest := r.Maze.NearestDistanceMap(players)
+
+	goals := make([]game.Goal, 0, len(r.Maze.Goals))
+	var placed []game.Distances // Distance maps from the exits placed so far
+	for range r.Maze.Goals {
+		best, bestDist := game.Point{}, 0
+		for _, cell := range r.Maze.Points() {
+			c := r.Maze.CellAt(cell)
+			if d := nearest.At(cell); d <= bestDist || c.StairsUp || c.StairsDown ||
+				r.Items[cell] != nil || r.Maze.IsSpawn(cell) || tooClose(placed, cell) {
+				continue
+			}
+			best, bestDist = cell, nearest.At(cell)
+		}
+		if bestDist == 0 {
+			break // Nowhere left that's far enough from everything
+		}
+		goals = append(goals, game.Goal{Point: best, Value: bestDist * game.GoalPointsPerStep})
+		placed = append(placed, r.Maze.DistanceMap(best))
+	}
+	if len(goals) == 0 {
+		return
+	}
+
+	r.Maze.Goal = goals[0].Point
+	r.Maze.Goals = goals
+	for i, g := range goals {
+		r.logEventLocked(Event{Type: EventGoalMoved, X: g.X, Y: g.Y, Z: g.Z, Value: i, At: now})
+	}
+	r.broadcastLocked(messages.ServerMessage{
+		Type:     "goalMoved",
+		Position: &messages.Position{X: goals[0].X, Y: goals[0].Y, Z: goals[0].Z},
+		Goals:    goalsToMessage(goals),
+	}, "")
+}
+
+// tooClose reports whether p is within MinGoalSpawnDistance of any of the
+// exits with the given distance maps
+func tooClose(placed []game.Distances, p game.Point) bool {
+	for _, dist := range placed {
+		if d := dist.At(p); d >= 0 && d < game.MinGoalSpawnDistance {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,168 @@
+package room
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"time"
+
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+// SnapshotInterval is how often a playing room broadcasts a state snapshot
+const SnapshotInterval = time.Second
+
+// stateHashLocked fingerprints the authoritative room state so clients can
+// detect drift. It is the FNV-1a 64-bit hash, as 16 hex digits, of:
+//
+//	state "|"
+//	"p:" id "," x "," y "," level "," score ";"  for each player, by ID
+//	"i:" id "," kind "," x "," y ";"             for each item, by ID
+//	"c:" x "," y "," walls "," terrain "," under ";"  for each cell, row by row
+//
+// where walls is a bitmask of top=1, right=2, bottom=4, left=8. In a maze
+// of more than one floor, players and items have "," z after y, cells go
+// floor by floor from the ground up, and walls adds stairs up=16 and stairs
+// down=32. Cells are left out under fog, since players never hold the whole
+// maze.
+func (r *Room) stateHashLocked() string {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s|", r.State)
+
+	ids := make([]string, 0, len(r.Players))
+	for id := range r.Players {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	floors := r.Maze.FloorCount() > 1
+	for _, id := range ids {
+		p := r.Players[id]
+		if floors {
+			fmt.Fprintf(h, "p:%s,%d,%d,%d,%d,%d;", id, p.X, p.Y, p.Z, p.Level, p.Score)
+		} else {
+			fmt.Fprintf(h, "p:%s,%d,%d,%d,%d;", id, p.X, p.Y, p.Level, p.Score)
+		}
+	}
+
+	items := r.itemsLocked()
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	for _, item := range items {
+		if floors {
+			fmt.Fprintf(h, "i:%s,%s,%d,%d,%d;", item.ID, item.Kind, item.X, item.Y, item.Z)
+		} else {
+			fmt.Fprintf(h, "i:%s,%s,%d,%d;", item.ID, item.Kind, item.X, item.Y)
+		}
+	}
+
+	if !r.Rules.Fog {
+		for _, p := range r.Maze.Points() {
+			c := r.Maze.CellAt(p)
+			walls := 0
+			for bit, wall := range []bool{c.Top, c.Right, c.Bottom, c.Left, c.StairsUp, c.StairsDown} {
+				if wall {
+					walls |= 1 << bit
+				}
+			}
+			fmt.Fprintf(h, "c:%d,%d,%d,%s,%s;", c.X, c.Y, walls, c.Terrain, c.Under)
+		}
+	}
+
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// broadcastSnapshotLocked sends every client the dynamic room state and its
+// hash once per SnapshotInterval. A degraded room only sends the hash, less
+// often; clients that drift can still ask for a resync.
+func (r *Room) broadcastSnapshotLocked(now time.Time) {
+	if now.Before(r.nextSnapshot) {
+		return
+	}
+
+	msg := messages.ServerMessage{
+		Type:    "snapshot",
+		State:   string(r.State),
+		Tick:    r.tickCount,
+		Version: r.version,
+		Hash:    r.stateHashLocked(),
+	}
+	if r.degraded {
+		r.nextSnapshot = now.Add(DegradedSnapshotInterval)
+	} else {
+		r.nextSnapshot = now.Add(SnapshotInterval)
+		msg.Players = r.playersLocked()
+		msg.Items = r.itemsLocked()
+		msg.Hazards = r.hazardsLocked()
+	}
+	r.broadcastLocked(msg, "")
+}
+
+// Resync sends a player the full room state, as they are allowed to see it,
+// so a client that detected drift can rebuild its model from scratch
+func (r *Room) Resync(playerID string) bool {
+	return r.sendFullSnapshot(playerID, "resync")
+}
+
+// RequestSnapshot sends a client the complete current room state: the maze
+// with every change applied, players and scores, items, and the tick count.
+// Used after reconnects, detected desyncs, or spectator joins.
+//
+// A client that knows the room version it last caught up to (from any
+// snapshot) can pass it as sinceVersion: if the room's history still covers
+// it, only the messages sent to that client since then are replayed in a
+// "fastForward" batch, which is far smaller than a full snapshot.
+func (r *Room) RequestSnapshot(clientID string, sinceVersion uint64) bool {
+	if sinceVersion == 0 {
+		return r.sendFullSnapshot(clientID, "fullSnapshot")
+	}
+
+	r.mu.Lock()
+	if _, subscribed := r.Clients[clientID]; !subscribed {
+		r.mu.Unlock()
+		return false
+	}
+	r.flushMazeUpdatesLocked()
+	missed, ok := r.missedSinceLocked(clientID, sinceVersion)
+	if ok {
+		r.sendLocked(clientID, messages.ServerMessage{
+			Type:    "fastForward",
+			Batch:   missed,
+			Tick:    r.tickCount,
+			Version: r.version,
+			Hash:    r.stateHashLocked(),
+		})
+	}
+	r.mu.Unlock()
+
+	if !ok {
+		return r.sendFullSnapshot(clientID, "fullSnapshot")
+	}
+	return true
+}
+
+func (r *Room) sendFullSnapshot(clientID, msgType string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, subscribed := r.Clients[clientID]; !subscribed {
+		return false
+	}
+
+	// Changes still queued for a flush are already applied to the maze
+	// this snapshot carries, so send them first to keep ordering sane
+	r.flushMazeUpdatesLocked()
+
+	data, visible := r.mazeDataForLocked(clientID)
+	r.sendLocked(clientID, messages.ServerMessage{
+		Type:    msgType,
+		Maze:    data,
+		Cells:   visible,
+		State:   string(r.State),
+		Players: r.playersLocked(),
+		Items:   r.itemsLocked(),
+		Round:   r.round + 1,
+		Tick:    r.tickCount,
+		Version: r.version,
+		Hash:    r.stateHashLocked(),
+	})
+	return true
+}
@@ -0,0 +1,72 @@
+package room
+
+import "labyrinth-duel/websocket/internal/messages"
+
+// HistorySize is how many sent messages a room keeps for fast-forwarding
+// clients that fell behind
+const HistorySize = 1024
+
+// sentMessage is a message delivered to one client, stamped with the room
+// version it produced
+type sentMessage struct {
+	version uint64
+	to      string
+	msg     messages.ServerMessage
+}
+
+// recoveryMessages carry whole-state copies, so replaying them is pointless
+var recoveryMessages = map[string]bool{
+	"snapshot":     true,
+	"fullSnapshot": true,
+	"resync":       true,
+	"fastForward":  true,
+}
+
+// recordLocked advances the room version, stamps the message's envelope and
+// remembers it. The history is trimmed back to HistorySize once it doubles,
+// so appends stay cheap.
+//
+// Seq is the room version the message produced (recovery messages don't
+// produce one and carry the current version), and PrevSeq is the Seq of the
+// previous message sent to the same client. A client that sees PrevSeq
+// differ from the last Seq it received has missed something and should
+// resync.
+func (r *Room) recordLocked(to string, msg messages.ServerMessage) messages.ServerMessage {
+	if !recoveryMessages[msg.Type] {
+		r.version++
+	}
+	if r.lastSeq == nil {
+		r.lastSeq = make(map[string]uint64)
+	}
+	msg.Seq = r.version
+	msg.PrevSeq = r.lastSeq[to]
+	r.lastSeq[to] = msg.Seq
+
+	if recoveryMessages[msg.Type] {
+		return msg
+	}
+	r.history = append(r.history, sentMessage{version: r.version, to: to, msg: msg})
+	if len(r.history) >= 2*HistorySize {
+		r.history = append([]sentMessage(nil), r.history[len(r.history)-HistorySize:]...)
+	}
+	return msg
+}
+
+// missedSinceLocked returns the messages a client was sent after the given
+// room version, or false if the history no longer reaches back that far
+func (r *Room) missedSinceLocked(clientID string, since uint64) ([]messages.ServerMessage, bool) {
+	if since > r.version {
+		return nil, false
+	}
+	if len(r.history) > 0 && r.history[0].version > since+1 {
+		return nil, false
+	}
+
+	var missed []messages.ServerMessage
+	for _, sent := range r.history {
+		if sent.version > since && sent.to == clientID {
+			missed = append(missed, sent.msg)
+		}
+	}
+	return missed, true
+}
@@ -0,0 +1,222 @@
+package room
+
+import (
+	"errors"
+
+	"labyrinth-duel/websocket/internal/game"
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+// Errors returned by the host's commands
+var (
+	ErrRoomLocked      = errors.New("room is locked")
+	ErrKickSelf        = errors.New("cannot kick yourself")
+	ErrHostBot         = errors.New("a bot cannot host the room")
+	ErrNotBetweenRound = errors.New("maze settings can only change in the lobby or between rounds")
+	ErrBadMazeSettings = errors.New("unknown algorithm, theme or difficulty, or a share outside 0-1")
+)
+
+// Reasons a room changes host
+const (
+	HostTransferred = "transfer" // The host handed the room over
+	HostLeft        = "left"     // The host left and the longest-present player took over
+)
+
+// GetHost returns the player hosting the room, if anyone
+func (r *Room) GetHost() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.Host
+}
+
+// HostKick removes a player for the host. A banned player is kept out for
+// good; a kicked one may join again.
+func (r *Room) HostKick(hostID, playerID string, ban bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if hostID != r.Host {
+		return ErrNotHost
+	}
+	if playerID == hostID {
+		return ErrKickSelf
+	}
+	if _, exists := r.Players[playerID]; !exists {
+		return ErrNoPlayer
+	}
+	notice := messages.ServerMessage{Type: "hostKicked", RoomID: r.ID, Reason: "kicked"}
+	if ban {
+		notice.Reason = "banned"
+		r.kickLocked(playerID, notice)
+		return nil
+	}
+	r.removeWithNoticeLocked(playerID, notice)
+	return nil
+}
+
+// TransferHost hands the room to another player
+func (r *Room) TransferHost(hostID, playerID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if hostID != r.Host {
+		return ErrNotHost
+	}
+	player, exists := r.Players[playerID]
+	if !exists {
+		return ErrNoPlayer
+	}
+	if player.Bot {
+		return ErrHostBot
+	}
+	r.setHostLocked(playerID, HostTransferred)
+	return nil
+}
+
+// SetLocked locks the room against players who aren't already in it, or
+// opens it again
+func (r *Room) SetLocked(hostID string, locked bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if hostID != r.Host {
+		return ErrNotHost
+	}
+	if r.Access.Locked == locked {
+		return nil
+	}
+	r.Access.Locked = locked
+	msgType := "roomUnlocked"
+	if locked {
+		msgType = "roomLocked"
+	}
+	r.broadcastLocked(messages.ServerMessage{Type: msgType, RoomID: r.ID, Message: hostID}, "")
+	return nil
+}
+
+// SetMazeSettings changes the options the room's mazes are generated with,
+// for the host. Empty fields are left as they were. It only works in the
+// lobby or in the countdown between rounds, where the maze is replaced at
+// once; a room playing a level goes back to generated mazes.
+func (r *Room) SetMazeSettings(hostID string, opts game.Options) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if hostID != r.Host {
+		return ErrNotHost
+	}
+	if r.State != StateWaiting && r.State != StateCountdown || r.veto != nil {
+		return ErrNotBetweenRound
+	}
+	if _, ok := game.GeneratorByName(opts.Algorithm); opts.Algorithm != "" && !ok {
+		return ErrBadMazeSettings
+	}
+	if _, ok := game.ThemeByName(opts.Theme); opts.Theme != "" && !ok {
+		return ErrBadMazeSettings
+	}
+	if opts.Difficulty != "" && !game.IsDifficulty(opts.Difficulty) {
+		return ErrBadMazeSettings
+	}
+	if opts.Floors < 0 || opts.Floors > game.MaxFloors {
+		return ErrBadMazeSettings
+	}
+	floors := r.mazeOpts.Floors
+	if opts.Floors > 0 {
+		floors = opts.Floors
+	}
+	if floors > 1 && !r.showsFloorsLocked() {
+		return ErrNeedsFloors
+	}
+	for _, share := range []float64{opts.LoopFactor, opts.TerrainDensity, opts.CrossingDensity, opts.MinPathRatio} {
+		if share < 0 || share > 1 {
+			return ErrBadMazeSettings
+		}
+	}
+
+	mo := &r.mazeOpts
+	if opts.Algorithm != "" {
+		mo.Algorithm = opts.Algorithm
+	}
+	if opts.Theme != "" {
+		mo.Theme = opts.Theme
+	}
+	if opts.LoopFactor > 0 {
+		mo.LoopFactor = opts.LoopFactor
+	}
+	if opts.TerrainDensity > 0 {
+		mo.TerrainDensity = opts.TerrainDensity
+	}
+	if opts.CrossingDensity > 0 {
+		mo.CrossingDensity = opts.CrossingDensity
+	}
+	if opts.Floors > 0 {
+		mo.Floors = opts.Floors
+	}
+	if opts.MinPathRatio > 0 {
+		mo.MinPathRatio = opts.MinPathRatio
+	}
+	if opts.GoalCount > 0 {
+		mo.GoalCount = opts.GoalCount
+	}
+	if opts.Seed != 0 {
+		mo.Seed = opts.Seed
+	}
+	if opts.Difficulty != "" {
+		mo.Difficulty = opts.Difficulty
+	}
+
+	// The next round's maze is drawn off the seed, so this one is too
+	next := r.mazeOpts
+	if next.Seed != 0 {
+		next.Seed += int64(r.round)
+	}
+	r.level = nil
+	r.replaceMazeLocked(game.Generate(r.Maze.Width, r.Maze.Height, next))
+	return nil
+}
+
+// setHostLocked makes a player the host and tells everyone why
+func (r *Room) setHostLocked(playerID, reason string) {
+	r.Host = playerID
+	r.broadcastLocked(messages.ServerMessage{
+		Type:   "hostChanged",
+		RoomID: r.ID,
+		Host:   playerID,
+		Reason: reason,
+	}, "")
+}
+
+// migrateHostLocked hands the room of a host who left to the player who
+// has been in it longest, if anyone is left to host it
+func (r *Room) migrateHostLocked() {
+	var next *PlayerState
+	for _, p := range r.Players {
+		if p.Bot || r.Clients[p.ID] == nil {
+			continue
+		}
+		if next == nil || p.joinedAt.Before(next.joinedAt) || p.joinedAt.Equal(next.joinedAt) && p.ID < next.ID {
+			next = p
+		}
+	}
+	if next == nil {
+		r.Host = ""
+		return
+	}
+	r.setHostLocked(next.ID, HostLeft)
+}
+
+// removeWithNoticeLocked removes a player, telling them why with notice
+// and everyone else that they left
+func (r *Room) removeWithNoticeLocked(playerID string, notice messages.ServerMessage) {
+	client := r.Clients[playerID]
+	r.sendLocked(playerID, notice)
+	if leaver, ok := client.(Leaver); ok {
+		leaver.LeftRoom(r.ID)
+	}
+	r.removePlayerLocked(playerID)
+	r.broadcastLocked(messages.ServerMessage{
+		Type:    "playerLeft",
+		Message: playerID,
+		Players: r.playersLocked(),
+	}, "")
+}
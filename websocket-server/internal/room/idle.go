@@ -0,0 +1,68 @@
+package room
+
+import (
+	"math"
+	"time"
+
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+// DefaultIdleGrace is how long a player flagged idle has to do something
+// before they are removed, unless Settings.IdleGrace says otherwise
+const DefaultIdleGrace = 30 * time.Second
+
+// MarkActive records that a player did something, bringing them back if
+// they had been flagged idle
+func (r *Room) MarkActive(playerID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, exists := r.Players[playerID]
+	if !exists {
+		return
+	}
+	p.lastActionAt = r.clock.Now()
+	if !p.Idle {
+		return
+	}
+	p.Idle = false
+	r.broadcastLocked(messages.ServerMessage{
+		Type:    "playerActive",
+		Message: playerID,
+		Players: r.playersLocked(),
+	}, "")
+}
+
+// updateIdleLocked flags players who have done nothing for the idle timeout
+// and removes them once the grace after it runs out too, freeing their
+// slot. Bots never idle, and nobody idles while the match is paused.
+func (r *Room) updateIdleLocked(now time.Time) {
+	if r.idleTimeout <= 0 || r.State == StatePaused {
+		return
+	}
+	for id, p := range r.Players {
+		if p.Bot {
+			continue
+		}
+		if p.lastActionAt.IsZero() {
+			// Restored from a saved match: the clock starts now
+			p.lastActionAt = now
+		}
+		idle := now.Sub(p.lastActionAt)
+		switch {
+		case p.Idle && idle >= r.idleTimeout+r.idleGrace:
+			r.removeWithNoticeLocked(id, messages.ServerMessage{Type: "idleRemoved", RoomID: r.ID})
+			if r.onIdleRemoved != nil {
+				r.onIdleRemoved(r, id)
+			}
+		case !p.Idle && idle >= r.idleTimeout:
+			p.Idle = true
+			r.broadcastLocked(messages.ServerMessage{
+				Type:    "playerIdle",
+				Message: id,
+				Seconds: int(math.Ceil(r.idleGrace.Seconds())),
+				Players: r.playersLocked(),
+			}, "")
+		}
+	}
+}
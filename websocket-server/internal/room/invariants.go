@@ -0,0 +1,125 @@
+package room
+
+import (
+	"fmt"
+	"log/slog"
+
+	"labyrinth-duel/websocket/internal/game"
+)
+
+// Invariant names reported in a Violation
+const (
+	InvariantInBounds      = "inBounds"      // Players and items lie inside the maze
+	InvariantLevel         = "level"         // Only crossing cells have a tunnel level
+	InvariantStep          = "step"          // A walked move is one open step from the previous cell
+	InvariantScore         = "score"         // Scores are never negative
+	InvariantWallCharges   = "wallCharges"   // Wall charges are never negative
+	InvariantItemPlacement = "itemPlacement" // Items are keyed by the cell they lie on
+)
+
+// Violation is a broken engine invariant, with enough context to debug it
+type Violation struct {
+	Invariant string
+	PlayerID  string // Empty for room-wide invariants
+	Detail    string
+	Player    *PlayerState // Copy of the player at the time, if any
+	Cell      *game.Cell   // Copy of the relevant maze cell, if any
+}
+
+func (v Violation) String() string {
+	s := fmt.Sprintf("%s: %s", v.Invariant, v.Detail)
+	if v.PlayerID != "" {
+		s = fmt.Sprintf("player %s %s", v.PlayerID, s)
+	}
+	if v.Player != nil {
+		s += fmt.Sprintf(" [player at %v level %d, score %d, charges %d]",
+			v.Player.at(), v.Player.Level, v.Player.Score, v.Player.WallCharges)
+	}
+	if v.Cell != nil {
+		s += fmt.Sprintf(" [cell %v walls T:%v R:%v B:%v L:%v stairs U:%v D:%v under %q]",
+			game.Point{X: v.Cell.X, Y: v.Cell.Y, Z: v.Cell.Z}, v.Cell.Top, v.Cell.Right, v.Cell.Bottom, v.Cell.Left,
+			v.Cell.StairsUp, v.Cell.StairsDown, v.Cell.Under)
+	}
+	return s
+}
+
+// step is a move a player walked, kept until the invariants are checked
+type step struct {
+	from, to  game.Point
+	fromLevel int
+}
+
+// CheckInvariants checks the engine invariants and returns every violation.
+// Each walked move is checked once, by the first call after it happens.
+func (r *Room) CheckInvariants() []Violation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.checkInvariantsLocked()
+}
+
+func (r *Room) checkInvariantsLocked() []Violation {
+	var found []Violation
+	flag := func(invariant string, p *PlayerState, cell *game.Cell, format string, args ...interface{}) {
+		v := Violation{Invariant: invariant, Detail: fmt.Sprintf(format, args...), Cell: cell}
+		if p != nil {
+			cp := *p
+			v.PlayerID, v.Player = p.ID, &cp
+		}
+		found = append(found, v)
+	}
+
+	m := r.Maze
+	for _, p := range r.Players {
+		if !m.Contains(p.at()) {
+			flag(InvariantInBounds, p, nil, "outside the %dx%dx%d maze", m.Width, m.Height, m.FloorCount())
+		} else {
+			cell := *m.CellAt(p.at())
+			if p.Level != game.LevelSurface && (p.Level != game.LevelUnder || cell.Under == "") {
+				flag(InvariantLevel, p, &cell, "level %d outside a crossing", p.Level)
+			}
+		}
+
+		if s := p.lastStep; s != nil {
+			p.lastStep = nil
+			if !m.Contains(s.from) {
+				flag(InvariantStep, p, nil, "walked from %v, outside the maze", s.from)
+			} else if abs(s.to.X-s.from.X)+abs(s.to.Y-s.from.Y)+abs(s.to.Z-s.from.Z) != 1 {
+				flag(InvariantStep, p, nil, "walked from %v to %v, not a single step", s.from, s.to)
+			} else if _, ok := m.Step(s.from, s.fromLevel, s.to); !ok {
+				cell := *m.CellAt(s.from)
+				flag(InvariantStep, p, &cell, "walked from %v level %d to %v through a wall or gap",
+					s.from, s.fromLevel, s.to)
+			}
+		}
+
+		if p.Score < 0 {
+			flag(InvariantScore, p, nil, "negative score %d", p.Score)
+		}
+		if p.WallCharges < 0 {
+			flag(InvariantWallCharges, p, nil, "negative wall charges %d", p.WallCharges)
+		}
+	}
+
+	for cell, item := range r.Items {
+		at := game.Point{X: item.X, Y: item.Y, Z: item.Z}
+		if !m.Contains(at) {
+			flag(InvariantInBounds, nil, nil, "item %s at %v outside the maze", item.ID, at)
+		}
+		if cell != at {
+			flag(InvariantItemPlacement, nil, nil, "item %s at %v filed under %v", item.ID, at, cell)
+		}
+	}
+
+	return found
+}
+
+// debugCheckLocked runs the invariant checker in debug mode and logs every
+// violation with its context
+func (r *Room) debugCheckLocked(after string) {
+	if !r.Debug {
+		return
+	}
+	for _, v := range r.checkInvariantsLocked() {
+		slog.Error("Invariant violation", "room", r.ID, "after", after, "violation", v)
+	}
+}
@@ -0,0 +1,141 @@
+package room
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"labyrinth-duel/websocket/internal/clock"
+	"labyrinth-duel/websocket/internal/game"
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+// discard is a connection that drops everything sent to it
+type discard struct{}
+
+func (discard) SendJSON(messages.ServerMessage) {}
+
+// TestRandomSequences has two players make random moves, near and far, use
+// random power-ups and break random walls, through mazes with crossings,
+// loops and floors; the engine's invariants must hold after every action
+func TestRandomSequences(t *testing.T) {
+	tests := []struct {
+		name    string
+		maze    game.Options
+		actions []string // Drawn from at random for each step
+	}{
+		{"moves", game.Options{Seed: 7, CrossingDensity: 0.5, LoopFactor: 0.5}, []string{"move"}},
+		{"moves and items", game.Options{Seed: 8, LoopFactor: 0.3}, []string{"move", "move", "item"}},
+		{"moves and wall breaks", game.Options{Seed: 9, CrossingDensity: 0.5}, []string{"move", "move", "wall"}},
+		{"everything on floors", game.Options{Seed: 10, Floors: 2}, []string{"move", "move", "item", "wall"}},
+		{"everything on a weave", game.Options{Seed: 11, Algorithm: game.AlgorithmWeave}, []string{"move", "move", "item", "wall"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for run := int64(0); run < 5; run++ {
+				if err := randomSequence(tt.maze, tt.actions, run); err != nil {
+					t.Fatalf("run %d: %v", run, err)
+				}
+			}
+		})
+	}
+}
+
+// randomSequence plays one seeded random sequence of actions, returning the
+// first broken invariant
+func randomSequence(maze game.Options, actions []string, seed int64) error {
+	c := clock.NewManual(time.Now())
+	m := NewManager()
+	m.Clock = c
+	r, _ := m.GetOrCreateRoom("walk", Options{Maze: maze, Rules: RuleSet{WallCharges: 50}})
+	defer m.RemoveRoom("walk")
+
+	ids := []string{"walker-1", "walker-2"}
+	for _, id := range ids {
+		if err := r.AddPlayer(id, discard{}); err != nil {
+			return err
+		}
+		r.SetReady(id)
+	}
+	c.Advance(CountdownDuration + DefaultTickInterval)
+	if r.GetState() != StatePlaying {
+		return fmt.Errorf("match in state %q after the countdown", r.GetState())
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	directions := []string{"up", "right", "down", "left"}
+	// scatter drops power-ups on free cells for moves to pick up
+	scatter := func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		for len(r.Items) < MaxItems {
+			cell, ok := r.randomFreeCellLocked()
+			if !ok {
+				return
+			}
+			r.itemSeq++
+			r.placeItemLocked(&Item{ID: fmt.Sprintf("item-%d", r.itemSeq), Kind: PowerUps[rng.Intn(len(PowerUps))],
+				X: cell.X, Y: cell.Y, Z: cell.Z})
+		}
+	}
+	// grant hands a player a power-up, as if they had picked it up
+	grant := func(id, kind string) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		p := r.Players[id]
+		p.Inventory = append(p.Inventory, kind)
+	}
+	check := func() []Violation {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return r.checkInvariantsLocked()
+	}
+
+	accepted := 0
+	for i := 0; i < 400 && r.GetState() == StatePlaying; i++ {
+		id := ids[rng.Intn(len(ids))]
+		var from messages.Player
+		for _, p := range r.GetPlayers() {
+			if p.ID == id {
+				from = p
+			}
+		}
+
+		var did string
+		switch action := actions[rng.Intn(len(actions))]; action {
+		case "move":
+			x, y, z := from.X+rng.Intn(5)-2, from.Y+rng.Intn(5)-2, from.Z
+			if maze.Floors > 1 {
+				z += rng.Intn(3) - 1
+			}
+			if r.UpdatePlayerPosition(id, x, y, z) == nil {
+				accepted++
+			}
+			did = fmt.Sprintf("move to (%d, %d, %d)", x, y, z)
+		case "item":
+			scatter()
+			kind, dir := PowerUps[rng.Intn(len(PowerUps))], directions[rng.Intn(len(directions))]
+			grant(id, kind)
+			if r.UseItem(id, kind, dir) {
+				accepted++
+			}
+			did = fmt.Sprintf("use %s %s", kind, dir)
+		case "wall":
+			dir := directions[rng.Intn(len(directions))]
+			if r.BreakWall(id, dir) {
+				accepted++
+			}
+			did = "break the wall " + dir
+		}
+		if v := check(); len(v) > 0 {
+			return fmt.Errorf("step %d, %s tried to %s: %s", i, id, did, v[0])
+		}
+		c.Advance(BaseMoveInterval / 2)
+	}
+
+	if accepted == 0 {
+		return fmt.Errorf("no random action was ever accepted")
+	}
+	return nil
+}
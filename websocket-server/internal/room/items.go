@@ -0,0 +1,267 @@
+package room
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"labyrinth-duel/websocket/internal/game"
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+// Item kinds
+const (
+	ItemTreasure   = "treasure"   // Worth Value points when picked up
+	ItemSpeedBoost = "speedBoost" // Halves the move cooldown for a while
+	ItemWallBreak  = "wallBreak"  // Smashes the wall in a chosen direction
+	ItemTeleport   = "teleport"   // Jumps to a random reachable cell
+	ItemFreeze     = "freeze"     // Freezes every opponent in place
+	ItemHint       = "hint"       // Shows the way toward the nearest exit (RuleSet.Hints)
+)
+
+// PowerUps lists the item kinds a player can pick up and use
+var PowerUps = []string{ItemSpeedBoost, ItemWallBreak, ItemTeleport, ItemFreeze, ItemHint}
+
+const (
+	// ItemSpawnInterval is how often a new power-up appears during a match
+	ItemSpawnInterval = 10 * time.Second
+	// MaxItems caps how many items can lie in the maze at once
+	MaxItems = 5
+	// PickupPoints is awarded for grabbing a power-up
+	PickupPoints = 10
+	// SpeedBoostDuration is how long a speed boost lasts
+	SpeedBoostDuration = 5 * time.Second
+	// FreezeDuration is how long a freeze holds opponents
+	FreezeDuration = 3 * time.Second
+	// HintWeight is the hint's spawn weight in rooms with hints on, against
+	// the theme's weights for the other power-ups
+	HintWeight = 2
+	// HintLength is how many steps of the way a hint shows
+	HintLength = 8
+)
+
+// Item is something lying in the maze that a player can pick up
+type Item struct {
+	ID    string
+	Kind  string
+	X     int
+	Y     int
+	Z     int
+	Value int
+}
+
+// placeItemLocked puts an item on the floor
+func (r *Room) placeItemLocked(item *Item) {
+	r.Items[game.Point{X: item.X, Y: item.Y, Z: item.Z}] = item
+}
+
+// spawnItemsLocked drops a theme-weighted power-up at a random reachable
+// cell every ItemSpawnInterval
+func (r *Room) spawnItemsLocked(now time.Time) {
+	if r.tutorial != nil || now.Before(r.nextItemSpawn) {
+		return
+	}
+	r.nextItemSpawn = now.Add(ItemSpawnInterval)
+
+	if len(r.Items) >= MaxItems {
+		return
+	}
+
+	kind := r.pickItemKindLocked()
+	cell, ok := r.randomFreeCellLocked()
+	if kind == "" || !ok {
+		return
+	}
+
+	r.itemSeq++
+	item := &Item{
+		ID:   fmt.Sprintf("item-%d", r.itemSeq),
+		Kind: kind,
+		X:    cell.X,
+		Y:    cell.Y,
+		Z:    cell.Z,
+	}
+	r.placeItemLocked(item)
+
+	r.broadcastLocked(messages.ServerMessage{
+		Type:  "itemSpawned",
+		Items: []messages.Item{item.toMessage()},
+	}, "")
+}
+
+// pickItemKindLocked chooses a power-up using the maze theme's weights
+func (r *Room) pickItemKindLocked() string {
+	theme, ok := game.ThemeByName(r.Maze.Theme)
+	if !ok {
+		return ""
+	}
+
+	// Walk kinds in a fixed order so equal weights don't depend on map order
+	kinds := PowerUps
+	weights := make(map[string]int, len(kinds))
+	for k, w := range theme.ItemWeights {
+		weights[k] = w
+	}
+	if r.Rules.Hints {
+		weights[ItemHint] = HintWeight
+	}
+	total := 0
+	for _, k := range kinds {
+		total += weights[k]
+	}
+	if total == 0 {
+		return ""
+	}
+
+	roll := rand.Intn(total)
+	for _, k := range kinds {
+		roll -= weights[k]
+		if roll < 0 {
+			return k
+		}
+	}
+	return ""
+}
+
+// randomFreeCellLocked picks a cell reachable from the first spawn that has
+// no item, no player, and isn't the goal
+func (r *Room) randomFreeCellLocked() (game.Point, bool) {
+	dist := r.Maze.DistanceMap(r.Maze.Spawns[0])
+
+	occupied := make(map[game.Point]bool)
+	for _, p := range r.Players {
+		occupied[p.at()] = true
+	}
+
+	var free []game.Point
+	for _, cell := range r.Maze.Points() {
+		if dist.At(cell) < 0 || occupied[cell] || r.Items[cell] != nil || r.Maze.IsGoal(cell) {
+			continue
+		}
+		free = append(free, cell)
+	}
+	if len(free) == 0 {
+		return game.Point{}, false
+	}
+	return free[rand.Intn(len(free))], true
+}
+
+// pickupLocked collects whatever item lies under the player
+func (r *Room) pickupLocked(player *PlayerState, now time.Time) {
+	cell := player.at()
+	item, ok := r.Items[cell]
+	if !ok {
+		return
+	}
+	delete(r.Items, cell)
+	r.logEventLocked(Event{Type: EventItemPickup, PlayerID: player.ID, X: cell.X, Y: cell.Y, Z: cell.Z, Detail: item.Kind, At: now})
+
+	switch item.Kind {
+	case ItemTreasure:
+		r.awardPointsLocked(player.ID, item.Value, now)
+	default:
+		player.Inventory = append(player.Inventory, item.Kind)
+		r.awardPointsLocked(player.ID, PickupPoints, now)
+	}
+
+	r.broadcastLocked(messages.ServerMessage{
+		Type:    "itemPickedUp",
+		Message: player.ID,
+		Items:   []messages.Item{item.toMessage()},
+		Players: r.playersLocked(),
+	}, "")
+}
+
+// UseItem spends one power-up from the player's inventory and resolves its
+// effect as a single transaction. direction is only used by wallBreak.
+// Returns false if the player doesn't hold the item or it has no effect.
+func (r *Room) UseItem(playerID, kind, direction string) bool {
+	err := r.Transact(func(tx *Tx) error {
+		return r.useItemLocked(tx, playerID, kind, direction)
+	})
+	return err == nil
+}
+
+func (r *Room) useItemLocked(tx *Tx, playerID, kind, direction string) error {
+	if r.State != StatePlaying {
+		return ErrNotPlaying
+	}
+	player, err := tx.Player(playerID)
+	if err != nil {
+		return err
+	}
+	if err := tx.ConsumeItem(playerID, kind); err != nil {
+		return err
+	}
+
+	now := r.clock.Now()
+	switch kind {
+	case ItemSpeedBoost:
+		player.addSpeedModifier(ItemSpeedBoost, SpeedBoostFactor, SpeedBoostDuration, now)
+	case ItemWallBreak:
+		if !r.breakWallLocked(player, direction) {
+			return ErrNoEffect
+		}
+	case ItemTeleport:
+		cell, ok := r.randomFreeCellLocked()
+		if !ok {
+			return ErrNoEffect
+		}
+		if err := tx.Teleport(playerID, cell); err != nil {
+			return err
+		}
+	case ItemFreeze:
+		for id, p := range r.Players {
+			if id == playerID {
+				continue
+			}
+			p.frozenUntil = now.Add(FreezeDuration)
+			r.logEventLocked(Event{Type: EventStunned, PlayerID: id, X: p.X, Y: p.Y, Z: p.Z,
+				Value: int(FreezeDuration.Milliseconds())})
+		}
+	case ItemHint:
+		path := r.Maze.PathToGoal(player.at(), player.Level)
+		if len(path) < 2 {
+			return ErrNoEffect
+		}
+		if len(path) > HintLength+1 {
+			path = path[:HintLength+1]
+		}
+		hint := make([]messages.Position, len(path)-1)
+		for i, p := range path[1:] {
+			hint[i] = positionOf(p)
+		}
+		r.sendLocked(playerID, messages.ServerMessage{Type: "hint", Path: hint})
+	default:
+		return ErrNoEffect
+	}
+
+	r.logEventLocked(Event{Type: EventItemUsed, PlayerID: playerID, X: player.X, Y: player.Y, Z: player.Z, Detail: kind, At: now})
+	tx.Broadcast(messages.ServerMessage{
+		Type:    "itemUsed",
+		Message: playerID,
+		Items:   []messages.Item{{Kind: kind, X: player.X, Y: player.Y, Z: player.Z}},
+		Players: r.playersLocked(),
+	}, "")
+	return nil
+}
+
+func (i *Item) toMessage() messages.Item {
+	return messages.Item{ID: i.ID, Kind: i.Kind, X: i.X, Y: i.Y, Z: i.Z}
+}
+
+// GetItems returns every item lying in the maze
+func (r *Room) GetItems() []messages.Item {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.itemsLocked()
+}
+
+// itemsLocked is GetItems for callers already holding the room lock
+func (r *Room) itemsLocked() []messages.Item {
+	items := make([]messages.Item, 0, len(r.Items))
+	for _, item := range r.Items {
+		items = append(items, item.toMessage())
+	}
+	return items
+}
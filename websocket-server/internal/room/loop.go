@@ -0,0 +1,59 @@
+package room
+
+import "time"
+
+// DefaultTickInterval is how often the room loop updates timed state and
+// sends clients that take ticks their tick message: 20 Hz
+const DefaultTickInterval = 50 * time.Millisecond
+
+// run drives the room's timed state until Stop is called
+func (r *Room) run() {
+	r.stopTicks = r.clock.Every(r.tickInterval, r.tick)
+}
+
+// tick advances all time-based room state
+func (r *Room) tick(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	defer r.flushTicksLocked(now)
+	defer r.flushMazeUpdatesLocked()
+	defer r.debugCheckLocked("tick")
+
+	r.tickCount++
+	r.updateBandwidthLocked(now)
+	if r.skipTickLocked() {
+		return
+	}
+
+	r.updateVoteLocked(now)
+	r.updateIdleLocked(now)
+	r.updateVetoLocked(now)
+	r.updateAttractLocked(now)
+	r.updateTutorialLocked(now)
+	switch r.State {
+	case StateCountdown:
+		r.updateCountdownLocked(now)
+	case StatePaused:
+		r.updatePauseLocked(now)
+	case StatePlaying:
+		r.decayStreaksLocked(now)
+		r.spawnItemsLocked(now)
+		r.updateGoalMoveLocked(now)
+		r.updateMazeShiftLocked(now)
+		r.expireTrailsLocked(now)
+		r.updateFlagsLocked(now)
+		r.updateCampingLocked(now)
+		r.moveBotsLocked(now)
+		r.moveMinotaursLocked(now)
+		r.updateTimerLocked(now)
+	}
+	if r.State == StatePlaying {
+		r.broadcastSnapshotLocked(now)
+	}
+}
+
+// Stop shuts down the room loop
+func (r *Room) Stop() {
+	r.stopOnce.Do(func() { r.stopTicks() })
+}
@@ -0,0 +1,34 @@
+package room
+
+import "testing"
+
+// TestRemoveIfEmpty closes a room once its last player leaves: until then
+// it stays, and afterwards nobody can join it, only a new room by its ID
+func TestRemoveIfEmpty(t *testing.T) {
+	m := NewManager()
+	r, _ := m.GetOrCreateRoom("last", Options{})
+	if err := r.Join("stayer", discard{}, PlayerProfile{}, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if m.RemoveIfEmpty(r, "empty") {
+		t.Fatal("removed a room with a player in it")
+	}
+
+	r.RemovePlayer("stayer")
+	if !m.RemoveIfEmpty(r, "empty") {
+		t.Fatal("kept a room nobody is in")
+	}
+	if m.RemoveIfEmpty(r, "empty") {
+		t.Error("removed a room twice")
+	}
+	if err := r.Join("latecomer", discard{}, PlayerProfile{}, "", ""); err != ErrRoomClosed {
+		t.Errorf("joined a removed room: %v", err)
+	}
+	next, created := m.GetOrCreateRoom("last", Options{})
+	if !created || next == r {
+		t.Fatal("no new room in the removed one's place")
+	}
+	if m.RemoveIfEmpty(r, "empty") || m.GetRoom("last") != next {
+		t.Error("removing the old room again took out its replacement")
+	}
+}
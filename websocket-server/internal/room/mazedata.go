@@ -0,0 +1,185 @@
+package room
+
+import (
+	"labyrinth-duel/websocket/internal/game"
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+// MazeDataFor returns the maze as the given player may see it. With fog
+// enabled the cell grid is left out; the player's visible cells come back
+// separately and should be sent alongside as a reveal.
+func (r *Room) MazeDataFor(playerID string) (*messages.MazeData, []messages.Cell) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.mazeDataForLocked(playerID)
+}
+
+// mazeDataForLocked is MazeDataFor for callers already holding the room lock
+func (r *Room) mazeDataForLocked(playerID string) (*messages.MazeData, []messages.Cell) {
+	data := mazeToMessage(r.Maze)
+	if !r.Rules.Fog {
+		return data, nil
+	}
+
+	data.Cells, data.Upper = nil, nil
+	data.Fog = true
+
+	player, exists := r.Players[playerID]
+	if !exists {
+		return data, nil
+	}
+	return data, r.revealedCellsLocked(player)
+}
+
+// mazeToMessage converts game.Maze to messages.MazeData
+func mazeToMessage(m *game.Maze) *messages.MazeData {
+	floors := make([][][]messages.Cell, m.FloorCount())
+	for z := range floors {
+		floors[z] = make([][]messages.Cell, m.Height)
+		for y, row := range m.Floor(z) {
+			floors[z][y] = make([]messages.Cell, m.Width)
+			for x, c := range row {
+				floors[z][y][x] = cellToMessage(c)
+			}
+		}
+	}
+
+	data := &messages.MazeData{
+		Width:      m.Width,
+		Height:     m.Height,
+		Cells:      floors[0],
+		Goal:       positionOf(m.Goal),
+		Goals:      goalsToMessage(m.Goals),
+		Spawns:     spawnsToMessage(m.Spawns),
+		Portals:    portalsToMessage(m.Portals),
+		Seed:       m.Seed,
+		Algorithm:  m.Algorithm,
+		Theme:      m.Theme,
+		LoopFactor: m.LoopFactor,
+		Rating:     ratingToMessage(m.Rate()),
+	}
+	if len(floors) > 1 {
+		data.Floors, data.Upper = len(floors), floors[1:]
+	}
+	return data
+}
+
+// ratingToMessage converts game.Rating to messages.Rating
+func ratingToMessage(r game.Rating) *messages.Rating {
+	return &messages.Rating{
+		Tier:           r.Tier,
+		Score:          r.Score,
+		SolutionLength: r.SolutionLength,
+		BranchFactor:   r.BranchFactor,
+		DeadEnds:       r.DeadEnds,
+	}
+}
+
+// mazeFromMessage rebuilds a game.Maze from its wire format, e.g. to find
+// paths on a recorded round's maze
+func mazeFromMessage(d *messages.MazeData) *game.Maze {
+	m := &game.Maze{
+		Width:      d.Width,
+		Height:     d.Height,
+		Cells:      cellsFromMessage(d.Cells),
+		Goal:       pointOf(d.Goal),
+		Seed:       d.Seed,
+		Algorithm:  d.Algorithm,
+		Theme:      d.Theme,
+		LoopFactor: d.LoopFactor,
+	}
+	for _, floor := range d.Upper {
+		m.Upper = append(m.Upper, cellsFromMessage(floor))
+	}
+	for _, g := range d.Goals {
+		m.Goals = append(m.Goals, game.Goal{Point: game.Point{X: g.X, Y: g.Y, Z: g.Z}, Value: g.Value})
+	}
+	for _, s := range d.Spawns {
+		m.Spawns = append(m.Spawns, pointOf(s))
+	}
+	for _, p := range d.Portals {
+		m.Portals = append(m.Portals, game.Portal{A: pointOf(p.A), B: pointOf(p.B)})
+	}
+	return m
+}
+
+// cellsFromMessage converts one floor of wire cells back to game cells
+func cellsFromMessage(rows [][]messages.Cell) [][]game.Cell {
+	cells := make([][]game.Cell, len(rows))
+	for y, row := range rows {
+		cells[y] = make([]game.Cell, len(row))
+		for x, c := range row {
+			cells[y][x] = game.Cell{
+				X:          c.X,
+				Y:          c.Y,
+				Z:          c.Z,
+				Top:        c.Top,
+				Right:      c.Right,
+				Bottom:     c.Bottom,
+				Left:       c.Left,
+				StairsUp:   c.StairsUp,
+				StairsDown: c.StairsDown,
+				Terrain:    game.Terrain(c.Terrain),
+				Under:      c.Under,
+			}
+		}
+	}
+	return cells
+}
+
+// positionOf converts a maze point to its wire format
+func positionOf(p game.Point) messages.Position {
+	return messages.Position{X: p.X, Y: p.Y, Z: p.Z}
+}
+
+// pointOf converts a wire position to a maze point
+func pointOf(p messages.Position) game.Point {
+	return game.Point{X: p.X, Y: p.Y, Z: p.Z}
+}
+
+// goalsToMessage converts the maze's exits to their wire format
+func goalsToMessage(goals []game.Goal) []messages.Goal {
+	out := make([]messages.Goal, len(goals))
+	for i, g := range goals {
+		out[i] = messages.Goal{X: g.X, Y: g.Y, Z: g.Z, Value: g.Value}
+	}
+	return out
+}
+
+// spawnsToMessage converts the maze's spawn points to their wire format
+func spawnsToMessage(spawns []game.Point) []messages.Position {
+	out := make([]messages.Position, len(spawns))
+	for i, s := range spawns {
+		out[i] = positionOf(s)
+	}
+	return out
+}
+
+// portalsToMessage converts the maze's portal links to their wire format
+func portalsToMessage(portals []game.Portal) []messages.Portal {
+	if len(portals) == 0 {
+		return nil
+	}
+	out := make([]messages.Portal, len(portals))
+	for i, p := range portals {
+		out[i] = messages.Portal{A: positionOf(p.A), B: positionOf(p.B)}
+	}
+	return out
+}
+
+// cellToMessage converts a single game.Cell to its wire format
+func cellToMessage(c game.Cell) messages.Cell {
+	return messages.Cell{
+		X:          c.X,
+		Y:          c.Y,
+		Z:          c.Z,
+		Top:        c.Top,
+		Right:      c.Right,
+		Bottom:     c.Bottom,
+		Left:       c.Left,
+		StairsUp:   c.StairsUp,
+		StairsDown: c.StairsDown,
+		Terrain:    string(c.Terrain),
+		Under:      c.Under,
+	}
+}
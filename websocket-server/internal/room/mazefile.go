@@ -0,0 +1,60 @@
+package room
+
+import (
+	"time"
+
+	"labyrinth-duel/websocket/internal/game"
+	"labyrinth-duel/websocket/internal/level"
+)
+
+// GetMaze returns a copy of the room's current maze, e.g. to export it
+func (r *Room) GetMaze() *game.Maze {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.Maze.Clone()
+}
+
+// LoadMaze swaps in a maze from a file, checked with game.Maze.Validate and
+// level.CheckPlayable, for the coming round; later rounds are generated as
+// before. Like maze settings, it only works in the lobby or in the
+// countdown between rounds.
+func (r *Room) LoadMaze(maze *game.Maze) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.State != StateWaiting && r.State != StateCountdown || r.veto != nil {
+		return ErrNotBetweenRound
+	}
+	if maze.FloorCount() > 1 && !r.showsFloorsLocked() {
+		return ErrNeedsFloors
+	}
+	maze = maze.Clone()
+	if maze.Theme == "" {
+		maze.Theme = r.Maze.Theme
+	}
+	r.replaceMazeLocked(maze)
+	return nil
+}
+
+// levelMaze returns a fresh copy of an uploaded level's maze to play a
+// round on, in the theme the room's mazes would have had if the level
+// doesn't name one
+func levelMaze(l *level.Level, opts game.Options) *game.Maze {
+	maze := l.Maze.Clone()
+	if _, ok := game.ThemeByName(maze.Theme); !ok {
+		maze.Theme = opts.Theme
+		if _, ok := game.ThemeByName(maze.Theme); !ok {
+			maze.Theme = game.SeasonalTheme(time.Now())
+		}
+	}
+	return maze
+}
+
+// generateLocked makes the maze for another round: the room's level again
+// if it plays one, else a new maze of the given size
+func (r *Room) generateLocked(width, height int, opts game.Options) *game.Maze {
+	if r.level != nil {
+		return levelMaze(r.level, r.mazeOpts)
+	}
+	return game.Generate(width, height, opts)
+}
@@ -0,0 +1,209 @@
+package room
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"labyrinth-duel/websocket/internal/game"
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+// MinotaurDifficulty tunes the hazards that prowl a room's maze
+type MinotaurDifficulty struct {
+	Name         string
+	Count        int           // How many prowl the maze
+	MoveInterval time.Duration // Time between steps
+	AggroRadius  int           // Corridor distance within which one charges the nearest player rather than patrol
+}
+
+// Minotaur difficulties
+var (
+	MinotaurEasy   = MinotaurDifficulty{Name: "easy", Count: 1, MoveInterval: 600 * time.Millisecond, AggroRadius: 4}
+	MinotaurMedium = MinotaurDifficulty{Name: "medium", Count: 1, MoveInterval: 400 * time.Millisecond, AggroRadius: 6}
+	MinotaurHard   = MinotaurDifficulty{Name: "hard", Count: 2, MoveInterval: 250 * time.Millisecond, AggroRadius: 8}
+)
+
+var minotaurDifficulties = map[string]MinotaurDifficulty{
+	MinotaurEasy.Name:   MinotaurEasy,
+	MinotaurMedium.Name: MinotaurMedium,
+	MinotaurHard.Name:   MinotaurHard,
+}
+
+// MinotaurDifficultyByName looks up a difficulty
+func MinotaurDifficultyByName(name string) (MinotaurDifficulty, bool) {
+	d, ok := minotaurDifficulties[name]
+	return d, ok
+}
+
+// MinotaurPenalty is what being caught costs, on top of the trip back to
+// spawn
+const MinotaurPenalty = 50
+
+// minotaur is one server-controlled hazard
+type minotaur struct {
+	id string
+	game.Point
+	patrol     game.Point // Where it wanders to while nobody is near
+	chasing    string     // Player it is charging, if any
+	nextMoveAt time.Time
+}
+
+// spawnMinotaursLocked puts the room's minotaurs at the start of a round on
+// random cells at least twice their aggro radius from every spawn, so
+// nobody is charged from the start
+func (r *Room) spawnMinotaursLocked(now time.Time) {
+	r.minotaurs = nil
+	d, ok := MinotaurDifficultyByName(r.Rules.Minotaur)
+	if !ok {
+		return
+	}
+
+	var far []game.Point
+	nearest := r.Maze.NearestDistanceMap(r.Maze.Spawns)
+	for _, p := range r.Maze.Points() {
+		if nearest.At(p) >= 2*d.AggroRadius {
+			far = append(far, p)
+		}
+	}
+	if len(far) == 0 {
+		far = []game.Point{r.Maze.Goal}
+	}
+
+	for i := range d.Count {
+		at := far[rand.Intn(len(far))]
+		r.minotaurs = append(r.minotaurs, &minotaur{
+			id:         fmt.Sprintf("minotaur-%d", i+1),
+			Point:      at,
+			patrol:     at,
+			nextMoveAt: now.Add(d.MoveInterval),
+		})
+	}
+	r.broadcastMinotaursLocked()
+}
+
+// moveMinotaursLocked steps every minotaur whose turn it is: toward the
+// nearest player within its aggro radius, or else on toward a random cell
+func (r *Room) moveMinotaursLocked(now time.Time) {
+	d, ok := MinotaurDifficultyByName(r.Rules.Minotaur)
+	if !ok {
+		return
+	}
+	moved := false
+	for _, m := range r.minotaurs {
+		if now.Before(m.nextMoveAt) {
+			continue
+		}
+		m.nextMoveAt = now.Add(d.MoveInterval)
+
+		target := r.preyLocked(m, d.AggroRadius)
+		if target == nil {
+			m.chasing = ""
+			if m.Point == m.patrol {
+				m.patrol = game.Point{X: rand.Intn(r.Maze.Width), Y: rand.Intn(r.Maze.Height), Z: rand.Intn(r.Maze.FloorCount())}
+			}
+		} else {
+			m.chasing = target.ID
+			m.patrol = target.at()
+			if m.Point == m.patrol {
+				r.catchLocked(target, m, now) // Landed on it some other way, e.g. a portal
+				continue
+			}
+		}
+		path := r.Maze.PathFrom(m.Point, game.LevelSurface, []game.Point{m.patrol})
+		if len(path) < 2 {
+			m.patrol = m.Point // Out of reach: pick another next time
+			continue
+		}
+		m.Point = path[1]
+		moved = true
+
+		for _, p := range r.Players {
+			if p.at() == m.Point {
+				r.catchLocked(p, m, now)
+			}
+		}
+	}
+	if moved {
+		r.broadcastMinotaursLocked()
+	}
+}
+
+// preyLocked returns the player nearest a minotaur by corridor within
+// radius, if any. Players who already made it out are safe.
+func (r *Room) preyLocked(m *minotaur, radius int) *PlayerState {
+	dist := r.Maze.DistanceMap(m.Point)
+	var prey *PlayerState
+	best := radius + 1
+	for _, p := range r.Players {
+		if d := dist.At(p.at()); !p.home && d >= 0 && (d < best || d == best && prey != nil && p.ID < prey.ID) {
+			prey, best = p, d
+		}
+	}
+	return prey
+}
+
+// meetMinotaurLocked catches a player who walked into a minotaur
+func (r *Room) meetMinotaurLocked(player *PlayerState, now time.Time) {
+	for _, m := range r.minotaurs {
+		if m.Point == player.at() {
+			r.catchLocked(player, m, now)
+			return
+		}
+	}
+}
+
+// catchLocked sends a player a minotaur caught back to their spawn, down
+// MinotaurPenalty points and their streak, dropping any flag they carry
+func (r *Room) catchLocked(player *PlayerState, m *minotaur, now time.Time) {
+	if player.home || r.State != StatePlaying {
+		return
+	}
+	r.dropFlagOfLocked(player.ID, now)
+	if player.Spawn >= len(r.Maze.Spawns) {
+		player.Spawn = 0
+	}
+	spawn := r.Maze.Spawns[player.Spawn]
+	player.moveTo(spawn)
+	player.Level = game.LevelSurface
+	player.Score = max(0, player.Score-MinotaurPenalty)
+	player.Streak = 0
+	m.chasing = ""
+	r.logEventLocked(Event{Type: EventCaught, PlayerID: player.ID, X: m.X, Y: m.Y, Z: m.Z, Detail: m.id, At: now})
+	r.sendRevealLocked(player)
+
+	r.broadcastLocked(messages.ServerMessage{
+		Type:     "minotaurCaught",
+		Message:  player.ID,
+		Position: &messages.Position{X: m.X, Y: m.Y, Z: m.Z},
+		Players:  r.playersLocked(),
+	}, "")
+	r.broadcastMoveLocked(player.ID)
+}
+
+// hazardsLocked lists the minotaurs in their wire format
+func (r *Room) hazardsLocked() []messages.Hazard {
+	if len(r.minotaurs) == 0 {
+		return nil
+	}
+	hazards := make([]messages.Hazard, len(r.minotaurs))
+	for i, m := range r.minotaurs {
+		hazards[i] = messages.Hazard{ID: m.id, Kind: "minotaur", X: m.X, Y: m.Y, Z: m.Z, Chasing: m.chasing}
+	}
+	return hazards
+}
+
+// GetHazards returns the server-controlled hazards in the maze
+func (r *Room) GetHazards() []messages.Hazard {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.hazardsLocked()
+}
+
+// broadcastMinotaursLocked tells everyone where the minotaurs are
+func (r *Room) broadcastMinotaursLocked() {
+	r.broadcastLocked(messages.ServerMessage{
+		Type:    "hazardsMoved",
+		Hazards: r.hazardsLocked(),
+	}, "")
+}
@@ -0,0 +1,100 @@
+package room
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+// GameMode adds mode-specific rules to a room. Its client messages are
+// namespaced "<mode>.<action>", e.g. "tag.transfer", and only reach it while
+// it is the room's mode.
+type GameMode interface {
+	// Name is the mode's message namespace
+	Name() string
+	// HandleMessage handles one of the mode's messages inside a room
+	// transaction: an error rolls back the room changes the handler made
+	// through tx. The mode's own state isn't rolled back, so check before
+	// changing it.
+	HandleMessage(tx *Tx, playerID, action string, msg messages.ClientMessage) error
+}
+
+// Errors returned when routing mode messages
+var (
+	ErrUnknownMode   = errors.New("unknown game mode")
+	ErrModeInactive  = errors.New("game mode is not active in this room")
+	ErrUnknownAction = errors.New("unknown action for this game mode")
+)
+
+var (
+	modes   = make(map[string]func() GameMode)
+	modesMu sync.RWMutex
+)
+
+// RegisterMode makes a game mode available to rooms. newMode is called once
+// for every room created with the mode.
+func RegisterMode(name string, newMode func() GameMode) {
+	modesMu.Lock()
+	defer modesMu.Unlock()
+	modes[name] = newMode
+}
+
+// Modes returns the registered game modes, sorted
+func Modes() []string {
+	modesMu.RLock()
+	defer modesMu.RUnlock()
+	names := make([]string, 0, len(modes))
+	for name := range modes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newMode creates a room's mode; "" is the classic race with no mode
+func newMode(name string) (GameMode, error) {
+	if name == "" {
+		return nil, nil
+	}
+	modesMu.RLock()
+	defer modesMu.RUnlock()
+	create, ok := modes[name]
+	if !ok {
+		return nil, ErrUnknownMode
+	}
+	return create(), nil
+}
+
+// SplitModeType splits a namespaced message type into mode and action; ok
+// is false for plain message types
+func SplitModeType(msgType string) (mode, action string, ok bool) {
+	mode, action, ok = strings.Cut(msgType, ".")
+	return mode, action, ok && mode != "" && action != ""
+}
+
+// HandleModeMessage routes a namespaced message to the room's mode
+func (r *Room) HandleModeMessage(playerID string, msg messages.ClientMessage) error {
+	mode, action, ok := SplitModeType(msg.Type)
+	if !ok {
+		return ErrUnknownAction
+	}
+	modesMu.RLock()
+	_, known := modes[mode]
+	modesMu.RUnlock()
+	if !known {
+		return ErrUnknownMode
+	}
+	if r.Mode == nil || r.Mode.Name() != mode {
+		return ErrModeInactive
+	}
+
+	return r.Transact(func(tx *Tx) error {
+		if _, err := tx.Player(playerID); err != nil {
+			return err
+		}
+		return r.Mode.HandleMessage(tx, playerID, action, msg)
+	})
+}
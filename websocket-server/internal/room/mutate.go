@@ -0,0 +1,184 @@
+package room
+
+import (
+	"errors"
+
+	"labyrinth-duel/websocket/internal/game"
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+// Errors returned by the maze mutation API
+var (
+	ErrBadCell         = errors.New("cell is outside the maze")
+	ErrBadDirection    = errors.New("unknown direction")
+	ErrNoWall          = errors.New("there is no wall to remove")
+	ErrWallExists      = errors.New("there is already a wall")
+	ErrWouldDisconnect = errors.New("change would cut off part of the maze")
+	ErrBadTerrain      = errors.New("unknown terrain type")
+	ErrPortalTerrain   = errors.New("portals come in linked pairs made with the maze")
+)
+
+// The methods below are the supported way for game modes to edit the maze.
+// They validate the change, keep the maze fully connected, reveal changed
+// cells to players who can see them, and queue a mazeUpdated delta. The
+// exported versions lock the room and send the delta immediately; the
+// *Locked versions are for code already holding the lock (e.g. on the room
+// tick) and leave the delta queued until the next flush.
+
+// RemoveWall knocks down the wall on the given side of a cell
+func (r *Room) RemoveWall(at game.Point, direction string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.removeWallLocked(at, direction); err != nil {
+		return err
+	}
+	r.flushMazeUpdatesLocked()
+	return nil
+}
+
+// AddWall builds a wall on the given side of a cell. Fails with
+// ErrWouldDisconnect if the wall would seal off any part of the maze.
+func (r *Room) AddWall(at game.Point, direction string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.addWallLocked(at, direction); err != nil {
+		return err
+	}
+	r.flushMazeUpdatesLocked()
+	return nil
+}
+
+// SetTerrain changes the ground type of a cell
+func (r *Room) SetTerrain(at game.Point, terrain game.Terrain) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.setTerrainLocked(at, terrain); err != nil {
+		return err
+	}
+	r.flushMazeUpdatesLocked()
+	return nil
+}
+
+// neighborOf resolves the cell on the given side of at, on the same floor
+func (r *Room) neighborOf(at game.Point, direction string) (game.Point, error) {
+	if !r.Maze.Contains(at) {
+		return game.Point{}, ErrBadCell
+	}
+	dx, dy, ok := game.Offset(direction)
+	if !ok {
+		return game.Point{}, ErrBadDirection
+	}
+	n := game.Point{X: at.X + dx, Y: at.Y + dy, Z: at.Z}
+	if !r.Maze.Contains(n) {
+		return game.Point{}, ErrBadCell // Outer walls stay up
+	}
+	return n, nil
+}
+
+func (r *Room) removeWallLocked(at game.Point, direction string) error {
+	n, err := r.neighborOf(at, direction)
+	if err != nil {
+		return err
+	}
+	if !r.Maze.RemoveWallBetween(at, n) {
+		return ErrNoWall
+	}
+	r.markChangedLocked(at, n)
+	return nil
+}
+
+func (r *Room) addWallLocked(at game.Point, direction string) error {
+	n, err := r.neighborOf(at, direction)
+	if err != nil {
+		return err
+	}
+	if !r.Maze.AddWallBetween(at, n) {
+		return ErrWallExists
+	}
+	if !r.Maze.IsConnected() {
+		r.Maze.RemoveWallBetween(at, n)
+		return ErrWouldDisconnect
+	}
+	r.markChangedLocked(at, n)
+	return nil
+}
+
+func (r *Room) setTerrainLocked(at game.Point, terrain game.Terrain) error {
+	if !r.Maze.Contains(at) {
+		return ErrBadCell
+	}
+	if !game.IsTerrain(terrain) {
+		return ErrBadTerrain
+	}
+	if terrain == game.TerrainPortal || r.Maze.CellAt(at).Terrain == game.TerrainPortal {
+		return ErrPortalTerrain
+	}
+	r.Maze.CellAt(at).Terrain = terrain
+	r.markChangedLocked(at)
+	return nil
+}
+
+// markChangedLocked queues cells for the next mazeUpdated delta. Under fog,
+// players currently in sight of a changed cell on their floor get it
+// revealed.
+func (r *Room) markChangedLocked(cells ...game.Point) {
+	r.pendingCells = append(r.pendingCells, cells...)
+
+	if !r.Rules.Fog {
+		return
+	}
+	radius := r.fogRadius()
+	for _, p := range r.Players {
+		for _, c := range cells {
+			if c.Z == p.Z && abs(c.X-p.X)+abs(c.Y-p.Y) <= radius {
+				if p.revealed == nil {
+					p.revealed = make(map[game.Point]bool)
+				}
+				p.revealed[c] = true
+			}
+		}
+	}
+}
+
+// flushMazeUpdatesLocked sends queued cell changes. Under fog each player
+// only hears about cells they have already seen.
+func (r *Room) flushMazeUpdatesLocked() {
+	if len(r.pendingCells) == 0 {
+		return
+	}
+
+	seen := make(map[game.Point]bool)
+	var changed []game.Point
+	for _, c := range r.pendingCells {
+		if !seen[c] {
+			seen[c] = true
+			changed = append(changed, c)
+		}
+	}
+	r.pendingCells = nil
+
+	for id := range r.Clients {
+		player := r.Players[id]
+
+		var cells []messages.Cell
+		for _, c := range changed {
+			if r.Rules.Fog && (player == nil || !player.revealed[c]) {
+				continue
+			}
+			cells = append(cells, cellToMessage(*r.Maze.CellAt(c)))
+		}
+		if len(cells) == 0 {
+			continue
+		}
+
+		msg := messages.ServerMessage{Type: "mazeUpdated", Cells: cells}
+		if !supports(r.Clients[id], messages.CapDeltaUpdates) {
+			// The whole maze as they see it, in place of the changes
+			msg.Maze, msg.Cells = r.mazeDataForLocked(id)
+		}
+		r.sendLocked(id, msg)
+	}
+}
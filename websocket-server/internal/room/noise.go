@@ -0,0 +1,43 @@
+package room
+
+import "labyrinth-duel/websocket/internal/messages"
+
+// NoiseRadius is how many corridor steps away footsteps can be heard
+const NoiseRadius = 4
+
+// EmitFootsteps sends a "noise" hint to every other player within
+// NoiseRadius corridor steps of the moving player. The hint only carries the
+// direction the sound comes from, never the mover's position. Footsteps are
+// only heard under fog, where players can't see each other.
+func (r *Room) EmitFootsteps(playerID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.emitFootstepsLocked(playerID)
+}
+
+// emitFootstepsLocked is EmitFootsteps for callers already holding the
+// room lock
+func (r *Room) emitFootstepsLocked(playerID string) {
+	mover, exists := r.Players[playerID]
+	if !exists || !r.Rules.Fog {
+		return
+	}
+
+	dist := r.Maze.DistanceMap(mover.at())
+
+	for id, p := range r.Players {
+		if id == playerID {
+			continue
+		}
+		d := dist.At(p.at())
+		if d <= 0 || d > NoiseRadius {
+			continue
+		}
+
+		r.sendLocked(id, messages.ServerMessage{
+			Type:      "noise",
+			Message:   "You hear footsteps",
+			Direction: r.Maze.DirectionToward(dist, p.at()),
+		})
+	}
+}
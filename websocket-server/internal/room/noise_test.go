@@ -0,0 +1,65 @@
+package room
+
+import (
+	"sync"
+	"testing"
+
+	"labyrinth-duel/websocket/internal/game"
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+// recorder is a connection that keeps the types of the messages sent to it
+type recorder struct {
+	types []string
+	mu    sync.Mutex
+}
+
+func (c *recorder) SendJSON(msg messages.ServerMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.types = append(c.types, msg.Type)
+}
+
+func (c *recorder) heard(msgType string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, t := range c.types {
+		if t == msgType {
+			return true
+		}
+	}
+	return false
+}
+
+// TestFootsteps has a player move next to another, with and without fog:
+// only under fog are their footsteps heard
+func TestFootsteps(t *testing.T) {
+	for _, fog := range []bool{false, true} {
+		m := NewManager()
+		r, _ := m.GetOrCreateRoom("steps", Options{Maze: game.Options{Seed: 3}, Rules: RuleSet{Fog: fog}})
+		listener := &recorder{}
+		if err := r.AddPlayer("mover", discard{}); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.AddPlayer("listener", listener); err != nil {
+			t.Fatal(err)
+		}
+
+		r.mu.Lock()
+		mover, near := r.Players["mover"], r.Players["listener"]
+		next := game.Moves(mover.at())
+		for _, to := range next {
+			if _, ok := r.Maze.Step(mover.at(), 0, to); ok && r.Maze.Contains(to) {
+				near.X, near.Y, near.Z = to.X, to.Y, to.Z
+				break
+			}
+		}
+		r.mu.Unlock()
+
+		r.EmitFootsteps("mover")
+		if heard := listener.heard("noise"); heard != fog {
+			t.Errorf("fog %v: footsteps heard %v", fog, heard)
+		}
+		m.RemoveRoom("steps")
+	}
+}
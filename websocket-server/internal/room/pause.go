@@ -0,0 +1,199 @@
+package room
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+const (
+	// PauseLimit is how long a pause may last before the match resumes by
+	// itself, so nobody can hold it hostage
+	PauseLimit = 2 * time.Minute
+	// PausesPerMatch is how many times a match may be paused
+	PausesPerMatch = 3
+)
+
+// Reasons a paused match resumes
+const (
+	ResumedByHost = "host"    // The host resumed it
+	ResumedByVote = "vote"    // The room voted to resume it
+	ResumedByTime = "timeout" // PauseLimit ran out
+)
+
+// Errors returned by Pause and Resume
+var (
+	ErrNotPausable   = errors.New("only a match under way can be paused")
+	ErrNoPausesLeft  = errors.New("this match has been paused too often already")
+	ErrNotPaused     = errors.New("the match isn't paused")
+	ErrPauseHostOnly = errors.New("only the host can pause or resume the match; start a vote instead")
+)
+
+// Pause freezes the match for the host: the clock stops and moves are
+// rejected until they resume it, the room votes to, or PauseLimit runs out
+func (r *Room) Pause(playerID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.Host == "" || playerID != r.Host {
+		return ErrPauseHostOnly
+	}
+	if err := r.checkPausableLocked(); err != nil {
+		return err
+	}
+	r.pauseLocked(playerID, r.clock.Now())
+	return nil
+}
+
+// Resume unfreezes a paused match for the host, after the same countdown
+// as a round start
+func (r *Room) Resume(playerID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.Host == "" || playerID != r.Host {
+		return ErrPauseHostOnly
+	}
+	if r.State != StatePaused {
+		return ErrNotPaused
+	}
+	r.startResumeLocked(ResumedByHost, r.clock.Now())
+	return nil
+}
+
+// checkPausableLocked reports why the match can't be paused now, if it
+// can't. Demo matches are the bots' to finish.
+func (r *Room) checkPausableLocked() error {
+	if r.State != StatePlaying || r.demo {
+		return ErrNotPausable
+	}
+	if r.pauses >= PausesPerMatch {
+		return ErrNoPausesLeft
+	}
+	return nil
+}
+
+func (r *Room) pauseLocked(by string, now time.Time) {
+	r.State = StatePaused
+	r.pauses++
+	r.pausedAt = now
+	r.pauseEnds = now.Add(PauseLimit)
+	r.broadcastLocked(messages.ServerMessage{
+		Type:    "matchPaused",
+		State:   string(r.State),
+		Message: by,
+		Seconds: int(PauseLimit.Seconds()),
+	}, "")
+}
+
+// updatePauseLocked counts down to the end of a pause, starting the count
+// itself once the pause has run out
+func (r *Room) updatePauseLocked(now time.Time) {
+	if r.resumeAt.IsZero() {
+		if !now.Before(r.pauseEnds) {
+			r.startResumeLocked(ResumedByTime, now)
+		}
+		return
+	}
+
+	remaining := r.resumeAt.Sub(now)
+	if remaining <= 0 {
+		r.resumeLocked(r.resumeReason, now)
+		return
+	}
+	seconds := int(math.Ceil(remaining.Seconds()))
+	if seconds != r.lastCountdown {
+		r.lastCountdown = seconds
+		r.broadcastLocked(messages.ServerMessage{
+			Type:    "countdown",
+			State:   string(r.State),
+			Seconds: seconds,
+		}, "")
+	}
+}
+
+// startResumeLocked counts down CountdownDuration before the paused match
+// picks up again, moves still being rejected, so nobody gets a head start
+// from hearing of it first
+func (r *Room) startResumeLocked(reason string, now time.Time) {
+	if !r.resumeAt.IsZero() {
+		return // Already counting down
+	}
+	r.resumeAt = now.Add(CountdownDuration)
+	r.resumeReason = reason
+	r.lastCountdown = int(CountdownDuration.Seconds())
+	r.broadcastLocked(messages.ServerMessage{
+		Type:     "matchResuming",
+		State:    string(r.State),
+		Reason:   reason,
+		Seconds:  r.lastCountdown,
+		StartsAt: r.resumeAt.UnixMilli(),
+	}, "")
+}
+
+// resumeLocked picks the match up where it was paused, with every clock it
+// runs on moved on by the length of the pause
+func (r *Room) resumeLocked(reason string, now time.Time) {
+	if !r.pausedAt.IsZero() {
+		r.shiftClocksLocked(now.Sub(r.pausedAt))
+	}
+	r.pausedAt, r.pauseEnds, r.resumeAt = time.Time{}, time.Time{}, time.Time{}
+	r.State = StatePlaying
+
+	remaining := r.roundStartedAt.Add(r.MatchDuration + r.roundExtension).Sub(now)
+	r.lastTimerSecond = int(math.Ceil(remaining.Seconds()))
+	r.broadcastLocked(messages.ServerMessage{
+		Type:    "matchResumed",
+		State:   string(r.State),
+		Reason:  reason,
+		Seconds: r.lastTimerSecond,
+	}, "")
+}
+
+// shiftClocksLocked moves every deadline and timestamp of the match under
+// way d later, as if the time it was paused for never passed
+func (r *Room) shiftClocksLocked(d time.Duration) {
+	later := func(t *time.Time) {
+		if !t.IsZero() {
+			*t = t.Add(d)
+		}
+	}
+
+	later(&r.matchStartedAt)
+	later(&r.roundStartedAt)
+	later(&r.nextItemSpawn)
+	later(&r.nextGoalMove)
+	later(&r.nextShift)
+	for i := range r.events {
+		later(&r.events[i].At)
+	}
+	for _, p := range r.Players {
+		later(&p.frozenUntil)
+		later(&p.nextMoveAt)
+		later(&p.lastScoreAt)
+		later(&p.lastActionAt)
+		later(&p.camp.since)
+		later(&p.camp.nextPenalty)
+		for i := range p.speedMods {
+			later(&p.speedMods[i].until)
+		}
+	}
+	for _, b := range r.bots {
+		later(&b.nextMoveAt)
+	}
+	for _, m := range r.minotaurs {
+		later(&m.nextMoveAt)
+	}
+	for _, trail := range r.trails {
+		for i := range trail {
+			later(&trail[i].at)
+		}
+	}
+	if ctf := r.ctfLocked(); ctf != nil {
+		for _, f := range ctf.flags {
+			later(&f.returnAt)
+		}
+	}
+}
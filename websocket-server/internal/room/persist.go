@@ -0,0 +1,271 @@
+package room
+
+import (
+	"sort"
+	"time"
+
+	"labyrinth-duel/websocket/internal/game"
+	"labyrinth-duel/websocket/internal/level"
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+// SavedRoom is everything needed to bring a room back after a restart:
+// its maze, rules, players and where the match stands. Connections, votes,
+// a running map veto and game mode state are not kept.
+type SavedRoom struct {
+	ID          string       `json:"id"`
+	SavedAt     time.Time    `json:"savedAt"`
+	Maze        *game.Maze   `json:"maze"`
+	MazeOptions game.Options `json:"mazeOptions"`
+	Level       *level.Level `json:"level,omitempty"`
+	Rules       RuleSet      `json:"rules"`
+	Access      Access       `json:"access"`
+	JoinCode    string       `json:"joinCode,omitempty"`
+	Host        string       `json:"host,omitempty"`
+	State       State        `json:"state"`
+
+	Players []SavedPlayer     `json:"players"`
+	Bots    map[string]string `json:"bots,omitempty"` // Difficulty of each bot, by player ID
+	Items   []Item            `json:"items,omitempty"`
+	Kicked  []string          `json:"kicked,omitempty"`
+
+	Round          int                  `json:"round"`
+	MatchStartedAt time.Time            `json:"matchStartedAt"`
+	RoundStartedAt time.Time            `json:"roundStartedAt"`
+	RoundExtension time.Duration        `json:"roundExtension"`
+	MatchMazes     []*messages.MazeData `json:"matchMazes,omitempty"`
+	MatchEvents    []Event              `json:"matchEvents,omitempty"`
+	ItemSeq        int                  `json:"itemSeq"`
+	BotSeq         int                  `json:"botSeq"`
+}
+
+// SavedPlayer is a player's place in a saved room
+type SavedPlayer struct {
+	ID          string        `json:"id"`
+	X           int           `json:"x"`
+	Y           int           `json:"y"`
+	Z           int           `json:"z,omitempty"`
+	Level       int           `json:"level"`
+	Spawn       int           `json:"spawn"`
+	Team        int           `json:"team"`
+	TeamPinned  bool          `json:"teamPinned,omitempty"`
+	Bot         bool          `json:"bot,omitempty"`
+	Ready       bool          `json:"ready"`
+	Score       int           `json:"score"`
+	Streak      int           `json:"streak"`
+	RoundWins   int           `json:"roundWins"`
+	Profile     PlayerProfile `json:"profile"`
+	Inventory   []string      `json:"inventory,omitempty"`
+	WallCharges int           `json:"wallCharges"`
+	Speed       float64       `json:"speed,omitempty"`
+	Revealed    []game.Point  `json:"revealed,omitempty"`
+	Claimed     []game.Point  `json:"claimed,omitempty"`
+	// ResumeHash is filled in by the server so the player can still prove
+	// who they are after a restart; the room doesn't use it
+	ResumeHash string `json:"resumeHash,omitempty"`
+}
+
+// Save captures the room for a later Restore
+func (r *Room) Save() *SavedRoom {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.saveLocked()
+}
+
+// saveLocked is Save for callers already holding the room lock
+func (r *Room) saveLocked() *SavedRoom {
+	s := &SavedRoom{
+		ID:             r.ID,
+		SavedAt:        r.clock.Now(),
+		Maze:           r.Maze.Clone(),
+		MazeOptions:    r.mazeOpts,
+		Level:          r.level,
+		Rules:          r.Rules,
+		Access:         r.Access,
+		JoinCode:       r.JoinCode,
+		Host:           r.Host,
+		State:          r.State,
+		Round:          r.round,
+		MatchStartedAt: r.matchStartedAt,
+		RoundStartedAt: r.roundStartedAt,
+		RoundExtension: r.roundExtension,
+		MatchMazes:     r.matchMazes,
+		MatchEvents:    append([]Event(nil), r.matchEventsLocked()...),
+		ItemSeq:        r.itemSeq,
+		BotSeq:         r.botSeq,
+	}
+	for _, p := range r.Players {
+		s.Players = append(s.Players, SavedPlayer{
+			ID:          p.ID,
+			X:           p.X,
+			Y:           p.Y,
+			Z:           p.Z,
+			Level:       p.Level,
+			Spawn:       p.Spawn,
+			Team:        p.Team,
+			TeamPinned:  p.teamPinned,
+			Bot:         p.Bot,
+			Ready:       p.Ready,
+			Score:       p.Score,
+			Streak:      p.Streak,
+			RoundWins:   p.RoundWins,
+			Profile:     p.Profile,
+			Inventory:   append([]string(nil), p.Inventory...),
+			WallCharges: p.WallCharges,
+			Speed:       p.Speed,
+			Revealed:    pointList(p.revealed),
+			Claimed:     pointList(p.claimed),
+		})
+	}
+	sort.Slice(s.Players, func(i, j int) bool { return s.Players[i].ID < s.Players[j].ID })
+	for id, b := range r.bots {
+		if s.Bots == nil {
+			s.Bots = make(map[string]string)
+		}
+		s.Bots[id] = b.difficulty.Name
+	}
+	for _, item := range r.Items {
+		s.Items = append(s.Items, *item)
+	}
+	for id := range r.kicked {
+		s.Kicked = append(s.Kicked, id)
+	}
+	return s
+}
+
+// SaveAll captures every room
+func (m *Manager) SaveAll() []*SavedRoom {
+	rooms := m.All()
+	saved := make([]*SavedRoom, len(rooms))
+	for i, r := range rooms {
+		saved[i] = r.Save()
+	}
+	return saved
+}
+
+// Restore brings a saved room back. Its players have no connection until
+// they join it again, which puts them back where they were. Match clocks
+// are moved on by the time the room spent saved, so a round's time left
+// is what it was; a countdown starts over. Returns false if a room with
+// that ID already exists.
+func (m *Manager) Restore(s *SavedRoom) (*Room, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.rooms[s.ID]; exists {
+		return nil, false
+	}
+
+	now := m.clock().Now()
+	downtime := now.Sub(s.SavedAt)
+	r := m.newRoom(s.ID, Options{Maze: s.MazeOptions, Rules: s.Rules, Access: s.Access, Level: s.Level}, s.Maze)
+	r.JoinCode = s.JoinCode
+	r.Host = s.Host
+	r.State = s.State
+	r.round = s.Round
+	r.matchStartedAt = s.MatchStartedAt.Add(downtime)
+	r.roundStartedAt = s.RoundStartedAt.Add(downtime)
+	r.roundExtension = s.RoundExtension
+	r.matchMazes = s.MatchMazes
+	r.itemSeq = s.ItemSeq
+	r.botSeq = s.BotSeq
+	r.nextItemSpawn = now.Add(ItemSpawnInterval)
+	if r.State == StatePlaying || r.State == StatePaused {
+		r.scheduleGoalMoveLocked(now)
+	}
+	if r.State == StatePaused {
+		// The pause starts over, deadline and all
+		r.pausedAt, r.pauseEnds = now, now.Add(PauseLimit)
+	}
+	for _, ev := range s.MatchEvents {
+		ev.At = ev.At.Add(downtime)
+		r.events = append(r.events, ev)
+	}
+
+	for _, sp := range s.Players {
+		r.Players[sp.ID] = &PlayerState{
+			ID:          sp.ID,
+			X:           sp.X,
+			Y:           sp.Y,
+			Z:           sp.Z,
+			Level:       sp.Level,
+			Spawn:       sp.Spawn,
+			Team:        sp.Team,
+			teamPinned:  sp.TeamPinned,
+			Bot:         sp.Bot,
+			Ready:       sp.Ready,
+			Score:       sp.Score,
+			Streak:      sp.Streak,
+			RoundWins:   sp.RoundWins,
+			Profile:     sp.Profile,
+			Inventory:   sp.Inventory,
+			WallCharges: sp.WallCharges,
+			Speed:       sp.Speed,
+			revealed:    pointSet(sp.Revealed),
+			claimed:     pointSet(sp.Claimed),
+		}
+	}
+	for id, name := range s.Bots {
+		d, ok := BotDifficultyByName(name)
+		if !ok {
+			d = DefaultBotDifficulty
+		}
+		if r.bots == nil {
+			r.bots = make(map[string]*bot)
+		}
+		r.bots[id] = &bot{difficulty: d}
+	}
+	for i := range s.Items {
+		item := s.Items[i]
+		r.placeItemLocked(&item)
+	}
+	for _, id := range s.Kicked {
+		if r.kicked == nil {
+			r.kicked = make(map[string]bool)
+		}
+		r.kicked[id] = true
+	}
+	if r.State == StateCountdown {
+		r.startCountdownLocked()
+	}
+
+	m.addRoomLocked(r)
+	return r, true
+}
+
+// DropAbsent removes every player who hasn't reconnected since the room was
+// restored, and returns their IDs
+func (r *Room) DropAbsent() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var dropped []string
+	for id, p := range r.Players {
+		if p.Bot || r.Clients[id] != nil {
+			continue
+		}
+		r.removePlayerLocked(id)
+		dropped = append(dropped, id)
+	}
+	sort.Strings(dropped)
+	return dropped
+}
+
+func pointList(set map[game.Point]bool) []game.Point {
+	points := make([]game.Point, 0, len(set))
+	for p := range set {
+		points = append(points, p)
+	}
+	return points
+}
+
+func pointSet(points []game.Point) map[game.Point]bool {
+	if len(points) == 0 {
+		return nil
+	}
+	set := make(map[game.Point]bool, len(points))
+	for _, p := range points {
+		set[p] = true
+	}
+	return set
+}
@@ -0,0 +1,40 @@
+package room
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"labyrinth-duel/websocket/internal/messages"
+	"labyrinth-duel/websocket/internal/replay"
+)
+
+// replayLocked packages the match just finished for playback: the maze of
+// every round and the match's events, timed from the start of play
+func (r *Room) replayLocked(winnerID string, players []messages.Player, now time.Time) *replay.Replay {
+	events := r.matchEventsLocked()
+	rec := &replay.Replay{
+		ReplayInfo: messages.ReplayInfo{
+			ID:        uuid.New().String(),
+			RoomID:    r.ID,
+			StartedAt: r.matchStartedAt.UnixMilli(),
+			Duration:  now.Sub(r.matchStartedAt).Seconds(),
+			Winner:    winnerID,
+			Players:   players,
+		},
+		Mazes:  r.matchMazes,
+		Events: make([]messages.ReplayEvent, len(events)),
+	}
+	for i, ev := range events {
+		rec.Events[i] = messages.ReplayEvent{
+			T:        ev.At.Sub(r.matchStartedAt).Milliseconds(),
+			Type:     ev.Type,
+			PlayerID: ev.PlayerID,
+			X:        ev.X,
+			Y:        ev.Y,
+			Z:        ev.Z,
+			Value:    ev.Value,
+			Detail:   ev.Detail,
+		}
+	}
+	return rec
+}
@@ -0,0 +1,171 @@
+package room
+
+import (
+	"errors"
+	"sort"
+
+	"labyrinth-duel/websocket/internal/game"
+	"labyrinth-duel/websocket/internal/messages"
+	"labyrinth-duel/websocket/internal/replay"
+)
+
+// Review commands, for going over a finished ranked match together
+const (
+	ReviewStart = "start" // Open the review at the first event, or go back to it
+	ReviewNext  = "next"  // Show the next event
+	ReviewPrev  = "prev"  // Take the last event back
+	ReviewStop  = "stop"  // Close the review
+)
+
+// Errors returned by Review
+var (
+	ErrNoReview            = errors.New("only a finished ranked match can be reviewed")
+	ErrNotReviewing        = errors.New("no review is open")
+	ErrUnknownReviewAction = errors.New("unknown review command")
+)
+
+// review is a post-match review: the room's players step through the
+// match's replay together, with the server drawing the paths
+type review struct {
+	replay *replay.Replay
+	step   int // Events shown
+	round  int // Round of the last broadcast, to know when to send its maze
+}
+
+// Review runs a review command for a player. Any player in the room may
+// step, for everyone; the room only watches, so nothing changes in it.
+func (r *Room) Review(playerID, command string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.Players[playerID]; !exists {
+		return ErrNoPlayer
+	}
+	if !r.Rules.Ranked || r.State != StateFinished || r.LastMatch == nil || r.LastMatch.Replay == nil {
+		return ErrNoReview
+	}
+
+	switch command {
+	case ReviewStart:
+		r.review = &review{replay: r.LastMatch.Replay}
+		r.broadcastLocked(r.reviewMessageLocked("reviewStarted", playerID, true), "")
+		return nil
+	case ReviewNext, ReviewPrev, ReviewStop:
+		if r.review == nil {
+			return ErrNotReviewing
+		}
+	default:
+		return ErrUnknownReviewAction
+	}
+
+	switch command {
+	case ReviewNext:
+		if r.review.step == len(r.review.replay.Events) {
+			return ErrNoEffect
+		}
+		r.review.step++
+	case ReviewPrev:
+		if r.review.step == 0 {
+			return ErrNoEffect
+		}
+		r.review.step--
+	case ReviewStop:
+		r.review = nil
+		r.broadcastLocked(messages.ServerMessage{Type: "reviewEnded", Message: playerID}, "")
+		return nil
+	}
+
+	round := r.review.round
+	msg := r.reviewMessageLocked("reviewStep", playerID, false)
+	if r.review.round != round {
+		msg.Maze = r.review.replay.Mazes[r.review.round]
+	}
+	r.broadcastLocked(msg, "")
+	return nil
+}
+
+// CatchUpReview sends a player who just came in the open review, if any
+func (r *Room) CatchUpReview(playerID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.review != nil {
+		r.sendLocked(playerID, r.reviewMessageLocked("reviewStarted", "", true))
+	}
+}
+
+// reviewMessageLocked describes the review at its current step: the last
+// event shown, and each player's path and best path in its round. The
+// round's maze is included, and its round remembered as sent.
+func (r *Room) reviewMessageLocked(msgType, by string, withMaze bool) messages.ServerMessage {
+	rv := r.review
+	events := rv.replay.Events[:rv.step]
+
+	// Replay the events up to the step: paths and moved exits start over
+	// with each round
+	round := 0
+	paths := make(map[string][]messages.Position)
+	var goals []game.Goal
+	for _, ev := range events {
+		switch ev.Type {
+		case EventRound:
+			if ev.Value < len(rv.replay.Mazes) {
+				round = ev.Value
+			}
+			paths = make(map[string][]messages.Position)
+			goals = nil
+		case EventMatchStart, EventMove:
+			paths[ev.PlayerID] = append(paths[ev.PlayerID], messages.Position{X: ev.X, Y: ev.Y, Z: ev.Z})
+		case EventGoalMoved:
+			if ev.Value == 0 {
+				goals = nil // The exits moved again
+			}
+			goals = append(goals, game.Goal{Point: game.Point{X: ev.X, Y: ev.Y, Z: ev.Z}})
+		}
+	}
+	rv.round = round
+
+	status := &messages.ReviewStatus{
+		Step:  rv.step,
+		Steps: len(rv.replay.Events),
+		Round: round,
+	}
+	var maze *game.Maze
+	if round < len(rv.replay.Mazes) {
+		maze = mazeFromMessage(rv.replay.Mazes[round])
+		if len(goals) > 0 {
+			maze.Goal, maze.Goals = goals[0].Point, goals
+		}
+	}
+	for _, id := range sortedKeys(paths) {
+		path := paths[id]
+		status.Paths = append(status.Paths, messages.ReviewPath{PlayerID: id, Path: path})
+		if maze == nil {
+			continue
+		}
+		best := maze.PathToGoal(game.Point{X: path[0].X, Y: path[0].Y}, game.LevelSurface)
+		optimal := messages.ReviewPath{PlayerID: id, Path: make([]messages.Position, len(best))}
+		for i, p := range best {
+			optimal.Path[i] = messages.Position{X: p.X, Y: p.Y}
+		}
+		status.Optimal = append(status.Optimal, optimal)
+	}
+
+	msg := messages.ServerMessage{Type: msgType, Message: by, Review: status}
+	if rv.step > 0 {
+		ev := events[rv.step-1]
+		msg.Event = &ev
+	}
+	if withMaze && round < len(rv.replay.Mazes) {
+		msg.Maze = rv.replay.Mazes[round]
+	}
+	return msg
+}
+
+func sortedKeys(m map[string][]messages.Position) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -1,30 +1,205 @@
 package room
 
 import (
+	"sort"
 	"sync"
+	"time"
 
+	"labyrinth-duel/websocket/internal/clock"
 	"labyrinth-duel/websocket/internal/game"
+	"labyrinth-duel/websocket/internal/level"
 	"labyrinth-duel/websocket/internal/messages"
 )
 
+// BaseMoveInterval is the move cooldown on normal terrain
+const BaseMoveInterval = 100 * time.Millisecond
+
+// Sender is a connection that can receive server messages
+type Sender interface {
+	SendJSON(msg messages.ServerMessage)
+}
+
+// Leaver is implemented by Senders that track which room they are in. The
+// room calls LeftRoom when it removes a player itself, as after a kick
+// vote, so the connection stops pointing at it.
+type Leaver interface {
+	LeftRoom(roomID string)
+}
+
 // Room represents a game room with its maze and players
 type Room struct {
-	ID      string
-	Maze    *game.Maze
-	Players map[string]*PlayerState
-	mu      sync.RWMutex
+	ID            string
+	Maze          *game.Maze
+	Players       map[string]*PlayerState
+	Clients       map[string]Sender // Connections subscribed to this room's broadcasts
+	Rules         RuleSet
+	Mode          GameMode // Mode-specific rules, nil for the classic race
+	Access        Access
+	JoinCode      string               // Code to join a private room by
+	Host          string               // Player with the host's privileges, see host.go: the creator, then whoever they hand over to or has been in longest
+	Items         map[game.Point]*Item // Items lying in the maze, by cell
+	State         State
+	MinPlayers    int
+	MatchDuration time.Duration
+	Debug         bool // Check engine invariants after every change and log violations
+	mu            sync.RWMutex
+
+	LastMatch *MatchRecord // Summary of the most recently finished match
+
+	countdownEnds   time.Time
+	lastCountdown   int
+	matchStartedAt  time.Time
+	roundStartedAt  time.Time
+	lastTimerSecond int
+	round           int          // Rounds completed in the current match
+	mazeOpts        game.Options // Options new round mazes are generated with
+	tickInterval    time.Duration
+	onFinish        func(*MatchRecord)
+	onSuspend       func(*SavedRoom) (string, error)
+	onIdleRemoved   func(*Room, string)
+	idleTimeout     time.Duration // Inactivity that flags a player idle (Settings.IdleTimeout, 0 = never)
+	idleGrace       time.Duration // Further inactivity that removes them
+
+	events     []Event              // Log of the current (or last) match and the lobby since
+	matchMazes []*messages.MazeData // Maze of each round of the current match, for its replay
+
+	pendingCells []game.Point                        // Changed cells awaiting a mazeUpdated flush
+	outbox       map[string][]messages.ServerMessage // Messages held back by an open transaction
+	tickOutbox   map[string][]messages.ServerMessage // Messages held back for the next tick, see ticks.go
+	watchers     map[string]Sender                   // Spectators, by client ID
+	watchOutbox  []messages.ServerMessage            // Watchers' messages held back by an open transaction
+
+	attract    bool      // Run demo matches while empty (Settings.AttractMode)
+	demo       bool      // A demo match is on: the bots in the room are playing it
+	emptySince time.Time // When the last player left
+	removed    bool      // Taken out of the manager: nobody may join any more
+
+	nextItemSpawn time.Time
+	itemSeq       int
+
+	nextGoalMove     time.Time   // When the exits move next (RuleSet.GoalMoveInterval)
+	lastGoalWarning  int         // Seconds of the last goalMoving warning
+	minotaurs        []*minotaur // Hazards prowling the maze (RuleSet.Minotaur)
+	nextShift        time.Time   // When the maze shifts next (RuleSet.MazeShiftInterval)
+	plannedShift     *mazeShift  // What it will change, once players are being warned
+	lastShiftWarning int         // Seconds of the last mazeShifting warning
+	nextSnapshot     time.Time
+	tickCount        uint64 // Ticks since the room was created
+
+	// Outbound bandwidth, measured in one-second windows
+	BandwidthBudget int // Bytes per second before degrading (default DefaultBandwidthBudget)
+	LastBandwidth   int // Bytes sent in the last full window
+	LastMessages    int // Messages sent in the last full window
+	bytesSent       int
+	messagesSent    int
+	bandwidthWindow time.Time
+	degraded        bool // Over budget: coarser ticks, sparser snapshots and timers
+
+	chat []messages.ChatMessage // Last ChatHistorySize chat messages
+
+	history []sentMessage     // Recently sent messages, oldest first
+	version uint64            // Bumped by every message sent; snapshots carry it
+	lastSeq map[string]uint64 // Seq of the last message sent to each client
+
+	bots   map[string]*bot // Controllers of the server-controlled players, by player ID
+	botSeq int
+
+	vote           *Vote // Running vote, if any
+	voteSeq        int
+	kicked         map[string]bool // Players voted out, who may not rejoin
+	roundExtension time.Duration   // Time added to the current round by votes
+	pauses         int             // Times the current match has been paused
+	pausedAt       time.Time       // When the match was paused, while it is
+	pauseEnds      time.Time       // When it resumes by itself (PauseLimit)
+	resumeAt       time.Time       // End of the countdown out of the pause, once it has begun
+	resumeReason   string          // Why it is resuming
+
+	veto   *mapVeto                // Pre-match map veto (RuleSet.MapVeto)
+	level  *level.Level            // Uploaded level every round is played on, if any
+	trails map[string][]breadcrumb // Runners' breadcrumbs by player ID, when the mode is a Pursuit
+	review *review                 // Post-match review of a ranked match
+
+	tutorial       *tutorial // Scripted objectives (RuleSet.Tutorial)
+	onTutorialDone func(playerID string)
+
+	clock     clock.Clock
+	stopTicks func() // Stops the room loop
+	stopOnce  sync.Once
 }
 
 // PlayerState tracks a player's position in a room
 type PlayerState struct {
-	ID string
-	X  int
-	Y  int
+	ID     string
+	X      int
+	Y      int
+	Z      int  // Floor, from 0 at the bottom
+	Level  int  // game.LevelSurface or game.LevelUnder in crossing cells
+	Spawn  int  // Index into Maze.Spawns of where the player starts
+	Team   int  // Team number from 1 in team rooms, 0 otherwise
+	Bot    bool // Server-controlled, see AddBot
+	Ready  bool
+	Away   bool // Client is backgrounded; their update stream is paused
+	Idle   bool // Has done nothing for the room's idle timeout, and is removed if it goes on
+	Score  int
+	Streak int // Consecutive pickups/objectives, drives the score multiplier
+
+	RoundWins int // Rounds won in a best-of-N match
+
+	Profile PlayerProfile
+
+	Inventory   []string // Power-ups held, in pickup order
+	WallCharges int      // Walls this player can still break
+	Speed       float64  // Speed stat dividing the move interval (0 = DefaultSpeed)
+
+	revealed     map[game.Point]bool // Cells seen so far (fog of war)
+	claimed      map[game.Point]bool // Exits already banked (points mode)
+	speedMods    []speedModifier     // Speed changes in effect, such as a speed boost
+	frozenUntil  time.Time           // Frozen by an opponent until
+	lastScoreAt  time.Time
+	chatTimes    []time.Time // Recent chat sends, for rate limiting
+	nextEmoteAt  time.Time
+	nextMoveAt   time.Time     // Earliest time the next move is due, see checkMoveTimingLocked
+	moveCooldown time.Duration // Length of the wait ending at nextMoveAt
+	lastStep     *step         // Last walked move, until the invariant checker sees it
+	teamPinned   bool          // Placed on Team by the host, so balancing leaves them there
+	home         bool          // Reached an exit and is out of the round (TeamGoalAll)
+	camp         campState     // How long they have stayed put (RuleSet.AntiCamp)
+	joinedAt     time.Time     // When they joined, so the longest-present player can take over as host
+	lastActionAt time.Time     // Last message they sent the room, see MarkActive
 }
 
 // Manager manages all active rooms
 type Manager struct {
+	// OnMatchFinished, if set, is called with every finished match. It runs
+	// with the room locked, so it must not call back into the room.
+	OnMatchFinished func(*MatchRecord)
+	// OnSuspend, if set, stores a match suspended by vote and returns the
+	// code that resumes it. Without it matches can't be suspended. It runs
+	// with the room locked, so it must not call back into the room.
+	OnSuspend func(*SavedRoom) (string, error)
+	// OnTutorialComplete, if set, is called with the ID of every player who
+	// finishes the tutorial. It runs with the room locked, so it must not
+	// call back into the room.
+	OnTutorialComplete func(playerID string)
+	// OnIdleRemoved, if set, is called with every player removed from a
+	// room for idling, which may leave it empty. It runs with the room
+	// locked, so it must not call back into the room.
+	OnIdleRemoved func(r *Room, playerID string)
+	// OnRoomAdded and OnRoomRemoved, if set, are called as rooms open and
+	// close. They run with the manager locked, so they must not call back
+	// into it.
+	OnRoomAdded   func(*Room)
+	OnRoomRemoved func(*Room)
+	// Debug turns on invariant checking in rooms created from now on
+	Debug bool
+	// Settings apply to rooms created from now on
+	Settings Settings
+	// Clock tells rooms created from now on the time and paces their loops
+	// (the system clock if nil)
+	Clock clock.Clock
+
 	rooms map[string]*Room
+	codes map[string]string // Private room join code -> room ID
 	mu    sync.RWMutex
 }
 
@@ -32,27 +207,242 @@ type Manager struct {
 func NewManager() *Manager {
 	return &Manager{
 		rooms: make(map[string]*Room),
+		codes: make(map[string]string),
+	}
+}
+
+// Options configure a room when it is first created
+type Options struct {
+	Maze   game.Options
+	Rules  RuleSet
+	Access Access
+	Level  *level.Level // Uploaded level to play every round on instead of generated mazes
+}
+
+// GetOrCreateRoom gets existing room or creates new one with maze. Options
+// only apply when the room is created; created reports whether it was.
+func (m *Manager) GetOrCreateRoom(roomID string, opts Options) (*Room, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if room, exists := m.rooms[roomID]; exists {
+		return room, false
+	}
+
+	var maze *game.Maze
+	if opts.Level != nil {
+		maze = levelMaze(opts.Level, opts.Maze)
+	} else {
+		width, height := m.Settings.mazeSize()
+		maze = game.Generate(width, height, opts.Maze)
+	}
+	room := m.newRoom(roomID, opts, maze)
+	room.applyDeadEndRulesLocked()
+	if opts.Access.Private {
+		room.JoinCode = newJoinCode()
+		for m.codes[room.JoinCode] != "" {
+			room.JoinCode = newJoinCode()
+		}
+	}
+	m.addRoomLocked(room)
+	return room, true
+}
+
+// newRoom builds an empty room in the lobby around a maze
+func (m *Manager) newRoom(roomID string, opts Options, maze *game.Maze) *Room {
+	// Unknown modes fall back to the classic race
+	mode, err := newMode(opts.Rules.Mode)
+	if err != nil {
+		opts.Rules.Mode = ""
+	}
+
+	// Co-op rooms are won by getting everyone out, and capture the flag
+	// needs someone to capture from
+	if opts.Rules.Coop {
+		opts.Rules.TeamGoal = TeamGoalAll
+	}
+	if opts.Rules.Mode == ModeCTF && opts.Rules.Teams < 2 {
+		opts.Rules.Teams = 2
+	}
+	if _, ok := MinotaurDifficultyByName(opts.Rules.Minotaur); !ok {
+		opts.Rules.Minotaur = ""
 	}
+	// There is nothing to veto on a level
+	if opts.Level != nil {
+		opts.Rules.MapVeto = false
+	}
+
+	// Practice rooms and daily challenges are for one player, who starts on
+	// their own; a tutorial is practice with no hurry
+	matchDuration := m.Settings.matchDuration()
+	if opts.Rules.Tutorial {
+		opts.Rules.Practice = true
+		matchDuration = TutorialMatchDuration
+	}
+	minPlayers := DefaultMinPlayers
+	if opts.Rules.Practice || opts.Rules.Daily != "" {
+		minPlayers = 1
+		opts.Access.MaxPlayers = 1
+	}
+	opts.Access.MaxPlayers = m.Settings.maxPlayers(opts.Access.MaxPlayers)
+	if opts.Access.Password != "" {
+		opts.Access.PasswordHash, opts.Access.Password = hashPassword(opts.Access.Password), ""
+	}
+
+	r := &Room{
+		ID:            roomID,
+		Maze:          maze,
+		Players:       make(map[string]*PlayerState),
+		Clients:       make(map[string]Sender),
+		Rules:         opts.Rules,
+		Mode:          mode,
+		Access:        opts.Access,
+		Items:         make(map[game.Point]*Item),
+		State:         StateWaiting,
+		MinPlayers:    minPlayers,
+		MatchDuration: matchDuration,
+		mazeOpts:      opts.Maze,
+		level:         opts.Level,
+		onFinish:      m.OnMatchFinished,
+		onSuspend:     m.OnSuspend,
+		onIdleRemoved: m.OnIdleRemoved,
+		idleTimeout:   m.Settings.IdleTimeout,
+		idleGrace:     m.Settings.idleGrace(),
+		Debug:         m.Debug,
+		tickInterval:  m.Settings.tickInterval(),
+		attract:       m.Settings.AttractMode,
+		clock:         m.clock(),
+	}
+	if opts.Rules.Tutorial {
+		r.tutorial = &tutorial{}
+		r.onTutorialDone = m.OnTutorialComplete
+	}
+	return r
+}
+
+// addRoomLocked registers a room and its join code and starts its loop
+func (m *Manager) addRoomLocked(room *Room) {
+	if room.JoinCode != "" {
+		m.codes[room.JoinCode] = room.ID
+	}
+	m.rooms[room.ID] = room
+	room.run()
+	if m.OnRoomAdded != nil {
+		m.OnRoomAdded(room)
+	}
+}
+
+// clock returns the clock for new rooms
+func (m *Manager) clock() clock.Clock {
+	if m.Clock == nil {
+		return clock.Real{}
+	}
+	return m.Clock
 }
 
-// GetOrCreateRoom gets existing room or creates new one with maze
-func (m *Manager) GetOrCreateRoom(roomID string) *Room {
+// All returns every room
+func (m *Manager) All() []*Room {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	rooms := make([]*Room, 0, len(m.rooms))
+	for _, r := range m.rooms {
+		rooms = append(rooms, r)
+	}
+	return rooms
+}
+
+// RemoveRoom stops a room's loop and forgets it
+func (m *Manager) RemoveRoom(roomID string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	if room, exists := m.rooms[roomID]; exists {
-		return room
+		room.mu.Lock()
+		room.removed = true
+		room.mu.Unlock()
+		m.removeLocked(room)
+	}
+}
+
+// removeLocked takes a room out of the manager, whose lock is held, once
+// it has been marked removed
+func (m *Manager) removeLocked(room *Room) {
+	room.Stop()
+	delete(m.rooms, room.ID)
+	delete(m.codes, room.JoinCode)
+	if m.OnRoomRemoved != nil {
+		m.OnRoomRemoved(room)
+	}
+}
+
+// GetRoomByCode returns the private room with the given join code, if any
+func (m *Manager) GetRoomByCode(code string) *Room {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.rooms[m.codes[code]]
+}
+
+// ListRooms returns a metadata snapshot of every public room, sorted by ID
+func (m *Manager) ListRooms() []messages.RoomInfo {
+	m.mu.RLock()
+	rooms := make([]*Room, 0, len(m.rooms))
+	for _, r := range m.rooms {
+		if !r.Access.Private {
+			rooms = append(rooms, r)
+		}
+	}
+	m.mu.RUnlock()
+
+	infos := make([]messages.RoomInfo, 0, len(rooms))
+	for _, r := range rooms {
+		infos = append(infos, r.Info())
 	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
+
+// Info returns the room's lobby metadata
+func (r *Room) Info() messages.RoomInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 
-	// Create new room with maze
-	room := &Room{
-		ID:      roomID,
-		Maze:    game.NewMaze(10, 10), // 10x10 maze
-		Players: make(map[string]*PlayerState),
+	full := r.fullLocked("")
+	rating := 0
+	players := 0
+	for id, p := range r.Players {
+		if _, isBot := r.bots[id]; isBot && r.demo {
+			continue
+		}
+		rating += p.Profile.Rating
+		players++
+	}
+	if players > 0 {
+		rating /= players
+	}
+	return messages.RoomInfo{
+		ID:         r.ID,
+		Players:    players,
+		MaxPlayers: r.Access.MaxPlayers,
+		Width:      r.Maze.Width,
+		Height:     r.Maze.Height,
+		State:      string(r.State),
+		Joinable:   (r.State == StateWaiting || r.demo) && !full && !r.Access.Locked,
+		Locked:     r.Access.Locked,
+		Demo:       r.demo,
+		Password:   r.Access.PasswordHash != "",
+		Degraded:   r.degraded,
+		Rating:     rating,
+		Mode:       r.Rules.Mode,
+		Level:      r.levelName(),
 	}
-	m.rooms[roomID] = room
+}
 
-	return room
+// levelName is the name of the level the room plays, if any
+func (r *Room) levelName() string {
+	if r.level == nil {
+		return ""
+	}
+	return r.level.Name
 }
 
 // GetRoom returns a room if it exists
@@ -62,64 +452,366 @@ func (m *Manager) GetRoom(roomID string) *Room {
 	return m.rooms[roomID]
 }
 
-// AddPlayer adds a player to a room
-func (r *Room) AddPlayer(playerID string, x, y int) {
+// PlayerProfile is how a player presents themselves to the room
+type PlayerProfile struct {
+	Name    string
+	Color   string
+	Avatar  string
+	Rating  int
+	Matches int // Matches played, to tell how far Rating can be trusted
+}
+
+// SetProfile changes a player's displayed profile and tells the room
+func (r *Room) SetProfile(playerID string, profile PlayerProfile) bool {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	player, exists := r.Players[playerID]
+	if !exists {
+		return false
+	}
+	player.Profile = profile
+
+	r.broadcastLocked(messages.ServerMessage{
+		Type:    "profileUpdated",
+		Message: playerID,
+		Players: r.playersLocked(),
+	}, "")
+	return true
+}
+
+// AddPlayer adds a player to a room on a spawn point of their own, if there
+// are enough, and subscribes their connection. It returns ErrRoomFull if
+// there is no free slot. Unlike Join it skips the
+// code and password checks.
+func (r *Room) AddPlayer(playerID string, client Sender) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fullLocked(playerID) {
+		return ErrRoomFull
+	}
+	r.addPlayerLocked(playerID, client)
+	r.balanceTeamsLocked()
+	return nil
+}
+
+// addPlayerLocked is AddPlayer for callers already holding the room lock
+func (r *Room) addPlayerLocked(playerID string, client Sender) {
+	// A player already here, such as one restored without a connection or
+	// joining again, picks up where they left off
+	if _, exists := r.Players[playerID]; exists {
+		if client != nil {
+			r.Clients[playerID] = client
+		}
+		return
+	}
+
+	spawn := r.assignSpawnLocked(playerID)
+	at := r.Maze.Spawns[spawn]
 	r.Players[playerID] = &PlayerState{
-		ID: playerID,
-		X:  x,
-		Y:  y,
+		ID:           playerID,
+		X:            at.X,
+		Y:            at.Y,
+		Z:            at.Z,
+		Spawn:        spawn,
+		WallCharges:  r.wallCharges(),
+		joinedAt:     r.clock.Now(),
+		lastActionAt: r.clock.Now(),
+	}
+	if client != nil {
+		r.Clients[playerID] = client
+	}
+	// Matchmade rooms belong to nobody
+	if r.Host == "" && client != nil && len(r.Players)-len(r.bots) == 1 && !r.Rules.Ranked {
+		r.Host = playerID
+	}
+	r.logEventLocked(Event{Type: EventJoin, PlayerID: playerID, X: at.X, Y: at.Y, Z: at.Z})
+}
+
+// assignSpawnLocked picks the spawn point shared by the fewest players,
+// keeping a rejoining player's own
+func (r *Room) assignSpawnLocked(playerID string) int {
+	if p, ok := r.Players[playerID]; ok && p.Spawn < len(r.Maze.Spawns) {
+		return p.Spawn
+	}
+	used := make([]int, len(r.Maze.Spawns))
+	for _, p := range r.Players {
+		if p.Spawn < len(used) {
+			used[p.Spawn]++
+		}
+	}
+	best := 0
+	for i, n := range used {
+		if n < used[best] {
+			best = i
+		}
 	}
+	return best
 }
 
-// RemovePlayer removes a player from a room
+// RemovePlayer removes a player and their connection from a room
 func (r *Room) RemovePlayer(playerID string) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	r.removePlayerLocked(playerID)
+}
+
+// removePlayerLocked is RemovePlayer for callers already holding the room
+// lock
+func (r *Room) removePlayerLocked(playerID string) {
+	r.flushTickLocked(playerID, r.clock.Now())
+	r.dropFlagOfLocked(playerID, r.clock.Now())
 	delete(r.Players, playerID)
+	delete(r.Clients, playerID)
+	delete(r.lastSeq, playerID)
+	delete(r.bots, playerID)
+	delete(r.trails, playerID)
+	r.logEventLocked(Event{Type: EventLeave, PlayerID: playerID})
+	if r.Host == playerID {
+		r.migrateHostLocked()
+	}
+	r.balanceTeamsLocked()
+	r.cancelVetoLocked()
+
+	// A countdown only makes sense while everyone left is still ready
+	if r.State == StateCountdown && !r.allReadyLocked() {
+		r.cancelCountdownLocked()
+	}
 }
 
-// UpdatePlayerPosition updates a player's position
-func (r *Room) UpdatePlayerPosition(playerID string, x, y int) bool {
+// Broadcast sends a message to every client in the room except excludeID.
+// It takes the write lock since sending stamps and records the message.
+func (r *Room) Broadcast(msg messages.ServerMessage, excludeID string) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	r.broadcastLocked(msg, excludeID)
+}
+
+// broadcastLocked is Broadcast for callers already holding the room lock
+func (r *Room) broadcastLocked(msg messages.ServerMessage, excludeID string) {
+	for id := range r.Clients {
+		if id != excludeID {
+			r.sendLocked(id, msg)
+		}
+	}
+	r.sendWatchersLocked(msg)
+}
+
+// broadcastToLocked sends a message to the clients for which to is true,
+// for what only some players may see
+func (r *Room) broadcastToLocked(msg messages.ServerMessage, to func(playerID string) bool) {
+	for id := range r.Clients {
+		if to(id) {
+			r.sendLocked(id, msg)
+		}
+	}
+}
+
+// sendLocked delivers a message to one client, or holds it back while a
+// transaction is open or until the client's next tick, if it takes ticks.
+// Away players get nothing until they come back.
+func (r *Room) sendLocked(id string, msg messages.ServerMessage) {
+	client, ok := r.Clients[id]
+	if !ok || r.pausedLocked(id) {
+		return
+	}
+	if r.outbox != nil {
+		r.outbox[id] = append(r.outbox[id], msg)
+		return
+	}
+	if r.wantsTicksLocked(client) {
+		r.queueTickLocked(id, msg)
+		return
+	}
+	r.deliverLocked(id, client, msg)
+}
 
+// deliverLocked stamps, records and sends a message to one client
+func (r *Room) deliverLocked(id string, client Sender, msg messages.ServerMessage) {
+	msg = r.recordLocked(id, msg)
+	r.countBytesLocked(msg)
+	client.SendJSON(msg)
+}
+
+// BroadcastMove tells everyone where a player now is with a small
+// playerMoved delta instead of the whole player list. Full player state goes
+// out with the periodic snapshot and on resync.
+func (r *Room) BroadcastMove(playerID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.broadcastMoveLocked(playerID)
+}
+
+// broadcastMoveLocked is BroadcastMove for callers already holding the
+// room lock
+func (r *Room) broadcastMoveLocked(playerID string) {
 	player, exists := r.Players[playerID]
 	if !exists {
-		return false
+		return
+	}
+	msg := messages.ServerMessage{
+		Type:     "playerMoved",
+		Message:  playerID,
+		Position: &messages.Position{X: player.X, Y: player.Y, Z: player.Z},
 	}
+	// Clients that can't apply deltas get everyone with it
+	var full *messages.ServerMessage
+	for id, client := range r.Clients {
+		if supports(client, messages.CapDeltaUpdates) {
+			r.sendLocked(id, msg)
+			continue
+		}
+		if full == nil {
+			full = &messages.ServerMessage{}
+			*full = msg
+			full.Players = r.playersLocked()
+		}
+		r.sendLocked(id, *full)
+	}
+	r.sendWatchersLocked(msg)
+}
 
-	// Validate move against maze
-	if !r.Maze.CanMove(player.X, player.Y, x, y) {
-		return false
+// UpdatePlayerPosition updates a player's position. Moves are only
+// accepted while the match is playing, one cell at a time (a flight of
+// stairs counts as one) and no faster than the player's speed allows;
+// reaching the goal ends the match.
+func (r *Room) UpdatePlayerPosition(playerID string, x, y, z int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	player, exists := r.Players[playerID]
+	if !exists {
+		return ErrNoPlayer
 	}
+	return r.movePlayerLocked(player, game.Point{X: x, Y: y, Z: z}, r.clock.Now())
+}
 
-	player.X = x
-	player.Y = y
-	return true
+// MovePlayerDir moves a player one cell up, right, down or left of where
+// the server has them, or upstairs or downstairs, under the same rules as
+// UpdatePlayerPosition
+func (r *Room) MovePlayerDir(playerID, direction string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	player, exists := r.Players[playerID]
+	if !exists {
+		return ErrNoPlayer
+	}
+	to := player.at()
+	if dz, ok := game.Climb(direction); ok {
+		to.Z += dz
+	} else if dx, dy, ok := game.Offset(direction); ok {
+		to.X, to.Y = to.X+dx, to.Y+dy
+	} else {
+		return ErrBadDirection
+	}
+	return r.movePlayerLocked(player, to, r.clock.Now())
+}
+
+// movePlayerLocked validates and applies one step, with everything that
+// follows from it: reveals, pickups and reaching an exit
+func (r *Room) movePlayerLocked(player *PlayerState, to game.Point, now time.Time) error {
+	if r.State != StatePlaying {
+		return ErrNotPlaying
+	}
+	if player.home {
+		return ErrHome
+	}
+
+	// Validate move against maze (and the player's level at crossings)
+	level, ok := r.Maze.Step(player.at(), player.Level, to)
+	if !ok {
+		return ErrBadMove
+	}
+
+	// Enforce the cooldown the last step set, see speed.go
+	if err := r.checkMoveTimingLocked(player, now); err != nil {
+		return err
+	}
+	if !r.resolveCollisionLocked(player, to, level, now) {
+		return ErrBlocked
+	}
+	r.startCooldownLocked(player, to, now)
+
+	player.lastStep = &step{from: player.at(), fromLevel: player.Level, to: to}
+	player.moveTo(to)
+	player.Level = level
+	r.logEventLocked(Event{Type: EventMove, PlayerID: player.ID, X: to.X, Y: to.Y, Z: to.Z})
+	r.sendRevealLocked(player)
+	r.dropBreadcrumbLocked(player, now)
+	r.pickupLocked(player, now)
+	r.ctfStepLocked(player, now)
+	r.applyTerrainLocked(player, now)
+	r.meetMinotaurLocked(player, now)
+
+	r.reachGoalLocked(player, now)
+	r.debugCheckLocked("move")
+	return nil
 }
 
 // GetPlayers returns all players in the room
 func (r *Room) GetPlayers() []messages.Player {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
+	return r.playersLocked()
+}
 
+// playersLocked is GetPlayers for callers already holding the room lock
+func (r *Room) playersLocked() []messages.Player {
 	players := make([]messages.Player, 0, len(r.Players))
 	for _, p := range r.Players {
-		players = append(players, messages.Player{
-			ID: p.ID,
-			X:  p.X,
-			Y:  p.Y,
-		})
+		players = append(players, p.toMessage())
 	}
 	return players
 }
 
-// IsEmpty returns true if room has no players
+// at is the cell the player is on
+func (p *PlayerState) at() game.Point {
+	return game.Point{X: p.X, Y: p.Y, Z: p.Z}
+}
+
+// moveTo puts the player on a cell, leaving their level to the caller
+func (p *PlayerState) moveTo(to game.Point) {
+	p.X, p.Y, p.Z = to.X, to.Y, to.Z
+}
+
+// toMessage converts a player's state to its wire format
+func (p *PlayerState) toMessage() messages.Player {
+	return messages.Player{
+		ID:          p.ID,
+		Name:        p.Profile.Name,
+		Color:       p.Profile.Color,
+		Avatar:      p.Profile.Avatar,
+		Rating:      p.Profile.Rating,
+		X:           p.X,
+		Y:           p.Y,
+		Z:           p.Z,
+		Ready:       p.Ready,
+		Away:        p.Away,
+		Idle:        p.Idle,
+		Score:       p.Score,
+		Multiplier:  p.multiplier(),
+		Level:       p.Level,
+		RoundWins:   p.RoundWins,
+		Team:        p.Team,
+		TeamColor:   p.teamColor(),
+		Bot:         p.Bot,
+		Inventory:   append([]string(nil), p.Inventory...),
+		WallCharges: p.WallCharges,
+		Speed:       p.speed(),
+	}
+}
+
+// IsEmpty returns true if room has no players left besides bots
 func (r *Room) IsEmpty() bool {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	return len(r.Players) == 0
+	return len(r.Players) == len(r.bots)
+}
+
+// GetState returns the room's current lifecycle state
+func (r *Room) GetState() State {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.State
 }
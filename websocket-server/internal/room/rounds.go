@@ -0,0 +1,110 @@
+package room
+
+import (
+	"time"
+
+	"labyrinth-duel/websocket/internal/game"
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+// roundsToWin is how many round wins clinch a best-of-N match
+func (r *Room) roundsToWin() int {
+	return r.Rules.Rounds/2 + 1
+}
+
+// endRoundLocked credits the round winner and either starts the next round
+// on a fresh maze or reports that the match is decided
+func (r *Room) endRoundLocked(winnerID, reason string) (decided bool) {
+	r.round++
+	if p, ok := r.Players[winnerID]; ok {
+		p.RoundWins++
+		decided = p.RoundWins >= r.roundsToWin()
+	}
+	if r.round >= r.Rules.Rounds {
+		decided = true
+	}
+
+	r.broadcastLocked(messages.ServerMessage{
+		Type:    "roundOver",
+		Winner:  winnerID,
+		Reason:  reason,
+		Round:   r.round,
+		Players: r.playersLocked(),
+	}, "")
+
+	if !decided {
+		r.nextRoundLocked()
+	}
+	return decided
+}
+
+// nextRoundLocked swaps in a new maze, puts everyone back on their spawn and
+// counts down into the next round. Scores and round wins carry over.
+func (r *Room) nextRoundLocked() {
+	opts := r.mazeOpts
+	if opts.Seed != 0 {
+		// Keep seeded matches reproducible while still varying the maze
+		opts.Seed += int64(r.round)
+	}
+	r.replaceMazeLocked(r.generateLocked(r.Maze.Width, r.Maze.Height, opts))
+	r.startCountdownLocked()
+}
+
+// replaceMazeLocked swaps in a new maze, puts everyone back on their spawn
+// and sends it out as a newRound
+func (r *Room) replaceMazeLocked(maze *game.Maze) {
+	r.Maze = maze
+	r.Items = make(map[game.Point]*Item)
+	r.pendingCells = nil
+	r.applyDeadEndRulesLocked()
+
+	for _, p := range r.Players {
+		if p.Spawn >= len(r.Maze.Spawns) {
+			p.Spawn = 0
+		}
+		spawn := r.Maze.Spawns[p.Spawn]
+		p.moveTo(spawn)
+		p.Level = game.LevelSurface
+		p.Streak = 0
+		p.Inventory = nil
+		p.revealed = nil
+		p.claimed = nil
+		p.speedMods = nil
+		p.frozenUntil = time.Time{}
+		p.nextMoveAt = time.Time{}
+		p.lastStep = nil
+	}
+
+	for id := range r.Clients {
+		data, visible := r.mazeDataForLocked(id)
+		r.sendLocked(id, messages.ServerMessage{
+			Type:    "newRound",
+			Maze:    data,
+			Cells:   visible,
+			Round:   r.round + 1,
+			Players: r.playersLocked(),
+			Items:   r.itemsLocked(),
+		})
+	}
+	r.sendWatchersLocked(messages.ServerMessage{
+		Type:    "newRound",
+		Maze:    mazeToMessage(r.Maze),
+		Round:   r.round + 1,
+		Players: r.playersLocked(),
+		Items:   r.itemsLocked(),
+	})
+}
+
+// matchWinnerLocked returns the player with the most round wins, breaking
+// ties on score
+func (r *Room) matchWinnerLocked() string {
+	winner := ""
+	var best *PlayerState
+	for id, p := range r.Players {
+		if best == nil || p.RoundWins > best.RoundWins ||
+			(p.RoundWins == best.RoundWins && p.Score > best.Score) {
+			winner, best = id, p
+		}
+	}
+	return winner
+}
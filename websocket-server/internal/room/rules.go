@@ -0,0 +1,88 @@
+package room
+
+import (
+	"fmt"
+	"time"
+)
+
+// Dead-end policies for RuleSet.DeadEnds
+const (
+	DeadEndsKeep  = ""      // Leave the maze as generated
+	DeadEndsPrune = "prune" // Open the longest dead ends into loops
+	DeadEndsStuff = "stuff" // Put treasure at the end of the longest dead ends
+)
+
+const (
+	// DefaultDeadEndCount is how many dead ends a policy applies to
+	DefaultDeadEndCount = 3
+	// TreasurePointsPerCell scales treasure value with corridor length
+	TreasurePointsPerCell = 5
+)
+
+// Goal modes for RuleSet.GoalMode
+const (
+	GoalModeFirstExit = ""       // First player out of any exit wins
+	GoalModePoints    = "points" // Exits bank their value; highest score at time-up wins
+)
+
+// RuleSet holds the gameplay rules a room is created with
+type RuleSet struct {
+	DeadEnds          string        // DeadEndsKeep, DeadEndsPrune, or DeadEndsStuff
+	DeadEndCount      int           // How many of the longest dead ends the policy applies to
+	GoalMode          string        // GoalModeFirstExit or GoalModePoints
+	WallCharges       int           // Walls each player may break per match (default DefaultWallCharges)
+	Collision         string        // CollisionPass, CollisionBlock, or CollisionBump
+	Fog               bool          // Only send players the cells they have seen
+	FogRadius         int           // How far players see with fog on (default DefaultFogRadius)
+	Rounds            int           // Best-of-N rounds, each on a new maze (0 or 1 = single round)
+	Mode              string        // Registered GameMode name, "" for the classic race
+	Teams             int           // Number of teams, balanced by rating (0 = free-for-all, at most MaxTeams)
+	TeamGoal          string        // TeamGoalFirst, TeamGoalAll or TeamGoalScore
+	Coop              bool          // Everyone is one team racing the clock to get every player out
+	Hints             bool          // Spawn hint power-ups
+	MoveInterval      time.Duration // Time between moves at speed 1 on open floor (default BaseMoveInterval, at least MinMoveInterval)
+	Practice          bool          // Solo room that starts with one player and takes sandbox commands
+	Tutorial          bool          // Practice room that walks its player through the basics, see tutorial.go
+	Daily             string        // Day (YYYY-MM-DD) of the daily challenge the room's one player races against the clock
+	MapVeto           bool          // Players strike candidate mazes in turn before the first match
+	Ranked            bool          // Opened by the matchmaker for a pair of players
+	GoalMoveInterval  time.Duration // Move the exits far from every player this often (0 = fixed, at least MinGoalMoveInterval)
+	Minotaur          string        // MinotaurDifficulty name; "" for none
+	MazeShiftInterval time.Duration // Rotate a section, toggle a corridor or shift a wall this often (0 = static, at least MinMazeShiftInterval)
+	AntiCamp          string        // AntiCampOff, AntiCampReveal, AntiCampDecay or AntiCampBoth
+	CampWindow        time.Duration // How long a player may stay put before they are camping (default DefaultCampWindow)
+	CampRadius        int           // Cells a player may wander each way and still be camping (default DefaultCampRadius)
+}
+
+// applyDeadEndRulesLocked prunes or stuffs the longest dead ends
+func (r *Room) applyDeadEndRulesLocked() {
+	count := r.Rules.DeadEndCount
+	if count <= 0 {
+		count = DefaultDeadEndCount
+	}
+
+	treated := 0
+	for _, d := range r.Maze.DeadEnds() {
+		if treated == count {
+			break
+		}
+		// Spawn and goal cells are never touched
+		if r.Maze.IsSpawn(d.Tip) || r.Maze.IsGoal(d.Tip) {
+			continue
+		}
+		treated++
+
+		switch r.Rules.DeadEnds {
+		case DeadEndsPrune:
+			r.Maze.PruneDeadEnd(d)
+		case DeadEndsStuff:
+			r.placeItemLocked(&Item{
+				ID:    fmt.Sprintf("treasure-%d", treated),
+				Kind:  ItemTreasure,
+				X:     d.Tip.X,
+				Y:     d.Tip.Y,
+				Value: d.Length * TreasurePointsPerCell,
+			})
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package room
+
+import (
+	"errors"
+
+	"labyrinth-duel/websocket/internal/game"
+)
+
+// Sandbox commands, accepted in practice rooms only
+const (
+	SandboxTeleport  = "teleport"  // Jump to (x, y, z); landing on an exit counts
+	SandboxReveal    = "reveal"    // Lift the fog from the whole maze
+	SandboxSpawnItem = "spawnItem" // Drop a power-up of the given kind at (x, y, z)
+	SandboxSetSpeed  = "setSpeed"  // Change the player's speed stat
+)
+
+// Errors returned by Sandbox
+var (
+	ErrNotPractice    = errors.New("sandbox commands only work in practice rooms")
+	ErrUnknownCommand = errors.New("unknown sandbox command")
+)
+
+// Sandbox runs a practice command for a player as one transaction, through
+// the same Tx operations power-ups and game modes use. kind is only used by
+// spawnItem, and speed by setSpeed.
+func (r *Room) Sandbox(playerID, command string, at game.Point, kind string, speed float64) error {
+	return r.Transact(func(tx *Tx) error {
+		if !r.Rules.Practice {
+			return ErrNotPractice
+		}
+		if r.State != StatePlaying {
+			return ErrNotPlaying
+		}
+		player, err := tx.Player(playerID)
+		if err != nil {
+			return err
+		}
+
+		switch command {
+		case SandboxTeleport:
+			if err := tx.Teleport(playerID, at); err != nil {
+				return err
+			}
+			r.broadcastMoveLocked(playerID)
+			r.reachGoalLocked(player, r.clock.Now())
+			return nil
+		case SandboxReveal:
+			return tx.Reveal(playerID)
+		case SandboxSpawnItem:
+			return tx.SpawnItem(kind, at)
+		case SandboxSetSpeed:
+			return r.setSpeedLocked(playerID, speed)
+		}
+		return ErrUnknownCommand
+	})
+}
@@ -0,0 +1,102 @@
+package room
+
+import (
+	"time"
+
+	"labyrinth-duel/websocket/internal/game"
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+const (
+	// StreakDecay is how long a streak survives without another score
+	StreakDecay = 5 * time.Second
+	// StreakStep is how much each streak level adds to the multiplier
+	StreakStep = 0.5
+	// MaxMultiplier caps the streak bonus
+	MaxMultiplier = 4.0
+)
+
+// multiplier returns the score multiplier for the player's current streak
+func (p *PlayerState) multiplier() float64 {
+	m := 1 + float64(p.Streak)*StreakStep
+	if m > MaxMultiplier {
+		return MaxMultiplier
+	}
+	return m
+}
+
+// AwardPoints gives a player points for a pickup or objective
+func (r *Room) AwardPoints(playerID string, points int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.awardPointsLocked(playerID, points, r.clock.Now())
+}
+
+// awardPointsLocked applies the streak multiplier, extends the streak, and
+// broadcasts the new scores
+func (r *Room) awardPointsLocked(playerID string, points int, now time.Time) {
+	player, exists := r.Players[playerID]
+	if !exists {
+		return
+	}
+
+	gained := int(float64(points) * player.multiplier())
+	player.Score += gained
+	player.Streak++
+	r.logEventLocked(Event{Type: EventScore, PlayerID: playerID, X: player.X, Y: player.Y, Value: gained, At: now})
+	player.lastScoreAt = now
+
+	r.broadcastScoresLocked()
+}
+
+// decayStreaksLocked drops one streak level per StreakDecay without scoring
+func (r *Room) decayStreaksLocked(now time.Time) {
+	changed := false
+	for _, p := range r.Players {
+		if p.Streak > 0 && now.Sub(p.lastScoreAt) >= StreakDecay {
+			p.Streak--
+			p.lastScoreAt = now
+			changed = true
+		}
+	}
+
+	if changed {
+		r.broadcastScoresLocked()
+	}
+}
+
+// reachGoalLocked handles a player stepping onto an exit: in first-exit
+// mode they win; in points mode each exit pays out once per player. Exits
+// are just cells in capture the flag.
+func (r *Room) reachGoalLocked(player *PlayerState, now time.Time) {
+	goal, ok := r.Maze.GoalAt(player.at())
+	if !ok || r.tutorialHoldsGoalLocked(player) || r.ctfLocked() != nil {
+		return
+	}
+
+	if r.Rules.GoalMode != GoalModePoints {
+		r.awardPointsLocked(player.ID, goal.Value, now)
+		if r.teamCount() > 0 && r.Rules.TeamGoal == TeamGoalAll {
+			r.bringHomeLocked(player)
+			return
+		}
+		r.finishLocked(player.ID, "goal")
+		return
+	}
+
+	if player.claimed == nil {
+		player.claimed = make(map[game.Point]bool)
+	}
+	if player.claimed[goal.Point] {
+		return
+	}
+	player.claimed[goal.Point] = true
+	r.awardPointsLocked(player.ID, goal.Value, now)
+}
+
+func (r *Room) broadcastScoresLocked() {
+	r.broadcastLocked(messages.ServerMessage{
+		Type:    "scoreUpdate",
+		Players: r.playersLocked(),
+	}, "")
+}
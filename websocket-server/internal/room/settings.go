@@ -0,0 +1,61 @@
+package room
+
+import "time"
+
+// DefaultMazeSize is the width and height of new rooms' mazes
+const DefaultMazeSize = 10
+
+// Settings are the server-wide defaults and limits rooms are created with.
+// Zero values mean the defaults.
+type Settings struct {
+	MazeWidth     int           // New rooms' maze width (default DefaultMazeSize)
+	MazeHeight    int           // New rooms' maze height (default DefaultMazeSize)
+	MaxPlayers    int           // Cap on any room's player limit (0 = none)
+	TickInterval  time.Duration // How often rooms update timed state (default DefaultTickInterval)
+	MatchDuration time.Duration // How long a match runs (default DefaultMatchDuration)
+	RoomTTL       time.Duration // How long an empty room stays open before it is removed (0 = at once)
+	AttractMode   bool          // Bots play demo matches in empty public rooms while they stay open
+	IdleTimeout   time.Duration // How long a player may do nothing before they are flagged idle (0 = never)
+	IdleGrace     time.Duration // How much longer before an idle player is removed (default DefaultIdleGrace)
+}
+
+// mazeSize returns the size new rooms' mazes are generated at
+func (s Settings) mazeSize() (int, int) {
+	w, h := s.MazeWidth, s.MazeHeight
+	if w <= 0 {
+		w = DefaultMazeSize
+	}
+	if h <= 0 {
+		h = DefaultMazeSize
+	}
+	return w, h
+}
+
+// maxPlayers caps a room's requested player limit (0 = unlimited)
+func (s Settings) maxPlayers(requested int) int {
+	if s.MaxPlayers > 0 && (requested <= 0 || requested > s.MaxPlayers) {
+		return s.MaxPlayers
+	}
+	return requested
+}
+
+func (s Settings) tickInterval() time.Duration {
+	if s.TickInterval > 0 {
+		return s.TickInterval
+	}
+	return DefaultTickInterval
+}
+
+func (s Settings) matchDuration() time.Duration {
+	if s.MatchDuration > 0 {
+		return s.MatchDuration
+	}
+	return DefaultMatchDuration
+}
+
+func (s Settings) idleGrace() time.Duration {
+	if s.IdleGrace > 0 {
+		return s.IdleGrace
+	}
+	return DefaultIdleGrace
+}
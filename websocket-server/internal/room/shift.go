@@ -0,0 +1,195 @@
+package room
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+	"time"
+
+	"labyrinth-duel/websocket/internal/game"
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+// Maze shift kinds, see RuleSet.MazeShiftInterval
+const (
+	ShiftRotate = "rotate" // A square section's inner walls turn a quarter
+	ShiftToggle = "toggle" // A corridor opens, or closes
+	ShiftWall   = "shift"  // A wall comes down and another goes up nearby
+)
+
+const (
+	// MinMazeShiftInterval is the shortest RuleSet.MazeShiftInterval allowed
+	MinMazeShiftInterval = 10 * time.Second
+	// MazeShiftWarning is how long before the maze shifts players are warned
+	MazeShiftWarning = 3 * time.Second
+	// ShiftSectionSize is the side of the square a rotate turns
+	ShiftSectionSize = 3
+	// ShiftReach is how far from the wall it takes down a shift puts one up
+	ShiftReach = 2
+	// shiftAttempts bounds the search for a shift that keeps the maze solvable
+	shiftAttempts = 20
+)
+
+var shiftKinds = []string{ShiftRotate, ShiftToggle, ShiftWall}
+
+// mazeShift is one planned change to the maze
+type mazeShift struct {
+	kind  string
+	at    game.Point // Section's top-left cell (rotate), or cell of the wall to toggle or take down
+	dir   string     // Side of at the wall is on (toggle, shift)
+	to    game.Point // Cell of the wall a shift puts up
+	toDir string
+}
+
+// mazeShiftInterval is how often the maze shifts, or 0 if it holds still
+func (r *Room) mazeShiftInterval() time.Duration {
+	if r.Rules.MazeShiftInterval <= 0 {
+		return 0
+	}
+	return max(r.Rules.MazeShiftInterval, MinMazeShiftInterval)
+}
+
+// scheduleMazeShiftLocked sets when the maze next shifts, counting from now
+func (r *Room) scheduleMazeShiftLocked(now time.Time) {
+	r.nextShift = time.Time{}
+	r.plannedShift = nil
+	r.lastShiftWarning = 0
+	if interval := r.mazeShiftInterval(); interval > 0 {
+		r.nextShift = now.Add(interval)
+	}
+}
+
+// updateMazeShiftLocked picks the next shift MazeShiftWarning ahead and
+// counts down to it once per second, saying what will change where, then
+// makes it. Changed cells go out in the tick's mazeUpdated.
+func (r *Room) updateMazeShiftLocked(now time.Time) {
+	if r.nextShift.IsZero() {
+		return
+	}
+	remaining := r.nextShift.Sub(now)
+	if remaining > MazeShiftWarning {
+		return
+	}
+	if r.plannedShift == nil {
+		if r.plannedShift = r.planMazeShiftLocked(); r.plannedShift == nil {
+			r.scheduleMazeShiftLocked(now) // Nothing safe to change; try again next time
+			return
+		}
+	}
+	if remaining > 0 {
+		seconds := int(math.Ceil(remaining.Seconds()))
+		if seconds != r.lastShiftWarning {
+			r.lastShiftWarning = seconds
+			r.broadcastLocked(messages.ServerMessage{
+				Type:     "mazeShifting",
+				Message:  r.plannedShift.kind,
+				Seconds:  seconds,
+				Position: &messages.Position{X: r.plannedShift.at.X, Y: r.plannedShift.at.Y, Z: r.plannedShift.at.Z},
+			}, "")
+		}
+		return
+	}
+
+	r.shiftMazeLocked(r.plannedShift, now)
+	r.scheduleMazeShiftLocked(now)
+}
+
+// planMazeShiftLocked picks a random shift that leaves the maze solvable
+func (r *Room) planMazeShiftLocked() *mazeShift {
+	for range shiftAttempts {
+		s := r.randomShiftLocked()
+		if _, ok := r.tryShiftLocked(s); ok {
+			return s
+		}
+	}
+	return nil
+}
+
+// randomShiftLocked makes up a shift somewhere on one floor of the maze,
+// which may not be possible
+func (r *Room) randomShiftLocked() *mazeShift {
+	m := r.Maze
+	s := &mazeShift{kind: shiftKinds[rand.Intn(len(shiftKinds))]}
+	z := rand.Intn(m.FloorCount())
+	if s.kind == ShiftRotate {
+		s.at = game.Point{X: rand.Intn(max(1, m.Width-ShiftSectionSize+1)), Y: rand.Intn(max(1, m.Height-ShiftSectionSize+1)), Z: z}
+		return s
+	}
+
+	dirs := []string{"up", "right", "down", "left"}
+	s.at = game.Point{X: rand.Intn(m.Width), Y: rand.Intn(m.Height), Z: z}
+	s.dir = dirs[rand.Intn(len(dirs))]
+	s.to = game.Point{
+		X: s.at.X + rand.Intn(2*ShiftReach+1) - ShiftReach,
+		Y: s.at.Y + rand.Intn(2*ShiftReach+1) - ShiftReach,
+		Z: z,
+	}
+	s.toDir = dirs[rand.Intn(len(dirs))]
+	return s
+}
+
+// tryShiftLocked makes a shift on a copy of the maze, returning the copy if
+// it changed a wall, every cell is still reachable and every player can
+// still get to an exit
+func (r *Room) tryShiftLocked(s *mazeShift) (*game.Maze, bool) {
+	m := r.Maze.Clone()
+	changed, ok := applyShift(m, s)
+	// Moving a wall onto itself or turning a symmetric section does nothing
+	differs := func(p game.Point) bool { return *m.CellAt(p) != *r.Maze.CellAt(p) }
+	if !ok || !slices.ContainsFunc(changed, differs) || !m.IsConnected() {
+		return nil, false
+	}
+	for _, p := range r.Players {
+		if m.PathToGoal(p.at(), p.Level) == nil {
+			return nil, false
+		}
+	}
+	return m, true
+}
+
+// shiftMazeLocked makes a planned shift, unless the maze has changed since
+// so that it no longer keeps it solvable
+func (r *Room) shiftMazeLocked(s *mazeShift, now time.Time) {
+	if _, ok := r.tryShiftLocked(s); !ok {
+		return
+	}
+	changed, _ := applyShift(r.Maze, s)
+	r.markChangedLocked(changed...)
+	r.logEventLocked(Event{Type: EventMazeShift, X: s.at.X, Y: s.at.Y, Z: s.at.Z, Detail: s.kind, At: now})
+}
+
+// applyShift makes a shift on a maze, returning the cells it changed
+func applyShift(m *game.Maze, s *mazeShift) ([]game.Point, bool) {
+	switch s.kind {
+	case ShiftRotate:
+		return m.RotateSection(s.at, ShiftSectionSize)
+	case ShiftToggle:
+		b, ok := neighbor(m, s.at, s.dir)
+		if !ok {
+			return nil, false
+		}
+		if !m.RemoveWallBetween(s.at, b) && !m.AddWallBetween(s.at, b) {
+			return nil, false
+		}
+		return []game.Point{s.at, b}, true
+	case ShiftWall:
+		b, ok := neighbor(m, s.at, s.dir)
+		d, ok2 := neighbor(m, s.to, s.toDir)
+		if !ok || !ok2 || !m.RemoveWallBetween(s.at, b) || !m.AddWallBetween(s.to, d) {
+			return nil, false
+		}
+		return []game.Point{s.at, b, s.to, d}, true
+	}
+	return nil, false
+}
+
+// neighbor returns the cell on the given side of p if it is in the maze
+// and neither is a crossing, whose walls stay put
+func neighbor(m *game.Maze, p game.Point, dir string) (game.Point, bool) {
+	dx, dy, ok := game.Offset(dir)
+	n := game.Point{X: p.X + dx, Y: p.Y + dy, Z: p.Z}
+	if !ok || !m.Contains(p) || !m.Contains(n) {
+		return n, false
+	}
+	return n, m.CellAt(p).Under == "" && m.CellAt(n).Under == ""
+}
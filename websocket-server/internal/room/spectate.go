@@ -0,0 +1,75 @@
+package room
+
+import (
+	"errors"
+
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+// ErrNotWatchable is returned by Watch for rooms outsiders can't see into
+var ErrNotWatchable = errors.New("private rooms can't be watched")
+
+// Watch subscribes a client who isn't playing to everything sent to the
+// whole room, and returns a spectating message with the full maze and where
+// everyone stands for them to start from. Watchers see no fog and nothing
+// meant only for some players.
+func (r *Room) Watch(id string, client Sender) (messages.ServerMessage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.Access.Private {
+		return messages.ServerMessage{}, ErrNotWatchable
+	}
+	if r.watchers == nil {
+		r.watchers = make(map[string]Sender)
+	}
+	r.watchers[id] = client
+	return messages.ServerMessage{
+		Type:    "spectating",
+		RoomID:  r.ID,
+		Maze:    mazeToMessage(r.Maze),
+		Players: r.playersLocked(),
+		State:   string(r.State),
+		Items:   r.itemsLocked(),
+		Flags:   r.flagsLocked(r.clock.Now()),
+		Hazards: r.hazardsLocked(),
+		Host:    r.Host,
+	}, nil
+}
+
+// Unwatch stops sending a watcher the room's messages
+func (r *Room) Unwatch(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.watchers, id)
+}
+
+// sendWatchersLocked delivers a message meant for the whole room to its
+// watchers, holding it back while a transaction is open
+func (r *Room) sendWatchersLocked(msg messages.ServerMessage) {
+	if len(r.watchers) == 0 {
+		return
+	}
+	if r.outbox != nil {
+		r.watchOutbox = append(r.watchOutbox, msg)
+		return
+	}
+	for _, w := range r.watchers {
+		r.countBytesLocked(msg)
+		w.SendJSON(msg)
+	}
+}
+
+// flushWatchersLocked sends watchers what a committed transaction held back
+// for them, combined like everyone else's
+func (r *Room) flushWatchersLocked() {
+	held := r.watchOutbox
+	r.watchOutbox = nil
+	switch len(held) {
+	case 0:
+	case 1:
+		r.sendWatchersLocked(held[0])
+	default:
+		r.sendWatchersLocked(messages.ServerMessage{Type: "batch", Batch: held})
+	}
+}
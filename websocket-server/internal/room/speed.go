@@ -0,0 +1,147 @@
+package room
+
+import (
+	"errors"
+	"time"
+
+	"labyrinth-duel/websocket/internal/game"
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+const (
+	// DefaultSpeed is the speed stat players start with
+	DefaultSpeed = 1.0
+	// MinSpeed and MaxSpeed bound the speed stat
+	MinSpeed = 0.25
+	MaxSpeed = 4.0
+	// SpeedBoostFactor is how much faster a speed boost makes its user
+	SpeedBoostFactor = 2.0
+	// MinMoveInterval is the shortest move interval a room can be set to
+	MinMoveInterval = 20 * time.Millisecond
+	// MoveLeeway is how early a move may come, as a share of the wait
+	// before it, and still be accepted: network jitter bunches up moves
+	// that were sent in good time
+	MoveLeeway = 0.1
+)
+
+// Errors returned by UpdatePlayerPosition and SetSpeed
+var (
+	ErrBadMove  = errors.New("not a step to a neighbouring open cell")
+	ErrBlocked  = errors.New("another player is in the way")
+	ErrTooFast  = errors.New("moving faster than the player's speed allows")
+	ErrFrozen   = errors.New("player is frozen")
+	ErrBadSpeed = errors.New("speed is out of range")
+)
+
+// speedModifier speeds a player up (factor above 1) or slows them down
+// (below 1) until it wears off
+type speedModifier struct {
+	source string // What applied it, such as an item kind; applying it again refreshes it
+	factor float64
+	until  time.Time
+}
+
+// moveInterval is the room's time between moves before terrain, speed and
+// modifiers
+func (r *Room) moveInterval() time.Duration {
+	if r.Rules.MoveInterval > 0 {
+		return max(r.Rules.MoveInterval, MinMoveInterval)
+	}
+	return BaseMoveInterval
+}
+
+// checkMoveTimingLocked rejects a move the player isn't allowed to make
+// yet, give or take MoveLeeway
+func (r *Room) checkMoveTimingLocked(player *PlayerState, now time.Time) error {
+	if now.Before(player.frozenUntil) {
+		return ErrFrozen
+	}
+	leeway := time.Duration(float64(player.moveCooldown) * MoveLeeway)
+	if now.Before(player.nextMoveAt.Add(-leeway)) {
+		return ErrTooFast
+	}
+	return nil
+}
+
+// startCooldownLocked makes the player wait before moving again after
+// stepping onto at. The wait after an early move runs from when it was
+// due, so the leeway never adds up to extra speed.
+func (r *Room) startCooldownLocked(player *PlayerState, at game.Point, now time.Time) {
+	from := now
+	if player.nextMoveAt.After(now) {
+		from = player.nextMoveAt
+	}
+	player.moveCooldown = r.moveCooldownLocked(player, at, now)
+	player.nextMoveAt = from.Add(player.moveCooldown)
+}
+
+// moveCooldownLocked is how long the player must wait after stepping onto
+// a cell before they move again: the room's move interval, scaled by the
+// terrain there, divided by their speed stat and by every speed modifier in
+// effect on them, flag carrying included
+func (r *Room) moveCooldownLocked(player *PlayerState, at game.Point, now time.Time) time.Duration {
+	speed := player.speed() * player.speedFactor(now)
+	if r.carryingFlagLocked(player.ID) {
+		speed *= FlagCarrierSpeed
+	}
+	return time.Duration(float64(r.moveInterval()) * r.Maze.MoveCost(at) / speed)
+}
+
+// speed is the player's speed stat
+func (p *PlayerState) speed() float64 {
+	if p.Speed <= 0 {
+		return DefaultSpeed
+	}
+	return p.Speed
+}
+
+// speedFactor multiplies together the modifiers still in effect, dropping
+// those that have worn off
+func (p *PlayerState) speedFactor(now time.Time) float64 {
+	factor := 1.0
+	active := p.speedMods[:0]
+	for _, m := range p.speedMods {
+		if now.Before(m.until) {
+			factor *= m.factor
+			active = append(active, m)
+		}
+	}
+	p.speedMods = active
+	return factor
+}
+
+// addSpeedModifier applies a modifier for d, replacing any earlier one
+// from the same source
+func (p *PlayerState) addSpeedModifier(source string, factor float64, d time.Duration, now time.Time) {
+	for i, m := range p.speedMods {
+		if m.source == source {
+			p.speedMods[i] = speedModifier{source: source, factor: factor, until: now.Add(d)}
+			return
+		}
+	}
+	p.speedMods = append(p.speedMods, speedModifier{source: source, factor: factor, until: now.Add(d)})
+}
+
+// SetSpeed changes a player's speed stat, between MinSpeed and MaxSpeed
+func (r *Room) SetSpeed(playerID string, speed float64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.setSpeedLocked(playerID, speed)
+}
+
+func (r *Room) setSpeedLocked(playerID string, speed float64) error {
+	player, ok := r.Players[playerID]
+	if !ok {
+		return ErrNoPlayer
+	}
+	if speed < MinSpeed || speed > MaxSpeed {
+		return ErrBadSpeed
+	}
+	player.Speed = speed
+	r.broadcastLocked(messages.ServerMessage{
+		Type:    "speedChanged",
+		Message: playerID,
+		Players: r.playersLocked(),
+	}, "")
+	return nil
+}
@@ -0,0 +1,216 @@
+package room
+
+import (
+	"math"
+	"time"
+
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+// State is a room's position in the match lifecycle
+type State string
+
+const (
+	StateWaiting   State = "waiting"   // Lobby: players join and ready up
+	StateCountdown State = "countdown" // Everyone is ready, match about to start
+	StatePlaying   State = "playing"   // Race is on, moves are accepted
+	StatePaused    State = "paused"    // Match frozen: the clock stops and moves are rejected
+	StateFinished  State = "finished"  // Someone won, moves are rejected
+)
+
+const (
+	// DefaultMinPlayers is how many ready players are needed to start
+	DefaultMinPlayers = 2
+	// CountdownDuration is how long the pre-match countdown lasts
+	CountdownDuration = 3 * time.Second
+)
+
+// SetReady marks a player as ready and starts the countdown once every
+// player in the room is ready. Returns false if the room isn't in the lobby.
+func (r *Room) SetReady(playerID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	player, exists := r.Players[playerID]
+	if !exists || r.State != StateWaiting {
+		return false
+	}
+
+	player.Ready = true
+
+	r.broadcastLocked(messages.ServerMessage{
+		Type:    "playerReady",
+		Message: playerID,
+		Players: r.playersLocked(),
+	}, "")
+
+	r.startWhenReadyLocked(r.clock.Now())
+	return true
+}
+
+// InRankedMatch reports whether a player is in a ranked room whose match is
+// under way, from the map veto to the end, so leaving now abandons it
+func (r *Room) InRankedMatch(playerID string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if _, ok := r.Players[playerID]; !ok || !r.Rules.Ranked {
+		return false
+	}
+	return r.veto != nil || r.State == StateCountdown || r.State == StatePlaying || r.State == StatePaused
+}
+
+// allReadyLocked reports whether enough players are present and all are ready
+func (r *Room) allReadyLocked() bool {
+	if len(r.Players) < r.MinPlayers {
+		return false
+	}
+	for _, p := range r.Players {
+		if !p.Ready {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Room) startCountdownLocked() {
+	r.State = StateCountdown
+	r.countdownEnds = r.clock.Now().Add(CountdownDuration)
+	r.lastCountdown = int(CountdownDuration.Seconds())
+
+	r.broadcastLocked(messages.ServerMessage{
+		Type:     "gameStarting",
+		State:    string(r.State),
+		Seconds:  r.lastCountdown,
+		StartsAt: r.countdownEnds.UnixMilli(),
+	}, "")
+}
+
+// cancelCountdownLocked drops back to the lobby, e.g. when a player leaves
+func (r *Room) cancelCountdownLocked() {
+	r.State = StateWaiting
+	r.broadcastLocked(messages.ServerMessage{
+		Type:    "countdown",
+		State:   string(r.State),
+		Message: "Countdown cancelled",
+	}, "")
+}
+
+// updateCountdownLocked announces each remaining second and starts the match
+func (r *Room) updateCountdownLocked(now time.Time) {
+	remaining := r.countdownEnds.Sub(now)
+	if remaining <= 0 {
+		r.startMatchLocked(now)
+		r.broadcastLocked(messages.ServerMessage{
+			Type:    "countdown",
+			State:   string(r.State),
+			Message: "Go!",
+		}, "")
+		return
+	}
+
+	seconds := int(math.Ceil(remaining.Seconds()))
+	if seconds != r.lastCountdown {
+		r.lastCountdown = seconds
+		r.broadcastLocked(messages.ServerMessage{
+			Type:    "countdown",
+			State:   string(r.State),
+			Seconds: seconds,
+		}, "")
+	}
+}
+
+// startMatchLocked begins play (or the next round of a best-of-N match) and
+// marks everyone's start in the event log
+func (r *Room) startMatchLocked(now time.Time) {
+	r.State = StatePlaying
+	if r.round == 0 {
+		r.pauses = 0
+		r.events = nil // Earlier matches are in their records and replays
+		r.matchStartedAt = now
+		r.matchMazes = nil
+		if r.balanceTeamsLocked() {
+			r.broadcastTeamsLocked()
+		}
+	}
+	r.matchMazes = append(r.matchMazes, mazeToMessage(r.Maze))
+	r.logEventLocked(Event{Type: EventRound, Value: len(r.matchMazes) - 1, At: now})
+	r.roundStartedAt = now
+	r.roundExtension = 0
+	r.lastTimerSecond = 0
+	r.nextItemSpawn = now.Add(ItemSpawnInterval)
+	r.scheduleGoalMoveLocked(now)
+	r.scheduleMazeShiftLocked(now)
+	r.spawnMinotaursLocked(now)
+	r.trails = nil
+	r.resetCampingLocked(now)
+	r.setUpFlagsLocked()
+
+	for id, p := range r.Players {
+		p.WallCharges = r.wallCharges()
+		p.home = false
+		r.logEventLocked(Event{Type: EventMatchStart, PlayerID: id, X: p.X, Y: p.Y, At: now})
+	}
+}
+
+// finishLocked ends the round. Once the match is over it records it and
+// announces the winner with the match summary.
+func (r *Room) finishLocked(winnerID, reason string) {
+	if r.teamCount() > 0 && r.Rules.TeamGoal == TeamGoalScore && r.ctfLocked() == nil {
+		winnerID = r.topTeamScorerLocked()
+	}
+	if r.Rules.Rounds > 1 {
+		if !r.endRoundLocked(winnerID, reason) {
+			return
+		}
+		winnerID = r.matchWinnerLocked()
+	}
+
+	now := r.clock.Now()
+	winningTeam := r.teamOfLocked(winnerID)
+	r.State = StateFinished
+	r.logEventLocked(Event{Type: EventFinish, PlayerID: winnerID, At: now})
+
+	awards := r.computeAwardsLocked()
+	players := r.playersLocked()
+	var ranking []string
+	if reason == "timeUp" && r.Rules.GoalMode != GoalModePoints {
+		ranking = r.rankByGoalDistanceLocked()
+	}
+	r.LastMatch = &MatchRecord{
+		RoomID:      r.ID,
+		Winner:      winnerID,
+		WinningTeam: winningTeam,
+		Reason:      reason,
+		StartedAt:   r.matchStartedAt,
+		EndedAt:     now,
+		Players:     players,
+		Teams:       r.teamsLocked(),
+		Awards:      awards,
+		Moves:       r.moveCountsLocked(),
+		Seed:        r.matchMazes[0].Seed,
+		Replay:      r.replayLocked(winnerID, players, now),
+		Practice:    r.Rules.Practice,
+		Coop:        r.Rules.Coop,
+		Mode:        r.Rules.Mode,
+		Daily:       r.Rules.Daily,
+	}
+	// Demo matches are only for show
+	if r.onFinish != nil && !r.demo {
+		r.onFinish(r.LastMatch)
+	}
+
+	r.broadcastLocked(messages.ServerMessage{
+		Type:        "gameOver",
+		State:       string(r.State),
+		Winner:      winnerID,
+		WinningTeam: winningTeam,
+		Reason:      reason,
+		Summary: &messages.GameSummary{
+			Duration: now.Sub(r.matchStartedAt).Seconds(),
+			Players:  players,
+			Awards:   awards,
+			Ranking:  ranking,
+		},
+	}, "")
+}
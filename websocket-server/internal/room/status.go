@@ -0,0 +1,79 @@
+package room
+
+import (
+	"math"
+	"sort"
+
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+// Status is a room's live state for operators, including private rooms'
+type Status struct {
+	ID          string            `json:"id"`
+	State       string            `json:"state"`
+	Mode        string            `json:"mode,omitempty"`
+	Private     bool              `json:"private"`
+	JoinCode    string            `json:"joinCode,omitempty"`
+	Host        string            `json:"host,omitempty"`
+	Rules       RuleSet           `json:"rules"`
+	Width       int               `json:"width"`
+	Height      int               `json:"height"`
+	Players     []messages.Player `json:"players"`
+	MaxPlayers  int               `json:"maxPlayers,omitempty"`
+	Round       int               `json:"round"` // Rounds completed in the current match
+	SecondsLeft int               `json:"secondsLeft,omitempty"`
+	Tick        uint64            `json:"tick"`
+	Version     uint64            `json:"version"`
+
+	MessagesPerSec int  `json:"messagesPerSec"` // Sent in the last full second
+	BytesPerSec    int  `json:"bytesPerSec"`
+	Degraded       bool `json:"degraded"`
+}
+
+// Status returns the room's live state
+func (r *Room) Status() Status {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	s := Status{
+		ID:             r.ID,
+		State:          string(r.State),
+		Mode:           r.Rules.Mode,
+		Private:        r.Access.Private,
+		JoinCode:       r.JoinCode,
+		Host:           r.Host,
+		Rules:          r.Rules,
+		Width:          r.Maze.Width,
+		Height:         r.Maze.Height,
+		Players:        r.playersLocked(),
+		MaxPlayers:     r.Access.MaxPlayers,
+		Round:          r.round,
+		Tick:           r.tickCount,
+		Version:        r.version,
+		MessagesPerSec: r.LastMessages,
+		BytesPerSec:    r.LastBandwidth,
+		Degraded:       r.degraded,
+	}
+	if r.State == StatePlaying {
+		remaining := r.roundStartedAt.Add(r.MatchDuration).Sub(r.clock.Now())
+		s.SecondsLeft = int(math.Max(0, math.Ceil(remaining.Seconds())))
+	}
+	return s
+}
+
+// Statuses returns the live state of every room, sorted by ID
+func (m *Manager) Statuses() []Status {
+	m.mu.RLock()
+	rooms := make([]*Room, 0, len(m.rooms))
+	for _, r := range m.rooms {
+		rooms = append(rooms, r)
+	}
+	m.mu.RUnlock()
+
+	statuses := make([]Status, 0, len(rooms))
+	for _, r := range rooms {
+		statuses = append(statuses, r.Status())
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].ID < statuses[j].ID })
+	return statuses
+}
@@ -0,0 +1,54 @@
+package room
+
+import (
+	"log/slog"
+
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+// suspendableLocked reports whether the room's match may be suspended:
+// only casual matches between players can, and only with somewhere to
+// keep them
+func (r *Room) suspendableLocked() bool {
+	return r.onSuspend != nil && !r.Rules.Ranked && !r.Rules.Practice && r.Rules.Daily == ""
+}
+
+// suspendLocked carries out a passed suspend vote. The room is saved and
+// handed to onSuspend, then everyone is sent away with the code that
+// resumes it, leaving the room empty for the server to close.
+func (r *Room) suspendLocked() {
+	code, err := r.onSuspend(r.saveLocked())
+	if err != nil {
+		slog.Error("Cannot suspend match", "room", r.ID, "err", err)
+		r.broadcastLocked(messages.ServerMessage{
+			Type:    "suspendFailed",
+			Message: "the match could not be saved",
+		}, "")
+		return
+	}
+
+	r.broadcastLocked(messages.ServerMessage{
+		Type:   "matchSuspended",
+		RoomID: r.ID,
+		Code:   code,
+	}, "")
+	r.emptyLocked()
+}
+
+// emptyLocked sends every player away and ends the match, leaving a room
+// nobody can play in until it is removed
+func (r *Room) emptyLocked() {
+	r.flushTicksLocked(r.clock.Now())
+	for _, client := range r.Clients {
+		if leaver, ok := client.(Leaver); ok {
+			leaver.LeftRoom(r.ID)
+		}
+	}
+	r.Players = make(map[string]*PlayerState)
+	r.Clients = make(map[string]Sender)
+	r.lastSeq = nil
+	r.bots = nil
+	r.watchers = nil
+	r.demo = false
+	r.State = StateFinished
+}
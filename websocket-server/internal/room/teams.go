@@ -0,0 +1,245 @@
+package room
+
+import (
+	"errors"
+	"sort"
+
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+const (
+	// MaxTeams caps RuleSet.Teams
+	MaxTeams = 8
+	// ProvisionalMatches is how many matches a player needs before their
+	// rating is trusted for balancing; newer players count as average
+	ProvisionalMatches = 5
+)
+
+// Team goals: what wins a team room (RuleSet.TeamGoal)
+const (
+	TeamGoalFirst = ""      // The first player to an exit wins for their team
+	TeamGoalAll   = "all"   // The first team to get every member to an exit wins
+	TeamGoalScore = "score" // The team with the highest combined score wins when the match ends
+)
+
+// TeamColors tint each team's players, team 1 first
+var TeamColors = [MaxTeams]string{
+	"#e74c3c", "#3498db", "#2ecc71", "#f1c40f",
+	"#9b59b6", "#e67e22", "#1abc9c", "#95a5a6",
+}
+
+// Errors returned by SetTeam and moves
+var (
+	ErrNotHost    = errors.New("only the room host can do that")
+	ErrNoTeams    = errors.New("room is not playing in teams")
+	ErrBadTeam    = errors.New("no such team")
+	ErrNotInLobby = errors.New("teams can only change in the lobby")
+	ErrHome       = errors.New("player already made it out")
+)
+
+// teamCount returns how many teams the room plays in, or 0 for free-for-all.
+// Co-op rooms are one team against the maze.
+func (r *Room) teamCount() int {
+	switch {
+	case r.Rules.Coop:
+		return 1
+	case r.Rules.Teams < 2:
+		return 0
+	case r.Rules.Teams > MaxTeams:
+		return MaxTeams
+	}
+	return r.Rules.Teams
+}
+
+// balanceTeamsLocked assigns teams, numbered from 1. In the lobby every
+// player the host hasn't placed is reshuffled: strongest first, each onto
+// the smallest team, breaking ties by the lowest total skill. Once the match
+// is under way only players without a team are placed, so nobody switches
+// sides mid-match. Reports whether anyone changed team.
+func (r *Room) balanceTeamsLocked() bool {
+	teams := r.teamCount()
+	if teams == 0 {
+		return false
+	}
+	reshuffle := r.State == StateWaiting || r.State == StateCountdown
+
+	skill := r.skillsLocked()
+	sizes := make([]int, teams)
+	totals := make([]int, teams)
+	var free []*PlayerState
+	for _, p := range r.Players {
+		if p.Team > 0 && p.Team <= teams && (p.teamPinned || !reshuffle) {
+			sizes[p.Team-1]++
+			totals[p.Team-1] += skill[p.ID]
+			continue
+		}
+		free = append(free, p)
+	}
+	sort.Slice(free, func(i, j int) bool {
+		if skill[free[i].ID] != skill[free[j].ID] {
+			return skill[free[i].ID] > skill[free[j].ID]
+		}
+		return free[i].ID < free[j].ID
+	})
+
+	changed := false
+	for _, p := range free {
+		best := 0
+		for t := 1; t < teams; t++ {
+			if sizes[t] < sizes[best] || (sizes[t] == sizes[best] && totals[t] < totals[best]) {
+				best = t
+			}
+		}
+		sizes[best]++
+		totals[best] += skill[p.ID]
+		if p.Team != best+1 {
+			p.Team = best + 1
+			changed = true
+		}
+	}
+	return changed
+}
+
+// skillsLocked rates every player for balancing. Players with too short a
+// history to trust their rating count as the average of those who have one.
+func (r *Room) skillsLocked() map[string]int {
+	sum, rated := 0, 0
+	for _, p := range r.Players {
+		if p.Profile.Matches >= ProvisionalMatches {
+			sum += p.Profile.Rating
+			rated++
+		}
+	}
+
+	skill := make(map[string]int, len(r.Players))
+	for id, p := range r.Players {
+		skill[id] = p.Profile.Rating
+		if p.Profile.Matches < ProvisionalMatches && rated > 0 {
+			skill[id] = sum / rated
+		}
+	}
+	return skill
+}
+
+// SetTeam lets the host move a player onto a team, keeping them there
+// through rebalancing. Team 0 hands the player back to auto-balancing.
+func (r *Room) SetTeam(hostID, playerID string, team int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if hostID != r.Host {
+		return ErrNotHost
+	}
+	teams := r.teamCount()
+	if teams == 0 {
+		return ErrNoTeams
+	}
+	if r.State != StateWaiting {
+		return ErrNotInLobby
+	}
+	player, exists := r.Players[playerID]
+	if !exists {
+		return ErrNoPlayer
+	}
+	if team < 0 || team > teams {
+		return ErrBadTeam
+	}
+
+	player.teamPinned = team > 0
+	if team > 0 {
+		player.Team = team
+	}
+	r.balanceTeamsLocked()
+	r.broadcastTeamsLocked()
+	return nil
+}
+
+// broadcastTeamsLocked tells everyone the current team line-up
+func (r *Room) broadcastTeamsLocked() {
+	r.broadcastLocked(messages.ServerMessage{
+		Type:    "teamsUpdated",
+		Players: r.playersLocked(),
+	}, "")
+}
+
+// teamsLocked lists the player IDs on each team, sorted, team 1 first
+func (r *Room) teamsLocked() [][]string {
+	teams := r.teamCount()
+	if teams == 0 {
+		return nil
+	}
+	out := make([][]string, teams)
+	for _, p := range r.Players {
+		if p.Team > 0 && p.Team <= teams {
+			out[p.Team-1] = append(out[p.Team-1], p.ID)
+		}
+	}
+	for _, ids := range out {
+		sort.Strings(ids)
+	}
+	return out
+}
+
+// bringHomeLocked takes a player who reached an exit out of the round,
+// when every member of a team must get out (TeamGoalAll). The team wins
+// with its last member out.
+func (r *Room) bringHomeLocked(player *PlayerState) {
+	player.home = true
+	r.broadcastLocked(messages.ServerMessage{
+		Type:    "playerHome",
+		Message: player.ID,
+		Players: r.playersLocked(),
+	}, "")
+
+	for _, p := range r.Players {
+		if p.Team == player.Team && !p.home {
+			return
+		}
+	}
+	r.finishLocked(player.ID, "teamGoal")
+}
+
+// teamOfLocked returns the team a player is on, or 0 outside team rooms or
+// if there is no such player
+func (r *Room) teamOfLocked(playerID string) int {
+	if p, ok := r.Players[playerID]; ok && r.teamCount() > 0 {
+		return p.Team
+	}
+	return 0
+}
+
+// topTeamScorerLocked returns the best scorer of the team with the highest
+// combined score, lowest team number winning ties
+func (r *Room) topTeamScorerLocked() string {
+	totals := make([]int, r.teamCount()+1)
+	for _, p := range r.Players {
+		if p.Team > 0 && p.Team < len(totals) {
+			totals[p.Team] += p.Score
+		}
+	}
+	best := 1
+	for t := 2; t < len(totals); t++ {
+		if totals[t] > totals[best] {
+			best = t
+		}
+	}
+
+	var top *PlayerState
+	for _, p := range r.Players {
+		if p.Team == best && (top == nil || p.Score > top.Score || p.Score == top.Score && p.ID < top.ID) {
+			top = p
+		}
+	}
+	if top == nil {
+		return ""
+	}
+	return top.ID
+}
+
+// teamColor is the color of a player's team, if they are on one
+func (p *PlayerState) teamColor() string {
+	if p.Team < 1 || p.Team > MaxTeams {
+		return ""
+	}
+	return TeamColors[p.Team-1]
+}
@@ -0,0 +1,59 @@
+package room
+
+import (
+	"time"
+
+	"labyrinth-duel/websocket/internal/game"
+)
+
+// applyTerrainLocked carries a player on from the cell they just walked
+// onto: ice slides them on the way they were going until they are off the
+// ice, hit a wall or run into someone, then a portal there sends them out
+// of the linked one. Each cell passed through counts as visited.
+func (r *Room) applyTerrainLocked(player *PlayerState, now time.Time) {
+	s := player.lastStep
+	if s == nil {
+		return
+	}
+	dx, dy := s.to.X-s.from.X, s.to.Y-s.from.Y
+
+	// Ice only slides along a floor, never up or down the stairs
+	for slid := 0; slid < r.Maze.Width*r.Maze.Height && (dx != 0 || dy != 0); slid++ {
+		if r.State != StatePlaying || r.Maze.CellAt(player.at()).Terrain != game.TerrainIce {
+			break
+		}
+		to := game.Point{X: player.X + dx, Y: player.Y + dy, Z: player.Z}
+		level, ok := r.Maze.Step(player.at(), player.Level, to)
+		if !ok || !r.cellFreeLocked(player, to, level) {
+			break
+		}
+		player.lastStep = &step{from: player.at(), fromLevel: player.Level, to: to}
+		r.enterCellLocked(player, to, level, now)
+		r.dropBreadcrumbLocked(player, now)
+	}
+
+	if r.State != StatePlaying || r.Maze.CellAt(player.at()).Terrain != game.TerrainPortal {
+		return
+	}
+	exit, ok := r.Maze.PortalExit(player.at())
+	if ok && r.cellFreeLocked(player, exit, game.LevelSurface) {
+		r.enterCellLocked(player, exit, game.LevelSurface, now)
+	}
+}
+
+// cellFreeLocked reports whether the room's collision rules let a player
+// be carried into a cell without walking
+func (r *Room) cellFreeLocked(player *PlayerState, at game.Point, level int) bool {
+	return r.Rules.Collision == CollisionPass || r.playerAtLocked(at, level, player.ID) == nil
+}
+
+// enterCellLocked puts a player on a cell they were carried to, with what
+// arriving there brings
+func (r *Room) enterCellLocked(player *PlayerState, at game.Point, level int, now time.Time) {
+	player.moveTo(at)
+	player.Level = level
+	r.logEventLocked(Event{Type: EventMove, PlayerID: player.ID, X: at.X, Y: at.Y, Z: at.Z, At: now})
+	r.sendRevealLocked(player)
+	r.pickupLocked(player, now)
+	r.ctfStepLocked(player, now)
+}
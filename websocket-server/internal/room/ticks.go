@@ -0,0 +1,98 @@
+package room
+
+import (
+	"time"
+
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+// TickBatcher is implemented by Senders that take the room's messages as
+// one tick message per room tick rather than one by one
+// (messages.CapTickBatch)
+type TickBatcher interface {
+	WantsTicks() bool
+}
+
+// latestOnly are the message types a tick only needs the latest of, keyed
+// by what makes two of them about the same thing: a newer one replaces an
+// older one with the same key
+var latestOnly = map[string]func(messages.ServerMessage) string{
+	"playerMoved":  func(m messages.ServerMessage) string { return m.Message },
+	"timer":        func(messages.ServerMessage) string { return "" },
+	"snapshot":     func(messages.ServerMessage) string { return "" },
+	"scoreUpdate":  func(messages.ServerMessage) string { return "" },
+	"hazardsMoved": func(messages.ServerMessage) string { return "" },
+}
+
+// Capable is implemented by Senders that declared which optional features
+// they support (messages.CapDeltaUpdates, messages.CapFog,
+// messages.CapFloors). Senders that don't implement it are taken to support
+// them all.
+type Capable interface {
+	Supports(capability string) bool
+}
+
+// supports reports whether a client supports an optional feature
+func supports(client Sender, capability string) bool {
+	c, ok := client.(Capable)
+	return !ok || c.Supports(capability)
+}
+
+// wantsTicksLocked reports whether a client takes its messages in ticks
+func (r *Room) wantsTicksLocked(client Sender) bool {
+	batcher, ok := client.(TickBatcher)
+	return ok && batcher.WantsTicks()
+}
+
+// queueTickLocked holds a message back for the client's next tick,
+// unpacking transaction batches so a tick is flat
+func (r *Room) queueTickLocked(id string, msg messages.ServerMessage) {
+	if r.tickOutbox == nil {
+		r.tickOutbox = make(map[string][]messages.ServerMessage)
+	}
+	if msg.Type == "batch" {
+		for _, inner := range msg.Batch {
+			r.tickOutbox[id] = coalesce(r.tickOutbox[id], inner)
+		}
+		return
+	}
+	r.tickOutbox[id] = coalesce(r.tickOutbox[id], msg)
+}
+
+// coalesce appends msg to queue, dropping an older message it supersedes
+func coalesce(queue []messages.ServerMessage, msg messages.ServerMessage) []messages.ServerMessage {
+	if key, ok := latestOnly[msg.Type]; ok {
+		for i, queued := range queue {
+			if queued.Type == msg.Type && key(queued) == key(msg) {
+				queue = append(queue[:i], queue[i+1:]...)
+				break
+			}
+		}
+	}
+	return append(queue, msg)
+}
+
+// flushTicksLocked sends every client its tick: whatever the room sent it
+// since the last one, stamped with the tick number and server time so the
+// client can interpolate between ticks
+func (r *Room) flushTicksLocked(now time.Time) {
+	for id := range r.tickOutbox {
+		r.flushTickLocked(id, now)
+	}
+}
+
+// flushTickLocked sends one client its tick early, as when they leave
+func (r *Room) flushTickLocked(id string, now time.Time) {
+	queue := r.tickOutbox[id]
+	delete(r.tickOutbox, id)
+	client, ok := r.Clients[id]
+	if len(queue) == 0 || !ok {
+		return
+	}
+	r.deliverLocked(id, client, messages.ServerMessage{
+		Type:  "tick",
+		Tick:  r.tickCount,
+		Time:  now.UnixMilli(),
+		Batch: queue,
+	})
+}
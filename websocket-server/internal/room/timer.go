@@ -0,0 +1,97 @@
+package room
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+// DefaultMatchDuration is how long a match runs before time is up
+const DefaultMatchDuration = 3 * time.Minute
+
+// updateTimerLocked broadcasts the remaining round time once per second and
+// ends the round when it runs out
+func (r *Room) updateTimerLocked(now time.Time) {
+	remaining := r.roundStartedAt.Add(r.MatchDuration + r.roundExtension).Sub(now)
+	if remaining <= 0 {
+		winner := r.closestToGoalLocked()
+		switch {
+		case r.Rules.Coop:
+			winner = "" // The maze wins
+		case r.ctfLocked() != nil:
+			winner = r.ctfLeaderLocked()
+		case r.Rules.GoalMode == GoalModePoints:
+			winner = r.topScorerLocked()
+		}
+		r.finishLocked(winner, "timeUp")
+		return
+	}
+
+	seconds := int(math.Ceil(remaining.Seconds()))
+	if r.degraded && seconds > 10 && seconds%DegradedTimerEvery != 0 {
+		return
+	}
+	if seconds != r.lastTimerSecond {
+		r.lastTimerSecond = seconds
+		r.broadcastLocked(messages.ServerMessage{
+			Type:    "timer",
+			Seconds: seconds,
+		}, "")
+	}
+}
+
+// closestToGoalLocked returns the player with the shortest path to an
+// exit, or "" if nobody can reach one
+func (r *Room) closestToGoalLocked() string {
+	ranking := r.rankByGoalDistanceLocked()
+	if len(ranking) == 0 || r.goalDistanceLocked(r.Players[ranking[0]]) < 0 {
+		return ""
+	}
+	return ranking[0]
+}
+
+// rankByGoalDistanceLocked orders players by the length of their shortest
+// path to an exit from where they stand, ties going to the higher score and
+// then the lower ID. Players cut off from every exit come last.
+func (r *Room) rankByGoalDistanceLocked() []string {
+	dist := make(map[string]int, len(r.Players))
+	ids := make([]string, 0, len(r.Players))
+	for id, p := range r.Players {
+		dist[id] = r.goalDistanceLocked(p)
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := r.Players[ids[i]], r.Players[ids[j]]
+		da, db := dist[a.ID], dist[b.ID]
+		switch {
+		case da != db && (da < 0 || db < 0):
+			return db < 0
+		case da != db:
+			return da < db
+		case a.Score != b.Score:
+			return a.Score > b.Score
+		}
+		return a.ID < b.ID
+	})
+	return ids
+}
+
+// goalDistanceLocked returns how many steps the player is from the nearest
+// exit, or -1 if they can't reach one
+func (r *Room) goalDistanceLocked(p *PlayerState) int {
+	return len(r.Maze.PathToGoal(p.at(), p.Level)) - 1
+}
+
+// topScorerLocked returns the player with the highest score
+func (r *Room) topScorerLocked() string {
+	winner, best := "", -1
+	for id, p := range r.Players {
+		if p.Score > best {
+			winner, best = id, p.Score
+		}
+	}
+	return winner
+}
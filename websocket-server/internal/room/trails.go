@@ -0,0 +1,117 @@
+package room
+
+import (
+	"sort"
+	"time"
+
+	"labyrinth-duel/websocket/internal/game"
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+const (
+	// TrailLength is how many of a runner's last cells make up their trail
+	TrailLength = 10
+	// TrailLifetime is how long a breadcrumb lasts before it fades away
+	TrailLifetime = 6 * time.Second
+)
+
+// Pursuit is implemented by game modes where some players hunt the others,
+// such as tag or hunt. In a room with such a mode every runner (anyone not
+// hunting) leaves a trail of breadcrumbs in the cells they walk out of,
+// which only hunters are shown, fading until it expires.
+type Pursuit interface {
+	GameMode
+	// IsHunter reports whether a player is hunting right now. It is called
+	// with the room locked.
+	IsHunter(playerID string) bool
+}
+
+// breadcrumb is a cell a runner left, and when
+type breadcrumb struct {
+	game.Point
+	at time.Time
+}
+
+// pursuitLocked returns the room's mode if it is a Pursuit
+func (r *Room) pursuitLocked() (Pursuit, bool) {
+	p, ok := r.Mode.(Pursuit)
+	return p, ok
+}
+
+// dropBreadcrumbLocked adds the cell a runner just stepped out of to their
+// trail and shows hunters the new trails
+func (r *Room) dropBreadcrumbLocked(player *PlayerState, now time.Time) {
+	pursuit, ok := r.pursuitLocked()
+	if !ok || player.lastStep == nil || pursuit.IsHunter(player.ID) {
+		return
+	}
+	if r.trails == nil {
+		r.trails = make(map[string][]breadcrumb)
+	}
+	trail := append(r.trails[player.ID], breadcrumb{
+		Point: player.lastStep.from,
+		at:    now,
+	})
+	if len(trail) > TrailLength {
+		trail = trail[len(trail)-TrailLength:]
+	}
+	r.trails[player.ID] = trail
+	r.sendTrailsLocked(pursuit, now)
+}
+
+// expireTrailsLocked drops breadcrumbs older than TrailLifetime, and shows
+// hunters what is left if any went
+func (r *Room) expireTrailsLocked(now time.Time) {
+	pursuit, ok := r.pursuitLocked()
+	if !ok {
+		return
+	}
+	expired := false
+	for id, trail := range r.trails {
+		fresh := 0
+		for fresh < len(trail) && now.Sub(trail[fresh].at) >= TrailLifetime {
+			fresh++
+		}
+		if fresh == 0 {
+			continue
+		}
+		expired = true
+		if fresh == len(trail) {
+			delete(r.trails, id)
+		} else {
+			r.trails[id] = trail[fresh:]
+		}
+	}
+	if expired {
+		r.sendTrailsLocked(pursuit, now)
+	}
+}
+
+// sendTrailsLocked sends every runner's trail to the hunters only, oldest
+// breadcrumb first with how far it has faded
+func (r *Room) sendTrailsLocked(pursuit Pursuit, now time.Time) {
+	ids := make([]string, 0, len(r.trails))
+	for id := range r.trails {
+		if !pursuit.IsHunter(id) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	var crumbs []messages.Breadcrumb
+	for _, id := range ids {
+		for _, b := range r.trails[id] {
+			crumbs = append(crumbs, messages.Breadcrumb{
+				PlayerID: id,
+				X:        b.X,
+				Y:        b.Y,
+				Z:        b.Z,
+				Fade:     float64(now.Sub(b.at)) / float64(TrailLifetime),
+			})
+		}
+	}
+	r.broadcastToLocked(messages.ServerMessage{
+		Type:        "breadcrumbs",
+		Breadcrumbs: crumbs,
+	}, pursuit.IsHunter)
+}
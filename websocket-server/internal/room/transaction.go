@@ -0,0 +1,263 @@
+package room
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"labyrinth-duel/websocket/internal/game"
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+// Errors returned by transaction operations
+var (
+	ErrNoPlayer   = errors.New("player is not in the room")
+	ErrNoItem     = errors.New("player does not hold that item")
+	ErrNoEffect   = errors.New("item had no effect")
+	ErrNotPlaying = errors.New("match is not in progress")
+	ErrBadItem    = errors.New("unknown item kind")
+	ErrCellTaken  = errors.New("cell already holds an item")
+)
+
+// Tx applies a batch of room changes atomically. It is only valid inside
+// the function passed to Room.Transact.
+type Tx struct {
+	r *Room
+}
+
+// Transact runs fn with the room locked. Every message the changes produce
+// is held back and delivered to each client as one combined "batch"
+// message once fn succeeds. If fn returns an error, all changes are rolled
+// back and nothing is sent.
+func (r *Room) Transact(fn func(tx *Tx) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	snap := r.snapshotLocked()
+	r.outbox = make(map[string][]messages.ServerMessage)
+
+	if err := fn(&Tx{r: r}); err != nil {
+		r.restoreLocked(snap)
+		r.outbox = nil
+		r.watchOutbox = nil
+		return err
+	}
+
+	r.flushMazeUpdatesLocked()
+	r.debugCheckLocked("transaction")
+	outbox := r.outbox
+	r.outbox = nil
+	for id, msgs := range outbox {
+		if len(msgs) == 1 {
+			r.sendLocked(id, msgs[0])
+			continue
+		}
+		r.sendLocked(id, messages.ServerMessage{Type: "batch", Batch: msgs})
+	}
+	r.flushWatchersLocked()
+	return nil
+}
+
+// Player returns a player's state for direct reads and edits
+func (tx *Tx) Player(playerID string) (*PlayerState, error) {
+	p, ok := tx.r.Players[playerID]
+	if !ok {
+		return nil, ErrNoPlayer
+	}
+	return p, nil
+}
+
+// Teleport moves a player to any cell, revealing it and picking up items
+func (tx *Tx) Teleport(playerID string, to game.Point) error {
+	p, err := tx.Player(playerID)
+	if err != nil {
+		return err
+	}
+	if !tx.r.Maze.Contains(to) {
+		return ErrBadCell
+	}
+
+	p.moveTo(to)
+	p.Level = game.LevelSurface
+	tx.r.logEventLocked(Event{Type: EventMove, PlayerID: playerID, X: to.X, Y: to.Y, Z: to.Z})
+	tx.r.sendRevealLocked(p)
+	tx.r.pickupLocked(p, tx.r.clock.Now())
+	return nil
+}
+
+// Reveal shows a player the whole maze. Fails with ErrNoEffect if there
+// was nothing left to see (always the case without fog).
+func (tx *Tx) Reveal(playerID string) error {
+	p, err := tx.Player(playerID)
+	if err != nil {
+		return err
+	}
+	if !tx.r.Rules.Fog {
+		return ErrNoEffect
+	}
+	if p.revealed == nil {
+		p.revealed = make(map[game.Point]bool)
+	}
+
+	var fresh []messages.Cell
+	for _, at := range tx.r.Maze.Points() {
+		if p.revealed[at] {
+			continue
+		}
+		p.revealed[at] = true
+		fresh = append(fresh, cellToMessage(*tx.r.Maze.CellAt(at)))
+	}
+	if len(fresh) == 0 {
+		return ErrNoEffect
+	}
+	tx.r.sendLocked(playerID, messages.ServerMessage{Type: "mazeReveal", Cells: fresh})
+	return nil
+}
+
+// SpawnItem drops a power-up on a free cell and announces it
+func (tx *Tx) SpawnItem(kind string, at game.Point) error {
+	if !slices.Contains(PowerUps, kind) {
+		return ErrBadItem
+	}
+	if !tx.r.Maze.Contains(at) {
+		return ErrBadCell
+	}
+	if tx.r.Items[at] != nil {
+		return ErrCellTaken
+	}
+
+	tx.r.itemSeq++
+	item := &Item{ID: fmt.Sprintf("item-%d", tx.r.itemSeq), Kind: kind, X: at.X, Y: at.Y, Z: at.Z}
+	tx.r.placeItemLocked(item)
+	tx.r.broadcastLocked(messages.ServerMessage{
+		Type:  "itemSpawned",
+		Items: []messages.Item{item.toMessage()},
+	}, "")
+	return nil
+}
+
+// ConsumeItem removes one power-up of the given kind from a player
+func (tx *Tx) ConsumeItem(playerID, kind string) error {
+	p, err := tx.Player(playerID)
+	if err != nil {
+		return err
+	}
+	for i, held := range p.Inventory {
+		if held == kind {
+			p.Inventory = append(p.Inventory[:i], p.Inventory[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNoItem
+}
+
+// GiveItem adds a power-up to a player's inventory
+func (tx *Tx) GiveItem(playerID, kind string) error {
+	p, err := tx.Player(playerID)
+	if err != nil {
+		return err
+	}
+	p.Inventory = append(p.Inventory, kind)
+	return nil
+}
+
+// AwardPoints scores points for a player (streak multiplier applies)
+func (tx *Tx) AwardPoints(playerID string, points int) error {
+	if _, err := tx.Player(playerID); err != nil {
+		return err
+	}
+	tx.r.awardPointsLocked(playerID, points, tx.r.clock.Now())
+	return nil
+}
+
+// RemoveWall knocks down a wall (see Room.RemoveWall)
+func (tx *Tx) RemoveWall(at game.Point, direction string) error {
+	return tx.r.removeWallLocked(at, direction)
+}
+
+// AddWall builds a wall (see Room.AddWall)
+func (tx *Tx) AddWall(at game.Point, direction string) error {
+	return tx.r.addWallLocked(at, direction)
+}
+
+// SetTerrain changes a cell's ground type (see Room.SetTerrain)
+func (tx *Tx) SetTerrain(at game.Point, terrain game.Terrain) error {
+	return tx.r.setTerrainLocked(at, terrain)
+}
+
+// Broadcast queues a message for every client except excludeID
+func (tx *Tx) Broadcast(msg messages.ServerMessage, excludeID string) {
+	tx.r.broadcastLocked(msg, excludeID)
+}
+
+// snapshot is a deep copy of the mutable room state, used for rollback
+type snapshot struct {
+	players      map[string]PlayerState
+	items        map[game.Point]Item
+	floors       [][][]game.Cell
+	state        State
+	events       int
+	pendingCells int
+}
+
+func (r *Room) snapshotLocked() snapshot {
+	s := snapshot{
+		players:      make(map[string]PlayerState, len(r.Players)),
+		items:        make(map[game.Point]Item, len(r.Items)),
+		floors:       make([][][]game.Cell, r.Maze.FloorCount()),
+		state:        r.State,
+		events:       len(r.events),
+		pendingCells: len(r.pendingCells),
+	}
+
+	for id, p := range r.Players {
+		cp := *p
+		cp.Inventory = append([]string(nil), p.Inventory...)
+		cp.speedMods = append([]speedModifier(nil), p.speedMods...)
+		cp.revealed = copyPointSet(p.revealed)
+		cp.claimed = copyPointSet(p.claimed)
+		s.players[id] = cp
+	}
+	for cell, item := range r.Items {
+		s.items[cell] = *item
+	}
+	for z := range s.floors {
+		for _, row := range r.Maze.Floor(z) {
+			s.floors[z] = append(s.floors[z], append([]game.Cell(nil), row...))
+		}
+	}
+	return s
+}
+
+func (r *Room) restoreLocked(s snapshot) {
+	for id, p := range s.players {
+		if live, ok := r.Players[id]; ok {
+			*live = p
+		}
+	}
+
+	r.Items = make(map[game.Point]*Item, len(s.items))
+	for cell, item := range s.items {
+		item := item
+		r.Items[cell] = &item
+	}
+
+	r.Maze.Cells, r.Maze.Upper = s.floors[0], nil
+	if len(s.floors) > 1 {
+		r.Maze.Upper = s.floors[1:]
+	}
+	r.State = s.state
+	r.events = r.events[:s.events]
+	r.pendingCells = r.pendingCells[:s.pendingCells]
+}
+
+func copyPointSet(set map[game.Point]bool) map[game.Point]bool {
+	if set == nil {
+		return nil
+	}
+	cp := make(map[game.Point]bool, len(set))
+	for k, v := range set {
+		cp[k] = v
+	}
+	return cp
+}
@@ -0,0 +1,248 @@
+package room
+
+import (
+	"fmt"
+	"time"
+
+	"labyrinth-duel/websocket/internal/game"
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+// Tutorial stages, in the order a player goes through them
+const (
+	TutorialMove  = "move"
+	TutorialSmash = "smash"
+	TutorialItem  = "item"
+	TutorialWin   = "win"
+)
+
+const (
+	// TutorialMoves is how many steps the move stage asks for
+	TutorialMoves = 3
+	// TutorialHintDelay is how long a player is left on a stage before
+	// they are given a hint, and again between hints
+	TutorialHintDelay = 15 * time.Second
+	// TutorialItemKind is the power-up the item stage puts down
+	TutorialItemKind = ItemSpeedBoost
+	// TutorialMatchDuration is long enough that a new player needn't hurry
+	TutorialMatchDuration = 30 * time.Minute
+)
+
+// tutorialStage is one objective of the tutorial
+type tutorialStage struct {
+	name      string
+	objective string
+	hint      string
+	// enter sets the stage up, returning where to point the player, if
+	// anywhere
+	enter func(r *Room, player *PlayerState) *game.Point
+	// counts reports whether an event by the player counts towards the
+	// stage; need is how many must
+	counts func(ev Event) bool
+	need   int
+}
+
+var tutorialStages = []tutorialStage{
+	{
+		name:      TutorialMove,
+		objective: fmt.Sprintf("Take %d steps: each move goes one cell, unless a wall is in the way", TutorialMoves),
+		hint:      "Use the arrow keys or WASD to step to a neighbouring cell",
+		counts:    func(ev Event) bool { return ev.Type == EventMove },
+		need:      TutorialMoves,
+	},
+	{
+		name:      TutorialSmash,
+		objective: "Smash through a wall next to you",
+		hint:      "Every match gives you a wall charge: pick a side with a wall and break it",
+		enter: func(r *Room, player *PlayerState) *game.Point {
+			if player.WallCharges < 1 {
+				player.WallCharges = 1
+			}
+			return nil
+		},
+		counts: func(ev Event) bool { return ev.Type == EventWallBroken },
+		need:   1,
+	},
+	{
+		name:      TutorialItem,
+		objective: "Pick up the power-up nearby, then use it",
+		hint:      "Walk onto the power-up to pick it up, then use it from your inventory",
+		enter:     (*Room).placeTutorialItemLocked,
+		counts:    func(ev Event) bool { return ev.Type == EventItemUsed },
+		need:      1,
+	},
+	{
+		name:      TutorialWin,
+		objective: "Now race to the exit to win",
+		hint:      "The exit is the marked cell: find a way to it",
+		counts:    func(ev Event) bool { return ev.Type == EventFinish },
+		need:      1,
+	},
+}
+
+// tutorial is a tutorial room's state machine: it walks its one player
+// through the stages by watching the event log, sending each objective as
+// a tutorialStep and a tutorialHint whenever they are stuck
+type tutorial struct {
+	playerID string
+	stage    int // Index into tutorialStages
+	progress int // Events counted towards the stage so far
+	seen     int // Events in the log already looked at
+	pointAt  *game.Point
+	hintAt   time.Time
+	started  bool
+	done     bool
+}
+
+// updateTutorialLocked starts the tutorial match as soon as its player is
+// in, then moves them through the stages as the events come in
+func (r *Room) updateTutorialLocked(now time.Time) {
+	t := r.tutorial
+	if t == nil || t.done {
+		return
+	}
+
+	if r.State == StateWaiting {
+		for id, p := range r.Players {
+			if !p.Bot {
+				t.playerID = id
+				p.Ready = true
+				r.startWhenReadyLocked(now)
+				break
+			}
+		}
+		return
+	}
+	if _, ok := r.Players[t.playerID]; !ok {
+		return
+	}
+	if !t.started {
+		if r.State != StatePlaying {
+			return
+		}
+		t.started = true
+		t.seen = len(r.events)
+		r.enterTutorialStageLocked(now)
+	}
+
+	if t.seen > len(r.events) { // A transaction rolled events back
+		t.seen = len(r.events)
+	}
+	for _, ev := range r.events[t.seen:] {
+		stage := tutorialStages[t.stage]
+		if ev.PlayerID != t.playerID || !stage.counts(ev) {
+			continue
+		}
+		if t.progress++; t.progress < stage.need {
+			continue
+		}
+		if t.stage == len(tutorialStages)-1 {
+			r.completeTutorialLocked()
+			return
+		}
+		t.stage++
+		r.enterTutorialStageLocked(now)
+	}
+	t.seen = len(r.events)
+
+	if now.After(t.hintAt) {
+		r.sendTutorialLocked("tutorialHint", tutorialStages[t.stage].hint)
+		t.hintAt = now.Add(TutorialHintDelay)
+	}
+}
+
+// enterTutorialStageLocked sets up the current stage and tells the player
+// what to do
+func (r *Room) enterTutorialStageLocked(now time.Time) {
+	t := r.tutorial
+	stage := tutorialStages[t.stage]
+	t.progress = 0
+	t.pointAt = nil
+	t.hintAt = now.Add(TutorialHintDelay)
+	if stage.enter != nil {
+		t.pointAt = stage.enter(r, r.Players[t.playerID])
+	}
+	r.sendTutorialLocked("tutorialStep", stage.objective)
+}
+
+// sendTutorialLocked sends the player a tutorial message about the current
+// stage
+func (r *Room) sendTutorialLocked(msgType, text string) {
+	t := r.tutorial
+	msg := messages.ServerMessage{
+		Type:    msgType,
+		Reason:  tutorialStages[t.stage].name,
+		Message: text,
+		Round:   t.stage + 1,
+	}
+	if t.pointAt != nil {
+		pos := positionOf(*t.pointAt)
+		msg.Position = &pos
+	}
+	r.sendLocked(t.playerID, msg)
+}
+
+// completeTutorialLocked congratulates the player and has their profile
+// marked
+func (r *Room) completeTutorialLocked() {
+	t := r.tutorial
+	t.done = true
+	r.sendLocked(t.playerID, messages.ServerMessage{
+		Type:    "tutorialComplete",
+		Message: "You're ready to race for real",
+	})
+	if r.onTutorialDone != nil {
+		r.onTutorialDone(t.playerID)
+	}
+}
+
+// tutorialHoldsGoalLocked reports whether a tutorial player has reached the
+// exit before the last stage, which doesn't count yet
+func (r *Room) tutorialHoldsGoalLocked(player *PlayerState) bool {
+	t := r.tutorial
+	if t == nil || t.done || player.ID != t.playerID || tutorialStages[t.stage].name == TutorialWin {
+		return false
+	}
+	r.sendTutorialLocked("tutorialHint", "Not so fast: "+tutorialStages[t.stage].objective)
+	return true
+}
+
+// placeTutorialItemLocked puts the item stage's power-up a few steps from
+// the player
+func (r *Room) placeTutorialItemLocked(player *PlayerState) *game.Point {
+	dist := r.Maze.DistanceMap(player.at())
+	var best *game.Point
+	bestDist := 0
+	for _, cell := range r.Maze.Points() {
+		d := dist.At(cell)
+		if d <= 0 || d > 3 || r.Items[cell] != nil || r.isGoalLocked(cell) {
+			continue
+		}
+		if d > bestDist {
+			best, bestDist = &cell, d
+		}
+	}
+	if best == nil {
+		return nil
+	}
+
+	r.itemSeq++
+	item := &Item{
+		ID:   fmt.Sprintf("item-%d", r.itemSeq),
+		Kind: TutorialItemKind,
+		X:    best.X,
+		Y:    best.Y,
+		Z:    best.Z,
+	}
+	r.placeItemLocked(item)
+	r.broadcastLocked(messages.ServerMessage{
+		Type:  "itemSpawned",
+		Items: []messages.Item{item.toMessage()},
+	}, "")
+	return best
+}
+
+func (r *Room) isGoalLocked(cell game.Point) bool {
+	_, ok := r.Maze.GoalAt(cell)
+	return ok
+}
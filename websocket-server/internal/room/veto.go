@@ -0,0 +1,183 @@
+package room
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+
+	"labyrinth-duel/websocket/internal/game"
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+const (
+	// VetoCandidates is how many mazes are put on the table
+	VetoCandidates = 5
+	// VetoTurnTimeout is how long a player has to strike a maze before one
+	// is struck for them at random
+	VetoTurnTimeout = 15 * time.Second
+)
+
+// Errors returned by Veto
+var (
+	ErrNoVeto       = errors.New("no map veto is running")
+	ErrNotYourTurn  = errors.New("it's not your turn to veto")
+	ErrBadCandidate = errors.New("that maze isn't one of the remaining candidates")
+)
+
+// mapVeto is a room's pre-match map veto: players take turns striking
+// candidate mazes until one is left, which the match is played on
+type mapVeto struct {
+	candidates []*game.Maze
+	vetoedBy   []string // Who struck each candidate, "" while it's still in
+	order      []string // Players in turn order
+	turn       int      // Strikes made so far; order[turn%len(order)] is up
+	turnEndsAt time.Time
+	chosen     *game.Maze // Set once one candidate is left
+}
+
+// remaining returns the indexes of candidates nobody has struck
+func (v *mapVeto) remaining() []int {
+	var left []int
+	for i, by := range v.vetoedBy {
+		if by == "" {
+			left = append(left, i)
+		}
+	}
+	return left
+}
+
+// current returns the player whose turn it is
+func (v *mapVeto) current() string {
+	return v.order[v.turn%len(v.order)]
+}
+
+// startWhenReadyLocked starts the countdown once everyone is ready. Rooms
+// with a map veto run it first and start when it has picked the maze.
+func (r *Room) startWhenReadyLocked(now time.Time) {
+	if !r.allReadyLocked() {
+		return
+	}
+	if r.Rules.MapVeto && (r.veto == nil || r.veto.chosen == nil) {
+		if r.veto == nil {
+			r.startVetoLocked(now)
+		}
+		return
+	}
+	r.startCountdownLocked()
+}
+
+// startVetoLocked generates the candidate mazes and opens the veto in a
+// random turn order
+func (r *Room) startVetoLocked(now time.Time) {
+	algorithms := game.Algorithms()
+	v := &mapVeto{
+		candidates: make([]*game.Maze, VetoCandidates),
+		vetoedBy:   make([]string, VetoCandidates),
+		turnEndsAt: now.Add(VetoTurnTimeout),
+	}
+	for i := range v.candidates {
+		opts := r.mazeOpts
+		opts.Seed = 0
+		opts.Algorithm = algorithms[i%len(algorithms)]
+		v.candidates[i] = game.Generate(r.Maze.Width, r.Maze.Height, opts)
+	}
+	for id := range r.Players {
+		v.order = append(v.order, id)
+	}
+	sort.Strings(v.order)
+	rand.Shuffle(len(v.order), func(i, j int) { v.order[i], v.order[j] = v.order[j], v.order[i] })
+
+	r.veto = v
+	r.broadcastVetoLocked("vetoStarted", now)
+}
+
+// Veto strikes the candidate maze with the given seed on the player's turn
+func (r *Room) Veto(playerID string, seed int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	v := r.veto
+	if v == nil || v.chosen != nil {
+		return ErrNoVeto
+	}
+	if v.current() != playerID {
+		return ErrNotYourTurn
+	}
+	for _, i := range v.remaining() {
+		if v.candidates[i].Seed == seed {
+			r.strikeLocked(i, r.clock.Now())
+			return nil
+		}
+	}
+	return ErrBadCandidate
+}
+
+// updateVetoLocked strikes a random candidate for bots, and for players
+// whose turn has run out
+func (r *Room) updateVetoLocked(now time.Time) {
+	v := r.veto
+	if v == nil || v.chosen != nil {
+		return
+	}
+	player := r.Players[v.current()]
+	if player != nil && !player.Bot && now.Before(v.turnEndsAt) {
+		return
+	}
+	left := v.remaining()
+	r.strikeLocked(left[rand.Intn(len(left))], now)
+}
+
+// strikeLocked vetoes a candidate for the current player and passes the
+// turn on. When one candidate is left it becomes the room's maze.
+func (r *Room) strikeLocked(i int, now time.Time) {
+	v := r.veto
+	v.vetoedBy[i] = v.current()
+	v.turn++
+	v.turnEndsAt = now.Add(VetoTurnTimeout)
+
+	left := v.remaining()
+	if len(left) > 1 {
+		r.broadcastVetoLocked("vetoUpdated", now)
+		return
+	}
+
+	v.chosen = v.candidates[left[0]]
+	r.broadcastVetoLocked("vetoDecided", now)
+	r.replaceMazeLocked(v.chosen)
+	r.startWhenReadyLocked(now)
+}
+
+// cancelVetoLocked abandons an undecided veto, e.g. when a player leaves;
+// it starts over once everyone is ready again
+func (r *Room) cancelVetoLocked() {
+	if r.veto == nil || r.veto.chosen != nil {
+		return
+	}
+	r.veto = nil
+	r.broadcastLocked(messages.ServerMessage{Type: "vetoCancelled"}, "")
+}
+
+// broadcastVetoLocked sends everyone the veto's standing
+func (r *Room) broadcastVetoLocked(msgType string, now time.Time) {
+	v := r.veto
+	status := &messages.VetoStatus{
+		Candidates: make([]messages.MapCandidate, len(v.candidates)),
+	}
+	for i, m := range v.candidates {
+		status.Candidates[i] = messages.MapCandidate{
+			Seed:      m.Seed,
+			Algorithm: m.Algorithm,
+			Theme:     m.Theme,
+			VetoedBy:  v.vetoedBy[i],
+		}
+	}
+	if v.chosen != nil {
+		status.Chosen = v.chosen.Seed
+	} else {
+		status.Turn = v.current()
+		status.Seconds = int(math.Ceil(v.turnEndsAt.Sub(now).Seconds()))
+	}
+	r.broadcastLocked(messages.ServerMessage{Type: msgType, Veto: status}, "")
+}
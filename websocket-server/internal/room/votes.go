@@ -0,0 +1,380 @@
+package room
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"labyrinth-duel/websocket/internal/game"
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+const (
+	// VoteWindow is how long a vote stays open
+	VoteWindow = 30 * time.Second
+	// VoteExtension is how much time a passed extendTime vote adds to the round
+	VoteExtension = time.Minute
+	// MinVoteMazeSize and MaxVoteMazeSize bound the size a mazeSize vote
+	// may ask for
+	MinVoteMazeSize = 5
+	MaxVoteMazeSize = 40
+)
+
+// Built-in vote kinds
+const (
+	VoteKick       = "kick"       // Remove the target player and keep them out
+	VoteNewMaze    = "newMaze"    // Swap in a freshly generated maze
+	VoteExtendTime = "extendTime" // Add VoteExtension to the current round
+	VoteSuspend    = "suspend"    // Save a casual match to be resumed later by code
+	VoteRestart    = "restart"    // Start the match over from the first round
+	VoteMazeSize   = "mazeSize"   // Swap in a fresh maze of another size
+	VotePause      = "pause"      // Freeze the match, see Pause
+	VoteResume     = "resume"     // Unfreeze a paused match
+)
+
+// Vote results
+const (
+	VotePassed    = "passed"
+	VoteFailed    = "failed"
+	VoteCancelled = "cancelled" // The target left before the vote ended
+)
+
+// Errors returned by StartVote and CastVote
+var (
+	ErrUnknownVote    = errors.New("unknown vote kind")
+	ErrVoteInProgress = errors.New("another vote is already running")
+	ErrNoVote         = errors.New("no vote is running")
+	ErrVoteNotNow     = errors.New("that vote can't be called right now")
+	ErrBadVoteTarget  = errors.New("that vote needs another player in the room as its target")
+	ErrBadVoteSize    = fmt.Errorf("maze size must be between %d and %d", MinVoteMazeSize, MaxVoteMazeSize)
+	ErrKicked         = errors.New("voted out of this room")
+)
+
+// VoteKind is a decision players can put to a vote
+type VoteKind struct {
+	Name string
+	// Quorum is the share of eligible voters that must say yes: the vote
+	// passes once yes votes are more than Quorum of them
+	Quorum float64
+	// Unanimous votes need every eligible voter to say yes, whatever the
+	// Quorum
+	Unanimous bool
+	// NeedsTarget votes are about another player, who doesn't get a say
+	NeedsTarget bool
+	// NeedsSize votes carry a maze size from MinVoteMazeSize to
+	// MaxVoteMazeSize
+	NeedsSize bool
+	// States the vote may be called in
+	States []State
+	// Allow, if set, is a further check the room must pass for the vote to
+	// be called, made with the room locked
+	Allow func(r *Room) bool
+	// Apply carries out a passed vote, with the room locked
+	Apply func(r *Room, v *Vote)
+}
+
+var voteKinds = map[string]VoteKind{}
+
+// RegisterVoteKind makes a kind of vote available to every room
+func RegisterVoteKind(k VoteKind) {
+	voteKinds[k.Name] = k
+}
+
+// VoteKinds returns the registered vote kind names, sorted
+func VoteKinds() []string {
+	names := make([]string, 0, len(voteKinds))
+	for name := range voteKinds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	RegisterVoteKind(VoteKind{
+		Name:        VoteKick,
+		Quorum:      0.6,
+		NeedsTarget: true,
+		States:      []State{StateWaiting, StateCountdown, StatePlaying, StatePaused, StateFinished},
+		Apply: func(r *Room, v *Vote) {
+			r.kickLocked(v.Target, messages.ServerMessage{Type: "voteKicked", RoomID: r.ID})
+		},
+	})
+	RegisterVoteKind(VoteKind{
+		Name:   VoteNewMaze,
+		Quorum: 0.5,
+		States: []State{StateWaiting, StatePlaying},
+		Apply:  func(r *Room, v *Vote) { r.newMazeLocked() },
+	})
+	RegisterVoteKind(VoteKind{
+		Name:   VoteExtendTime,
+		Quorum: 0.5,
+		States: []State{StatePlaying},
+		Apply:  func(r *Room, v *Vote) { r.roundExtension += VoteExtension },
+	})
+	RegisterVoteKind(VoteKind{
+		Name:      VoteSuspend,
+		Unanimous: true,
+		States:    []State{StatePlaying},
+		Allow:     (*Room).suspendableLocked,
+		Apply:     func(r *Room, v *Vote) { r.suspendLocked() },
+	})
+	RegisterVoteKind(VoteKind{
+		Name:   VoteRestart,
+		Quorum: 0.5,
+		States: []State{StateCountdown, StatePlaying, StateFinished},
+		// Ranked results stand, and a demo is the bots' to finish
+		Allow: func(r *Room) bool { return !r.Rules.Ranked && !r.demo },
+		Apply: func(r *Room, v *Vote) { r.restartMatchLocked() },
+	})
+	RegisterVoteKind(VoteKind{
+		Name:      VoteMazeSize,
+		Quorum:    0.5,
+		NeedsSize: true,
+		States:    []State{StateWaiting, StatePlaying},
+		Apply:     func(r *Room, v *Vote) { r.resizeMazeLocked(v.Size, v.Size) },
+	})
+	RegisterVoteKind(VoteKind{
+		Name:   VotePause,
+		Quorum: 0.5,
+		States: []State{StatePlaying},
+		Allow:  func(r *Room) bool { return r.checkPausableLocked() == nil },
+		Apply: func(r *Room, v *Vote) {
+			// The match may have ended while the room voted
+			if r.checkPausableLocked() == nil {
+				r.pauseLocked(v.StartedBy, r.clock.Now())
+			}
+		},
+	})
+	RegisterVoteKind(VoteKind{
+		Name:   VoteResume,
+		Quorum: 0.5,
+		States: []State{StatePaused},
+		Apply: func(r *Room, v *Vote) {
+			if r.State == StatePaused {
+				r.startResumeLocked(ResumedByVote, r.clock.Now())
+			}
+		},
+	})
+}
+
+// Vote is a running vote
+type Vote struct {
+	ID        int
+	Kind      string
+	Target    string // Player the vote is about, for kinds that need one
+	Size      int    // Maze width and height, for kinds that need one
+	StartedBy string
+	EndsAt    time.Time
+
+	ballots map[string]bool // Yes or no, by voter
+}
+
+// StartVote opens a vote of a registered kind, counting the caller as a
+// yes. Only one vote runs at a time. target and size are only used by kinds
+// that need them.
+func (r *Room) StartVote(playerID, kind, target string, size int) error {
+	k, ok := voteKinds[kind]
+	if !ok {
+		return ErrUnknownVote
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.Players[playerID]; !exists {
+		return ErrNoPlayer
+	}
+	if r.vote != nil {
+		return ErrVoteInProgress
+	}
+	if !stateIn(r.State, k.States) || (k.Allow != nil && !k.Allow(r)) {
+		return ErrVoteNotNow
+	}
+	if k.NeedsTarget {
+		if _, exists := r.Players[target]; !exists || target == playerID {
+			return ErrBadVoteTarget
+		}
+	} else {
+		target = ""
+	}
+	if !k.NeedsSize {
+		size = 0
+	} else if size < MinVoteMazeSize || size > MaxVoteMazeSize {
+		return ErrBadVoteSize
+	}
+
+	r.voteSeq++
+	r.vote = &Vote{
+		ID:        r.voteSeq,
+		Kind:      kind,
+		Target:    target,
+		Size:      size,
+		StartedBy: playerID,
+		EndsAt:    r.clock.Now().Add(VoteWindow),
+		ballots:   map[string]bool{playerID: true},
+	}
+	r.broadcastVoteLocked("voteStarted", "", r.clock.Now())
+	r.updateVoteLocked(r.clock.Now())
+	return nil
+}
+
+// CastVote records a player's yes or no on the running vote. Voting again
+// changes the earlier ballot.
+func (r *Room) CastVote(playerID string, yes bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.vote == nil {
+		return ErrNoVote
+	}
+	if !r.eligibleLocked(playerID) {
+		return ErrVoteNotNow
+	}
+	r.vote.ballots[playerID] = yes
+	if !r.updateVoteLocked(r.clock.Now()) {
+		r.broadcastVoteLocked("voteUpdated", "", r.clock.Now())
+	}
+	return nil
+}
+
+// eligibleLocked reports whether a player gets a say in the running vote.
+// Bots don't vote, and neither does the player a vote is about.
+func (r *Room) eligibleLocked(playerID string) bool {
+	p, exists := r.Players[playerID]
+	return exists && !p.Bot && playerID != r.vote.Target
+}
+
+// tallyLocked counts the running vote's ballots from players still eligible
+func (r *Room) tallyLocked() (yes, no, eligible, needed int) {
+	for id, p := range r.Players {
+		if p.Bot || id == r.vote.Target {
+			continue
+		}
+		eligible++
+		if ballot, voted := r.vote.ballots[id]; voted {
+			if ballot {
+				yes++
+			} else {
+				no++
+			}
+		}
+	}
+	k := voteKinds[r.vote.Kind]
+	needed = int(math.Floor(k.Quorum*float64(eligible))) + 1
+	if k.Unanimous {
+		needed = eligible
+	}
+	return yes, no, eligible, needed
+}
+
+// updateVoteLocked ends the running vote once its outcome is settled or its
+// window has closed, carrying it out if it passed. Reports whether it ended.
+func (r *Room) updateVoteLocked(now time.Time) bool {
+	v := r.vote
+	if v == nil {
+		return false
+	}
+
+	yes, no, eligible, needed := r.tallyLocked()
+	var result string
+	switch {
+	case v.Target != "" && r.Players[v.Target] == nil:
+		result = VoteCancelled
+	case yes >= needed:
+		result = VotePassed
+	case yes+(eligible-yes-no) < needed || !now.Before(v.EndsAt):
+		result = VoteFailed
+	default:
+		return false
+	}
+
+	r.broadcastVoteLocked("voteEnded", result, now)
+	r.vote = nil
+	if result == VotePassed {
+		voteKinds[v.Kind].Apply(r, v)
+	}
+	return true
+}
+
+// broadcastVoteLocked sends everyone the running vote's standing
+func (r *Room) broadcastVoteLocked(msgType, result string, now time.Time) {
+	v := r.vote
+	yes, no, eligible, needed := r.tallyLocked()
+	seconds := int(math.Ceil(v.EndsAt.Sub(now).Seconds()))
+	if seconds < 0 || result != "" {
+		seconds = 0
+	}
+	r.broadcastLocked(messages.ServerMessage{
+		Type: msgType,
+		Vote: &messages.VoteStatus{
+			ID:        v.ID,
+			Kind:      v.Kind,
+			Target:    v.Target,
+			Size:      v.Size,
+			StartedBy: v.StartedBy,
+			Yes:       yes,
+			No:        no,
+			Eligible:  eligible,
+			Needed:    needed,
+			Seconds:   seconds,
+			Result:    result,
+		},
+	}, "")
+}
+
+// kickLocked removes a player, telling them why with notice, and keeps
+// them from rejoining
+func (r *Room) kickLocked(playerID string, notice messages.ServerMessage) {
+	if r.kicked == nil {
+		r.kicked = make(map[string]bool)
+	}
+	r.kicked[playerID] = true
+	r.removeWithNoticeLocked(playerID, notice)
+}
+
+// newMazeLocked swaps in a maze on a fresh seed. Mid-match it restarts the
+// round on the new maze with a countdown; in the lobby it just replaces it.
+func (r *Room) newMazeLocked() {
+	r.resizeMazeLocked(r.Maze.Width, r.Maze.Height)
+}
+
+// resizeMazeLocked swaps in a maze of the given size on a fresh seed, like
+// newMazeLocked. Later rounds keep the size, and a room playing a level
+// goes back to generated mazes.
+func (r *Room) resizeMazeLocked(width, height int) {
+	r.level = nil
+	opts := r.mazeOpts
+	opts.Seed = 0
+	r.replaceMazeLocked(game.Generate(width, height, opts))
+	if r.State == StatePlaying {
+		r.startCountdownLocked()
+	}
+}
+
+// restartMatchLocked wipes the scores and round wins of the match under way
+// or just finished, and counts down into its first round again on a fresh
+// maze
+func (r *Room) restartMatchLocked() {
+	r.round = 0
+	r.LastMatch = nil
+	for _, p := range r.Players {
+		p.Score = 0
+		p.RoundWins = 0
+	}
+	opts := r.mazeOpts
+	opts.Seed = 0
+	r.replaceMazeLocked(r.generateLocked(r.Maze.Width, r.Maze.Height, opts))
+	r.startCountdownLocked()
+}
+
+func stateIn(s State, states []State) bool {
+	for _, st := range states {
+		if st == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package room
+
+// DefaultWallCharges is how many walls each player may break per match
+const DefaultWallCharges = 1
+
+func (r *Room) wallCharges() int {
+	if r.Rules.WallCharges > 0 {
+		return r.Rules.WallCharges
+	}
+	return DefaultWallCharges
+}
+
+// BreakWall spends one of the player's wall charges to smash the wall on
+// the given side of their cell. Returns false if they have no charges left
+// or there is no breakable wall there.
+func (r *Room) BreakWall(playerID, direction string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	player, exists := r.Players[playerID]
+	if !exists || r.State != StatePlaying || player.WallCharges <= 0 {
+		return false
+	}
+
+	if !r.breakWallLocked(player, direction) {
+		return false
+	}
+	player.WallCharges--
+	return true
+}
+
+// breakWallLocked smashes the wall on the given side of the player's cell
+// and tells everyone which cells changed
+func (r *Room) breakWallLocked(player *PlayerState, direction string) bool {
+	if err := r.removeWallLocked(player.at(), direction); err != nil {
+		return false
+	}
+
+	r.logEventLocked(Event{Type: EventWallBroken, PlayerID: player.ID, X: player.X, Y: player.Y, Z: player.Z, Detail: direction})
+	r.flushMazeUpdatesLocked()
+	return true
+}
@@ -0,0 +1,203 @@
+package schema
+
+// MessageType documents one value of a message's type field
+type MessageType struct {
+	Name    string
+	Summary string
+	// Fields the message uses besides type, requestId and seq (client
+	// messages only; server messages may carry any ServerMessage field)
+	Fields   []string
+	Required []string
+}
+
+// EnvelopeFields may appear on every client message
+var EnvelopeFields = []string{"type", "requestId", "seq"}
+
+// roomOptions are the join fields that configure a newly created room
+var roomOptions = []string{
+	"seed", "mazeAlgorithm", "theme", "loopFactor", "terrainDensity",
+	"crossingDensity", "floors", "deadEnds", "deadEndCount", "minPathRatio", "goalCount",
+	"goalMode", "wallCharges", "collision", "fog", "fogRadius", "private",
+	"maxPlayers", "rounds", "mode", "teams", "teamGoal", "coop", "hints", "practice", "mapVeto",
+	"goalMoveSeconds", "shiftSeconds", "minotaur", "antiCamp", "campSeconds", "campRadius",
+	"moveIntervalMs", "levelId", "difficulty",
+}
+
+// ClientMessages is every message a client may send
+var ClientMessages = []MessageType{
+	{Name: "hello", Summary: "Before joining a room: declare the protocol version, encodings (preferred first) and capabilities the client supports; answered by welcome",
+		Fields: []string{"protocol", "encodings", "caps"}},
+	{Name: "join", Summary: "Join a room by ID (creating it with the given options) or a private room by code",
+		Fields: append([]string{"roomId", "code", "password"}, roomOptions...)},
+	{Name: "ready", Summary: "Ready up in the lobby"},
+	{Name: "move", Summary: "Step to an adjacent cell, or up or down the stairs to the same cell on the next floor", Fields: []string{"x", "y", "z"}},
+	{Name: "moveDir", Summary: "Step one cell up, right, down or left of where the server has you, or upstairs or downstairs on stairs",
+		Fields: []string{"direction"}, Required: []string{"direction"}},
+	{Name: "useItem", Summary: "Use a held power-up", Fields: []string{"item", "direction"}, Required: []string{"item"}},
+	{Name: "breakWall", Summary: "Break the wall on one side with a wall charge", Fields: []string{"direction"}, Required: []string{"direction"}},
+	{Name: "resync", Summary: "Ask for the full room state after detecting drift"},
+	{Name: "requestSnapshot", Summary: "Ask for a fastForward since a version, or a full snapshot", Fields: []string{"sinceVersion"}},
+	{Name: "findMatch", Summary: "Join the ranked matchmaking queue"},
+	{Name: "cancelMatch", Summary: "Leave the matchmaking queue, declining a proposed match"},
+	{Name: "acceptMatch", Summary: "Accept the proposed match"},
+	{Name: "declineMatch", Summary: "Decline the proposed match; repeat offenders get a matchmaking cooldown"},
+	{Name: "updateProfile", Summary: "Change name, colour or avatar", Fields: []string{"name", "color", "avatar"}},
+	{Name: "chat", Summary: "Say something in the room, or only to your team on the team channel",
+		Fields: []string{"text", "channel"}, Required: []string{"text"}},
+	{Name: "emote", Summary: "Send a predefined emote", Fields: []string{"emote"}, Required: []string{"emote"}},
+	{Name: "visibility", Summary: "Report the page being hidden or shown again", Fields: []string{"hidden"}},
+	{Name: "listRooms", Summary: "List public rooms"},
+	{Name: "spectate", Summary: "Watch a public room without playing in it, until joining a room or stopping",
+		Fields: []string{"roomId"}, Required: []string{"roomId"}},
+	{Name: "stopSpectating", Summary: "Stop watching the room being spectated"},
+	{Name: "startTutorial", Summary: "Open a private tutorial room that teaches moving, smashing, items and winning, and join it; seed picks its maze",
+		Fields: []string{"seed"}},
+	{Name: "startDaily", Summary: "Open a private room on today's daily challenge maze, the same for everyone, and join it to race solo against the clock"},
+	{Name: "dailyLeaderboard", Summary: "Ask for the best daily challenge times of a UTC day (YYYY-MM-DD, default today)", Fields: []string{"day"}},
+	{Name: "leaderboard", Summary: "Ask for a page of a board (wins, fastest or rating) in a mode (all, classic or a registered mode)",
+		Fields: []string{"board", "mode", "offset", "limit"}},
+	{Name: "matchHistory", Summary: "Ask for a page of a player's finished matches (yours without playerId), newest first",
+		Fields: []string{"playerId", "offset", "limit"}},
+	{Name: "startVote", Summary: "Put a decision to the room: kick (with playerId), newMaze, mazeSize (with size), extendTime, restart (unranked), pause, resume, or suspend (casual matches, unanimous)",
+		Fields: []string{"voteKind", "playerId", "size"}, Required: []string{"voteKind"}},
+	{Name: "castVote", Summary: "Vote yes or no on the running vote", Fields: []string{"yes"}},
+	{Name: "veto", Summary: "Strike the candidate maze with this seed on your veto turn", Fields: []string{"seed"}, Required: []string{"seed"}},
+	{Name: "sandbox", Summary: "Practice rooms only: teleport to (x, y, z), reveal the maze, spawnItem of kind item at (x, y, z), or setSpeed to speed",
+		Fields: []string{"command", "x", "y", "z", "item", "speed"}, Required: []string{"command"}},
+	{Name: "resumeMatch", Summary: "Ask to resume a suspended match by its code; it resumes once every player has asked",
+		Fields: []string{"code"}, Required: []string{"code"}},
+	{Name: "review", Summary: "Go over a finished ranked match with the room: start, next or prev event, or stop",
+		Fields: []string{"command"}, Required: []string{"command"}},
+	{Name: "listReplays", Summary: "List recorded matches, newest first"},
+	{Name: "watchReplay", Summary: "Stream a recorded match, or change the speed of the one playing (no replayId)",
+		Fields: []string{"replayId", "speed"}},
+	{Name: "stopReplay", Summary: "Stop the replay being streamed"},
+	{Name: "addBot", Summary: "Seat a server-controlled opponent in the lobby", Fields: []string{"difficulty"}},
+	{Name: "setTeam", Summary: "Host only: pin a player to a team, or back to auto-balancing with team 0",
+		Fields: []string{"playerId", "team"}, Required: []string{"playerId"}},
+	{Name: "kick", Summary: "Host only: remove a player from the room; with ban they can't come back",
+		Fields: []string{"playerId", "ban"}, Required: []string{"playerId"}},
+	{Name: "transferHost", Summary: "Host only: hand the room's host privileges to another player",
+		Fields: []string{"playerId"}, Required: []string{"playerId"}},
+	{Name: "lockRoom", Summary: "Host only: turn away new players (locked), or let them in again", Fields: []string{"locked"}},
+	{Name: "mazeSettings", Summary: "Host only, in the lobby or between rounds: change how the room's mazes are generated and swap in a new one; empty fields are left as they were",
+		Fields: []string{"seed", "mazeAlgorithm", "theme", "loopFactor", "terrainDensity", "crossingDensity", "floors", "minPathRatio", "goalCount", "difficulty"}},
+	{Name: "loadMaze", Summary: "Admin role only, in the lobby or between rounds: load a hand-designed maze file, checked to be solvable, into a room (default yours) for its coming round",
+		Fields: []string{"roomId", "mazeFile", "format"}, Required: []string{"mazeFile"}},
+	{Name: "uploadMaze", Summary: "Store a maze made in an editor as a level, given as a layout (cells, goal(s), spawns) or a maze file; it must be 5 to 50 cells a side with every cell reachable and every exit reachable from every spawn. Answered by mazeUploaded; join with its levelId to play it",
+		Fields: []string{"name", "layout", "mazeFile", "format"}},
+	{Name: "pause", Summary: "Host only: freeze the match, at most 3 times a match; it resumes by itself after 2 minutes. Others start a pause vote"},
+	{Name: "resume", Summary: "Host only: unfreeze the paused match after a countdown. Others start a resume vote"},
+}
+
+// ServerMessages is every message the server sends
+var ServerMessages = []MessageType{
+	{Name: "connected", Summary: "Hello: the player's public ID (message), the secret to resume it with next time (secret) and profile"},
+	{Name: "welcome", Summary: "Answers hello with the protocol version the connection speaks (the client's, or the server's if older), the encoding used from the next message on, and the capabilities enabled"},
+	{Name: "ack", Summary: "Acknowledges client seqs up to ack when nothing else carried it"},
+	{Name: "error", Summary: "A request failed; error holds the code, requestId the request"},
+	{Name: "kicked", Summary: "The client is being disconnected, see reason (admin kicks carry the operator's message)"},
+	{Name: "roomClosed", Summary: "An operator closed the room, or it closed empty (message holds why); the client is back in the lobby"},
+	{Name: "announcement", Summary: "A message from the server's operators to everyone connected"},
+	{Name: "mazeData", Summary: "The room's maze and players, sent on join"},
+	{Name: "joinRejected", Summary: "Join refused: bad code, bad password, room full or locked, unknown levelId, or a fog room for a client that can't show fog"},
+	{Name: "chatHistory", Summary: "Recent chat, sent on join"},
+	{Name: "playerJoined", Summary: "Someone joined the room"},
+	{Name: "playerLeft", Summary: "Someone left the room"},
+	{Name: "playerReady", Summary: "Someone readied up"},
+	{Name: "playerAway", Summary: "Someone backgrounded their page"},
+	{Name: "playerBack", Summary: "Someone came back"},
+	{Name: "playerIdle", Summary: "Someone has done nothing for the idle timeout; seconds is how long they have before removal"},
+	{Name: "playerActive", Summary: "An idle player did something again"},
+	{Name: "idleRemoved", Summary: "The player idled too long and was removed from the room, freeing their slot"},
+	{Name: "playerMoved", Summary: "A player moved: message is the player, position where to"},
+	{Name: "profileUpdated", Summary: "Someone in the room changed their profile"},
+	{Name: "voteStarted", Summary: "A vote opened; vote holds its standing"},
+	{Name: "voteUpdated", Summary: "Someone voted"},
+	{Name: "voteEnded", Summary: "A vote closed with its result, carried out if it passed"},
+	{Name: "voteKicked", Summary: "The room voted the player out; they stay connected but can't rejoin it"},
+	{Name: "hostKicked", Summary: "The host removed the player from the room; they stay connected, and can rejoin unless reason is banned"},
+	{Name: "hostChanged", Summary: "Someone else hosts the room now (host), because the host handed it over or left (reason)"},
+	{Name: "roomLocked", Summary: "The host (message) locked the room: nobody new may join"},
+	{Name: "roomUnlocked", Summary: "The host (message) opened the locked room again"},
+	{Name: "matchPaused", Summary: "The match is frozen by the host or a vote started by message; it resumes by itself in seconds"},
+	{Name: "matchResuming", Summary: "The paused match counts down to resuming at startsAt; moves are still refused until then"},
+	{Name: "matchResumed", Summary: "The match is back on; reason is host, vote or timeout, and seconds is the round time left"},
+	{Name: "matchSuspended", Summary: "The room voted to suspend the match; code resumes it later"},
+	{Name: "suspendFailed", Summary: "A passed suspend vote could not save the match, which goes on"},
+	{Name: "resumePending", Summary: "Waiting for the other players to resume the match with this code"},
+	{Name: "resumeRequested", Summary: "Another player (message) wants to resume the suspended match with this code"},
+	{Name: "vetoStarted", Summary: "Everyone is ready: strike candidate mazes in turn until one is left"},
+	{Name: "vetoUpdated", Summary: "A candidate was struck; veto shows whose turn is next"},
+	{Name: "vetoDecided", Summary: "One maze is left (veto.chosen); a newRound with it follows"},
+	{Name: "vetoCancelled", Summary: "A player left mid-veto; it starts over once everyone is ready"},
+	{Name: "teamsUpdated", Summary: "The team line-up changed: players carry their team and its color"},
+	{Name: "playerHome", Summary: "A player (message) reached an exit and is out of the round, waiting for their team"},
+	{Name: "gameStarting", Summary: "Everyone is ready; the countdown to startsAt begins, and moves are refused until it ends"},
+	{Name: "countdown", Summary: "Countdown tick (before a round or out of a pause), cancellation, or Go!"},
+	{Name: "timer", Summary: "Seconds left in the match"},
+	{Name: "gameOver", Summary: "The match ended: winner, winningTeam in team rooms, reason and summary"},
+	{Name: "roundOver", Summary: "A round of a best-of-N match ended"},
+	{Name: "newRound", Summary: "The next round's maze and positions"},
+	{Name: "scoreUpdate", Summary: "Scores changed"},
+	{Name: "speedChanged", Summary: "A player's (message) speed stat changed; players holds everyone's"},
+	{Name: "collision", Summary: "Two players collided"},
+	{Name: "noise", Summary: "Footsteps heard from a direction, under fog"},
+	{Name: "campingWarning", Summary: "The player has stayed put too long and is penalized (reason) until they move on"},
+	{Name: "camperRevealed", Summary: "An opponent (message) is camping at position"},
+	{Name: "breadcrumbs", Summary: "Every runner's trail of recent cells, fading as they age; hunters only, in pursuit modes"},
+	{Name: "flags", Summary: "Capture the flag: every team's flag planted at its base, teams gathered there, as a round starts"},
+	{Name: "flagTaken", Summary: "A player (message) picked up an enemy flag; flags holds where every flag is"},
+	{Name: "flagDropped", Summary: "A carrier (message) dropped the flag, were tagged by an enemy or left; it goes home by itself after returnIn seconds"},
+	{Name: "flagReturned", Summary: "A dropped flag went back to its base, brought by a teammate (message) or on its own"},
+	{Name: "flagCaptured", Summary: "A player (message) brought the enemy flag to their base; the first team to 3 captures wins"},
+	{Name: "hazardsMoved", Summary: "Where every minotaur is now, and whom it is chasing"},
+	{Name: "minotaurCaught", Summary: "A minotaur caught a player (message) at position: back to spawn and down 50 points"},
+	{Name: "mazeShifting", Summary: "Part of the maze changes in this many seconds: message says how (rotate, toggle, shift) and position where; mazeUpdated follows"},
+	{Name: "goalMoving", Summary: "The exits move in this many seconds"},
+	{Name: "goalMoved", Summary: "The exits moved far from every player: goals holds where, and what each is worth"},
+	{Name: "itemSpawned", Summary: "A power-up appeared"},
+	{Name: "itemPickedUp", Summary: "Someone picked up an item"},
+	{Name: "itemUsed", Summary: "Someone used a power-up"},
+	{Name: "hint", Summary: "The next steps toward the nearest exit, for the hint's user only"},
+	{Name: "mazeReveal", Summary: "Cells that came into view under fog"},
+	{Name: "mazeUpdated", Summary: "Cells whose walls or terrain changed"},
+	{Name: "snapshot", Summary: "Periodic state fingerprint for desync detection, with players, items and hazards"},
+	{Name: "resync", Summary: "Full room state, answering resync"},
+	{Name: "fullSnapshot", Summary: "Full room state, answering requestSnapshot"},
+	{Name: "fastForward", Summary: "Messages missed since a version, answering requestSnapshot"},
+	{Name: "batch", Summary: "Messages from one atomic room update, in order"},
+	{Name: "tick", Summary: "With the tickBatch capability: everything the room sent since the last tick, in batch, stamped with tick and time"},
+	{Name: "chat", Summary: "A chat line, to the room or to the sender's team"},
+	{Name: "chatRejected", Summary: "A chat line was refused"},
+	{Name: "emote", Summary: "Someone emoted"},
+	{Name: "emoteRejected", Summary: "An emote was refused"},
+	{Name: "profile", Summary: "The player's own updated profile"},
+	{Name: "profileRejected", Summary: "A profile update was refused"},
+	{Name: "roomList", Summary: "Public rooms"},
+	{Name: "spectating", Summary: "Watching the room: its whole maze, players, state and items; everything sent to the whole room follows"},
+	{Name: "demoStarted", Summary: "Bots started a demo match in the empty room"},
+	{Name: "demoEnded", Summary: "The demo match is over or someone joined; the room is back in the lobby on a new maze"},
+	{Name: "tutorialStep", Summary: "The next tutorial objective (message) of stage reason, numbered by round; position points at what it is about, if anything"},
+	{Name: "tutorialHint", Summary: "A nudge (message) for a player stuck on tutorial stage reason"},
+	{Name: "tutorialComplete", Summary: "The tutorial is finished and marked on the player's profile"},
+	{Name: "dailyLeaderboard", Summary: "A day's daily challenge leaderboard (daily), as asked for or after finishing a run, with the player's own best"},
+	{Name: "matchHistory", Summary: "A page of a player's finished matches (history), with each player's score and moves"},
+	{Name: "leaderboard", Summary: "A page of a leaderboard, as asked for; clients in the lobby are sent the top of the overall wins board now and then"},
+	{Name: "reviewStarted", Summary: "A player (message) opened a match review: the first round's maze, and review with the paths"},
+	{Name: "reviewStep", Summary: "The review moved to another event (event); maze comes along when the round changes"},
+	{Name: "reviewEnded", Summary: "A player (message) closed the review"},
+	{Name: "replayList", Summary: "Recorded matches, newest first"},
+	{Name: "mazeUploaded", Summary: "The uploaded maze was stored as a level (level)"},
+	{Name: "replayStart", Summary: "A replay begins: replay describes it, maze is its first maze"},
+	{Name: "replayEvent", Summary: "The next event of the replay, sent in match time scaled by the speed"},
+	{Name: "replayEnd", Summary: "The replay played to the end"},
+	{Name: "queued", Summary: "Waiting in the matchmaking queue"},
+	{Name: "queueCancelled", Summary: "Left the matchmaking queue"},
+	{Name: "queueTimeout", Summary: "No opponent was found in time"},
+	{Name: "queueRejected", Summary: "Matchmaking refused during a cooldown; seconds holds the time left"},
+	{Name: "matchProposed", Summary: "An opponent was found (message, rating); accept within seconds"},
+	{Name: "matchAccepted", Summary: "The opponent accepted the proposed match"},
+	{Name: "matchCancelled", Summary: "A proposed match was declined or timed out (reason) by message; the other player is queued again, the offender gets seconds of cooldown"},
+	{Name: "matchFound", Summary: "Both players accepted; join the room by code"},
+}
@@ -0,0 +1,280 @@
+package schema
+
+import (
+	"reflect"
+	"strconv"
+
+	"labyrinth-duel/websocket/internal/messages"
+	"labyrinth-duel/websocket/internal/metrics"
+	"labyrinth-duel/websocket/internal/room"
+	"labyrinth-duel/websocket/internal/trace"
+)
+
+// Version is the protocol version the documents describe, as semver
+var Version = strconv.Itoa(messages.ProtocolVersion) + ".0.0"
+
+const componentRefs = "#/components/schemas/"
+
+// ClientMessageSchema is the strict schema of one client message type: the
+// envelope plus that type's fields, nothing else
+func ClientMessageSchema(g *Generator, mt MessageType) Schema {
+	all := structProperties(g, reflect.TypeOf(messages.ClientMessage{}))
+	props := Schema{"type": Schema{"type": "string", "enum": []string{mt.Name}}}
+	for _, name := range append(EnvelopeFields[1:], mt.Fields...) {
+		props[name] = all[name]
+	}
+	return Schema{
+		"type":                 "object",
+		"properties":           props,
+		"required":             append([]string{"type"}, mt.Required...),
+		"additionalProperties": false,
+	}
+}
+
+// ModeMessageSchema is the schema of game mode messages, typed
+// "<mode>.<action>". Modes may use any client message field.
+func ModeMessageSchema(g *Generator) Schema {
+	props := structProperties(g, reflect.TypeOf(messages.ClientMessage{}))
+	props["type"] = Schema{"type": "string", "pattern": "^[^.]+\\.[^.]+$"}
+	return Schema{
+		"type":                 "object",
+		"properties":           props,
+		"required":             []string{"type"},
+		"additionalProperties": false,
+	}
+}
+
+// structProperties returns the property schemas of a struct, without
+// registering it as a definition
+func structProperties(g *Generator, t reflect.Type) Schema {
+	props := Schema{}
+	for _, f := range Fields(t) {
+		props[f.Name] = g.For(f.Type)
+	}
+	return props
+}
+
+// AsyncAPI describes the WebSocket protocol
+func AsyncAPI() Schema {
+	g := NewGenerator(componentRefs)
+	serverMsg := g.For(reflect.TypeOf(messages.ServerMessage{}))
+
+	msgs := Schema{}
+	var publish, subscribe []Schema
+	for _, mt := range ClientMessages {
+		key := "client." + mt.Name
+		msgs[key] = Schema{
+			"name":    mt.Name,
+			"summary": mt.Summary,
+			"payload": ClientMessageSchema(g, mt),
+		}
+		publish = append(publish, Schema{"$ref": "#/components/messages/" + key})
+	}
+	msgs["client.mode"] = Schema{
+		"name":    "<mode>.<action>",
+		"summary": "A game mode action, e.g. tag.transfer; rejected with MODE_INACTIVE unless the room plays that mode",
+		"payload": ModeMessageSchema(g),
+	}
+	publish = append(publish, Schema{"$ref": "#/components/messages/client.mode"})
+	for _, mt := range ServerMessages {
+		key := "server." + mt.Name
+		msgs[key] = Schema{
+			"name":    mt.Name,
+			"summary": mt.Summary,
+			"payload": Schema{
+				"allOf": []Schema{serverMsg, {
+					"properties": Schema{"type": Schema{"type": "string", "enum": []string{mt.Name}}},
+				}},
+			},
+		}
+		subscribe = append(subscribe, Schema{"$ref": "#/components/messages/" + key})
+	}
+
+	return Schema{
+		"asyncapi": "2.6.0",
+		"info": Schema{
+			"title":       "Maze Smash game protocol",
+			"version":     Version,
+			"description": "One message per WebSocket frame, as JSON text frames or, with encoding=msgpack, MessagePack binary frames using the same field names.",
+		},
+		"servers": Schema{
+			"local": Schema{"url": "localhost:8080", "protocol": "ws"},
+		},
+		"defaultContentType": "application/json",
+		"channels": Schema{
+			"/ws": Schema{
+				"bindings": Schema{"ws": Schema{"query": Schema{
+					"type": "object",
+					"properties": Schema{
+						"playerId": Schema{"type": "string", "description": "Persistent player ID to resume"},
+						"secret":   Schema{"type": "string", "description": "Resume secret sent with the player ID in connected; required to resume it"},
+						"encoding": Schema{"type": "string", "enum": []string{"json", "msgpack"}},
+					},
+				}}},
+				"publish":   Schema{"summary": "Client to server", "message": Schema{"oneOf": publish}},
+				"subscribe": Schema{"summary": "Server to client", "message": Schema{"oneOf": subscribe}},
+			},
+		},
+		"components": Schema{
+			"messages": msgs,
+			"schemas":  g.Definitions(),
+		},
+	}
+}
+
+// OpenAPI describes the HTTP endpoints
+func OpenAPI() Schema {
+	g := NewGenerator(componentRefs)
+	roomInfo := g.For(reflect.TypeOf(messages.RoomInfo{}))
+	replayInfo := g.For(reflect.TypeOf(messages.ReplayInfo{}))
+	fullReplay := Schema{"allOf": []Schema{
+		replayInfo,
+		{"type": "object", "properties": Schema{
+			"mazes":  g.For(reflect.TypeOf([]*messages.MazeData{})),
+			"events": g.For(reflect.TypeOf([]messages.ReplayEvent{})),
+		}},
+	}}
+	scope := g.For(reflect.TypeOf(trace.Scope{}))
+	roomStatus := g.For(reflect.TypeOf(room.Status{}))
+	mazeFile := g.For(reflect.TypeOf(messages.MazeData{})) // Maze files in json have the fields of mazeData
+	serverMetrics := Schema{"allOf": []Schema{
+		g.For(reflect.TypeOf(metrics.Snapshot{})),
+		{"type": "object", "properties": Schema{
+			"rooms":   Schema{"type": "integer"},
+			"players": Schema{"type": "integer"},
+		}},
+	}}
+
+	jsonBody := func(description string, s Schema) Schema {
+		return Schema{
+			"description": description,
+			"content":     Schema{"application/json": Schema{"schema": s}},
+		}
+	}
+	query := func(name, description string, s Schema) Schema {
+		return Schema{"name": name, "in": "query", "description": description, "schema": s}
+	}
+	traceParams := []Schema{
+		query("room", "Room to trace", Schema{"type": "string"}),
+		query("client", "Client to trace (instead of room)", Schema{"type": "string"}),
+	}
+	admin := []Schema{{"adminToken": []string{}}}
+
+	return Schema{
+		"openapi": "3.0.3",
+		"info":    Schema{"title": "Maze Smash HTTP API", "version": Version},
+		"paths": Schema{
+			"/ws": Schema{"get": Schema{
+				"summary": "Upgrade to the game WebSocket (see the AsyncAPI document)",
+				"parameters": []Schema{
+					query("playerId", "Persistent player ID to resume", Schema{"type": "string"}),
+					query("secret", "Resume secret sent with the player ID in connected; required to resume it", Schema{"type": "string"}),
+					query("protocol", "Protocol version the client speaks (default the oldest still served; see hello)", Schema{"type": "integer", "minimum": 1}),
+					query("encoding", "Wire format", Schema{"type": "string", "enum": []string{"json", "msgpack"}}),
+				},
+				"responses": Schema{"101": Schema{"description": "Switching to WebSocket"}},
+			}},
+			"/rooms": Schema{"get": Schema{
+				"summary":   "List public rooms",
+				"responses": Schema{"200": jsonBody("Public rooms", Schema{"type": "array", "items": roomInfo})},
+			}},
+			"/replays": Schema{"get": Schema{
+				"summary":   "List recorded matches, newest first",
+				"responses": Schema{"200": jsonBody("Replays", Schema{"type": "array", "items": replayInfo})},
+			}},
+			"/replays/{id}": Schema{"get": Schema{
+				"summary":    "A recorded match: its mazes and every event, timed from the start of play",
+				"parameters": []Schema{{"name": "id", "in": "path", "required": true, "schema": Schema{"type": "string"}}},
+				"responses": Schema{
+					"200": jsonBody("Replay", fullReplay),
+					"404": Schema{"description": "No such replay"},
+				},
+			}},
+			"/admin/": Schema{"get": Schema{
+				"summary":   "Admin dashboard page (basic auth with the token as password also works)",
+				"security":  admin,
+				"responses": Schema{"200": Schema{"description": "HTML page"}},
+			}},
+			"/admin/metrics": Schema{"get": Schema{
+				"summary":   "Server-wide connection and traffic counters with recent rates",
+				"security":  admin,
+				"responses": Schema{"200": jsonBody("Counters", serverMetrics)},
+			}},
+			"/admin/rooms": Schema{"get": Schema{
+				"summary":   "Live state of every room, private ones included",
+				"security":  admin,
+				"responses": Schema{"200": jsonBody("Rooms", Schema{"type": "array", "items": roomStatus})},
+			}},
+			"/admin/rooms/{id}/maze": Schema{
+				"parameters": []Schema{{"name": "id", "in": "path", "required": true, "schema": Schema{"type": "string"}}},
+				"get": Schema{
+					"summary":    "The room's current maze as a file",
+					"security":   admin,
+					"parameters": []Schema{query("format", "File format (default json)", Schema{"type": "string", "enum": []string{"json", "text"}})},
+					"responses": Schema{
+						"200": Schema{
+							"description": "Maze file",
+							"content": Schema{
+								"application/json": Schema{"schema": mazeFile},
+								"text/plain":       Schema{"schema": Schema{"type": "string", "description": "ASCII art, see game.ParseText"}},
+							},
+						},
+						"404": Schema{"description": "No such room"},
+					},
+				},
+				"put": Schema{
+					"summary":    "Load a maze file, checked to be solvable, into the room for its coming round (lobby or countdown only)",
+					"security":   admin,
+					"parameters": []Schema{query("format", "File format (default from Content-Type, then the first character)", Schema{"type": "string", "enum": []string{"json", "text"}})},
+					"requestBody": Schema{"required": true, "content": Schema{
+						"application/json": Schema{"schema": mazeFile},
+						"text/plain":       Schema{"schema": Schema{"type": "string"}},
+					}},
+					"responses": Schema{
+						"204": Schema{"description": "Maze loaded"},
+						"400": Schema{"description": "Malformed or unplayable maze"},
+						"404": Schema{"description": "No such room"},
+						"409": Schema{"description": "The room is playing a round"},
+					},
+				},
+			},
+			"/admin/trace": Schema{
+				"get": Schema{
+					"summary":   "List traced rooms and clients",
+					"security":  admin,
+					"responses": Schema{"200": jsonBody("Traced scopes", Schema{"type": "array", "items": scope})},
+				},
+				"post": Schema{
+					"summary":  "Start tracing a room or client",
+					"security": admin,
+					"parameters": append(traceParams,
+						query("minutes", "How long to trace (default 10, at most 60)", Schema{"type": "integer", "minimum": 1})),
+					"responses": Schema{
+						"200": jsonBody("Tracing started", scope),
+						"400": Schema{"description": "Missing room or client, or bad minutes"},
+					},
+				},
+				"delete": Schema{
+					"summary":    "Stop tracing a room or client",
+					"security":   admin,
+					"parameters": traceParams,
+					"responses":  Schema{"204": Schema{"description": "Tracing stopped"}},
+				},
+			},
+			"/schema/asyncapi.json": Schema{"get": Schema{
+				"summary":   "AsyncAPI document for the WebSocket protocol",
+				"responses": Schema{"200": jsonBody("AsyncAPI 2.6 document", Schema{"type": "object"})},
+			}},
+			"/schema/openapi.json": Schema{"get": Schema{
+				"summary":   "This document",
+				"responses": Schema{"200": jsonBody("OpenAPI 3.0 document", Schema{"type": "object"})},
+			}},
+		},
+		"components": Schema{
+			"schemas": g.Definitions(),
+			"securitySchemes": Schema{
+				"adminToken": Schema{"type": "http", "scheme": "bearer", "description": "ADMIN_TOKEN"},
+			},
+		},
+	}
+}
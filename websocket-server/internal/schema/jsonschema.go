@@ -0,0 +1,119 @@
+// Package schema describes the protocol for tooling: JSON Schemas generated
+// from the message structs, an AsyncAPI document for the WebSocket protocol
+// and an OpenAPI document for the HTTP endpoints.
+package schema
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Schema is a JSON Schema object
+type Schema = map[string]interface{}
+
+// Generator builds JSON Schemas from Go types. Named structs become shared
+// definitions referenced by $ref, so recursive types like ServerMessage's
+// batch work.
+type Generator struct {
+	refPrefix string
+	defs      map[string]Schema
+}
+
+// NewGenerator creates a generator whose references point under refPrefix,
+// e.g. "#/components/schemas/"
+func NewGenerator(refPrefix string) *Generator {
+	return &Generator{refPrefix: refPrefix, defs: make(map[string]Schema)}
+}
+
+// Definitions returns every struct schema generated so far, by type name
+func (g *Generator) Definitions() map[string]Schema {
+	return g.defs
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// For returns the schema of a Go type
+func (g *Generator) For(t reflect.Type) Schema {
+	if t == timeType {
+		return Schema{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return g.For(t.Elem())
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Schema{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Schema{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return Schema{"type": "array", "items": g.For(t.Elem())}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": g.For(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		if _, done := g.defs[t.Name()]; !done {
+			g.defs[t.Name()] = nil // Placeholder so recursion stops here
+			g.defs[t.Name()] = g.structSchema(t)
+		}
+		return Schema{"$ref": g.refPrefix + t.Name()}
+	}
+	return Schema{}
+}
+
+// structSchema lists a struct's JSON fields. Fields without omitempty are
+// always sent, so they are required.
+func (g *Generator) structSchema(t reflect.Type) Schema {
+	props := Schema{}
+	var required []string
+	for _, f := range Fields(t) {
+		props[f.Name] = g.For(f.Type)
+		if !f.OmitEmpty {
+			required = append(required, f.Name)
+		}
+	}
+
+	s := Schema{"type": "object", "properties": props}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+// Field is a struct field as it appears in JSON
+type Field struct {
+	Name      string
+	Type      reflect.Type
+	OmitEmpty bool
+}
+
+// Fields returns a struct's JSON fields in declaration order
+func Fields(t reflect.Type) []Field {
+	var fields []Field
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name, opts := f.Name, ""
+		if tag, ok := f.Tag.Lookup("json"); ok {
+			if tag == "-" {
+				continue
+			}
+			name, opts, _ = strings.Cut(tag, ",")
+			if name == "" {
+				name = f.Name
+			}
+		}
+		fields = append(fields, Field{Name: name, Type: f.Type, OmitEmpty: strings.Contains(opts, "omitempty")})
+	}
+	return fields
+}
@@ -0,0 +1,184 @@
+package schema
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+// Validator checks decoded client messages against their strict schemas
+type Validator struct {
+	defs    map[string]Schema
+	clients map[string]Schema // By message type
+	mode    Schema            // Any "<mode>.<action>" message
+}
+
+// NewValidator builds the schemas of every client message type
+func NewValidator() *Validator {
+	g := NewGenerator(componentRefs)
+	v := &Validator{clients: make(map[string]Schema)}
+	for _, mt := range ClientMessages {
+		v.clients[mt.Name] = ClientMessageSchema(g, mt)
+	}
+	v.mode = ModeMessageSchema(g)
+	v.defs = g.Definitions()
+	return v
+}
+
+// ValidateClient checks a client message decoded into interface{} (as
+// encoding/json would) and returns every problem found, or nil if it is
+// valid
+func (v *Validator) ValidateClient(msg interface{}) []messages.FieldError {
+	obj, ok := msg.(map[string]interface{})
+	if !ok {
+		return []messages.FieldError{{Field: "", Message: "expected object, got " + kindOf(msg)}}
+	}
+	msgType, ok := obj["type"].(string)
+	if !ok {
+		return []messages.FieldError{{Field: "type", Message: "required string"}}
+	}
+	s, ok := v.clients[msgType]
+	if !ok && isModeType(msgType) {
+		s, ok = v.mode, true
+	}
+	if !ok {
+		return []messages.FieldError{{Field: "type", Message: fmt.Sprintf("unknown message type %q", msgType)}}
+	}
+
+	var errs []messages.FieldError
+	v.check(s, msg, "", &errs)
+	return errs
+}
+
+// check validates value against s, appending problems under path. It
+// supports the subset of JSON Schema the generator emits.
+func (v *Validator) check(s Schema, value interface{}, path string, errs *[]messages.FieldError) {
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, messages.FieldError{Field: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if ref, ok := s["$ref"].(string); ok {
+		v.check(v.defs[strings.TrimPrefix(ref, componentRefs)], value, path, errs)
+		return
+	}
+	if all, ok := s["allOf"].([]Schema); ok {
+		for _, sub := range all {
+			v.check(sub, value, path, errs)
+		}
+	}
+
+	if want, ok := s["type"].(string); ok && !isType(value, want) {
+		fail("expected %s, got %s", want, kindOf(value))
+		return
+	}
+	if enum, ok := s["enum"].([]string); ok && value != nil {
+		str, _ := value.(string)
+		if !contains(enum, str) {
+			fail("must be one of %s", strings.Join(enum, ", "))
+		}
+	}
+	if min, ok := s["minimum"].(int); ok {
+		if n, _ := value.(float64); n < float64(min) {
+			fail("must be at least %d", min)
+		}
+	}
+
+	switch value := value.(type) {
+	case []interface{}:
+		if items, ok := s["items"].(Schema); ok {
+			for i, item := range value {
+				v.check(items, item, fmt.Sprintf("%s[%d]", path, i), errs)
+			}
+		}
+	case map[string]interface{}:
+		props, _ := s["properties"].(Schema)
+		required, _ := s["required"].([]string)
+		for _, name := range required {
+			if _, ok := value[name]; !ok {
+				*errs = append(*errs, messages.FieldError{Field: join(path, name), Message: "required"})
+			}
+		}
+
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if prop, ok := props[key].(Schema); ok {
+				v.check(prop, value[key], join(path, key), errs)
+				continue
+			}
+			switch extra := s["additionalProperties"].(type) {
+			case bool:
+				if !extra {
+					*errs = append(*errs, messages.FieldError{Field: join(path, key), Message: "unknown field"})
+				}
+			case Schema:
+				v.check(extra, value[key], join(path, key), errs)
+			}
+		}
+	}
+}
+
+// isType reports whether a decoded value has a JSON Schema type. Null is
+// accepted anywhere, like encoding/json leaves the field zero.
+func isType(value interface{}, want string) bool {
+	if value == nil {
+		return true
+	}
+	switch want {
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == math.Trunc(n) && !math.IsInf(n, 0)
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	}
+	return kindOf(value) == want
+}
+
+// kindOf names the JSON type of a decoded value
+func kindOf(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return reflect.TypeOf(value).String()
+}
+
+// isModeType reports whether a message type is namespaced "<mode>.<action>"
+func isModeType(msgType string) bool {
+	mode, action, ok := strings.Cut(msgType, ".")
+	return ok && mode != "" && action != ""
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,238 @@
+package server
+
+import (
+	_ "embed"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+
+	"labyrinth-duel/websocket/internal/game"
+	"labyrinth-duel/websocket/internal/level"
+	"labyrinth-duel/websocket/internal/messages"
+	"labyrinth-duel/websocket/internal/metrics"
+)
+
+//go:embed dashboard.html
+var dashboardHTML []byte
+
+// adminMetrics is what /admin/metrics reports
+type adminMetrics struct {
+	metrics.Snapshot
+	Rooms   int `json:"rooms"`
+	Players int `json:"players"`
+}
+
+// HandleDashboard serves the admin dashboard page at /admin/
+func (s *Server) HandleDashboard(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if r.URL.Path != "/admin/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(dashboardHTML)
+}
+
+// HandleAdminMetrics serves GET /admin/metrics: server-wide connection and
+// traffic counters with their recent rates
+func (s *Server) HandleAdminMetrics(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	m := adminMetrics{Snapshot: s.metrics.Snapshot()}
+	for _, status := range s.rooms.Statuses() {
+		m.Rooms++
+		m.Players += len(status.Players)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(m)
+}
+
+// HandleAdminRooms serves the room admin API. GET /admin/rooms lists the
+// live state of every room, private ones included; GET /admin/rooms/{id}
+// dumps one room's full state, and DELETE /admin/rooms/{id} (optional
+// ?reason=) closes it, sending its players away. /admin/rooms/{id}/maze
+// exports and loads mazes, see handleAdminMaze.
+func (s *Server) HandleAdminRooms(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+
+	id, sub, _ := strings.Cut(strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/rooms"), "/"), "/")
+	switch {
+	case id != "" && sub == "maze":
+		s.handleAdminMaze(w, r, id)
+	case sub != "":
+		http.NotFound(w, r)
+	case id == "" && r.Method == http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.rooms.Statuses())
+	case id != "" && r.Method == http.MethodGet:
+		rm := s.rooms.GetRoom(id)
+		if rm == nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rm.Dump())
+	case id != "" && r.Method == http.MethodDelete:
+		reason := r.URL.Query().Get("reason")
+		if !s.rooms.CloseRoom(id, reason) {
+			http.NotFound(w, r)
+			return
+		}
+		slog.Info("Closed room", "room", id, "reason", reason)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// MaxMazeFileBytes caps the body of a maze loaded over HTTP
+const MaxMazeFileBytes = 1 << 20
+
+// handleAdminMaze serves GET /admin/rooms/{id}/maze, the room's current
+// maze as a file (?format=json, the default, or text), and PUT, which loads
+// the maze file in the body into the room for its coming round. The body's
+// format is taken from ?format=, then its Content-Type, then its first
+// character.
+func (s *Server) handleAdminMaze(w http.ResponseWriter, r *http.Request, id string) {
+	rm := s.rooms.GetRoom(id)
+	if rm == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	switch r.Method {
+	case http.MethodGet:
+		if format == "" {
+			format = game.FormatJSON
+		}
+		data, err := rm.GetMaze().Export(format)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if format == game.FormatJSON {
+			w.Header().Set("Content-Type", "application/json")
+		} else {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		}
+		w.Write(data)
+	case http.MethodPut:
+		if format == "" {
+			switch mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType {
+			case "application/json":
+				format = game.FormatJSON
+			case "text/plain":
+				format = game.FormatText
+			}
+		}
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxMazeFileBytes))
+		if err != nil {
+			http.Error(w, "maze file too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		maze, err := game.ImportMaze(data, format)
+		if err == nil {
+			err = level.CheckPlayable(maze)
+		}
+		if err != nil {
+			http.Error(w, "bad maze file: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := rm.LoadMaze(maze); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		slog.Info("Loaded a maze", "room", id, "width", maze.Width, "height", maze.Height)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleAdminPlayers serves POST /admin/players/{id}/kick (optional
+// ?reason=): the player is put out of their room for good and
+// disconnected
+func (s *Server) HandleAdminPlayers(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	id, action, _ := strings.Cut(strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/players"), "/"), "/")
+	if id == "" || action != "kick" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	client := s.onlineClient(id)
+	if client == nil {
+		http.NotFound(w, r)
+		return
+	}
+	reason := r.URL.Query().Get("reason")
+	s.kick(client, reason)
+	client.logger("").Info("Kicked by an admin", "reason", reason)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// kick removes a client from their room, if any, and closes its connection
+func (s *Server) kick(client *Client, reason string) {
+	kicked := false
+	if r := s.rooms.GetRoom(client.currentRoom()); r != nil {
+		kicked = r.Kick(client.ID, reason) == nil
+	}
+	if !kicked {
+		client.SendJSON(messages.ServerMessage{
+			Type:    "kicked",
+			Reason:  "admin",
+			Message: reason,
+		})
+	}
+	client.Conn.Close()
+}
+
+// announcement is the body of POST /admin/announce
+type announcement struct {
+	Message string `json:"message"`
+}
+
+// HandleAdminAnnounce serves POST /admin/announce with {"message": ...}:
+// every player connected to this server is sent it as an announcement
+func (s *Server) HandleAdminAnnounce(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var a announcement
+	if err := json.NewDecoder(r.Body).Decode(&a); err != nil || a.Message == "" {
+		http.Error(w, "expected {\"message\": ...}", http.StatusBadRequest)
+		return
+	}
+	clients := s.onlineClients()
+	for _, c := range clients {
+		c.SendJSON(messages.ServerMessage{Type: "announcement", Message: a.Message})
+	}
+	slog.Info("Announced", "message", a.Message, "clients", len(clients))
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"labyrinth-duel/websocket/internal/auth"
+)
+
+// Errors returned by Authenticate
+var (
+	ErrTokenRequired = errors.New("a token is required to connect")
+	ErrAuthDisabled  = errors.New("token authentication is not enabled")
+	ErrBadSubject    = errors.New("token subject is not a valid player ID")
+)
+
+// Authenticate verifies the token a connecting client presents, as
+// "Authorization: Bearer <token>" or ?token=, before the WebSocket upgrade.
+// It returns nil claims for anonymous clients, which are only let in when
+// RequireAuth is off.
+func (s *Server) Authenticate(r *http.Request) (*auth.Claims, error) {
+	token := r.URL.Query().Get("token")
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		token = bearer
+	}
+	if token == "" {
+		if s.RequireAuth {
+			return nil, ErrTokenRequired
+		}
+		return nil, nil
+	}
+	if s.Auth == nil {
+		return nil, ErrAuthDisabled
+	}
+
+	claims, err := s.Auth.Verify(token, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !validPlayerID(claims.Subject) {
+		return nil, ErrBadSubject
+	}
+	return claims, nil
+}
+
+// HandleUpgradeError answers a WebSocket request Authenticate turned away
+func HandleUpgradeError(w http.ResponseWriter, err error) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="maze"`)
+	http.Error(w, err.Error(), http.StatusUnauthorized)
+}
@@ -0,0 +1,344 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"labyrinth-duel/websocket/internal/bus"
+	"labyrinth-duel/websocket/internal/messages"
+	"labyrinth-duel/websocket/internal/room"
+)
+
+// ClaimInterval is how often a clustered server renews its claims on the
+// rooms it hosts, well inside bus.OwnerTTL
+const ClaimInterval = 10 * time.Second
+
+// Envelope kinds carried between cluster nodes
+const (
+	envForward = "forward" // Home node to owner: a client message to handle
+	envLeave   = "leave"   // Home node to owner: the client is gone
+	envDeliver = "deliver" // Owner to home node: a server message for the client
+)
+
+// envelope is what nodes publish on each other's topics
+type envelope struct {
+	Kind   string                  `json:"kind"`
+	Node   string                  `json:"node"` // Sender
+	Client string                  `json:"client"`
+	Msg    *messages.ClientMessage `json:"msg,omitempty"`
+	Out    json.RawMessage         `json:"out,omitempty"`
+
+	// What the client settled on its home node, for its proxy (forward)
+	Caps     []string `json:"caps,omitempty"`
+	Hello    bool     `json:"hello,omitempty"`
+	Protocol int      `json:"protocol,omitempty"`
+}
+
+// cluster is the server's part in a group of instances sharing rooms. Each
+// room lives on the node that claimed it in the registry, which runs its
+// simulation and validates every move. A client connected elsewhere (its
+// home node) has its messages forwarded there, and the owner keeps a proxy
+// client in the room whose output is published back to the home node.
+type cluster struct {
+	node     string
+	bus      bus.Bus
+	registry bus.Registry
+
+	proxies map[string]*Client // Stand-ins for clients homed elsewhere, by home node and ID
+	mu      sync.Mutex
+}
+
+// JoinCluster makes the server one node of a cluster sharing rooms over b,
+// with room ownership recorded in reg. node must be unique in the cluster.
+// Call it before Run and before serving clients.
+//
+// Only rooms are shared: matchmaking, profiles, replays and room listings
+// stay per-instance.
+func (s *Server) JoinCluster(node string, b bus.Bus, reg bus.Registry) error {
+	c := &cluster{
+		node:     node,
+		bus:      b,
+		registry: reg,
+		proxies:  make(map[string]*Client),
+	}
+	if err := b.Subscribe(nodeTopic(node), s.handleEnvelope); err != nil {
+		return err
+	}
+	s.cluster = c
+
+	s.rooms.OnRoomAdded = func(r *room.Room) {
+		for _, key := range roomKeys(r) {
+			if owner, err := reg.Claim(key, node); err != nil {
+				slog.Error("Cannot claim room", "key", key, "err", err)
+			} else if owner != node {
+				slog.Warn("Hosting a room another node owns", "key", key, "owner", owner)
+			}
+		}
+	}
+	s.rooms.OnRoomRemoved = func(r *room.Room) {
+		for _, key := range roomKeys(r) {
+			if err := reg.Release(key, node); err != nil {
+				slog.Error("Cannot release room", "key", key, "err", err)
+			}
+		}
+	}
+	return nil
+}
+
+// Node returns the server's cluster node ID, or "" if it isn't clustered
+func (s *Server) Node() string {
+	if s.cluster == nil {
+		return ""
+	}
+	return s.cluster.node
+}
+
+// roomKeys are the registry keys a room is found by
+func roomKeys(r *room.Room) []string {
+	keys := []string{"room:" + r.ID}
+	if r.JoinCode != "" {
+		keys = append(keys, "code:"+r.JoinCode)
+	}
+	return keys
+}
+
+func nodeTopic(node string) string {
+	return "node." + node
+}
+
+// renewClaims keeps the claims on the rooms hosted here alive
+func (s *Server) renewClaims() {
+	for _, r := range s.rooms.All() {
+		for _, key := range roomKeys(r) {
+			if _, err := s.cluster.registry.Claim(key, s.cluster.node); err != nil {
+				slog.Error("Cannot renew room claim", "key", key, "err", err)
+			}
+		}
+	}
+}
+
+// nodeLocal are the message types always handled by the client's home
+// node, whatever room it is in
+var nodeLocal = map[string]bool{
+	"hello":            true,
+	"findMatch":        true,
+	"cancelMatch":      true,
+	"acceptMatch":      true,
+	"declineMatch":     true,
+	"updateProfile":    true,
+	"listRooms":        true,
+	"listReplays":      true,
+	"watchReplay":      true,
+	"stopReplay":       true,
+	"spectate":         true,
+	"stopSpectating":   true,
+	"startTutorial":    true,
+	"startDaily":       true,
+	"dailyLeaderboard": true,
+	"leaderboard":      true,
+	"matchHistory":     true,
+	"loadMaze":         true, // Forwarded messages don't carry the admin role
+	"uploadMaze":       true,
+}
+
+// route handles a client message here or forwards it to the node owning
+// the client's room
+func (s *Server) route(client *Client, msg messages.ClientMessage) {
+	if s.cluster == nil || nodeLocal[msg.Type] {
+		s.dispatch(client, msg)
+		return
+	}
+
+	owner := client.remoteNode()
+	if msg.Type == "join" || msg.Type == "resumeMatch" {
+		var err error
+		if owner, err = s.ownerOf(msg); err != nil {
+			client.logger(msg.Type).Error("Cannot locate room", "err", err)
+			sendError(client, msg, ErrCodeUnavailable, "room is unavailable")
+			return
+		}
+		if previous := client.remoteNode(); previous != "" && previous != owner {
+			s.publish(previous, envelope{Kind: envLeave, Client: client.ID})
+		}
+		if owner == s.cluster.node {
+			owner = ""
+		}
+		client.setRemote(owner)
+	}
+
+	if owner == "" {
+		s.dispatch(client, msg)
+		return
+	}
+	env := envelope{Kind: envForward, Client: client.ID, Msg: &msg}
+	env.Caps, env.Hello, env.Protocol = client.settled()
+	if err := s.publish(owner, env); err != nil {
+		client.logger(msg.Type).Error("Cannot forward message", "node", owner, "err", err)
+		sendError(client, msg, ErrCodeUnavailable, "room is unavailable")
+	}
+}
+
+// ownerOf finds the node that hosts, or will host, the room a join (or
+// resumeMatch) is for.
+// A room nobody owns yet is claimed here.
+func (s *Server) ownerOf(msg messages.ClientMessage) (string, error) {
+	c := s.cluster
+	switch {
+	case msg.Type == "resumeMatch":
+		return c.node, nil // Suspended matches come back wherever they are resumed
+	case msg.Code != "":
+		owner, ok, err := c.registry.Lookup("code:" + msg.Code)
+		if err != nil || !ok {
+			return c.node, err // Unknown codes are rejected here
+		}
+		return owner, nil
+	case msg.RoomID == "":
+		return c.node, nil // A new private room
+	case !validRoomID(msg.RoomID):
+		return c.node, nil // Rejected here
+	}
+	return c.registry.Claim("room:"+msg.RoomID, c.node)
+}
+
+// publish sends an envelope to another node
+func (s *Server) publish(node string, env envelope) error {
+	env.Node = s.cluster.node
+	data, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+	return s.cluster.bus.Publish(nodeTopic(node), data)
+}
+
+// handleEnvelope handles what other nodes publish to this one
+func (s *Server) handleEnvelope(data []byte) {
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		slog.Error("Bad envelope", "err", err)
+		return
+	}
+
+	switch env.Kind {
+	case envForward:
+		if env.Msg != nil {
+			s.dispatch(s.proxyFor(env), *env.Msg)
+		}
+	case envLeave:
+		if proxy := s.dropProxy(env.Node, env.Client); proxy != nil {
+			s.handleDisconnect(proxy)
+		}
+	case envDeliver:
+		var msg messages.ServerMessage
+		if err := json.Unmarshal(env.Out, &msg); err != nil {
+			slog.Error("Bad delivery", "client", env.Client, "node", env.Node, "err", err)
+			return
+		}
+		if client := s.onlineClient(env.Client); client != nil {
+			client.SendJSON(msg)
+		}
+	default:
+		slog.Warn("Unknown envelope kind", "kind", env.Kind, "node", env.Node)
+	}
+}
+
+// proxyFor returns the stand-in for the client a forward envelope is from,
+// creating it on first use. It takes on the capabilities and protocol
+// version the client settled on its home node, so the room sends it what
+// the client can handle.
+func (s *Server) proxyFor(env envelope) *Client {
+	c := s.cluster
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := env.Node + "/" + env.Client
+	proxy, ok := c.proxies[key]
+	if !ok {
+		proxy = &Client{
+			ID:    env.Client,
+			Conn:  &busConn{server: s, node: env.Node, client: env.Client},
+			codec: messages.JSON,
+		}
+		c.proxies[key] = proxy
+	}
+	caps, _ := enableCaps(env.Caps)
+	proxy.mu.Lock()
+	proxy.caps, proxy.hello = caps, env.Hello
+	proxy.version = min(env.Protocol, messages.ProtocolVersion)
+	proxy.mu.Unlock()
+	return proxy
+}
+
+// dropProxy forgets the stand-in for a client homed on another node
+func (s *Server) dropProxy(node, clientID string) *Client {
+	c := s.cluster
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := node + "/" + clientID
+	proxy := c.proxies[key]
+	delete(c.proxies, key)
+	return proxy
+}
+
+// leaveRemote tells the node hosting a disconnected client's room, if that
+// is another node, that the client is gone
+func (s *Server) leaveRemote(client *Client) {
+	if owner := client.remoteNode(); owner != "" {
+		client.setRemote("")
+		if err := s.publish(owner, envelope{Kind: envLeave, Client: client.ID}); err != nil {
+			client.logger("").Error("Cannot tell node the client left", "node", owner, "err", err)
+		}
+	}
+}
+
+// remoteNode returns the node hosting the client's room, or "" if it is
+// hosted here
+func (c *Client) remoteNode() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.remote
+}
+
+// settled returns the capabilities, hello and protocol version the client
+// settled on, to hand to its proxy on the node hosting its room
+func (c *Client) settled() (caps []string, hello bool, version int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for capability, on := range c.caps {
+		if on {
+			caps = append(caps, capability)
+		}
+	}
+	slices.Sort(caps)
+	return caps, c.hello, c.version
+}
+
+func (c *Client) setRemote(node string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.remote = node
+}
+
+// busConn is the connection of a proxy client: whatever the room sends it
+// is published to the client's home node
+type busConn struct {
+	server *Server
+	node   string
+	client string
+}
+
+func (c *busConn) ReadMessage() (int, []byte, error) {
+	return 0, nil, io.EOF
+}
+
+func (c *busConn) WriteMessage(_ int, data []byte) error {
+	return c.server.publish(c.node, envelope{Kind: envDeliver, Client: c.client, Out: data})
+}
+
+func (c *busConn) Close() error {
+	return nil
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"log/slog"
+
+	"github.com/google/uuid"
+	"labyrinth-duel/websocket/internal/daily"
+	"labyrinth-duel/websocket/internal/messages"
+	"labyrinth-duel/websocket/internal/room"
+)
+
+// handleStartDaily opens a room of the client's own on today's daily
+// challenge maze and puts them in it
+func (s *Server) handleStartDaily(client *Client, msg messages.ClientMessage) {
+	day := daily.Day(s.clock.Now())
+	r, _ := s.rooms.GetOrCreateRoom("daily-"+uuid.New().String()[:8], room.Options{
+		Maze:   daily.MazeOptions(day),
+		Rules:  room.RuleSet{Daily: day},
+		Access: room.Access{Private: true},
+	})
+	client.logger(msg.Type).Info("Starting daily challenge", "room", r.ID, "day", day)
+	s.joinRoom(client, r, msg, r.JoinCode)
+}
+
+// handleDailyLeaderboard sends the leaderboard of the day asked for, or of
+// today
+func (s *Server) handleDailyLeaderboard(client *Client, msg messages.ClientMessage) {
+	day := msg.Day
+	if day == "" {
+		day = daily.Day(s.clock.Now())
+	}
+	if !daily.ValidDay(day) {
+		sendError(client, msg, ErrCodeBadRequest, "day must look like YYYY-MM-DD")
+		return
+	}
+	board, err := s.dailyBoard(day, client.ID)
+	if err != nil {
+		sendError(client, msg, ErrCodeBadRequest, err.Error())
+		return
+	}
+	client.SendJSON(messages.ServerMessage{Type: "dailyLeaderboard", Daily: board})
+}
+
+// recordDaily enters the winner of a finished daily challenge on its day's
+// leaderboard, and sends them where it put them. Runs out of time and
+// players who left before the end count for nothing.
+func (s *Server) recordDaily(rec *room.MatchRecord) {
+	if rec.Reason != "goal" || rec.Winner == "" {
+		return
+	}
+	entry := daily.Entry{
+		PlayerID: rec.Winner,
+		Name:     s.profiles.Get(rec.Winner).Name,
+		Time:     solveTime(rec, rec.Winner),
+		At:       rec.EndedAt,
+	}
+	improved, err := s.Daily.Record(rec.Daily, entry)
+	if err != nil {
+		slog.Error("Cannot record daily challenge time", "day", rec.Daily, "err", err)
+		return
+	}
+
+	client := s.onlineClient(rec.Winner)
+	if client == nil {
+		return
+	}
+	board, err := s.dailyBoard(rec.Daily, rec.Winner)
+	if err != nil {
+		slog.Error("Cannot load daily leaderboard", "day", rec.Daily, "err", err)
+		return
+	}
+	board.Improved = improved
+	client.SendJSON(messages.ServerMessage{Type: "dailyLeaderboard", Daily: board})
+}
+
+// dailyBoard builds a day's leaderboard as seen by a player
+func (s *Server) dailyBoard(day, playerID string) (*messages.DailyBoard, error) {
+	rankings, err := s.Daily.Rankings(day)
+	if err != nil {
+		return nil, err
+	}
+	board := &messages.DailyBoard{Day: day, Seed: daily.Seed(day), Entries: []messages.DailyEntry{}}
+	for i, e := range rankings {
+		entry := messages.DailyEntry{Rank: i + 1, PlayerID: e.PlayerID, Name: e.Name, TimeMs: e.Time.Milliseconds()}
+		if i < daily.LeaderboardSize {
+			board.Entries = append(board.Entries, entry)
+		}
+		if e.PlayerID == playerID {
+			board.You = &entry
+		}
+	}
+	return board, nil
+}
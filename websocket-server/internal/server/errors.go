@@ -0,0 +1,145 @@
+package server
+
+import (
+	"fmt"
+
+	"labyrinth-duel/websocket/internal/level"
+	"labyrinth-duel/websocket/internal/messages"
+	"labyrinth-duel/websocket/internal/room"
+)
+
+// Error codes sent in the error field of error and *Rejected messages
+const (
+	ErrCodeBadRequest       = "BAD_REQUEST"    // Malformed, unknown or invalid message
+	ErrCodeNotInRoom        = "NOT_IN_ROOM"    // Needs a room the client hasn't joined
+	ErrCodeInvalidMove      = "INVALID_MOVE"   // Move blocked by a wall or player, or not adjacent
+	ErrCodeTooFast          = "TOO_FAST"       // Moved again before the player's speed allows, or while frozen
+	ErrCodeInvalidAction    = "INVALID_ACTION" // Ready, item or wall break not allowed right now
+	ErrCodeRoomFull         = "ROOM_FULL"
+	ErrCodeBadCode          = "BAD_CODE"          // Unknown or missing private room code
+	ErrCodeBadPassword      = "BAD_PASSWORD"      // Wrong room password
+	ErrCodeRateLimited      = "RATE_LIMITED"      // Sending too fast
+	ErrCodeModeInactive     = "MODE_INACTIVE"     // Game mode message for a mode the room isn't playing
+	ErrCodeNotHost          = "NOT_HOST"          // Only the room host may do that
+	ErrCodeNotAdmin         = "NOT_ADMIN"         // Only players with the admin role may do that
+	ErrCodeKicked           = "KICKED"            // Voted out of, or banned from, the room being joined
+	ErrCodeRoomLocked       = "ROOM_LOCKED"       // The host locked the room being joined
+	ErrCodeNotFound         = "NOT_FOUND"         // No such replay, room or level
+	ErrCodeUnavailable      = "UNAVAILABLE"       // The node hosting the room can't be reached
+	ErrCodeCooldown         = "COOLDOWN"          // Barred from matchmaking for a while after declining or abandoning matches
+	ErrCodeAlreadyConnected = "ALREADY_CONNECTED" // A verified player connected twice
+	ErrCodeUnsupported      = "UNSUPPORTED"       // The room needs a capability the client said hello without
+	ErrCodeOldProtocol      = "OLD_PROTOCOL"      // The client speaks a protocol version the server no longer serves
+)
+
+// sendError tells the client a request failed, echoing its requestId
+func sendError(client *Client, req messages.ClientMessage, code, detail string) {
+	client.SendJSON(messages.ServerMessage{
+		Type:      "error",
+		Error:     code,
+		RequestID: req.RequestID,
+		Message:   detail,
+	})
+}
+
+// validate checks a raw client message against its schema, sending
+// BAD_REQUEST with every problem found if it doesn't conform. Frames that
+// don't decode at all are left for the caller to report.
+func (s *Server) validate(client *Client, data []byte) bool {
+	var raw interface{}
+	if err := client.codec.Decode(data, &raw); err != nil {
+		return true
+	}
+	fields := s.validator.ValidateClient(raw)
+	if len(fields) == 0 {
+		return true
+	}
+
+	var req messages.ClientMessage
+	if obj, ok := raw.(map[string]interface{}); ok {
+		req.Type, _ = obj["type"].(string)
+		req.RequestID, _ = obj["requestId"].(string)
+	}
+	detail := fields[0].Message
+	if fields[0].Field != "" {
+		detail = fields[0].Field + ": " + detail
+	}
+	if len(fields) > 1 {
+		detail += fmt.Sprintf(" (and %d more)", len(fields)-1)
+	}
+	client.logger(req.Type).Info("Invalid message", "detail", detail)
+	client.SendJSON(messages.ServerMessage{
+		Type:      "error",
+		Error:     ErrCodeBadRequest,
+		RequestID: req.RequestID,
+		Message:   "invalid message: " + detail,
+		Fields:    fields,
+	})
+	return false
+}
+
+// roomOf returns the room the client is in, or sends NOT_IN_ROOM
+func (s *Server) roomOf(client *Client, req messages.ClientMessage) *room.Room {
+	if roomID := client.currentRoom(); roomID != "" {
+		if r := s.rooms.GetRoom(roomID); r != nil {
+			return r
+		}
+	}
+	sendError(client, req, ErrCodeNotInRoom, req.Type+" needs a room")
+	return nil
+}
+
+// modeErrorCode maps a game mode routing or handler error to its code
+func modeErrorCode(err error) string {
+	switch err {
+	case room.ErrUnknownMode, room.ErrUnknownAction:
+		return ErrCodeBadRequest
+	case room.ErrModeInactive:
+		return ErrCodeModeInactive
+	case room.ErrNoPlayer:
+		return ErrCodeNotInRoom
+	}
+	return ErrCodeInvalidAction
+}
+
+// moveErrorCode maps a rejected move's error to its code
+func moveErrorCode(err error) string {
+	switch err {
+	case room.ErrTooFast, room.ErrFrozen:
+		return ErrCodeTooFast
+	case room.ErrNoPlayer:
+		return ErrCodeNotInRoom
+	}
+	return ErrCodeInvalidMove
+}
+
+// errorCode maps a room error to its protocol error code
+func errorCode(err error) string {
+	switch err {
+	case room.ErrRoomFull:
+		return ErrCodeRoomFull
+	case room.ErrBadCode:
+		return ErrCodeBadCode
+	case room.ErrBadPassword:
+		return ErrCodeBadPassword
+	case room.ErrChatRateLimited, room.ErrEmoteCooldown:
+		return ErrCodeRateLimited
+	case room.ErrNotHost, room.ErrPauseHostOnly:
+		return ErrCodeNotHost
+	case room.ErrKicked:
+		return ErrCodeKicked
+	case room.ErrRoomLocked:
+		return ErrCodeRoomLocked
+	case room.ErrNeedsFog, room.ErrNeedsFloors:
+		return ErrCodeUnsupported
+	case level.ErrNotFound, room.ErrRoomClosed:
+		return ErrCodeNotFound
+	case room.ErrNotInLobby, room.ErrNoVote, room.ErrVoteInProgress, room.ErrVoteNotNow,
+		room.ErrNotPractice, room.ErrNotPlaying, room.ErrNoEffect, room.ErrCellTaken,
+		room.ErrNoVeto, room.ErrNotYourTurn, room.ErrNoReview, room.ErrNotReviewing,
+		room.ErrNotWatchable, room.ErrBadSpeed, room.ErrNoFlag, room.ErrKickSelf, room.ErrHostBot,
+		room.ErrNotBetweenRound, room.ErrNotPausable, room.ErrNoPausesLeft, room.ErrNotPaused, room.ErrInMatch:
+		return ErrCodeInvalidAction
+	}
+	return ErrCodeBadRequest
+}
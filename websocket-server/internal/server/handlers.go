@@ -0,0 +1,806 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"labyrinth-duel/websocket/internal/auth"
+	"labyrinth-duel/websocket/internal/game"
+	"labyrinth-duel/websocket/internal/level"
+	"labyrinth-duel/websocket/internal/messages"
+	"labyrinth-duel/websocket/internal/profile"
+	"labyrinth-duel/websocket/internal/room"
+)
+
+func (s *Server) handleJoin(client *Client, msg messages.ClientMessage) {
+	if msg.Code != "" {
+		s.joinByCode(client, msg)
+		return
+	}
+
+	if msg.RoomID == "" && (msg.Private || msg.LevelID != "") {
+		msg.RoomID = uuid.New().String()[:8]
+	}
+	if !validRoomID(msg.RoomID) {
+		sendError(client, msg, ErrCodeBadRequest, "room IDs are 1 to 64 letters, digits, dashes and underscores")
+		return
+	}
+
+	var lvl *level.Level
+	if msg.LevelID != "" {
+		var err error
+		if lvl, err = s.Levels.Get(msg.LevelID); err != nil {
+			rejectJoin(client, msg, err)
+			return
+		}
+	}
+
+	if msg.MazeAlgorithm != "" {
+		if _, ok := game.GeneratorByName(msg.MazeAlgorithm); !ok {
+			client.logger(msg.Type).Warn("Unknown maze algorithm",
+				"algorithm", msg.MazeAlgorithm, "using", game.DefaultAlgorithm)
+		}
+	}
+
+	if msg.Difficulty != "" && !game.IsDifficulty(msg.Difficulty) {
+		client.logger(msg.Type).Warn("Unknown maze difficulty, rating mazes any tier", "difficulty", msg.Difficulty)
+	}
+
+	if msg.Mode != "" && !knownMode(msg.Mode) {
+		client.logger(msg.Type).Warn("Unknown game mode, using the classic race", "mode", msg.Mode)
+	}
+
+	opts := room.Options{
+		Maze: game.Options{
+			Seed:            msg.Seed,
+			Algorithm:       msg.MazeAlgorithm,
+			Theme:           msg.Theme,
+			LoopFactor:      msg.LoopFactor,
+			TerrainDensity:  msg.TerrainDensity,
+			CrossingDensity: msg.CrossingDensity,
+			Floors:          msg.Floors,
+			MinPathRatio:    msg.MinPathRatio,
+			GoalCount:       msg.GoalCount,
+			Difficulty:      msg.Difficulty,
+		},
+		Rules: room.RuleSet{
+			DeadEnds:          msg.DeadEnds,
+			DeadEndCount:      msg.DeadEndCount,
+			GoalMode:          msg.GoalMode,
+			WallCharges:       msg.WallCharges,
+			Collision:         msg.Collision,
+			Fog:               msg.Fog,
+			FogRadius:         msg.FogRadius,
+			Rounds:            msg.Rounds,
+			Mode:              msg.Mode,
+			Teams:             msg.Teams,
+			TeamGoal:          msg.TeamGoal,
+			Coop:              msg.Coop,
+			Hints:             msg.Hints,
+			MoveInterval:      time.Duration(msg.MoveIntervalMs) * time.Millisecond,
+			Practice:          msg.Practice,
+			MapVeto:           msg.MapVeto,
+			GoalMoveInterval:  time.Duration(msg.GoalMoveSeconds) * time.Second,
+			MazeShiftInterval: time.Duration(msg.ShiftSeconds) * time.Second,
+			Minotaur:          msg.Minotaur,
+			AntiCamp:          msg.AntiCamp,
+			CampWindow:        time.Duration(msg.CampSeconds) * time.Second,
+			CampRadius:        msg.CampRadius,
+		},
+		Access: room.Access{
+			Private:    msg.Private,
+			Password:   msg.Password,
+			MaxPlayers: msg.MaxPlayers,
+		},
+		Level: lvl,
+	}
+
+	for {
+		// Get or create room (creates maze if new)
+		r, created := s.rooms.GetOrCreateRoom(msg.RoomID, opts)
+
+		// The creator of a private room is handed its code rather than knowing it
+		code := msg.Code
+		if created {
+			code = r.JoinCode
+		}
+		// A room closed as it emptied can't be joined, but one opened by
+		// its ID in its place can
+		err := s.admit(client, r, msg, code)
+		if err == nil {
+			return
+		}
+		if err != room.ErrRoomClosed {
+			rejectJoin(client, msg, err)
+			return
+		}
+	}
+}
+
+// joinByCode joins the private room a join code belongs to
+func (s *Server) joinByCode(client *Client, msg messages.ClientMessage) {
+	r := s.rooms.GetRoomByCode(msg.Code)
+	if r == nil {
+		rejectJoin(client, msg, room.ErrBadCode)
+		return
+	}
+	s.joinRoom(client, r, msg, msg.Code)
+}
+
+// handleStartTutorial opens a tutorial room of the client's own and puts
+// them in it
+func (s *Server) handleStartTutorial(client *Client, msg messages.ClientMessage) {
+	r, _ := s.rooms.GetOrCreateRoom("tutorial-"+uuid.New().String()[:8], room.Options{
+		Maze:   game.Options{Seed: msg.Seed},
+		Rules:  room.RuleSet{Tutorial: true},
+		Access: room.Access{Private: true},
+	})
+	client.logger(msg.Type).Info("Starting tutorial", "room", r.ID)
+	s.joinRoom(client, r, msg, r.JoinCode)
+}
+
+// joinRoom admits the client to a room and sends them the maze, or tells
+// them why not. code is the room's join code, which req only carries when
+// joining by code.
+func (s *Server) joinRoom(client *Client, r *room.Room, req messages.ClientMessage, code string) {
+	if err := s.admit(client, r, req, code); err != nil {
+		rejectJoin(client, req, err)
+	}
+}
+
+// admit is joinRoom, returning why the client was turned away instead of
+// telling them
+func (s *Server) admit(client *Client, r *room.Room, req messages.ClientMessage, code string) error {
+	// Add player to room on a spawn point
+	if err := r.Join(client.ID, client, s.lookOf(client.ID), code, req.Password); err != nil {
+		return err
+	}
+	s.enterRoom(client, r)
+	return nil
+}
+
+// enterRoom catches a client up on the room they were just admitted to and
+// introduces them to the others
+func (s *Server) enterRoom(client *Client, r *room.Room) {
+	s.stopSpectating(client)
+	// A client plays in one room at a time, so joining another leaves the
+	// last
+	if client.currentRoom() != r.ID {
+		s.leaveRoom(client)
+	}
+	client.setRoom(r.ID)
+	s.matchmaker.Cancel(client.ID)
+
+	client.logger("").Info("Joined room")
+
+	// Convert maze to message format (only the visible part under fog)
+	mazeData, visible := r.MazeDataFor(client.ID)
+
+	// Send maze to the joining player
+	client.SendJSON(messages.ServerMessage{
+		Type:    "mazeData",
+		Maze:    mazeData,
+		Cells:   visible,
+		Players: r.GetPlayers(),
+		State:   string(r.GetState()),
+		Items:   r.GetItems(),
+		Flags:   r.GetFlags(),
+		Hazards: r.GetHazards(),
+		Host:    r.GetHost(),
+		Code:    r.JoinCode,
+	})
+
+	// Catch the newcomer up on the conversation
+	if history := r.ChatHistory(client.ID); len(history) > 0 {
+		client.SendJSON(messages.ServerMessage{
+			Type: "chatHistory",
+			Chat: history,
+		})
+	}
+
+	// ...and on the match review, if one is open
+	r.CatchUpReview(client.ID)
+
+	// Notify other players in room
+	r.Broadcast(messages.ServerMessage{
+		Type:    "playerJoined",
+		Message: client.ID,
+		Players: r.GetPlayers(),
+	}, client.ID) // Exclude the joining player
+}
+
+// rejectJoin tells the client why they could not join
+func rejectJoin(client *Client, req messages.ClientMessage, err error) {
+	reason := "badCode"
+	switch err {
+	case room.ErrBadPassword:
+		reason = "badPassword"
+	case room.ErrRoomFull:
+		reason = "roomFull"
+	case room.ErrRoomLocked:
+		reason = "roomLocked"
+	case room.ErrNeedsFog, room.ErrNeedsFloors:
+		reason = "unsupported"
+	case room.ErrRoomClosed:
+		reason = "roomClosed"
+	case room.ErrInMatch:
+		reason = "inMatch"
+	case level.ErrNotFound:
+		reason = "noLevel"
+	}
+
+	client.logger(req.Type).Info("Join rejected", "err", err)
+	client.SendJSON(messages.ServerMessage{
+		Type:      "joinRejected",
+		Reason:    reason,
+		Error:     errorCode(err),
+		RequestID: req.RequestID,
+		Message:   err.Error(),
+	})
+}
+
+func (s *Server) handleReady(client *Client, msg messages.ClientMessage) {
+	r := s.roomOf(client, msg)
+	if r == nil {
+		return
+	}
+
+	if !r.SetReady(client.ID) {
+		client.logger(msg.Type).Debug("Cannot ready up")
+		sendError(client, msg, ErrCodeInvalidAction, "cannot ready up now")
+		return
+	}
+
+	client.logger(msg.Type).Info("Ready")
+}
+
+func (s *Server) handleMove(client *Client, msg messages.ClientMessage) {
+	r := s.roomOf(client, msg)
+	if r == nil {
+		return
+	}
+
+	// Validate and update position (server validates against maze!)
+	to := game.Point{X: msg.X, Y: msg.Y, Z: msg.Z}
+	if err := r.UpdatePlayerPosition(client.ID, to.X, to.Y, to.Z); err != nil {
+		client.logger(msg.Type).Debug("Invalid move", "x", msg.X, "y", msg.Y, "z", msg.Z, "err", err)
+		sendError(client, msg, moveErrorCode(err), fmt.Sprintf("cannot move to %v: %v", to, err))
+		return
+	}
+
+	client.logger(msg.Type).Debug("Moved", "x", msg.X, "y", msg.Y, "z", msg.Z)
+	announceMove(r, client)
+}
+
+// handleMoveDir steps the client's player in a direction from where the
+// server has them, so a client that missed an update can't ask for a cell
+// it isn't next to
+func (s *Server) handleMoveDir(client *Client, msg messages.ClientMessage) {
+	r := s.roomOf(client, msg)
+	if r == nil {
+		return
+	}
+
+	if err := r.MovePlayerDir(client.ID, msg.Direction); err != nil {
+		client.logger(msg.Type).Debug("Invalid move", "direction", msg.Direction, "err", err)
+		code := moveErrorCode(err)
+		if err == room.ErrBadDirection {
+			code = ErrCodeBadRequest
+		}
+		sendError(client, msg, code, fmt.Sprintf("cannot move %s: %v", msg.Direction, err))
+		return
+	}
+
+	client.logger(msg.Type).Debug("Moved", "direction", msg.Direction)
+	announceMove(r, client)
+}
+
+// announceMove tells the room where the client's player moved to
+func announceMove(r *room.Room, client *Client) {
+	// Broadcast to all players in room
+	r.BroadcastMove(client.ID)
+
+	// Let nearby opponents hear the movement through the fog
+	r.EmitFootsteps(client.ID)
+}
+
+func (s *Server) handleUseItem(client *Client, msg messages.ClientMessage) {
+	r := s.roomOf(client, msg)
+	if r == nil {
+		return
+	}
+
+	if !r.UseItem(client.ID, msg.Item, msg.Direction) {
+		client.logger(msg.Type).Debug("Cannot use item", "item", msg.Item)
+		sendError(client, msg, ErrCodeInvalidAction, "cannot use "+msg.Item)
+		return
+	}
+
+	client.logger(msg.Type).Debug("Used item", "item", msg.Item)
+}
+
+func (s *Server) handleBreakWall(client *Client, msg messages.ClientMessage) {
+	r := s.roomOf(client, msg)
+	if r == nil {
+		return
+	}
+
+	if !r.BreakWall(client.ID, msg.Direction) {
+		client.logger(msg.Type).Debug("Cannot break wall", "direction", msg.Direction)
+		sendError(client, msg, ErrCodeInvalidAction, "cannot break the "+msg.Direction+" wall")
+		return
+	}
+
+	client.logger(msg.Type).Debug("Broke wall", "direction", msg.Direction)
+}
+
+func (s *Server) handleResync(client *Client, msg messages.ClientMessage) {
+	r := s.roomOf(client, msg)
+	if r == nil {
+		return
+	}
+
+	if r.Resync(client.ID) {
+		client.logger(msg.Type).Debug("Resynced")
+	}
+}
+
+func (s *Server) handleRequestSnapshot(client *Client, msg messages.ClientMessage) {
+	r := s.roomOf(client, msg)
+	if r == nil {
+		return
+	}
+
+	if r.RequestSnapshot(client.ID, msg.SinceVersion) {
+		client.logger(msg.Type).Debug("Sent snapshot", "since", msg.SinceVersion)
+	}
+}
+
+func (s *Server) handleUpdateProfile(client *Client, msg messages.ClientMessage) {
+	// A name from the player's token is theirs to keep
+	name := msg.Name
+	if client.claims != nil && client.claims.Name != "" {
+		name = ""
+	}
+	if _, err := s.profiles.Update(client.ID, name, msg.Color, msg.Avatar); err != nil {
+		client.SendJSON(messages.ServerMessage{
+			Type:      "profileRejected",
+			Error:     ErrCodeBadRequest,
+			RequestID: msg.RequestID,
+			Message:   err.Error(),
+		})
+		return
+	}
+
+	client.SendJSON(messages.ServerMessage{
+		Type:    "profile",
+		Profile: s.profileMessage(client.ID),
+	})
+
+	if roomID := client.currentRoom(); roomID != "" {
+		if r := s.rooms.GetRoom(roomID); r != nil {
+			r.SetProfile(client.ID, s.lookOf(client.ID))
+		}
+	}
+	client.logger(msg.Type).Info("Updated profile")
+}
+
+func (s *Server) handleChat(client *Client, msg messages.ClientMessage) {
+	r := s.roomOf(client, msg)
+	if r == nil {
+		return
+	}
+
+	if err := r.Chat(client.ID, msg.Channel, msg.Text); err != nil {
+		client.SendJSON(messages.ServerMessage{
+			Type:      "chatRejected",
+			Error:     errorCode(err),
+			RequestID: msg.RequestID,
+			Message:   err.Error(),
+		})
+	}
+}
+
+func (s *Server) handleEmote(client *Client, msg messages.ClientMessage) {
+	r := s.roomOf(client, msg)
+	if r == nil {
+		return
+	}
+
+	if err := r.Emote(client.ID, msg.Emote); err != nil {
+		client.SendJSON(messages.ServerMessage{
+			Type:      "emoteRejected",
+			Emote:     msg.Emote,
+			Error:     errorCode(err),
+			RequestID: msg.RequestID,
+			Message:   err.Error(),
+		})
+	}
+}
+
+func (s *Server) handleVisibility(client *Client, msg messages.ClientMessage) {
+	r := s.roomOf(client, msg)
+	if r == nil {
+		return
+	}
+
+	if r.SetAway(client.ID, msg.Hidden) {
+		client.logger(msg.Type).Debug("Visibility changed", "away", msg.Hidden)
+	}
+}
+
+// handleAddBot seats a server-controlled opponent in the client's room
+func (s *Server) handleAddBot(client *Client, msg messages.ClientMessage) {
+	r := s.roomOf(client, msg)
+	if r == nil {
+		return
+	}
+
+	id, err := r.AddBot(msg.Difficulty)
+	if err != nil {
+		sendError(client, msg, errorCode(err), err.Error())
+		return
+	}
+	client.logger(msg.Type).Info("Added bot", "bot", id)
+}
+
+// handleSandbox runs a practice room command: teleport, reveal or spawnItem
+func (s *Server) handleSandbox(client *Client, msg messages.ClientMessage) {
+	r := s.roomOf(client, msg)
+	if r == nil {
+		return
+	}
+
+	if err := r.Sandbox(client.ID, msg.Command, game.Point{X: msg.X, Y: msg.Y, Z: msg.Z}, msg.Item, msg.Speed); err != nil {
+		sendError(client, msg, errorCode(err), err.Error())
+		return
+	}
+	client.logger(msg.Type).Debug("Ran sandbox command", "command", msg.Command)
+}
+
+// handleReview steps through a finished ranked match with the room:
+// start, next, prev or stop
+func (s *Server) handleReview(client *Client, msg messages.ClientMessage) {
+	r := s.roomOf(client, msg)
+	if r == nil {
+		return
+	}
+
+	if err := r.Review(client.ID, msg.Command); err != nil {
+		sendError(client, msg, errorCode(err), err.Error())
+	}
+}
+
+// handleVeto strikes a candidate maze on the client's veto turn
+func (s *Server) handleVeto(client *Client, msg messages.ClientMessage) {
+	r := s.roomOf(client, msg)
+	if r == nil {
+		return
+	}
+
+	if err := r.Veto(client.ID, msg.Seed); err != nil {
+		sendError(client, msg, errorCode(err), err.Error())
+	}
+}
+
+func (s *Server) handleStartVote(client *Client, msg messages.ClientMessage) {
+	r := s.roomOf(client, msg)
+	if r == nil {
+		return
+	}
+
+	if err := r.StartVote(client.ID, msg.VoteKind, msg.PlayerID, msg.Size); err != nil {
+		sendError(client, msg, errorCode(err), err.Error())
+		return
+	}
+	client.logger(msg.Type).Info("Started vote", "kind", msg.VoteKind)
+}
+
+func (s *Server) handleCastVote(client *Client, msg messages.ClientMessage) {
+	r := s.roomOf(client, msg)
+	if r == nil {
+		return
+	}
+
+	if err := r.CastVote(client.ID, msg.Yes); err != nil {
+		sendError(client, msg, errorCode(err), err.Error())
+	}
+}
+
+// handleSetTeam lets the room host move a player between teams
+func (s *Server) handleSetTeam(client *Client, msg messages.ClientMessage) {
+	r := s.roomOf(client, msg)
+	if r == nil {
+		return
+	}
+
+	if err := r.SetTeam(client.ID, msg.PlayerID, msg.Team); err != nil {
+		sendError(client, msg, errorCode(err), err.Error())
+		return
+	}
+	client.logger(msg.Type).Info("Set team", "player", msg.PlayerID, "team", msg.Team)
+}
+
+func (s *Server) handleKick(client *Client, msg messages.ClientMessage) {
+	r := s.roomOf(client, msg)
+	if r == nil {
+		return
+	}
+
+	if err := r.HostKick(client.ID, msg.PlayerID, msg.Ban); err != nil {
+		sendError(client, msg, errorCode(err), err.Error())
+		return
+	}
+	client.logger(msg.Type).Info("Kicked player", "player", msg.PlayerID, "ban", msg.Ban)
+	s.closeIfEmpty(r)
+}
+
+func (s *Server) handleTransferHost(client *Client, msg messages.ClientMessage) {
+	r := s.roomOf(client, msg)
+	if r == nil {
+		return
+	}
+
+	if err := r.TransferHost(client.ID, msg.PlayerID); err != nil {
+		sendError(client, msg, errorCode(err), err.Error())
+		return
+	}
+	client.logger(msg.Type).Info("Handed over the room", "host", msg.PlayerID)
+}
+
+func (s *Server) handleLockRoom(client *Client, msg messages.ClientMessage) {
+	r := s.roomOf(client, msg)
+	if r == nil {
+		return
+	}
+
+	if err := r.SetLocked(client.ID, msg.Locked); err != nil {
+		sendError(client, msg, errorCode(err), err.Error())
+		return
+	}
+	client.logger(msg.Type).Info("Locked room", "locked", msg.Locked)
+}
+
+func (s *Server) handleMazeSettings(client *Client, msg messages.ClientMessage) {
+	r := s.roomOf(client, msg)
+	if r == nil {
+		return
+	}
+
+	err := r.SetMazeSettings(client.ID, game.Options{
+		Seed:            msg.Seed,
+		Algorithm:       msg.MazeAlgorithm,
+		Theme:           msg.Theme,
+		LoopFactor:      msg.LoopFactor,
+		TerrainDensity:  msg.TerrainDensity,
+		CrossingDensity: msg.CrossingDensity,
+		Floors:          msg.Floors,
+		MinPathRatio:    msg.MinPathRatio,
+		GoalCount:       msg.GoalCount,
+		Difficulty:      msg.Difficulty,
+	})
+	if err != nil {
+		sendError(client, msg, errorCode(err), err.Error())
+		return
+	}
+	client.logger(msg.Type).Info("Changed maze settings")
+}
+
+// handleLoadMaze puts a maze file into a room for players with the admin
+// role: the room named, or their own
+func (s *Server) handleLoadMaze(client *Client, msg messages.ClientMessage) {
+	if client.claims == nil || !client.claims.HasRole(auth.RoleAdmin) {
+		sendError(client, msg, ErrCodeNotAdmin, "loading a maze needs the admin role")
+		return
+	}
+	var r *room.Room
+	if msg.RoomID == "" {
+		if r = s.roomOf(client, msg); r == nil {
+			return
+		}
+	} else if r = s.rooms.GetRoom(msg.RoomID); r == nil {
+		sendError(client, msg, ErrCodeNotFound, "no room "+msg.RoomID)
+		return
+	}
+
+	maze, err := game.ImportMaze([]byte(msg.MazeFile), msg.Format)
+	if err == nil {
+		err = level.CheckPlayable(maze)
+	}
+	if err != nil {
+		sendError(client, msg, ErrCodeBadRequest, "bad maze file: "+err.Error())
+		return
+	}
+	if err := r.LoadMaze(maze); err != nil {
+		sendError(client, msg, errorCode(err), err.Error())
+		return
+	}
+	client.logger(msg.Type).Info("Loaded a maze", "room", r.ID, "width", maze.Width, "height", maze.Height)
+}
+
+// handlePause freezes the client's match, or unfreezes it for resume, if
+// they host the room; others vote to pause or resume
+func (s *Server) handlePause(client *Client, msg messages.ClientMessage) {
+	r := s.roomOf(client, msg)
+	if r == nil {
+		return
+	}
+
+	pause := r.Pause
+	if msg.Type == "resume" {
+		pause = r.Resume
+	}
+	if err := pause(client.ID); err != nil {
+		sendError(client, msg, errorCode(err), err.Error())
+		return
+	}
+	client.logger(msg.Type).Info("Paused match", "resumed", msg.Type == "resume")
+}
+
+func (s *Server) handleFindMatch(client *Client, msg messages.ClientMessage) {
+	if client.currentRoom() != "" {
+		client.logger(msg.Type).Debug("Already in a room")
+		return
+	}
+
+	if cooldown := s.profiles.Cooldown(client.ID, s.clock.Now()); cooldown > 0 {
+		client.logger(msg.Type).Info("Refused on matchmaking cooldown", "cooldown", cooldown)
+		client.SendJSON(messages.ServerMessage{
+			Type:      "queueRejected",
+			Reason:    "cooldown",
+			Error:     ErrCodeCooldown,
+			RequestID: msg.RequestID,
+			Seconds:   int(cooldown.Round(time.Second).Seconds()),
+		})
+		return
+	}
+
+	if s.matchmaker.Enqueue(client.ID, client, s.ratings.Get(client.ID)) {
+		client.logger(msg.Type).Info("Looking for a match")
+	}
+}
+
+func (s *Server) handleAcceptMatch(client *Client, msg messages.ClientMessage) {
+	if !s.matchmaker.Accept(client.ID) {
+		sendError(client, msg, ErrCodeInvalidAction, "no match to accept")
+		return
+	}
+	client.logger(msg.Type).Info("Accepted match")
+}
+
+func (s *Server) handleDeclineMatch(client *Client, msg messages.ClientMessage) {
+	if !s.matchmaker.Decline(client.ID) {
+		sendError(client, msg, ErrCodeInvalidAction, "no match to decline")
+		return
+	}
+	client.logger(msg.Type).Info("Declined match")
+}
+
+func (s *Server) handleCancelMatch(client *Client) {
+	if !s.matchmaker.Cancel(client.ID) {
+		return
+	}
+
+	client.logger("cancelMatch").Info("Left the match queue")
+	client.SendJSON(messages.ServerMessage{
+		Type: "queueCancelled",
+	})
+}
+
+func (s *Server) handleDisconnect(client *Client) {
+	client.logger("").Info("Disconnected")
+	s.stopSpectating(client)
+	s.matchmaker.Cancel(client.ID)
+	s.leaveRemote(client)
+	s.dropResumeRequests(client)
+
+	s.leaveRoom(client)
+}
+
+// leaveRoom takes the client out of the room they are in, if any, and
+// tells the players left behind
+func (s *Server) leaveRoom(client *Client) {
+	roomID := client.currentRoom()
+	if roomID == "" {
+		return
+	}
+	client.setRoom("")
+	r := s.rooms.GetRoom(roomID)
+	if r == nil {
+		return
+	}
+	if r.InRankedMatch(client.ID) {
+		s.penalize(client.ID, profile.OffenceAbandon)
+	}
+	r.RemovePlayer(client.ID)
+
+	// Notify remaining players
+	r.Broadcast(messages.ServerMessage{
+		Type:    "playerLeft",
+		Message: client.ID,
+		Players: r.GetPlayers(),
+	}, "")
+
+	s.closeIfEmpty(r)
+}
+
+// closeIfEmpty removes a room nobody is left in, once the room TTL has
+// passed without anyone joining it again
+func (s *Server) closeIfEmpty(r *room.Room) {
+	if !r.IsEmpty() {
+		return
+	}
+	ttl := s.rooms.Settings.RoomTTL
+	if ttl <= 0 {
+		s.rooms.RemoveIfEmpty(r, "empty")
+		return
+	}
+	s.clock.AfterFunc(ttl, func() { s.rooms.RemoveIfEmpty(r, "empty") })
+}
+
+// handleModeMessage routes a "<mode>.<action>" message to the room's game
+// mode
+func (s *Server) handleModeMessage(client *Client, msg messages.ClientMessage) {
+	r := s.roomOf(client, msg)
+	if r == nil {
+		return
+	}
+
+	if err := r.HandleModeMessage(client.ID, msg); err != nil {
+		client.logger(msg.Type).Debug("Mode message rejected", "err", err)
+		sendError(client, msg, modeErrorCode(err), err.Error())
+	}
+}
+
+// knownMode reports whether a game mode is registered
+func knownMode(name string) bool {
+	for _, mode := range room.Modes() {
+		if mode == name {
+			return true
+		}
+	}
+	return false
+}
+
+// handleSpectate lets a client outside any room watch a public one, such as
+// a demo match found in the room list, until they join a room or stop
+func (s *Server) handleSpectate(client *Client, msg messages.ClientMessage) {
+	if client.currentRoom() != "" {
+		sendError(client, msg, ErrCodeInvalidAction, "leave the room to spectate")
+		return
+	}
+	r := s.rooms.GetRoom(msg.RoomID)
+	if r == nil {
+		sendError(client, msg, ErrCodeNotFound, "no such room")
+		return
+	}
+
+	s.stopSpectating(client)
+	snapshot, err := r.Watch(client.ID, client)
+	if err != nil {
+		sendError(client, msg, errorCode(err), err.Error())
+		return
+	}
+	client.mu.Lock()
+	client.watched = r.ID
+	client.mu.Unlock()
+	snapshot.RequestID = msg.RequestID
+	client.SendJSON(snapshot)
+	client.logger(msg.Type).Info("Spectating", "watched", r.ID)
+}
+
+// stopSpectating unsubscribes the client from the room it is watching, if
+// any
+func (s *Server) stopSpectating(client *Client) {
+	client.mu.Lock()
+	roomID := client.watched
+	client.watched = ""
+	client.mu.Unlock()
+	if roomID == "" {
+		return
+	}
+	if r := s.rooms.GetRoom(roomID); r != nil {
+		r.Unwatch(client.ID)
+	}
+}
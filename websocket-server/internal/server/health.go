@@ -0,0 +1,105 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"time"
+)
+
+// DefaultDrainGrace is how long a draining server keeps serving after
+// /readyz turns false, so orchestrators and load balancers stop sending it
+// new players before it stops
+const DefaultDrainGrace = 5 * time.Second
+
+// healthStatus is what /healthz and /readyz report
+type healthStatus struct {
+	Status      string  `json:"status"` // ok, starting, draining or stopped
+	Ready       bool    `json:"ready"`
+	Draining    bool    `json:"draining"`
+	Uptime      float64 `json:"uptime"` // Seconds
+	Goroutines  int     `json:"goroutines"`
+	Connections int64   `json:"connections"`
+	Rooms       int     `json:"rooms"`
+}
+
+// Drain marks the server as shutting down: /readyz fails from now on while
+// connected players carry on. Call Stop once DrainGrace has passed.
+func (s *Server) Drain() {
+	s.draining.Store(true)
+}
+
+// DrainGrace is how long to wait between Drain and Stop
+func (s *Server) DrainGrace() time.Duration {
+	if s.config.DrainGrace > 0 {
+		return s.config.DrainGrace
+	}
+	return DefaultDrainGrace
+}
+
+// Draining reports whether Drain was called
+func (s *Server) Draining() bool {
+	return s.draining.Load()
+}
+
+// health reads the server's status
+func (s *Server) health() healthStatus {
+	m := s.metrics.Snapshot()
+	h := healthStatus{
+		Status:      "ok",
+		Draining:    s.Draining(),
+		Uptime:      m.Uptime,
+		Goroutines:  runtime.NumGoroutine(),
+		Connections: m.Connections,
+		Rooms:       len(s.rooms.All()),
+	}
+	select {
+	case <-s.done:
+		h.Status = "stopped"
+	default:
+		switch {
+		case h.Draining:
+			h.Status = "draining"
+		case !s.running.Load():
+			h.Status = "starting"
+		default:
+			h.Ready = true
+		}
+	}
+	return h
+}
+
+// HandleHealth serves GET /healthz: the process is up and answering. It
+// fails only once the server has stopped.
+func (s *Server) HandleHealth(w http.ResponseWriter, r *http.Request) {
+	h := s.health()
+	code := http.StatusOK
+	if h.Status == "stopped" {
+		code = http.StatusServiceUnavailable
+	}
+	writeHealth(w, r, code, h)
+}
+
+// HandleReady serves GET /readyz: the server has started and isn't
+// draining, so it should be sent new players
+func (s *Server) HandleReady(w http.ResponseWriter, r *http.Request) {
+	h := s.health()
+	code := http.StatusOK
+	if !h.Ready {
+		code = http.StatusServiceUnavailable
+	}
+	writeHealth(w, r, code, h)
+}
+
+func writeHealth(w http.ResponseWriter, r *http.Request, code int, h healthStatus) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	if r.Method == http.MethodGet {
+		json.NewEncoder(w).Encode(h)
+	}
+}
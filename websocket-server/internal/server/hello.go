@@ -0,0 +1,91 @@
+package server
+
+import (
+	"fmt"
+
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+// handleHello settles what the connection speaks from what the client
+// declares: its protocol version, the first of its encodings the server
+// has, and the capabilities both support. It replaces whatever the
+// connection URL asked for. The welcome answering it is the last message in
+// the old encoding.
+func (s *Server) handleHello(client *Client, msg messages.ClientMessage) {
+	if client.currentRoom() != "" {
+		sendError(client, msg, ErrCodeInvalidAction, "hello must come before joining a room")
+		return
+	}
+	version := max(msg.Protocol, messages.MinProtocolVersion)
+	if version < s.minProtocol() {
+		s.refuseProtocol(client, msg, version)
+		return
+	}
+	version = min(version, messages.ProtocolVersion)
+
+	client.mu.Lock()
+	codec := client.codec
+	client.mu.Unlock()
+	if len(msg.Encodings) > 0 {
+		codec = messages.JSON
+		for _, name := range msg.Encodings {
+			if c, ok := messages.CodecByName(name); ok && name != "" {
+				codec = c
+				break
+			}
+		}
+	}
+	caps, enabled := enableCaps(msg.Caps)
+
+	client.mu.Lock()
+	client.version = version
+	client.mu.Unlock()
+	client.SendJSON(messages.ServerMessage{
+		Type:      "welcome",
+		RequestID: msg.RequestID,
+		Protocol:  version,
+		Encoding:  codec.Name(),
+		Caps:      enabled,
+	})
+	client.mu.Lock()
+	client.codec = codec
+	client.caps = caps
+	client.hello = true
+	client.mu.Unlock()
+	client.logger(msg.Type).Info("Said hello", "protocol", version, "encoding", codec.Name(), "caps", enabled)
+}
+
+// minProtocol is the oldest protocol version clients may speak
+func (s *Server) minProtocol() int {
+	return max(s.MinProtocol, messages.MinProtocolVersion)
+}
+
+// settleProtocol takes a client that sends something other than hello
+// before saying which protocol version it speaks to speak the oldest, and
+// turns it away if that is no longer served. It reports whether the client
+// may carry on.
+func (s *Server) settleProtocol(client *Client, msg messages.ClientMessage) bool {
+	client.mu.Lock()
+	said := client.version != 0
+	client.mu.Unlock()
+	if said || msg.Type == "hello" {
+		return true
+	}
+	if messages.MinProtocolVersion < s.minProtocol() {
+		s.refuseProtocol(client, msg, messages.MinProtocolVersion)
+		return false
+	}
+	client.mu.Lock()
+	client.version = messages.MinProtocolVersion
+	client.mu.Unlock()
+	return true
+}
+
+// refuseProtocol tells a client its protocol version is too old and hangs
+// up
+func (s *Server) refuseProtocol(client *Client, req messages.ClientMessage, version int) {
+	client.logger(req.Type).Info("Refusing old protocol", "protocol", version)
+	sendError(client, req, ErrCodeOldProtocol, fmt.Sprintf(
+		"protocol version %d is no longer supported; update to version %d or later", version, s.minProtocol()))
+	client.Conn.Close()
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"log/slog"
+
+	"labyrinth-duel/websocket/internal/history"
+	"labyrinth-duel/websocket/internal/messages"
+	"labyrinth-duel/websocket/internal/room"
+)
+
+// saveHistory adds a finished match to the history of everyone in it
+func (s *Server) saveHistory(rec *room.MatchRecord) {
+	m := &history.Match{
+		RoomID:    rec.RoomID,
+		Mode:      rec.Mode,
+		Daily:     rec.Daily,
+		Seed:      rec.Seed,
+		StartedAt: rec.StartedAt,
+		Duration:  rec.EndedAt.Sub(rec.StartedAt),
+		Winner:    rec.Winner,
+		Reason:    rec.Reason,
+		Players:   make([]history.Participant, len(rec.Players)),
+	}
+	if rec.Replay != nil {
+		m.ReplayID = rec.Replay.ID
+	}
+	for i, p := range rec.Players {
+		m.Players[i] = history.Participant{
+			ID:    p.ID,
+			Name:  p.Name,
+			Team:  p.Team,
+			Score: p.Score,
+			Moves: rec.Moves[p.ID],
+			Won:   p.ID == rec.Winner || rec.WinningTeam > 0 && p.Team == rec.WinningTeam,
+			Bot:   p.Bot,
+		}
+	}
+	if err := s.History.Save(m); err != nil {
+		slog.Error("Cannot save match history", "room", rec.RoomID, "err", err)
+	}
+}
+
+// handleMatchHistory sends a page of a player's matches, the client's own
+// unless they name someone else
+func (s *Server) handleMatchHistory(client *Client, msg messages.ClientMessage) {
+	id := msg.PlayerID
+	if id == "" {
+		id = client.ID
+	}
+	matches, total, err := s.History.ForPlayer(id, msg.Offset, msg.Limit)
+	if err != nil {
+		sendError(client, msg, ErrCodeBadRequest, err.Error())
+		return
+	}
+
+	h := &messages.MatchHistory{PlayerID: id, Offset: max(msg.Offset, 0), Total: total, Matches: make([]messages.MatchSummary, len(matches))}
+	for i, m := range matches {
+		summary := messages.MatchSummary{
+			ReplayID:  m.ReplayID,
+			RoomID:    m.RoomID,
+			Mode:      m.Mode,
+			Daily:     m.Daily,
+			Seed:      m.Seed,
+			StartedAt: m.StartedAt.UnixMilli(),
+			Duration:  m.Duration.Seconds(),
+			Winner:    m.Winner,
+			Reason:    m.Reason,
+			Players:   make([]messages.MatchPlayer, len(m.Players)),
+		}
+		for j, p := range m.Players {
+			summary.Players[j] = messages.MatchPlayer(p)
+		}
+		h.Matches[i] = summary
+	}
+	client.SendJSON(messages.ServerMessage{Type: "matchHistory", History: h})
+}
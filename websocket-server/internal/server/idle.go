@@ -0,0 +1,32 @@
+package server
+
+import (
+	"log/slog"
+
+	"labyrinth-duel/websocket/internal/room"
+)
+
+// idleExempt are the message types clients send on their own, which don't
+// show the player is still there
+var idleExempt = map[string]bool{
+	"visibility":      true,
+	"resync":          true,
+	"requestSnapshot": true,
+}
+
+// markActive tells the client's room that its player did something
+func (s *Server) markActive(client *Client) {
+	if roomID := client.currentRoom(); roomID != "" {
+		if r := s.rooms.GetRoom(roomID); r != nil {
+			r.MarkActive(client.ID)
+		}
+	}
+}
+
+// idleRemoved closes the room an idle player was removed from if they were
+// the last one in it
+func (s *Server) idleRemoved(r *room.Room, playerID string) {
+	slog.Info("Removed idle player", "room", r.ID, "client", playerID)
+	// The room is locked until this returns
+	go s.closeIfEmpty(r)
+}
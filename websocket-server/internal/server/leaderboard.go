@@ -0,0 +1,129 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"labyrinth-duel/websocket/internal/leaderboard"
+	"labyrinth-duel/websocket/internal/messages"
+	"labyrinth-duel/websocket/internal/room"
+)
+
+// LeaderboardInterval is how often clients in the lobby are sent the top of
+// the overall wins board
+const LeaderboardInterval = 30 * time.Second
+
+// recordLeaderboards adds a finished match to the leaderboards of its mode.
+// ratings are the players' new overall ratings, nil if it wasn't rated.
+func (s *Server) recordLeaderboards(rec *room.MatchRecord, ratings map[string]int) {
+	mode := rec.Mode
+	if mode == "" {
+		mode = leaderboard.ModeClassic
+	}
+	var results []leaderboard.Result
+	for _, p := range rec.Players {
+		if p.Bot {
+			continue
+		}
+		results = append(results, leaderboard.Result{
+			PlayerID: p.ID,
+			Name:     p.Name,
+			Won:      p.ID == rec.Winner || rec.WinningTeam > 0 && p.Team == rec.WinningTeam,
+			Time:     solveTime(rec, p.ID),
+			Rating:   ratings[p.ID],
+		})
+	}
+	if err := s.Leaderboards.Record(mode, results, ratings != nil); err != nil {
+		slog.Error("Cannot update leaderboards", "mode", mode, "err", err)
+	}
+}
+
+// handleLeaderboard sends the page of a leaderboard the client asked for
+func (s *Server) handleLeaderboard(client *Client, msg messages.ClientMessage) {
+	page, err := s.leaderboardPage(msg.Board, msg.Mode, msg.Offset, msg.Limit)
+	if err != nil {
+		sendError(client, msg, ErrCodeBadRequest, err.Error())
+		return
+	}
+	client.SendJSON(messages.ServerMessage{Type: "leaderboard", Leaderboard: page})
+}
+
+// HandleLeaderboard serves GET /leaderboard with a page of a board, chosen
+// by the same board, mode, offset and limit query parameters as the
+// leaderboard message
+func (s *Server) HandleLeaderboard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	offset, _ := strconv.Atoi(q.Get("offset"))
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	page, err := s.leaderboardPage(q.Get("board"), q.Get("mode"), offset, limit)
+	if err == leaderboard.ErrUnknownBoard {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// BroadcastLeaderboard sends the top of the overall wins board to every
+// client in the lobby, unless nobody has won anything yet
+func (s *Server) BroadcastLeaderboard() {
+	page, err := s.leaderboardPage("", "", 0, 0)
+	if err != nil {
+		slog.Error("Cannot load leaderboard", "err", err)
+		return
+	}
+	if page.Total == 0 {
+		return
+	}
+	for _, c := range s.onlineClients() {
+		if c != nil && c.currentRoom() == "" {
+			c.SendJSON(messages.ServerMessage{Type: "leaderboard", Leaderboard: page})
+		}
+	}
+}
+
+// leaderboardPage loads a page of a board in its wire format. The board
+// defaults to wins and the mode to every mode together.
+func (s *Server) leaderboardPage(board, mode string, offset, limit int) (*messages.Leaderboard, error) {
+	if board == "" {
+		board = leaderboard.BoardWins
+	}
+	if mode == "" {
+		mode = leaderboard.ModeAll
+	}
+	page, err := s.Leaderboards.Page(board, mode, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	msg := &messages.Leaderboard{
+		Board:   page.Board,
+		Mode:    page.Mode,
+		Offset:  page.Offset,
+		Total:   page.Total,
+		Entries: make([]messages.LeaderboardEntry, len(page.Entries)),
+	}
+	for i, e := range page.Entries {
+		msg.Entries[i] = messages.LeaderboardEntry{
+			Rank:       e.Rank,
+			PlayerID:   e.PlayerID,
+			Name:       e.Name,
+			Matches:    e.Matches,
+			Wins:       e.Wins,
+			BestTimeMs: e.BestTime.Milliseconds(),
+			Rating:     e.Rating,
+		}
+	}
+	return msg, nil
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"labyrinth-duel/websocket/internal/game"
+	"labyrinth-duel/websocket/internal/level"
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+// handleUploadMaze stores a maze made in an editor as a level, once it is
+// checked to be playable, and tells the client its ID to create rooms with
+func (s *Server) handleUploadMaze(client *Client, msg messages.ClientMessage) {
+	data, format := []byte(msg.MazeFile), msg.Format
+	if msg.Layout != nil {
+		var err error
+		if data, err = json.Marshal(msg.Layout); err != nil {
+			sendError(client, msg, ErrCodeBadRequest, err.Error())
+			return
+		}
+		format = game.FormatJSON
+	}
+	maze, err := game.ImportMaze(data, format)
+	if err == nil {
+		err = level.Check(maze)
+	}
+	if err != nil {
+		sendError(client, msg, ErrCodeBadRequest, "bad level: "+err.Error())
+		return
+	}
+
+	name := strings.TrimSpace(msg.Name)
+	if len(name) > level.MaxNameLength {
+		name = name[:level.MaxNameLength]
+	}
+	if name == "" {
+		name = "Untitled"
+	}
+	lvl := &level.Level{
+		LevelInfo: messages.LevelInfo{
+			ID:        uuid.New().String()[:8],
+			Name:      name,
+			Author:    client.ID,
+			Width:     maze.Width,
+			Height:    maze.Height,
+			CreatedAt: s.clock.Now().UnixMilli(),
+		},
+		Maze: maze,
+	}
+	if err := s.Levels.Save(lvl); err != nil {
+		client.logger(msg.Type).Error("Cannot save level", "err", err)
+		sendError(client, msg, ErrCodeUnavailable, "level could not be saved")
+		return
+	}
+	client.logger(msg.Type).Info("Uploaded a level", "level", lvl.ID, "width", maze.Width, "height", maze.Height)
+	client.SendJSON(messages.ServerMessage{Type: "mazeUploaded", RequestID: msg.RequestID, Level: &lvl.LevelInfo})
+}
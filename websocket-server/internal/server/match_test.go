@@ -0,0 +1,1081 @@
+package server_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"labyrinth-duel/websocket/internal/game"
+	"labyrinth-duel/websocket/internal/harness"
+	"labyrinth-duel/websocket/internal/matchmaking"
+	"labyrinth-duel/websocket/internal/messages"
+	"labyrinth-duel/websocket/internal/room"
+	"labyrinth-duel/websocket/internal/server"
+)
+
+func TestMatches(t *testing.T) {
+	runScenarios(t, []scenario{
+		{"join, race, win, disconnect", raceToGoal},
+		{"a bot races an idle player to the exit", botRace},
+		{"a finished match replays move for move", replayMatch},
+		{"sandbox commands work in practice rooms only", practiceSandbox},
+		{"ranked players veto mazes down to one", mapVeto},
+		{"declining matches again earns a cooldown", declineMatch},
+		{"a suspended match resumes where it stopped", suspendMatch},
+		{"ranked players review their match step by step", reviewMatch},
+		{"the tutorial walks a new player through to a win", tutorialFlow},
+		{"moves faster than a player's speed are turned away", speedLimit},
+		{"a player steers to the exit by direction alone", directionMoves},
+		{"a tick client gets the race as one message per tick", tickBatches},
+		{"the host pauses a match, moves are refused, and a vote resumes it", pauseMatch},
+		{"moves are refused through the countdown to the announced start", startFreeze},
+	})
+}
+
+// raceToGoal has two players join a seeded room; the first walks the
+// shortest path to the exit and must be declared the winner, then the
+// loser's disconnect must reach the winner
+func raceToGoal(h *harness.Harness) error {
+	a, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	b, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+
+	a.Send(messages.ClientMessage{Type: "join", RoomID: "race", Seed: 42})
+	if _, err := a.Expect("mazeData", 0); err != nil {
+		return err
+	}
+	b.Send(messages.ClientMessage{Type: "join", RoomID: "race"})
+	if _, err := b.Expect("mazeData", 0); err != nil {
+		return err
+	}
+	if a.Maze.Seed != 42 || b.Maze.Seed != 42 {
+		return fmt.Errorf("seed not applied: %d, %d", a.Maze.Seed, b.Maze.Seed)
+	}
+
+	a.Send(messages.ClientMessage{Type: "ready"})
+	b.Send(messages.ClientMessage{Type: "ready"})
+	if err := b.Sync(); err != nil {
+		return err
+	}
+	if _, err := a.Expect("gameStarting", 0); err != nil {
+		return err
+	}
+	if err := awaitPlaying(a); err != nil {
+		return err
+	}
+
+	path, err := a.PathToGoal(0, 0)
+	if err != nil {
+		return err
+	}
+	if err := a.Walk(path, room.BaseMoveInterval); err != nil {
+		return err
+	}
+
+	over, err := b.Expect("gameOver", 0)
+	if err != nil {
+		return err
+	}
+	if over.Winner != a.ID || over.Reason != "goal" {
+		return fmt.Errorf("gameOver winner=%q reason=%q, want %q by goal", over.Winner, over.Reason, a.ID)
+	}
+
+	b.Disconnect()
+	left, err := a.Expect("playerLeft", 0)
+	if err != nil {
+		return err
+	}
+	if left.Message != b.ID {
+		return fmt.Errorf("playerLeft for %q, want %q", left.Message, b.ID)
+	}
+	return nil
+}
+
+// botRace seats a hard bot next to a player who never moves; the bot must
+// count towards starting the match and then win it by reaching the exit
+func botRace(h *harness.Harness) error {
+	a, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+
+	a.Send(messages.ClientMessage{Type: "join", RoomID: "bots", Seed: 42})
+	if _, err := a.Expect("mazeData", 0); err != nil {
+		return err
+	}
+	a.Send(messages.ClientMessage{Type: "addBot", Difficulty: room.BotHard.Name})
+	joined, err := a.Expect("playerJoined", 0)
+	if err != nil {
+		return err
+	}
+	var bot *messages.Player
+	for i, p := range joined.Players {
+		if p.ID == joined.Message {
+			bot = &joined.Players[i]
+		}
+	}
+	if bot == nil || !bot.Bot || !bot.Ready {
+		return fmt.Errorf("bot %q missing, or not marked as a ready bot: %+v", joined.Message, joined.Players)
+	}
+
+	a.Send(messages.ClientMessage{Type: "ready"})
+	if err := a.Sync(); err != nil {
+		return err
+	}
+	over, err := a.Await("gameOver", 30*time.Second)
+	if err != nil {
+		return err
+	}
+	if over.Winner != bot.ID || over.Reason != "goal" {
+		return fmt.Errorf("gameOver winner=%q reason=%q, want %q by goal", over.Winner, over.Reason, bot.ID)
+	}
+	return nil
+}
+
+// replayMatch lets a bot win a match, then watches its replay at top
+// speed: the replay must be listed, start on the match's maze, and end with
+// the bot's winning move on an exit
+func replayMatch(h *harness.Harness) error {
+	a, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+
+	a.Send(messages.ClientMessage{Type: "join", RoomID: "replay", Seed: 7})
+	if _, err := a.Expect("mazeData", 0); err != nil {
+		return err
+	}
+	a.Send(messages.ClientMessage{Type: "addBot", Difficulty: room.BotHard.Name})
+	a.Send(messages.ClientMessage{Type: "ready"})
+	if err := a.Sync(); err != nil {
+		return err
+	}
+	over, err := a.Await("gameOver", 30*time.Second)
+	if err != nil {
+		return err
+	}
+
+	a.Send(messages.ClientMessage{Type: "listReplays"})
+	list, err := a.Expect("replayList", 0)
+	if err != nil {
+		return err
+	}
+	if len(list.Replays) != 1 || list.Replays[0].Winner != over.Winner {
+		return fmt.Errorf("replayList %+v, want one replay won by %q", list.Replays, over.Winner)
+	}
+
+	a.Send(messages.ClientMessage{Type: "watchReplay", ReplayID: list.Replays[0].ID, Speed: server.MaxReplaySpeed})
+	start, err := a.Expect("replayStart", 0)
+	if err != nil {
+		return err
+	}
+	if start.Maze == nil || start.Maze.Seed != 7 {
+		return fmt.Errorf("replayStart without the match's maze: %+v", start.Maze)
+	}
+	from := len(a.Log)
+	if _, err := a.Await("replayEnd", 30*time.Second); err != nil {
+		return err
+	}
+
+	var lastMove *messages.ReplayEvent
+	for _, msg := range a.Log[from:] {
+		if msg.Type == "replayEvent" && msg.Event.Type == room.EventMove && msg.Event.PlayerID == over.Winner {
+			lastMove = msg.Event
+		}
+	}
+	if lastMove == nil || !isGoal(start.Maze, lastMove.X, lastMove.Y) {
+		return fmt.Errorf("replay's last winning move %+v isn't on an exit", lastMove)
+	}
+	return nil
+}
+
+// practiceSandbox checks sandbox commands are refused in a normal room,
+// then plays a solo practice match: reveal the fogged maze, drop an item,
+// and teleport onto the exit to win
+func practiceSandbox(h *harness.Harness) error {
+	a, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	b, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+
+	b.Send(messages.ClientMessage{Type: "join", RoomID: "normal"})
+	if _, err := b.Expect("mazeData", 0); err != nil {
+		return err
+	}
+	b.Send(messages.ClientMessage{Type: "sandbox", Command: room.SandboxReveal})
+	refused, err := b.Expect("error", 0)
+	if err != nil {
+		return err
+	}
+	if refused.Error != server.ErrCodeInvalidAction {
+		return fmt.Errorf("sandbox outside practice got %q, want %q", refused.Error, server.ErrCodeInvalidAction)
+	}
+
+	a.Send(messages.ClientMessage{Type: "join", RoomID: "practice", Practice: true, Fog: true, Seed: 11})
+	if _, err := a.Expect("mazeData", 0); err != nil {
+		return err
+	}
+	a.Send(messages.ClientMessage{Type: "ready"})
+	if err := awaitPlaying(a); err != nil {
+		return err
+	}
+
+	a.Send(messages.ClientMessage{Type: "sandbox", Command: room.SandboxReveal})
+	if _, err := a.Expect("mazeReveal", 0); err != nil {
+		return err
+	}
+
+	goal := a.Maze.Goal
+	a.Send(messages.ClientMessage{Type: "sandbox", Command: room.SandboxSpawnItem, Item: room.ItemFreeze, X: goal.X, Y: goal.Y})
+	spawned, err := a.Expect("itemSpawned", 0)
+	if err != nil {
+		return err
+	}
+	if len(spawned.Items) != 1 || spawned.Items[0].Kind != room.ItemFreeze {
+		return fmt.Errorf("itemSpawned %+v, want one freeze", spawned.Items)
+	}
+
+	a.Send(messages.ClientMessage{Type: "sandbox", Command: room.SandboxTeleport, X: goal.X, Y: goal.Y})
+	over, err := a.Expect("gameOver", 0)
+	if err != nil {
+		return err
+	}
+	if over.Winner != a.ID || over.Reason != "goal" {
+		return fmt.Errorf("gameOver winner=%q reason=%q, want %q by goal", over.Winner, over.Reason, a.ID)
+	}
+	return nil
+}
+
+// mapVeto matches two players through the queue; once both are ready they
+// must strike candidate mazes in the announced turn order, out of turn
+// strikes are refused, and the match is played on the maze left over
+func mapVeto(h *harness.Harness) error {
+	players := map[string]*harness.Client{}
+	var clients []*harness.Client
+	for range 2 {
+		c, err := h.Connect("")
+		if err != nil {
+			return err
+		}
+		players[c.ID] = c
+		clients = append(clients, c)
+		c.Send(messages.ClientMessage{Type: "findMatch"})
+		if err := c.Sync(); err != nil {
+			return err
+		}
+	}
+	for _, c := range clients {
+		if _, err := c.Await("matchProposed", 2*matchmaking.MatchInterval); err != nil {
+			return err
+		}
+		c.Send(messages.ClientMessage{Type: "acceptMatch"})
+	}
+	for _, c := range clients {
+		found, err := c.Expect("matchFound", 0)
+		if err != nil {
+			return err
+		}
+		c.Send(messages.ClientMessage{Type: "join", Code: found.Code})
+		if _, err := c.Expect("mazeData", 0); err != nil {
+			return err
+		}
+	}
+	for _, c := range clients {
+		c.Send(messages.ClientMessage{Type: "ready"})
+	}
+
+	started, err := clients[0].Expect("vetoStarted", 0)
+	if err != nil {
+		return err
+	}
+	veto := started.Veto
+	if len(veto.Candidates) != room.VetoCandidates {
+		return fmt.Errorf("vetoStarted with %d candidates, want %d", len(veto.Candidates), room.VetoCandidates)
+	}
+	for _, c := range clients {
+		if c.ID != veto.Turn {
+			c.Send(messages.ClientMessage{Type: "veto", Seed: veto.Candidates[0].Seed})
+			if _, err := c.Expect("error", 0); err != nil {
+				return fmt.Errorf("out of turn strike wasn't refused: %w", err)
+			}
+		}
+	}
+
+	for veto.Chosen == 0 {
+		var strike int64
+		for _, cand := range veto.Candidates {
+			if cand.VetoedBy == "" {
+				strike = cand.Seed
+				break
+			}
+		}
+		players[veto.Turn].Send(messages.ClientMessage{Type: "veto", Seed: strike})
+
+		msg, err := clients[0].Next(0)
+		for err == nil && msg.Type != "vetoUpdated" && msg.Type != "vetoDecided" {
+			msg, err = clients[0].Next(0)
+		}
+		if err != nil {
+			return err
+		}
+		veto = msg.Veto
+	}
+
+	if _, err := clients[0].Expect("newRound", 0); err != nil {
+		return err
+	}
+	if clients[0].Maze.Seed != veto.Chosen {
+		return fmt.Errorf("playing on maze %d, want the one left over, %d", clients[0].Maze.Seed, veto.Chosen)
+	}
+	if _, err := clients[0].Await("gameStarting", time.Second); err != nil {
+		return err
+	}
+	return nil
+}
+
+// declineMatch has one player of a proposed pair decline twice: the first
+// time is let off, the second bars them from the queue, and each time the
+// other player goes straight back in it
+func declineMatch(h *harness.Harness) error {
+	a, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	b, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	a.Send(messages.ClientMessage{Type: "findMatch"})
+	b.Send(messages.ClientMessage{Type: "findMatch"})
+
+	for _, wantCooldown := range []bool{false, true} {
+		for _, c := range []*harness.Client{a, b} {
+			if err := c.Sync(); err != nil {
+				return err
+			}
+		}
+		for _, c := range []*harness.Client{a, b} {
+			proposed, err := c.Await("matchProposed", 2*matchmaking.MatchInterval)
+			if err != nil {
+				return err
+			}
+			if proposed.Seconds != int(matchmaking.AcceptTimeout.Seconds()) {
+				return fmt.Errorf("matchProposed gives %ds to accept", proposed.Seconds)
+			}
+		}
+		a.Send(messages.ClientMessage{Type: "acceptMatch"})
+		if _, err := b.Expect("matchAccepted", 0); err != nil {
+			return err
+		}
+		b.Send(messages.ClientMessage{Type: "declineMatch"})
+
+		cancelled, err := a.Expect("matchCancelled", 0)
+		if err != nil {
+			return err
+		}
+		if cancelled.Reason != "declined" || cancelled.Message != b.ID {
+			return fmt.Errorf("matchCancelled reason=%q by %q, want declined by %q", cancelled.Reason, cancelled.Message, b.ID)
+		}
+		if _, err := a.Expect("queued", 0); err != nil {
+			return err
+		}
+		penalty, err := b.Expect("matchCancelled", 0)
+		if err != nil {
+			return err
+		}
+		if (penalty.Seconds > 0) != wantCooldown {
+			return fmt.Errorf("decliner got a %ds cooldown, want one: %v", penalty.Seconds, wantCooldown)
+		}
+		b.Send(messages.ClientMessage{Type: "findMatch"})
+	}
+
+	rejected, err := b.Expect("queueRejected", 0)
+	if err != nil {
+		return err
+	}
+	if rejected.Error != server.ErrCodeCooldown || rejected.Seconds <= 0 {
+		return fmt.Errorf("queueRejected error=%q seconds=%d, want %s with time left", rejected.Error, rejected.Seconds, server.ErrCodeCooldown)
+	}
+	return nil
+}
+
+// suspendMatch has two players agree to suspend a match, then resume it by
+// code: it must only come back once both ask, with the first to ask
+// waiting and the other told, and pick up where it stopped
+func suspendMatch(h *harness.Harness) error {
+	a, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	b, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	a.Send(messages.ClientMessage{Type: "join", RoomID: "friendly", Seed: 8})
+	if _, err := a.Expect("mazeData", 0); err != nil {
+		return err
+	}
+	b.Send(messages.ClientMessage{Type: "join", RoomID: "friendly"})
+	if _, err := b.Expect("mazeData", 0); err != nil {
+		return err
+	}
+	a.Send(messages.ClientMessage{Type: "ready"})
+	b.Send(messages.ClientMessage{Type: "ready"})
+	if err := awaitPlaying(a, b); err != nil {
+		return err
+	}
+
+	path, err := a.PathToGoal(0, 0)
+	if err != nil {
+		return err
+	}
+	at := path[1]
+	if err := a.Walk(path[:2], room.BaseMoveInterval); err != nil {
+		return err
+	}
+
+	a.Send(messages.ClientMessage{Type: "startVote", VoteKind: room.VoteSuspend})
+	if _, err := b.Expect("voteStarted", 0); err != nil {
+		return err
+	}
+	b.Send(messages.ClientMessage{Type: "castVote", Yes: true})
+	suspended, err := a.Expect("matchSuspended", 0)
+	if err != nil {
+		return err
+	}
+	if _, err := b.Expect("matchSuspended", 0); err != nil {
+		return err
+	}
+	code := suspended.Code
+	if code == "" {
+		return fmt.Errorf("matchSuspended carries no resume code")
+	}
+
+	stranger, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	stranger.Send(messages.ClientMessage{Type: "resumeMatch", Code: code})
+	if msg, err := stranger.Expect("error", 0); err != nil {
+		return err
+	} else if msg.Error != server.ErrCodeNotFound {
+		return fmt.Errorf("stranger resuming got %s, want %s", msg.Error, server.ErrCodeNotFound)
+	}
+
+	a.Send(messages.ClientMessage{Type: "resumeMatch", Code: code})
+	if _, err := a.Expect("resumePending", 0); err != nil {
+		return err
+	}
+	requested, err := b.Expect("resumeRequested", 0)
+	if err != nil {
+		return err
+	}
+	if requested.Message != a.ID || requested.Code != code {
+		return fmt.Errorf("resumeRequested from %q for %q, want %q for %q", requested.Message, requested.Code, a.ID, code)
+	}
+	b.Send(messages.ClientMessage{Type: "resumeMatch", Code: code})
+	for _, c := range []*harness.Client{a, b} {
+		data, err := c.Expect("mazeData", 0)
+		if err != nil {
+			return err
+		}
+		if data.Maze.Seed != 8 || data.State != string(room.StatePlaying) || len(data.Players) != 2 {
+			return fmt.Errorf("resumed seed %d in state %q with %d players, want 8 playing with 2",
+				data.Maze.Seed, data.State, len(data.Players))
+		}
+		for _, p := range data.Players {
+			if p.ID == a.ID && (p.X != at.X || p.Y != at.Y) {
+				return fmt.Errorf("resumed at (%d, %d), want (%d, %d)", p.X, p.Y, at.X, at.Y)
+			}
+		}
+	}
+	return nil
+}
+
+// reviewMatch plays a ranked match, then has both players step through it
+// in review: the winner's path must grow as its moves are shown and end up
+// as short as the optimal path, since they walked a shortest one
+func reviewMatch(h *harness.Harness) error {
+	h.Server.Rooms().GetOrCreateRoom("ranked", room.Options{
+		Maze:  game.Options{Seed: 3},
+		Rules: room.RuleSet{Ranked: true},
+	})
+	a, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	b, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	for _, c := range []*harness.Client{a, b} {
+		c.Send(messages.ClientMessage{Type: "join", RoomID: "ranked"})
+		if _, err := c.Expect("mazeData", 0); err != nil {
+			return err
+		}
+	}
+
+	a.Send(messages.ClientMessage{Type: "review", Command: room.ReviewStart})
+	if _, err := a.Expect("error", 0); err != nil {
+		return fmt.Errorf("review before the match wasn't refused: %w", err)
+	}
+
+	a.Send(messages.ClientMessage{Type: "ready"})
+	b.Send(messages.ClientMessage{Type: "ready"})
+	if err := awaitPlaying(a, b); err != nil {
+		return err
+	}
+	path, err := a.PathToGoal(0, 0)
+	if err != nil {
+		return err
+	}
+	if err := a.Walk(path, room.BaseMoveInterval); err != nil {
+		return err
+	}
+	if _, err := b.Expect("gameOver", 0); err != nil {
+		return err
+	}
+
+	a.Send(messages.ClientMessage{Type: "review", Command: room.ReviewStart})
+	started, err := b.Expect("reviewStarted", 0)
+	if err != nil {
+		return err
+	}
+	if started.Maze == nil || started.Review.Step != 0 || started.Review.Steps == 0 {
+		return fmt.Errorf("reviewStarted without a maze or at step %d of %d", started.Review.Step, started.Review.Steps)
+	}
+
+	// Both players take turns stepping to the end, slowly enough to stay
+	// inside the rate limit
+	var last *messages.ReviewStatus
+	walked := 0
+	for i := 0; i < started.Review.Steps; i++ {
+		c := []*harness.Client{a, b}[i%2]
+		h.Clock.Advance(100 * time.Millisecond)
+		c.Send(messages.ClientMessage{Type: "review", Command: room.ReviewNext})
+		msg, err := a.Expect("reviewStep", 0)
+		if err != nil {
+			return err
+		}
+		last = msg.Review
+		for _, p := range last.Paths {
+			if p.PlayerID == a.ID {
+				if len(p.Path) < walked {
+					return fmt.Errorf("winner's path shrank to %d cells at step %d", len(p.Path), last.Step)
+				}
+				walked = len(p.Path)
+			}
+		}
+	}
+	var optimal int
+	for _, p := range last.Optimal {
+		if p.PlayerID == a.ID {
+			optimal = len(p.Path)
+		}
+	}
+	if walked == 0 || walked != optimal {
+		return fmt.Errorf("winner walked %d cells, optimal path has %d", walked, optimal)
+	}
+
+	b.Send(messages.ClientMessage{Type: "review", Command: room.ReviewNext})
+	if _, err := b.Expect("error", 0); err != nil {
+		return fmt.Errorf("stepping past the end wasn't refused: %w", err)
+	}
+	b.Send(messages.ClientMessage{Type: "review", Command: room.ReviewPrev})
+	back, err := a.Expect("reviewStep", 0)
+	if err != nil {
+		return err
+	}
+	if back.Review.Step != last.Step-1 {
+		return fmt.Errorf("prev went to step %d, want %d", back.Review.Step, last.Step-1)
+	}
+	b.Send(messages.ClientMessage{Type: "review", Command: room.ReviewStop})
+	ended, err := a.Expect("reviewEnded", 0)
+	if err != nil {
+		return err
+	}
+	if ended.Message != b.ID {
+		return fmt.Errorf("reviewEnded by %q, want %q", ended.Message, b.ID)
+	}
+	return nil
+}
+
+// tutorialFlow starts the tutorial and does as each step says: walks,
+// smashes a wall, fetches and uses the power-up it puts down, then wins,
+// which marks the tutorial done on the player's profile
+func tutorialFlow(h *harness.Harness) error {
+	a, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	a.Send(messages.ClientMessage{Type: "startTutorial", Seed: 5})
+	joined, err := a.Expect("mazeData", 0)
+	if err != nil {
+		return err
+	}
+	r := h.Server.Rooms().GetRoomByCode(joined.Code)
+	if r == nil {
+		return fmt.Errorf("no tutorial room for code %q", joined.Code)
+	}
+	step := func(stage string) (messages.ServerMessage, error) {
+		msg, err := a.Await("tutorialStep", room.CountdownDuration+time.Second)
+		if err == nil && msg.Reason != stage {
+			err = fmt.Errorf("tutorial went to stage %q, want %q", msg.Reason, stage)
+		}
+		return msg, err
+	}
+
+	if _, err := step(room.TutorialMove); err != nil {
+		return err
+	}
+	me := playerIn(r, a.ID)
+	x, y := me.X, me.Y
+	for range room.TutorialMoves {
+		cell := a.Maze.Cells[y][x]
+		next := messages.Position{X: x, Y: y}
+		switch {
+		case !cell.Top:
+			next.Y--
+		case !cell.Right:
+			next.X++
+		case !cell.Bottom:
+			next.Y++
+		default:
+			next.X--
+		}
+		if err := a.Walk([]messages.Position{next}, room.BaseMoveInterval); err != nil {
+			return err
+		}
+		x, y = next.X, next.Y
+	}
+
+	if _, err := step(room.TutorialSmash); err != nil {
+		return err
+	}
+	cell := a.Maze.Cells[y][x]
+	for _, side := range []struct {
+		direction string
+		wall      bool
+		inside    bool
+	}{
+		{"up", cell.Top, y > 0},
+		{"right", cell.Right, x < a.Maze.Width-1},
+		{"down", cell.Bottom, y < a.Maze.Height-1},
+		{"left", cell.Left, x > 0},
+	} {
+		if side.wall && side.inside {
+			a.Send(messages.ClientMessage{Type: "breakWall", Direction: side.direction})
+			break
+		}
+	}
+
+	item, err := step(room.TutorialItem)
+	if err != nil {
+		return err
+	}
+	if item.Position == nil {
+		return fmt.Errorf("item step points at nothing")
+	}
+	path, err := a.PathTo(x, y, *item.Position)
+	if err != nil {
+		return err
+	}
+	if err := a.Walk(path, room.BaseMoveInterval); err != nil {
+		return err
+	}
+	x, y = item.Position.X, item.Position.Y
+	if _, err := a.Expect("itemPickedUp", 0); err != nil {
+		return err
+	}
+	a.Send(messages.ClientMessage{Type: "useItem", Item: room.TutorialItemKind})
+
+	if _, err := step(room.TutorialWin); err != nil {
+		return err
+	}
+	if path, err = a.PathToGoal(x, y); err != nil {
+		return err
+	}
+	if err := a.Walk(path, room.BaseMoveInterval); err != nil {
+		return err
+	}
+	if _, err := a.Await("tutorialComplete", time.Second); err != nil {
+		return err
+	}
+
+	a.Send(messages.ClientMessage{Type: "updateProfile"})
+	profile, err := a.Expect("profile", 0)
+	if err != nil {
+		return err
+	}
+	if !profile.Profile.TutorialDone {
+		return fmt.Errorf("tutorial not marked on profile %+v", profile.Profile)
+	}
+	return nil
+}
+
+// speedLimit moves back and forth in a practice room with a slow move
+// interval: a move well before the interval is up is turned away with
+// TOO_FAST, one just short of it is let through without bringing the next
+// forward, and doubling the player's speed halves the wait
+func speedLimit(h *harness.Harness) error {
+	interval := 200 * time.Millisecond
+	a, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	a.Send(messages.ClientMessage{Type: "join", RoomID: "slow", Seed: 11, Practice: true,
+		MoveIntervalMs: int(interval.Milliseconds())})
+	if _, err := a.Expect("mazeData", 0); err != nil {
+		return err
+	}
+	a.Send(messages.ClientMessage{Type: "ready"})
+	if err := awaitPlaying(a); err != nil {
+		return err
+	}
+
+	r := h.Server.Rooms().GetRoom("slow")
+	me := playerIn(r, a.ID)
+	from := messages.Position{X: me.X, Y: me.Y}
+	path, err := a.PathToGoal(from.X, from.Y)
+	if err != nil {
+		return err
+	}
+	to := path[0]
+
+	// Over the wire, a second move straight away is too fast
+	h.Clock.Advance(interval)
+	a.Send(messages.ClientMessage{Type: "move", X: to.X, Y: to.Y})
+	a.Send(messages.ClientMessage{Type: "move", X: from.X, Y: from.Y})
+	rejected, err := a.Expect("error", 0)
+	if err != nil {
+		return err
+	}
+	if rejected.Error != server.ErrCodeTooFast {
+		return fmt.Errorf("quick second move answered %q, want %s", rejected.Error, server.ErrCodeTooFast)
+	}
+
+	h.Clock.Advance(interval * 6 / 10)
+	if err := r.UpdatePlayerPosition(a.ID, from.X, from.Y, from.Z); err != room.ErrTooFast {
+		return fmt.Errorf("move at 60%% of the interval: %v, want %v", err, room.ErrTooFast)
+	}
+	h.Clock.Advance(interval / 2)
+	if err := r.SetSpeed(a.ID, 2); err != nil {
+		return err
+	}
+	if err := r.UpdatePlayerPosition(a.ID, from.X, from.Y, from.Z); err != nil {
+		return fmt.Errorf("move after the interval: %v", err)
+	}
+	h.Clock.Advance(interval * 6 / 10)
+	if err := r.UpdatePlayerPosition(a.ID, to.X, to.Y, to.Z); err != nil {
+		return fmt.Errorf("move at 60%% of the interval at double speed: %v", err)
+	}
+	half := interval / 2
+	h.Clock.Advance(half * 95 / 100)
+	if err := r.UpdatePlayerPosition(a.ID, from.X, from.Y, from.Z); err != nil {
+		return fmt.Errorf("move at 95%% of the interval: %v", err)
+	}
+	h.Clock.Advance(half * 90 / 100)
+	if err := r.UpdatePlayerPosition(a.ID, to.X, to.Y, to.Z); err != room.ErrTooFast {
+		return fmt.Errorf("move at 90%% of the interval after an early one: %v, want %v", err, room.ErrTooFast)
+	}
+
+	if err := r.SetSpeed(a.ID, room.MaxSpeed*2); err != room.ErrBadSpeed {
+		return fmt.Errorf("speed beyond the maximum: %v", err)
+	}
+	return nil
+}
+
+// directionMoves races a practice room to the exit with moveDir, never
+// naming a cell; an unknown direction and one into a wall are refused
+func directionMoves(h *harness.Harness) error {
+	a, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	a.Send(messages.ClientMessage{Type: "join", RoomID: "steer", Seed: 4, Practice: true})
+	if _, err := a.Expect("mazeData", 0); err != nil {
+		return err
+	}
+	a.Send(messages.ClientMessage{Type: "ready"})
+	if err := awaitPlaying(a); err != nil {
+		return err
+	}
+
+	me := playerIn(h.Server.Rooms().GetRoom("steer"), a.ID)
+	x, y := me.X, me.Y
+
+	a.Send(messages.ClientMessage{Type: "moveDir", Direction: "sideways"})
+	if refused, err := a.Expect("error", 0); err != nil || refused.Error != server.ErrCodeBadRequest {
+		return fmt.Errorf("unknown direction answered %+v (%v)", refused, err)
+	}
+	cell := a.Maze.Cells[y][x]
+	walled := map[string]bool{"up": cell.Top, "right": cell.Right, "down": cell.Bottom, "left": cell.Left}
+	for _, dir := range []string{"up", "right", "down", "left"} {
+		if walled[dir] {
+			a.Send(messages.ClientMessage{Type: "moveDir", Direction: dir})
+			if refused, err := a.Expect("error", 0); err != nil || refused.Error != server.ErrCodeInvalidMove {
+				return fmt.Errorf("move %s into a wall answered %+v (%v)", dir, refused, err)
+			}
+			break
+		}
+	}
+
+	path, err := a.PathToGoal(x, y)
+	if err != nil {
+		return err
+	}
+	for _, p := range path {
+		dir := map[messages.Position]string{
+			{X: x, Y: y - 1}: "up", {X: x + 1, Y: y}: "right",
+			{X: x, Y: y + 1}: "down", {X: x - 1, Y: y}: "left",
+		}[p]
+		h.Clock.Advance(room.BaseMoveInterval)
+		a.Send(messages.ClientMessage{Type: "moveDir", Direction: dir})
+		if err := a.Sync(); err != nil {
+			return err
+		}
+		x, y = p.X, p.Y
+	}
+
+	over, err := a.Expect("gameOver", 0)
+	if err != nil {
+		return err
+	}
+	if over.Winner != a.ID {
+		return fmt.Errorf("gameOver winner=%q, want %q", over.Winner, a.ID)
+	}
+	return nil
+}
+
+// tickBatches has a tickBatch client watch another race to the exit: from
+// joining on it only gets ticks, numbered in order, each with at most the
+// latest move of the racer, and the last move puts them on the exit
+func tickBatches(h *harness.Harness) error {
+	a, err := h.ConnectWith(server.Handshake{Caps: []string{messages.CapTickBatch}})
+	if err != nil {
+		return err
+	}
+	b, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	for _, c := range []*harness.Client{a, b} {
+		c.Send(messages.ClientMessage{Type: "join", RoomID: "ticks", Seed: 8})
+		if _, err := c.Expect("mazeData", 0); err != nil {
+			return err
+		}
+		c.Send(messages.ClientMessage{Type: "ready"})
+	}
+	if err := awaitPlaying(b, a); err != nil {
+		return err
+	}
+
+	from := playerIn(h.Server.Rooms().GetRoom("ticks"), b.ID)
+	path, err := b.PathToGoal(from.X, from.Y)
+	if err != nil {
+		return err
+	}
+	if err := b.Walk(path, room.BaseMoveInterval); err != nil {
+		return err
+	}
+	seen := len(a.Log)
+	if _, err := a.Await("gameOver", time.Second); err != nil {
+		return err
+	}
+
+	var lastTick uint64
+	var last *messages.Position
+	for _, msg := range a.Log[seen:] {
+		if msg.Type == "ack" {
+			continue
+		}
+		if msg.Type != "tick" {
+			return fmt.Errorf("tick client got a %q message", msg.Type)
+		}
+		if msg.Tick <= lastTick || msg.Time == 0 {
+			return fmt.Errorf("tick %d (time %d) after tick %d", msg.Tick, msg.Time, lastTick)
+		}
+		lastTick = msg.Tick
+		moves := 0
+		for _, inner := range msg.Batch {
+			if inner.Type == "playerMoved" && inner.Message == b.ID {
+				moves++
+				last = inner.Position
+			}
+		}
+		if moves > 1 {
+			return fmt.Errorf("tick %d holds %d moves of one player", msg.Tick, moves)
+		}
+	}
+	goal := path[len(path)-1]
+	if last == nil || *last != goal {
+		return fmt.Errorf("racer last seen at %+v, exit at %+v", last, goal)
+	}
+	return nil
+}
+
+// pauseMatch has the host pause a race, checks the other player can neither
+// pause nor move, then has the room vote to resume, still frozen through
+// the countdown out of it, and the host win it
+func pauseMatch(h *harness.Harness) error {
+	var clients []*harness.Client
+	for range 2 {
+		c, err := h.Connect("")
+		if err != nil {
+			return err
+		}
+		c.Send(messages.ClientMessage{Type: "join", RoomID: "pausing", Seed: 16})
+		if _, err := c.Expect("mazeData", 0); err != nil {
+			return err
+		}
+		c.Send(messages.ClientMessage{Type: "ready"})
+		clients = append(clients, c)
+	}
+	a, b := clients[0], clients[1]
+	if err := awaitPlaying(a, b); err != nil {
+		return err
+	}
+
+	b.Send(messages.ClientMessage{Type: "pause"})
+	if msg, err := b.Expect("error", 0); err != nil {
+		return err
+	} else if msg.Error != server.ErrCodeNotHost {
+		return fmt.Errorf("pause by a guest got %s, want %s", msg.Error, server.ErrCodeNotHost)
+	}
+	a.Send(messages.ClientMessage{Type: "pause"})
+	paused, err := b.Expect("matchPaused", 0)
+	if err != nil {
+		return err
+	}
+	if paused.State != string(room.StatePaused) || paused.Message != a.ID {
+		return fmt.Errorf("paused to %q by %q, want %s by %s", paused.State, paused.Message, room.StatePaused, a.ID)
+	}
+
+	r := h.Server.Rooms().GetRoom("pausing")
+	spawn := playerIn(r, b.ID)
+	// Every way out of the spawn is refused, open or not
+	refused := func() error {
+		for _, dir := range []string{"up", "right", "down", "left"} {
+			b.Send(messages.ClientMessage{Type: "moveDir", Direction: dir})
+		}
+		for range 4 {
+			if msg, err := b.Expect("error", 0); err != nil {
+				return err
+			} else if msg.Error != server.ErrCodeInvalidMove {
+				return fmt.Errorf("move while paused got %s, want %s", msg.Error, server.ErrCodeInvalidMove)
+			}
+		}
+		if at := playerIn(r, b.ID); at.X != spawn.X || at.Y != spawn.Y {
+			return fmt.Errorf("%s moved while paused", b.ID)
+		}
+		return nil
+	}
+	if err := refused(); err != nil {
+		return err
+	}
+
+	b.Send(messages.ClientMessage{Type: "startVote", VoteKind: room.VoteResume})
+	if _, err := a.Expect("voteStarted", 0); err != nil {
+		return err
+	}
+	a.Send(messages.ClientMessage{Type: "castVote", Yes: true})
+	resuming, err := b.Expect("matchResuming", 0)
+	if err != nil {
+		return err
+	}
+	if resuming.StartsAt < h.Clock.Now().Add(room.CountdownDuration-time.Second).UnixMilli() {
+		return fmt.Errorf("resuming at %d, want a full countdown away", resuming.StartsAt)
+	}
+	if err := refused(); err != nil {
+		return err
+	}
+	resumed, err := b.Await("matchResumed", room.CountdownDuration+time.Second)
+	if err != nil {
+		return err
+	}
+	if resumed.Reason != room.ResumedByVote || resumed.Seconds < int(room.DefaultMatchDuration.Seconds())-5 {
+		return fmt.Errorf("resumed by %q with %ds left", resumed.Reason, resumed.Seconds)
+	}
+
+	path, err := a.PathToGoal(0, 0)
+	if err != nil {
+		return err
+	}
+	if err := a.Walk(path, room.BaseMoveInterval); err != nil {
+		return err
+	}
+	if over, err := b.Expect("gameOver", 0); err != nil {
+		return err
+	} else if over.Winner != a.ID {
+		return fmt.Errorf("gameOver won by %q, want %s", over.Winner, a.ID)
+	}
+	return nil
+}
+
+// startFreeze tries to jump the gun during the countdown into a race: it
+// counts down to the start gameStarting announced, every move before Go is
+// refused, and the first one after is taken
+func startFreeze(h *harness.Harness) error {
+	var clients []*harness.Client
+	for range 2 {
+		c, err := h.Connect("")
+		if err != nil {
+			return err
+		}
+		c.Send(messages.ClientMessage{Type: "join", RoomID: "freeze", Seed: 17})
+		if _, err := c.Expect("mazeData", 0); err != nil {
+			return err
+		}
+		c.Send(messages.ClientMessage{Type: "ready"})
+		clients = append(clients, c)
+	}
+	a := clients[0]
+
+	starting, err := a.Expect("gameStarting", 0)
+	if err != nil {
+		return err
+	}
+	startsAt := time.UnixMilli(starting.StartsAt)
+	if until := startsAt.Sub(h.Clock.Now()); until < room.CountdownDuration-time.Second || until > room.CountdownDuration {
+		return fmt.Errorf("gameStarting announced a start %s away, want %s", until, room.CountdownDuration)
+	}
+	path, err := a.PathToGoal(0, 0)
+	if err != nil {
+		return err
+	}
+	first := path[0]
+	for startsAt.Sub(h.Clock.Now()) > 500*time.Millisecond {
+		a.Send(messages.ClientMessage{Type: "move", X: first.X, Y: first.Y})
+		if msg, err := a.Expect("error", 0); err != nil {
+			return err
+		} else if msg.Error != server.ErrCodeInvalidMove {
+			return fmt.Errorf("move in the countdown got %s, want %s", msg.Error, server.ErrCodeInvalidMove)
+		}
+		h.Clock.Advance(200 * time.Millisecond)
+	}
+
+	if err := awaitPlaying(clients...); err != nil {
+		return err
+	}
+	if h.Clock.Now().Before(startsAt) {
+		return fmt.Errorf("went before the announced start")
+	}
+	a.Send(messages.ClientMessage{Type: "move", X: first.X, Y: first.Y})
+	moved, err := a.Expect("playerMoved", 0)
+	if err != nil {
+		return err
+	}
+	if moved.Message != a.ID || moved.Position.X != first.X || moved.Position.Y != first.Y {
+		return fmt.Errorf("%s moved to %+v, want %s to %v", moved.Message, moved.Position, a.ID, first)
+	}
+	return nil
+}
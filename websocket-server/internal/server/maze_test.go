@@ -0,0 +1,728 @@
+package server_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"labyrinth-duel/websocket/internal/auth"
+	"labyrinth-duel/websocket/internal/game"
+	"labyrinth-duel/websocket/internal/harness"
+	"labyrinth-duel/websocket/internal/messages"
+	"labyrinth-duel/websocket/internal/room"
+	"labyrinth-duel/websocket/internal/server"
+)
+
+func TestMazes(t *testing.T) {
+	runScenarios(t, []scenario{
+		{"the exit moves away from the players", movingGoal},
+		{"ice slides players on and portals send them out of their twin", slipperyTerrain},
+		{"the maze shifts after a warning and stays solvable", shiftingMaze},
+		{"a minotaur charges a player nearby and sends them back to spawn", minotaurHunt},
+		{"an admin loads a hand-drawn maze, which exports and loads back until play starts", mazeFiles},
+		{"a player uploads a level, unplayable ones are refused, and rooms are made from it", levelUpload},
+		{"rooms asking for a difficulty get mazes rated in that tier, and the host can change it", mazeDifficulty},
+		{"players climb the stairs of a maze with floors to the exit on the top one", mazeFloors},
+		{"a weave room hands out its crossings intact", weaveRoom},
+	})
+}
+
+// movingGoal waits out the first move of the exit in a moving-goal room: it
+// must end up no nearer the closest player than it was, and reaching it
+// wins
+func movingGoal(h *harness.Harness) error {
+	r, _ := h.Server.Rooms().GetOrCreateRoom("moving", room.Options{
+		Maze:  game.Options{Seed: 8},
+		Rules: room.RuleSet{GoalMoveInterval: room.MinGoalMoveInterval},
+	})
+	a, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	b, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	for _, c := range []*harness.Client{a, b} {
+		c.Send(messages.ClientMessage{Type: "join", RoomID: "moving"})
+		if _, err := c.Expect("mazeData", 0); err != nil {
+			return err
+		}
+		c.Send(messages.ClientMessage{Type: "ready"})
+	}
+	if err := awaitPlaying(a, b); err != nil {
+		return err
+	}
+
+	// The closest any player is to the exit, walking
+	closest := func() (int, error) {
+		best := -1
+		for _, p := range r.GetPlayers() {
+			path, err := a.PathToGoal(p.X, p.Y)
+			if err != nil {
+				return 0, err
+			}
+			if best < 0 || len(path) < best {
+				best = len(path)
+			}
+		}
+		return best, nil
+	}
+	before, err := closest()
+	if err != nil {
+		return err
+	}
+
+	warning, err := a.Await("goalMoving", room.MinGoalMoveInterval)
+	if err != nil {
+		return err
+	}
+	if warning.Seconds != int(room.GoalMoveWarning.Seconds()) {
+		return fmt.Errorf("first warning at %d seconds, want %v", warning.Seconds, room.GoalMoveWarning)
+	}
+	moved, err := b.Await("goalMoved", room.GoalMoveWarning+time.Second)
+	if err != nil {
+		return err
+	}
+	if len(moved.Goals) == 0 || moved.Goals[0].X != moved.Position.X || moved.Goals[0].Y != moved.Position.Y {
+		return fmt.Errorf("goalMoved to %+v doesn't lead its goals %+v", moved.Position, moved.Goals)
+	}
+	if _, err := a.Expect("goalMoved", 0); err != nil {
+		return err
+	}
+	after, err := closest()
+	if err != nil {
+		return err
+	}
+	if after < before {
+		return fmt.Errorf("exit moved to %d steps from the nearest player, was %d", after, before)
+	}
+
+	me := playerIn(r, a.ID)
+	path, err := a.PathToGoal(me.X, me.Y)
+	if err != nil {
+		return err
+	}
+	if err := a.Walk(path, room.BaseMoveInterval); err != nil {
+		return err
+	}
+	over, err := b.Expect("gameOver", 0)
+	if err != nil {
+		return err
+	}
+	if over.Winner != a.ID {
+		return fmt.Errorf("winner %q, want %q at the moved exit", over.Winner, a.ID)
+	}
+	return nil
+}
+
+// slipperyTerrain steps a practice player onto ice and onto a portal in an
+// ice-themed maze, checking each lands them where the terrain rules say
+func slipperyTerrain(h *harness.Harness) error {
+	a, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	a.Send(messages.ClientMessage{Type: "join", RoomID: "rink", Practice: true, Seed: 12,
+		Theme: game.ThemeIce, TerrainDensity: 0.4})
+	if _, err := a.Expect("mazeData", 0); err != nil {
+		return err
+	}
+	a.Send(messages.ClientMessage{Type: "ready"})
+	if err := awaitPlaying(a); err != nil {
+		return err
+	}
+
+	m := a.Maze
+	if len(m.Portals) == 0 {
+		return fmt.Errorf("an ice maze at 40%% terrain has no portals")
+	}
+	terrain := func(p messages.Position) string { return m.Cells[p.Y][p.X].Terrain }
+	open := func(p messages.Position, dir string) (messages.Position, bool) {
+		c := m.Cells[p.Y][p.X]
+		dx, dy, _ := game.Offset(dir)
+		walled := map[string]bool{"up": c.Top, "right": c.Right, "down": c.Bottom, "left": c.Left}[dir]
+		return messages.Position{X: p.X + dx, Y: p.Y + dy}, !walled
+	}
+	// land works out where stepping from one cell into the next ends up
+	land := func(to messages.Position, dir string) messages.Position {
+		for terrain(to) == string(game.TerrainIce) {
+			next, ok := open(to, dir)
+			if !ok {
+				break
+			}
+			to = next
+		}
+		if terrain(to) != string(game.TerrainPortal) {
+			return to
+		}
+		for _, p := range m.Portals {
+			switch to {
+			case p.A:
+				return p.B
+			case p.B:
+				return p.A
+			}
+		}
+		return to
+	}
+
+	// Find a plain cell next to the given terrain, entered without reaching
+	// an exit on the way
+	approach := func(want game.Terrain) (from, to messages.Position, dir string, ok bool) {
+		for y, row := range m.Cells {
+			for x, c := range row {
+				from = messages.Position{X: x, Y: y}
+				if c.Terrain != "" || isGoal(m, x, y) {
+					continue
+				}
+				for _, dir = range []string{"up", "right", "down", "left"} {
+					to, ok = open(from, dir)
+					if !ok || terrain(to) != string(want) {
+						continue
+					}
+					if landed := land(to, dir); !isGoal(m, landed.X, landed.Y) {
+						return from, to, dir, true
+					}
+				}
+			}
+		}
+		return from, to, dir, false
+	}
+
+	for _, want := range []game.Terrain{game.TerrainIce, game.TerrainPortal} {
+		from, to, dir, ok := approach(want)
+		if !ok {
+			return fmt.Errorf("no way onto %s in the maze", want)
+		}
+		a.Send(messages.ClientMessage{Type: "sandbox", Command: room.SandboxTeleport, X: from.X, Y: from.Y})
+		if _, err := a.Expect("playerMoved", 0); err != nil {
+			return err
+		}
+		h.Clock.Advance(room.BaseMoveInterval)
+		a.Send(messages.ClientMessage{Type: "move", X: to.X, Y: to.Y})
+		moved, err := a.Expect("playerMoved", 0)
+		if err != nil {
+			return err
+		}
+		if want := land(to, dir); *moved.Position != want {
+			return fmt.Errorf("stepping %s onto %s at (%d, %d) landed on %+v, want %+v",
+				dir, terrain(to), to.X, to.Y, *moved.Position, want)
+		}
+	}
+	return nil
+}
+
+// shiftingMaze waits out the first shift of a shifting room: the warning
+// says what changes, the update follows, and both players can still get out
+func shiftingMaze(h *harness.Harness) error {
+	var clients []*harness.Client
+	for range 2 {
+		c, err := h.Connect("")
+		if err != nil {
+			return err
+		}
+		c.Send(messages.ClientMessage{Type: "join", RoomID: "shifting", Seed: 14, ShiftSeconds: 1})
+		if _, err := c.Expect("mazeData", 0); err != nil {
+			return err
+		}
+		c.Send(messages.ClientMessage{Type: "ready"})
+		clients = append(clients, c)
+	}
+	a := clients[0]
+	if err := awaitPlaying(clients...); err != nil {
+		return err
+	}
+
+	// Shifts are at least MinMazeShiftInterval apart
+	warning, err := a.Await("mazeShifting", room.MinMazeShiftInterval+time.Second)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains([]string{room.ShiftRotate, room.ShiftToggle, room.ShiftWall}, warning.Message) || warning.Seconds < 1 {
+		return fmt.Errorf("warned of %q in %ds", warning.Message, warning.Seconds)
+	}
+	before := make([][]messages.Cell, len(a.Maze.Cells))
+	for y, row := range a.Maze.Cells {
+		before[y] = slices.Clone(row)
+	}
+	update, err := a.Await("mazeUpdated", room.MazeShiftWarning+time.Second)
+	if err != nil {
+		return err
+	}
+	changed := false
+	for _, c := range update.Cells {
+		changed = changed || c != before[c.Y][c.X]
+	}
+	if !changed {
+		return fmt.Errorf("the %s shift changed nothing", warning.Message)
+	}
+
+	for _, p := range h.Server.Rooms().GetRoom("shifting").GetPlayers() {
+		if path, err := a.PathToGoal(p.X, p.Y); err != nil || len(path) == 0 {
+			return fmt.Errorf("player %s is cut off from the exit after the shift: %v", p.ID, err)
+		}
+	}
+	return nil
+}
+
+// minotaurHunt puts a practice player right on a minotaur, which must
+// catch them and send them back to spawn
+func minotaurHunt(h *harness.Harness) error {
+	a, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	a.Send(messages.ClientMessage{Type: "join", RoomID: "labyrinth", Practice: true, Seed: 15, Minotaur: room.MinotaurEasy.Name})
+	if _, err := a.Expect("mazeData", 0); err != nil {
+		return err
+	}
+	a.Send(messages.ClientMessage{Type: "ready"})
+	if err := a.Sync(); err != nil {
+		return err
+	}
+	moved, err := a.Await("hazardsMoved", room.CountdownDuration+time.Second)
+	if err != nil {
+		return err
+	}
+	if len(moved.Hazards) != room.MinotaurEasy.Count {
+		return fmt.Errorf("%d hazards, want %d", len(moved.Hazards), room.MinotaurEasy.Count)
+	}
+
+	m := moved.Hazards[0]
+	a.Send(messages.ClientMessage{Type: "sandbox", Command: room.SandboxTeleport, X: m.X, Y: m.Y})
+	if err := a.Sync(); err != nil {
+		return err
+	}
+	caught, err := a.Await("minotaurCaught", 3*room.MinotaurEasy.MoveInterval)
+	if err != nil {
+		return err
+	}
+	if caught.Message != a.ID {
+		return fmt.Errorf("minotaur caught %q", caught.Message)
+	}
+	spawn := a.Maze.Spawns[0]
+	for _, p := range caught.Players {
+		if p.ID == a.ID && (p.X != spawn.X || p.Y != spawn.Y || p.Score != 0) {
+			return fmt.Errorf("caught player at (%d, %d) with %d points, want back at spawn %+v with none", p.X, p.Y, p.Score, spawn)
+		}
+	}
+	return nil
+}
+
+// mazeFiles has an admin load a hand-drawn maze into a room: players are
+// sent it as the round's maze, an unsolvable one or one with cells nobody
+// can reach is refused, and the admin API exports it as text and JSON and
+// takes it back, until play starts
+func mazeFiles(h *harness.Harness) error {
+	const design = `
+# Two spawns up top, the exit down below, portals either side
++---+---+---+---+---+
+| S       m       S |
++---+---+   +---+---+
+| p           i   p |
++   +---+---+---+   +
+|         G         |
++---+---+---+---+---+
+portal 0 1 4 1
+`
+	h.Server.AdminToken = "secret"
+	call := func(method, path, contentType, body string, want int) (*httptest.ResponseRecorder, error) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		h.Server.HandleAdminRooms(rec, req)
+		if rec.Code != want {
+			return rec, fmt.Errorf("%s %s answered %d, want %d: %s", method, path, rec.Code, want, rec.Body)
+		}
+		return rec, nil
+	}
+
+	admin, err := h.ConnectWith(server.Handshake{
+		Protocol: messages.ProtocolVersion,
+		Claims:   &auth.Claims{Subject: "ada", Roles: []string{auth.RoleAdmin}},
+	})
+	if err != nil {
+		return err
+	}
+	player, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	for _, c := range []*harness.Client{admin, player} {
+		c.Send(messages.ClientMessage{Type: "join", RoomID: "custom"})
+		if _, err := c.Expect("mazeData", 0); err != nil {
+			return err
+		}
+	}
+
+	player.Send(messages.ClientMessage{Type: "loadMaze", MazeFile: design})
+	if msg, err := player.Expect("error", 0); err != nil {
+		return err
+	} else if msg.Error != server.ErrCodeNotAdmin {
+		return fmt.Errorf("loadMaze by a player got %s, want %s", msg.Error, server.ErrCodeNotAdmin)
+	}
+	walledIn := strings.Replace(design, "+---+---+   +---+---+", "+---+---+---+---+---+", 1)
+	admin.Send(messages.ClientMessage{Type: "loadMaze", MazeFile: walledIn})
+	if msg, err := admin.Expect("error", 0); err != nil {
+		return err
+	} else if msg.Error != server.ErrCodeBadRequest || !strings.Contains(msg.Message, "can't reach an exit") {
+		return fmt.Errorf("unsolvable maze got %s: %s", msg.Error, msg.Message)
+	}
+	sealed := strings.Replace(design, "+   +---+---+---+   +\n|         G", "+---+---+---+---+   +\n|   |     G", 1)
+	admin.Send(messages.ClientMessage{Type: "loadMaze", MazeFile: sealed})
+	if msg, err := admin.Expect("error", 0); err != nil {
+		return err
+	} else if msg.Error != server.ErrCodeBadRequest || !strings.Contains(msg.Message, "can't be reached") {
+		return fmt.Errorf("maze with a sealed cell got %s: %s", msg.Error, msg.Message)
+	}
+
+	admin.Send(messages.ClientMessage{Type: "loadMaze", RoomID: "custom", MazeFile: design})
+	for _, c := range []*harness.Client{admin, player} {
+		msg, err := c.Expect("newRound", 0)
+		if err != nil {
+			return err
+		}
+		m := msg.Maze
+		if m.Width != 5 || m.Height != 3 || m.Goal != (messages.Position{X: 2, Y: 2}) || len(m.Spawns) != 2 ||
+			m.Cells[1][0].Terrain != "portal" || len(m.Portals) != 1 {
+			return fmt.Errorf("%s was sent a %dx%d maze exiting at %v with %d spawns", c.ID, m.Width, m.Height, m.Goal, len(m.Spawns))
+		}
+	}
+
+	drawn, err := game.ImportMaze([]byte(design), "")
+	if err != nil {
+		return err
+	}
+	rec, err := call(http.MethodGet, "/admin/rooms/custom/maze?format=text", "", "", http.StatusOK)
+	if err != nil {
+		return err
+	}
+	if rec.Body.String() != drawn.Text() {
+		return fmt.Errorf("exported as\n%s\nwant\n%s", rec.Body, drawn.Text())
+	}
+	rec, err = call(http.MethodGet, "/admin/rooms/custom/maze", "", "", http.StatusOK)
+	if err != nil {
+		return err
+	}
+	if _, err := call(http.MethodPut, "/admin/rooms/custom/maze", "application/json", rec.Body.String(), http.StatusNoContent); err != nil {
+		return err
+	}
+	if _, err := call(http.MethodPut, "/admin/rooms/custom/maze", "text/plain", walledIn, http.StatusBadRequest); err != nil {
+		return err
+	}
+	if _, err := call(http.MethodPut, "/admin/rooms/custom/maze", "text/plain", sealed, http.StatusBadRequest); err != nil {
+		return err
+	}
+
+	for _, c := range []*harness.Client{admin, player} {
+		c.Send(messages.ClientMessage{Type: "ready"})
+	}
+	if err := awaitPlaying(player, admin); err != nil {
+		return err
+	}
+	if _, err := call(http.MethodPut, "/admin/rooms/custom/maze", "", design, http.StatusConflict); err != nil {
+		return err
+	}
+	admin.Send(messages.ClientMessage{Type: "loadMaze", MazeFile: design})
+	if msg, err := admin.Expect("error", 0); err != nil {
+		return err
+	} else if msg.Error != server.ErrCodeInvalidAction {
+		return fmt.Errorf("loadMaze mid-round got %s, want %s", msg.Error, server.ErrCodeInvalidAction)
+	}
+	if maze := h.Server.Rooms().GetRoom("custom").GetMaze(); maze.Algorithm != game.AlgorithmCustom || maze.Width != 5 {
+		return fmt.Errorf("playing a %dx%d %s maze", maze.Width, maze.Height, maze.Algorithm)
+	}
+	return nil
+}
+
+// levelUpload has a player upload mazes from an editor: too small or partly
+// walled off ones are refused, a good one gets a level ID, and rooms
+// created with it play that maze
+func levelUpload(h *harness.Harness) error {
+	const walledOff = `
++---+---+---+---+---+
+| S                 |
++   +   +   +   +   +
+|                   |
++   +   +   +   +   +
+|       G           |
++   +   +   +   +   +
+|                   |
++   +   +   +---+   +
+|           |   |   |
++---+---+---+---+---+
+`
+	const tiny = `
++---+---+
+| S   G |
++---+---+
+`
+	designer, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	for _, file := range []string{tiny, walledOff} {
+		designer.Send(messages.ClientMessage{Type: "uploadMaze", MazeFile: file})
+		if msg, err := designer.Expect("error", 0); err != nil {
+			return err
+		} else if msg.Error != server.ErrCodeBadRequest || !strings.HasPrefix(msg.Message, "bad level: ") {
+			return fmt.Errorf("unplayable level got %s: %s", msg.Error, msg.Message)
+		}
+	}
+
+	// The editor's layout has the fields of mazeData
+	var layout messages.MazeData
+	data, err := json.Marshal(game.Generate(9, 7, game.Options{Seed: 818, GoalCount: 2}))
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &layout); err != nil {
+		return err
+	}
+	designer.Send(messages.ClientMessage{Type: "uploadMaze", RequestID: "up", Name: "  Twin exits  ", Layout: &layout})
+	uploaded, err := designer.Expect("mazeUploaded", 0)
+	if err != nil {
+		return err
+	}
+	lvl := uploaded.Level
+	if lvl == nil || lvl.ID == "" || lvl.Name != "Twin exits" || lvl.Author != designer.ID || lvl.Width != 9 || lvl.Height != 7 ||
+		uploaded.RequestID != "up" {
+		return fmt.Errorf("uploaded as %+v", lvl)
+	}
+
+	designer.Send(messages.ClientMessage{Type: "join", RoomID: "levels", LevelID: "nosuchlevel"})
+	if msg, err := designer.Expect("joinRejected", 0); err != nil {
+		return err
+	} else if msg.Reason != "noLevel" || msg.Error != server.ErrCodeNotFound {
+		return fmt.Errorf("join with an unknown level rejected as %s/%s", msg.Reason, msg.Error)
+	}
+
+	player, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	designer.Send(messages.ClientMessage{Type: "join", RoomID: "levels", LevelID: lvl.ID, MapVeto: true})
+	for _, c := range []*harness.Client{designer, player} {
+		if c == player {
+			c.Send(messages.ClientMessage{Type: "join", RoomID: "levels"})
+		}
+		msg, err := c.Expect("mazeData", 0)
+		if err != nil {
+			return err
+		}
+		m := msg.Maze
+		if m.Width != layout.Width || m.Height != layout.Height || m.Goal != layout.Goal || len(m.Goals) != 2 ||
+			!slices.Equal(m.Spawns, layout.Spawns) {
+			return fmt.Errorf("%s was sent a %dx%d maze exiting at %v, not the level", c.ID, m.Width, m.Height, m.Goal)
+		}
+	}
+
+	player.Send(messages.ClientMessage{Type: "listRooms"})
+	list, err := player.Expect("roomList", 0)
+	if err != nil {
+		return err
+	}
+	if len(list.Rooms) != 1 || list.Rooms[0].Level != "Twin exits" {
+		return fmt.Errorf("listed %+v, want the room playing Twin exits", list.Rooms)
+	}
+
+	// Readying up goes straight to the countdown: there's no veto on a level
+	for _, c := range []*harness.Client{designer, player} {
+		c.Send(messages.ClientMessage{Type: "ready"})
+		if err := c.Sync(); err != nil {
+			return err
+		}
+	}
+	if _, err := player.Await("countdown", time.Second); err != nil {
+		return err
+	}
+	return nil
+}
+
+// mazeDifficulty creates rooms asking for easy and hard mazes and checks
+// the rating sent with each, then has the host switch tiers
+func mazeDifficulty(h *harness.Harness) error {
+	host, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	for _, tier := range []string{game.DifficultyEasy, game.DifficultyHard} {
+		roomID := "difficulty-" + tier
+		host.Send(messages.ClientMessage{Type: "join", RoomID: roomID, MazeAlgorithm: "wilson", Difficulty: tier})
+		msg, err := host.Expect("mazeData", 0)
+		if err != nil {
+			return err
+		}
+		rating := msg.Maze.Rating
+		if rating == nil || rating.Tier != tier || rating.SolutionLength <= 0 {
+			return fmt.Errorf("room %s asking for %s mazes was sent one rated %+v", roomID, tier, rating)
+		}
+		if want := game.DifficultyOf(rating.Score); want != tier {
+			return fmt.Errorf("score %d is rated %s, want %s", rating.Score, tier, want)
+		}
+	}
+
+	// The host is left in the hard room
+	host.Send(messages.ClientMessage{Type: "mazeSettings", Difficulty: "nightmare"})
+	if msg, err := host.Expect("error", 0); err != nil {
+		return err
+	} else if msg.Error != server.ErrCodeBadRequest {
+		return fmt.Errorf("unknown difficulty got %s, want %s", msg.Error, server.ErrCodeBadRequest)
+	}
+	host.Send(messages.ClientMessage{Type: "mazeSettings", Difficulty: game.DifficultyMedium})
+	round, err := host.Expect("newRound", 0)
+	if err != nil {
+		return err
+	}
+	if round.Maze.Rating == nil || round.Maze.Rating.Tier != game.DifficultyMedium {
+		return fmt.Errorf("after switching to medium the maze is rated %+v", round.Maze.Rating)
+	}
+	return nil
+}
+
+// mazeFloors creates a room whose mazes have three floors: a client that
+// didn't ask for floors is turned away, the maze arrives with every floor
+// joined by stairs to the next, and a player walks and climbs from the
+// ground floor to the exit on the top one
+func mazeFloors(h *harness.Harness) error {
+	a, err := h.ConnectWith(server.Handshake{Protocol: messages.ProtocolVersion, Caps: []string{messages.CapFloors}})
+	if err != nil {
+		return err
+	}
+	b, err := h.ConnectWith(server.Handshake{Protocol: messages.ProtocolVersion, Caps: []string{messages.CapFloors}})
+	if err != nil {
+		return err
+	}
+	flat, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+
+	a.Send(messages.ClientMessage{Type: "join", RoomID: "floors", Seed: 11, Floors: 3})
+	data, err := a.Expect("mazeData", 0)
+	if err != nil {
+		return err
+	}
+	m := data.Maze
+	if m.Floors != 3 || len(m.Upper) != 2 || m.Goal.Z != 2 {
+		return fmt.Errorf("maze has %d floors (%d above ground) and its exit on floor %d, want 3, 2 and 2", m.Floors, len(m.Upper), m.Goal.Z)
+	}
+	floors := append([][][]messages.Cell{m.Cells}, m.Upper...)
+	for z := 0; z+1 < len(floors); z++ {
+		stairs := 0
+		for y, row := range floors[z] {
+			for x, c := range row {
+				if c.StairsUp != floors[z+1][y][x].StairsDown {
+					return fmt.Errorf("stairs up from (%d,%d,%d) don't land on stairs down", x, y, z)
+				}
+				if c.StairsUp {
+					stairs++
+				}
+			}
+		}
+		if stairs == 0 {
+			return fmt.Errorf("no stairs from floor %d to floor %d", z, z+1)
+		}
+	}
+	compact := m.Compacted()
+	if err := compact.Expand(); err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(compact.Upper, m.Upper) || !reflect.DeepEqual(compact.Cells, m.Cells) {
+		return fmt.Errorf("floors changed going through the compact encoding")
+	}
+
+	flat.Send(messages.ClientMessage{Type: "join", RoomID: "floors"})
+	if rejected, err := flat.Expect("joinRejected", 0); err != nil {
+		return err
+	} else if rejected.Error != server.ErrCodeUnsupported {
+		return fmt.Errorf("room with floors turned a flat client away with %s, want %s", rejected.Error, server.ErrCodeUnsupported)
+	}
+
+	b.Send(messages.ClientMessage{Type: "join", RoomID: "floors"})
+	if _, err := b.Expect("mazeData", 0); err != nil {
+		return err
+	}
+	a.Send(messages.ClientMessage{Type: "ready"})
+	b.Send(messages.ClientMessage{Type: "ready"})
+	if err := awaitPlaying(a, b); err != nil {
+		return err
+	}
+
+	spawn := m.Spawns[0]
+	path, err := a.PathToGoal(spawn.X, spawn.Y)
+	if err != nil {
+		return err
+	}
+	climbs, at := 0, spawn
+	for _, p := range path {
+		h.Clock.Advance(room.BaseMoveInterval)
+		if p.Z > at.Z {
+			// Climb by direction, walk the rest by cell
+			climbs++
+			a.Send(messages.ClientMessage{Type: "moveDir", Direction: game.DirectionUpstairs})
+		} else {
+			a.Send(messages.ClientMessage{Type: "move", X: p.X, Y: p.Y, Z: p.Z})
+		}
+		if err := a.Sync(); err != nil {
+			return err
+		}
+		at = p
+	}
+	if climbs < 2 {
+		return fmt.Errorf("the way to the exit climbed %d flights of stairs, want at least 2", climbs)
+	}
+
+	over, err := a.Expect("gameOver", 0)
+	if err != nil {
+		return err
+	}
+	if over.Winner != a.ID || over.Reason != "goal" {
+		return fmt.Errorf("gameOver winner=%q reason=%q, want %q by goal", over.Winner, over.Reason, a.ID)
+	}
+	return nil
+}
+
+// weaveRoom has a room hand out a weave maze, whose crossings must come
+// through the compact encoding intact
+func weaveRoom(h *harness.Harness) error {
+	a, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	a.Send(messages.ClientMessage{Type: "join", RoomID: "weave", MazeAlgorithm: game.AlgorithmWeave, Seed: 5})
+	data, err := a.Expect("mazeData", 0)
+	if err != nil {
+		return err
+	}
+	if data.Maze.Algorithm != game.AlgorithmWeave {
+		return fmt.Errorf("room generated a %q maze, want %q", data.Maze.Algorithm, game.AlgorithmWeave)
+	}
+	tunnels := 0
+	for _, row := range data.Maze.Cells {
+		for _, c := range row {
+			if c.Under != "" {
+				tunnels++
+			}
+		}
+	}
+	if tunnels == 0 {
+		return fmt.Errorf("room's weave maze arrived without crossings")
+	}
+	compact := data.Maze.Compacted()
+	if err := compact.Expand(); err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(compact.Cells, data.Maze.Cells) {
+		return fmt.Errorf("crossings changed going through the compact encoding")
+	}
+	return nil
+}
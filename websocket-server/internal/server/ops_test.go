@@ -0,0 +1,563 @@
+package server_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"labyrinth-duel/websocket/internal/bus"
+	"labyrinth-duel/websocket/internal/daily"
+	"labyrinth-duel/websocket/internal/harness"
+	"labyrinth-duel/websocket/internal/leaderboard"
+	"labyrinth-duel/websocket/internal/messages"
+	"labyrinth-duel/websocket/internal/persist"
+	"labyrinth-duel/websocket/internal/room"
+	"labyrinth-duel/websocket/internal/server"
+)
+
+func TestOperations(t *testing.T) {
+	runScenarios(t, []scenario{
+		{"a restarted server restores a game in progress", restoreRooms},
+		{"players on two servers race in one room", sharedRoom},
+		{"a player on another server keeps the features they asked for", remoteCaps},
+		{"a draining server stops reporting ready", drainReadiness},
+		{"an operator kicks a player, announces and closes the room", adminAPI},
+		{"daily challengers share a maze and a leaderboard of times", dailyChallenge},
+		{"a win climbs the leaderboards, which the lobby is sent", leaderboards},
+		{"a finished match is in both players' history and stats", matchHistory},
+	})
+}
+
+// restoreRooms stops a server mid-match and has a second one, sharing its
+// room store, bring the match back with the players where they were
+func restoreRooms(h *harness.Harness) error {
+	store := persist.NewMemoryStore()
+	h.Server.RoomStore = store
+
+	a, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	b, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	a.Send(messages.ClientMessage{Type: "join", RoomID: "persist", Seed: 5})
+	if _, err := a.Expect("mazeData", 0); err != nil {
+		return err
+	}
+	b.Send(messages.ClientMessage{Type: "join", RoomID: "persist"})
+	if _, err := b.Expect("mazeData", 0); err != nil {
+		return err
+	}
+	a.Send(messages.ClientMessage{Type: "ready"})
+	b.Send(messages.ClientMessage{Type: "ready"})
+	if err := awaitPlaying(a, b); err != nil {
+		return err
+	}
+
+	path, err := a.PathToGoal(0, 0)
+	if err != nil {
+		return err
+	}
+	at := path[2]
+	if err := a.Walk(path[:3], room.BaseMoveInterval); err != nil {
+		return err
+	}
+	h.Server.Stop()
+
+	h2 := h.Sibling()
+	defer h2.Close()
+	h2.Server.RoomStore = store
+	if err := h2.Server.RestoreRooms(); err != nil {
+		return err
+	}
+	back, err := h2.Resume(a)
+	if err != nil {
+		return err
+	}
+	back.Send(messages.ClientMessage{Type: "join", RoomID: "persist"})
+	data, err := back.Expect("mazeData", 0)
+	if err != nil {
+		return err
+	}
+	if data.Maze.Seed != 5 || data.State != string(room.StatePlaying) {
+		return fmt.Errorf("restored room has seed %d in state %q, want 5 playing", data.Maze.Seed, data.State)
+	}
+	for _, p := range data.Players {
+		if p.ID == a.ID && (p.X != at.X || p.Y != at.Y) {
+			return fmt.Errorf("rejoined at (%d, %d), want (%d, %d)", p.X, p.Y, at.X, at.Y)
+		}
+	}
+	if len(data.Players) != 2 {
+		return fmt.Errorf("restored room has %d players, want both", len(data.Players))
+	}
+	return nil
+}
+
+// sharedRoom joins two servers over a bus: a player on the second joins a
+// room hosted by the first and wins the race there
+func sharedRoom(h *harness.Harness) error {
+	local := bus.NewLocal()
+	defer local.Close()
+	if err := h.Server.JoinCluster("one", local, local); err != nil {
+		return err
+	}
+	h2 := h.Sibling()
+	defer h2.Close()
+	if err := h2.Server.JoinCluster("two", local, local); err != nil {
+		return err
+	}
+
+	a, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	b, err := h2.Connect("")
+	if err != nil {
+		return err
+	}
+	a.Send(messages.ClientMessage{Type: "join", RoomID: "shared", Seed: 11})
+	if _, err := a.Expect("mazeData", 0); err != nil {
+		return err
+	}
+	b.Send(messages.ClientMessage{Type: "join", RoomID: "shared"})
+	data, err := b.Expect("mazeData", 0)
+	if err != nil {
+		return err
+	}
+	if data.Maze.Seed != 11 || len(data.Players) != 2 {
+		return fmt.Errorf("remote join saw seed %d and %d players, want 11 and 2", data.Maze.Seed, len(data.Players))
+	}
+	if h2.Server.Rooms().GetRoom("shared") != nil {
+		return fmt.Errorf("room is hosted on both servers")
+	}
+
+	a.Send(messages.ClientMessage{Type: "ready"})
+	b.Send(messages.ClientMessage{Type: "ready"})
+	// The remote ready crosses the bus, so wait for the room to hear it
+	if _, err := a.Expect("gameStarting", 0); err != nil {
+		return err
+	}
+	if err := awaitPlaying(a); err != nil {
+		return err
+	}
+
+	// The remote player races; the hosting server validates every step.
+	// Steps cross the bus, so each is awaited on the host before the next;
+	// the last ends the match
+	var from messages.Position
+	for _, p := range data.Players {
+		if p.ID == b.ID {
+			from = messages.Position{X: p.X, Y: p.Y}
+		}
+	}
+	path, err := b.PathToGoal(from.X, from.Y)
+	if err != nil {
+		return err
+	}
+	for i, p := range path {
+		h.Clock.Advance(room.BaseMoveInterval)
+		b.Send(messages.ClientMessage{Type: "move", X: p.X, Y: p.Y})
+		if i == len(path)-1 {
+			break
+		}
+		if _, err := a.Expect("playerMoved", 0); err != nil {
+			return err
+		}
+	}
+	over, err := a.Expect("gameOver", 0)
+	if err != nil {
+		return err
+	}
+	if over.Winner != b.ID {
+		return fmt.Errorf("gameOver winner=%q, want the remote player %q", over.Winner, b.ID)
+	}
+
+	b.Disconnect()
+	left, err := a.Expect("playerLeft", 0)
+	if err != nil {
+		return err
+	}
+	if left.Message != b.ID {
+		return fmt.Errorf("playerLeft for %q, want %q", left.Message, b.ID)
+	}
+	return nil
+}
+
+// remoteCaps has a player on a second server, who asked for floors and
+// compact mazes, join a room with floors hosted by the first: they are let
+// in and sent the maze compacted
+func remoteCaps(h *harness.Harness) error {
+	local := bus.NewLocal()
+	defer local.Close()
+	if err := h.Server.JoinCluster("one", local, local); err != nil {
+		return err
+	}
+	h2 := h.Sibling()
+	defer h2.Close()
+	if err := h2.Server.JoinCluster("two", local, local); err != nil {
+		return err
+	}
+
+	a, err := h.ConnectWith(server.Handshake{Protocol: messages.ProtocolVersion, Caps: []string{messages.CapFloors}})
+	if err != nil {
+		return err
+	}
+	b, err := h2.ConnectWith(server.Handshake{Protocol: messages.ProtocolVersion,
+		Caps: []string{messages.CapFloors, messages.CapCompactMaze}})
+	if err != nil {
+		return err
+	}
+	a.Send(messages.ClientMessage{Type: "join", RoomID: "tower", Seed: 11, Floors: 2})
+	if _, err := a.Expect("mazeData", 0); err != nil {
+		return err
+	}
+	b.Send(messages.ClientMessage{Type: "join", RoomID: "tower"})
+	data, err := b.Expect("mazeData", 0)
+	if err != nil {
+		return err
+	}
+	if m := data.Maze; m.Floors != 2 || m.Cells != nil || m.Walls == "" {
+		return fmt.Errorf("remote player was sent %d floors with %d rows and walls %q, want 2 compacted", m.Floors, len(m.Cells), m.Walls)
+	}
+	return nil
+}
+
+// drainReadiness checks the health and readiness probes through a drain
+// and a stop
+func drainReadiness(h *harness.Harness) error {
+	a, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	a.Send(messages.ClientMessage{Type: "join", RoomID: "drain"})
+	if _, err := a.Expect("mazeData", 0); err != nil {
+		return err
+	}
+
+	probe := func(handler http.HandlerFunc, path string, want int) error {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != want {
+			return fmt.Errorf("%s answered %d, want %d: %s", path, rec.Code, want, rec.Body)
+		}
+		return nil
+	}
+	both := func(health, ready int) error {
+		if err := probe(h.Server.HandleHealth, "/healthz", health); err != nil {
+			return err
+		}
+		return probe(h.Server.HandleReady, "/readyz", ready)
+	}
+
+	if err := both(http.StatusOK, http.StatusOK); err != nil {
+		return err
+	}
+	h.Server.Drain()
+	if err := both(http.StatusOK, http.StatusServiceUnavailable); err != nil {
+		return err
+	}
+
+	// Players already in a room carry on while the server drains
+	a.Send(messages.ClientMessage{Type: "chat", Text: "still here"})
+	if _, err := a.Expect("chat", 0); err != nil {
+		return err
+	}
+
+	h.Server.Stop()
+	return both(http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+}
+
+// adminAPI has an operator dump a room, kick a player, announce and close
+// the room through the admin API
+func adminAPI(h *harness.Harness) error {
+	h.Server.AdminToken = "secret"
+	call := func(handler http.HandlerFunc, method, path, body string, want int) (*httptest.ResponseRecorder, error) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		if rec.Code != want {
+			return rec, fmt.Errorf("%s %s answered %d, want %d: %s", method, path, rec.Code, want, rec.Body)
+		}
+		return rec, nil
+	}
+
+	a, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	b, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	for _, c := range []*harness.Client{a, b} {
+		c.Send(messages.ClientMessage{Type: "join", RoomID: "admin"})
+		if _, err := c.Expect("mazeData", 0); err != nil {
+			return err
+		}
+	}
+
+	rec, err := call(h.Server.HandleAdminRooms, http.MethodGet, "/admin/rooms/admin", "", http.StatusOK)
+	if err != nil {
+		return err
+	}
+	var dump room.Dump
+	if err := json.Unmarshal(rec.Body.Bytes(), &dump); err != nil {
+		return err
+	}
+	if len(dump.Connected) != 2 || len(dump.Saved.Players) != 2 || dump.Saved.Maze == nil {
+		return fmt.Errorf("dump shows %d connected, %d players", len(dump.Connected), len(dump.Saved.Players))
+	}
+
+	if _, err := call(h.Server.HandleAdminPlayers, http.MethodPost, "/admin/players/"+b.ID+"/kick?reason=spam", "", http.StatusNoContent); err != nil {
+		return err
+	}
+	kicked, err := b.Expect("kicked", 0)
+	if err != nil {
+		return err
+	}
+	if kicked.Reason != "admin" || kicked.Message != "spam" {
+		return fmt.Errorf("kicked for %q (%q), want admin (spam)", kicked.Reason, kicked.Message)
+	}
+	if _, err := a.Expect("playerLeft", 0); err != nil {
+		return err
+	}
+	if _, err := call(h.Server.HandleAdminPlayers, http.MethodPost, "/admin/players/nobody/kick", "", http.StatusNotFound); err != nil {
+		return err
+	}
+
+	if _, err := call(h.Server.HandleAdminAnnounce, http.MethodPost, "/admin/announce", `{"message":"restart at noon"}`, http.StatusNoContent); err != nil {
+		return err
+	}
+	if msg, err := a.Expect("announcement", 0); err != nil || msg.Message != "restart at noon" {
+		return fmt.Errorf("announcement %q: %v", msg.Message, err)
+	}
+
+	if _, err := call(h.Server.HandleAdminRooms, http.MethodDelete, "/admin/rooms/admin?reason=maintenance", "", http.StatusNoContent); err != nil {
+		return err
+	}
+	if msg, err := a.Expect("roomClosed", 0); err != nil || msg.Message != "maintenance" {
+		return fmt.Errorf("roomClosed %q: %v", msg.Message, err)
+	}
+	if h.Server.Rooms().GetRoom("admin") != nil {
+		return fmt.Errorf("closed room is still there")
+	}
+
+	// Unauthenticated callers get nothing
+	req := httptest.NewRequest(http.MethodGet, "/admin/rooms", nil)
+	unauth := httptest.NewRecorder()
+	h.Server.HandleAdminRooms(unauth, req)
+	if unauth.Code != http.StatusUnauthorized {
+		return fmt.Errorf("unauthenticated admin call answered %d", unauth.Code)
+	}
+	return nil
+}
+
+// dailyChallenge has two players start today's challenge: both get the
+// same maze, and the one who finishes tops the day's leaderboard
+func dailyChallenge(h *harness.Harness) error {
+	a, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	b, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	var seeds []int64
+	for _, c := range []*harness.Client{a, b} {
+		c.Send(messages.ClientMessage{Type: "startDaily"})
+		joined, err := c.Expect("mazeData", 0)
+		if err != nil {
+			return err
+		}
+		seeds = append(seeds, joined.Maze.Seed)
+	}
+	today := daily.Seed(daily.Day(h.Clock.Now()))
+	if seeds[0] != seeds[1] || seeds[0] != today {
+		return fmt.Errorf("daily mazes have seeds %v, want today's %d", seeds, today)
+	}
+
+	a.Send(messages.ClientMessage{Type: "ready"})
+	if err := awaitPlaying(a); err != nil {
+		return err
+	}
+	spawn := a.Maze.Spawns[0]
+	path, err := a.PathToGoal(spawn.X, spawn.Y)
+	if err != nil {
+		return err
+	}
+	if err := a.Walk(path, room.BaseMoveInterval); err != nil {
+		return err
+	}
+	run, err := a.Expect("dailyLeaderboard", 0)
+	if err != nil {
+		return err
+	}
+	if you := run.Daily.You; you == nil || you.Rank != 1 || !run.Daily.Improved || you.TimeMs <= 0 {
+		return fmt.Errorf("finished run recorded as %+v", run.Daily)
+	}
+
+	b.Send(messages.ClientMessage{Type: "dailyLeaderboard"})
+	board, err := b.Expect("dailyLeaderboard", 0)
+	if err != nil {
+		return err
+	}
+	if len(board.Daily.Entries) != 1 || board.Daily.Entries[0].PlayerID != a.ID || board.Daily.You != nil {
+		return fmt.Errorf("leaderboard %+v, want only %s on it", board.Daily, a.ID)
+	}
+	b.Send(messages.ClientMessage{Type: "dailyLeaderboard", Day: "yesterday"})
+	if _, err := b.Expect("error", 0); err != nil {
+		return err
+	}
+	return nil
+}
+
+// leaderboards plays one match and reads the boards back, paged, and as
+// the lobby is sent them
+func leaderboards(h *harness.Harness) error {
+	a, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	b, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	for _, c := range []*harness.Client{a, b} {
+		c.Send(messages.ClientMessage{Type: "join", RoomID: "ranks", Seed: 42})
+		if _, err := c.Expect("mazeData", 0); err != nil {
+			return err
+		}
+		c.Send(messages.ClientMessage{Type: "ready"})
+	}
+	if err := awaitPlaying(a, b); err != nil {
+		return err
+	}
+	path, err := a.PathToGoal(0, 0)
+	if err != nil {
+		return err
+	}
+	if err := a.Walk(path, room.BaseMoveInterval); err != nil {
+		return err
+	}
+	if _, err := b.Expect("gameOver", 0); err != nil {
+		return err
+	}
+
+	query := func(board string, offset, limit int) (*messages.Leaderboard, error) {
+		b.Send(messages.ClientMessage{Type: "leaderboard", Board: board, Mode: leaderboard.ModeClassic, Offset: offset, Limit: limit})
+		msg, err := b.Expect("leaderboard", 0)
+		if err != nil {
+			return nil, err
+		}
+		return msg.Leaderboard, nil
+	}
+	fastest, err := query(leaderboard.BoardFastest, 0, 0)
+	if err != nil {
+		return err
+	}
+	if fastest.Total != 1 || fastest.Entries[0].PlayerID != a.ID || fastest.Entries[0].BestTimeMs <= 0 {
+		return fmt.Errorf("fastest board %+v, want only %s with a time", fastest, a.ID)
+	}
+	second, err := query(leaderboard.BoardRating, 1, 1)
+	if err != nil {
+		return err
+	}
+	if second.Total != 2 || len(second.Entries) != 1 || second.Entries[0].Rank != 2 || second.Entries[0].PlayerID != b.ID {
+		return fmt.Errorf("second page of the rating board %+v, want %s second of 2", second, b.ID)
+	}
+	b.Send(messages.ClientMessage{Type: "leaderboard", Board: "longest"})
+	if _, err := b.Expect("error", 0); err != nil {
+		return err
+	}
+
+	lobby, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	h.Server.BroadcastLeaderboard()
+	top, err := lobby.Expect("leaderboard", 0)
+	if err != nil {
+		return err
+	}
+	if board := top.Leaderboard; board.Board != leaderboard.BoardWins || board.Mode != leaderboard.ModeAll ||
+		len(board.Entries) == 0 || board.Entries[0].PlayerID != a.ID || board.Entries[0].Wins != 1 {
+		return fmt.Errorf("lobby was sent %+v, want %s top of the overall wins board", board, a.ID)
+	}
+	return nil
+}
+
+// matchHistory plays one match and reads it back from both players'
+// history and the winner's stats
+func matchHistory(h *harness.Harness) error {
+	a, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	b, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	for _, c := range []*harness.Client{a, b} {
+		c.Send(messages.ClientMessage{Type: "join", RoomID: "history", Seed: 42})
+		if _, err := c.Expect("mazeData", 0); err != nil {
+			return err
+		}
+		c.Send(messages.ClientMessage{Type: "ready"})
+	}
+	if err := awaitPlaying(a, b); err != nil {
+		return err
+	}
+	path, err := a.PathToGoal(0, 0)
+	if err != nil {
+		return err
+	}
+	if err := a.Walk(path, room.BaseMoveInterval); err != nil {
+		return err
+	}
+	if _, err := b.Expect("gameOver", 0); err != nil {
+		return err
+	}
+
+	for _, c := range []*harness.Client{a, b} {
+		c.Send(messages.ClientMessage{Type: "matchHistory", PlayerID: a.ID})
+		msg, err := c.Expect("matchHistory", 0)
+		if err != nil {
+			return err
+		}
+		if msg.History.Total != 1 || len(msg.History.Matches) != 1 {
+			return fmt.Errorf("%s sees %d matches of %d in %s's history, want 1", c.ID, len(msg.History.Matches), msg.History.Total, a.ID)
+		}
+		m := msg.History.Matches[0]
+		if m.Winner != a.ID || m.Seed != 42 || m.Reason != "goal" || len(m.Players) != 2 || m.Duration <= 0 {
+			return fmt.Errorf("history has %+v", m)
+		}
+		for _, p := range m.Players {
+			if p.ID == a.ID && (p.Moves != len(path) || !p.Won) {
+				return fmt.Errorf("winner recorded as %+v, want %d moves", p, len(path))
+			}
+		}
+	}
+	b.Send(messages.ClientMessage{Type: "matchHistory", Offset: 1})
+	if msg, err := b.Expect("matchHistory", 0); err != nil {
+		return err
+	} else if msg.History.Total != 1 || len(msg.History.Matches) != 0 {
+		return fmt.Errorf("second page of %s's history %+v, want empty", b.ID, msg.History)
+	}
+
+	a.Send(messages.ClientMessage{Type: "updateProfile"})
+	msg, err := a.Expect("profile", 0)
+	if err != nil {
+		return err
+	}
+	if stats := msg.Profile.Stats; stats.WinRate != 1 || stats.AvgSolveMs <= 0 {
+		return fmt.Errorf("winner's stats %+v, want a win rate of 1 and a solve time", stats)
+	}
+	return nil
+}
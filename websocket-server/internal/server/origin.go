@@ -0,0 +1,89 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// OriginPolicy decides which web pages may connect to the server and read
+// its HTTP API. Requests without an Origin header don't come from a
+// browser page and are always allowed, as are pages served from the
+// server's own host.
+type OriginPolicy struct {
+	// Allowed origins, such as "https://maze.example". A "*." host prefix
+	// matches any subdomain ("https://*.example.com"), and a pattern without
+	// a scheme matches the host over any scheme.
+	Allowed []string
+	// AnyOrigin allows every origin. It is for development only.
+	AnyOrigin bool
+}
+
+// allows reports whether a request's origin may use the server
+func (p OriginPolicy) allows(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || p.AnyOrigin {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, pattern := range p.Allowed {
+		if originMatches(pattern, u) {
+			return true
+		}
+	}
+	return false
+}
+
+// originMatches matches an origin against one allowlist pattern
+func originMatches(pattern string, origin *url.URL) bool {
+	pattern = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(pattern), "/"))
+	if scheme, host, ok := strings.Cut(pattern, "://"); ok {
+		if scheme != strings.ToLower(origin.Scheme) {
+			return false
+		}
+		pattern = host
+	}
+	host := strings.ToLower(origin.Host)
+	if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+		return strings.HasSuffix(host, "."+suffix)
+	}
+	return host == pattern
+}
+
+// CheckOrigin is the WebSocket upgrader's origin check: it enforces the
+// origin policy and counts the upgrades from each origin
+func (s *Server) CheckOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	ok := s.Origins.allows(r)
+	s.metrics.Upgraded(origin, ok)
+	if !ok {
+		slog.Info("Rejected connection from origin", "origin", origin, "remote", r.RemoteAddr)
+	}
+	return ok
+}
+
+// CORS lets pages from allowed origins read an HTTP endpoint, answering
+// their preflight requests itself
+func (s *Server) CORS(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Origin")
+		origin := r.Header.Get("Origin")
+		if origin != "" && s.Origins.allows(r) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, OPTIONS")
+			w.Header().Set("Access-Control-Max-Age", "600")
+		}
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next(w, r)
+	}
+}
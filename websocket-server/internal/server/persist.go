@@ -0,0 +1,92 @@
+package server
+
+import (
+	"log/slog"
+	"time"
+
+	"labyrinth-duel/websocket/internal/messages"
+	"labyrinth-duel/websocket/internal/room"
+)
+
+const (
+	// PersistInterval is how often every room is saved to the RoomStore
+	PersistInterval = 5 * time.Second
+	// RestoreGrace is how long players of a restored room have to join it
+	// again before they are dropped
+	RestoreGrace = 2 * time.Minute
+)
+
+// RestoreRooms brings back the rooms saved in RoomStore, e.g. before a
+// restart. Call it before serving clients.
+func (s *Server) RestoreRooms() error {
+	if s.RoomStore == nil {
+		return nil
+	}
+	saved, err := s.RoomStore.Load()
+	if err != nil {
+		return err
+	}
+
+	s.persistMu.Lock()
+	defer s.persistMu.Unlock()
+	for _, sr := range saved {
+		s.persisted[sr.ID] = true
+		for _, p := range sr.Players {
+			if p.ResumeHash != "" {
+				s.profiles.RestoreResumeHash(p.ID, p.ResumeHash)
+			}
+		}
+		r, ok := s.rooms.Restore(sr)
+		if !ok {
+			continue
+		}
+		slog.Info("Restored room", "room", r.ID, "state", sr.State, "players", len(sr.Players))
+		s.clock.AfterFunc(RestoreGrace, func() { s.dropAbsent(r) })
+	}
+	return nil
+}
+
+// dropAbsent removes the players of a restored room who never came back,
+// and the room itself if that empties it
+func (s *Server) dropAbsent(r *room.Room) {
+	for _, id := range r.DropAbsent() {
+		r.Broadcast(messages.ServerMessage{
+			Type:    "playerLeft",
+			Message: id,
+			Players: r.GetPlayers(),
+		}, "")
+	}
+	s.rooms.RemoveIfEmpty(r, "empty")
+}
+
+// SaveRooms saves every room to RoomStore and deletes the saves of rooms
+// that have closed since the last call
+func (s *Server) SaveRooms() {
+	if s.RoomStore == nil {
+		return
+	}
+
+	s.persistMu.Lock()
+	defer s.persistMu.Unlock()
+
+	live := make(map[string]bool)
+	for _, sr := range s.rooms.SaveAll() {
+		for i := range sr.Players {
+			sr.Players[i].ResumeHash = s.profiles.ResumeHash(sr.Players[i].ID)
+		}
+		if err := s.RoomStore.Save(sr); err != nil {
+			slog.Error("Cannot save room", "room", sr.ID, "err", err)
+		}
+		live[sr.ID] = true
+	}
+	for id := range s.persisted {
+		if live[id] {
+			continue
+		}
+		if err := s.RoomStore.Delete(id); err != nil {
+			slog.Error("Cannot delete saved room", "room", id, "err", err)
+			live[id] = true // Try again next time
+		}
+	}
+	s.persisted = live
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"labyrinth-duel/websocket/internal/messages"
+	"labyrinth-duel/websocket/internal/profile"
+	"labyrinth-duel/websocket/internal/room"
+)
+
+// recordMatch adds a finished match to every participant's profile stats
+// and rating, and keeps its replay
+func (s *Server) recordMatch(rec *room.MatchRecord) {
+	s.saveReplay(rec)
+
+	// Sandbox commands make practice results meaningless, and a daily
+	// challenge is only raced against the clock
+	if rec.Practice {
+		return
+	}
+	s.saveHistory(rec)
+	if rec.Daily != "" {
+		s.recordDaily(rec)
+		return
+	}
+
+	var ids []string
+	for _, p := range rec.Players {
+		// Bots have no profile, and games against them aren't rated
+		if p.Bot {
+			continue
+		}
+		won := p.ID == rec.Winner || rec.WinningTeam > 0 && p.Team == rec.WinningTeam
+		s.profiles.RecordResult(p.ID, won, p.Score, solveTime(rec, p.ID))
+		ids = append(ids, p.ID)
+	}
+
+	// Nobody beat anybody in a co-op match
+	var ratings map[string]int
+	if !rec.Coop {
+		var err error
+		if ratings, err = s.ratings.RecordMatch(rec.Winner, ids); err != nil {
+			slog.Error("Cannot update ratings", "err", err)
+		}
+	}
+	s.recordLeaderboards(rec, ratings)
+}
+
+// solveTime is how long a player took to win a match by reaching the exit,
+// 0 if they didn't
+func solveTime(rec *room.MatchRecord, playerID string) time.Duration {
+	if playerID != rec.Winner || rec.Reason != "goal" {
+		return 0
+	}
+	return rec.EndedAt.Sub(rec.StartedAt)
+}
+
+// penalize records a declined or abandoned ranked match against a player,
+// returning the matchmaking cooldown it earns
+func (s *Server) penalize(id string, offence profile.Offence) time.Duration {
+	cooldown := s.profiles.RecordOffence(id, offence, s.clock.Now())
+	if cooldown > 0 {
+		slog.Info("Barred from matchmaking", "client", id, "cooldown", cooldown, "offence", offence)
+	}
+	return cooldown
+}
+
+// profileMessage returns a player's profile, with their rating, for sending
+func (s *Server) profileMessage(id string) *messages.Profile {
+	msg := s.profiles.Get(id).ToMessage()
+	msg.Rating = s.ratings.Get(id)
+	return msg
+}
+
+// lookOf returns how a player is shown to others in a room
+func (s *Server) lookOf(id string) room.PlayerProfile {
+	p := s.profiles.Get(id)
+	return room.PlayerProfile{
+		Name:    p.Name,
+		Color:   p.Color,
+		Avatar:  p.Avatar,
+		Rating:  s.ratings.Get(id),
+		Matches: p.Stats.Matches,
+	}
+}
+
+// claimPlayerID marks a requested player ID as online, or issues a new one
+// if it is missing, malformed, already connected, a verified player's, or
+// the secret doesn't match the one last issued with it
+func (s *Server) claimPlayerID(requested, secret string) string {
+	s.onlineMu.Lock()
+	defer s.onlineMu.Unlock()
+
+	id := requested
+	if _, taken := s.online[id]; !validPlayerID(id) || taken || s.profiles.IsVerified(id) || !s.profiles.CheckSecret(id, secret) {
+		id = uuid.New().String()[:8]
+	}
+	s.online[id] = nil // Until setOnline has the client
+	return id
+}
+
+// claimVerifiedID marks a token-verified player ID as online. Returns false
+// if they are already connected.
+func (s *Server) claimVerifiedID(id string) bool {
+	s.onlineMu.Lock()
+	defer s.onlineMu.Unlock()
+
+	if _, taken := s.online[id]; taken {
+		return false
+	}
+	s.online[id] = nil // Until setOnline has the client
+	return true
+}
+
+// setOnline records the client for its claimed player ID
+func (s *Server) setOnline(client *Client) {
+	s.onlineMu.Lock()
+	defer s.onlineMu.Unlock()
+	s.online[client.ID] = client
+}
+
+// onlineClient returns the client connected as a player, if any
+func (s *Server) onlineClient(id string) *Client {
+	s.onlineMu.Lock()
+	defer s.onlineMu.Unlock()
+	return s.online[id]
+}
+
+// onlineClients returns every client connected to this server
+func (s *Server) onlineClients() []*Client {
+	s.onlineMu.Lock()
+	defer s.onlineMu.Unlock()
+	clients := make([]*Client, 0, len(s.online))
+	for _, c := range s.online {
+		clients = append(clients, c)
+	}
+	return clients
+}
+
+func (s *Server) releasePlayerID(id string) {
+	s.onlineMu.Lock()
+	defer s.onlineMu.Unlock()
+	delete(s.online, id)
+}
+
+// validPlayerID accepts up to 64 letters, digits, dashes and underscores
+func validPlayerID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// validRoomID accepts the same IDs as validPlayerID. Room IDs name the
+// files rooms are saved to (persist.FileStore), which the length keeps
+// within file name limits.
+func validRoomID(id string) bool {
+	return validPlayerID(id)
+}
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"labyrinth-duel/websocket/internal/config"
+	"labyrinth-duel/websocket/internal/messages"
+	"labyrinth-duel/websocket/internal/rating"
+	"labyrinth-duel/websocket/internal/room"
+)
+
+// sentConn is a connection that keeps what the server sends it
+type sentConn struct {
+	mu   sync.Mutex
+	sent []messages.ServerMessage
+}
+
+func (c *sentConn) ReadMessage() (int, []byte, error) { return 0, nil, errors.New("write only") }
+func (c *sentConn) Close() error                      { return nil }
+
+func (c *sentConn) WriteMessage(_ int, data []byte) error {
+	var msg messages.ServerMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sent = append(c.sent, msg)
+	return nil
+}
+
+// last returns the last message of a type sent, if any
+func (c *sentConn) last(msgType string) (messages.ServerMessage, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := len(c.sent) - 1; i >= 0; i-- {
+		if c.sent[i].Type == msgType {
+			return c.sent[i], true
+		}
+	}
+	return messages.ServerMessage{}, false
+}
+
+// testClient is a client of s connected as id
+func testClient(s *Server, id string) (*Client, *sentConn) {
+	conn := &sentConn{}
+	return &Client{ID: id, Conn: conn, codec: messages.JSON, version: messages.ProtocolVersion, metrics: s.metrics}, conn
+}
+
+// TestClaimPlayerID checks a player ID is only resumed with the secret last
+// issued with it, and only while nobody else is connected as it
+func TestClaimPlayerID(t *testing.T) {
+	s := New(config.Default(), rating.NewMemoryStore())
+	id := s.claimPlayerID("", "")
+	secret := s.profiles.IssueSecret(id)
+	s.releasePlayerID(id)
+
+	tests := []struct {
+		name   string
+		secret func() string
+		resume bool
+	}{
+		{"no secret", func() string { return "" }, false},
+		{"wrong secret", func() string { return "not-" + secret }, false},
+		{"the secret", func() string { return secret }, true},
+		{"an older secret", func() string {
+			old := secret
+			secret = s.profiles.IssueSecret(id)
+			return old
+		}, false},
+		{"the newer secret", func() string { return secret }, true},
+	}
+	for _, tt := range tests {
+		got := s.claimPlayerID(id, tt.secret())
+		if resumed := got == id; resumed != tt.resume {
+			t.Errorf("%s: resumed = %v, want %v", tt.name, resumed, tt.resume)
+		}
+		if tt.resume {
+			again := s.claimPlayerID(id, secret)
+			if again == id {
+				t.Errorf("%s: claimed again while connected", tt.name)
+			}
+			s.releasePlayerID(again)
+		}
+		s.releasePlayerID(got)
+	}
+}
+
+func TestValidRoomID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"lobby", true},
+		{"match-1a2b_C", true},
+		{strings.Repeat("r", 64), true},
+		{"", false},
+		{strings.Repeat("r", 65), false},
+		{"<img src=x onerror=alert(1)>", false},
+		{"../rooms", false},
+		{"café", false},
+	}
+	for _, tt := range tests {
+		if got := validRoomID(tt.id); got != tt.want {
+			t.Errorf("validRoomID(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
+
+// TestUpdateProfile changes a player's name and color: they get their new
+// profile, the room shows it to the others, a bad color changes nothing,
+// and the profile and its stats stay with the ID when the player comes back
+func TestUpdateProfile(t *testing.T) {
+	s := New(config.Default(), rating.NewMemoryStore())
+	id := s.claimPlayerID("", "")
+	secret := s.profiles.IssueSecret(id)
+	a, aConn := testClient(s, id)
+	b, bConn := testClient(s, s.claimPlayerID("", ""))
+	r, _ := s.rooms.GetOrCreateRoom("lobby", room.Options{})
+	for _, c := range []*Client{a, b} {
+		s.joinRoom(c, r, messages.ClientMessage{Type: "join", RoomID: "lobby"}, "")
+	}
+
+	s.handleUpdateProfile(a, messages.ClientMessage{Type: "updateProfile", Name: "Alice", Color: "#ff8800"})
+	own, ok := aConn.last("profile")
+	if !ok || own.Profile.Name != "Alice" || own.Profile.Color != "#ff8800" {
+		t.Fatalf("player was sent %+v", own.Profile)
+	}
+	seen, ok := bConn.last("profileUpdated")
+	if !ok || seen.Message != a.ID {
+		t.Fatalf("room was not told of the new profile")
+	}
+	for _, p := range seen.Players {
+		if p.ID == a.ID && (p.Name != "Alice" || p.Color != "#ff8800") {
+			t.Errorf("room shows %q in %s", p.Name, p.Color)
+		}
+	}
+
+	s.handleUpdateProfile(a, messages.ClientMessage{Type: "updateProfile", Name: "Mallory", Color: "red", RequestID: "u2"})
+	rejected, ok := aConn.last("profileRejected")
+	if !ok || rejected.Error != ErrCodeBadRequest || rejected.RequestID != "u2" {
+		t.Errorf("bad color rejected with %+v", rejected)
+	}
+	if name := s.profiles.Get(a.ID).Name; name != "Alice" {
+		t.Errorf("rejected update renamed the player %q", name)
+	}
+
+	s.profiles.RecordResult(a.ID, true, 120, time.Minute)
+	s.handleDisconnect(a)
+	s.releasePlayerID(a.ID)
+	if back := s.claimPlayerID(id, secret); back != id {
+		t.Fatalf("came back as %s, want %s", back, id)
+	}
+	if p := s.profileMessage(id); p.Name != "Alice" || p.Stats.Matches != 1 || p.Stats.Wins != 1 {
+		t.Errorf("came back to %+v", p)
+	}
+}
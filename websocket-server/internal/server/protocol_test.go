@@ -0,0 +1,397 @@
+package server_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"labyrinth-duel/websocket/internal/auth"
+	"labyrinth-duel/websocket/internal/harness"
+	"labyrinth-duel/websocket/internal/memconn"
+	"labyrinth-duel/websocket/internal/messages"
+	"labyrinth-duel/websocket/internal/metrics"
+	"labyrinth-duel/websocket/internal/room"
+	"labyrinth-duel/websocket/internal/server"
+)
+
+func TestProtocol(t *testing.T) {
+	runScenarios(t, []scenario{
+		{"flooding gets a warning, then a kick", flooding},
+		{"a hello settles encoding and features, and moves come whole without deltas", capabilityHello},
+		{"old clients see a pause as a countdown, until their version is no longer served", protocolVersions},
+		{"only allowed origins connect and read the API", originPolicy},
+		{"a token decides who a player is and what they may do", tokenAuth},
+		{"a player resumes their ID with a secret nobody else is sent", resumeSecret},
+	})
+}
+
+// flooding sends far more than the rate limit allows without letting the
+// clock move, so no tokens come back
+func flooding(h *harness.Harness) error {
+	c, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < 2*server.KickAfter; i++ {
+		c.Send(messages.ClientMessage{Type: "listRooms"})
+	}
+	warning, err := c.Expect("error", 0)
+	if err != nil {
+		return err
+	}
+	if warning.Error != server.ErrCodeRateLimited {
+		return fmt.Errorf("warned with %q, want %s", warning.Error, server.ErrCodeRateLimited)
+	}
+	kicked, err := c.Expect("kicked", 0)
+	if err != nil {
+		return err
+	}
+	if kicked.Reason != "rateLimited" {
+		return fmt.Errorf("kicked with %q, want rateLimited", kicked.Reason)
+	}
+	return nil
+}
+
+// capabilityHello has one client settle its encoding and features with a
+// hello: it is kept out of rooms needing features it lacks, and is sent
+// the player list with every move since it didn't ask for deltas
+func capabilityHello(h *harness.Harness) error {
+	a, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	b, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	a.Send(messages.ClientMessage{
+		Type:      "hello",
+		RequestID: "hi",
+		Protocol:  messages.ProtocolVersion,
+		Encodings: []string{"carrier-pigeon", "json"},
+		Caps:      []string{messages.CapCompactMaze, "telepathy"},
+	})
+	welcome, err := a.Expect("welcome", 0)
+	if err != nil {
+		return err
+	}
+	if welcome.RequestID != "hi" || welcome.Protocol != messages.ProtocolVersion || welcome.Encoding != "json" ||
+		!slices.Equal(welcome.Caps, []string{messages.CapCompactMaze}) {
+		return fmt.Errorf("welcome %+v", welcome)
+	}
+
+	a.Send(messages.ClientMessage{Type: "join", RoomID: "foggy", Fog: true, Seed: 5})
+	if rejected, err := a.Expect("joinRejected", 0); err != nil {
+		return err
+	} else if rejected.Error != server.ErrCodeUnsupported {
+		return fmt.Errorf("fog room turned the client away with %s, want %s", rejected.Error, server.ErrCodeUnsupported)
+	}
+
+	for _, c := range []*harness.Client{a, b} {
+		c.Send(messages.ClientMessage{Type: "join", RoomID: "hello", Seed: 5})
+		if _, err := c.Expect("mazeData", 0); err != nil {
+			return err
+		}
+		c.Send(messages.ClientMessage{Type: "ready"})
+	}
+	a.Send(messages.ClientMessage{Type: "hello", Protocol: messages.ProtocolVersion})
+	if msg, err := a.Expect("error", 0); err != nil {
+		return err
+	} else if msg.Error != server.ErrCodeInvalidAction {
+		return fmt.Errorf("hello in a room got %s, want %s", msg.Error, server.ErrCodeInvalidAction)
+	}
+	if err := awaitPlaying(b, a); err != nil {
+		return err
+	}
+
+	from := playerIn(h.Server.Rooms().GetRoom("hello"), b.ID)
+	path, err := b.PathToGoal(from.X, from.Y)
+	if err != nil {
+		return err
+	}
+	b.Send(messages.ClientMessage{Type: "move", X: path[0].X, Y: path[0].Y})
+	for _, c := range []*harness.Client{a, b} {
+		moved, err := c.Expect("playerMoved", 0)
+		if err != nil {
+			return err
+		}
+		if moved.Message != b.ID || *moved.Position != path[0] {
+			return fmt.Errorf("%s saw %s move to %+v, want %s to %v", c.ID, moved.Message, moved.Position, b.ID, path[0])
+		}
+		if full := len(moved.Players) > 0; full != (c == a) {
+			return fmt.Errorf("%s got the player list with a move: %v", c.ID, full)
+		}
+	}
+	return nil
+}
+
+// protocolVersions plays a current and a legacy client in one room: the
+// legacy one sees a pause as a countdown and never hears of pausing, and
+// once the server stops serving its version it is turned away
+func protocolVersions(h *harness.Harness) error {
+	current, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	legacy, err := h.ConnectWith(server.Handshake{})
+	if err != nil {
+		return err
+	}
+	for _, c := range []*harness.Client{current, legacy} {
+		c.Send(messages.ClientMessage{Type: "join", RoomID: "versions", Seed: 18})
+		if _, err := c.Expect("mazeData", 0); err != nil {
+			return err
+		}
+		c.Send(messages.ClientMessage{Type: "ready"})
+	}
+	if err := awaitPlaying(legacy, current); err != nil {
+		return err
+	}
+
+	current.Send(messages.ClientMessage{Type: "pause"})
+	if _, err := current.Expect("matchPaused", 0); err != nil {
+		return err
+	}
+	if msg, err := legacy.Expect("countdown", 0); err != nil {
+		return err
+	} else if msg.State != string(room.StateCountdown) {
+		return fmt.Errorf("old client saw the pause as %q, want %s", msg.State, room.StateCountdown)
+	}
+	current.Send(messages.ClientMessage{Type: "resume"})
+	if err := current.Sync(); err != nil {
+		return err
+	}
+	if _, err := current.Await("matchResumed", room.CountdownDuration+time.Second); err != nil {
+		return err
+	}
+	for {
+		msg, err := legacy.Await("countdown", room.CountdownDuration+time.Second)
+		if err != nil {
+			return err
+		}
+		if msg.State == string(room.StatePlaying) {
+			break
+		}
+		if msg.State != string(room.StateCountdown) {
+			return fmt.Errorf("old client counted down in %q", msg.State)
+		}
+	}
+	for _, msg := range legacy.Log {
+		if strings.HasPrefix(msg.Type, "matchRes") || msg.Type == "matchPaused" || msg.State == string(room.StatePaused) {
+			return fmt.Errorf("old client was sent %s in state %q", msg.Type, msg.State)
+		}
+	}
+
+	h.Server.MinProtocol = messages.ProtocolVersion
+	if _, err := h.ConnectWith(server.Handshake{Protocol: messages.MinProtocolVersion}); err == nil {
+		return fmt.Errorf("connected with a protocol version no longer served")
+	}
+	for _, first := range []messages.ClientMessage{
+		{Type: "join", RoomID: "versions"},
+		{Type: "hello", Protocol: messages.MinProtocolVersion},
+	} {
+		c, err := h.ConnectWith(server.Handshake{})
+		if err != nil {
+			return err
+		}
+		c.Send(first)
+		if msg, err := c.Expect("error", 0); err != nil {
+			return err
+		} else if msg.Error != server.ErrCodeOldProtocol {
+			return fmt.Errorf("%s from an old client got %s, want %s", first.Type, msg.Error, server.ErrCodeOldProtocol)
+		}
+		if msg, err := c.Next(time.Second); !errors.Is(err, memconn.ErrClosed) {
+			return fmt.Errorf("old client still connected, sent %s (%v)", msg.Type, err)
+		}
+	}
+	return nil
+}
+
+// originPolicy checks which origins may connect and read the API, and that
+// the admin metrics count them
+func originPolicy(h *harness.Harness) error {
+	h.Server.Origins = server.OriginPolicy{Allowed: []string{"https://maze.example", "https://*.maze.example"}}
+	h.Server.AdminToken = "secret"
+
+	request := func(method, origin string) *http.Request {
+		req := httptest.NewRequest(method, "http://game.internal/rooms", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		return req
+	}
+	for origin, want := range map[string]bool{
+		"":                          true, // Not a browser
+		"http://game.internal":      true, // Same host
+		"https://maze.example":      true,
+		"https://beta.maze.example": true,
+		"http://maze.example":       false, // Wrong scheme
+		"https://evil.example":      false,
+		"https://maze.example.evil": false,
+	} {
+		if got := h.Server.CheckOrigin(request(http.MethodGet, origin)); got != want {
+			return fmt.Errorf("origin %q allowed = %v, want %v", origin, got, want)
+		}
+	}
+
+	rooms := h.Server.CORS(h.Server.HandleRooms)
+	rec := httptest.NewRecorder()
+	preflight := request(http.MethodOptions, "https://beta.maze.example")
+	preflight.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	rooms(rec, preflight)
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Origin") != "https://beta.maze.example" {
+		return fmt.Errorf("preflight answered %d with %v", rec.Code, rec.Header())
+	}
+	rec = httptest.NewRecorder()
+	rooms(rec, request(http.MethodGet, "https://evil.example"))
+	if rec.Code != http.StatusOK || rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		return fmt.Errorf("disallowed origin answered %d with %v", rec.Code, rec.Header())
+	}
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/admin/metrics", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	h.Server.HandleAdminMetrics(rec, req)
+	var m metrics.Snapshot
+	if err := json.NewDecoder(rec.Body).Decode(&m); err != nil {
+		return err
+	}
+	if m.Origins["https://evil.example"].Rejected != 1 || m.Origins[metrics.NoOrigin].Accepted != 1 {
+		return fmt.Errorf("origin counts %+v", m.Origins)
+	}
+	return nil
+}
+
+// tokenAuth checks bearer tokens: bad ones are refused, a good one fixes
+// who the player is, and an admin token opens the admin API
+func tokenAuth(h *harness.Harness) error {
+	v := auth.NewVerifier("shh", "maze-test")
+	h.Server.Auth = v
+	h.Server.RequireAuth = true
+
+	authenticate := func(token string) (*auth.Claims, error) {
+		req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		return h.Server.Authenticate(req)
+	}
+	if _, err := authenticate(""); err != server.ErrTokenRequired {
+		return fmt.Errorf("no token: %v", err)
+	}
+	forged := auth.NewVerifier("guess", "maze-test").Sign(auth.Claims{Subject: "alice", Issuer: "maze-test"})
+	if _, err := authenticate(forged); err != auth.ErrSignature {
+		return fmt.Errorf("forged token: %v", err)
+	}
+	expired := v.Sign(auth.Claims{Subject: "alice", Issuer: "maze-test", ExpiresAt: time.Now().Add(-time.Hour).Unix()})
+	if _, err := authenticate(expired); err != auth.ErrExpired {
+		return fmt.Errorf("expired token: %v", err)
+	}
+
+	claims, err := authenticate(v.Sign(auth.Claims{Subject: "alice", Name: "Alice", Issuer: "maze-test"}))
+	if err != nil {
+		return err
+	}
+	alice, err := h.ConnectWith(server.Handshake{PlayerID: "mallory", Claims: claims})
+	if err != nil {
+		return err
+	}
+	hello := alice.Log[len(alice.Log)-1]
+	if alice.ID != "alice" || hello.Profile == nil || hello.Profile.Name != "Alice" || !hello.Profile.Verified {
+		return fmt.Errorf("connected as %s with profile %+v", alice.ID, hello.Profile)
+	}
+	alice.Send(messages.ClientMessage{Type: "updateProfile", Name: "Eve", Color: "#00ff00"})
+	profile, err := alice.Expect("profile", 0)
+	if err != nil {
+		return err
+	}
+	if profile.Profile.Name != "Alice" || profile.Profile.Color != "#00ff00" {
+		return fmt.Errorf("profile updated to %+v", profile.Profile)
+	}
+
+	// Without a token nobody can pass themselves off as alice
+	h.Server.RequireAuth = false
+	imposter, err := h.Connect("alice")
+	if err != nil {
+		return err
+	}
+	if imposter.ID == "alice" {
+		return fmt.Errorf("anonymous client took a verified ID")
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/admin/metrics", nil)
+	req.Header.Set("Authorization", "Bearer "+v.Sign(auth.Claims{Subject: "root", Roles: []string{auth.RoleAdmin}, Issuer: "maze-test"}))
+	h.Server.HandleAdminMetrics(rec, req)
+	if rec.Code != http.StatusOK {
+		return fmt.Errorf("admin token answered %d", rec.Code)
+	}
+	return nil
+}
+
+// resumeSecret has two players share a room: neither is ever sent the
+// other's resume secret, and one comes back as themselves with theirs
+func resumeSecret(h *harness.Harness) error {
+	a, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	b, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	if a.Secret == "" || a.Secret == b.Secret {
+		return fmt.Errorf("issued secrets %q and %q", a.Secret, b.Secret)
+	}
+	for _, c := range []*harness.Client{a, b} {
+		c.Send(messages.ClientMessage{Type: "join", RoomID: "secrets"})
+		if _, err := c.Expect("mazeData", 0); err != nil {
+			return err
+		}
+	}
+	a.Send(messages.ClientMessage{Type: "updateProfile", Name: "Ada"})
+	if err := a.Sync(); err != nil {
+		return err
+	}
+	if err := b.Sync(); err != nil {
+		return err
+	}
+	for _, msg := range b.Log[1:] {
+		if data, _ := json.Marshal(msg); strings.Contains(string(data), a.Secret) || msg.Secret != "" {
+			return fmt.Errorf("%s was sent a secret in %s", b.ID, msg.Type)
+		}
+	}
+
+	a.Disconnect()
+	if imposter, err := h.Connect(a.ID); err != nil {
+		return err
+	} else if imposter.ID == a.ID {
+		return fmt.Errorf("connected as %s without the secret", a.ID)
+	}
+	// The server lets go of the ID once it notices the disconnect
+	deadline := time.Now().Add(harness.DefaultTimeout)
+	for {
+		back, err := h.Resume(a)
+		if err != nil {
+			return err
+		}
+		if back.ID == a.ID {
+			if back.Secret == a.Secret {
+				return fmt.Errorf("resumed with the same secret, want a new one")
+			}
+			return nil
+		}
+		back.Disconnect()
+		if time.Now().After(deadline) {
+			return fmt.Errorf("never resumed %s with its secret", a.ID)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,108 @@
+package server
+
+import "time"
+
+// Limit is a token bucket: Rate messages per second on average, with bursts
+// of up to Burst
+type Limit struct {
+	Rate  float64
+	Burst float64
+}
+
+// DefaultRateLimits caps each client's inbound messages per type. The ""
+// entry covers every type without its own limit.
+var DefaultRateLimits = map[string]Limit{
+	"":        {Rate: 10, Burst: 20},
+	"move":    {Rate: 20, Burst: 10},
+	"moveDir": {Rate: 20, Burst: 10},
+	"chat":    {Rate: 2, Burst: 5},
+	"emote":   {Rate: 1, Burst: 3},
+
+	"uploadMaze": {Rate: 0.1, Burst: 3},
+}
+
+const (
+	// AbuseWindow is how long dropped messages count against a client
+	AbuseWindow = 10 * time.Second
+	// WarnAfter is how many dropped messages in an AbuseWindow earn a warning
+	WarnAfter = 20
+	// KickAfter is how many dropped messages in an AbuseWindow get the
+	// client disconnected
+	KickAfter = 100
+)
+
+// verdict is what to do with an inbound message
+type verdict int
+
+const (
+	allow verdict = iota
+	drop          // Over the limit; ignore it
+	warn          // Drop it and warn the client
+	kick          // Drop it and disconnect the client
+)
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// limiter rate-limits one client's inbound messages. It is only used from
+// the client's read loop, so it needs no lock.
+type limiter struct {
+	limits  map[string]Limit
+	buckets map[string]*bucket
+
+	// Messages dropped since windowStart
+	drops       int
+	windowStart time.Time
+	warned      bool
+}
+
+func newLimiter(limits map[string]Limit) *limiter {
+	return &limiter{
+		limits:  limits,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// check spends a token for a message of the given type
+func (l *limiter) check(msgType string, now time.Time) verdict {
+	limit, ok := l.limits[msgType]
+	if !ok {
+		// Unknown types share one bucket so they can't each get a fresh one
+		msgType = ""
+		limit, ok = l.limits[""]
+		if !ok {
+			return allow
+		}
+	}
+
+	b, ok := l.buckets[msgType]
+	if !ok {
+		b = &bucket{tokens: limit.Burst, last: now}
+		l.buckets[msgType] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * limit.Rate
+	if b.tokens > limit.Burst {
+		b.tokens = limit.Burst
+	}
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return allow
+	}
+
+	if now.Sub(l.windowStart) > AbuseWindow {
+		l.drops, l.windowStart, l.warned = 0, now, false
+	}
+	l.drops++
+	switch {
+	case l.drops >= KickAfter:
+		return kick
+	case l.drops >= WarnAfter && !l.warned:
+		l.warned = true
+		return warn
+	}
+	return drop
+}
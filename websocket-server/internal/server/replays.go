@@ -0,0 +1,184 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"labyrinth-duel/websocket/internal/clock"
+	"labyrinth-duel/websocket/internal/messages"
+	"labyrinth-duel/websocket/internal/replay"
+	"labyrinth-duel/websocket/internal/room"
+)
+
+// Playback speeds a watchReplay may ask for
+const (
+	MinReplaySpeed = 0.25
+	MaxReplaySpeed = 16
+)
+
+// HandleReplays serves recorded matches: /replays lists them, newest
+// first, and /replays/{id} returns one in full
+func (s *Server) HandleReplays(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/replays"), "/")
+	if id == "" {
+		infos, err := s.Replays.List()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(infos)
+		return
+	}
+
+	rep, err := s.Replays.Get(id)
+	if err == replay.ErrNotFound {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rep)
+}
+
+// saveReplay stores a finished match's replay
+func (s *Server) saveReplay(rec *room.MatchRecord) {
+	if rec.Replay == nil {
+		return
+	}
+	if err := s.Replays.Save(rec.Replay); err != nil {
+		slog.Error("Cannot save replay", "replay", rec.Replay.ID, "err", err)
+	}
+}
+
+func (s *Server) handleListReplays(client *Client, msg messages.ClientMessage) {
+	infos, err := s.Replays.List()
+	if err != nil {
+		sendError(client, msg, ErrCodeBadRequest, err.Error())
+		return
+	}
+	client.SendJSON(messages.ServerMessage{Type: "replayList", Replays: infos})
+}
+
+// handleWatchReplay starts streaming a replay to the client, replacing any
+// replay already playing. Without a replayId it changes the speed of the
+// one playing.
+func (s *Server) handleWatchReplay(client *Client, msg messages.ClientMessage) {
+	if msg.ReplayID == "" {
+		p := client.currentPlayback()
+		if p == nil {
+			sendError(client, msg, ErrCodeInvalidAction, "no replay is playing")
+			return
+		}
+		p.setSpeed(msg.Speed)
+		return
+	}
+
+	rep, err := s.Replays.Get(msg.ReplayID)
+	if err != nil {
+		code := ErrCodeBadRequest
+		if err == replay.ErrNotFound {
+			code = ErrCodeNotFound
+		}
+		sendError(client, msg, code, err.Error())
+		return
+	}
+
+	p := newPlayback(msg.Speed, s.clock)
+	client.startPlayback(p)
+	go p.run(client, rep)
+}
+
+func (s *Server) handleStopReplay(client *Client) {
+	client.startPlayback(nil)
+}
+
+// playback streams one replay to a client in (scaled) real time
+type playback struct {
+	speed float64
+	clock clock.Clock // Paces the events
+	stop  chan struct{}
+	once  sync.Once
+	mu    sync.Mutex
+}
+
+func newPlayback(speed float64, c clock.Clock) *playback {
+	p := &playback{stop: make(chan struct{}), clock: c}
+	p.setSpeed(speed)
+	return p
+}
+
+// setSpeed changes the playback rate, taking effect from the next event.
+// 0 means normal speed.
+func (p *playback) setSpeed(speed float64) {
+	switch {
+	case speed == 0:
+		speed = 1
+	case speed < MinReplaySpeed:
+		speed = MinReplaySpeed
+	case speed > MaxReplaySpeed:
+		speed = MaxReplaySpeed
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.speed = speed
+}
+
+func (p *playback) currentSpeed() float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.speed
+}
+
+// halt stops the stream; safe to call more than once
+func (p *playback) halt() {
+	p.once.Do(func() { close(p.stop) })
+}
+
+// run sends replayStart with the first maze, every event as a replayEvent
+// (a new round's maze travels with its round event), then replayEnd
+func (p *playback) run(client *Client, rep *replay.Replay) {
+	start := messages.ServerMessage{Type: "replayStart", Replay: &rep.ReplayInfo}
+	if len(rep.Mazes) > 0 {
+		start.Maze = rep.Mazes[0]
+	}
+	client.SendJSON(start)
+
+	var last int64
+	for i := range rep.Events {
+		ev := rep.Events[i]
+		wait := time.Duration(float64(time.Duration(ev.T-last)*time.Millisecond) / p.currentSpeed())
+		last = ev.T
+		if wait > 0 {
+			select {
+			case <-p.stop:
+				return
+			case <-p.clock.After(wait):
+			}
+		}
+
+		msg := messages.ServerMessage{Type: "replayEvent", Event: &ev}
+		if ev.Type == room.EventRound && ev.Value > 0 && ev.Value < len(rep.Mazes) {
+			msg.Maze = rep.Mazes[ev.Value]
+		}
+		select {
+		case <-p.stop:
+			return
+		default:
+			client.SendJSON(msg)
+		}
+	}
+	client.SendJSON(messages.ServerMessage{Type: "replayEnd", Replay: &rep.ReplayInfo})
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"log/slog"
+
+	"github.com/google/uuid"
+	"labyrinth-duel/websocket/internal/messages"
+	"labyrinth-duel/websocket/internal/room"
+)
+
+// suspendMatch keeps a match suspended by vote in Suspended under a fresh
+// resume code, which is also its room ID once resumed, and closes the room
+// it leaves behind
+func (s *Server) suspendMatch(saved *room.SavedRoom) (string, error) {
+	roomID := saved.ID
+	code := uuid.New().String()[:8]
+	saved.ID = code
+	if err := s.Suspended.Save(saved); err != nil {
+		return "", err
+	}
+	slog.Info("Suspended match", "room", roomID, "code", code)
+
+	// The room is locked until this returns
+	go func() {
+		if r := s.rooms.GetRoom(roomID); r != nil {
+			s.rooms.RemoveIfEmpty(r, "suspended")
+		}
+	}()
+	return code, nil
+}
+
+// handleResumeMatch asks to resume a suspended match by its code. It comes
+// back once every player in it has asked; until then the others who are
+// online are told someone is waiting for them.
+func (s *Server) handleResumeMatch(client *Client, msg messages.ClientMessage) {
+	saved, ok, err := s.Suspended.Get(msg.Code)
+	if err != nil {
+		client.logger(msg.Type).Error("Cannot load suspended match", "code", msg.Code, "err", err)
+		sendError(client, msg, ErrCodeUnavailable, "suspended matches are unavailable")
+		return
+	}
+	if !ok || !wasIn(saved, client.ID) {
+		sendError(client, msg, ErrCodeNotFound, "no suspended match of yours with that code")
+		return
+	}
+
+	s.resumeMu.Lock()
+	waiting := s.resumes[msg.Code]
+	if waiting == nil {
+		waiting = make(map[string]*Client)
+		s.resumes[msg.Code] = waiting
+	}
+	waiting[client.ID] = client
+	var missing []string
+	for _, p := range saved.Players {
+		if !p.Bot && waiting[p.ID] == nil {
+			missing = append(missing, p.ID)
+		}
+	}
+	if len(missing) > 0 {
+		s.resumeMu.Unlock()
+		client.logger(msg.Type).Info("Waiting to resume match", "code", msg.Code)
+		client.SendJSON(messages.ServerMessage{
+			Type: "resumePending",
+			Code: msg.Code,
+		})
+		for _, id := range missing {
+			if other := s.onlineClient(id); other != nil {
+				other.SendJSON(messages.ServerMessage{
+					Type:    "resumeRequested",
+					Code:    msg.Code,
+					Message: client.ID,
+				})
+			}
+		}
+		return
+	}
+	delete(s.resumes, msg.Code)
+	s.resumeMu.Unlock()
+
+	r, ok := s.rooms.Restore(saved)
+	if !ok {
+		for _, c := range waiting {
+			sendError(c, msg, ErrCodeInvalidAction, "the match is already running")
+		}
+		return
+	}
+	if err := s.Suspended.Delete(msg.Code); err != nil {
+		slog.Error("Cannot delete suspended match", "code", msg.Code, "err", err)
+	}
+	slog.Info("Resumed match", "room", r.ID)
+	for _, c := range waiting {
+		if err := r.AddPlayer(c.ID, c); err != nil {
+			rejectJoin(c, msg, err)
+			continue
+		}
+		s.enterRoom(c, r)
+	}
+}
+
+// dropResumeRequests withdraws a disconnected client's requests to resume
+// suspended matches
+func (s *Server) dropResumeRequests(client *Client) {
+	s.resumeMu.Lock()
+	defer s.resumeMu.Unlock()
+	for code, waiting := range s.resumes {
+		if waiting[client.ID] != client {
+			continue
+		}
+		delete(waiting, client.ID)
+		if len(waiting) == 0 {
+			delete(s.resumes, code)
+		}
+	}
+}
+
+// wasIn reports whether a player took part in a saved match
+func wasIn(saved *room.SavedRoom, playerID string) bool {
+	for _, p := range saved.Players {
+		if p.ID == playerID && !p.Bot {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,1051 @@
+package server_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"labyrinth-duel/websocket/internal/game"
+	"labyrinth-duel/websocket/internal/harness"
+	"labyrinth-duel/websocket/internal/messages"
+	"labyrinth-duel/websocket/internal/room"
+	"labyrinth-duel/websocket/internal/server"
+)
+
+func TestRooms(t *testing.T) {
+	runScenarios(t, []scenario{
+		{"private room rejects a wrong code", privateRoom},
+		{"full room turns away an extra player", fullRoom},
+		{"a room's messages reach its own players only", roomBroadcast},
+		{"joining another room leaves the last one", switchRooms},
+		{"joining your own room again keeps your place", rejoinRoom},
+		{"a kick vote removes a player for good", kickVote},
+		{"the host kicks, bans, locks, resets the maze and hands over the room", hostPrivileges},
+		{"players vote a smaller maze in, then vote to restart the finished match", roomVotes},
+		{"idle players are flagged, then removed, and the emptied room closes", idlePlayers},
+		{"bots play a demo in an empty room until someone joins", attractMode},
+		{"team chat stays on the team, colors follow the teams", teamChat},
+		{"co-op players all get out to win together", coopEscape},
+		{"a flag carrier is slowed, can drop the flag and captures it at home", captureTheFlag},
+		{"hunters see a runner's fading breadcrumbs", breadcrumbs},
+		{"a camper is warned and revealed to their opponent", camping},
+	})
+}
+
+// privateRoom checks that a private room is unlisted and needs its code
+func privateRoom(h *harness.Harness) error {
+	owner, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	guest, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+
+	owner.Send(messages.ClientMessage{Type: "join", RoomID: "secret", Private: true})
+	data, err := owner.Expect("mazeData", 0)
+	if err != nil {
+		return err
+	}
+
+	guest.Send(messages.ClientMessage{Type: "listRooms"})
+	list, err := guest.Expect("roomList", 0)
+	if err != nil {
+		return err
+	}
+	if len(list.Rooms) != 0 {
+		return fmt.Errorf("private room is listed: %+v", list.Rooms)
+	}
+
+	guest.Send(messages.ClientMessage{Type: "join", Code: "WRONG1"})
+	rejected, err := guest.Expect("joinRejected", 0)
+	if err != nil {
+		return err
+	}
+	if rejected.Reason != "badCode" {
+		return fmt.Errorf("rejected with %q, want badCode", rejected.Reason)
+	}
+
+	guest.Send(messages.ClientMessage{Type: "join", Code: data.Code})
+	_, err = guest.Expect("mazeData", 0)
+	return err
+}
+
+// fullRoom fills a two-player room, then checks a third player gets
+// ROOM_FULL and the listing shows the room as 2/2 and not joinable
+func fullRoom(h *harness.Harness) error {
+	var clients []*harness.Client
+	for range 3 {
+		c, err := h.Connect("")
+		if err != nil {
+			return err
+		}
+		clients = append(clients, c)
+	}
+
+	for _, c := range clients[:2] {
+		c.Send(messages.ClientMessage{Type: "join", RoomID: "duo", MaxPlayers: 2})
+		if _, err := c.Expect("mazeData", 0); err != nil {
+			return err
+		}
+	}
+
+	extra := clients[2]
+	extra.Send(messages.ClientMessage{Type: "join", RoomID: "duo", RequestID: "j1"})
+	rejected, err := extra.Expect("joinRejected", 0)
+	if err != nil {
+		return err
+	}
+	if rejected.Error != server.ErrCodeRoomFull || rejected.RequestID != "j1" {
+		return fmt.Errorf("rejected with %q for %q, want %s for j1", rejected.Error, rejected.RequestID, server.ErrCodeRoomFull)
+	}
+
+	extra.Send(messages.ClientMessage{Type: "listRooms"})
+	list, err := extra.Expect("roomList", 0)
+	if err != nil {
+		return err
+	}
+	if len(list.Rooms) != 1 {
+		return fmt.Errorf("listed %d rooms, want 1", len(list.Rooms))
+	}
+	if info := list.Rooms[0]; info.Players != 2 || info.MaxPlayers != 2 || info.Joinable {
+		return fmt.Errorf("listed as %d/%d joinable=%v, want 2/2 not joinable", info.Players, info.MaxPlayers, info.Joinable)
+	}
+	return nil
+}
+
+// roomBroadcast puts two players in one room and a third in another: the
+// room tells its players of a newcomer, but not the newcomer, and nobody
+// outside it
+func roomBroadcast(h *harness.Harness) error {
+	a, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	b, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	other, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	other.Send(messages.ClientMessage{Type: "join", RoomID: "elsewhere"})
+	if _, err := other.Expect("mazeData", 0); err != nil {
+		return err
+	}
+	for _, c := range []*harness.Client{a, b} {
+		c.Send(messages.ClientMessage{Type: "join", RoomID: "shared"})
+		if _, err := c.Expect("mazeData", 0); err != nil {
+			return err
+		}
+	}
+
+	joined, err := a.Expect("playerJoined", 0)
+	if err != nil {
+		return err
+	}
+	if joined.Message != b.ID || len(joined.Players) != 2 {
+		return fmt.Errorf("told %q joined with %d players, want %s with 2", joined.Message, len(joined.Players), b.ID)
+	}
+	connected := h.Server.Rooms().GetRoom("shared").Dump().Connected
+	if len(connected) != 2 {
+		return fmt.Errorf("room has connections for %v, want %s and %s", connected, a.ID, b.ID)
+	}
+
+	a.Send(messages.ClientMessage{Type: "chat", Text: "hello room"})
+	for _, c := range []*harness.Client{a, b} {
+		if _, err := c.Expect("chat", 0); err != nil {
+			return err
+		}
+	}
+	for _, c := range []*harness.Client{b, other} {
+		if err := c.Sync(); err != nil {
+			return err
+		}
+		for {
+			msg, err := c.Next(time.Millisecond)
+			if err != nil {
+				break
+			}
+			if msg.Type == "chat" || (msg.Type == "playerJoined" && msg.Message == c.ID) {
+				return fmt.Errorf("client %s was sent %s from %q", c.ID, msg.Type, msg.Message)
+			}
+		}
+	}
+	return nil
+}
+
+// switchRooms moves a player from one room to another: the room they left
+// drops them and stops sending them its messages, and disconnecting later
+// leaves no ghost behind in it
+func switchRooms(h *harness.Harness) error {
+	mover, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	stayer, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	for _, c := range []*harness.Client{mover, stayer} {
+		c.Send(messages.ClientMessage{Type: "join", RoomID: "first"})
+		if _, err := c.Expect("mazeData", 0); err != nil {
+			return err
+		}
+	}
+
+	mover.Send(messages.ClientMessage{Type: "join", RoomID: "second"})
+	if _, err := mover.Expect("mazeData", 0); err != nil {
+		return err
+	}
+	left, err := stayer.Expect("playerLeft", 0)
+	if err != nil {
+		return err
+	}
+	if left.Message != mover.ID || len(left.Players) != 1 {
+		return fmt.Errorf("told %q left, leaving %d players; want %s, leaving 1", left.Message, len(left.Players), mover.ID)
+	}
+
+	stayer.Send(messages.ClientMessage{Type: "chat", Text: "still here?"})
+	if _, err := stayer.Expect("chat", 0); err != nil {
+		return err
+	}
+	if err := mover.Sync(); err != nil {
+		return err
+	}
+	for {
+		msg, err := mover.Next(time.Millisecond)
+		if err != nil {
+			break
+		}
+		if msg.Type == "chat" {
+			return fmt.Errorf("left room still sends %+v", msg.Chat)
+		}
+	}
+
+	mover.Disconnect()
+	for deadline := time.Now().Add(harness.DefaultTimeout); ; {
+		if r := h.Server.Rooms().GetRoom("second"); r == nil || r.IsEmpty() {
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("disconnected player still in their room")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if players := h.Server.Rooms().GetRoom("first").GetPlayers(); len(players) != 1 || players[0].ID != stayer.ID {
+		return fmt.Errorf("first room holds %+v, want only %s", players, stayer.ID)
+	}
+	return nil
+}
+
+// rejoinRoom has a player join the room they are in again: in the lobby
+// they stay ready, and mid-match they are turned away without being sent
+// back to spawn
+func rejoinRoom(h *harness.Harness) error {
+	a, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	b, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	for _, c := range []*harness.Client{a, b} {
+		c.Send(messages.ClientMessage{Type: "join", RoomID: "again", Seed: 42})
+		if _, err := c.Expect("mazeData", 0); err != nil {
+			return err
+		}
+	}
+	r := h.Server.Rooms().GetRoom("again")
+
+	a.Send(messages.ClientMessage{Type: "ready"})
+	a.Send(messages.ClientMessage{Type: "join", RoomID: "again"})
+	if _, err := a.Expect("mazeData", 0); err != nil {
+		return err
+	}
+	if !playerIn(r, a.ID).Ready {
+		return fmt.Errorf("joining again undid ready")
+	}
+	if n := len(r.GetPlayers()); n != 2 {
+		return fmt.Errorf("room holds %d players, want 2", n)
+	}
+
+	b.Send(messages.ClientMessage{Type: "ready"})
+	if err := awaitPlaying(a, b); err != nil {
+		return err
+	}
+	start := playerIn(r, a.ID)
+	path, err := a.PathToGoal(start.X, start.Y)
+	if err != nil {
+		return err
+	}
+	if err := a.Walk(path[:1], room.BaseMoveInterval); err != nil {
+		return err
+	}
+
+	a.Send(messages.ClientMessage{Type: "join", RoomID: "again", RequestID: "j2"})
+	rejected, err := a.Expect("joinRejected", 0)
+	if err != nil {
+		return err
+	}
+	if rejected.Reason != "inMatch" || rejected.RequestID != "j2" {
+		return fmt.Errorf("rejected with %q for %q, want inMatch for j2", rejected.Reason, rejected.RequestID)
+	}
+	if at := playerIn(r, a.ID); at.X != path[0].X || at.Y != path[0].Y {
+		return fmt.Errorf("player at (%d,%d), want (%d,%d)", at.X, at.Y, path[0].X, path[0].Y)
+	}
+	return nil
+}
+
+// kickVote has two of three players vote the third out, who must then be
+// told, dropped from the room and refused when trying to come back
+func kickVote(h *harness.Harness) error {
+	var clients []*harness.Client
+	for range 3 {
+		c, err := h.Connect("")
+		if err != nil {
+			return err
+		}
+		c.Send(messages.ClientMessage{Type: "join", RoomID: "vote"})
+		if _, err := c.Expect("mazeData", 0); err != nil {
+			return err
+		}
+		clients = append(clients, c)
+	}
+	a, b, c := clients[0], clients[1], clients[2]
+
+	a.Send(messages.ClientMessage{Type: "startVote", VoteKind: room.VoteKick, PlayerID: c.ID})
+	started, err := b.Expect("voteStarted", 0)
+	if err != nil {
+		return err
+	}
+	if v := started.Vote; v.Target != c.ID || v.Yes != 1 || v.Eligible != 2 || v.Needed != 2 {
+		return fmt.Errorf("vote started as %+v, want target %s with 1 of 2 needed yes from 2 voters", v, c.ID)
+	}
+	c.Send(messages.ClientMessage{Type: "castVote", RequestID: "v1"})
+	if rejected, err := c.Expect("error", 0); err != nil {
+		return err
+	} else if rejected.Error != server.ErrCodeInvalidAction || rejected.RequestID != "v1" {
+		return fmt.Errorf("target voting got %q for %q, want %s", rejected.Error, rejected.RequestID, server.ErrCodeInvalidAction)
+	}
+
+	b.Send(messages.ClientMessage{Type: "castVote", Yes: true})
+	ended, err := a.Expect("voteEnded", 0)
+	if err != nil {
+		return err
+	}
+	if ended.Vote.Result != room.VotePassed {
+		return fmt.Errorf("vote ended %q, want %s", ended.Vote.Result, room.VotePassed)
+	}
+	if _, err := c.Expect("voteKicked", 0); err != nil {
+		return err
+	}
+	left, err := a.Expect("playerLeft", 0)
+	if err != nil {
+		return err
+	}
+	if left.Message != c.ID || len(left.Players) != 2 {
+		return fmt.Errorf("playerLeft for %q with %d players, want %q with 2", left.Message, len(left.Players), c.ID)
+	}
+
+	c.Send(messages.ClientMessage{Type: "join", RoomID: "vote"})
+	rejected, err := c.Expect("joinRejected", 0)
+	if err != nil {
+		return err
+	}
+	if rejected.Error != server.ErrCodeKicked {
+		return fmt.Errorf("rejoin rejected with %q, want %s", rejected.Error, server.ErrCodeKicked)
+	}
+	return nil
+}
+
+// hostPrivileges has the first player into a room use every host command,
+// checks others can't, and that the room passes on when its host leaves
+func hostPrivileges(h *harness.Harness) error {
+	var clients []*harness.Client
+	for range 3 {
+		c, err := h.Connect("")
+		if err != nil {
+			return err
+		}
+		c.Send(messages.ClientMessage{Type: "join", RoomID: "hosted", Seed: 3})
+		joined, err := c.Expect("mazeData", 0)
+		if err != nil {
+			return err
+		}
+		first := c
+		if len(clients) > 0 {
+			first = clients[0]
+		}
+		if joined.Host != first.ID {
+			return fmt.Errorf("%s was told %q hosts, want the first to join", c.ID, joined.Host)
+		}
+		clients = append(clients, c)
+	}
+	a, b, c := clients[0], clients[1], clients[2]
+	expectError := func(cl *harness.Client, code string) error {
+		msg, err := cl.Expect("error", 0)
+		if err == nil && msg.Error != code {
+			err = fmt.Errorf("error %s, want %s", msg.Error, code)
+		}
+		return err
+	}
+
+	b.Send(messages.ClientMessage{Type: "kick", PlayerID: c.ID})
+	if err := expectError(b, server.ErrCodeNotHost); err != nil {
+		return err
+	}
+
+	a.Send(messages.ClientMessage{Type: "mazeSettings", Seed: 77})
+	round, err := b.Expect("newRound", 0)
+	if err != nil {
+		return err
+	}
+	if round.Maze.Seed != 77 {
+		return fmt.Errorf("new maze has seed %d, want 77", round.Maze.Seed)
+	}
+
+	a.Send(messages.ClientMessage{Type: "lockRoom", Locked: true})
+	if _, err := b.Expect("roomLocked", 0); err != nil {
+		return err
+	}
+	d, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	d.Send(messages.ClientMessage{Type: "join", RoomID: "hosted"})
+	if rejected, err := d.Expect("joinRejected", 0); err != nil {
+		return err
+	} else if rejected.Error != server.ErrCodeRoomLocked {
+		return fmt.Errorf("join of a locked room rejected with %s", rejected.Error)
+	}
+	a.Send(messages.ClientMessage{Type: "lockRoom"})
+	if _, err := b.Expect("roomUnlocked", 0); err != nil {
+		return err
+	}
+
+	a.Send(messages.ClientMessage{Type: "kick", PlayerID: b.ID, Ban: true})
+	if kicked, err := b.Expect("hostKicked", 0); err != nil {
+		return err
+	} else if kicked.Reason != "banned" {
+		return fmt.Errorf("kicked with reason %q, want banned", kicked.Reason)
+	}
+	b.Send(messages.ClientMessage{Type: "join", RoomID: "hosted"})
+	if rejected, err := b.Expect("joinRejected", 0); err != nil {
+		return err
+	} else if rejected.Error != server.ErrCodeKicked {
+		return fmt.Errorf("banned player's join rejected with %s", rejected.Error)
+	}
+
+	a.Send(messages.ClientMessage{Type: "transferHost", PlayerID: c.ID})
+	if changed, err := c.Expect("hostChanged", 0); err != nil {
+		return err
+	} else if changed.Host != c.ID || changed.Reason != room.HostTransferred {
+		return fmt.Errorf("host changed to %q (%s), want %s by transfer", changed.Host, changed.Reason, c.ID)
+	}
+	if _, err := a.Expect("hostChanged", 0); err != nil {
+		return err
+	}
+	c.Disconnect()
+	if changed, err := a.Expect("hostChanged", 0); err != nil {
+		return err
+	} else if changed.Host != a.ID || changed.Reason != room.HostLeft {
+		return fmt.Errorf("host changed to %q (%s), want %s after the host left", changed.Host, changed.Reason, a.ID)
+	}
+	return nil
+}
+
+// roomVotes has two players in a room without a host vote the maze down to
+// a smaller size, race on it, then vote the finished match into a restart
+func roomVotes(h *harness.Harness) error {
+	var clients []*harness.Client
+	for range 2 {
+		c, err := h.Connect("")
+		if err != nil {
+			return err
+		}
+		c.Send(messages.ClientMessage{Type: "join", RoomID: "votes"})
+		if _, err := c.Expect("mazeData", 0); err != nil {
+			return err
+		}
+		clients = append(clients, c)
+	}
+	a, b := clients[0], clients[1]
+
+	a.Send(messages.ClientMessage{Type: "startVote", VoteKind: room.VoteMazeSize, Size: room.MaxVoteMazeSize + 1})
+	if msg, err := a.Expect("error", 0); err != nil {
+		return err
+	} else if msg.Error != server.ErrCodeBadRequest {
+		return fmt.Errorf("oversized maze vote got %s, want %s", msg.Error, server.ErrCodeBadRequest)
+	}
+
+	a.Send(messages.ClientMessage{Type: "startVote", VoteKind: room.VoteMazeSize, Size: 6})
+	started, err := b.Expect("voteStarted", 0)
+	if err != nil {
+		return err
+	}
+	if v := started.Vote; v.Size != 6 || v.Needed != 2 {
+		return fmt.Errorf("vote started as %+v, want size 6 needing 2 yes", v)
+	}
+	b.Send(messages.ClientMessage{Type: "castVote", Yes: true})
+	round, err := a.Expect("newRound", 0)
+	if err != nil {
+		return err
+	}
+	if round.Maze.Width != 6 || round.Maze.Height != 6 {
+		return fmt.Errorf("voted maze is %dx%d, want 6x6", round.Maze.Width, round.Maze.Height)
+	}
+	if _, err := b.Expect("newRound", 0); err != nil {
+		return err
+	}
+
+	a.Send(messages.ClientMessage{Type: "ready"})
+	b.Send(messages.ClientMessage{Type: "ready"})
+	if err := awaitPlaying(a, b); err != nil {
+		return err
+	}
+	path, err := a.PathToGoal(0, 0)
+	if err != nil {
+		return err
+	}
+	if err := a.Walk(path, room.BaseMoveInterval); err != nil {
+		return err
+	}
+	if _, err := b.Expect("gameOver", 0); err != nil {
+		return err
+	}
+
+	b.Send(messages.ClientMessage{Type: "startVote", VoteKind: room.VoteRestart})
+	if _, err := a.Expect("voteStarted", 0); err != nil {
+		return err
+	}
+	a.Send(messages.ClientMessage{Type: "castVote", Yes: true})
+	if ended, err := b.Expect("voteEnded", 0); err != nil {
+		return err
+	} else if ended.Vote.Result != room.VotePassed {
+		return fmt.Errorf("restart vote %s, want %s", ended.Vote.Result, room.VotePassed)
+	}
+	restarted, err := b.Expect("newRound", 0)
+	if err != nil {
+		return err
+	}
+	if restarted.Round != 1 || restarted.Maze.Width != 6 {
+		return fmt.Errorf("restart is round %d on a %d-wide maze, want round 1 at 6", restarted.Round, restarted.Maze.Width)
+	}
+	for _, p := range restarted.Players {
+		if p.Score != 0 {
+			return fmt.Errorf("%s kept a score of %d through the restart", p.ID, p.Score)
+		}
+	}
+	if _, err := b.Await("gameStarting", time.Second); err != nil {
+		return err
+	}
+	return nil
+}
+
+// idlePlayers lets two players idle: both are flagged, one comes back for a
+// while, the other is removed, and the room closes once the first idles out
+// too
+func idlePlayers(h *harness.Harness) error {
+	h.Server.Rooms().Settings.IdleTimeout = time.Second
+	h.Server.Rooms().Settings.IdleGrace = time.Second
+	var clients []*harness.Client
+	for range 2 {
+		c, err := h.Connect("")
+		if err != nil {
+			return err
+		}
+		c.Send(messages.ClientMessage{Type: "join", RoomID: "idle"})
+		if _, err := c.Expect("mazeData", 0); err != nil {
+			return err
+		}
+		clients = append(clients, c)
+	}
+	a, b := clients[0], clients[1]
+
+	flagged := map[string]bool{}
+	for range 2 {
+		msg, err := a.Await("playerIdle", 2*time.Second)
+		if err != nil {
+			return err
+		}
+		flagged[msg.Message] = true
+	}
+	if !flagged[a.ID] || !flagged[b.ID] {
+		return fmt.Errorf("flagged %v idle, want both players", flagged)
+	}
+
+	a.Send(messages.ClientMessage{Type: "listRooms"})
+	if back, err := a.Expect("playerActive", 0); err != nil {
+		return err
+	} else if back.Message != a.ID {
+		return fmt.Errorf("%s was told %s is active again", a.ID, back.Message)
+	}
+	if _, err := b.Await("idleRemoved", 2*time.Second); err != nil {
+		return err
+	}
+	if left, err := a.Expect("playerLeft", 0); err != nil {
+		return err
+	} else if left.Message != b.ID {
+		return fmt.Errorf("playerLeft for %q, want %q", left.Message, b.ID)
+	}
+
+	if _, err := a.Await("idleRemoved", 3*time.Second); err != nil {
+		return err
+	}
+	// The room is closed behind the last player's back
+	for deadline := time.Now().Add(harness.DefaultTimeout); h.Server.Rooms().GetRoom("idle") != nil; {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("the room stayed open with nobody left in it")
+		}
+		h.Clock.Advance(room.DefaultTickInterval)
+		time.Sleep(time.Millisecond)
+	}
+	return nil
+}
+
+// attractMode watches bots start a demo match in an empty public room,
+// finds it in the room list, then joins and gets the room to themselves
+func attractMode(h *harness.Harness) error {
+	h.Server.Rooms().Settings.AttractMode = true
+	h.Server.Rooms().GetOrCreateRoom("attract", room.Options{})
+
+	watcher, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	watcher.Send(messages.ClientMessage{Type: "spectate", RoomID: "attract"})
+	if _, err := watcher.Expect("spectating", 0); err != nil {
+		return err
+	}
+	demo, err := watcher.Await("demoStarted", room.AttractDelay+time.Second)
+	if err != nil {
+		return err
+	}
+	if len(demo.Players) != room.DemoBots {
+		return fmt.Errorf("demo started with %d players", len(demo.Players))
+	}
+	if err := awaitPlaying(watcher); err != nil {
+		return err
+	}
+	if _, err := watcher.Await("playerMoved", time.Second); err != nil {
+		return err
+	}
+
+	player, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	player.Send(messages.ClientMessage{Type: "listRooms"})
+	list, err := player.Expect("roomList", 0)
+	if err != nil {
+		return err
+	}
+	if len(list.Rooms) != 1 || !list.Rooms[0].Demo || !list.Rooms[0].Joinable || list.Rooms[0].Players != 0 {
+		return fmt.Errorf("room list %+v", list.Rooms)
+	}
+
+	player.Send(messages.ClientMessage{Type: "join", RoomID: "attract"})
+	joined, err := player.Expect("mazeData", 0)
+	if err != nil {
+		return err
+	}
+	if len(joined.Players) != 1 || joined.State != string(room.StateWaiting) {
+		return fmt.Errorf("joined a %s room with %d players", joined.State, len(joined.Players))
+	}
+	if _, err := watcher.Expect("demoEnded", 0); err != nil {
+		return err
+	}
+	return nil
+}
+
+// teamChat fills a two-team room: every player is tinted with their
+// team's color, and a team chat line reaches the sender's teammate only,
+// now and in the history
+func teamChat(h *harness.Harness) error {
+	var clients []*harness.Client
+	for range 4 {
+		c, err := h.Connect("")
+		if err != nil {
+			return err
+		}
+		c.Send(messages.ClientMessage{Type: "join", RoomID: "duo", Teams: 2, MaxPlayers: 4})
+		if _, err := c.Expect("mazeData", 0); err != nil {
+			return err
+		}
+		clients = append(clients, c)
+	}
+
+	r := h.Server.Rooms().GetRoom("duo")
+	team := make(map[string]int)
+	for _, p := range r.GetPlayers() {
+		if p.Team < 1 || p.TeamColor != room.TeamColors[p.Team-1] {
+			return fmt.Errorf("player %s on team %d colored %q", p.ID, p.Team, p.TeamColor)
+		}
+		team[p.ID] = p.Team
+	}
+
+	sender := clients[0]
+	sender.Send(messages.ClientMessage{Type: "chat", Channel: room.ChatChannelTeam, Text: "left side"})
+	if _, err := sender.Expect("chat", 0); err != nil {
+		return err
+	}
+	for _, c := range clients[1:] {
+		if team[c.ID] == team[sender.ID] {
+			line, err := c.Expect("chat", 0)
+			if err != nil {
+				return err
+			}
+			if line.Chat[0].Text != "left side" || line.Chat[0].Channel != room.ChatChannelTeam {
+				return fmt.Errorf("teammate got %+v", line.Chat[0])
+			}
+			continue
+		}
+		if history := r.ChatHistory(c.ID); len(history) > 0 {
+			return fmt.Errorf("opponent %s can read %+v", c.ID, history)
+		}
+	}
+
+	// Free-for-all rooms have no team channel
+	solo, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	solo.Send(messages.ClientMessage{Type: "join", RoomID: "ffa"})
+	if _, err := solo.Expect("mazeData", 0); err != nil {
+		return err
+	}
+	solo.Send(messages.ClientMessage{Type: "chat", Channel: room.ChatChannelTeam, Text: "anyone?"})
+	if _, err := solo.Expect("chatRejected", 0); err != nil {
+		return err
+	}
+	return nil
+}
+
+// coopEscape has two co-op players walk out one after the other: the
+// first out waits at the exit, and the second out wins it for both
+func coopEscape(h *harness.Harness) error {
+	a, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	b, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	for _, c := range []*harness.Client{a, b} {
+		c.Send(messages.ClientMessage{Type: "join", RoomID: "coop", Seed: 6, Coop: true})
+		if _, err := c.Expect("mazeData", 0); err != nil {
+			return err
+		}
+		c.Send(messages.ClientMessage{Type: "ready"})
+	}
+	if err := awaitPlaying(a, b); err != nil {
+		return err
+	}
+
+	r := h.Server.Rooms().GetRoom("coop")
+	walkOut := func(c *harness.Client) error {
+		me := playerIn(r, c.ID)
+		path, err := c.PathToGoal(me.X, me.Y)
+		if err != nil {
+			return err
+		}
+		return c.Walk(path, room.BaseMoveInterval)
+	}
+
+	if err := walkOut(a); err != nil {
+		return err
+	}
+	home, err := b.Expect("playerHome", 0)
+	if err != nil {
+		return err
+	}
+	if home.Message != a.ID || r.GetState() != room.StatePlaying {
+		return fmt.Errorf("%s home, room %s", home.Message, r.GetState())
+	}
+	a.Send(messages.ClientMessage{Type: "moveDir", Direction: "up"})
+	if _, err := a.Expect("error", 0); err != nil {
+		return fmt.Errorf("a player who is out moved on: %w", err)
+	}
+
+	if err := walkOut(b); err != nil {
+		return err
+	}
+	over, err := a.Expect("gameOver", 0)
+	if err != nil {
+		return err
+	}
+	if over.Reason != "teamGoal" || over.WinningTeam != 1 {
+		return fmt.Errorf("gameOver reason=%q team=%d, want teamGoal by team 1", over.Reason, over.WinningTeam)
+	}
+	return nil
+}
+
+// captureTheFlag has one player of a two-team ctf room raid the other's
+// base: the flag slows them down, dropping and retaking it works, and
+// bringing it home is a capture
+func captureTheFlag(h *harness.Harness) error {
+	raider, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	defender, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	for _, c := range []*harness.Client{raider, defender} {
+		c.Send(messages.ClientMessage{Type: "join", RoomID: "ctf", Mode: room.ModeCTF, Seed: 8})
+		if _, err := c.Expect("mazeData", 0); err != nil {
+			return err
+		}
+		c.Send(messages.ClientMessage{Type: "ready"})
+	}
+	// Flags are planted as the match starts
+	for _, c := range []*harness.Client{raider, defender} {
+		if err := c.Sync(); err != nil {
+			return err
+		}
+	}
+	start, err := raider.Await("flags", room.CountdownDuration+time.Second)
+	if err != nil {
+		return err
+	}
+	if len(start.Flags) != 2 {
+		return fmt.Errorf("%d flags, want one per team", len(start.Flags))
+	}
+
+	var own, enemy messages.Flag
+	for _, p := range start.Players {
+		if p.ID != raider.ID {
+			continue
+		}
+		for _, f := range start.Flags {
+			if f.Team == p.Team {
+				own = f
+			} else {
+				enemy = f
+			}
+		}
+		if p.X != own.Base.X || p.Y != own.Base.Y {
+			return fmt.Errorf("raider starts at (%d, %d), not their base %+v", p.X, p.Y, own.Base)
+		}
+	}
+
+	walk := func(from, to messages.Position, interval time.Duration) (messages.Position, error) {
+		path, err := raider.PathTo(from.X, from.Y, to)
+		if err != nil {
+			return from, err
+		}
+		return to, raider.Walk(path, interval)
+	}
+	carrying := 2 * room.BaseMoveInterval // Comfortably slower than a carrier's move interval
+
+	at, err := walk(own.Base, enemy.Base, room.BaseMoveInterval)
+	if err != nil {
+		return err
+	}
+	taken, err := raider.Expect("flagTaken", 0)
+	if err != nil {
+		return err
+	}
+	if taken.Message != raider.ID {
+		return fmt.Errorf("flag taken by %q", taken.Message)
+	}
+
+	// Carrying slows the raider below the base move rate
+	path, err := raider.PathTo(at.X, at.Y, own.Base)
+	if err != nil {
+		return err
+	}
+	if err := raider.Walk(path[:1], carrying); err != nil {
+		return err
+	}
+	h.Clock.Advance(room.BaseMoveInterval)
+	raider.Send(messages.ClientMessage{Type: "move", X: path[1].X, Y: path[1].Y})
+	if msg, err := raider.Expect("error", 0); err != nil || msg.Error != server.ErrCodeTooFast {
+		return fmt.Errorf("a carrier moved at full speed: %v %+v", err, msg)
+	}
+
+	// Dropping leaves it on the raider's cell, and stepping back onto it
+	// takes it again
+	raider.Send(messages.ClientMessage{Type: "ctf.drop"})
+	dropped, err := raider.Expect("flagDropped", 0)
+	if err != nil {
+		return err
+	}
+	for _, f := range dropped.Flags {
+		if f.Team == enemy.Team && (f.Carrier != "" || f.X != path[0].X || f.Y != path[0].Y || f.ReturnIn <= 0) {
+			return fmt.Errorf("dropped flag is %+v", f)
+		}
+	}
+	if err := raider.Walk([]messages.Position{at, path[0]}, room.BaseMoveInterval); err != nil {
+		return err
+	}
+	if _, err := raider.Expect("flagTaken", 0); err != nil {
+		return err
+	}
+
+	if _, err := walk(path[0], own.Base, carrying); err != nil {
+		return err
+	}
+	captured, err := defender.Expect("flagCaptured", 0)
+	if err != nil {
+		return err
+	}
+	for _, f := range captured.Flags {
+		switch {
+		case !f.Home:
+			return fmt.Errorf("flag of team %d not home after the capture", f.Team)
+		case f.Team == own.Team && f.Captures != 1:
+			return fmt.Errorf("raider's team has %d captures, want 1", f.Captures)
+		}
+	}
+	return nil
+}
+
+// chaseMode is the barest pursuit mode, for trying out breadcrumbs: whoever
+// sends chase.hunt is the hunter
+type chaseMode struct {
+	hunter string
+}
+
+func init() {
+	room.RegisterMode("chase", func() room.GameMode { return &chaseMode{} })
+}
+
+func (m *chaseMode) Name() string { return "chase" }
+
+func (m *chaseMode) HandleMessage(tx *room.Tx, playerID, action string, msg messages.ClientMessage) error {
+	if action != "hunt" {
+		return room.ErrUnknownAction
+	}
+	m.hunter = playerID
+	return nil
+}
+
+func (m *chaseMode) IsHunter(playerID string) bool { return playerID == m.hunter }
+
+// breadcrumbs has a runner take a few steps in a chase room: the hunter
+// must be shown their trail, the runner never, and the trail must fade
+// away once they stop
+func breadcrumbs(h *harness.Harness) error {
+	hunter, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	runner, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	for _, c := range []*harness.Client{hunter, runner} {
+		c.Send(messages.ClientMessage{Type: "join", RoomID: "chase", Mode: "chase", Seed: 4})
+		if _, err := c.Expect("mazeData", 0); err != nil {
+			return err
+		}
+		c.Send(messages.ClientMessage{Type: "ready"})
+	}
+	hunter.Send(messages.ClientMessage{Type: "chase.hunt"})
+	if err := awaitPlaying(runner, hunter); err != nil {
+		return err
+	}
+
+	from := playerIn(h.Server.Rooms().GetRoom("chase"), runner.ID)
+	path, err := runner.PathToGoal(from.X, from.Y)
+	if err != nil {
+		return err
+	}
+	const steps = 3
+	if err := runner.Walk(path[:steps], room.BaseMoveInterval); err != nil {
+		return err
+	}
+
+	var trail []messages.Breadcrumb
+	for len(trail) < steps {
+		msg, err := hunter.Await("breadcrumbs", time.Second)
+		if err != nil {
+			return err
+		}
+		trail = msg.Breadcrumbs
+	}
+	if trail[0].PlayerID != runner.ID || trail[0].X != from.X || trail[0].Y != from.Y {
+		return fmt.Errorf("trail starts at %+v, want %s at (%d, %d)", trail[0], runner.ID, from.X, from.Y)
+	}
+	for i := 1; i < steps; i++ {
+		if trail[i].X != path[i-1].X || trail[i].Y != path[i-1].Y || trail[i].Fade > trail[i-1].Fade {
+			return fmt.Errorf("breadcrumb %d is %+v, want (%d, %d) and fresher than the one before", i, trail[i], path[i-1].X, path[i-1].Y)
+		}
+	}
+
+	// The trail fades out entirely once the runner stops
+	for len(trail) > 0 {
+		msg, err := hunter.Await("breadcrumbs", room.TrailLifetime+time.Second)
+		if err != nil {
+			return err
+		}
+		trail = msg.Breadcrumbs
+	}
+	for {
+		if _, err := runner.Next(time.Millisecond); err != nil {
+			break // Caught up
+		}
+	}
+	for _, msg := range runner.Log {
+		if msg.Type == "breadcrumbs" {
+			return fmt.Errorf("the runner was shown breadcrumbs")
+		}
+	}
+	return nil
+}
+
+// camping has two players stand still in an anti-camping room: once the
+// window passes both are warned, and each sees the other revealed where
+// they stand
+func camping(h *harness.Harness) error {
+	window := 2 * time.Second
+	r, _ := h.Server.Rooms().GetOrCreateRoom("camp", room.Options{
+		Maze:  game.Options{Seed: 3},
+		Rules: room.RuleSet{AntiCamp: room.AntiCampReveal, CampWindow: window},
+	})
+	a, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	b, err := h.Connect("")
+	if err != nil {
+		return err
+	}
+	for _, c := range []*harness.Client{a, b} {
+		c.Send(messages.ClientMessage{Type: "join", RoomID: "camp"})
+		if _, err := c.Expect("mazeData", 0); err != nil {
+			return err
+		}
+		c.Send(messages.ClientMessage{Type: "ready"})
+	}
+	if err := awaitPlaying(a, b); err != nil {
+		return err
+	}
+
+	warning, err := a.Await("campingWarning", window+time.Second)
+	if err != nil {
+		return err
+	}
+	if warning.Reason != room.AntiCampReveal {
+		return fmt.Errorf("warned with penalty %q, want %q", warning.Reason, room.AntiCampReveal)
+	}
+	revealed, err := b.Await("camperRevealed", time.Second)
+	for err == nil && revealed.Message != a.ID {
+		revealed, err = b.Await("camperRevealed", time.Second)
+	}
+	if err != nil {
+		return err
+	}
+	me := playerIn(r, a.ID)
+	if revealed.Position == nil || revealed.Position.X != me.X || revealed.Position.Y != me.Y {
+		return fmt.Errorf("camper at (%d,%d) revealed at %+v", me.X, me.Y, revealed.Position)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package server_test
+
+import (
+	"testing"
+	"time"
+
+	"labyrinth-duel/websocket/internal/harness"
+	"labyrinth-duel/websocket/internal/messages"
+	"labyrinth-duel/websocket/internal/room"
+)
+
+// scenario is one scripted game, played against a fresh server
+type scenario struct {
+	name string
+	run  func(h *harness.Harness) error
+}
+
+// runScenarios plays each scenario as a parallel subtest
+func runScenarios(t *testing.T, scenarios []scenario) {
+	for _, sc := range scenarios {
+		t.Run(sc.name, func(t *testing.T) {
+			t.Parallel()
+			h := harness.New()
+			defer h.Close()
+			if err := sc.run(h); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// awaitPlaying waits for the server to handle what the clients sent, then
+// runs the clock until the first of them is told the match is on
+func awaitPlaying(clients ...*harness.Client) error {
+	for _, c := range clients {
+		if err := c.Sync(); err != nil {
+			return err
+		}
+	}
+	for {
+		msg, err := clients[0].Await("countdown", room.CountdownDuration+time.Second)
+		if err != nil {
+			return err
+		}
+		if msg.State == string(room.StatePlaying) {
+			return nil
+		}
+	}
+}
+
+// playerIn returns a player's state in a room
+func playerIn(r *room.Room, playerID string) messages.Player {
+	for _, p := range r.GetPlayers() {
+		if p.ID == playerID {
+			return p
+		}
+	}
+	return messages.Player{}
+}
+
+// isGoal reports whether a cell is one of the maze's exits
+func isGoal(maze *messages.MazeData, x, y int) bool {
+	for _, g := range maze.Goals {
+		if g.X == x && g.Y == y {
+			return true
+		}
+	}
+	return false
+}
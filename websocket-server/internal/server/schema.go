@@ -0,0 +1,51 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+
+	"labyrinth-duel/websocket/internal/schema"
+)
+
+var (
+	schemaDocs     map[string][]byte
+	schemaDocsOnce sync.Once
+)
+
+// HandleSchema serves the protocol documents: /schema lists them,
+// /schema/asyncapi.json describes the WebSocket protocol and
+// /schema/openapi.json the HTTP endpoints
+func (s *Server) HandleSchema(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	schemaDocsOnce.Do(func() {
+		schemaDocs = make(map[string][]byte)
+		for name, doc := range map[string]schema.Schema{
+			"asyncapi.json": schema.AsyncAPI(),
+			"openapi.json":  schema.OpenAPI(),
+		} {
+			schemaDocs[name], _ = json.MarshalIndent(doc, "", "  ")
+		}
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/schema"), "/")
+	if name == "" {
+		json.NewEncoder(w).Encode(map[string]string{
+			"asyncapi": "/schema/asyncapi.json",
+			"openapi":  "/schema/openapi.json",
+		})
+		return
+	}
+	doc, ok := schemaDocs[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Write(doc)
+}
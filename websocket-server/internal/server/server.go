@@ -0,0 +1,746 @@
+// Package server implements the game protocol on top of any message
+// transport: real WebSockets in production, or in-memory pipes for
+// integration tests.
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"labyrinth-duel/websocket/internal/auth"
+	"labyrinth-duel/websocket/internal/clock"
+	"labyrinth-duel/websocket/internal/config"
+	"labyrinth-duel/websocket/internal/daily"
+	"labyrinth-duel/websocket/internal/history"
+	"labyrinth-duel/websocket/internal/leaderboard"
+	"labyrinth-duel/websocket/internal/level"
+	"labyrinth-duel/websocket/internal/matchmaking"
+	"labyrinth-duel/websocket/internal/messages"
+	"labyrinth-duel/websocket/internal/metrics"
+	"labyrinth-duel/websocket/internal/persist"
+	"labyrinth-duel/websocket/internal/profile"
+	"labyrinth-duel/websocket/internal/rating"
+	"labyrinth-duel/websocket/internal/replay"
+	"labyrinth-duel/websocket/internal/room"
+	"labyrinth-duel/websocket/internal/schema"
+	"labyrinth-duel/websocket/internal/trace"
+)
+
+// Conn is a client connection carrying one message per frame.
+// *websocket.Conn satisfies it.
+type Conn interface {
+	ReadMessage() (messageType int, p []byte, err error)
+	WriteMessage(messageType int, data []byte) error
+	Close() error
+}
+
+// MaxMessageSize caps one inbound frame; enough for the largest maze
+// upload
+const MaxMessageSize = 1 << 20
+
+// Server holds every subsystem shared by connected clients
+type Server struct {
+	config     config.Config
+	rooms      *room.Manager
+	matchmaker *matchmaking.Matchmaker
+	profiles   *profile.Store
+	ratings    *rating.Ratings
+	tracer     *trace.Tracer
+	validator  *schema.Validator
+	metrics    *metrics.Metrics
+
+	// AdminToken guards the admin endpoints; they are disabled when empty
+	AdminToken string
+	// Auth verifies the tokens players connect with; without it every
+	// player is anonymous
+	Auth *auth.Verifier
+	// RequireAuth turns away clients that present no token
+	RequireAuth bool
+	// Origins are the web pages allowed to connect and use the HTTP API
+	Origins OriginPolicy
+	// RateLimits caps each client's inbound messages per type
+	RateLimits map[string]Limit
+	// Replays keeps finished matches for playback (in memory by default)
+	Replays replay.Store
+	// Levels keeps uploaded mazes rooms can be created from (in memory by
+	// default)
+	Levels level.Store
+	// RoomStore, if set, is where rooms are saved every PersistInterval and
+	// on Stop, for RestoreRooms to bring back after a restart
+	RoomStore persist.Store
+	// Suspended keeps matches suspended by vote until a player resumes them
+	// (in memory by default)
+	Suspended persist.Store
+	// Daily keeps the daily challenge leaderboards (in memory by default)
+	Daily daily.Store
+	// History keeps every player's finished matches (recent ones in memory
+	// by default)
+	History history.Store
+	// Leaderboards rank players overall and in each mode (in memory by
+	// default)
+	Leaderboards *leaderboard.Leaderboards
+	// StrictValidation checks every inbound message against its schema and
+	// rejects it with field-level errors, instead of ignoring unknown fields
+	// and zeroing bad ones
+	StrictValidation bool
+	// MinProtocol is the oldest protocol version clients may speak; older
+	// ones are turned away (0 = messages.MinProtocolVersion)
+	MinProtocol int
+
+	// Clients with a live connection by player ID, so an ID can't be used
+	// twice at once
+	online   map[string]*Client
+	onlineMu sync.Mutex
+
+	// Players asking to resume each suspended match, by resume code
+	resumes  map[string]map[string]*Client
+	resumeMu sync.Mutex
+
+	// Set by JoinCluster when rooms are shared with other instances
+	cluster *cluster
+
+	// IDs of the rooms currently saved in RoomStore
+	persisted map[string]bool
+	persistMu sync.Mutex
+
+	clock    clock.Clock
+	loops    []func()    // Stop the background loops started by Run
+	running  atomic.Bool // Run was called
+	draining atomic.Bool // Drain was called
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// New creates a server running with cfg whose ratings live in the given
+// store
+func New(cfg config.Config, ratingStore rating.Store) *Server {
+	rooms := room.NewManager()
+	rooms.Debug = cfg.Debug
+	rooms.Settings = room.Settings{
+		MazeWidth:     cfg.MazeWidth,
+		MazeHeight:    cfg.MazeHeight,
+		MaxPlayers:    cfg.MaxPlayers,
+		TickInterval:  cfg.TickInterval,
+		MatchDuration: cfg.MatchDuration,
+		RoomTTL:       cfg.RoomTTL,
+		AttractMode:   cfg.AttractMode,
+		IdleTimeout:   cfg.IdleTimeout,
+		IdleGrace:     cfg.IdleGrace,
+	}
+	s := &Server{
+		config:           cfg,
+		rooms:            rooms,
+		matchmaker:       matchmaking.New(rooms),
+		profiles:         profile.NewStore(),
+		ratings:          rating.New(ratingStore),
+		tracer:           trace.New(),
+		validator:        schema.NewValidator(),
+		metrics:          metrics.New(),
+		AdminToken:       cfg.AdminToken,
+		StrictValidation: cfg.StrictValidation,
+		MinProtocol:      cfg.MinProtocol,
+		Origins:          OriginPolicy{Allowed: cfg.Origins(), AnyOrigin: cfg.DevAnyOrigin},
+		RequireAuth:      cfg.RequireAuth,
+		RateLimits:       DefaultRateLimits,
+		Replays:          replay.NewMemoryStore(0),
+		Levels:           level.NewMemoryStore(0),
+		Suspended:        persist.NewMemoryStore(),
+		Daily:            daily.NewMemoryStore(),
+		Leaderboards:     leaderboard.New(leaderboard.NewMemoryStore()),
+		History:          history.NewMemoryStore(0),
+		resumes:          make(map[string]map[string]*Client),
+		online:           make(map[string]*Client),
+		persisted:        make(map[string]bool),
+		clock:            clock.Real{},
+		done:             make(chan struct{}),
+	}
+	if cfg.JWTSecret != "" {
+		s.Auth = auth.NewVerifier(cfg.JWTSecret, cfg.JWTIssuer)
+	}
+	s.matchmaker.FillWithBots = cfg.BotFill
+	rooms.OnMatchFinished = s.recordMatch
+	rooms.OnSuspend = s.suspendMatch
+	rooms.OnTutorialComplete = s.profiles.CompleteTutorial
+	rooms.OnIdleRemoved = s.idleRemoved
+	s.matchmaker.Penalize = func(id string) time.Duration {
+		return s.penalize(id, profile.OffenceDecline)
+	}
+	return s
+}
+
+// Run starts the server's background loops: the matchmaker, leaderboard
+// broadcasts every LeaderboardInterval, saving the rooms every
+// PersistInterval with a RoomStore, and renewing room claims every
+// ClaimInterval in a cluster
+func (s *Server) Run() {
+	s.running.Store(true)
+	s.matchmaker.Run()
+	s.every(LeaderboardInterval, func(time.Time) { s.BroadcastLeaderboard() })
+	if s.RoomStore != nil {
+		s.every(PersistInterval, func(time.Time) { s.SaveRooms() })
+	}
+	if s.cluster != nil {
+		s.every(ClaimInterval, func(time.Time) { s.renewClaims() })
+	}
+}
+
+// every runs f on the server's clock until Stop
+func (s *Server) every(d time.Duration, f func(now time.Time)) {
+	s.loops = append(s.loops, s.clock.Every(d, f))
+}
+
+// Stop shuts down the background loops, saving the rooms one last time
+func (s *Server) Stop() {
+	s.matchmaker.Stop()
+	s.stopOnce.Do(func() {
+		for _, stop := range s.loops {
+			stop()
+		}
+		close(s.done)
+		s.SaveRooms()
+	})
+}
+
+// UseClock has the server, its rooms and its matchmaker tell the time and
+// run their timers by c instead of the system clock. Call it before Run
+// and before any room is created.
+func (s *Server) UseClock(c clock.Clock) {
+	s.clock = c
+	s.rooms.Clock = c
+	s.matchmaker.Clock = c
+}
+
+// Rooms returns the server's room manager
+func (s *Server) Rooms() *room.Manager {
+	return s.rooms
+}
+
+// Matchmaker returns the server's matchmaker
+func (s *Server) Matchmaker() *matchmaking.Matchmaker {
+	return s.matchmaker
+}
+
+// HandleRooms serves GET /rooms with the same listing as listRooms
+func (s *Server) HandleRooms(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.rooms.ListRooms())
+}
+
+// HandleTrace serves the protocol tracing admin API. GET lists the traced
+// scopes, POST ?room= or ?client= (with optional minutes=) starts tracing
+// one, and DELETE with the same parameters stops it.
+func (s *Server) HandleTrace(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+
+	q := r.URL.Query()
+	kind, id := trace.ScopeRoom, q.Get("room")
+	if client := q.Get("client"); client != "" {
+		kind, id = trace.ScopeClient, client
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(s.tracer.Active())
+	case http.MethodPost:
+		if id == "" {
+			http.Error(w, "room or client required", http.StatusBadRequest)
+			return
+		}
+		var d time.Duration
+		if minutes := q.Get("minutes"); minutes != "" {
+			n, err := strconv.Atoi(minutes)
+			if err != nil || n <= 0 {
+				http.Error(w, "bad minutes", http.StatusBadRequest)
+				return
+			}
+			d = time.Duration(n) * time.Minute
+		}
+		scope := s.tracer.Enable(kind, id, d)
+		slog.Info("Tracing", "kind", scope.Kind, "id", scope.ID, "until", scope.Expires.Format(time.RFC3339))
+		json.NewEncoder(w).Encode(scope)
+	case http.MethodDelete:
+		if id == "" {
+			http.Error(w, "room or client required", http.StatusBadRequest)
+			return
+		}
+		s.tracer.Disable(kind, id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// authorizeAdmin checks the request carries "Authorization: Bearer
+// <AdminToken>", or HTTP basic auth with the token as the password so
+// browsers can open the dashboard, or a player token with the admin role,
+// writing an error response if not
+func (s *Server) authorizeAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if s.AdminToken == "" && s.Auth == nil {
+		http.Error(w, "admin API disabled", http.StatusForbidden)
+		return false
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if _, password, ok := r.BasicAuth(); ok {
+		token = password
+	}
+	if s.Auth != nil {
+		if claims, err := s.Auth.Verify(token, time.Now()); err == nil && claims.HasRole(auth.RoleAdmin) {
+			return true
+		}
+	}
+	if s.AdminToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(s.AdminToken)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Basic realm="maze admin"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// Handshake is what a client asks for when it connects, taken from the
+// WebSocket URL's query string
+type Handshake struct {
+	PlayerID string   // Persistent player ID to resume, if any
+	Secret   string   // Resume secret issued with PlayerID; without it a new ID is issued
+	Protocol int      // Protocol version the client speaks, if it says (see hello)
+	Encoding string   // Wire format: "" or json, msgpack
+	Caps     []string // Optional features wanted, e.g. compactMaze
+	// Claims are the client's verified identity, from Authenticate; they
+	// override PlayerID
+	Claims *auth.Claims
+}
+
+// HandshakeFromQuery reads ?playerId=&secret=&protocol=&encoding=&caps=a,b
+func HandshakeFromQuery(q url.Values) Handshake {
+	protocol, _ := strconv.Atoi(q.Get("protocol"))
+	return Handshake{
+		PlayerID: q.Get("playerId"),
+		Secret:   q.Get("secret"),
+		Protocol: protocol,
+		Encoding: q.Get("encoding"),
+		Caps:     messages.ParseCaps(q.Get("caps")),
+	}
+}
+
+// SupportedCaps are the capabilities a client may ask for; others are
+// ignored so newer clients still connect
+var SupportedCaps = []string{messages.CapCompactMaze, messages.CapTickBatch, messages.CapDeltaUpdates, messages.CapFog, messages.CapFloors}
+
+// enableCaps picks the capabilities asked for that the server supports,
+// returning them as a set and in the order asked
+func enableCaps(asked []string) (map[string]bool, []string) {
+	caps := make(map[string]bool)
+	var enabled []string
+	for _, c := range asked {
+		if slices.Contains(SupportedCaps, c) && !caps[c] {
+			caps[c] = true
+			enabled = append(enabled, c)
+		}
+	}
+	return caps, enabled
+}
+
+// Client represents a connected client
+type Client struct {
+	ID      string
+	Conn    Conn
+	RoomID  string          // Guarded by mu: rooms clear it from their goroutines when removing the client
+	codec   messages.Codec  // Wire format the client chose
+	caps    map[string]bool // Capabilities enabled for the connection
+	hello   bool            // Declared what it supports with hello, see Supports
+	version int             // Protocol version the client speaks (0 = not said yet, treated as the oldest)
+	replay  *playback       // Replay being streamed to the client, if any
+	watched string          // Room the client is spectating, if any
+	remote  string          // Cluster node hosting the client's room, if not this one
+	claims  *auth.Claims    // Verified identity, if the client presented a token
+	tracer  *trace.Tracer
+	metrics *metrics.Metrics
+	limiter *limiter
+
+	// Client message seqs: the highest received, and the highest the client
+	// has been told about in an ack
+	acked   uint64
+	sentAck uint64
+
+	mu sync.Mutex
+}
+
+// Serve runs a client connection until it closes
+func (s *Server) Serve(conn Conn, hs Handshake) {
+	defer conn.Close()
+
+	codec, ok := messages.CodecByName(hs.Encoding)
+	if !ok {
+		codec = messages.JSON
+	}
+	caps, enabled := enableCaps(hs.Caps)
+	if hs.Protocol != 0 && hs.Protocol < s.minProtocol() {
+		s.refuseProtocol(&Client{Conn: conn, codec: codec, metrics: s.metrics}, messages.ClientMessage{}, hs.Protocol)
+		return
+	}
+
+	// Verified players are who their token says; others resume their
+	// persistent ID with its secret, or are issued a new one. Either way
+	// they get a fresh secret to store for next time.
+	var id, secret string
+	if hs.Claims != nil {
+		if !s.claimVerifiedID(hs.Claims.Subject) {
+			(&Client{Conn: conn, codec: codec, metrics: s.metrics}).SendJSON(messages.ServerMessage{
+				Type:    "error",
+				Error:   ErrCodeAlreadyConnected,
+				Message: "already connected elsewhere",
+			})
+			return
+		}
+		id = hs.Claims.Subject
+		s.profiles.Verify(id, hs.Claims.Name)
+	} else {
+		id = s.claimPlayerID(hs.PlayerID, hs.Secret)
+		secret = s.profiles.IssueSecret(id)
+	}
+	client := &Client{
+		ID:      id,
+		Conn:    conn,
+		codec:   codec,
+		caps:    caps,
+		version: min(hs.Protocol, messages.ProtocolVersion),
+		claims:  hs.Claims,
+		tracer:  s.tracer,
+		metrics: s.metrics,
+		limiter: newLimiter(s.RateLimits),
+	}
+	defer s.releasePlayerID(client.ID)
+	s.setOnline(client)
+	defer client.startPlayback(nil)
+	s.metrics.Connected()
+	defer s.metrics.Disconnected()
+
+	client.logger("").Info("Connected", "encoding", codec.Name(), "verified", hs.Claims != nil)
+	if !ok {
+		sendError(client, messages.ClientMessage{}, ErrCodeBadRequest,
+			fmt.Sprintf("unknown encoding %q, using json", hs.Encoding))
+	}
+
+	// Send client their ID and profile
+	client.SendJSON(messages.ServerMessage{
+		Type:    "connected",
+		Message: client.ID,
+		Secret:  secret,
+		Profile: s.profileMessage(client.ID),
+		Caps:    enabled,
+	})
+
+	// Handle messages
+	for {
+		_, msgBytes, err := conn.ReadMessage()
+		if err != nil {
+			client.logger("").Debug("Read error", "err", err)
+			break
+		}
+		s.metrics.Received(len(msgBytes))
+
+		if s.StrictValidation && !s.validate(client, msgBytes) {
+			continue
+		}
+
+		var msg messages.ClientMessage
+		if err := client.codec.Decode(msgBytes, &msg); err != nil {
+			client.logger("").Info("Cannot parse message", "err", err)
+			sendError(client, msg, ErrCodeBadRequest, "malformed message")
+			continue
+		}
+		s.tracer.Inbound(client.ID, client.currentRoom(), msg)
+
+		switch client.limiter.check(msg.Type, s.clock.Now()) {
+		case drop:
+			continue
+		case warn:
+			client.logger(msg.Type).Warn("Over the rate limit")
+			sendError(client, msg, ErrCodeRateLimited, "too many "+msg.Type+" messages")
+			continue
+		case kick:
+			client.logger(msg.Type).Warn("Kicking for flooding")
+			client.SendJSON(messages.ServerMessage{
+				Type:   "kicked",
+				Reason: "rateLimited",
+				Error:  ErrCodeRateLimited,
+			})
+			s.handleDisconnect(client)
+			return
+		}
+
+		if msg.Seq != 0 && !client.receive(msg.Seq) {
+			continue // A replay of something already processed
+		}
+		if !s.settleProtocol(client, msg) {
+			break
+		}
+		s.route(client, msg)
+		client.flushAck()
+	}
+
+	// Cleanup on disconnect
+	s.handleDisconnect(client)
+}
+
+// dispatch routes a client message to its handler
+func (s *Server) dispatch(client *Client, msg messages.ClientMessage) {
+	if !idleExempt[msg.Type] {
+		s.markActive(client)
+	}
+	switch msg.Type {
+	case "hello":
+		s.handleHello(client, msg)
+	case "join":
+		s.handleJoin(client, msg)
+	case "startTutorial":
+		s.handleStartTutorial(client, msg)
+	case "startDaily":
+		s.handleStartDaily(client, msg)
+	case "dailyLeaderboard":
+		s.handleDailyLeaderboard(client, msg)
+	case "leaderboard":
+		s.handleLeaderboard(client, msg)
+	case "matchHistory":
+		s.handleMatchHistory(client, msg)
+	case "ready":
+		s.handleReady(client, msg)
+	case "move":
+		s.handleMove(client, msg)
+	case "moveDir":
+		s.handleMoveDir(client, msg)
+	case "useItem":
+		s.handleUseItem(client, msg)
+	case "breakWall":
+		s.handleBreakWall(client, msg)
+	case "resync":
+		s.handleResync(client, msg)
+	case "requestSnapshot":
+		s.handleRequestSnapshot(client, msg)
+	case "findMatch":
+		s.handleFindMatch(client, msg)
+	case "cancelMatch":
+		s.handleCancelMatch(client)
+	case "acceptMatch":
+		s.handleAcceptMatch(client, msg)
+	case "declineMatch":
+		s.handleDeclineMatch(client, msg)
+	case "updateProfile":
+		s.handleUpdateProfile(client, msg)
+	case "chat":
+		s.handleChat(client, msg)
+	case "emote":
+		s.handleEmote(client, msg)
+	case "visibility":
+		s.handleVisibility(client, msg)
+	case "setTeam":
+		s.handleSetTeam(client, msg)
+	case "addBot":
+		s.handleAddBot(client, msg)
+	case "veto":
+		s.handleVeto(client, msg)
+	case "sandbox":
+		s.handleSandbox(client, msg)
+	case "review":
+		s.handleReview(client, msg)
+	case "listReplays":
+		s.handleListReplays(client, msg)
+	case "watchReplay":
+		s.handleWatchReplay(client, msg)
+	case "stopReplay":
+		s.handleStopReplay(client)
+	case "resumeMatch":
+		s.handleResumeMatch(client, msg)
+	case "startVote":
+		s.handleStartVote(client, msg)
+	case "castVote":
+		s.handleCastVote(client, msg)
+	case "kick":
+		s.handleKick(client, msg)
+	case "transferHost":
+		s.handleTransferHost(client, msg)
+	case "lockRoom":
+		s.handleLockRoom(client, msg)
+	case "mazeSettings":
+		s.handleMazeSettings(client, msg)
+	case "loadMaze":
+		s.handleLoadMaze(client, msg)
+	case "uploadMaze":
+		s.handleUploadMaze(client, msg)
+	case "pause", "resume":
+		s.handlePause(client, msg)
+	case "spectate":
+		s.handleSpectate(client, msg)
+	case "stopSpectating":
+		s.stopSpectating(client)
+	case "listRooms":
+		client.SendJSON(messages.ServerMessage{
+			Type:  "roomList",
+			Rooms: s.rooms.ListRooms(),
+		})
+	default:
+		if _, _, ok := room.SplitModeType(msg.Type); ok {
+			s.handleModeMessage(client, msg)
+			return
+		}
+		sendError(client, msg, ErrCodeBadRequest, fmt.Sprintf("unknown message type %q", msg.Type))
+	}
+}
+
+// WantsTicks reports whether the client asked for its room messages in
+// ticks (messages.CapTickBatch)
+func (c *Client) WantsTicks() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.caps[messages.CapTickBatch]
+}
+
+// Supports implements room.Capable. Deltas and fog are features every
+// client had before they could be declared, so only clients that said
+// hello without them go without.
+func (c *Client) Supports(capability string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.hello && (capability == messages.CapDeltaUpdates || capability == messages.CapFog) {
+		return true
+	}
+	return c.caps[capability]
+}
+
+// SendJSON sends a message to the client in its chosen encoding
+func (c *Client) SendJSON(msg messages.ServerMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.version < messages.ProtocolVersion {
+		var ok bool
+		if msg, ok = messages.Downgrade(msg, c.version); !ok {
+			return
+		}
+	}
+	msg.Ack = c.acked
+	c.sentAck = c.acked
+	if c.caps[messages.CapCompactMaze] {
+		msg = compactMazes(msg)
+	}
+	if c.tracer != nil {
+		c.tracer.Outbound(c.ID, c.RoomID, msg)
+	}
+	data, err := c.codec.Encode(msg)
+	if err != nil {
+		slog.Error("Cannot encode message", "client", c.ID, "type", msg.Type, "err", err)
+		return
+	}
+	if c.metrics != nil {
+		c.metrics.Sent(len(data))
+	}
+	c.Conn.WriteMessage(c.codec.FrameType(), data)
+}
+
+// compactMazes packs the cell grids of a message and its batch, copying
+// rather than changing what other recipients share
+func compactMazes(msg messages.ServerMessage) messages.ServerMessage {
+	msg.Maze = msg.Maze.Compacted()
+	if len(msg.Batch) > 0 {
+		batch := make([]messages.ServerMessage, len(msg.Batch))
+		for i, m := range msg.Batch {
+			batch[i] = compactMazes(m)
+		}
+		msg.Batch = batch
+	}
+	return msg
+}
+
+// receive records a client message seq, returning false for duplicates
+func (c *Client) receive(seq uint64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if seq <= c.acked {
+		return false
+	}
+	c.acked = seq
+	return true
+}
+
+// flushAck sends a bare ack if nothing sent since the last message carried
+// one
+func (c *Client) flushAck() {
+	c.mu.Lock()
+	pending := c.sentAck < c.acked
+	c.mu.Unlock()
+	if pending {
+		c.SendJSON(messages.ServerMessage{Type: "ack"})
+	}
+}
+
+// logger returns a logger for what the client does, carrying its ID, its
+// room, if any, and msgType, the type of the message being handled, if any
+func (c *Client) logger(msgType string) *slog.Logger {
+	c.mu.Lock()
+	attrs := []any{"client", c.ID}
+	if c.RoomID != "" {
+		attrs = append(attrs, "room", c.RoomID)
+	}
+	c.mu.Unlock()
+	if msgType != "" {
+		attrs = append(attrs, "type", msgType)
+	}
+	return slog.With(attrs...)
+}
+
+// setRoom records the room the client is in
+func (c *Client) setRoom(roomID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.RoomID = roomID
+}
+
+// currentRoom returns the room the client is in
+func (c *Client) currentRoom() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.RoomID
+}
+
+// startPlayback stops whatever replay the client was watching and records
+// the new one (nil to just stop)
+func (c *Client) startPlayback(p *playback) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.replay != nil {
+		c.replay.halt()
+	}
+	c.replay = p
+}
+
+// currentPlayback returns the replay being streamed to the client, if any
+func (c *Client) currentPlayback() *playback {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.replay
+}
+
+// LeftRoom implements room.Leaver
+func (c *Client) LeftRoom(roomID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.RoomID == roomID {
+		c.RoomID = ""
+	}
+}
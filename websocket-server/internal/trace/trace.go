@@ -0,0 +1,214 @@
+// Package trace logs the protocol frames of selected rooms or clients, for
+// diagnosing client integration problems in production. Tracing a scope
+// switches itself off after a while and is rate limited, so it is safe to
+// leave on by mistake.
+package trace
+
+import (
+	"encoding/json"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+const (
+	// DefaultDuration is how long tracing stays on when no duration is given
+	DefaultDuration = 10 * time.Minute
+	// MaxDuration caps how long a scope can be traced
+	MaxDuration = time.Hour
+	// LinesPerSecond caps how many frames are logged per scope each second
+	LinesPerSecond = 100
+)
+
+// Redacted replaces chat text, passwords and resume secrets in traced frames
+const Redacted = "[redacted]"
+
+// Scope kinds
+const (
+	ScopeRoom   = "room"
+	ScopeClient = "client"
+)
+
+// Scope is a traced room or client
+type Scope struct {
+	Kind    string    `json:"kind"`
+	ID      string    `json:"id"`
+	Expires time.Time `json:"expires"`
+}
+
+// budget is one scope's per-second log allowance
+type budget struct {
+	window  time.Time
+	lines   int
+	dropped int
+}
+
+// Tracer decides which frames to log and logs them
+type Tracer struct {
+	scopes  map[string]*Scope // Keyed by kind + ":" + id
+	budgets map[string]*budget
+	mu      sync.Mutex
+}
+
+// New creates a tracer with nothing traced
+func New() *Tracer {
+	return &Tracer{
+		scopes:  make(map[string]*Scope),
+		budgets: make(map[string]*budget),
+	}
+}
+
+// Enable traces a room or client for d (DefaultDuration if zero, at most
+// MaxDuration)
+func (t *Tracer) Enable(kind, id string, d time.Duration) Scope {
+	if d <= 0 {
+		d = DefaultDuration
+	}
+	if d > MaxDuration {
+		d = MaxDuration
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := &Scope{Kind: kind, ID: id, Expires: time.Now().Add(d)}
+	t.scopes[kind+":"+id] = s
+	return *s
+}
+
+// Disable stops tracing a room or client
+func (t *Tracer) Disable(kind, id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.scopes, kind+":"+id)
+	delete(t.budgets, kind+":"+id)
+}
+
+// Active returns every scope still being traced
+func (t *Tracer) Active() []Scope {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	var active []Scope
+	for key, s := range t.scopes {
+		if now.After(s.Expires) {
+			delete(t.scopes, key)
+			delete(t.budgets, key)
+			continue
+		}
+		active = append(active, *s)
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].Kind+active[i].ID < active[j].Kind+active[j].ID })
+	return active
+}
+
+// Inbound logs a message received from a client, if traced
+func (t *Tracer) Inbound(clientID, roomID string, msg messages.ClientMessage) {
+	key, ok := t.allow(clientID, roomID)
+	if !ok {
+		return
+	}
+
+	if msg.Text != "" {
+		msg.Text = Redacted
+	}
+	if msg.Password != "" {
+		msg.Password = Redacted
+	}
+	t.log(key, "in", clientID, roomID, msg)
+}
+
+// Outbound logs a message sent to a client, if traced
+func (t *Tracer) Outbound(clientID, roomID string, msg messages.ServerMessage) {
+	key, ok := t.allow(clientID, roomID)
+	if !ok {
+		return
+	}
+
+	msg = redactServer(msg)
+	t.log(key, "out", clientID, roomID, msg)
+}
+
+// redactServer strips chat text and resume secrets, including inside
+// batches
+func redactServer(msg messages.ServerMessage) messages.ServerMessage {
+	if msg.Secret != "" {
+		msg.Secret = Redacted
+	}
+	if len(msg.Chat) > 0 {
+		chat := make([]messages.ChatMessage, len(msg.Chat))
+		for i, line := range msg.Chat {
+			line.Text = Redacted
+			chat[i] = line
+		}
+		msg.Chat = chat
+	}
+	if len(msg.Batch) > 0 {
+		batch := make([]messages.ServerMessage, len(msg.Batch))
+		for i, inner := range msg.Batch {
+			batch[i] = redactServer(inner)
+		}
+		msg.Batch = batch
+	}
+	return msg
+}
+
+// allow reports whether a frame is in a live traced scope and within that
+// scope's per-second budget
+func (t *Tracer) allow(clientID, roomID string) (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.scopes) == 0 {
+		return "", false
+	}
+
+	now := time.Now()
+	key := ""
+	for _, k := range []string{ScopeClient + ":" + clientID, ScopeRoom + ":" + roomID} {
+		s, ok := t.scopes[k]
+		if !ok {
+			continue
+		}
+		if now.After(s.Expires) {
+			delete(t.scopes, k)
+			delete(t.budgets, k)
+			slog.Info("Trace expired", "kind", s.Kind, "id", s.ID)
+			continue
+		}
+		key = k
+		break
+	}
+	if key == "" {
+		return "", false
+	}
+
+	b, ok := t.budgets[key]
+	if !ok {
+		b = &budget{}
+		t.budgets[key] = b
+	}
+	if now.Sub(b.window) >= time.Second {
+		if b.dropped > 0 {
+			slog.Warn("Trace frames dropped over the rate limit", "scope", key, "dropped", b.dropped)
+		}
+		b.window, b.lines, b.dropped = now, 0, 0
+	}
+	if b.lines >= LinesPerSecond {
+		b.dropped++
+		return "", false
+	}
+	b.lines++
+	return key, true
+}
+
+func (t *Tracer) log(scope, direction, clientID, roomID string, msg interface{}) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	slog.Info("Trace", "scope", scope, "direction", direction, "client", clientID, "room", roomID, "frame", string(data))
+}
@@ -0,0 +1,482 @@
+// Package sdk is a Go client for the maze server. It keeps a session alive
+// across dropped connections: it reconnects with jittered exponential
+// backoff, resumes the player's persistent ID, rejoins their room and
+// replays inputs the server never acknowledged. It also watches the room
+// sequence numbers and asks for a resync when a state update goes missing.
+package sdk
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"labyrinth-duel/websocket/internal/messages"
+)
+
+// Protocol messages, re-exported for SDK users
+type (
+	ClientMessage = messages.ClientMessage
+	ServerMessage = messages.ServerMessage
+)
+
+const (
+	// DefaultMinBackoff is the first reconnect delay
+	DefaultMinBackoff = 250 * time.Millisecond
+	// DefaultMaxBackoff caps the reconnect delay
+	DefaultMaxBackoff = 30 * time.Second
+	// MaxPending caps how many unacknowledged inputs are kept for replay;
+	// the oldest are dropped first
+	MaxPending = 64
+)
+
+// Errors returned by the client
+var (
+	ErrClosed    = errors.New("sdk: client closed")
+	ErrHandshake = errors.New("sdk: server did not send connected")
+)
+
+// State is the connection state reported to OnState
+type State int
+
+const (
+	Connecting   State = iota // Dialing for the first time
+	Connected                 // Connected and, if in a room, rejoined
+	Reconnecting              // Connection lost; waiting to retry
+	Closed                    // Closed by the user, the server, or after MaxAttempts
+)
+
+func (s State) String() string {
+	switch s {
+	case Connecting:
+		return "connecting"
+	case Connected:
+		return "connected"
+	case Reconnecting:
+		return "reconnecting"
+	case Closed:
+		return "closed"
+	}
+	return "unknown"
+}
+
+// Options configures a client
+type Options struct {
+	URL      string // Server WebSocket endpoint, e.g. ws://localhost:8080/ws
+	PlayerID string // Public player ID to resume; empty gets a new one
+	Secret   string // Resume secret issued with PlayerID, needed to resume it
+
+	MinBackoff  time.Duration // Default DefaultMinBackoff
+	MaxBackoff  time.Duration // Default DefaultMaxBackoff
+	MaxAttempts int           // Reconnect attempts before giving up (0 = forever)
+
+	// OnMessage receives every server message, from the client's read
+	// goroutine
+	OnMessage func(ServerMessage)
+	// OnState is told about every connection state change; err is why the
+	// connection was lost, if it was
+	OnState func(state State, err error)
+
+	Dialer   *websocket.Dialer // Default websocket.DefaultDialer
+	Encoding string            // Wire format: "" or json, msgpack
+	// Caps are capability flags to ask for, e.g. messages.CapCompactMaze.
+	// Compact mazes are expanded again before OnMessage sees them.
+	Caps []string
+}
+
+// Client is a self-healing connection to the server
+type Client struct {
+	opts Options
+
+	conn      *websocket.Conn
+	codec     messages.Codec
+	state     State
+	playerID  string
+	secret    string          // Resume secret for playerID, replaced on every connect
+	join      *ClientMessage  // Last successful join, repeated on resume
+	pending   []ClientMessage // Inputs the server hasn't acked, oldest first
+	seq       uint64          // Last input seq assigned
+	lastSeq   uint64          // Room seq of the last message received
+	resyncing bool            // A resync is in flight
+	mu        sync.Mutex
+
+	done chan struct{} // Closed when the client shuts down for good
+}
+
+// Dial connects to the server and keeps the connection alive until Close.
+// Only the first connection attempt's error is returned; later failures
+// are retried and reported through OnState.
+func Dial(opts Options) (*Client, error) {
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = DefaultMinBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = DefaultMaxBackoff
+	}
+	if opts.Dialer == nil {
+		opts.Dialer = websocket.DefaultDialer
+	}
+	codec, ok := messages.CodecByName(opts.Encoding)
+	if !ok {
+		return nil, fmt.Errorf("sdk: unknown encoding %q", opts.Encoding)
+	}
+
+	c := &Client{
+		opts:     opts,
+		codec:    codec,
+		playerID: opts.PlayerID,
+		secret:   opts.Secret,
+		done:     make(chan struct{}),
+	}
+	c.notify(Connecting, nil)
+	if err := c.connect(); err != nil {
+		c.shutdown(err)
+		return nil, err
+	}
+	go c.run()
+	return c, nil
+}
+
+// PlayerID returns the player's public ID
+func (c *Client) PlayerID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.playerID
+}
+
+// Session returns the player ID and the resume secret last issued with it;
+// store both to resume later. The secret changes on every connect.
+func (c *Client) Session() (playerID, secret string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.playerID, c.secret
+}
+
+// State returns the current connection state
+func (c *Client) State() State {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
+
+// Send sends a message. Inputs are numbered and kept until the server acks
+// them; any still unacked after a reconnect are replayed once the client has
+// rejoined its room. A join is remembered and repeated on every reconnect.
+// The server may see an input twice if it processed it but the ack was lost
+// with the connection.
+func (c *Client) Send(msg ClientMessage) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state == Closed {
+		return ErrClosed
+	}
+	if msg.Type == "join" {
+		join := msg
+		c.join = &join
+	} else {
+		c.seq++
+		msg.Seq = c.seq
+		c.queueLocked(msg)
+	}
+	if c.conn == nil || c.state != Connected {
+		return nil
+	}
+	if err := c.write(c.conn, msg); err != nil {
+		c.conn.Close() // The read loop notices and reconnects
+	}
+	return nil
+}
+
+// Close shuts the connection down for good
+func (c *Client) Close() error {
+	c.shutdown(nil)
+	return nil
+}
+
+// shutdown closes the client, reporting err as the reason
+func (c *Client) shutdown(err error) {
+	c.mu.Lock()
+	if c.state == Closed {
+		c.mu.Unlock()
+		return
+	}
+	c.state = Closed
+	close(c.done)
+	conn := c.conn
+	c.conn = nil
+	c.mu.Unlock()
+
+	if conn != nil {
+		conn.Close()
+	}
+	c.notify(Closed, err)
+}
+
+// queueLocked keeps an input until it is acked
+func (c *Client) queueLocked(msg ClientMessage) {
+	if len(c.pending) >= MaxPending {
+		c.pending = c.pending[1:]
+	}
+	c.pending = append(c.pending, msg)
+}
+
+// run reads from the current connection and reconnects when it drops
+func (c *Client) run() {
+	attempt := 0
+	for {
+		err := c.readLoop()
+		for {
+			if c.isClosed() {
+				return
+			}
+			attempt++
+			if c.opts.MaxAttempts > 0 && attempt > c.opts.MaxAttempts {
+				c.shutdown(err)
+				return
+			}
+			c.setState(Reconnecting, err)
+
+			select {
+			case <-time.After(c.backoff(attempt)):
+			case <-c.done:
+				return
+			}
+			if err = c.connect(); err == nil {
+				attempt = 0
+				break
+			}
+		}
+	}
+}
+
+// connect dials the server, resumes the session and replays what's pending
+func (c *Client) connect() error {
+	u, err := url.Parse(c.opts.URL)
+	if err != nil {
+		return err
+	}
+	q := u.Query()
+	if id, secret := c.Session(); id != "" {
+		q.Set("playerId", id)
+		q.Set("secret", secret)
+	}
+	if c.opts.Encoding != "" {
+		q.Set("encoding", c.opts.Encoding)
+	}
+	if len(c.opts.Caps) > 0 {
+		q.Set("caps", strings.Join(c.opts.Caps, ","))
+	}
+	u.RawQuery = q.Encode()
+
+	conn, _, err := c.opts.Dialer.Dial(u.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	var hello ServerMessage
+	if err := c.read(conn, &hello); err != nil || hello.Type != "connected" {
+		conn.Close()
+		return ErrHandshake
+	}
+	if c.opts.OnMessage != nil {
+		c.opts.OnMessage(hello)
+	}
+
+	c.mu.Lock()
+	if c.state == Closed {
+		c.mu.Unlock()
+		conn.Close()
+		return ErrClosed
+	}
+	// The server issues a new ID if the old one is still marked online or
+	// the secret didn't match
+	c.playerID = hello.Message
+	c.secret = hello.Secret
+	c.conn = conn
+
+	if c.join != nil {
+		if err := c.write(conn, c.join); err != nil {
+			c.mu.Unlock()
+			conn.Close()
+			return err
+		}
+	}
+	for _, msg := range c.pending {
+		if err := c.write(conn, msg); err != nil {
+			c.mu.Unlock()
+			conn.Close()
+			return err
+		}
+	}
+	// The new connection starts a fresh room sequence
+	c.lastSeq, c.resyncing = 0, false
+	// Switch state under the lock so Send can't queue behind the replay
+	c.state = Connected
+	c.mu.Unlock()
+
+	c.notify(Connected, nil)
+	return nil
+}
+
+// readLoop delivers messages until the connection drops
+func (c *Client) readLoop() error {
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+	if conn == nil {
+		return ErrClosed
+	}
+
+	for {
+		var msg ServerMessage
+		if err := c.read(conn, &msg); err != nil {
+			c.mu.Lock()
+			if c.conn == conn {
+				c.conn = nil
+			}
+			c.mu.Unlock()
+			conn.Close()
+			return err
+		}
+
+		c.mu.Lock()
+		c.ackLocked(msg.Ack)
+		if c.checkSeqLocked(msg) {
+			c.resyncing = true
+			c.write(conn, ClientMessage{Type: "resync"})
+		}
+		c.mu.Unlock()
+
+		switch msg.Type {
+		case "mazeData":
+			// Private rooms are rejoined by code
+			c.mu.Lock()
+			if c.join != nil && msg.Code != "" {
+				c.join.Code = msg.Code
+			}
+			c.mu.Unlock()
+		case "joinRejected":
+			// Don't keep retrying a join the server refuses
+			c.mu.Lock()
+			c.join = nil
+			c.mu.Unlock()
+		}
+
+		if c.opts.OnMessage != nil {
+			c.opts.OnMessage(msg)
+		}
+
+		if msg.Type == "kicked" {
+			c.shutdown(errors.New("sdk: kicked: " + msg.Reason))
+			return ErrClosed
+		}
+	}
+}
+
+// ackLocked forgets inputs the server has processed
+func (c *Client) ackLocked(ack uint64) {
+	i := 0
+	for i < len(c.pending) && c.pending[i].Seq <= ack {
+		i++
+	}
+	c.pending = c.pending[i:]
+}
+
+// checkSeqLocked follows the room sequence and reports whether a message
+// was lost and a resync is needed. Messages that aren't from a room carry
+// no seq.
+func (c *Client) checkSeqLocked(msg ServerMessage) bool {
+	if msg.Seq == 0 {
+		return false
+	}
+	defer func() { c.lastSeq = msg.Seq }()
+
+	switch msg.Type {
+	case "resync", "fullSnapshot", "fastForward":
+		// Whole state: whatever went missing before is covered
+		c.resyncing = false
+		return false
+	}
+	return msg.PrevSeq != c.lastSeq && !c.resyncing
+}
+
+// backoff is a full-jitter exponential delay: uniform in
+// [0, min(MaxBackoff, MinBackoff * 2^(attempt-1))]
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.opts.MinBackoff
+	for i := 1; i < attempt && d < c.opts.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > c.opts.MaxBackoff {
+		d = c.opts.MaxBackoff
+	}
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+// write sends one message in the client's encoding
+func (c *Client) write(conn *websocket.Conn, msg interface{}) error {
+	data, err := c.codec.Encode(msg)
+	if err != nil {
+		return err
+	}
+	return conn.WriteMessage(c.codec.FrameType(), data)
+}
+
+// read waits for one message in the client's encoding
+func (c *Client) read(conn *websocket.Conn, msg *ServerMessage) error {
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		return err
+	}
+	if err := c.codec.Decode(data, msg); err != nil {
+		return err
+	}
+	return expandMazes(msg)
+}
+
+// expandMazes turns compact mazes in a message and its batch back into cell
+// grids
+func expandMazes(msg *ServerMessage) error {
+	if msg.Maze != nil {
+		if err := msg.Maze.Expand(); err != nil {
+			return err
+		}
+	}
+	for i := range msg.Batch {
+		if err := expandMazes(&msg.Batch[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client) isClosed() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *Client) setState(state State, err error) {
+	c.mu.Lock()
+	if c.state == Closed {
+		c.mu.Unlock()
+		return
+	}
+	c.state = state
+	c.mu.Unlock()
+	c.notify(state, err)
+}
+
+func (c *Client) notify(state State, err error) {
+	if c.opts.OnState != nil {
+		c.opts.OnState(state, err)
+	}
+}
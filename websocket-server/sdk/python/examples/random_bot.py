@@ -0,0 +1,65 @@
+"""A bot that joins a room, readies up and wanders at random.
+
+    python examples/random_bot.py ws://localhost:8080/ws lobby
+"""
+
+import asyncio
+import random
+import sys
+
+from maze_sdk import Client
+
+MOVE_INTERVAL = 0.12  # Just over the server's move cooldown
+
+
+class Bot:
+    def __init__(self, client: Client):
+        self.client = client
+        self.cells = {}
+        self.x, self.y = 0, 0
+        self.playing = False
+
+    async def walk(self) -> None:
+        """Take a random open step every MOVE_INTERVAL while playing."""
+        while True:
+            await asyncio.sleep(MOVE_INTERVAL)
+            if not self.playing:
+                continue
+            cell = self.cells[(self.x, self.y)]
+            options = [(nx, ny) for wall, nx, ny in (
+                (cell.top, self.x, self.y - 1), (cell.right, self.x + 1, self.y),
+                (cell.bottom, self.x, self.y + 1), (cell.left, self.x - 1, self.y),
+            ) if not wall]
+            if options:
+                await self.client.move(*random.choice(options))
+
+    async def listen(self) -> None:
+        """Track the match until it ends."""
+        async for msg in self.client:
+            if msg.state:
+                self.playing = msg.state == "playing"
+            if msg.type == "playerMoved" and msg.message == self.client.player_id:
+                self.x, self.y = msg.position.x, msg.position.y
+            if msg.type == "gameOver":
+                print("winner:", msg.winner)
+                return
+
+
+async def main(url: str, room: str) -> None:
+    async with Client(url) as client:
+        await client.join(room)
+        data = await client.wait_for("mazeData")
+
+        bot = Bot(client)
+        bot.cells = {(c.x, c.y): c for row in data.maze.cells for c in row}
+        await client.ready()
+
+        walker = asyncio.create_task(bot.walk())
+        try:
+            await bot.listen()
+        finally:
+            walker.cancel()
+
+
+if __name__ == "__main__":
+    asyncio.run(main(*sys.argv[1:3]))
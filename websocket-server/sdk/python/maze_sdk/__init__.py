@@ -0,0 +1,17 @@
+"""Python client for the maze server.
+
+    import asyncio
+    from maze_sdk import Client
+
+    async def main():
+        async with Client("ws://localhost:8080/ws") as client:
+            await client.join("lobby")
+            await client.ready()
+            async for msg in client:
+                print(msg.type)
+
+    asyncio.run(main())
+"""
+
+from .client import PROTOCOL_VERSION, Client, ProtocolError
+from .messages import *  # noqa: F401,F403
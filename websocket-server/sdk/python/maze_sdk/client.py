@@ -0,0 +1,144 @@
+"""Asyncio WebSocket client for the maze server."""
+
+from __future__ import annotations
+
+import json
+from typing import Any, AsyncIterator, Optional
+from urllib.parse import urlencode
+
+import websockets
+
+from .messages import ClientMessage, ServerMessage
+
+# Protocol version the SDK speaks (messages.ProtocolVersion on the server),
+# declared when connecting so newer servers translate their messages down to it
+PROTOCOL_VERSION = 2
+
+# Messages that carry the whole room state, so any gap before them is healed
+_RECOVERY = {"resync", "fullSnapshot", "fastForward"}
+
+
+class ProtocolError(Exception):
+    """The server answered a request with an error message."""
+
+    def __init__(self, msg: ServerMessage):
+        super().__init__(f"{msg.error}: {msg.message}")
+        self.code = msg.error
+        self.message = msg
+
+
+class Client:
+    """One connection to the server.
+
+    Inputs are numbered so the server can ack them and drop duplicates.
+    Incoming room messages are checked against their sequence numbers; if one
+    goes missing the client asks for a resync on its own.
+    """
+
+    def __init__(self, url: str = "ws://localhost:8080/ws", player_id: Optional[str] = None,
+                 secret: Optional[str] = None):
+        self.url = url
+        self.player_id = player_id  # Public ID; store it with secret to resume later
+        self.secret = secret  # Resume secret for player_id, replaced on every connect
+        self.acked = 0  # Highest input seq the server has processed
+        self._ws = None
+        self._seq = 0
+        self._last_seq = 0
+        self._resyncing = False
+
+    async def connect(self) -> ServerMessage:
+        """Connect and wait for the server's hello, which carries our ID."""
+        query = {"protocol": PROTOCOL_VERSION}
+        if self.player_id:
+            query["playerId"] = self.player_id
+            query["secret"] = self.secret or ""
+        url = self.url + ("&" if "?" in self.url else "?") + urlencode(query)
+        self._ws = await websockets.connect(url)
+
+        hello = await self.recv()
+        if hello.type != "connected":
+            raise ProtocolError(hello)
+        self.player_id = hello.message
+        self.secret = hello.secret
+        return hello
+
+    async def close(self) -> None:
+        if self._ws is not None:
+            await self._ws.close()
+            self._ws = None
+
+    async def __aenter__(self) -> "Client":
+        await self.connect()
+        return self
+
+    async def __aexit__(self, *exc: Any) -> None:
+        await self.close()
+
+    async def send(self, msg: ClientMessage) -> int:
+        """Send a message, numbering it unless it is a join. Returns its seq."""
+        if msg.type != "join" and not msg.seq:
+            self._seq += 1
+            msg.seq = self._seq
+        await self._ws.send(json.dumps(msg.to_dict()))
+        return msg.seq
+
+    async def recv(self) -> ServerMessage:
+        """Wait for the next server message."""
+        msg = ServerMessage.from_dict(json.loads(await self._ws.recv()))
+        if msg.ack > self.acked:
+            self.acked = msg.ack
+        if self._gap(msg):
+            self._resyncing = True
+            await self.send(ClientMessage(type="resync"))
+        return msg
+
+    async def __aiter__(self) -> AsyncIterator[ServerMessage]:
+        while True:
+            try:
+                yield await self.recv()
+            except websockets.ConnectionClosed:
+                return
+
+    async def wait_for(self, msg_type: str) -> ServerMessage:
+        """Skip messages until one of the given type arrives.
+
+        Raises ProtocolError if the server sends an error first.
+        """
+        while True:
+            msg = await self.recv()
+            if msg.type == msg_type:
+                return msg
+            if msg.type == "error":
+                raise ProtocolError(msg)
+
+    def _gap(self, msg: ServerMessage) -> bool:
+        """Follow the room sequence; True if a message was lost."""
+        if not msg.seq:
+            return False
+        last, self._last_seq = self._last_seq, msg.seq
+        if msg.type in _RECOVERY:
+            self._resyncing = False
+            return False
+        return msg.prev_seq != last and not self._resyncing
+
+    # Shortcuts for common requests
+
+    async def join(self, room_id: str = "", **options: Any) -> None:
+        """Join (or create) a room. Options are ClientMessage fields such as
+        code, password, seed or fog."""
+        await self.send(ClientMessage(type="join", room_id=room_id, **options))
+
+    async def ready(self) -> int:
+        return await self.send(ClientMessage(type="ready"))
+
+    async def move(self, x: int, y: int, z: int = 0) -> int:
+        """Step to an adjacent cell; z is the floor in mazes with floors."""
+        return await self.send(ClientMessage(type="move", x=x, y=y, z=z))
+
+    async def move_dir(self, direction: str) -> int:
+        """Step up, right, down or left of where the server has us, or
+        upstairs or downstairs on stairs."""
+        return await self.send(ClientMessage(type="moveDir", direction=direction))
+
+    async def chat(self, text: str) -> int:
+        return await self.send(ClientMessage(type="chat", text=text))
@@ -0,0 +1,1132 @@
+# Code generated by cmd/pysdkgen from internal/messages/messages.go.
+# DO NOT EDIT; regenerate with: go run ./cmd/pysdkgen -o sdk/python/maze_sdk/messages.py
+"""Protocol messages exchanged with the maze server."""
+
+from __future__ import annotations
+
+from dataclasses import dataclass, field
+from typing import Any, ClassVar, Dict, List, Optional
+
+
+class _Message:
+    # (attribute, JSON key, decode spec, omitempty) per field. A decode spec
+    # is None for plain values, a class name for nested messages, or a
+    # one-element list wrapping the spec of list items.
+    _SCHEMA: ClassVar[tuple] = ()
+
+    def to_dict(self) -> Dict[str, Any]:
+        out = {}
+        for attr, key, _, omitempty in self._SCHEMA:
+            value = getattr(self, attr)
+            if omitempty and not value:
+                continue
+            out[key] = _encode(value)
+        return out
+
+    @classmethod
+    def from_dict(cls, data: Dict[str, Any]):
+        kwargs = {}
+        for attr, key, spec, _ in cls._SCHEMA:
+            if key in data and data[key] is not None:
+                kwargs[attr] = _decode(spec, data[key])
+        return cls(**kwargs)
+
+
+def _encode(value: Any) -> Any:
+    if isinstance(value, _Message):
+        return value.to_dict()
+    if isinstance(value, list):
+        return [_encode(v) for v in value]
+    return value
+
+
+def _decode(spec: Any, value: Any) -> Any:
+    if spec is None or value is None:
+        return value
+    if isinstance(spec, list):
+        return [_decode(spec[0], v) for v in value]
+    return _CLASSES[spec].from_dict(value)
+
+
+@dataclass
+class ClientMessage(_Message):
+    "ClientMessage is what we receive from the browser"
+
+    type: str = ""
+    request_id: str = ""  # Echoed in any error the message causes
+    seq: int = 0  # Optional increasing message ID; acked via ack, duplicates are dropped
+    room_id: str = ""
+    x: int = 0
+    y: int = 0
+    z: int = 0  # Floor, in mazes of more than one
+
+    # hello: what the client speaks, replacing what the connection URL asked for
+    protocol: int = 0  # Protocol version the client was written against
+    encodings: List[str] = field(default_factory=list)  # Wire formats it can use, preferred first: json, msgpack
+    caps: List[str] = field(default_factory=list)  # Features it supports: compactMaze, tickBatch, deltaUpdates, fog, floors
+
+    # join
+    code: str = ""  # Join code of a private room (instead of roomId); resume code (resumeMatch)
+    password: str = ""  # Room password, if it has one
+
+    # updateProfile (empty fields are left unchanged)
+    name: str = ""
+    color: str = ""  # #rrggbb
+    avatar: str = ""
+
+    # chat / emote
+    text: str = ""
+    channel: str = ""  # chat: "" for the whole room, team for your team only
+    emote: str = ""  # One of the predefined emote IDs
+
+    # setTeam, kick, transferHost, lockRoom (host only)
+    player_id: str = ""  # Player to move, kick or hand the room to
+    team: int = 0  # Team to pin them to (0 = back to auto-balancing)
+    ban: bool = False  # kick: keep them out for good
+    locked: bool = False  # lockRoom: true to turn new players away, false to let them in again
+
+    # loadMaze (admin only; roomId picks the room, default yours), uploadMaze (name names the level)
+    maze_file: str = ""  # The maze in a file format: text (ASCII art) or json
+    format: str = ""  # text or json (default: json if mazeFile starts with {)
+    layout: Optional[MazeData] = None  # uploadMaze: the maze as cells, goal(s) and spawns, instead of mazeFile
+
+    # startVote, castVote
+    vote_kind: str = ""  # Kind of vote to start: kick (needs playerId), newMaze, mazeSize (needs size), extendTime, restart, pause, resume or suspend
+    size: int = 0  # startVote mazeSize: new maze width and height, 5-40
+    yes: bool = False  # castVote: for (true) or against
+
+    # watchReplay
+    replay_id: str = ""  # Replay to stream; empty with a speed changes the running one
+    speed: float = 0.0  # Playback rate (default 1, MinReplaySpeed-MaxReplaySpeed); sandbox setSpeed: the speed stat
+
+    # dailyLeaderboard
+    day: str = ""  # UTC day as YYYY-MM-DD (default today)
+
+    # leaderboard (mode picks the game mode's board: all, classic or a
+    # registered mode), matchHistory (playerId picks whose, default yours)
+    board: str = ""  # wins (default), fastest or rating
+    offset: int = 0  # Entries to skip
+    limit: int = 0  # Entries to return (leaderboard: default 10, at most 100; matchHistory: default 10, at most 50)
+
+    # addBot; join and mazeSettings (room options)
+    difficulty: str = ""  # easy, medium or hard. addBot: how well the bot plays (default medium); rooms: the tier every maze must rate in ("" = any)
+
+    # Sandbox command (practice rooms), review command (finished ranked matches)
+    command: str = ""  # teleport (x, y), reveal, spawnItem (item, x, y) or setSpeed (speed); review: start, next, prev, stop
+
+    # visibility
+    hidden: bool = False  # Page was backgrounded (false = foregrounded again)
+
+    # requestSnapshot
+    since_version: int = 0  # Last snapshot version seen; enables a fastForward instead of a full snapshot
+
+    # useItem / breakWall / moveDir
+    item: str = ""  # Power-up kind to use
+    direction: str = ""  # up, right, down, left (moveDir, breakWall, wallBreak item); upstairs, downstairs (moveDir on stairs)
+
+    # Room creation options (only used by the first join)
+    level_id: str = ""  # Play every round on this uploaded level instead of generated mazes
+    seed: int = 0  # Reproduce a specific maze
+    maze_algorithm: str = ""  # backtracker, prim, kruskal, wilson, eller, weave
+    theme: str = ""  # hedge, ice, lava (default: seasonal)
+    loop_factor: float = 0.0  # 0-1, share of dead ends opened into loops
+    terrain_density: float = 0.0  # 0-1, share of cells with the theme's terrain: mud, road, ice or portals
+    crossing_density: float = 0.0  # 0-1, share of straight corridors bridged over a tunnel
+    floors: int = 0  # Floors stacked and joined by stairs, 1-4 (default 1)
+    dead_ends: str = ""  # "", prune, stuff
+    dead_end_count: int = 0  # How many of the longest dead ends to prune/stuff
+    min_path_ratio: float = 0.0  # 0-1, minimum spawn-to-goal distance vs. the longest possible path
+    goal_count: int = 0  # Number of exits
+    goal_mode: str = ""  # "" (first exit wins) or points (bank exits until time-up)
+    goal_move_seconds: int = 0  # Move the exits far from every player this often (0 = fixed, at least 10)
+    shift_seconds: int = 0  # Change part of the maze this often, keeping it solvable (0 = static, at least 10)
+    minotaur: str = ""  # Let minotaurs prowl the maze: easy, medium or hard ("" = none)
+    anti_camp: str = ""  # Camping penalty: "" (off), reveal, decay, both
+    camp_seconds: int = 0  # How long a player may stay put before they are camping (default 20)
+    camp_radius: int = 0  # Cells a player may wander each way and still be camping (default 1)
+    move_interval_ms: int = 0  # Milliseconds between moves at speed 1 (default 100, at least 20)
+    wall_charges: int = 0  # Walls each player may break per match
+    collision: str = ""  # "" (pass through), block, bump
+    fog: bool = False  # Reveal the maze only as players explore
+    fog_radius: int = 0  # Visibility radius under fog
+    private: bool = False  # Hide the room from listings; others join with the returned code
+    max_players: int = 0  # Player limit (0 = unlimited)
+    rounds: int = 0  # Best-of-N rounds, each on a new maze
+    mode: str = ""  # Game mode; its messages are typed "<mode>.<action>"
+    teams: int = 0  # Number of teams (0 = free-for-all)
+    team_goal: str = ""  # "" (first player out wins for their team), all (whole team out), score (combined score)
+    coop: bool = False  # Everyone on one team, racing the clock to get every player out
+    hints: bool = False  # Spawn hint power-ups
+    practice: bool = False  # Solo practice room that accepts sandbox commands
+    map_veto: bool = False  # Players strike candidate mazes before the match
+
+    _SCHEMA: ClassVar[tuple] = (
+        ("type", "type", None, False),
+        ("request_id", "requestId", None, True),
+        ("seq", "seq", None, True),
+        ("room_id", "roomId", None, True),
+        ("x", "x", None, True),
+        ("y", "y", None, True),
+        ("z", "z", None, True),
+        ("protocol", "protocol", None, True),
+        ("encodings", "encodings", [None], True),
+        ("caps", "caps", [None], True),
+        ("code", "code", None, True),
+        ("password", "password", None, True),
+        ("name", "name", None, True),
+        ("color", "color", None, True),
+        ("avatar", "avatar", None, True),
+        ("text", "text", None, True),
+        ("channel", "channel", None, True),
+        ("emote", "emote", None, True),
+        ("player_id", "playerId", None, True),
+        ("team", "team", None, True),
+        ("ban", "ban", None, True),
+        ("locked", "locked", None, True),
+        ("maze_file", "mazeFile", None, True),
+        ("format", "format", None, True),
+        ("layout", "layout", "MazeData", True),
+        ("vote_kind", "voteKind", None, True),
+        ("size", "size", None, True),
+        ("yes", "yes", None, True),
+        ("replay_id", "replayId", None, True),
+        ("speed", "speed", None, True),
+        ("day", "day", None, True),
+        ("board", "board", None, True),
+        ("offset", "offset", None, True),
+        ("limit", "limit", None, True),
+        ("difficulty", "difficulty", None, True),
+        ("command", "command", None, True),
+        ("hidden", "hidden", None, True),
+        ("since_version", "sinceVersion", None, True),
+        ("item", "item", None, True),
+        ("direction", "direction", None, True),
+        ("level_id", "levelId", None, True),
+        ("seed", "seed", None, True),
+        ("maze_algorithm", "mazeAlgorithm", None, True),
+        ("theme", "theme", None, True),
+        ("loop_factor", "loopFactor", None, True),
+        ("terrain_density", "terrainDensity", None, True),
+        ("crossing_density", "crossingDensity", None, True),
+        ("floors", "floors", None, True),
+        ("dead_ends", "deadEnds", None, True),
+        ("dead_end_count", "deadEndCount", None, True),
+        ("min_path_ratio", "minPathRatio", None, True),
+        ("goal_count", "goalCount", None, True),
+        ("goal_mode", "goalMode", None, True),
+        ("goal_move_seconds", "goalMoveSeconds", None, True),
+        ("shift_seconds", "shiftSeconds", None, True),
+        ("minotaur", "minotaur", None, True),
+        ("anti_camp", "antiCamp", None, True),
+        ("camp_seconds", "campSeconds", None, True),
+        ("camp_radius", "campRadius", None, True),
+        ("move_interval_ms", "moveIntervalMs", None, True),
+        ("wall_charges", "wallCharges", None, True),
+        ("collision", "collision", None, True),
+        ("fog", "fog", None, True),
+        ("fog_radius", "fogRadius", None, True),
+        ("private", "private", None, True),
+        ("max_players", "maxPlayers", None, True),
+        ("rounds", "rounds", None, True),
+        ("mode", "mode", None, True),
+        ("teams", "teams", None, True),
+        ("team_goal", "teamGoal", None, True),
+        ("coop", "coop", None, True),
+        ("hints", "hints", None, True),
+        ("practice", "practice", None, True),
+        ("map_veto", "mapVeto", None, True),
+    )
+
+
+@dataclass
+class ServerMessage(_Message):
+    "ServerMessage is what we send to the browser"
+
+    type: str = ""
+    players: List[Player] = field(default_factory=list)
+    message: str = ""
+    maze: Optional[MazeData] = None
+    direction: str = ""  # For noise hints: up, right, down, left
+    state: str = ""  # Room lifecycle: waiting, countdown, playing, paused, finished
+    seconds: int = 0  # Countdown seconds remaining
+    starts_at: int = 0  # gameStarting, matchResuming: server time (Unix ms) moves are accepted from, to count down to
+    winner: str = ""
+    winning_team: int = 0  # Team of the winner in team rooms, all of whom won (gameOver)
+    reason: str = ""  # Why the game ended, e.g. "goal"
+    summary: Optional[GameSummary] = None
+    items: List[Item] = field(default_factory=list)
+    cells: List[Cell] = field(default_factory=list)  # Newly visible cells (mazeReveal, or mazeData under fog)
+    position: Optional[Position] = None  # Where an event happened (collision), a player moved to (playerMoved) or the primary exit moved to (goalMoved)
+    goals: List[Goal] = field(default_factory=list)  # Every exit with its new value (goalMoved)
+    breadcrumbs: List[Breadcrumb] = field(default_factory=list)  # Runners' recent cells, for hunters only (breadcrumbs)
+    flags: List[Flag] = field(default_factory=list)  # Every team's flag, in capture the flag
+    hazards: List[Hazard] = field(default_factory=list)  # Server-controlled hazards roaming the maze (hazardsMoved, snapshot, mazeData)
+    path: List[Position] = field(default_factory=list)  # Next steps toward the nearest exit (hint)
+    vote: Optional[VoteStatus] = None  # voteStarted, voteUpdated, voteEnded
+    veto: Optional[VetoStatus] = None  # vetoStarted, vetoUpdated, vetoDecided
+    review: Optional[ReviewStatus] = None  # reviewStarted, reviewStep
+    replay: Optional[ReplayInfo] = None  # replayStart
+    event: Optional[ReplayEvent] = None  # replayEvent
+    replays: List[ReplayInfo] = field(default_factory=list)  # replayList
+    level: Optional[LevelInfo] = None  # mazeUploaded
+    batch: List[ServerMessage] = field(default_factory=list)  # Messages from one atomic room transaction, or one tick, in order
+    round: int = 0  # Round just finished (roundOver) or about to start (newRound)
+    hash: str = ""  # Fingerprint of the room state (snapshot, resync) for desync detection
+    tick: int = 0  # Room tick the snapshot was taken at or the tick message is for
+    time: int = 0  # Server clock in Unix milliseconds when the tick was sent, for interpolation
+    version: int = 0  # Room version a snapshot reflects; pass back as sinceVersion
+    rooms: List[RoomInfo] = field(default_factory=list)  # Open rooms (roomList)
+    code: str = ""  # Join code of a private room (mazeData, matchFound); resume code (matchSuspended, resumePending, resumeRequested)
+    room_id: str = ""  # Room to join (matchFound)
+    profile: Optional[Profile] = None  # The player's own profile (connected, profile)
+    secret: str = ""  # Secret to pass back with the player ID to resume it (connected); sent to no one else
+    rating: int = 0  # Opponent's rating (matchProposed, matchFound)
+    host: str = ""  # Player hosting the room (mazeData, spectating, hostChanged)
+    daily: Optional[DailyBoard] = None  # dailyLeaderboard
+    leaderboard: Optional[Leaderboard] = None  # leaderboard
+    history: Optional[MatchHistory] = None  # matchHistory
+    chat: List[ChatMessage] = field(default_factory=list)  # chat (one line) or chatHistory
+    emote: str = ""  # Emote ID; Message holds the sender
+    error: str = ""  # Machine-readable code (error, *Rejected), e.g. INVALID_MOVE
+    request_id: str = ""  # requestId of the message that failed
+    fields: List[FieldError] = field(default_factory=list)  # What exactly was wrong with a rejected message (strict validation)
+    caps: List[str] = field(default_factory=list)  # Capabilities enabled for this connection (connected, welcome)
+    protocol: int = 0  # Protocol version the connection speaks from now on (welcome)
+    encoding: str = ""  # Wire format used from the next message on (welcome)
+
+    # Envelope
+    seq: int = 0  # Room sequence number (room messages only)
+    prev_seq: int = 0  # seq of the previous room message to this client; a mismatch means one was lost
+    ack: int = 0  # Highest client seq processed so far
+
+    _SCHEMA: ClassVar[tuple] = (
+        ("type", "type", None, False),
+        ("players", "players", ["Player"], True),
+        ("message", "message", None, True),
+        ("maze", "maze", "MazeData", True),
+        ("direction", "direction", None, True),
+        ("state", "state", None, True),
+        ("seconds", "seconds", None, True),
+        ("starts_at", "startsAt", None, True),
+        ("winner", "winner", None, True),
+        ("winning_team", "winningTeam", None, True),
+        ("reason", "reason", None, True),
+        ("summary", "summary", "GameSummary", True),
+        ("items", "items", ["Item"], True),
+        ("cells", "cells", ["Cell"], True),
+        ("position", "position", "Position", True),
+        ("goals", "goals", ["Goal"], True),
+        ("breadcrumbs", "breadcrumbs", ["Breadcrumb"], True),
+        ("flags", "flags", ["Flag"], True),
+        ("hazards", "hazards", ["Hazard"], True),
+        ("path", "path", ["Position"], True),
+        ("vote", "vote", "VoteStatus", True),
+        ("veto", "veto", "VetoStatus", True),
+        ("review", "review", "ReviewStatus", True),
+        ("replay", "replay", "ReplayInfo", True),
+        ("event", "event", "ReplayEvent", True),
+        ("replays", "replays", ["ReplayInfo"], True),
+        ("level", "level", "LevelInfo", True),
+        ("batch", "batch", ["ServerMessage"], True),
+        ("round", "round", None, True),
+        ("hash", "hash", None, True),
+        ("tick", "tick", None, True),
+        ("time", "time", None, True),
+        ("version", "version", None, True),
+        ("rooms", "rooms", ["RoomInfo"], True),
+        ("code", "code", None, True),
+        ("room_id", "roomId", None, True),
+        ("profile", "profile", "Profile", True),
+        ("secret", "secret", None, True),
+        ("rating", "rating", None, True),
+        ("host", "host", None, True),
+        ("daily", "daily", "DailyBoard", True),
+        ("leaderboard", "leaderboard", "Leaderboard", True),
+        ("history", "history", "MatchHistory", True),
+        ("chat", "chat", ["ChatMessage"], True),
+        ("emote", "emote", None, True),
+        ("error", "error", None, True),
+        ("request_id", "requestId", None, True),
+        ("fields", "fields", ["FieldError"], True),
+        ("caps", "caps", [None], True),
+        ("protocol", "protocol", None, True),
+        ("encoding", "encoding", None, True),
+        ("seq", "seq", None, True),
+        ("prev_seq", "prevSeq", None, True),
+        ("ack", "ack", None, True),
+    )
+
+
+@dataclass
+class FieldError(_Message):
+    "FieldError is one problem with a field of a client message"
+
+    field: str = ""  # Path to the field, e.g. x or batch[2].type
+    message: str = ""
+
+    _SCHEMA: ClassVar[tuple] = (
+        ("field", "field", None, False),
+        ("message", "message", None, False),
+    )
+
+
+@dataclass
+class RoomInfo(_Message):
+    "RoomInfo describes a room for lobby discovery"
+
+    id: str = ""
+    players: int = 0
+    width: int = 0
+    height: int = 0
+    state: str = ""  # Room lifecycle: waiting, countdown, playing, paused, finished
+    joinable: bool = False  # In the lobby with a free slot, so new players can take part
+    max_players: int = 0
+    password: bool = False  # Joining needs a password
+    degraded: bool = False  # Over its bandwidth budget
+    rating: int = 0  # Average rating of the players in it
+    mode: str = ""  # Game mode, if not the classic race
+    demo: bool = False  # Bots are playing a demo match to watch until someone joins
+    locked: bool = False  # The host locked it against new players
+    level: str = ""  # Name of the uploaded level it plays, if any
+
+    _SCHEMA: ClassVar[tuple] = (
+        ("id", "id", None, False),
+        ("players", "players", None, False),
+        ("width", "width", None, False),
+        ("height", "height", None, False),
+        ("state", "state", None, False),
+        ("joinable", "joinable", None, False),
+        ("max_players", "maxPlayers", None, True),
+        ("password", "password", None, True),
+        ("degraded", "degraded", None, True),
+        ("rating", "rating", None, True),
+        ("mode", "mode", None, True),
+        ("demo", "demo", None, True),
+        ("locked", "locked", None, True),
+        ("level", "level", None, True),
+    )
+
+
+@dataclass
+class ChatMessage(_Message):
+    "ChatMessage is one line of room chat"
+
+    player_id: str = ""
+    text: str = ""
+    time: int = 0  # Unix milliseconds
+    channel: str = ""  # "" for the whole room, team for the sender's team only
+    team: int = 0  # Sender's team in team rooms
+
+    _SCHEMA: ClassVar[tuple] = (
+        ("player_id", "playerId", None, False),
+        ("text", "text", None, False),
+        ("time", "time", None, False),
+        ("channel", "channel", None, True),
+        ("team", "team", None, True),
+    )
+
+
+@dataclass
+class Item(_Message):
+    "Item is a pickup lying in the maze"
+
+    id: str = ""
+    kind: str = ""
+    x: int = 0
+    y: int = 0
+    z: int = 0  # Floor
+
+    _SCHEMA: ClassVar[tuple] = (
+        ("id", "id", None, False),
+        ("kind", "kind", None, False),
+        ("x", "x", None, False),
+        ("y", "y", None, False),
+        ("z", "z", None, True),
+    )
+
+
+@dataclass
+class GameSummary(_Message):
+    "GameSummary is sent with gameOver to recap the match"
+
+    duration: float = 0.0  # Seconds from start to finish
+    players: List[Player] = field(default_factory=list)
+    awards: List[Award] = field(default_factory=list)
+    ranking: List[str] = field(default_factory=list)  # Player IDs nearest an exit first, when a race runs out of time
+
+    _SCHEMA: ClassVar[tuple] = (
+        ("duration", "duration", None, False),
+        ("players", "players", ["Player"], False),
+        ("awards", "awards", ["Award"], False),
+        ("ranking", "ranking", [None], True),
+    )
+
+
+@dataclass
+class LevelInfo(_Message):
+    "LevelInfo describes an uploaded level, a hand-made maze rooms can be\ncreated to play"
+
+    id: str = ""  # levelId to join with
+    name: str = ""
+    author: str = ""  # Player ID of the uploader
+    width: int = 0
+    height: int = 0
+    created_at: int = 0  # Unix milliseconds
+
+    _SCHEMA: ClassVar[tuple] = (
+        ("id", "id", None, False),
+        ("name", "name", None, False),
+        ("author", "author", None, False),
+        ("width", "width", None, False),
+        ("height", "height", None, False),
+        ("created_at", "createdAt", None, False),
+    )
+
+
+@dataclass
+class ReplayInfo(_Message):
+    "ReplayInfo describes a recorded match"
+
+    id: str = ""
+    room_id: str = ""
+    started_at: int = 0  # Unix milliseconds
+    duration: float = 0.0  # Seconds from start to finish
+    winner: str = ""
+    players: List[Player] = field(default_factory=list)  # As they finished
+
+    _SCHEMA: ClassVar[tuple] = (
+        ("id", "id", None, False),
+        ("room_id", "roomId", None, False),
+        ("started_at", "startedAt", None, False),
+        ("duration", "duration", None, False),
+        ("winner", "winner", None, True),
+        ("players", "players", ["Player"], False),
+    )
+
+
+@dataclass
+class ReplayEvent(_Message):
+    "ReplayEvent is one recorded event of a match"
+
+    t: int = 0  # Milliseconds since play started
+    type: str = ""
+    player_id: str = ""
+    x: int = 0
+    y: int = 0
+    z: int = 0  # Floor
+    value: int = 0
+    detail: str = ""  # Item kind, wall direction
+
+    _SCHEMA: ClassVar[tuple] = (
+        ("t", "t", None, False),
+        ("type", "type", None, False),
+        ("player_id", "playerId", None, True),
+        ("x", "x", None, False),
+        ("y", "y", None, False),
+        ("z", "z", None, True),
+        ("value", "value", None, True),
+        ("detail", "detail", None, True),
+    )
+
+
+@dataclass
+class VoteStatus(_Message):
+    "VoteStatus is the standing of a room vote"
+
+    id: int = 0
+    kind: str = ""
+    target: str = ""  # Player the vote is about
+    size: int = 0  # Maze size asked for
+    started_by: str = ""
+    yes: int = 0
+    no: int = 0
+    eligible: int = 0  # Players who get a say
+    needed: int = 0  # Yes votes that pass it
+    seconds: int = 0  # Left to vote
+    result: str = ""  # passed, failed or cancelled, once ended
+
+    _SCHEMA: ClassVar[tuple] = (
+        ("id", "id", None, False),
+        ("kind", "kind", None, False),
+        ("target", "target", None, True),
+        ("size", "size", None, True),
+        ("started_by", "startedBy", None, False),
+        ("yes", "yes", None, False),
+        ("no", "no", None, False),
+        ("eligible", "eligible", None, False),
+        ("needed", "needed", None, False),
+        ("seconds", "seconds", None, False),
+        ("result", "result", None, True),
+    )
+
+
+@dataclass
+class VetoStatus(_Message):
+    "VetoStatus is the standing of a pre-match map veto"
+
+    candidates: List[MapCandidate] = field(default_factory=list)
+    turn: str = ""  # Player who strikes next
+    seconds: int = 0  # Left in their turn before a random strike
+    chosen: int = 0  # Seed of the maze left over, once decided
+
+    _SCHEMA: ClassVar[tuple] = (
+        ("candidates", "candidates", ["MapCandidate"], False),
+        ("turn", "turn", None, True),
+        ("seconds", "seconds", None, True),
+        ("chosen", "chosen", None, True),
+    )
+
+
+@dataclass
+class ReviewStatus(_Message):
+    "ReviewStatus is where a post-match review stands, with the paths to\ndraw over the maze of the round being reviewed"
+
+    step: int = 0  # Events shown so far
+    steps: int = 0  # Events in the match
+    round: int = 0  # Round the step is in, from 0
+    paths: List[ReviewPath] = field(default_factory=list)  # Where each player has walked this round, up to the step
+    optimal: List[ReviewPath] = field(default_factory=list)  # Shortest way from each player's start to an exit this round
+
+    _SCHEMA: ClassVar[tuple] = (
+        ("step", "step", None, False),
+        ("steps", "steps", None, False),
+        ("round", "round", None, False),
+        ("paths", "paths", ["ReviewPath"], False),
+        ("optimal", "optimal", ["ReviewPath"], False),
+    )
+
+
+@dataclass
+class ReviewPath(_Message):
+    "ReviewPath is one player's path in a review"
+
+    player_id: str = ""
+    path: List[Position] = field(default_factory=list)
+
+    _SCHEMA: ClassVar[tuple] = (
+        ("player_id", "playerId", None, False),
+        ("path", "path", ["Position"], False),
+    )
+
+
+@dataclass
+class MapCandidate(_Message):
+    "MapCandidate is a maze on the veto table"
+
+    seed: int = 0
+    algorithm: str = ""
+    theme: str = ""
+    vetoed_by: str = ""  # Player who struck it
+
+    _SCHEMA: ClassVar[tuple] = (
+        ("seed", "seed", None, False),
+        ("algorithm", "algorithm", None, False),
+        ("theme", "theme", None, False),
+        ("vetoed_by", "vetoedBy", None, True),
+    )
+
+
+@dataclass
+class Award(_Message):
+    "Award is an end-of-match accolade such as MVP or Pathfinder"
+
+    name: str = ""
+    player_id: str = ""
+    value: float = 0.0  # Stat that earned the award
+
+    _SCHEMA: ClassVar[tuple] = (
+        ("name", "name", None, False),
+        ("player_id", "playerId", None, False),
+        ("value", "value", None, False),
+    )
+
+
+@dataclass
+class Player(_Message):
+    "Player represents a player's state"
+
+    id: str = ""
+    name: str = ""
+    color: str = ""  # #rrggbb
+    avatar: str = ""
+    rating: int = 0  # Elo skill rating
+    x: int = 0
+    y: int = 0
+    z: int = 0  # Floor, from 0 at the bottom
+    ready: bool = False
+    away: bool = False  # Backgrounded (AFK)
+    idle: bool = False  # Has done nothing for a while; removed soon if it goes on
+    score: int = 0
+    multiplier: float = 0.0  # Current streak multiplier
+    level: int = 0  # 1 when in a tunnel under a crossing
+    inventory: List[str] = field(default_factory=list)  # Power-ups held
+    wall_charges: int = 0  # Walls the player can still break
+    speed: float = 0.0  # Speed stat: moves are this many times as frequent as the room's base
+    round_wins: int = 0  # Rounds won in a best-of-N match
+    team: int = 0  # Team number from 1 in team rooms
+    team_color: str = ""  # Team's color, to tint the player with
+    bot: bool = False  # Server-controlled player
+
+    _SCHEMA: ClassVar[tuple] = (
+        ("id", "id", None, False),
+        ("name", "name", None, True),
+        ("color", "color", None, True),
+        ("avatar", "avatar", None, True),
+        ("rating", "rating", None, True),
+        ("x", "x", None, False),
+        ("y", "y", None, False),
+        ("z", "z", None, True),
+        ("ready", "ready", None, False),
+        ("away", "away", None, True),
+        ("idle", "idle", None, True),
+        ("score", "score", None, False),
+        ("multiplier", "multiplier", None, False),
+        ("level", "level", None, True),
+        ("inventory", "inventory", [None], True),
+        ("wall_charges", "wallCharges", None, False),
+        ("speed", "speed", None, False),
+        ("round_wins", "roundWins", None, True),
+        ("team", "team", None, True),
+        ("team_color", "teamColor", None, True),
+        ("bot", "bot", None, True),
+    )
+
+
+@dataclass
+class Profile(_Message):
+    "Profile is a player's persistent identity and lifetime stats"
+
+    id: str = ""
+    name: str = ""
+    color: str = ""
+    avatar: str = ""
+    rating: int = 0
+    stats: ProfileStats = field(default_factory=lambda: ProfileStats())
+
+    # Verified players connected with a token, which their ID (and name, if
+    # it has one) come from
+    verified: bool = False
+
+    # TutorialDone players have finished the tutorial
+    tutorial_done: bool = False
+
+    _SCHEMA: ClassVar[tuple] = (
+        ("id", "id", None, False),
+        ("name", "name", None, False),
+        ("color", "color", None, False),
+        ("avatar", "avatar", None, True),
+        ("rating", "rating", None, False),
+        ("stats", "stats", "ProfileStats", False),
+        ("verified", "verified", None, True),
+        ("tutorial_done", "tutorialDone", None, True),
+    )
+
+
+@dataclass
+class ProfileStats(_Message):
+    "ProfileStats are a player's lifetime results"
+
+    matches: int = 0
+    wins: int = 0
+    best_score: int = 0
+    win_rate: float = 0.0  # Share of matches won, 0-1
+    avg_solve_ms: int = 0  # Average time to win by reaching the exit
+
+    _SCHEMA: ClassVar[tuple] = (
+        ("matches", "matches", None, False),
+        ("wins", "wins", None, False),
+        ("best_score", "bestScore", None, False),
+        ("win_rate", "winRate", None, False),
+        ("avg_solve_ms", "avgSolveMs", None, True),
+    )
+
+
+@dataclass
+class MazeData(_Message):
+    "MazeData represents maze data sent to clients"
+
+    width: int = 0
+    height: int = 0
+    floors: int = 0  # Floors joined by stairs, when more than one
+    cells: List[List[Cell]] = field(default_factory=list)  # Ground floor; null under fog (cells arrive via mazeReveal) or when compact
+    upper: List[List[List[Cell]]] = field(default_factory=list)  # Floors above the ground floor, [z-1][y][x]; sent like cells
+    walls: str = ""  # Compact cell grid (compactMaze capability), see CapCompactMaze
+    terrain: str = ""  # Compact terrain, one letter per cell (compactMaze capability)
+    fog: bool = False
+    goal: Position = field(default_factory=lambda: Position())  # Primary exit
+    goals: List[Goal] = field(default_factory=list)  # Every exit with its point value
+    spawns: List[Position] = field(default_factory=list)  # Starting cells, handed out one per player while there are enough
+    portals: List[Portal] = field(default_factory=list)  # Linked pairs of portal cells: stepping on either comes out of the other
+    seed: int = 0  # Share to replay the same maze
+    algorithm: str = ""
+    theme: str = ""  # Tileset to render: hedge, ice, lava
+    loop_factor: float = 0.0
+    rating: Optional[Rating] = None  # How hard the maze is
+
+    _SCHEMA: ClassVar[tuple] = (
+        ("width", "width", None, False),
+        ("height", "height", None, False),
+        ("floors", "floors", None, True),
+        ("cells", "cells", [["Cell"]], False),
+        ("upper", "upper", [[["Cell"]]], True),
+        ("walls", "walls", None, True),
+        ("terrain", "terrain", None, True),
+        ("fog", "fog", None, False),
+        ("goal", "goal", "Position", False),
+        ("goals", "goals", ["Goal"], False),
+        ("spawns", "spawns", ["Position"], False),
+        ("portals", "portals", ["Portal"], True),
+        ("seed", "seed", None, False),
+        ("algorithm", "algorithm", None, False),
+        ("theme", "theme", None, False),
+        ("loop_factor", "loopFactor", None, False),
+        ("rating", "rating", "Rating", True),
+    )
+
+
+@dataclass
+class Rating(_Message):
+    "Rating is a maze's difficulty, from what its solutions look like"
+
+    tier: str = ""  # easy, medium or hard
+    score: int = 0  # 0 (a straight corridor) to 100; medium from 35, hard from 55
+    solution_length: int = 0  # Average distance from a spawn to its nearest exit
+    branch_factor: float = 0.0  # Wrong turns on offer per step of those solutions
+    dead_ends: int = 0  # Cells with one open side
+
+    _SCHEMA: ClassVar[tuple] = (
+        ("tier", "tier", None, False),
+        ("score", "score", None, False),
+        ("solution_length", "solutionLength", None, False),
+        ("branch_factor", "branchFactor", None, False),
+        ("dead_ends", "deadEnds", None, False),
+    )
+
+
+@dataclass
+class Portal(_Message):
+    "Portal links two portal cells both ways"
+
+    a: Position = field(default_factory=lambda: Position())
+    b: Position = field(default_factory=lambda: Position())
+
+    _SCHEMA: ClassVar[tuple] = (
+        ("a", "a", "Position", False),
+        ("b", "b", "Position", False),
+    )
+
+
+@dataclass
+class Goal(_Message):
+    "Goal is an exit cell and what reaching it is worth"
+
+    x: int = 0
+    y: int = 0
+    z: int = 0  # Floor
+    value: int = 0
+
+    _SCHEMA: ClassVar[tuple] = (
+        ("x", "x", None, False),
+        ("y", "y", None, False),
+        ("z", "z", None, True),
+        ("value", "value", None, False),
+    )
+
+
+@dataclass
+class Breadcrumb(_Message):
+    "Breadcrumb is a cell a runner walked out of, shown to hunters"
+
+    player_id: str = ""
+    x: int = 0
+    y: int = 0
+    z: int = 0  # Floor
+    fade: float = 0.0  # 0 when dropped, rising to 1 as it expires
+
+    _SCHEMA: ClassVar[tuple] = (
+        ("player_id", "playerId", None, False),
+        ("x", "x", None, False),
+        ("y", "y", None, False),
+        ("z", "z", None, True),
+        ("fade", "fade", None, False),
+    )
+
+
+@dataclass
+class Hazard(_Message):
+    "Hazard is a server-controlled danger in the maze, such as a minotaur"
+
+    id: str = ""
+    kind: str = ""  # minotaur
+    x: int = 0
+    y: int = 0
+    z: int = 0  # Floor
+    chasing: str = ""  # Player it is charging
+
+    _SCHEMA: ClassVar[tuple] = (
+        ("id", "id", None, False),
+        ("kind", "kind", None, False),
+        ("x", "x", None, False),
+        ("y", "y", None, False),
+        ("z", "z", None, True),
+        ("chasing", "chasing", None, True),
+    )
+
+
+@dataclass
+class MatchHistory(_Message):
+    "MatchHistory is a page of a player's finished matches, newest first"
+
+    player_id: str = ""
+    offset: int = 0
+    total: int = 0  # Matches kept for the player
+    matches: List[MatchSummary] = field(default_factory=list)
+
+    _SCHEMA: ClassVar[tuple] = (
+        ("player_id", "playerId", None, False),
+        ("offset", "offset", None, False),
+        ("total", "total", None, False),
+        ("matches", "matches", ["MatchSummary"], False),
+    )
+
+
+@dataclass
+class MatchSummary(_Message):
+    "MatchSummary is a finished match"
+
+    replay_id: str = ""  # Watch it with watchReplay, while it is kept
+    room_id: str = ""
+    mode: str = ""  # Game mode, if not the classic race
+    daily: str = ""  # Day of the daily challenge, if it was one
+    seed: int = 0  # Seed of the first round's maze
+    started_at: int = 0  # Unix milliseconds
+    duration: float = 0.0  # Seconds from start to finish
+    winner: str = ""
+    reason: str = ""
+    players: List[MatchPlayer] = field(default_factory=list)
+
+    _SCHEMA: ClassVar[tuple] = (
+        ("replay_id", "replayId", None, True),
+        ("room_id", "roomId", None, False),
+        ("mode", "mode", None, True),
+        ("daily", "daily", None, True),
+        ("seed", "seed", None, False),
+        ("started_at", "startedAt", None, False),
+        ("duration", "duration", None, False),
+        ("winner", "winner", None, True),
+        ("reason", "reason", None, True),
+        ("players", "players", ["MatchPlayer"], False),
+    )
+
+
+@dataclass
+class MatchPlayer(_Message):
+    "MatchPlayer is how one player did in a finished match"
+
+    id: str = ""
+    name: str = ""
+    team: int = 0
+    score: int = 0
+    moves: int = 0
+    won: bool = False
+    bot: bool = False
+
+    _SCHEMA: ClassVar[tuple] = (
+        ("id", "id", None, False),
+        ("name", "name", None, True),
+        ("team", "team", None, True),
+        ("score", "score", None, False),
+        ("moves", "moves", None, False),
+        ("won", "won", None, True),
+        ("bot", "bot", None, True),
+    )
+
+
+@dataclass
+class Leaderboard(_Message):
+    "Leaderboard is a page of players ranked on a board in a game mode"
+
+    board: str = ""  # wins, fastest or rating
+    mode: str = ""  # all, classic or a registered mode
+    offset: int = 0
+    total: int = 0  # Players on the whole board
+    entries: List[LeaderboardEntry] = field(default_factory=list)
+
+    _SCHEMA: ClassVar[tuple] = (
+        ("board", "board", None, False),
+        ("mode", "mode", None, False),
+        ("offset", "offset", None, False),
+        ("total", "total", None, False),
+        ("entries", "entries", ["LeaderboardEntry"], False),
+    )
+
+
+@dataclass
+class LeaderboardEntry(_Message):
+    "LeaderboardEntry is one player's place on a leaderboard, with the stats\nevery board ranks on"
+
+    rank: int = 0
+    player_id: str = ""
+    name: str = ""
+    matches: int = 0
+    wins: int = 0
+    best_time_ms: int = 0  # Fastest win by reaching the exit
+    rating: int = 0  # Elo rating in the mode
+
+    _SCHEMA: ClassVar[tuple] = (
+        ("rank", "rank", None, False),
+        ("player_id", "playerId", None, False),
+        ("name", "name", None, True),
+        ("matches", "matches", None, False),
+        ("wins", "wins", None, False),
+        ("best_time_ms", "bestTimeMs", None, True),
+        ("rating", "rating", None, True),
+    )
+
+
+@dataclass
+class DailyBoard(_Message):
+    "DailyBoard is a daily challenge leaderboard"
+
+    day: str = ""  # UTC day as YYYY-MM-DD
+    seed: int = 0
+    entries: List[DailyEntry] = field(default_factory=list)  # Best times, fastest first
+    you: Optional[DailyEntry] = None  # The player's own best time, if they have one
+    improved: bool = False  # The run just finished was the player's best of the day
+
+    _SCHEMA: ClassVar[tuple] = (
+        ("day", "day", None, False),
+        ("seed", "seed", None, False),
+        ("entries", "entries", ["DailyEntry"], False),
+        ("you", "you", "DailyEntry", True),
+        ("improved", "improved", None, True),
+    )
+
+
+@dataclass
+class DailyEntry(_Message):
+    "DailyEntry is one player's place on a daily leaderboard"
+
+    rank: int = 0
+    player_id: str = ""
+    name: str = ""
+    time_ms: int = 0  # From the start of the run to the exit
+
+    _SCHEMA: ClassVar[tuple] = (
+        ("rank", "rank", None, False),
+        ("player_id", "playerId", None, False),
+        ("name", "name", None, True),
+        ("time_ms", "timeMs", None, False),
+    )
+
+
+@dataclass
+class Flag(_Message):
+    "Flag is a team's flag in capture the flag"
+
+    team: int = 0
+    x: int = 0  # Where it is now, following its carrier
+    y: int = 0
+    z: int = 0
+    base: Position = field(default_factory=lambda: Position())  # Where it goes home to, and where its team captures
+    carrier: str = ""  # Player carrying it
+    home: bool = False  # At its base
+    return_in: float = 0.0  # Seconds until a dropped flag goes home by itself
+    captures: int = 0  # Enemy flags its team has captured this round
+
+    _SCHEMA: ClassVar[tuple] = (
+        ("team", "team", None, False),
+        ("x", "x", None, False),
+        ("y", "y", None, False),
+        ("z", "z", None, True),
+        ("base", "base", "Position", False),
+        ("carrier", "carrier", None, True),
+        ("home", "home", None, True),
+        ("return_in", "returnIn", None, True),
+        ("captures", "captures", None, False),
+    )
+
+
+@dataclass
+class Position(_Message):
+    "Position is a cell coordinate"
+
+    x: int = 0
+    y: int = 0
+    z: int = 0  # Floor
+
+    _SCHEMA: ClassVar[tuple] = (
+        ("x", "x", None, False),
+        ("y", "y", None, False),
+        ("z", "z", None, True),
+    )
+
+
+@dataclass
+class Cell(_Message):
+    "Cell represents a maze cell"
+
+    x: int = 0
+    y: int = 0
+    z: int = 0  # Floor
+    top: bool = False
+    right: bool = False
+    bottom: bool = False
+    left: bool = False
+    stairs_up: bool = False  # Stairs to the same cell on the floor above
+    stairs_down: bool = False  # Stairs to the same cell on the floor below
+    terrain: str = ""  # "", mud, road, ice (slide on through), portal (come out of the linked one)
+    under: str = ""  # Crossing cells: axis of the tunnel beneath (horizontal, vertical)
+
+    _SCHEMA: ClassVar[tuple] = (
+        ("x", "x", None, False),
+        ("y", "y", None, False),
+        ("z", "z", None, True),
+        ("top", "top", None, False),
+        ("right", "right", None, False),
+        ("bottom", "bottom", None, False),
+        ("left", "left", None, False),
+        ("stairs_up", "stairsUp", None, True),
+        ("stairs_down", "stairsDown", None, True),
+        ("terrain", "terrain", None, True),
+        ("under", "under", None, True),
+    )
+
+
+_CLASSES = {
+    "ClientMessage": ClientMessage,
+    "ServerMessage": ServerMessage,
+    "FieldError": FieldError,
+    "RoomInfo": RoomInfo,
+    "ChatMessage": ChatMessage,
+    "Item": Item,
+    "GameSummary": GameSummary,
+    "LevelInfo": LevelInfo,
+    "ReplayInfo": ReplayInfo,
+    "ReplayEvent": ReplayEvent,
+    "VoteStatus": VoteStatus,
+    "VetoStatus": VetoStatus,
+    "ReviewStatus": ReviewStatus,
+    "ReviewPath": ReviewPath,
+    "MapCandidate": MapCandidate,
+    "Award": Award,
+    "Player": Player,
+    "Profile": Profile,
+    "ProfileStats": ProfileStats,
+    "MazeData": MazeData,
+    "Rating": Rating,
+    "Portal": Portal,
+    "Goal": Goal,
+    "Breadcrumb": Breadcrumb,
+    "Hazard": Hazard,
+    "MatchHistory": MatchHistory,
+    "MatchSummary": MatchSummary,
+    "MatchPlayer": MatchPlayer,
+    "Leaderboard": Leaderboard,
+    "LeaderboardEntry": LeaderboardEntry,
+    "DailyBoard": DailyBoard,
+    "DailyEntry": DailyEntry,
+    "Flag": Flag,
+    "Position": Position,
+    "Cell": Cell,
+}